@@ -0,0 +1,194 @@
+package main
+
+import (
+	"iter"
+	"sort"
+	"strings"
+)
+
+// ToolCallFunction represents the function invoked by a tool call
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolCall represents a complete tool call requested by the model
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// AccumulatedChoice represents a choice rebuilt from streaming chunks
+type AccumulatedChoice struct {
+	Index        int
+	Role         string
+	Content      string
+	ToolCalls    []ToolCall
+	FinishReason string
+	Logprobs     *ChoiceLogprobs // nil unless logprobs were requested
+}
+
+// ChunkAccumulator rebuilds complete assistant messages from streaming chunks.
+// Feed every chunk to Add in arrival order, then read the final state.
+type ChunkAccumulator struct {
+	ID      string
+	Model   string
+	Created int64
+
+	choices map[int]*accumulatingChoice
+}
+
+// accumulatingChoice holds the in-progress state of a single choice
+type accumulatingChoice struct {
+	role         string
+	content      strings.Builder
+	toolCalls    map[int]*ToolCall
+	arguments    map[int]*strings.Builder
+	finishReason string
+	logprobs     *ChoiceLogprobs
+}
+
+// NewChunkAccumulator creates an empty accumulator
+func NewChunkAccumulator() *ChunkAccumulator {
+	return &ChunkAccumulator{choices: make(map[int]*accumulatingChoice)}
+}
+
+// Add merges a streaming chunk into the accumulated state
+func (a *ChunkAccumulator) Add(chunk ChatCompletionChunk) {
+	if a.choices == nil {
+		a.choices = make(map[int]*accumulatingChoice)
+	}
+	if chunk.ID != "" {
+		a.ID = chunk.ID
+	}
+	if chunk.Model != "" {
+		a.Model = chunk.Model
+	}
+	if chunk.Created != 0 {
+		a.Created = chunk.Created
+	}
+
+	for _, choice := range chunk.Choices {
+		acc, ok := a.choices[choice.Index]
+		if !ok {
+			acc = &accumulatingChoice{
+				toolCalls: make(map[int]*ToolCall),
+				arguments: make(map[int]*strings.Builder),
+			}
+			a.choices[choice.Index] = acc
+		}
+
+		if choice.Delta.Role != "" {
+			acc.role = choice.Delta.Role
+		}
+		acc.content.WriteString(choice.Delta.Content)
+		if choice.FinishReason != "" {
+			acc.finishReason = choice.FinishReason
+		}
+		if choice.Logprobs != nil {
+			if acc.logprobs == nil {
+				acc.logprobs = &ChoiceLogprobs{}
+			}
+			acc.logprobs.Content = append(acc.logprobs.Content, choice.Logprobs.Content...)
+			acc.logprobs.Refusal = append(acc.logprobs.Refusal, choice.Logprobs.Refusal...)
+		}
+
+		// Tool call arguments arrive in fragments keyed by the call index;
+		// the id, type and name are only sent on the first fragment
+		for _, delta := range choice.Delta.ToolCalls {
+			call, ok := acc.toolCalls[delta.Index]
+			if !ok {
+				call = &ToolCall{}
+				acc.toolCalls[delta.Index] = call
+				acc.arguments[delta.Index] = &strings.Builder{}
+			}
+			if delta.ID != "" {
+				call.ID = delta.ID
+			}
+			if delta.Type != "" {
+				call.Type = delta.Type
+			}
+			if delta.Function.Name != "" && call.Function.Name == "" {
+				call.Function.Name = delta.Function.Name
+			}
+			acc.arguments[delta.Index].WriteString(delta.Function.Arguments)
+		}
+	}
+}
+
+// Choices returns the accumulated choices ordered by index
+func (a *ChunkAccumulator) Choices() []AccumulatedChoice {
+	indexes := make([]int, 0, len(a.choices))
+	for i := range a.choices {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	result := make([]AccumulatedChoice, 0, len(indexes))
+	for _, i := range indexes {
+		acc := a.choices[i]
+		choice := AccumulatedChoice{
+			Index:        i,
+			Role:         acc.role,
+			Content:      acc.content.String(),
+			FinishReason: acc.finishReason,
+			Logprobs:     acc.logprobs,
+		}
+		if choice.Role == "" {
+			choice.Role = "assistant"
+		}
+
+		callIndexes := make([]int, 0, len(acc.toolCalls))
+		for ci := range acc.toolCalls {
+			callIndexes = append(callIndexes, ci)
+		}
+		sort.Ints(callIndexes)
+		for _, ci := range callIndexes {
+			call := *acc.toolCalls[ci]
+			call.Function.Arguments = acc.arguments[ci].String()
+			if call.Type == "" {
+				call.Type = "function"
+			}
+			choice.ToolCalls = append(choice.ToolCalls, call)
+		}
+
+		result = append(result, choice)
+	}
+	return result
+}
+
+// Message returns the first accumulated choice, which is the only one unless n > 1 was requested
+func (a *ChunkAccumulator) Message() AccumulatedChoice {
+	choices := a.Choices()
+	if len(choices) == 0 {
+		return AccumulatedChoice{Role: "assistant"}
+	}
+	return choices[0]
+}
+
+// Done reports whether every choice seen so far has received a finish_reason
+func (a *ChunkAccumulator) Done() bool {
+	if len(a.choices) == 0 {
+		return false
+	}
+	for _, acc := range a.choices {
+		if acc.finishReason == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// AccumulateStream drains a chunk sequence into a new accumulator. On error the
+// accumulator holds everything received before the failure.
+func AccumulateStream(stream iter.Seq2[ChatCompletionChunk, error]) (*ChunkAccumulator, error) {
+	acc := NewChunkAccumulator()
+	for chunk, err := range stream {
+		if err != nil {
+			return acc, err
+		}
+		acc.Add(chunk)
+	}
+	return acc, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"iter"
+	"reflect"
+	"testing"
+)
+
+// decodeChunks decodes chunks written as they arrive on the wire
+func decodeChunks(t *testing.T, raw []string) []ChatCompletionChunk {
+	t.Helper()
+	chunks := make([]ChatCompletionChunk, len(raw))
+	for i, r := range raw {
+		if err := json.Unmarshal([]byte(r), &chunks[i]); err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+	}
+	return chunks
+}
+
+func TestChunkAccumulator(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []AccumulatedChoice
+		done   bool
+	}{
+		{
+			name: "content",
+			chunks: []string{
+				`{"id":"c1","model":"m","created":7,"choices":[{"index":0,"delta":{"role":"assistant","content":"Dora "}}]}`,
+				`{"choices":[{"index":0,"delta":{"content":"is a pug."}}]}`,
+				`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+			},
+			want: []AccumulatedChoice{{Role: "assistant", Content: "Dora is a pug.", FinishReason: "stop"}},
+			done: true,
+		},
+		{
+			name: "tool call arguments across chunks",
+			chunks: []string{
+				`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"que"}}]}}]}`,
+				`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ry\":\"Do"}}]}}]}`,
+				`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ra\"}"}}]}}]}`,
+				`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			},
+			want: []AccumulatedChoice{{Role: "assistant", FinishReason: "tool_calls", ToolCalls: []ToolCall{
+				{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "lookup", Arguments: `{"query":"Dora"}`}},
+			}}},
+			done: true,
+		},
+		{
+			name: "parallel tool calls out of order",
+			chunks: []string{
+				`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","function":{"name":"weather","arguments":"{\"city\":"}}]}}]}`,
+				`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"lookup","arguments":"{}"}}]}}]}`,
+				`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"name":"ignored","arguments":"\"Campinas\"}"}}]}}]}`,
+			},
+			want: []AccumulatedChoice{{Role: "assistant", ToolCalls: []ToolCall{
+				{ID: "call_a", Type: "function", Function: ToolCallFunction{Name: "lookup", Arguments: "{}"}},
+				{ID: "call_b", Type: "function", Function: ToolCallFunction{Name: "weather", Arguments: `{"city":"Campinas"}`}},
+			}}},
+		},
+		{
+			name: "several choices",
+			chunks: []string{
+				`{"choices":[{"index":1,"delta":{"content":"Bella"}},{"index":0,"delta":{"content":"Dora"}}]}`,
+				`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+			},
+			want: []AccumulatedChoice{
+				{Index: 0, Role: "assistant", Content: "Dora", FinishReason: "stop"},
+				{Index: 1, Role: "assistant", Content: "Bella"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := NewChunkAccumulator()
+			for _, chunk := range decodeChunks(t, tt.chunks) {
+				acc.Add(chunk)
+			}
+			if got := acc.Choices(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Choices() = %+v\nwant %+v", got, tt.want)
+			}
+			if acc.Done() != tt.done {
+				t.Fatalf("Done() = %v, want %v", acc.Done(), tt.done)
+			}
+		})
+	}
+}
+
+func TestChunkAccumulatorEmpty(t *testing.T) {
+	var acc ChunkAccumulator
+	if acc.Done() {
+		t.Fatal("an empty accumulator is done")
+	}
+	if msg := acc.Message(); msg.Role != "assistant" || msg.Content != "" {
+		t.Fatalf("Message() = %+v, want an empty assistant message", msg)
+	}
+	// The zero value accepts chunks too
+	acc.Add(ChatCompletionChunk{ID: "c1", Choices: []ChunkChoice{{Delta: ChunkDelta{Content: "hi"}}}})
+	if acc.ID != "c1" || acc.Message().Content != "hi" {
+		t.Fatalf("zero-value accumulator lost the chunk: %+v", acc.Message())
+	}
+}
+
+func TestAccumulateStreamKeepsPartialState(t *testing.T) {
+	failure := errors.New("connection reset")
+	chunks := decodeChunks(t, []string{
+		`{"id":"c1","choices":[{"index":0,"delta":{"content":"Dora is"}}]}`,
+	})
+	stream := iter.Seq2[ChatCompletionChunk, error](func(yield func(ChatCompletionChunk, error) bool) {
+		if yield(chunks[0], nil) {
+			yield(ChatCompletionChunk{}, failure)
+		}
+	})
+	acc, err := AccumulateStream(stream)
+	if !errors.Is(err, failure) {
+		t.Fatalf("err = %v, want %v", err, failure)
+	}
+	if got := acc.Message().Content; got != "Dora is" {
+		t.Fatalf("content before the failure = %q, want %q", got, "Dora is")
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// builtinToolgroupTools maps built-in toolgroups to the tools they provide
+var builtinToolgroupTools = map[string][]string{
+	"builtin::rag":              {"knowledge_search", "insert_into_memory"},
+	"builtin::websearch":        {"web_search"},
+	"builtin::code_interpreter": {"code_interpreter"},
+	"builtin::wolfram_alpha":    {"wolfram_alpha"},
+}
+
+// AgentConfigBuilder builds an AgentConfig step by step; finish with Build
+type AgentConfigBuilder struct {
+	cfg    AgentConfig
+	rag    map[string]interface{} // args of the builtin::rag toolgroup, once added
+	prompt *PromptRef             // stored prompt to use as instructions
+}
+
+// NewAgentConfig starts a builder for an agent running model
+func NewAgentConfig(model string) *AgentConfigBuilder {
+	return &AgentConfigBuilder{cfg: AgentConfig{Model: model}}
+}
+
+// Instructions sets the system prompt
+func (b *AgentConfigBuilder) Instructions(instructions string) *AgentConfigBuilder {
+	b.cfg.Instructions = instructions
+	return b
+}
+
+// InstructionsFromPrompt sets the system prompt to a prompt stored with the
+// Prompts API; finish with BuildWithClient, which fetches and renders it
+func (b *AgentConfigBuilder) InstructionsFromPrompt(ref PromptRef) *AgentConfigBuilder {
+	b.prompt = &ref
+	return b
+}
+
+// Name sets the agent name
+func (b *AgentConfigBuilder) Name(name string) *AgentConfigBuilder {
+	b.cfg.Name = name
+	return b
+}
+
+// Description sets the agent description
+func (b *AgentConfigBuilder) Description(description string) *AgentConfigBuilder {
+	b.cfg.Description = description
+	return b
+}
+
+// ToolChoice sets tool_choice: "auto", "required", "none" or a tool name
+func (b *AgentConfigBuilder) ToolChoice(choice string) *AgentConfigBuilder {
+	b.cfg.ToolChoice = choice
+	return b
+}
+
+// ToolPromptFormat sets how tool definitions are presented to the model, e.g. "python_list"
+func (b *AgentConfigBuilder) ToolPromptFormat(format string) *AgentConfigBuilder {
+	b.cfg.ToolPromptFormat = format
+	return b
+}
+
+// MaxInferIters caps the inference iterations of a single turn
+func (b *AgentConfigBuilder) MaxInferIters(n int) *AgentConfigBuilder {
+	b.cfg.MaxInferIters = n
+	return b
+}
+
+// Sampling sets the sampling parameters
+func (b *AgentConfigBuilder) Sampling(params SamplingParams) *AgentConfigBuilder {
+	b.cfg.SamplingParams = &params
+	return b
+}
+
+// WithRAG adds the builtin::rag toolgroup searching vectorDBIDs. Calling it
+// again adds more vector DBs to the same toolgroup.
+func (b *AgentConfigBuilder) WithRAG(vectorDBIDs ...string) *AgentConfigBuilder {
+	if b.rag == nil {
+		b.rag = map[string]interface{}{"vector_db_ids": []string{}}
+		b.cfg.Toolgroups = append(b.cfg.Toolgroups, map[string]interface{}{
+			"name": "builtin::rag",
+			"args": b.rag,
+		})
+	}
+	b.rag["vector_db_ids"] = append(b.rag["vector_db_ids"].([]string), vectorDBIDs...)
+	return b
+}
+
+// WithRAGQueryConfig sets how the RAG toolgroup retrieves chunks; it adds the
+// toolgroup if WithRAG has not been called yet
+func (b *AgentConfigBuilder) WithRAGQueryConfig(cfg RAGQueryConfig) *AgentConfigBuilder {
+	b.WithRAG()
+	b.rag["query_config"] = cfg
+	return b
+}
+
+// WithWebSearch adds the builtin::websearch toolgroup
+func (b *AgentConfigBuilder) WithWebSearch() *AgentConfigBuilder {
+	b.cfg.Toolgroups = append(b.cfg.Toolgroups, "builtin::websearch")
+	return b
+}
+
+// WithCodeInterpreter adds the builtin::code_interpreter toolgroup, which
+// runs Python the model writes on the server
+func (b *AgentConfigBuilder) WithCodeInterpreter() *AgentConfigBuilder {
+	b.cfg.Toolgroups = append(b.cfg.Toolgroups, "builtin::code_interpreter")
+	return b
+}
+
+// WithToolgroup adds any other registered toolgroup by name
+func (b *AgentConfigBuilder) WithToolgroup(name string) *AgentConfigBuilder {
+	b.cfg.Toolgroups = append(b.cfg.Toolgroups, name)
+	return b
+}
+
+// WithClientTools adds tools the client executes itself
+func (b *AgentConfigBuilder) WithClientTools(tools ...SpecToolDef) *AgentConfigBuilder {
+	b.cfg.ClientTools = append(b.cfg.ClientTools, tools...)
+	return b
+}
+
+// WithShields applies the shields to both input and output
+func (b *AgentConfigBuilder) WithShields(shields ...string) *AgentConfigBuilder {
+	b.cfg.InputShields = append(b.cfg.InputShields, shields...)
+	b.cfg.OutputShields = append(b.cfg.OutputShields, shields...)
+	return b
+}
+
+// Build validates and returns the config
+func (b *AgentConfigBuilder) Build() (AgentConfig, error) {
+	if b.prompt != nil {
+		return AgentConfig{}, fmt.Errorf("instructions refer to stored prompt %s; use BuildWithClient", b.prompt.ID)
+	}
+	if err := b.cfg.Validate(); err != nil {
+		return AgentConfig{}, err
+	}
+	return b.cfg, nil
+}
+
+// BuildWithClient renders the stored prompt set with InstructionsFromPrompt
+// as the instructions, then validates and returns the config
+func (b *AgentConfigBuilder) BuildWithClient(ctx context.Context, client *LlamaStackClient) (AgentConfig, error) {
+	if b.prompt != nil {
+		instructions, err := client.RenderPrompt(ctx, *b.prompt)
+		if err != nil {
+			return AgentConfig{}, err
+		}
+		b.cfg.Instructions = instructions
+		b.prompt = nil
+	}
+	return b.Build()
+}
+
+// Validate checks the config for mistakes the server would reject, or
+// silently ignore, such as a tool_choice naming a tool no toolgroup provides
+func (cfg AgentConfig) Validate() error {
+	var errs []error
+	if cfg.Model == "" {
+		errs = append(errs, errors.New("model is required"))
+	}
+	if cfg.Instructions == "" {
+		errs = append(errs, errors.New("instructions are required"))
+	}
+	if cfg.MaxInferIters < 0 {
+		errs = append(errs, fmt.Errorf("max_infer_iters must not be negative, got %d", cfg.MaxInferIters))
+	}
+
+	// Collect the tools the agent can call; toolgroups other than the
+	// built-in ones are opaque, so any named tool may come from them
+	tools := make(map[string]bool)
+	opaque := false
+	seen := make(map[string]bool)
+	for i, tg := range cfg.Toolgroups {
+		name, args, err := parseToolgroup(tg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("toolgroups[%d]: %w", i, err))
+			continue
+		}
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("toolgroup %s is listed more than once", name))
+		}
+		seen[name] = true
+
+		provided, builtin := builtinToolgroupTools[name]
+		if !builtin {
+			opaque = true
+		}
+		for _, t := range provided {
+			tools[t] = true
+		}
+		if name == "builtin::rag" && len(stringList(args["vector_db_ids"])) == 0 {
+			errs = append(errs, errors.New("toolgroup builtin::rag needs at least one vector_db_ids entry"))
+		}
+		if name == "builtin::rag" && args["query_config"] != nil {
+			if err := validateToolgroupQueryConfig(args["query_config"]); err != nil {
+				errs = append(errs, fmt.Errorf("toolgroup builtin::rag query_config: %w", err))
+			}
+		}
+	}
+	for _, t := range cfg.ClientTools {
+		tools[t.Name] = true
+	}
+	for _, t := range cfg.Tools {
+		if fn, ok := t["function"].(map[string]interface{}); ok {
+			if name, ok := fn["name"].(string); ok {
+				tools[name] = true
+			}
+		}
+	}
+
+	hasTools := len(tools) > 0 || opaque
+	switch cfg.ToolChoice {
+	case "", "auto", "none":
+	case "required":
+		if !hasTools {
+			errs = append(errs, errors.New("tool_choice is required but no toolgroups or client tools are configured"))
+		}
+	default:
+		if !tools[cfg.ToolChoice] && !opaque {
+			errs = append(errs, fmt.Errorf("tool_choice %q does not match any configured tool", cfg.ToolChoice))
+		}
+	}
+	if cfg.ToolConfig != nil && cfg.ToolChoice != "" {
+		if choice, ok := cfg.ToolConfig.ToolChoice.(string); ok && choice != "" && choice != cfg.ToolChoice {
+			errs = append(errs, fmt.Errorf("tool_choice %q conflicts with tool_config.tool_choice %q", cfg.ToolChoice, choice))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateToolgroupQueryConfig validates a query_config set by the builder or
+// decoded from a preset
+func validateToolgroupQueryConfig(v interface{}) error {
+	var cfg RAGQueryConfig
+	switch qc := v.(type) {
+	case RAGQueryConfig:
+		cfg = qc
+	case *RAGQueryConfig:
+		cfg = *qc
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.Rewrite != nil || cfg.Filter != nil {
+		return errors.New("rewrite and filter are client-side options and are ignored by agents")
+	}
+	return cfg.Validate()
+}
+
+// parseToolgroup returns the name and args of a toolgroup given either as a
+// plain name or as a {"name", "args"} object
+func parseToolgroup(tg interface{}) (string, map[string]interface{}, error) {
+	switch v := tg.(type) {
+	case string:
+		if v == "" {
+			return "", nil, errors.New("empty toolgroup name")
+		}
+		return v, nil, nil
+	case map[string]interface{}:
+		name, _ := v["name"].(string)
+		if name == "" {
+			return "", nil, errors.New("toolgroup object has no name")
+		}
+		args, _ := v["args"].(map[string]interface{})
+		return name, args, nil
+	}
+	return "", nil, fmt.Errorf("unsupported toolgroup type %T", tg)
+}
+
+// RAGVectorDBIDs returns the vector DBs the builtin::rag toolgroup of cfg
+// searches, so client-side knowledge_search handlers can query the same ones
+func RAGVectorDBIDs(cfg AgentConfig) []string {
+	var ids []string
+	for _, tg := range cfg.Toolgroups {
+		name, args, err := parseToolgroup(tg)
+		if err != nil || (name != "builtin::rag" && !strings.HasPrefix(name, "builtin::rag/")) {
+			continue
+		}
+		for _, id := range stringList(args["vector_db_ids"]) {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// stringList converts a decoded JSON array, or a []string, to []string
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		var result []string
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxAgentIterations caps the turn and resume requests of one run
+	DefaultMaxAgentIterations = 10
+	// DefaultMaxIdenticalToolCalls is how often a run lets the model make the
+	// same tool call with the same arguments
+	DefaultMaxIdenticalToolCalls = 2
+	// DefaultToolTimeout bounds a single client-side tool call
+	DefaultToolTimeout = 30 * time.Second
+	// DefaultMaxParallelTools bounds the tool calls of one turn run at once
+	DefaultMaxParallelTools = 4
+	// DefaultToolRetryDelay is the delay before the first retry of a tool call
+	DefaultToolRetryDelay = 500 * time.Millisecond
+)
+
+// AgentToolCall is a client-side tool call a turn is awaiting input for
+type AgentToolCall struct {
+	CallID    string
+	ToolName  string
+	Arguments interface{}
+}
+
+// AgentToolHandler executes a client-side tool call and returns its text
+// output. ctx is done when the call times out or the turn is abandoned.
+// Return a *ToolError to tell the model exactly what went wrong.
+type AgentToolHandler func(ctx context.Context, call AgentToolCall) (string, error)
+
+// ToolError is a tool failure to report to the model as it is, e.g. "no
+// documents matched, try a broader query", so it can recover instead of
+// seeing a raw error. It is sent as the call's tool response and never
+// retried.
+type ToolError struct {
+	Message string // text of the tool response
+	Err     error  // underlying error for logs, if any
+}
+
+// NewToolError creates a ToolError sending message to the model
+func NewToolError(message string, err error) *ToolError {
+	return &ToolError{Message: message, Err: err}
+}
+
+func (e *ToolError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *ToolError) Unwrap() error { return e.Err }
+
+// ToolRetryPolicy retries a client-side tool call that failed transiently,
+// e.g. a RAG query answered with a 503, before the failure is reported to
+// the model
+type ToolRetryPolicy struct {
+	MaxRetries int           // retries after the first attempt; zero means none
+	Delay      time.Duration // delay before the first retry, doubled for each further retry; zero means DefaultToolRetryDelay
+	// Retryable reports whether an error is worth another attempt. By
+	// default 429s, 5xx responses and network errors are retried.
+	Retryable func(err error) bool
+}
+
+// retryable reports whether a failed attempt is worth another one. Timeouts
+// and ToolErrors are final: a retry would only delay the turn.
+func (p ToolRetryPolicy) retryable(err error) bool {
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return isTransientToolError(err)
+}
+
+// isTransientToolError reports whether a tool failed on a retryable API
+// response or a network error. Other handler errors, such as bad arguments,
+// would fail again.
+func isTransientToolError(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrStreamInterrupted)
+}
+
+// LoopAbortReason says why an AgentRunner stopped before the turn completed
+type LoopAbortReason string
+
+const (
+	LoopMaxIterations    LoopAbortReason = "max_iterations"
+	LoopRepeatedToolCall LoopAbortReason = "repeated_tool_call"
+	LoopNoToolCalls      LoopAbortReason = "no_tool_calls"
+	LoopNoToolResponses  LoopAbortReason = "no_tool_responses"
+	LoopNoTurn           LoopAbortReason = "no_turn"
+)
+
+// LoopAborted is returned by AgentRunner.Run when it stops a turn that did
+// not complete
+type LoopAborted struct {
+	Reason     LoopAbortReason
+	Iterations int    // turn and resume requests made
+	TurnID     string // the turn left awaiting input, if any
+	// ToolCall is the call made too often, for LoopRepeatedToolCall
+	ToolCall *AgentToolCall
+	// Turn is the last turn received, if any
+	Turn *Turn
+}
+
+func (e *LoopAborted) Error() string {
+	var detail string
+	switch e.Reason {
+	case LoopMaxIterations:
+		detail = "turn did not complete within the iteration limit"
+	case LoopRepeatedToolCall:
+		detail = fmt.Sprintf("tool %s was called again with the same arguments %v", e.ToolCall.ToolName, e.ToolCall.Arguments)
+	case LoopNoToolCalls:
+		detail = "turn is awaiting input without tool calls"
+	case LoopNoToolResponses:
+		detail = "no tool call could be handled by the client"
+	case LoopNoTurn:
+		detail = "stream ended without a completed or awaiting_input turn"
+	default:
+		detail = string(e.Reason)
+	}
+	return fmt.Sprintf("agent loop aborted after %d iterations: %s", e.Iterations, detail)
+}
+
+// AgentRunner drives the client-side agentic loop: it creates a turn, runs
+// the tool calls the turn pauses for and resumes it, until the turn
+// completes or a safeguard stops it with a *LoopAborted error
+type AgentRunner struct {
+	Client *LlamaStackClient
+	// Tools handle client-side tool calls. A call goes to the tool of the
+	// same name, else to the longest one whose name it contains, so
+	// "knowledge_search" also handles "builtin::rag/knowledge_search". Other
+	// calls are answered with an error tool response.
+	Tools map[string]AgentToolHandler
+	// MaxIterations caps the turn and resume requests of a run; zero means
+	// DefaultMaxAgentIterations
+	MaxIterations int
+	// MaxIdenticalToolCalls is how often the same tool may be called with the
+	// same arguments in a run before it is aborted; zero means
+	// DefaultMaxIdenticalToolCalls
+	MaxIdenticalToolCalls int
+	// ToolTimeout bounds each tool call; zero means DefaultToolTimeout.
+	// ToolTimeouts overrides it by the name a tool is registered under.
+	ToolTimeout  time.Duration
+	ToolTimeouts map[string]time.Duration
+	// ToolRetry retries tool calls that fail transiently; the zero value
+	// does not retry. ToolRetries overrides it by registered name. Each
+	// attempt gets the tool's full timeout.
+	ToolRetry   ToolRetryPolicy
+	ToolRetries map[string]ToolRetryPolicy
+	// ToolResultTransformer post-processes successful tool output before it
+	// is sent back, e.g. TruncateToolResults to keep the context window
+	// under control. ToolResultTransformers overrides it by registered name;
+	// map a tool to nil to send its output as it is.
+	ToolResultTransformer  ToolResultTransformer
+	ToolResultTransformers map[string]ToolResultTransformer
+	// MaxParallelTools bounds the calls of one turn run concurrently; zero
+	// means DefaultMaxParallelTools and 1 runs them one after another
+	MaxParallelTools int
+	// Titler, if set, titles the session in the background after its first
+	// completed turn
+	Titler *AutoTitler
+	// Moderator, if set, checks the user messages before the turn is
+	// created. Blocked input fails the run with a *ModerationBlocked error,
+	// as does a failed moderation call, so nothing is sent unchecked.
+	Moderator *Moderator
+	// Progress, if set, shows what the turn is doing step by step while the
+	// run is in progress
+	Progress *TurnProgress
+}
+
+// Run sends messages as a new turn and returns the completed turn
+func (r *AgentRunner) Run(ctx context.Context, agentID, sessionID string, messages []Message) (*Turn, error) {
+	defer r.Progress.Done()
+	maxIterations := r.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxAgentIterations
+	}
+	maxIdentical := r.MaxIdenticalToolCalls
+	if maxIdentical <= 0 {
+		maxIdentical = DefaultMaxIdenticalToolCalls
+	}
+
+	if r.Moderator != nil {
+		if _, err := r.Moderator.CheckMessages(ctx, messages); err != nil {
+			return nil, err
+		}
+	}
+
+	stream := true
+	url := fmt.Sprintf("%s/v1/agents/%s/session/%s/turn", r.Client.BaseURL, agentID, sessionID)
+	var payload interface{} = TurnCreateParams{Messages: messages, Stream: &stream}
+	seen := make(map[string]int) // calls made so far by tool name and arguments
+	var last *Turn
+
+	for iteration := 1; ; iteration++ {
+		if iteration > maxIterations {
+			return nil, &LoopAborted{Reason: LoopMaxIterations, Iterations: iteration - 1, TurnID: last.TurnID, Turn: last}
+		}
+		turn, eventType, err := r.postTurn(ctx, url, payload)
+		if err != nil {
+			return nil, err
+		}
+		if turn == nil {
+			return nil, &LoopAborted{Reason: LoopNoTurn, Iterations: iteration, Turn: last}
+		}
+		last = turn
+		if eventType == "turn_complete" {
+			if r.Titler != nil {
+				r.Titler.Title(sessionID, append(messages, turn.OutputMessage))
+			}
+			return turn, nil
+		}
+
+		calls := AgentToolCalls(turn)
+		if len(calls) == 0 {
+			return nil, &LoopAborted{Reason: LoopNoToolCalls, Iterations: iteration, TurnID: turn.TurnID, Turn: turn}
+		}
+		for i, call := range calls {
+			key := toolCallKey(call)
+			seen[key]++
+			if seen[key] > maxIdentical {
+				return nil, &LoopAborted{Reason: LoopRepeatedToolCall, Iterations: iteration, TurnID: turn.TurnID, ToolCall: &calls[i], Turn: turn}
+			}
+		}
+
+		r.Progress.ToolsStarted(calls)
+		responses, err := r.runTools(ctx, calls)
+		if err != nil {
+			return nil, err
+		}
+		if len(responses) == 0 {
+			return nil, &LoopAborted{Reason: LoopNoToolResponses, Iterations: iteration, TurnID: turn.TurnID, Turn: turn}
+		}
+		r.Progress.ToolsFinished(responses)
+		url = fmt.Sprintf("%s/v1/agents/%s/session/%s/turn/%s/resume", r.Client.BaseURL, agentID, sessionID, turn.TurnID)
+		payload = SpecResumeAgentTurnRequest{Stream: &stream, ToolResponses: responses}
+	}
+}
+
+// handler returns the name and handler of the tool handling a call named
+// name, or a nil handler. Without an exact match, the longest registered
+// name contained in name wins, the first in sort order on a tie, so
+// "web_search" rather than "search" handles "brave_web_search".
+func (r *AgentRunner) handler(name string) (string, AgentToolHandler) {
+	if h, ok := r.Tools[name]; ok {
+		return name, h
+	}
+	best := ""
+	for tool := range r.Tools {
+		if strings.Contains(name, tool) && (len(tool) > len(best) || len(tool) == len(best) && tool < best) {
+			best = tool
+		}
+	}
+	if best == "" {
+		return "", nil
+	}
+	return best, r.Tools[best]
+}
+
+// toolTimeout returns the deadline of calls to the tool registered as tool
+func (r *AgentRunner) toolTimeout(tool string) time.Duration {
+	if d, ok := r.ToolTimeouts[tool]; ok && d > 0 {
+		return d
+	}
+	if r.ToolTimeout > 0 {
+		return r.ToolTimeout
+	}
+	return DefaultToolTimeout
+}
+
+// toolRetry returns the retry policy of the tool registered as tool
+func (r *AgentRunner) toolRetry(tool string) ToolRetryPolicy {
+	if p, ok := r.ToolRetries[tool]; ok {
+		return p
+	}
+	return r.ToolRetry
+}
+
+// runTools executes the calls of a paused turn on a bounded pool and
+// returns a response for each, in call order. A call without a handler,
+// whose handler fails or that times out is answered with an error tool
+// response, so one failure does not lose the others. No responses are
+// returned if no call has a handler. If ctx is done the turn is abandoned,
+// outstanding handlers are cancelled and ctx's error returned.
+func (r *AgentRunner) runTools(ctx context.Context, calls []AgentToolCall) ([]SpecToolResponse, error) {
+	handled := false
+	for _, call := range calls {
+		if _, h := r.handler(call.ToolName); h != nil {
+			handled = true
+		}
+	}
+	if !handled {
+		r.Client.logger().WarnContext(ctx, "no handler for any tool call", "calls", len(calls))
+		return nil, nil
+	}
+
+	parallel := r.MaxParallelTools
+	if parallel <= 0 {
+		parallel = DefaultMaxParallelTools
+	}
+	responses := make([]SpecToolResponse, len(calls))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < parallel && w < len(calls); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				responses[i] = r.runCall(ctx, calls[i])
+			}
+		}()
+	}
+
+feed:
+	for i := range calls {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return responses, nil
+}
+
+// runCall executes one tool call and returns its tool response
+func (r *AgentRunner) runCall(ctx context.Context, call AgentToolCall) SpecToolResponse {
+	tool, h := r.handler(call.ToolName)
+	if h == nil {
+		r.Client.logger().WarnContext(ctx, "no handler for tool call", "tool", call.ToolName, "call_id", call.CallID)
+		return toolErrorResponse(call, fmt.Errorf("tool %s is not available on the client", call.ToolName))
+	}
+	timeout := r.toolTimeout(tool)
+	text, attempts, err := r.runToolWithRetries(ctx, h, call, timeout, r.toolRetry(tool))
+
+	var toolErr *ToolError
+	switch {
+	case errors.As(err, &toolErr):
+		r.Client.logger().InfoContext(ctx, "tool reported an error", "tool", call.ToolName, "call_id", call.CallID, "error", err)
+		return SpecToolResponse{
+			CallID:   call.CallID,
+			ToolName: call.ToolName,
+			Content:  map[string]interface{}{"type": "text", "text": toolErr.Message},
+			Metadata: map[string]interface{}{"error": err.Error()},
+		}
+	case err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded):
+		r.Client.logger().WarnContext(ctx, "tool call timed out", "tool", call.ToolName, "call_id", call.CallID, "timeout", timeout)
+		return toolErrorResponse(call, fmt.Errorf("tool %s timed out after %s", call.ToolName, timeout))
+	case err != nil:
+		r.Client.logger().WarnContext(ctx, "tool call failed", "tool", call.ToolName, "call_id", call.CallID, "attempts", attempts, "error", err)
+		if attempts > 1 {
+			err = fmt.Errorf("failed after %d attempts: %w", attempts, err)
+		}
+		return toolErrorResponse(call, err)
+	}
+	return SpecToolResponse{
+		CallID:   call.CallID,
+		ToolName: call.ToolName,
+		Content:  map[string]interface{}{"type": "text", "text": r.transformToolResult(ctx, tool, call, text)},
+	}
+}
+
+// runToolWithRetries runs a tool call, retrying transient failures as the
+// policy allows, and returns its output and the attempts made
+func (r *AgentRunner) runToolWithRetries(ctx context.Context, h AgentToolHandler, call AgentToolCall, timeout time.Duration, policy ToolRetryPolicy) (string, int, error) {
+	delay := policy.Delay
+	if delay <= 0 {
+		delay = DefaultToolRetryDelay
+	}
+	attempts := 1
+	text, err := runTool(ctx, h, call, timeout)
+	for err != nil && attempts <= policy.MaxRetries && ctx.Err() == nil && policy.retryable(err) {
+		r.Client.logger().WarnContext(ctx, "retrying tool call", "tool", call.ToolName, "call_id", call.CallID, "attempt", attempts, "delay", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", attempts, ctx.Err()
+		}
+		delay *= 2
+		attempts++
+		text, err = runTool(ctx, h, call, timeout)
+	}
+	return text, attempts, err
+}
+
+// runTool calls h with a context that is done after timeout. The handler
+// runs on its own goroutine so one that ignores its context cannot hang the
+// turn; it is left to finish in the background.
+func runTool(ctx context.Context, h AgentToolHandler, call AgentToolCall, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		text string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		text, err := h(ctx, call)
+		done <- result{text, err}
+	}()
+	select {
+	case res := <-done:
+		return res.text, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// toolErrorResponse reports a failed tool call back to the model
+func toolErrorResponse(call AgentToolCall, err error) SpecToolResponse {
+	return SpecToolResponse{
+		CallID:   call.CallID,
+		ToolName: call.ToolName,
+		Content:  map[string]interface{}{"type": "text", "text": "Error: " + err.Error()},
+		Metadata: map[string]interface{}{"error": err.Error()},
+	}
+}
+
+// postTurn sends a turn or resume request and reads its stream up to the
+// completed or awaiting_input turn, returning nil if there was none. An event
+// that cannot be decoded ends the turn with its *StreamDecodeError.
+func (r *AgentRunner) postTurn(ctx context.Context, url string, payload interface{}) (*Turn, string, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal turn params: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+r.Client.APIKey)
+
+	resp, err := r.Client.doStream(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		data, _ := io.ReadAll(resp.Body)
+		return nil, "", newAPIError(resp, data)
+	}
+
+	var turn *Turn
+	var eventType string
+	var decodeErr error
+	err = readSSE(resp.Body, func(ev sseEvent) bool {
+		var sse struct {
+			Event struct {
+				Payload TurnEvent `json:"payload"`
+			} `json:"event"`
+		}
+		if err := decodeSSEData(ev.Data, &sse); err != nil {
+			decodeErr = err
+			return false
+		}
+		payload := sse.Event.Payload
+		r.Client.logger().DebugContext(ctx, "turn event", "event_type", payload.EventType)
+		r.Progress.Event(payload)
+		if (payload.EventType == "turn_complete" || payload.EventType == "turn_awaiting_input") && payload.Turn != nil {
+			turn, eventType = payload.Turn, payload.EventType
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading stream: %w", err)
+	}
+	if decodeErr != nil {
+		return nil, "", decodeErr
+	}
+	return turn, eventType, nil
+}
+
+// AgentToolCalls returns the tool calls of a turn's tool_execution steps
+func AgentToolCalls(turn *Turn) []AgentToolCall {
+	var calls []AgentToolCall
+	for _, step := range turn.Steps {
+		stepMap, ok := step.(map[string]interface{})
+		if !ok || stepMap["step_type"] != "tool_execution" {
+			continue
+		}
+		raw, _ := stepMap["tool_calls"].([]interface{})
+		for _, c := range raw {
+			callMap, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			callID, _ := callMap["call_id"].(string)
+			toolName, _ := callMap["tool_name"].(string)
+			calls = append(calls, AgentToolCall{CallID: callID, ToolName: toolName, Arguments: callMap["arguments"]})
+		}
+	}
+	return calls
+}
+
+// toolCallKey identifies a call by tool name and arguments; map keys are
+// marshalled sorted, so equal arguments give equal keys
+func toolCallKey(call AgentToolCall) string {
+	args, err := json.Marshal(call.Arguments)
+	if err != nil {
+		args = []byte(fmt.Sprint(call.Arguments))
+	}
+	return call.ToolName + "\x00" + string(args)
+}
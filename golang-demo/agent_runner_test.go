@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newMockAgent starts a mock stack and creates an agent with cfg and a
+// session for it
+func newMockAgent(t *testing.T, cfg AgentConfig) (*LlamaStackClient, string, string) {
+	t.Helper()
+	mock := NewMockStack()
+	t.Cleanup(mock.Close)
+	client := NewLlamaStackClient(mock.URL(), "test")
+
+	ctx := context.Background()
+	agent, err := client.CreateAgent(ctx, AgentCreateParams{AgentConfig: cfg})
+	if err != nil {
+		t.Fatalf("CreateAgent: %v", err)
+	}
+	session, err := client.CreateSession(ctx, agent.AgentID, SessionCreateParams{SessionName: "test"})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return client, agent.AgentID, session.SessionID
+}
+
+// lookupAgent is an agent with a single client tool, which the mock calls
+// with the prompt as its query
+func lookupAgent() AgentConfig {
+	return AgentConfig{
+		Model:        "m1",
+		Instructions: "Answer with the lookup tool.",
+		ClientTools: []SpecToolDef{{
+			Name:       "lookup",
+			Parameters: []SpecToolParameter{{Name: "query", ParameterType: "string", Description: "what to look up"}},
+		}},
+	}
+}
+
+func TestAgentRunnerClientTool(t *testing.T) {
+	client, agentID, sessionID := newMockAgent(t, lookupAgent())
+
+	var query interface{}
+	runner := &AgentRunner{
+		Client: client,
+		Tools: map[string]AgentToolHandler{
+			"lookup": func(ctx context.Context, call AgentToolCall) (string, error) {
+				query = call.Arguments.(map[string]interface{})["query"]
+				return "Dora belongs to Edson", nil
+			},
+		},
+	}
+	turn, err := runner.Run(context.Background(), agentID, sessionID, []Message{{Role: "user", Content: "Who owns Dora?"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if query != "Who owns Dora?" {
+		t.Fatalf("tool called with query %v", query)
+	}
+	if got := turn.OutputMessage.Content; !strings.Contains(got, "Dora belongs to Edson") {
+		t.Fatalf("answer %q does not use the tool output", got)
+	}
+}
+
+func TestAgentRunnerRAG(t *testing.T) {
+	client, agentID, sessionID := newMockAgent(t, AgentConfig{
+		Model:        "m1",
+		Instructions: "Answer from the documents.",
+		Toolgroups:   []interface{}{map[string]interface{}{"name": "builtin::rag", "args": map[string]interface{}{"vector_db_ids": []string{"vs1"}}}},
+	})
+	ctx := context.Background()
+	err := client.InsertDocumentsIntoRAG(ctx, RagToolInsertParams{
+		VectorDBID: "vs1",
+		Documents:  []Document{{DocumentID: "dora.txt", Content: "Dora is a dog owned by Edson.", Metadata: map[string]interface{}{}}},
+	})
+	if err != nil {
+		t.Fatalf("InsertDocumentsIntoRAG: %v", err)
+	}
+
+	citations := &CitationTracker{}
+	runner := &AgentRunner{
+		Client: client,
+		Tools: map[string]AgentToolHandler{
+			"knowledge_search": func(ctx context.Context, call AgentToolCall) (string, error) {
+				return ragToolHandler(ctx, client, []string{"vs1"}, citations, call)
+			},
+		},
+	}
+	turn, err := runner.Run(ctx, agentID, sessionID, []Message{{Role: "user", Content: "Who is Dora's owner?"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := turn.OutputMessage.Content; !strings.Contains(got, "Edson") {
+		t.Fatalf("answer %q does not name the owner", got)
+	}
+	if len(citations.Citations()) == 0 {
+		t.Fatal("no citations recorded")
+	}
+}
+
+func TestAgentRunnerToolError(t *testing.T) {
+	client, agentID, sessionID := newMockAgent(t, lookupAgent())
+
+	runner := &AgentRunner{
+		Client: client,
+		Tools: map[string]AgentToolHandler{
+			"lookup": func(ctx context.Context, call AgentToolCall) (string, error) {
+				return "", NewToolError("no entry matched, try a broader query", errors.New("not found"))
+			},
+		},
+	}
+	turn, err := runner.Run(context.Background(), agentID, sessionID, []Message{{Role: "user", Content: "Who owns Dora?"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := turn.OutputMessage.Content; !strings.Contains(got, "no entry matched, try a broader query") {
+		t.Fatalf("answer %q does not carry the tool error", got)
+	}
+}
+
+func TestAgentRunnerRetriesTransientToolErrors(t *testing.T) {
+	client, agentID, sessionID := newMockAgent(t, lookupAgent())
+
+	var attempts atomic.Int32
+	runner := &AgentRunner{
+		Client:    client,
+		ToolRetry: ToolRetryPolicy{MaxRetries: 2, Delay: time.Millisecond},
+		Tools: map[string]AgentToolHandler{
+			"lookup": func(ctx context.Context, call AgentToolCall) (string, error) {
+				if attempts.Add(1) == 1 {
+					return "", &APIError{StatusCode: http.StatusServiceUnavailable}
+				}
+				return "Dora belongs to Edson", nil
+			},
+		},
+	}
+	turn, err := runner.Run(context.Background(), agentID, sessionID, []Message{{Role: "user", Content: "Who owns Dora?"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Fatalf("tool called %d times, want 2", n)
+	}
+	if got := turn.OutputMessage.Content; !strings.Contains(got, "Dora belongs to Edson") {
+		t.Fatalf("answer %q does not use the retried output", got)
+	}
+}
+
+func TestAgentRunnerToolTimeout(t *testing.T) {
+	client, agentID, sessionID := newMockAgent(t, lookupAgent())
+
+	runner := &AgentRunner{
+		Client:      client,
+		ToolTimeout: 20 * time.Millisecond,
+		Tools: map[string]AgentToolHandler{
+			"lookup": func(ctx context.Context, call AgentToolCall) (string, error) {
+				<-ctx.Done()
+				return "", ctx.Err()
+			},
+		},
+	}
+	turn, err := runner.Run(context.Background(), agentID, sessionID, []Message{{Role: "user", Content: "Who owns Dora?"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := turn.OutputMessage.Content; !strings.Contains(got, "timed out") {
+		t.Fatalf("answer %q does not report the timeout", got)
+	}
+}
+
+func TestAgentRunnerAborts(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner AgentRunner
+		want   LoopAbortReason
+	}{
+		{
+			name:   "no handler",
+			runner: AgentRunner{Tools: map[string]AgentToolHandler{}},
+			want:   LoopNoToolResponses,
+		},
+		{
+			name: "iteration limit",
+			runner: AgentRunner{
+				MaxIterations: 1,
+				Tools: map[string]AgentToolHandler{
+					"lookup": func(ctx context.Context, call AgentToolCall) (string, error) { return "ok", nil },
+				},
+			},
+			want: LoopMaxIterations,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, agentID, sessionID := newMockAgent(t, lookupAgent())
+			tt.runner.Client = client
+			_, err := tt.runner.Run(context.Background(), agentID, sessionID, []Message{{Role: "user", Content: "Who owns Dora?"}})
+			var aborted *LoopAborted
+			if !errors.As(err, &aborted) || aborted.Reason != tt.want {
+				t.Fatalf("err = %v, want a %s abort", err, tt.want)
+			}
+			if aborted.TurnID == "" {
+				t.Fatal("abort does not name the turn left awaiting input")
+			}
+		})
+	}
+}
+
+func TestAgentRunnerUndecodableEvent(t *testing.T) {
+	client := NewLlamaStackClient("http://stack.test", "test")
+	client.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := "data: {\"event\":{\"payload\":{\"event_type\":\"turn_start\",\"turn_id\":\"t1\"}}}\n\n" +
+			"data: {\"event\":{\"payload\":{\"event_type\":\n\n" +
+			"data: {\"event\":{\"payload\":{\"event_type\":\"turn_complete\",\"turn\":{\"turn_id\":\"t1\"}}}}\n\n"
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})}
+
+	runner := &AgentRunner{Client: client}
+	turn, err := runner.Run(context.Background(), "a1", "s1", []Message{{Role: "user", Content: "Who owns Dora?"}})
+	var decodeErr *StreamDecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Run = %v, %v; want a *StreamDecodeError", turn, err)
+	}
+	if !strings.Contains(decodeErr.Data, "event_type") {
+		t.Fatalf("decode error does not carry the bad event: %q", decodeErr.Data)
+	}
+}
+
+func TestAgentRunnerHandlerPrefersLongestMatch(t *testing.T) {
+	tool := func(context.Context, AgentToolCall) (string, error) { return "", nil }
+	runner := AgentRunner{Tools: map[string]AgentToolHandler{
+		"search": tool, "web_search": tool, "web": tool, "lookup": tool,
+	}}
+	tests := []struct {
+		call string
+		want string
+	}{
+		{"web_search", "web_search"},
+		{"brave_web_search", "web_search"},
+		{"search_docs", "search"},
+		{"web_lookup", "lookup"},
+		{"calculator", ""},
+	}
+	// Map order changes between runs, so try each call several times
+	for range 20 {
+		for _, tt := range tests {
+			got, h := runner.handler(tt.call)
+			if got != tt.want || (h == nil) != (tt.want == "") {
+				t.Fatalf("handler(%q) = %q, want %q", tt.call, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// agentToolInput names the parameter the calling model fills in
+const agentToolInput = "input"
+
+// AgentTool exposes an agent as a client-side tool of another agent, for
+// simple multi-agent pipelines such as a writer that asks a researcher. Each
+// call runs the agent on the call's input in a new session and returns its
+// final answer.
+type AgentTool struct {
+	Name        string
+	Description string
+	AgentID     string
+	// Runner runs the agent's turns, so the agent can have client-side tools
+	// of its own, including other agent tools
+	Runner *AgentRunner
+}
+
+// NewAgentTool creates an agent from cfg and returns it as a tool named name.
+// Register it with the calling agent's config and runner:
+//
+//	builder.WithClientTools(tool.Definition())
+//	runner.Tools[tool.Name] = tool.Handler()
+func NewAgentTool(ctx context.Context, client *LlamaStackClient, name, description string, cfg AgentConfig) (*AgentTool, error) {
+	resp, err := client.CreateAgent(ctx, AgentCreateParams{AgentConfig: cfg})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agent for tool %s: %w", name, err)
+	}
+	return &AgentTool{
+		Name:        name,
+		Description: description,
+		AgentID:     resp.AgentID,
+		Runner:      &AgentRunner{Client: client},
+	}, nil
+}
+
+// Definition returns the client tool definition the calling agent sees
+func (t *AgentTool) Definition() SpecToolDef {
+	required := true
+	description := t.Description
+	return SpecToolDef{
+		Name:        t.Name,
+		Description: &description,
+		Parameters: []SpecToolParameter{{
+			Name:          agentToolInput,
+			Description:   "The task or question for the agent, with all the context it needs",
+			ParameterType: "string",
+			Required:      &required,
+		}},
+	}
+}
+
+// Handler returns the handler to register under Name in the calling
+// agent's AgentRunner. The sub-agent's run counts against the calling
+// runner's tool timeout, which usually needs raising for agent tools.
+func (t *AgentTool) Handler() AgentToolHandler {
+	return func(ctx context.Context, call AgentToolCall) (string, error) {
+		input := agentToolArgument(call.Arguments)
+		if input == "" {
+			return "", fmt.Errorf("tool %s was called without %s", t.Name, agentToolInput)
+		}
+		client := t.Runner.Client
+		session, err := client.CreateSession(ctx, t.AgentID, SessionCreateParams{SessionName: t.Name + "-" + call.CallID})
+		if err != nil {
+			return "", fmt.Errorf("failed to create session for agent tool %s: %w", t.Name, err)
+		}
+		turn, err := t.Runner.Run(ctx, t.AgentID, session.SessionID, []Message{{Role: "user", Content: input}})
+		if err != nil {
+			return "", fmt.Errorf("agent tool %s failed: %w", t.Name, err)
+		}
+		return turn.OutputMessage.Content, nil
+	}
+}
+
+// agentToolArgument returns the input of an agent tool call. Models do not
+// always use the declared parameter name, so a lone string argument or a
+// plain string is accepted too.
+func agentToolArgument(args interface{}) string {
+	switch v := args.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if s, ok := v[agentToolInput].(string); ok {
+			return s
+		}
+		if len(v) == 1 {
+			for _, value := range v {
+				s, _ := value.(string)
+				return s
+			}
+		}
+	}
+	return ""
+}
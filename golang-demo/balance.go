@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadBalancePolicy decides which of BaseURL and Replicas a request is sent
+// to first. Whatever the policy, a request that cannot connect moves on to
+// the next endpoint.
+type LoadBalancePolicy string
+
+const (
+	// LoadBalanceFailover sends everything to BaseURL while it is up and
+	// uses Replicas, in order, only when it is not
+	LoadBalanceFailover LoadBalancePolicy = ""
+	// LoadBalanceRoundRobin spreads requests evenly over the endpoints
+	LoadBalanceRoundRobin LoadBalancePolicy = "round-robin"
+	// LoadBalanceLeastPending picks the endpoint with the fewest requests
+	// and open streams in flight
+	LoadBalanceLeastPending LoadBalancePolicy = "least-pending"
+)
+
+// endpointCooldown is how long an endpoint that refused a connection is
+// tried only after every healthy one
+const endpointCooldown = 10 * time.Second
+
+// WithLoadBalancing spreads requests over BaseURL and Replicas with policy
+func WithLoadBalancing(policy LoadBalancePolicy) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.LoadBalance = policy
+	}
+}
+
+// ParseLoadBalancePolicy parses "failover", "round-robin" or "least-pending"
+func ParseLoadBalancePolicy(s string) (LoadBalancePolicy, error) {
+	switch policy := LoadBalancePolicy(s); policy {
+	case "failover":
+		return LoadBalanceFailover, nil
+	case LoadBalanceRoundRobin, LoadBalanceLeastPending:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown load balancing policy %q (want failover, round-robin or least-pending)", s)
+}
+
+// endpointPool tracks the health and load of every base URL the client uses
+type endpointPool struct {
+	mu    sync.Mutex
+	state map[string]*endpointState
+	next  int // round-robin position
+}
+
+type endpointState struct {
+	pending   int
+	downUntil time.Time
+}
+
+func newEndpointPool() *endpointPool {
+	return &endpointPool{state: make(map[string]*endpointState)}
+}
+
+// stateOf returns the state of base; callers hold p.mu
+func (p *endpointPool) stateOf(base string) *endpointState {
+	s, ok := p.state[base]
+	if !ok {
+		s = &endpointState{}
+		p.state[base] = s
+	}
+	return s
+}
+
+// order returns the endpoints in the order policy wants them tried, healthy
+// ones first
+func (p *endpointPool) order(bases []string, policy LoadBalancePolicy) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ordered := append([]string(nil), bases...)
+	switch policy {
+	case LoadBalanceRoundRobin:
+		start := p.next % len(ordered)
+		p.next++
+		ordered = append(ordered[start:], ordered[:start]...)
+	case LoadBalanceLeastPending:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return p.stateOf(ordered[i]).pending < p.stateOf(ordered[j]).pending
+		})
+	}
+	now := time.Now()
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return !now.Before(p.stateOf(ordered[i]).downUntil) && now.Before(p.stateOf(ordered[j]).downUntil)
+	})
+	return ordered
+}
+
+func (p *endpointPool) begin(base string) {
+	p.mu.Lock()
+	p.stateOf(base).pending++
+	p.mu.Unlock()
+}
+
+// end finishes a request to base and records whether base could be reached
+func (p *endpointPool) end(base string, reachable bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stateOf(base)
+	s.pending--
+	if reachable {
+		s.downUntil = time.Time{}
+	} else {
+		s.downUntil = time.Now().Add(endpointCooldown)
+	}
+}
+
+// endpoints returns BaseURL and Replicas in the order req should try them.
+// Requests built against another URL are left alone.
+func (c *LlamaStackClient) endpoints(req *http.Request) []string {
+	if len(c.Replicas) == 0 || c.pool == nil || !strings.HasPrefix(req.URL.String(), c.BaseURL) {
+		return nil
+	}
+	return c.pool.order(append([]string{c.BaseURL}, c.Replicas...), c.LoadBalance)
+}
+
+// isConnectError reports whether err means the server was never reached, so
+// any request, even one that creates something, can safely go elsewhere
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// readRequestBody reads req's body so it can be sent more than once
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	return body, nil
+}
+
+// replayable reports whether req can be sent more than once: it has no
+// body, or GetBody recreates it. Bodies that are streamed, such as uploads,
+// are not buffered to make them replayable; they go to one endpoint only.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// sendTo sends a copy of req to base instead of BaseURL, with a fresh body
+// from GetBody when req has one. The endpoint counts as pending until the
+// response body is closed.
+func (c *LlamaStackClient) sendTo(ctx context.Context, req *http.Request, base string) (*http.Response, error) {
+	body := req.Body
+	if req.GetBody != nil {
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+	target := base + strings.TrimPrefix(req.URL.String(), c.BaseURL)
+	attempt, err := http.NewRequestWithContext(ctx, req.Method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	attempt.Header = req.Header.Clone()
+	attempt.ContentLength = req.ContentLength
+	attempt.GetBody = req.GetBody
+
+	c.pool.begin(base)
+	resp, err := c.HTTPClient.Do(attempt)
+	if err != nil {
+		c.pool.end(base, !isConnectError(err))
+		return nil, err
+	}
+	resp.Body = &endpointBody{ReadCloser: resp.Body, pool: c.pool, base: base}
+	return resp, nil
+}
+
+// endpointBody ends the pending request to its endpoint when closed
+type endpointBody struct {
+	io.ReadCloser
+	pool *endpointPool
+	base string
+	once sync.Once
+}
+
+func (eb *endpointBody) Close() error {
+	err := eb.ReadCloser.Close()
+	eb.once.Do(func() { eb.pool.end(eb.base, true) })
+	return err
+}
+
+// failover tries the endpoints in order until one can be reached. A request
+// that reached a server and then failed is only sent again elsewhere if it
+// is hedgeable, since the server may already have acted on it. A request
+// that is not replayable is sent to the first endpoint only.
+func (c *LlamaStackClient) failover(req *http.Request, bases []string) (*http.Response, error) {
+	if !replayable(req) {
+		bases = bases[:1]
+	}
+	for i, base := range bases {
+		resp, err := c.sendTo(req.Context(), req, base)
+		if err == nil {
+			return resp, nil
+		}
+		if i == len(bases)-1 || req.Context().Err() != nil || (!isConnectError(err) && !hedgeable(req)) {
+			if i > 0 {
+				err = fmt.Errorf("tried %d endpoints, last %s: %w", i+1, base, err)
+			}
+			return nil, err
+		}
+		c.logger().WarnContext(req.Context(), "endpoint unavailable, failing over", "endpoint", req.URL.Path, "from", base, "to", bases[i+1], "error", err)
+	}
+	return nil, errors.New("no endpoints to send to")
+}
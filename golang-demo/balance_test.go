@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deadEndpoint returns the URL of a server that has shut down, so
+// connecting to it fails
+func deadEndpoint(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv.URL
+}
+
+func TestFailoverReplaysOnlyReplayableBodies(t *testing.T) {
+	mock := NewMockStack()
+	defer mock.Close()
+	ctx := context.Background()
+
+	// A JSON body can be recreated, so it moves on to the replica
+	client := NewLlamaStackClient(deadEndpoint(t), "test", WithReplicas(mock.URL()))
+	if _, err := client.CreateVectorStore(ctx, "docs", nil); err != nil {
+		t.Fatalf("CreateVectorStore did not fail over: %v", err)
+	}
+
+	// A streamed upload is sent once, to the first endpoint, rather than
+	// held in memory for a replay
+	client = NewLlamaStackClient(deadEndpoint(t), "test", WithReplicas(mock.URL()))
+	content := strings.Repeat("Dora is a pug. ", 1000)
+	if _, err := client.UploadFileFromReader(ctx, "notes.txt", &countingReader{r: strings.NewReader(content)}, FilePurposeAssistants); err == nil {
+		t.Fatal("streamed upload to an unreachable endpoint succeeded")
+	}
+	if files, err := client.ListFiles(ctx); err != nil || len(files.Data) != 0 {
+		t.Fatalf("replica got a file after a failed streamed upload: %+v, %v", files, err)
+	}
+}
+
+func TestReplicasStreamUploads(t *testing.T) {
+	mock := NewMockStack()
+	defer mock.Close()
+	content := strings.Repeat("Dora is a pug. ", 1000)
+	src := &countingReader{r: strings.NewReader(content)}
+	var readAtSend int64
+	client := NewLlamaStackClient(mock.URL(), "test", WithReplicas(deadEndpoint(t)), WithHedging(1))
+	client.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		readAtSend = src.n.Load()
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+
+	file, err := client.UploadFileFromReader(context.Background(), "notes.txt", src, FilePurposeAssistants)
+	if err != nil {
+		t.Fatalf("UploadFileFromReader: %v", err)
+	}
+	if file.Bytes != len(content) {
+		t.Fatalf("server received %d bytes, want %d", file.Bytes, len(content))
+	}
+	if readAtSend > 1 {
+		t.Fatalf("%d bytes read before the request was sent", readAtSend)
+	}
+}
+
+func TestHedgeFailsOverPastTheHedgedPair(t *testing.T) {
+	mock := NewMockStack()
+	defer mock.Close()
+	client := NewLlamaStackClient(deadEndpoint(t), "test", WithReplicas(deadEndpoint(t), mock.URL()), WithHedging(time.Hour))
+	if _, err := client.ListModels(context.Background()); err != nil {
+		t.Fatalf("ListModels did not reach the third endpoint: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// BatchOptions configures CreateChatCompletionsBatch
+type BatchOptions struct {
+	Concurrency int           // requests in flight at once; defaults to 4
+	MaxRetries  int           // retries per item after the first attempt
+	RetryDelay  time.Duration // delay before the first retry, doubled for each further retry
+}
+
+// BatchResult holds the outcome of one item in a batch
+type BatchResult struct {
+	Index    int
+	Response *APIResponse
+	Err      error
+	Attempts int
+}
+
+// CreateChatCompletionsBatch runs many non-streaming chat completions with a
+// bounded worker pool. Results are returned in the same order as params; a
+// failed item does not stop the others. Retries apply to network errors,
+// 429s and 5xx responses.
+func (c *LlamaStackClient) CreateChatCompletionsBatch(ctx context.Context, params []ChatCompletionParams, opts BatchOptions) []BatchResult {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	results := make([]BatchResult, len(params))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(params); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = c.runBatchItem(ctx, i, params[i], opts)
+			}
+		}()
+	}
+
+feed:
+	for i := range params {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			for j := i; j < len(params); j++ {
+				results[j] = BatchResult{Index: j, Err: ctx.Err()}
+			}
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// runBatchItem sends one completion, retrying transient failures
+func (c *LlamaStackClient) runBatchItem(ctx context.Context, index int, params ChatCompletionParams, opts BatchOptions) BatchResult {
+	result := BatchResult{Index: index}
+	delay := opts.RetryDelay
+
+	for {
+		result.Attempts++
+		result.Response, result.Err = c.CreateChatCompletion(withRetries(ctx, result.Attempts-1), params)
+		if result.Err == nil || result.Attempts > opts.MaxRetries || !isRetryable(ctx, result.Err) {
+			return result
+		}
+
+		c.logger().WarnContext(ctx, "retrying chat completion", "item", index, "attempt", result.Attempts, "delay", delay, "error", result.Err)
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				result.Err = ctx.Err()
+				return result
+			}
+			delay *= 2
+		}
+	}
+}
+
+// isRetryable reports whether err is worth another attempt
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	var budgetErr *BudgetExceededError
+	if errors.As(err, &budgetErr) || errors.Is(err, ErrClientClosed) {
+		return false
+	}
+	// Anything else failed before a response arrived, e.g. a dropped connection
+	return true
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BenchPrompt is one line of a benchmark prompt file: either a single user
+// prompt or a full message list
+type BenchPrompt struct {
+	Prompt   string    `json:"prompt,omitempty"`
+	Messages []Message `json:"messages,omitempty"`
+}
+
+func (p BenchPrompt) messages() []Message {
+	if len(p.Messages) > 0 {
+		return p.Messages
+	}
+	return []Message{{Role: "user", Content: p.Prompt}}
+}
+
+// LoadBenchPrompts reads a JSONL prompt file. Each line is an object with a
+// "prompt" string or a "messages" array; blank lines are skipped.
+func LoadBenchPrompts(path string) ([]BenchPrompt, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open prompt file: %w", err)
+	}
+	defer file.Close()
+
+	var prompts []BenchPrompt
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var p BenchPrompt
+		if err := json.Unmarshal([]byte(text), &p); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if p.Prompt == "" && len(p.Messages) == 0 {
+			return nil, fmt.Errorf("%s:%d: no prompt or messages", path, line)
+		}
+		prompts = append(prompts, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("%s: no prompts", path)
+	}
+	return prompts, nil
+}
+
+// BenchOptions configures RunBenchmark
+type BenchOptions struct {
+	Model       string
+	Concurrency int      // streams in flight at once; defaults to 1
+	Requests    int      // total requests, cycling through the prompts; defaults to one per prompt
+	MaxTokens   int      // optional max_tokens for every request
+	Temperature *float64 // optional temperature for every request
+
+	// Progress, if set, is called after each request completes
+	Progress func(BenchSample)
+}
+
+// BenchSample holds the timings of one streamed completion. Output tokens
+// are counted as content chunks, which servers emit about one per token.
+type BenchSample struct {
+	Index        int           `json:"index"`
+	Prompt       int           `json:"prompt"`
+	TTFT         time.Duration `json:"-"`
+	Latency      time.Duration `json:"-"`
+	InterToken   time.Duration `json:"-"` // mean gap between content chunks after the first
+	OutputTokens int           `json:"output_tokens"`
+	Err          error         `json:"-"`
+}
+
+// TokensPerSecond is the decode rate after the first token
+func (s BenchSample) TokensPerSecond() float64 {
+	decode := s.Latency - s.TTFT
+	if s.OutputTokens < 2 || decode <= 0 {
+		return 0
+	}
+	return float64(s.OutputTokens-1) / decode.Seconds()
+}
+
+// MarshalJSON reports durations in milliseconds
+func (s BenchSample) MarshalJSON() ([]byte, error) {
+	type plain BenchSample
+	out := struct {
+		plain
+		TTFTMs          float64 `json:"ttft_ms"`
+		LatencyMs       float64 `json:"latency_ms"`
+		InterTokenMs    float64 `json:"inter_token_ms"`
+		TokensPerSecond float64 `json:"tokens_per_second"`
+		Error           string  `json:"error,omitempty"`
+	}{
+		plain:           plain(s),
+		TTFTMs:          durationMs(s.TTFT),
+		LatencyMs:       durationMs(s.Latency),
+		InterTokenMs:    durationMs(s.InterToken),
+		TokensPerSecond: s.TokensPerSecond(),
+	}
+	if s.Err != nil {
+		out.Error = s.Err.Error()
+	}
+	return json.Marshal(out)
+}
+
+// LatencyStats summarizes a distribution of durations, in milliseconds
+type LatencyStats struct {
+	Mean float64 `json:"mean_ms"`
+	Min  float64 `json:"min_ms"`
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// BenchReport summarizes a benchmark run
+type BenchReport struct {
+	Model             string        `json:"model"`
+	Concurrency       int           `json:"concurrency"`
+	Requests          int           `json:"requests"`
+	Failures          int           `json:"failures"`
+	Duration          time.Duration `json:"-"`
+	DurationSeconds   float64       `json:"duration_seconds"`
+	OutputTokens      int           `json:"output_tokens"`
+	Throughput        float64       `json:"throughput_tokens_per_second"` // output tokens per second across all streams
+	RequestsPerSecond float64       `json:"requests_per_second"`
+	TTFT              LatencyStats  `json:"ttft"`
+	InterToken        LatencyStats  `json:"inter_token"`
+	Latency           LatencyStats  `json:"latency"`
+	Samples           []BenchSample `json:"samples"`
+}
+
+// RunBenchmark drives streaming chat completions with a bounded worker pool
+// and measures time to first token, inter-token latency, end-to-end latency
+// and throughput. Requests are sent without the demo's request logging so the
+// timings are not skewed by printing. Failed requests are counted and left
+// out of the latency statistics.
+func (c *LlamaStackClient) RunBenchmark(ctx context.Context, prompts []BenchPrompt, opts BenchOptions) (*BenchReport, error) {
+	if len(prompts) == 0 {
+		return nil, errors.New("no prompts")
+	}
+	if opts.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Requests <= 0 {
+		opts.Requests = len(prompts)
+	}
+
+	samples := make([]BenchSample, opts.Requests)
+	jobs := make(chan int)
+	var progressMu sync.Mutex
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency && w < opts.Requests; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				samples[i] = c.benchOne(ctx, i, i%len(prompts), prompts[i%len(prompts)], opts)
+				if opts.Progress != nil {
+					progressMu.Lock()
+					opts.Progress(samples[i])
+					progressMu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < opts.Requests; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			for j := i; j < opts.Requests; j++ {
+				samples[j] = BenchSample{Index: j, Prompt: j % len(prompts), Err: ctx.Err()}
+			}
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &BenchReport{
+		Model:       opts.Model,
+		Concurrency: opts.Concurrency,
+		Requests:    opts.Requests,
+		Duration:    time.Since(start),
+		Samples:     samples,
+	}
+	report.DurationSeconds = report.Duration.Seconds()
+
+	var ttft, interToken, latency []time.Duration
+	for _, s := range samples {
+		if s.Err != nil {
+			report.Failures++
+			continue
+		}
+		report.OutputTokens += s.OutputTokens
+		ttft = append(ttft, s.TTFT)
+		latency = append(latency, s.Latency)
+		if s.OutputTokens > 1 {
+			interToken = append(interToken, s.InterToken)
+		}
+	}
+	if secs := report.Duration.Seconds(); secs > 0 {
+		report.Throughput = float64(report.OutputTokens) / secs
+		report.RequestsPerSecond = float64(opts.Requests-report.Failures) / secs
+	}
+	report.TTFT = latencyStats(ttft)
+	report.InterToken = latencyStats(interToken)
+	report.Latency = latencyStats(latency)
+	return report, ctx.Err()
+}
+
+// benchOne streams one completion and records its timings
+func (c *LlamaStackClient) benchOne(ctx context.Context, index, prompt int, p BenchPrompt, opts BenchOptions) BenchSample {
+	sample := BenchSample{Index: index, Prompt: prompt}
+	stream := true
+	params := ChatCompletionParams{
+		Model:       opts.Model,
+		Messages:    p.messages(),
+		Stream:      &stream,
+		Temperature: opts.Temperature,
+	}
+	if opts.MaxTokens > 0 {
+		params.MaxTokens = &opts.MaxTokens
+	}
+
+	start := time.Now()
+	resp, err := c.postStream(ctx, "/v1/openai/v1/chat/completions", params)
+	if err != nil {
+		sample.Err = err
+		return sample
+	}
+	defer resp.Body.Close()
+
+	var first, last time.Time
+	done := false
+	err = readSSE(resp.Body, func(ev sseEvent) bool {
+		if ev.Data == "[DONE]" {
+			done = true
+			return false
+		}
+		var chunk ChatCompletionChunk
+		if err := decodeSSEData(ev.Data, &chunk); err != nil {
+			sample.Err = err
+			return false
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			now := time.Now()
+			if first.IsZero() {
+				first = now
+			}
+			last = now
+			sample.OutputTokens++
+		}
+		return true
+	})
+	sample.Latency = time.Since(start)
+	if sample.Err != nil {
+		return sample
+	}
+	if !done {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		sample.Err = fmt.Errorf("%w: %w", ErrStreamInterrupted, err)
+		return sample
+	}
+	if first.IsZero() {
+		sample.Err = errors.New("stream returned no content")
+		return sample
+	}
+	sample.TTFT = first.Sub(start)
+	if sample.OutputTokens > 1 {
+		sample.InterToken = last.Sub(first) / time.Duration(sample.OutputTokens-1)
+	}
+	return sample
+}
+
+// latencyStats computes the mean, extremes and nearest-rank percentiles
+func latencyStats(durations []time.Duration) LatencyStats {
+	if len(durations) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		return durationMs(sorted[rank-1])
+	}
+	return LatencyStats{
+		Mean: durationMs(total / time.Duration(len(sorted))),
+		Min:  durationMs(sorted[0]),
+		P50:  percentile(50),
+		P95:  percentile(95),
+		P99:  percentile(99),
+		Max:  durationMs(sorted[len(sorted)-1]),
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// WriteJSON writes the report, including every sample, as indented JSON
+func (r *BenchReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes one row per sample, for loading into a spreadsheet to
+// compare runs
+func (r *BenchReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"model", "concurrency", "index", "prompt", "ttft_ms", "inter_token_ms", "latency_ms", "output_tokens", "tokens_per_second", "error"})
+	format := func(f float64) string { return strconv.FormatFloat(f, 'f', 3, 64) }
+	for _, s := range r.Samples {
+		errText := ""
+		if s.Err != nil {
+			errText = s.Err.Error()
+		}
+		cw.Write([]string{
+			r.Model,
+			strconv.Itoa(r.Concurrency),
+			strconv.Itoa(s.Index),
+			strconv.Itoa(s.Prompt),
+			format(durationMs(s.TTFT)),
+			format(durationMs(s.InterToken)),
+			format(durationMs(s.Latency)),
+			strconv.Itoa(s.OutputTokens),
+			format(s.TokensPerSecond()),
+			errText,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteSummary prints a human-readable summary of the report
+func (r *BenchReport) WriteSummary(w io.Writer) {
+	fmt.Fprintf(w, "Model:        %s\n", r.Model)
+	fmt.Fprintf(w, "Requests:     %d (%d failed), concurrency %d\n", r.Requests, r.Failures, r.Concurrency)
+	fmt.Fprintf(w, "Duration:     %.2fs (%.2f req/s)\n", r.DurationSeconds, r.RequestsPerSecond)
+	fmt.Fprintf(w, "Throughput:   %.1f tokens/s (%d output tokens)\n", r.Throughput, r.OutputTokens)
+	fmt.Fprintf(w, "%-13s %9s %9s %9s %9s %9s\n", "", "mean", "p50", "p95", "p99", "max")
+	for _, row := range []struct {
+		name  string
+		stats LatencyStats
+	}{{"TTFT (ms)", r.TTFT}, {"ITL (ms)", r.InterToken}, {"Latency (ms)", r.Latency}} {
+		fmt.Fprintf(w, "%-13s %9.1f %9.1f %9.1f %9.1f %9.1f\n", row.name, row.stats.Mean, row.stats.P50, row.stats.P95, row.stats.P99, row.stats.Max)
+	}
+}
+
+// runBenchCommand handles "bench -model X -concurrency N -prompt-file prompts.jsonl"
+func runBenchCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	model := fs.String("model", "", "model to benchmark")
+	concurrency := fs.Int("concurrency", 1, "streams in flight at once")
+	promptFile := fs.String("prompt-file", "", "JSONL file with a \"prompt\" or \"messages\" per line")
+	requests := fs.Int("requests", 0, "total requests, cycling through the prompts; one per prompt by default")
+	maxTokens := fs.Int("max-tokens", 0, "max_tokens for every request")
+	format := fs.String("format", "text", "report format: text, json or csv")
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *model == "" || *promptFile == "" || fs.NArg() != 0 {
+		return errors.New("usage: bench -model MODEL -prompt-file prompts.jsonl [-concurrency 4] [-requests 100] [-max-tokens 256] [-format text|json|csv] [-o report.json]")
+	}
+	if *format != "text" && *format != "json" && *format != "csv" {
+		return fmt.Errorf("unknown report format %q", *format)
+	}
+
+	prompts, err := LoadBenchPrompts(*promptFile)
+	if err != nil {
+		return err
+	}
+	client.ensureIdleConns(*concurrency)
+	report, err := client.RunBenchmark(context.Background(), prompts, BenchOptions{
+		Model:       *model,
+		Concurrency: *concurrency,
+		Requests:    *requests,
+		MaxTokens:   *maxTokens,
+		Progress: func(s BenchSample) {
+			if s.Err != nil {
+				fmt.Fprintf(os.Stderr, "request %d failed: %v\n", s.Index, s.Err)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	switch *format {
+	case "json":
+		err = report.WriteJSON(w)
+	case "csv":
+		err = report.WriteCSV(w)
+	default:
+		report.WriteSummary(w)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if *output != "" {
+		report.WriteSummary(os.Stdout)
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BudgetLimits cap what a client may use per window. Zero fields are
+// unlimited.
+type BudgetLimits struct {
+	MaxRequests int
+	MaxTokens   int           // total tokens reported in usage by chat and completion calls
+	MaxCallTime time.Duration // summed duration of calls, including reading streams
+	// Window is how often the counts start again from zero; zero means
+	// never, so the limits cover the client's lifetime
+	Window time.Duration
+}
+
+// BudgetUsage is what has been used in the current window
+type BudgetUsage struct {
+	Requests    int
+	Tokens      int
+	CallTime    time.Duration
+	WindowStart time.Time
+}
+
+// BudgetExceededError is returned, before anything is sent, by calls made
+// once a limit of the client's BudgetGuard has been reached
+type BudgetExceededError struct {
+	Limit   string // "requests", "tokens" or "call time"
+	Used    string
+	Max     string
+	ResetIn time.Duration // until the window starts again; zero if it never does
+}
+
+func (e *BudgetExceededError) Error() string {
+	msg := fmt.Sprintf("budget exceeded: %s used %s of %s", e.Limit, e.Used, e.Max)
+	if e.ResetIn > 0 {
+		msg += fmt.Sprintf(", resets in %s", e.ResetIn.Round(time.Second))
+	}
+	return msg
+}
+
+// BudgetGuard enforces BudgetLimits across every call of a client, so a
+// runaway agentic loop cannot hog a shared stack. Requests are counted when
+// they are sent; tokens and call time once the response body is closed, so
+// a call that is already running always finishes.
+type BudgetGuard struct {
+	limits BudgetLimits
+
+	mu    sync.Mutex
+	usage BudgetUsage
+}
+
+// NewBudgetGuard returns a guard enforcing limits, starting a window now
+func NewBudgetGuard(limits BudgetLimits) *BudgetGuard {
+	return &BudgetGuard{limits: limits, usage: BudgetUsage{WindowStart: time.Now()}}
+}
+
+// WithBudget makes the client reject calls with a BudgetExceededError once
+// limits are used up; see BudgetGuard
+func WithBudget(limits BudgetLimits) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.Budget = NewBudgetGuard(limits)
+	}
+}
+
+// Usage returns what has been used in the current window
+func (g *BudgetGuard) Usage() BudgetUsage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.roll(time.Now())
+	return g.usage
+}
+
+// roll starts a new window once the current one is over; callers hold g.mu
+func (g *BudgetGuard) roll(now time.Time) {
+	if g.limits.Window > 0 && now.Sub(g.usage.WindowStart) >= g.limits.Window {
+		g.usage = BudgetUsage{WindowStart: now}
+	}
+}
+
+// admit counts a request about to be sent, or rejects it
+func (g *BudgetGuard) admit() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	g.roll(now)
+
+	exceeded := func(limit, used, max string) error {
+		err := &BudgetExceededError{Limit: limit, Used: used, Max: max}
+		if g.limits.Window > 0 {
+			err.ResetIn = g.usage.WindowStart.Add(g.limits.Window).Sub(now)
+		}
+		return err
+	}
+	u, l := g.usage, g.limits
+	switch {
+	case l.MaxRequests > 0 && u.Requests >= l.MaxRequests:
+		return exceeded("requests", strconv.Itoa(u.Requests), strconv.Itoa(l.MaxRequests))
+	case l.MaxTokens > 0 && u.Tokens >= l.MaxTokens:
+		return exceeded("tokens", strconv.Itoa(u.Tokens), strconv.Itoa(l.MaxTokens))
+	case l.MaxCallTime > 0 && u.CallTime >= l.MaxCallTime:
+		return exceeded("call time", u.CallTime.Round(time.Millisecond).String(), l.MaxCallTime.String())
+	}
+	g.usage.Requests++
+	return nil
+}
+
+// charge adds the tokens and duration of a finished call
+func (g *BudgetGuard) charge(tokens int, d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.roll(time.Now())
+	g.usage.Tokens += tokens
+	g.usage.CallTime += d
+}
+
+// chargeCall charges a failed call now, and any other when its body is
+// closed, with the token usage found in the body. Chat completions without
+// usage, such as most streams, are charged an estimate: prompt, from
+// chatPromptTokens, plus the tokens of the reply.
+func (g *BudgetGuard) chargeCall(start time.Time, chat chatPromptEstimate, resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		g.charge(0, time.Since(start))
+		return resp, err
+	}
+	resp.Body = &budgetBody{ReadCloser: resp.Body, guard: g, start: start, contentType: resp.Header.Get("Content-Type"), chat: chat}
+	return resp, nil
+}
+
+// chatPromptEstimate is the model and estimated prompt tokens of a chat
+// completion request
+type chatPromptEstimate struct {
+	model  string
+	tokens int
+}
+
+// chatPromptTokens estimates the prompt of a chat completion request before
+// it is sent; other requests get a zero estimate
+func chatPromptTokens(req *http.Request) chatPromptEstimate {
+	if !strings.HasSuffix(req.URL.Path, "/chat/completions") || req.GetBody == nil {
+		return chatPromptEstimate{}
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return chatPromptEstimate{}
+	}
+	defer body.Close()
+	var params struct {
+		Model    string    `json:"model"`
+		Messages []Message `json:"messages"`
+	}
+	if json.NewDecoder(body).Decode(&params) != nil {
+		return chatPromptEstimate{}
+	}
+	return chatPromptEstimate{model: params.Model, tokens: DefaultTokenCounter.CountMessages(params.Model, params.Messages)}
+}
+
+// replyText returns the reply of a chat completion body, joining the deltas
+// of a stream
+func replyText(contentType string, body []byte) string {
+	var envelope struct {
+		Choices []struct {
+			Message ChunkDelta `json:"message"`
+			Delta   ChunkDelta `json:"delta"`
+		} `json:"choices"`
+	}
+	var reply strings.Builder
+	add := func() {
+		for _, choice := range envelope.Choices {
+			reply.WriteString(choice.Message.Content)
+			reply.WriteString(choice.Delta.Content)
+		}
+	}
+	if !strings.HasPrefix(contentType, "text/event-stream") {
+		if json.Unmarshal(body, &envelope) == nil {
+			add()
+		}
+		return reply.String()
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		envelope.Choices = nil
+		if json.Unmarshal([]byte(strings.TrimSpace(data)), &envelope) == nil {
+			add()
+		}
+	}
+	return reply.String()
+}
+
+// budgetBody keeps the start of a response body to find its token usage
+type budgetBody struct {
+	io.ReadCloser
+	guard       *BudgetGuard
+	start       time.Time
+	contentType string
+	chat        chatPromptEstimate
+
+	buf  bytes.Buffer
+	once sync.Once
+}
+
+func (bb *budgetBody) Read(p []byte) (int, error) {
+	n, err := bb.ReadCloser.Read(p)
+	if room := logBodyLimit + 1 - bb.buf.Len(); room > 0 {
+		bb.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+func (bb *budgetBody) Close() error {
+	err := bb.ReadCloser.Close()
+	bb.once.Do(func() {
+		bb.guard.charge(callUsage(bb.contentType, bb.buf.Bytes(), bb.chat).TotalTokens, time.Since(bb.start))
+	})
+	return err
+}
+
+// parseBudgetSpec parses LLAMASTACK_BUDGET, e.g.
+// "requests=500,tokens=200000,time=10m,window=1h"
+func parseBudgetSpec(spec string) (BudgetLimits, error) {
+	var limits BudgetLimits
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return limits, fmt.Errorf("budget setting %q is not key=value", field)
+		}
+		var err error
+		switch key {
+		case "requests":
+			limits.MaxRequests, err = strconv.Atoi(value)
+		case "tokens":
+			limits.MaxTokens, err = strconv.Atoi(value)
+		case "time":
+			limits.MaxCallTime, err = time.ParseDuration(value)
+		case "window":
+			limits.Window, err = time.ParseDuration(value)
+		default:
+			return limits, fmt.Errorf("unknown budget setting %q", key)
+		}
+		if err != nil {
+			return limits, fmt.Errorf("invalid budget setting %s: %w", key, err)
+		}
+	}
+	return limits, nil
+}
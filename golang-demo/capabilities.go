@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ToolGroup represents a toolgroup registered on the server
+type ToolGroup struct {
+	Identifier string `json:"identifier"`
+	ProviderID string `json:"provider_id"`
+	Type       string `json:"type,omitempty"`
+}
+
+// ListToolGroupsResponse represents the response from listing toolgroups
+type ListToolGroupsResponse = Page[ToolGroup]
+
+// Shield represents a safety shield registered on the server
+type Shield struct {
+	Identifier         string                 `json:"identifier"`
+	ProviderID         string                 `json:"provider_id"`
+	ProviderResourceID string                 `json:"provider_resource_id,omitempty"` // e.g. the guard model
+	Type               string                 `json:"type,omitempty"`
+	Params             map[string]interface{} `json:"params,omitempty"`
+}
+
+// ListShieldsResponse represents the response from listing shields
+type ListShieldsResponse = Page[Shield]
+
+// ListToolGroups lists the toolgroups registered on the server
+func (c *LlamaStackClient) ListToolGroups(ctx context.Context) (*ListToolGroupsResponse, error) {
+	var response ListToolGroupsResponse
+	if err := c.getJSON(ctx, "/v1/toolgroups", &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListShields lists the shields registered on the server
+func (c *LlamaStackClient) ListShields(ctx context.Context) (*ListShieldsResponse, error) {
+	var response ListShieldsResponse
+	if err := c.getJSON(ctx, "/v1/shields", &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// getJSON sends a GET request and decodes the JSON response into v
+func (c *LlamaStackClient) getJSON(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp, body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// postJSON posts v as JSON and decodes the response into out, without the
+// request logging of the demo calls; for bodies too large to print usefully
+func (c *LlamaStackClient) postJSON(ctx context.Context, path string, v, out interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp, body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// postStream posts v as JSON and returns the accepted SSE response without
+// request logging, for callers such as benchmarks where printing every call
+// would distort the timings. The caller owns resp.Body.
+func (c *LlamaStackClient) postStream(ctx context.Context, path string, v interface{}) (*http.Response, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.doStream(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, newAPIError(resp, body)
+	}
+
+	return resp, nil
+}
+
+// ValidateAgentConfig checks cfg locally and then against what the server
+// actually provides: the model must be a registered LLM, and every toolgroup
+// and shield must be registered. It reports all problems at once, so a bad
+// preset fails with "toolgroup builtin::rag not registered on this distro"
+// rather than an opaque 400 from CreateAgent.
+func (c *LlamaStackClient) ValidateAgentConfig(ctx context.Context, cfg AgentConfig) error {
+	var errs []error
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	if cfg.Model != "" {
+		var llms []string
+		found := false
+		for _, m := range models.Data {
+			if m.ModelType == "llm" {
+				llms = append(llms, m.Identifier)
+			}
+			if m.Identifier != cfg.Model {
+				continue
+			}
+			found = true
+			if m.ModelType != "llm" {
+				errs = append(errs, fmt.Errorf("model %s is a %s model, not an llm", cfg.Model, m.ModelType))
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("model %s not registered on this distro (available llms: %s)", cfg.Model, listOrNone(llms)))
+		}
+	}
+
+	if len(cfg.Toolgroups) > 0 {
+		toolgroups, err := c.ListToolGroups(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list toolgroups: %w", err)
+		}
+		registered := make(map[string]bool, len(toolgroups.Data))
+		var names []string
+		for _, tg := range toolgroups.Data {
+			registered[tg.Identifier] = true
+			names = append(names, tg.Identifier)
+		}
+		for _, tg := range cfg.Toolgroups {
+			name, _, err := parseToolgroup(tg)
+			if err != nil {
+				continue // already reported by Validate
+			}
+			// "builtin::rag/knowledge_search" selects one tool of a group
+			group, _, _ := strings.Cut(name, "/")
+			if !registered[group] {
+				errs = append(errs, fmt.Errorf("toolgroup %s not registered on this distro (registered: %s)", group, listOrNone(names)))
+			}
+		}
+	}
+
+	if len(cfg.InputShields) > 0 || len(cfg.OutputShields) > 0 {
+		shields, err := c.ListShields(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list shields: %w", err)
+		}
+		registered := make(map[string]bool, len(shields.Data))
+		var names []string
+		for _, s := range shields.Data {
+			registered[s.Identifier] = true
+			names = append(names, s.Identifier)
+		}
+		reported := make(map[string]bool)
+		for _, s := range append(append([]string(nil), cfg.InputShields...), cfg.OutputShields...) {
+			if !registered[s] && !reported[s] {
+				reported[s] = true
+				errs = append(errs, fmt.Errorf("shield %s not registered on this distro (registered: %s)", s, listOrNone(names)))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// listOrNone formats names for an error message
+func listOrNone(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ", ")
+}
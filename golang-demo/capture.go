@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// captureSeq numbers capture files, so files from the same second sort in
+// the order the requests were made
+var captureSeq atomic.Int64
+
+var captureSlugPattern = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// WithCaptureDir makes the client write every request and its response to a
+// file in dir, ready to attach to an upstream issue; see CaptureDir
+func WithCaptureDir(dir string) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.CaptureDir = dir
+	}
+}
+
+// requestCapture is the file one request/response exchange is written to
+type requestCapture struct {
+	file  *os.File
+	start time.Time
+}
+
+// startCapture creates the capture file for req and writes the request to it.
+// Capturing is best effort: on failure a warning is logged and the request
+// is sent uncaptured.
+func (c *LlamaStackClient) startCapture(req *http.Request) *requestCapture {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			c.logger().WarnContext(req.Context(), "failed to capture request", "error", err)
+			return nil
+		}
+	}
+
+	start := time.Now()
+	slug := strings.Trim(captureSlugPattern.ReplaceAllString(strings.TrimPrefix(req.URL.Path, "/v1/openai"), "-"), "-")
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+	name := fmt.Sprintf("%s-%04d-%s-%s.http", start.Format("20060102-150405.000"), captureSeq.Add(1), strings.ToLower(req.Method), slug)
+	if err := os.MkdirAll(c.CaptureDir, 0o755); err != nil {
+		c.logger().WarnContext(req.Context(), "failed to capture request", "error", err)
+		return nil
+	}
+	file, err := os.Create(filepath.Join(c.CaptureDir, name))
+	if err != nil {
+		c.logger().WarnContext(req.Context(), "failed to capture request", "error", err)
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# captured %s\n", start.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\n", req.Method, req.URL)
+	writeCaptureHeaders(&b, req.Header)
+	b.WriteString("\n")
+	if len(body) > 0 {
+		b.WriteString(renderDryRunBody(req.Header.Get("Content-Type"), body))
+		b.WriteString("\n\n")
+	}
+	file.WriteString(b.String())
+	return &requestCapture{file: file, start: start}
+}
+
+// finish writes the response head and tees the body into the capture file as
+// the caller reads it, so a stream's transcript is complete up to wherever
+// the caller stopped. The file is closed with the response body.
+func (rc *requestCapture) finish(resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		fmt.Fprintf(rc.file, "# error after %s: %v\n", time.Since(rc.start).Round(time.Millisecond), err)
+		rc.file.Close()
+		return resp, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTTP/1.1 %s\n", resp.Status)
+	writeCaptureHeaders(&b, resp.Header)
+	b.WriteString("\n")
+	rc.file.WriteString(b.String())
+	resp.Body = &captureBody{ReadCloser: resp.Body, capture: rc}
+	return resp, nil
+}
+
+func writeCaptureHeaders(b *strings.Builder, h http.Header) {
+	headers := sanitizeHeaders(h)
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range headers[name] {
+			fmt.Fprintf(b, "%s: %s\n", name, value)
+		}
+	}
+}
+
+// captureBody copies a response body into the capture file as it is read
+type captureBody struct {
+	io.ReadCloser
+	capture *requestCapture
+	once    sync.Once
+}
+
+func (cb *captureBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.capture.file.Write(p[:n])
+	return n, err
+}
+
+func (cb *captureBody) Close() error {
+	err := cb.ReadCloser.Close()
+	cb.once.Do(func() {
+		fmt.Fprintf(cb.capture.file, "\n# done after %s\n", time.Since(cb.capture.start).Round(time.Millisecond))
+		cb.capture.file.Close()
+	})
+	return err
+}
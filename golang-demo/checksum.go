@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// ChecksumAttribute is the vector store file attribute holding the hex
+// SHA-256 of the file's content, set when the client attaches a file it
+// uploaded
+const ChecksumAttribute = "sha256"
+
+// ErrChecksumMismatch is returned when downloaded or re-uploaded content does
+// not hash to the checksum recorded for it
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// FileChecksum returns the checksum recorded in vector store file
+// attributes, or "" when there is none
+func FileChecksum(attributes map[string]interface{}) string {
+	sum, _ := attributes[ChecksumAttribute].(string)
+	return sum
+}
+
+// checksumAttributes returns attributes with the checksum of an uploaded
+// file added, leaving attributes itself unchanged
+func checksumAttributes(attributes map[string]interface{}, file *FileResponse) map[string]interface{} {
+	if file.SHA256 == "" {
+		return attributes
+	}
+	attrs := make(map[string]interface{}, len(attributes)+1)
+	for k, v := range attributes {
+		attrs[k] = v
+	}
+	attrs[ChecksumAttribute] = file.SHA256
+	return attrs
+}
+
+// verifyChecksum compares the sum of h to the expected hex checksum
+func verifyChecksum(what string, h hash.Hash, want string) error {
+	got := hex.EncodeToString(h.Sum(nil))
+	if want != "" && got != want {
+		return fmt.Errorf("%w: %s has sha256 %s, expected %s", ErrChecksumMismatch, what, got, want)
+	}
+	return nil
+}
+
+// DownloadFileVerified writes the content of an uploaded file to w and fails
+// with ErrChecksumMismatch when it does not hash to want. The content is
+// streamed, so w has received it by the time the mismatch is reported. An
+// empty want skips the check.
+func (c *LlamaStackClient) DownloadFileVerified(ctx context.Context, fileID, want string, w io.Writer) error {
+	h := sha256.New()
+	if err := c.DownloadFile(ctx, fileID, io.MultiWriter(w, h)); err != nil {
+		return err
+	}
+	return verifyChecksum("file "+fileID, h, want)
+}
+
+// DownloadVectorStoreFile writes the content of a file attached to a vector
+// store to w, verified against the checksum in its attributes when it has one
+func (c *LlamaStackClient) DownloadVectorStoreFile(ctx context.Context, vectorStoreID, fileID string, w io.Writer) error {
+	file, err := c.GetVectorStoreFile(ctx, vectorStoreID, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to get vector store file: %w", err)
+	}
+	return c.DownloadFileVerified(ctx, fileID, FileChecksum(file.Attributes), w)
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ChunkerOptions configures ChunkText
+type ChunkerOptions struct {
+	MaxTokens     int // chunk size; defaults to 512
+	OverlapTokens int // text repeated from the end of the previous chunk; must be below MaxTokens
+
+	// CountTokens measures text; defaults to the CountTokens estimate for
+	// Model, the model that will read the chunks ("" for a Llama 3 vocabulary)
+	CountTokens func(string) int
+	Model       string
+}
+
+// TextChunk is one chunk produced by ChunkText
+type TextChunk struct {
+	Index  int
+	Text   string
+	Tokens int
+}
+
+// chunkUnit is the smallest piece ChunkText packs: a paragraph, a sentence,
+// a run of words or a fenced code block
+type chunkUnit struct {
+	text   string
+	sep    string // joins the unit to the one before it
+	tokens int
+	code   bool
+}
+
+// ChunkText splits text into chunks of at most MaxTokens, preferring to break
+// between paragraphs, then between sentences, then between words. Fenced code
+// blocks stay whole when they fit; larger ones are split between lines and
+// each part is fenced again so it still reads as code. Consecutive chunks
+// share up to OverlapTokens of whole sentences or paragraphs, so an answer
+// that straddles a boundary is still retrievable; to make room for it, the
+// paragraph that follows may be split between sentences.
+func ChunkText(text string, opts ChunkerOptions) []TextChunk {
+	opts = opts.withDefaults()
+	units := splitChunkUnits(strings.ReplaceAll(text, "\r\n", "\n"), opts)
+
+	var chunks []TextChunk
+	var current []chunkUnit
+	emit := func() {
+		joined := joinChunkUnits(current)
+		if strings.TrimSpace(joined) != "" {
+			chunks = append(chunks, TextChunk{Index: len(chunks), Text: joined, Tokens: opts.CountTokens(joined)})
+		}
+	}
+	// fits measures the joined text, since separators count too
+	fits := func(u chunkUnit) bool {
+		return opts.CountTokens(joinChunkUnits(append(current[:len(current):len(current)], u))) <= opts.MaxTokens
+	}
+	for i := 0; i < len(units); i++ {
+		u := units[i]
+		if len(current) > 0 && !fits(u) {
+			emit()
+			current = overlapTail(current, opts.OverlapTokens, opts)
+			if len(current) > 0 && !fits(u) && !u.code {
+				// Keep the overlap by packing this paragraph sentence by sentence
+				if sentences := splitSentences(u.text); len(sentences) > 1 {
+					sentences[0].sep = u.sep
+					for j := range sentences {
+						sentences[j].tokens = opts.CountTokens(sentences[j].text)
+					}
+					units = append(sentences, units[i+1:]...)
+					i, u = 0, units[0]
+				}
+			}
+			if !fits(u) {
+				current = nil
+			}
+		}
+		current = append(current, u)
+	}
+	if len(current) > 0 {
+		emit()
+	}
+	return chunks
+}
+
+func (o ChunkerOptions) withDefaults() ChunkerOptions {
+	if o.MaxTokens <= 0 {
+		o.MaxTokens = 512
+	}
+	if o.OverlapTokens >= o.MaxTokens {
+		o.OverlapTokens = o.MaxTokens / 4
+	}
+	if o.OverlapTokens < 0 {
+		o.OverlapTokens = 0
+	}
+	if o.CountTokens == nil {
+		model := o.Model
+		o.CountTokens = func(text string) int { return CountTokens(model, text) }
+	}
+	return o
+}
+
+func joinChunkUnits(units []chunkUnit) string {
+	var b strings.Builder
+	for i, u := range units {
+		if i > 0 {
+			b.WriteString(u.sep)
+		}
+		b.WriteString(u.text)
+	}
+	return b.String()
+}
+
+// overlapTail returns the trailing units of a chunk that fit in overlap
+// tokens, always leaving out at least the first unit so chunks advance. When
+// the last unit alone is too large, its trailing sentences are used instead;
+// code is never split for overlap.
+func overlapTail(units []chunkUnit, overlap int, opts ChunkerOptions) []chunkUnit {
+	total, start := 0, len(units)
+	for start > 1 && total+units[start-1].tokens <= overlap {
+		start--
+		total += units[start].tokens
+	}
+	if start < len(units) || overlap == 0 {
+		return append([]chunkUnit{}, units[start:]...)
+	}
+
+	last := units[len(units)-1]
+	if last.code {
+		return nil
+	}
+	sentences := splitSentences(last.text)
+	var tail []chunkUnit
+	for i := len(sentences) - 1; i > 0; i-- {
+		tokens := opts.CountTokens(sentences[i].text)
+		if total+tokens > overlap {
+			break
+		}
+		sentences[i].tokens = tokens
+		tail = append([]chunkUnit{sentences[i]}, tail...)
+		total += tokens
+	}
+	return tail
+}
+
+// splitChunkUnits breaks text into units of at most MaxTokens each
+func splitChunkUnits(text string, opts ChunkerOptions) []chunkUnit {
+	var units []chunkUnit
+	add := func(pieces []chunkUnit) {
+		if len(pieces) > 0 && len(units) > 0 {
+			pieces[0].sep = "\n\n"
+		}
+		units = append(units, pieces...)
+	}
+
+	var prose []string
+	flushProse := func() {
+		for _, paragraph := range strings.Split(strings.Join(prose, "\n"), "\n\n") {
+			if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+				add(splitParagraph(paragraph, opts))
+			}
+		}
+		prose = nil
+	}
+
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		fence := codeFence(lines[i])
+		if fence == "" {
+			prose = append(prose, lines[i])
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), fence) {
+			end++
+		}
+		if end == len(lines) {
+			end-- // unterminated fence: the block runs to the end of the text
+		}
+		flushProse()
+		add(splitCodeBlock(lines[i:end+1], fence, opts))
+		i = end
+	}
+	flushProse()
+	return units
+}
+
+// codeFence returns the ``` or ~~~ marker opening a fenced code block
+func codeFence(line string) string {
+	trimmed := strings.TrimSpace(line)
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// splitCodeBlock keeps a code block whole when it fits, and otherwise splits
+// it between lines, repeating the opening fence line on every part
+func splitCodeBlock(lines []string, fence string, opts ChunkerOptions) []chunkUnit {
+	block := strings.Join(lines, "\n")
+	if tokens := opts.CountTokens(block); tokens <= opts.MaxTokens || len(lines) < 3 {
+		return []chunkUnit{{text: block, sep: "\n\n", tokens: tokens, code: true}}
+	}
+
+	open, body := lines[0], lines[1:]
+	if strings.HasPrefix(strings.TrimSpace(body[len(body)-1]), fence) {
+		body = body[:len(body)-1]
+	}
+	overhead := opts.CountTokens(open + "\n" + fence)
+	var units []chunkUnit
+	var part []string
+	partTokens := 0
+	emit := func() {
+		text := open + "\n" + strings.Join(part, "\n") + "\n" + fence
+		units = append(units, chunkUnit{text: text, sep: "\n\n", tokens: opts.CountTokens(text), code: true})
+		part, partTokens = nil, 0
+	}
+	for _, line := range body {
+		lineTokens := opts.CountTokens(line) + 1
+		if len(part) > 0 && overhead+partTokens+lineTokens > opts.MaxTokens {
+			emit()
+		}
+		part = append(part, line)
+		partTokens += lineTokens
+	}
+	if len(part) > 0 {
+		emit()
+	}
+	return units
+}
+
+// splitParagraph returns the paragraph as one unit when it fits, and
+// otherwise its sentences, with oversized sentences split between words
+func splitParagraph(paragraph string, opts ChunkerOptions) []chunkUnit {
+	if tokens := opts.CountTokens(paragraph); tokens <= opts.MaxTokens {
+		return []chunkUnit{{text: paragraph, sep: "\n\n", tokens: tokens}}
+	}
+	var units []chunkUnit
+	for _, s := range splitSentences(paragraph) {
+		if tokens := opts.CountTokens(s.text); tokens <= opts.MaxTokens {
+			units = append(units, chunkUnit{text: s.text, sep: s.sep, tokens: tokens})
+			continue
+		}
+		words := splitWords(s.text, opts)
+		if len(words) > 0 {
+			words[0].sep = s.sep
+		}
+		units = append(units, words...)
+	}
+	return units
+}
+
+// splitSentences breaks text after ., ! or ? (and any closing quotes or
+// brackets) followed by whitespace, and at line breaks. Each sentence keeps
+// the separator that preceded it: "\n" for line breaks, " " otherwise.
+func splitSentences(text string) []chunkUnit {
+	var sentences []chunkUnit
+	sep := " "
+	start := 0
+	cut := func(end, next int, nextSep string) {
+		if s := strings.TrimSpace(text[start:end]); s != "" {
+			sentences = append(sentences, chunkUnit{text: s, sep: sep})
+		}
+		start, sep = next, nextSep
+	}
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\n':
+			cut(i, i+1, "\n")
+		case '.', '!', '?':
+			end := i + 1
+			for end < len(text) && strings.ContainsRune(".!?\"')]", rune(text[end])) {
+				end++
+			}
+			if end < len(text) && (text[end] == ' ' || text[end] == '\t') {
+				cut(end, end+1, " ")
+				i = end
+			}
+		}
+	}
+	cut(len(text), len(text), " ")
+	return sentences
+}
+
+// splitWords packs the words of an oversized sentence into units that fit,
+// cutting words that are longer than a whole chunk on their own
+func splitWords(text string, opts ChunkerOptions) []chunkUnit {
+	var units []chunkUnit
+	var current []string
+	emit := func() {
+		joined := strings.Join(current, " ")
+		units = append(units, chunkUnit{text: joined, sep: " ", tokens: opts.CountTokens(joined)})
+		current = nil
+	}
+	for _, word := range strings.FieldsFunc(text, unicode.IsSpace) {
+		for _, piece := range splitLongWord(word, opts) {
+			if len(current) > 0 && opts.CountTokens(strings.Join(current, " ")+" "+piece) > opts.MaxTokens {
+				emit()
+			}
+			current = append(current, piece)
+		}
+	}
+	if len(current) > 0 {
+		emit()
+	}
+	return units
+}
+
+func splitLongWord(word string, opts ChunkerOptions) []string {
+	if opts.CountTokens(word) <= opts.MaxTokens {
+		return []string{word}
+	}
+	// Shrink the piece until it fits; with the default counter the first guess does
+	size := opts.MaxTokens * 4
+	var pieces []string
+	for word != "" {
+		n := size
+		if n > utf8.RuneCountInString(word) {
+			n = utf8.RuneCountInString(word)
+		}
+		piece := string([]rune(word)[:n])
+		for n > 1 && opts.CountTokens(piece) > opts.MaxTokens {
+			n /= 2
+			piece = string([]rune(word)[:n])
+		}
+		pieces = append(pieces, piece)
+		word = word[len(piece):]
+	}
+	return pieces
+}
+
+// ChunkDocuments splits every text document into one document per chunk. A
+// chunk's ID is "<document_id>#<index>" and its metadata is the document's
+// plus parent_document_id, chunk_index and chunk_count, so the chunks of a
+// document can be filtered or deleted together. Documents whose content is
+// not a string are passed through unchanged.
+func ChunkDocuments(docs []Document, opts ChunkerOptions) []Document {
+	var out []Document
+	for _, doc := range docs {
+		text, ok := doc.Content.(string)
+		if !ok {
+			out = append(out, doc)
+			continue
+		}
+		chunks := ChunkText(text, opts)
+		for _, chunk := range chunks {
+			metadata := make(map[string]interface{}, len(doc.Metadata)+3)
+			for k, v := range doc.Metadata {
+				metadata[k] = v
+			}
+			metadata["parent_document_id"] = doc.DocumentID
+			metadata["chunk_index"] = chunk.Index
+			metadata["chunk_count"] = len(chunks)
+			out = append(out, Document{
+				DocumentID: doc.DocumentID + "#" + strconv.Itoa(chunk.Index),
+				Content:    chunk.Text,
+				Metadata:   metadata,
+				MimeType:   doc.MimeType,
+			})
+		}
+	}
+	return out
+}
+
+// InsertChunkedDocuments chunks documents with ChunkText and inserts the
+// chunks through the rag-tool. The server's chunk size is set to twice
+// MaxTokens so its own tokenizer, which usually counts more tokens than the
+// estimate, does not split the chunks again.
+func (c *LlamaStackClient) InsertChunkedDocuments(ctx context.Context, vectorDBID string, docs []Document, opts ChunkerOptions) error {
+	opts = opts.withDefaults()
+	return c.InsertDocumentsIntoRAG(ctx, RagToolInsertParams{
+		ChunkSizeInTokens: 2 * opts.MaxTokens,
+		Documents:         ChunkDocuments(docs, opts),
+		VectorDBID:        vectorDBID,
+	})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// countWords is a token counter whose chunks are easy to work out by hand
+func countWords(s string) int { return len(strings.Fields(s)) }
+
+func TestChunkText(t *testing.T) {
+	fence := "```"
+	tests := []struct {
+		name string
+		text string
+		opts ChunkerOptions
+		want []string
+	}{
+		{
+			name: "fits in one chunk",
+			text: "Dora is a pug.\r\n\r\nAna owns her.",
+			opts: ChunkerOptions{MaxTokens: 10},
+			want: []string{"Dora is a pug.\n\nAna owns her."},
+		},
+		{
+			name: "breaks between paragraphs",
+			text: "one two three four\n\nfive six seven eight\n\nnine ten eleven twelve",
+			opts: ChunkerOptions{MaxTokens: 8},
+			want: []string{"one two three four\n\nfive six seven eight", "nine ten eleven twelve"},
+		},
+		{
+			name: "overlaps whole sentences",
+			text: "Dora is small. Ana owns Dora. They live together. Bella visits often.",
+			opts: ChunkerOptions{MaxTokens: 6, OverlapTokens: 3},
+			want: []string{
+				"Dora is small. Ana owns Dora.",
+				"Ana owns Dora. They live together.",
+				"They live together. Bella visits often.",
+			},
+		},
+		{
+			name: "splits oversized sentences between words",
+			text: "a b c d e f g",
+			opts: ChunkerOptions{MaxTokens: 3},
+			want: []string{"a b c", "d e f", "g"},
+		},
+		{
+			name: "keeps a code block whole",
+			text: "Intro.\n\n" + fence + "go\nline one\nline two\n" + fence + "\n\nOutro.",
+			opts: ChunkerOptions{MaxTokens: 6},
+			want: []string{"Intro.", fence + "go\nline one\nline two\n" + fence, "Outro."},
+		},
+		{
+			name: "refences the parts of a large code block",
+			text: fence + "\na b\nc d\ne f\n" + fence,
+			opts: ChunkerOptions{MaxTokens: 6},
+			want: []string{fence + "\na b\n" + fence, fence + "\nc d\n" + fence, fence + "\ne f\n" + fence},
+		},
+		{
+			name: "unterminated code block runs to the end",
+			text: "Intro.\n\n" + fence + "\ncode here",
+			opts: ChunkerOptions{MaxTokens: 2},
+			want: []string{"Intro.", fence + "\ncode here"},
+		},
+		{
+			name: "whitespace only",
+			text: " \n\n \n",
+			opts: ChunkerOptions{MaxTokens: 5},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.CountTokens = countWords
+			chunks := ChunkText(tt.text, tt.opts)
+			var got []string
+			for i, c := range chunks {
+				got = append(got, c.Text)
+				if c.Index != i || c.Tokens != countWords(c.Text) {
+					t.Fatalf("chunk %d has index %d and %d tokens, want %d", i, c.Index, c.Tokens, countWords(c.Text))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("chunks = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkTextRespectsMaxTokens(t *testing.T) {
+	// A word longer than a chunk is cut, and nothing is lost
+	word := strings.Repeat("abcdefghij", 500)
+	chunks := ChunkText(word, ChunkerOptions{MaxTokens: 50})
+	var joined strings.Builder
+	for _, c := range chunks {
+		if c.Tokens > 50 {
+			t.Fatalf("chunk %d has %d tokens, over the 50 limit", c.Index, c.Tokens)
+		}
+		joined.WriteString(c.Text)
+	}
+	if len(chunks) < 2 || joined.String() != word {
+		t.Fatalf("%d chunks do not add up to the word", len(chunks))
+	}
+}
+
+func TestChunkerOptionsDefaults(t *testing.T) {
+	tests := []struct {
+		in                 ChunkerOptions
+		maxTokens, overlap int
+	}{
+		{ChunkerOptions{}, 512, 0},
+		{ChunkerOptions{MaxTokens: 100, OverlapTokens: 100}, 100, 25},
+		{ChunkerOptions{MaxTokens: 100, OverlapTokens: -5}, 100, 0},
+		{ChunkerOptions{MaxTokens: 100, OverlapTokens: 20}, 100, 20},
+	}
+	for _, tt := range tests {
+		got := tt.in.withDefaults()
+		if got.MaxTokens != tt.maxTokens || got.OverlapTokens != tt.overlap || got.CountTokens == nil {
+			t.Errorf("%+v.withDefaults() = max %d, overlap %d, want %d and %d", tt.in, got.MaxTokens, got.OverlapTokens, tt.maxTokens, tt.overlap)
+		}
+	}
+}
+
+func TestChunkDocuments(t *testing.T) {
+	docs := []Document{
+		{DocumentID: "dora", Content: "one two\n\nthree four", Metadata: map[string]interface{}{"owner": "Ana"}, MimeType: "text/plain"},
+		{DocumentID: "image", Content: map[string]interface{}{"uri": "file://dora.png"}},
+	}
+	out := ChunkDocuments(docs, ChunkerOptions{MaxTokens: 2, CountTokens: countWords})
+	if len(out) != 3 {
+		t.Fatalf("got %d documents, want 2 chunks and the image", len(out))
+	}
+	for i, want := range []string{"dora#0", "dora#1"} {
+		doc := out[i]
+		if doc.DocumentID != want || doc.MimeType != "text/plain" || doc.Metadata["owner"] != "Ana" ||
+			doc.Metadata["parent_document_id"] != "dora" || doc.Metadata["chunk_index"] != i || doc.Metadata["chunk_count"] != 2 {
+			t.Fatalf("chunk %d = %+v", i, doc)
+		}
+	}
+	if out[2].DocumentID != "image" {
+		t.Fatalf("non-text document was not passed through: %+v", out[2])
+	}
+	if _, ok := docs[0].Metadata["chunk_index"]; ok {
+		t.Fatal("chunking changed the source document's metadata")
+	}
+}
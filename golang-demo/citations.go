@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Citation links a retrieved chunk to the answer it supports
+type Citation struct {
+	Index      int // 1-based marker number, as in [1]
+	DocumentID string
+	VectorDBID string // store the chunk came from, when known
+	Content    string
+	Score      float64
+	Metadata   map[string]interface{} // chunk metadata, when the server returned it
+}
+
+// pythonDictEntryPattern matches 'key': 'value' entries of a Python dict repr
+var pythonDictEntryPattern = regexp.MustCompile(`'([^']+)': (?:'([^']*)'|"([^"]*)"|([^,}]+))`)
+
+// CitationsFromQueryResult extracts the chunks of a RAG query result as
+// citations, see ParseQueryResult
+func CitationsFromQueryResult(result *QueryResult) []Citation {
+	return citationsFromChunks(ParseQueryResult(result))
+}
+
+// citationsFromChunks converts parsed chunks into unnumbered citations
+func citationsFromChunks(chunks []RAGChunk) []Citation {
+	if len(chunks) == 0 {
+		return nil
+	}
+	citations := make([]Citation, len(chunks))
+	for i, c := range chunks {
+		citations[i] = Citation{DocumentID: c.DocumentID, Content: c.Content, Score: c.Score, Metadata: c.Metadata}
+	}
+	return citations
+}
+
+// floatList converts a decoded JSON number array
+func floatList(v interface{}) []float64 {
+	switch list := v.(type) {
+	case []float64:
+		return list
+	case []interface{}:
+		result := make([]float64, len(list))
+		for i, item := range list {
+			result[i], _ = item.(float64)
+		}
+		return result
+	}
+	return nil
+}
+
+// CitationsFromTurn extracts the chunks retrieved by knowledge_search calls the
+// server ran during a turn
+func CitationsFromTurn(turn *Turn) []Citation {
+	if turn == nil {
+		return nil
+	}
+	var citations []Citation
+	for _, raw := range turn.Steps {
+		step, ok := raw.(map[string]interface{})
+		if !ok || step["step_type"] != "tool_execution" {
+			continue
+		}
+		responses, _ := step["tool_responses"].([]interface{})
+		for _, r := range responses {
+			resp, _ := r.(map[string]interface{})
+			if resp == nil || resp["tool_name"] != "knowledge_search" {
+				continue
+			}
+			citations = append(citations, citationsFromChunks(ParseKnowledgeSearchResponse(resp))...)
+		}
+	}
+	return citations
+}
+
+// CitationTracker collects citations across the tool calls of an agentic loop,
+// dropping repeated chunks and numbering the rest in order of first retrieval.
+// It is safe for concurrent use by parallel tool calls.
+type CitationTracker struct {
+	mu        sync.Mutex
+	citations []Citation
+	seen      map[string]int
+}
+
+// Add records citations, assigning each new chunk the next marker number
+func (t *CitationTracker) Add(citations ...Citation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.seen == nil {
+		t.seen = make(map[string]int)
+	}
+	for _, c := range citations {
+		key := c.DocumentID + "\x00" + c.Content
+		if i, ok := t.seen[key]; ok {
+			if c.Score > t.citations[i].Score {
+				t.citations[i].Score = c.Score
+			}
+			continue
+		}
+		c.Index = len(t.citations) + 1
+		t.seen[key] = len(t.citations)
+		t.citations = append(t.citations, c)
+	}
+}
+
+// Citations returns the recorded citations in marker order
+func (t *CitationTracker) Citations() []Citation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Citation(nil), t.citations...)
+}
+
+// CitedAnswer is a final answer together with the chunks it was built from
+type CitedAnswer struct {
+	Answer    string
+	Citations []Citation
+}
+
+// CitationRenderOptions controls CitedAnswer.Render
+type CitationRenderOptions struct {
+	InlineMarkers bool // append [n] markers to sentences that overlap chunk n
+	SourceList    bool // append a numbered list of the cited documents
+}
+
+// Render returns the answer text with the requested citation decorations
+func (a CitedAnswer) Render(opts CitationRenderOptions) string {
+	text := a.Answer
+	if opts.InlineMarkers && len(a.Citations) > 0 {
+		text = addCitationMarkers(text, a.Citations)
+	}
+	if opts.SourceList && len(a.Citations) > 0 {
+		var b strings.Builder
+		b.WriteString(strings.TrimRight(text, "\n"))
+		b.WriteString("\n\nSources:\n")
+		for _, c := range a.Citations {
+			snippet := strings.Join(strings.Fields(c.Content), " ")
+			if r := []rune(snippet); len(r) > 80 {
+				snippet = string(r[:80]) + "..."
+			}
+			fmt.Fprintf(&b, "[%d] %s: %s\n", c.Index, c.DocumentID, snippet)
+		}
+		text = b.String()
+	}
+	return text
+}
+
+// sentencePattern splits text into sentences, keeping trailing punctuation and space
+var sentencePattern = regexp.MustCompile(`(?s).*?(?:[.!?]+(?:\s+|$)|\n+|$)`)
+
+// addCitationMarkers appends [n] to every sentence sharing at least two
+// significant words with chunk n (or all of them, for very short sentences)
+func addCitationMarkers(text string, citations []Citation) string {
+	chunkWords := make([]map[string]bool, len(citations))
+	for i, c := range citations {
+		chunkWords[i] = make(map[string]bool)
+		for _, w := range significantWords(c.Content) {
+			chunkWords[i][w] = true
+		}
+	}
+
+	var b strings.Builder
+	for _, sentence := range sentencePattern.FindAllString(text, -1) {
+		if sentence == "" {
+			continue
+		}
+		words := significantWords(sentence)
+		var markers []int
+		for i, cw := range chunkWords {
+			overlap := 0
+			for _, w := range words {
+				if cw[w] {
+					overlap++
+				}
+			}
+			if overlap >= 2 || (overlap > 0 && overlap == len(words)) {
+				markers = append(markers, citations[i].Index)
+			}
+		}
+		if len(markers) == 0 {
+			b.WriteString(sentence)
+			continue
+		}
+
+		sort.Ints(markers)
+		var marks strings.Builder
+		for _, m := range markers {
+			fmt.Fprintf(&marks, "[%d]", m)
+		}
+		// Place the markers before the closing punctuation: "Ana owns Dora [1]."
+		body := strings.TrimRightFunc(sentence, unicode.IsSpace)
+		trailing := sentence[len(body):]
+		core := strings.TrimRight(body, ".!?")
+		b.WriteString(core + " " + marks.String() + body[len(core):] + trailing)
+	}
+	return b.String()
+}
+
+// stopWords are ignored when matching sentences to chunks
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "with": true,
+	"that": true, "this": true, "from": true, "have": true, "has": true, "not": true,
+	"but": true, "you": true, "your": true, "its": true, "their": true, "they": true,
+	"which": true, "what": true, "who": true, "according": true, "documents": true,
+}
+
+// significantWords returns the lower-cased words of s worth matching on
+func significantWords(s string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}) {
+		w = strings.TrimSuffix(strings.Trim(w, "'"), "'s")
+		if len([]rune(w)) >= 3 && !stopWords[w] {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// parsePythonDict reads the flat string entries of a Python dict repr, as the
+// server renders chunk metadata
+func parsePythonDict(s string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, m := range pythonDictEntryPattern.FindAllStringSubmatch(s, -1) {
+		switch {
+		case m[3] != "":
+			result[m[1]] = m[3]
+		case m[4] != "":
+			result[m[1]] = strings.TrimSpace(m[4])
+		default:
+			result[m[1]] = m[2]
+		}
+	}
+	return result
+}
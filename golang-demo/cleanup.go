@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PlaygroundTag marks vector stores created by this client in their
+// "created_by" metadata, so cleanup can tell them apart from other stores
+const PlaygroundTag = "llama-stack-playground"
+
+// Resource kinds found by CleanupResources. All but vector stores are
+// recorded in a ResourceLedger.
+const (
+	ResourceAgent       = "agent"
+	ResourceSession     = "session"
+	ResourceFile        = "file"
+	ResourceVectorStore = "vector store"
+)
+
+// tagMetadata returns a copy of metadata with the playground tag added,
+// unless the caller set created_by themselves
+func tagMetadata(metadata map[string]interface{}) map[string]interface{} {
+	tagged := make(map[string]interface{}, len(metadata)+1)
+	for k, v := range metadata {
+		tagged[k] = v
+	}
+	if _, ok := tagged["created_by"]; !ok {
+		tagged["created_by"] = PlaygroundTag
+	}
+	return tagged
+}
+
+// LedgerEntry records one resource the client created
+type LedgerEntry struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Parent    string    `json:"parent,omitempty"` // the agent of a session
+	BaseURL   string    `json:"base_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ResourceLedger is a local JSONL log of the agents, sessions and files the
+// client created. Unlike vector stores these carry no metadata on the server,
+// so the ledger is how cleanup finds them.
+type ResourceLedger struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultResourceLedgerPath is the ledger in the user's cache directory
+func DefaultResourceLedgerPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, PlaygroundTag, "resources.jsonl"), nil
+}
+
+// OpenResourceLedger opens or creates the ledger at path
+func OpenResourceLedger(path string) (*ResourceLedger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create resource ledger: %w", err)
+	}
+	return &ResourceLedger{path: path}, nil
+}
+
+// Record appends an entry
+func (l *ResourceLedger) Record(entry LedgerEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return errors.Join(err, f.Close())
+}
+
+// Entries returns every recorded entry, oldest first
+func (l *ResourceLedger) Entries() ([]LedgerEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.read()
+}
+
+func (l *ResourceLedger) read() ([]LedgerEntry, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []LedgerEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // a line cut short by a crash
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Forget removes the entries of the given resources by kind and ID
+func (l *ResourceLedger) Forget(removed []LedgerEntry) error {
+	if len(removed) == 0 {
+		return nil
+	}
+	drop := make(map[[2]string]bool, len(removed))
+	for _, e := range removed {
+		drop[[2]string{e.Kind, e.ID}] = true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries, err := l.read()
+	if err != nil {
+		return err
+	}
+	var data []byte
+	for _, e := range entries {
+		if drop[[2]string{e.Kind, e.ID}] {
+			continue
+		}
+		line, _ := json.Marshal(e)
+		data = append(append(data, line...), '\n')
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write resource ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to write resource ledger: %w", err)
+	}
+	return nil
+}
+
+// trackResource records a created resource in the client's ledger, if any.
+// Failing to record never fails the call that created the resource.
+func (c *LlamaStackClient) trackResource(ctx context.Context, kind, id, parent string) {
+	if c.Ledger == nil || id == "" {
+		return
+	}
+	entry := LedgerEntry{Kind: kind, ID: id, Parent: parent, BaseURL: c.baseURL(ctx), CreatedAt: time.Now()}
+	if err := c.Ledger.Record(entry); err != nil {
+		c.logger().WarnContext(ctx, "failed to record resource for cleanup", "kind", kind, "id", id, "error", err)
+	}
+}
+
+// CleanupOptions selects what CleanupResources deletes
+type CleanupOptions struct {
+	OlderThan time.Duration // only resources created at least this long ago
+	DryRun    bool          // list what would be deleted without deleting
+}
+
+// CleanupItem is a resource found by CleanupResources
+type CleanupItem struct {
+	Kind      string
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	Err       error // why deletion failed; nil when deleted or on a dry run
+	ledger    *LedgerEntry
+}
+
+// CleanupResources deletes the sessions, agents, vector stores and files this
+// tool created against the client's server: vector stores tagged with
+// PlaygroundTag and everything else recorded in the client's ledger.
+// Resources that are already gone are dropped from the ledger silently.
+func (c *LlamaStackClient) CleanupResources(ctx context.Context, opts CleanupOptions) ([]CleanupItem, error) {
+	ctx = ensureCorrelationID(ctx)
+	cutoff := time.Now().Add(-opts.OlderThan)
+	var items []CleanupItem
+
+	if c.Ledger != nil {
+		entries, err := c.Ledger.Entries()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read resource ledger: %w", err)
+		}
+		for i, e := range entries {
+			if e.BaseURL == c.baseURL(ctx) && e.CreatedAt.Before(cutoff) {
+				items = append(items, CleanupItem{Kind: e.Kind, ID: e.ID, CreatedAt: e.CreatedAt, ledger: &entries[i]})
+			}
+		}
+	}
+
+	stores, err := c.ListVectorStores(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vector stores: %w", err)
+	}
+	for _, vs := range stores {
+		created := time.Unix(vs.CreatedAt, 0)
+		if vs.Metadata["created_by"] == PlaygroundTag && created.Before(cutoff) {
+			items = append(items, CleanupItem{Kind: ResourceVectorStore, ID: vs.ID, Name: vs.Name, CreatedAt: created})
+		}
+	}
+
+	// Sessions go before their agents and files after the stores they
+	// were attached to
+	order := map[string]int{ResourceSession: 0, ResourceAgent: 1, ResourceVectorStore: 2, ResourceFile: 3}
+	sort.SliceStable(items, func(i, j int) bool { return order[items[i].Kind] < order[items[j].Kind] })
+	if opts.DryRun {
+		return items, nil
+	}
+
+	var gone []LedgerEntry
+	for i := range items {
+		item := &items[i]
+		var err error
+		switch item.Kind {
+		case ResourceSession:
+			err = c.DeleteSession(ctx, item.ledger.Parent, item.ID)
+		case ResourceAgent:
+			err = c.DeleteAgent(ctx, item.ID)
+		case ResourceVectorStore:
+			err = c.DeleteVectorStore(ctx, item.ID)
+		case ResourceFile:
+			err = c.DeleteFile(ctx, item.ID)
+		default:
+			err = fmt.Errorf("unknown resource kind %q", item.Kind)
+		}
+		var apiErr *APIError
+		if err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == 404) {
+			item.Err = err
+			continue
+		}
+		if item.ledger != nil {
+			gone = append(gone, *item.ledger)
+		}
+	}
+	if c.Ledger != nil && !c.dryRunning(ctx) {
+		if err := c.Ledger.Forget(gone); err != nil {
+			return items, err
+		}
+	}
+	return items, nil
+}
+
+// runCleanupCommand handles "cleanup [-older-than 24h] [-dry-run]"
+func runCleanupCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", 24*time.Hour, "only delete resources created at least this long ago")
+	dryRun := fs.Bool("dry-run", false, "list what would be deleted without deleting anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: cleanup [-older-than 24h] [-dry-run]")
+	}
+
+	items, err := client.CleanupResources(context.Background(), CleanupOptions{OlderThan: *olderThan, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+	failed := 0
+	for _, item := range items {
+		label := item.Kind + " " + item.ID
+		if item.Name != "" {
+			label += " (" + item.Name + ")"
+		}
+		age := time.Since(item.CreatedAt).Round(time.Minute)
+		if item.Err != nil {
+			failed++
+			fmt.Printf("FAILED %s, created %s ago: %v\n", label, age, item.Err)
+			continue
+		}
+		fmt.Printf("%s %s, created %s ago\n", verb, label, age)
+	}
+	fmt.Printf("%s %d of %d resources older than %s\n", verb, len(items)-failed, len(items), *olderThan)
+	if failed > 0 {
+		return fmt.Errorf("%d resources could not be deleted", failed)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrClientClosed is returned by calls made after Close
+var ErrClientClosed = errors.New("client is closed")
+
+// inflightCalls tracks the calls whose response bodies are still open, so
+// Close can wait for them or cancel them
+type inflightCalls struct {
+	mu      sync.Mutex
+	closed  bool
+	next    int
+	cancels map[int]context.CancelFunc
+	drained chan struct{} // closed once Close was called and no call is left
+}
+
+func newInflightCalls() *inflightCalls {
+	return &inflightCalls{cancels: make(map[int]context.CancelFunc)}
+}
+
+// start registers req and returns it with a context Close can cancel, and a
+// func that releases it
+func (ic *inflightCalls) start(req *http.Request) (*http.Request, func(), error) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if ic.closed {
+		return nil, nil, ErrClientClosed
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	id := ic.next
+	ic.next++
+	ic.cancels[id] = cancel
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			cancel()
+			ic.mu.Lock()
+			defer ic.mu.Unlock()
+			delete(ic.cancels, id)
+			if ic.closed && len(ic.cancels) == 0 {
+				close(ic.drained)
+			}
+		})
+	}
+	return req.WithContext(ctx), release, nil
+}
+
+// finishInflight releases a failed call now, and any other when its response
+// body is closed
+func finishInflight(release func(), resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		release()
+		return resp, err
+	}
+	resp.Body = &inflightBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// inflightBody releases its call when closed
+type inflightBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (ib *inflightBody) Close() error {
+	err := ib.ReadCloser.Close()
+	ib.release()
+	return err
+}
+
+// close stops new calls and returns a channel closed once none is in flight
+func (ic *inflightCalls) close() <-chan struct{} {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if !ic.closed {
+		ic.closed = true
+		ic.drained = make(chan struct{})
+		if len(ic.cancels) == 0 {
+			close(ic.drained)
+		}
+	}
+	return ic.drained
+}
+
+func (ic *inflightCalls) cancelAll() {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	for _, cancel := range ic.cancels {
+		cancel()
+	}
+}
+
+// Close shuts the client down for graceful service shutdown. New calls fail
+// with ErrClientClosed right away, calls in flight, including open streams,
+// may finish until ctx is done, and whatever is still running then is
+// cancelled and ctx's error returned. Idle connections are closed either way.
+// Calling Close again waits for the same calls.
+func (c *LlamaStackClient) Close(ctx context.Context) error {
+	var err error
+	if c.inflight != nil {
+		select {
+		case <-c.inflight.close():
+		case <-ctx.Done():
+			c.inflight.cancelAll()
+			err = ctx.Err()
+		}
+	}
+	c.HTTPClient.CloseIdleConnections()
+	return err
+}
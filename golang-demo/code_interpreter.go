@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CodeExecution is one code_interpreter call the server ran during a turn
+type CodeExecution struct {
+	CallID string
+	Code   string
+	// Status is the process status the tool reports, e.g. "completed",
+	// "error" or "timeout"
+	Status string
+	Stdout string
+	Stderr string
+	// Files are the images and files the code produced
+	Files []CodeArtifact
+}
+
+// Failed reports whether the code did not run to completion
+func (e CodeExecution) Failed() bool {
+	return e.Status != "" && e.Status != "completed"
+}
+
+// CodeArtifact is a file produced by code the server ran. It is either
+// inline in Data, an uploaded file in the files API named by FileID, or
+// only known by URI, e.g. a file:// path on the server.
+type CodeArtifact struct {
+	MimeType string
+	Data     []byte
+	FileID   string
+	URI      string
+}
+
+// codeSectionPattern matches the [stdout]...[/stdout] style sections of a
+// code_interpreter result
+var codeSectionPattern = regexp.MustCompile(`(?s)\[(\w+)\]\n?(.*?)\n?\[/(\w+)\]`)
+
+// ParseCodeExecution decodes the content of a code_interpreter tool
+// response: text with [process_status], [stdout] and [stderr] sections, and
+// image items for plots
+func ParseCodeExecution(content interface{}) (CodeExecution, error) {
+	var exec CodeExecution
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return exec, fmt.Errorf("failed to encode code_interpreter result: %w", err)
+	}
+	decoded, err := decodeDocumentContent(raw)
+	if err != nil {
+		return exec, fmt.Errorf("failed to decode code_interpreter result: %w", err)
+	}
+	items, ok := decoded.([]interface{})
+	if !ok {
+		items = []interface{}{decoded}
+	}
+
+	var text []string
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			text = append(text, v)
+		case SpecTextContentItem:
+			text = append(text, v.Text)
+		case SpecImageContentItem:
+			exec.Files = append(exec.Files, imageArtifact(v.Image))
+		}
+	}
+	joined := strings.Join(text, "\n")
+	matched := false
+	for _, m := range codeSectionPattern.FindAllStringSubmatch(joined, -1) {
+		if m[1] != m[3] {
+			continue
+		}
+		matched = true
+		switch m[1] {
+		case "process_status":
+			exec.Status = strings.TrimSpace(m[2])
+		case "stdout":
+			exec.Stdout = m[2]
+		case "stderr":
+			exec.Stderr = m[2]
+		}
+	}
+	if !matched {
+		// Plain text, e.g. an error message from the tool runtime
+		exec.Stdout = joined
+	}
+	return exec, nil
+}
+
+// imageArtifact turns an image content item into an artifact
+func imageArtifact(img SpecURLOrData) CodeArtifact {
+	if img.Data != nil {
+		data, err := base64.StdEncoding.DecodeString(*img.Data)
+		if err == nil {
+			return CodeArtifact{MimeType: "image/png", Data: data}
+		}
+	}
+	if img.URL != nil {
+		return uriArtifact(img.URL.URI, "")
+	}
+	return CodeArtifact{MimeType: "image/png"}
+}
+
+// filesAPIPattern extracts the file ID from a files API content URL
+var filesAPIPattern = regexp.MustCompile(`/v1/(?:openai/v1/)?files/([^/?#]+)(?:/content)?$`)
+
+// uriArtifact turns a URI into an artifact, decoding data: URLs and
+// recognising files API URLs
+func uriArtifact(uri, mimeType string) CodeArtifact {
+	artifact := CodeArtifact{MimeType: mimeType, URI: uri}
+	if rest, ok := strings.CutPrefix(uri, "data:"); ok {
+		meta, payload, _ := strings.Cut(rest, ",")
+		if data, err := base64.StdEncoding.DecodeString(payload); err == nil && strings.HasSuffix(meta, ";base64") {
+			artifact.Data = data
+			if artifact.MimeType == "" {
+				artifact.MimeType = strings.TrimSuffix(meta, ";base64")
+			}
+		}
+		return artifact
+	}
+	if m := filesAPIPattern.FindStringSubmatch(uri); m != nil {
+		artifact.FileID = m[1]
+	}
+	return artifact
+}
+
+// CodeExecutionsFromTurn decodes the code_interpreter calls the server ran
+// during a turn, with the code each call ran
+func CodeExecutionsFromTurn(turn *Turn) []CodeExecution {
+	if turn == nil {
+		return nil
+	}
+	var executions []CodeExecution
+	for _, raw := range turn.Steps {
+		step, ok := raw.(map[string]interface{})
+		if !ok || step["step_type"] != "tool_execution" {
+			continue
+		}
+		code := make(map[string]string) // code argument by call ID
+		calls, _ := step["tool_calls"].([]interface{})
+		for _, c := range calls {
+			call, _ := c.(map[string]interface{})
+			args, _ := call["arguments"].(map[string]interface{})
+			if id, _ := call["call_id"].(string); id != "" && args != nil {
+				code[id], _ = args["code"].(string)
+			}
+		}
+		responses, _ := step["tool_responses"].([]interface{})
+		for _, r := range responses {
+			resp, _ := r.(map[string]interface{})
+			if resp == nil || resp["tool_name"] != "code_interpreter" {
+				continue
+			}
+			exec, err := ParseCodeExecution(resp["content"])
+			if err != nil {
+				continue
+			}
+			exec.CallID, _ = resp["call_id"].(string)
+			exec.Code = code[exec.CallID]
+			executions = append(executions, exec)
+		}
+	}
+	return executions
+}
+
+// CodeArtifactsFromTurn returns the files produced during a turn: those
+// inline in code_interpreter results and the turn's output attachments
+func CodeArtifactsFromTurn(turn *Turn) []CodeArtifact {
+	if turn == nil {
+		return nil
+	}
+	var artifacts []CodeArtifact
+	for _, exec := range CodeExecutionsFromTurn(turn) {
+		artifacts = append(artifacts, exec.Files...)
+	}
+	for _, doc := range turn.OutputAttachments {
+		switch v := doc.Content.(type) {
+		case SpecURL:
+			artifacts = append(artifacts, uriArtifact(v.URI, doc.MimeType))
+		case SpecImageContentItem:
+			a := imageArtifact(v.Image)
+			if doc.MimeType != "" {
+				a.MimeType = doc.MimeType
+			}
+			artifacts = append(artifacts, a)
+		case string:
+			artifacts = append(artifacts, CodeArtifact{MimeType: doc.MimeType, Data: []byte(v)})
+		}
+	}
+	return artifacts
+}
+
+// DownloadCodeArtifacts saves artifacts to dir and returns the paths written,
+// in order. Files in the files API are downloaded under their uploaded name;
+// inline data is named artifact-N with an extension for its MIME type.
+// Artifacts only known by URI, such as paths on the server, are skipped.
+func (c *LlamaStackClient) DownloadCodeArtifacts(ctx context.Context, artifacts []CodeArtifact, dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	var paths []string
+	for i, a := range artifacts {
+		name := fmt.Sprintf("artifact-%d%s", i+1, artifactExtension(a.MimeType))
+		switch {
+		case a.Data != nil:
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, a.Data, 0o644); err != nil {
+				return paths, fmt.Errorf("failed to write artifact: %w", err)
+			}
+			paths = append(paths, path)
+		case a.FileID != "":
+			if file, err := c.GetFile(ctx, a.FileID); err == nil && file.Filename != "" {
+				name = filepath.Base(file.Filename)
+			}
+			path := filepath.Join(dir, name)
+			if err := c.downloadFileTo(ctx, a.FileID, path); err != nil {
+				return paths, err
+			}
+			paths = append(paths, path)
+		default:
+			c.logger().WarnContext(ctx, "skipping artifact that cannot be downloaded", "uri", a.URI)
+		}
+	}
+	return paths, nil
+}
+
+// downloadFileTo downloads an uploaded file to path, removing the partial
+// file on failure
+func (c *LlamaStackClient) downloadFileTo(ctx context.Context, fileID, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create artifact file: %w", err)
+	}
+	err = c.DownloadFile(ctx, fileID, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// artifactExtension returns a file extension for mimeType, or ""
+func artifactExtension(mimeType string) string {
+	switch mimeType {
+	case "":
+		return ""
+	case "image/png":
+		return ".png"
+	case "text/plain":
+		return ".txt"
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// CompletionParams represents the parameters for a legacy text completion
+type CompletionParams struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+
+	Suffix           string   `json:"suffix,omitempty"` // text that comes after the completion, for fill-in-the-middle
+	Echo             *bool    `json:"echo,omitempty"`   // include the prompt in the returned text
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	N                *int     `json:"n,omitempty"`
+	BestOf           *int     `json:"best_of,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	Logprobs         *bool    `json:"logprobs,omitempty"`
+	User             string   `json:"user,omitempty"`
+	Stream           *bool    `json:"stream,omitempty"`
+}
+
+// CompletionChoice represents one generated text of a completion
+type CompletionChoice struct {
+	Index        int         `json:"index"`
+	Text         string      `json:"text"`
+	FinishReason string      `json:"finish_reason,omitempty"`
+	Logprobs     interface{} `json:"logprobs,omitempty"`
+}
+
+// CompletionResponse represents a text completion, or one chunk of a streamed one
+type CompletionResponse struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []CompletionChoice `json:"choices"`
+}
+
+// CreateCompletion creates a legacy (non-chat) text completion
+func (c *LlamaStackClient) CreateCompletion(ctx context.Context, params CompletionParams) (*CompletionResponse, error) {
+	params.Stream = nil
+	c.applyProfile(params.Model, &params.Temperature, &params.TopP, &params.MaxTokens, &params.Stop)
+	ctx = c.applySampling(ctx, &params.Seed, &params.Temperature)
+
+	jsonData, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal completion params: %w", err)
+	}
+
+	url := c.BaseURL + "/v1/openai/v1/completions"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, body)
+	}
+
+	var response CompletionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// StreamCompletion streams a legacy text completion as a sequence of chunks.
+// Breaking out of the range loop closes the underlying response body.
+func (c *LlamaStackClient) StreamCompletion(ctx context.Context, params CompletionParams) iter.Seq2[CompletionResponse, error] {
+	return func(yield func(CompletionResponse, error) bool) {
+		stream := true
+		params.Stream = &stream
+		c.applyProfile(params.Model, &params.Temperature, &params.TopP, &params.MaxTokens, &params.Stop)
+		ctx := c.applySampling(ctx, &params.Seed, &params.Temperature)
+
+		jsonData, err := json.Marshal(params)
+		if err != nil {
+			yield(CompletionResponse{}, fmt.Errorf("failed to marshal completion params: %w", err))
+			return
+		}
+
+		url := c.BaseURL + "/v1/openai/v1/completions"
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+		if err != nil {
+			yield(CompletionResponse{}, fmt.Errorf("failed to create request: %w", err))
+			return
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+		resp, err := c.doStream(req)
+		if err != nil {
+			yield(CompletionResponse{}, fmt.Errorf("failed to make request: %w", err))
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			errBody, _ := io.ReadAll(resp.Body)
+			yield(CompletionResponse{}, newAPIError(resp, errBody))
+			return
+		}
+
+		stopped, done := false, false
+		err = readSSE(resp.Body, func(ev sseEvent) bool {
+			if ev.Data == "[DONE]" {
+				done = true
+				return false
+			}
+			var chunk CompletionResponse
+			if err := decodeSSEData(ev.Data, &chunk); err != nil {
+				yield(CompletionResponse{}, err)
+				stopped = true
+				return false
+			}
+			if !yield(chunk, nil) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if stopped || done {
+			return
+		}
+		if ctx.Err() != nil {
+			yield(CompletionResponse{}, ctx.Err())
+			return
+		}
+		if errors.Is(err, ErrSSELineTooLong) {
+			yield(CompletionResponse{}, err)
+			return
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		yield(CompletionResponse{}, fmt.Errorf("%w: %w", ErrStreamInterrupted, err))
+	}
+}
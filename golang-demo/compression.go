@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// WithRequestCompression gzips JSON and text request bodies of at least
+// minSize bytes, such as large RAG inserts; see CompressRequestsOver
+func WithRequestCompression(minSize int) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.CompressRequestsOver = minSize
+	}
+}
+
+// compressionState remembers that the server refused gzipped bodies
+type compressionState struct {
+	refused atomic.Bool
+}
+
+// compressible reports whether bodies of this content type are worth
+// gzipping; uploads of PDFs and other binaries usually are not
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "application/x-ndjson" || strings.HasPrefix(mediaType, "text/")
+}
+
+// compressRequest gzips req's body in place when compression is on and the
+// body is large enough. It returns the original body so the request can be
+// sent again uncompressed.
+func (c *LlamaStackClient) compressRequest(req *http.Request) ([]byte, bool, error) {
+	if c.CompressRequestsOver <= 0 || req.Body == nil || req.Header.Get("Content-Encoding") != "" ||
+		!compressible(req.Header.Get("Content-Type")) || (c.compression != nil && c.compression.refused.Load()) {
+		return nil, false, nil
+	}
+	plain, err := readRequestBody(req)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(plain) < c.CompressRequestsOver {
+		setRequestBody(req, plain)
+		return nil, false, nil
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(plain); err != nil {
+		return nil, false, fmt.Errorf("failed to compress request body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, false, fmt.Errorf("failed to compress request body: %w", err)
+	}
+	setRequestBody(req, compressed.Bytes())
+	req.Header.Set("Content-Encoding", "gzip")
+	return plain, true, nil
+}
+
+// setRequestBody replaces req's body with body, keeping it resendable
+func setRequestBody(req *http.Request, body []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+// sendCompressed sends req with its body gzipped if it qualifies. A server
+// that answers 415 Unsupported Media Type does not take gzipped bodies: the
+// request is sent again as is, and later requests are no longer compressed.
+func (c *LlamaStackClient) sendCompressed(req *http.Request) (*http.Response, error) {
+	plain, compressed, err := c.compressRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(req)
+	if !compressed || err != nil || resp.StatusCode != http.StatusUnsupportedMediaType {
+		return decompressResponse(resp, err)
+	}
+
+	resp.Body.Close()
+	if c.compression != nil {
+		c.compression.refused.Store(true)
+	}
+	c.logger().InfoContext(req.Context(), "server does not accept gzipped request bodies, sending uncompressed", "endpoint", req.URL.Path)
+	req.Header.Del("Content-Encoding")
+	setRequestBody(req, plain)
+	return decompressResponse(c.send(req))
+}
+
+// decompressResponse decodes a gzipped response body that the transport
+// left encoded, which happens when Accept-Encoding was set explicitly or a
+// custom RoundTripper is in use
+func decompressResponse(resp *http.Response, err error) (*http.Response, error) {
+	if err != nil || resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp, err
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to decompress response: %w", err)
+	}
+	resp.Body = &gzipBody{Reader: zr, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// gzipBody reads a gzipped body and closes the underlying one
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (gb *gzipBody) Close() error {
+	gb.Reader.Close()
+	return gb.body.Close()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TruncationStrategy selects how a ContextManager shrinks a conversation
+// that does not fit the model's context
+type TruncationStrategy string
+
+const (
+	// TruncateDropOldest drops the oldest exchanges until the rest fits
+	TruncateDropOldest TruncationStrategy = "drop-oldest"
+	// TruncateSlidingWindow keeps only the last WindowSize messages, then
+	// drops the oldest of those if they still do not fit
+	TruncateSlidingWindow TruncationStrategy = "sliding-window"
+	// TruncateSummarize replaces the oldest messages with a summary written
+	// by the model, keeping the last KeepRecent messages as they are
+	TruncateSummarize TruncationStrategy = "summarize"
+)
+
+// messageOverheadTokens approximates the chat template tokens around each message
+const messageOverheadTokens = 4
+
+// ContextManager keeps a conversation within a token budget before each
+// completion. Leading system messages and the last message are always kept.
+type ContextManager struct {
+	// MaxTokens is the budget for the messages: the model's context length
+	// minus room for the reply; defaults to 4096
+	MaxTokens int
+	Strategy  TruncationStrategy // defaults to TruncateDropOldest
+
+	WindowSize int // messages kept by TruncateSlidingWindow; defaults to 20
+	KeepRecent int // messages TruncateSummarize never summarizes; defaults to 4
+
+	// Client and Model write the summaries of TruncateSummarize
+	Client *LlamaStackClient
+	Model  string
+
+	// CountTokens estimates the tokens of a text; defaults to the
+	// CountTokens estimate for Model
+	CountTokens func(text string) int
+}
+
+// ParseTruncationStrategy parses a strategy name as used on the command line
+func ParseTruncationStrategy(name string) (TruncationStrategy, error) {
+	switch s := TruncationStrategy(name); s {
+	case TruncateDropOldest, TruncateSlidingWindow, TruncateSummarize:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown truncation strategy %q (want drop-oldest, sliding-window or summarize)", name)
+}
+
+func (m *ContextManager) maxTokens() int {
+	if m.MaxTokens > 0 {
+		return m.MaxTokens
+	}
+	return 4096
+}
+
+// Tokens estimates the tokens messages take up in a request
+func (m *ContextManager) Tokens(messages []Message) int {
+	count := m.CountTokens
+	if count == nil {
+		count = func(text string) int { return CountTokens(m.Model, text) }
+	}
+	total := 0
+	for _, msg := range messages {
+		total += count(msg.Content) + messageOverheadTokens
+	}
+	return total
+}
+
+// Fit returns messages shrunk to MaxTokens with the strategy, or messages
+// itself if they fit. Callers that keep a history should replace it with the
+// result, so a summary is written once rather than before every completion.
+// An error is returned if even the system messages and the last message do
+// not fit.
+func (m *ContextManager) Fit(ctx context.Context, messages []Message) ([]Message, error) {
+	limit := m.maxTokens()
+	if m.Tokens(messages) <= limit {
+		return messages, nil
+	}
+
+	system, rest := splitSystemMessages(messages)
+	switch m.Strategy {
+	case TruncateSlidingWindow:
+		window := m.WindowSize
+		if window <= 0 {
+			window = 20
+		}
+		if len(rest) > window {
+			rest = rest[len(rest)-window:]
+		}
+	case TruncateSummarize:
+		summarized, err := m.summarize(ctx, system, rest)
+		if err != nil {
+			return nil, err
+		}
+		system, rest = splitSystemMessages(summarized)
+	case TruncateDropOldest, "":
+	default:
+		return nil, fmt.Errorf("unknown truncation strategy %q", m.Strategy)
+	}
+
+	// Drop whole exchanges, a user message with the replies that follow it,
+	// so no reply is left without its question
+	for len(rest) > 1 && m.Tokens(system)+m.Tokens(rest) > limit {
+		next := 1
+		for next < len(rest)-1 && rest[next].Role != "user" {
+			next++
+		}
+		rest = rest[next:]
+	}
+	fitted := append(append([]Message(nil), system...), rest...)
+	if m.Tokens(fitted) > limit {
+		return nil, fmt.Errorf("context needs %d tokens even after truncation, over the limit of %d", m.Tokens(fitted), limit)
+	}
+	return fitted, nil
+}
+
+// splitSystemMessages splits off the leading system messages
+func splitSystemMessages(messages []Message) (system, rest []Message) {
+	i := 0
+	for i < len(messages) && messages[i].Role == "system" {
+		i++
+	}
+	return messages[:i], messages[i:]
+}
+
+// summarize replaces the oldest of rest, all but KeepRecent, with a system
+// message summarizing them
+func (m *ContextManager) summarize(ctx context.Context, system, rest []Message) ([]Message, error) {
+	if m.Client == nil || m.Model == "" {
+		return nil, errors.New("summarize strategy needs a Client and Model")
+	}
+	keep := m.KeepRecent
+	if keep <= 0 {
+		keep = 4
+	}
+	if len(rest) <= keep {
+		return append(append([]Message(nil), system...), rest...), nil
+	}
+	old, recent := rest[:len(rest)-keep], rest[len(rest)-keep:]
+
+	var transcript strings.Builder
+	for _, msg := range old {
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, msg.Content)
+	}
+	resp, err := m.Client.CreateChatCompletion(ctx, ChatCompletionParams{
+		Model: m.Model,
+		Messages: []Message{
+			{Role: "system", Content: "Summarize the conversation below in a few sentences. Keep names, numbers, decisions and open questions."},
+			{Role: "user", Content: transcript.String()},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("failed to summarize conversation: no choices in response")
+	}
+
+	summarized := append([]Message(nil), system...)
+	summarized = append(summarized, Message{Role: "system", Content: "Summary of the earlier conversation: " + resp.Choices[0].Message.Content})
+	return append(summarized, recent...), nil
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// ConversationInfo is a stored conversation of the OpenAI-compatible
+// Conversations API. Unlike an agent session it belongs to no agent: any
+// Responses API call naming it reads and extends its items.
+type ConversationInfo struct {
+	ID        string            `json:"id"`
+	Object    string            `json:"object,omitempty"`
+	CreatedAt int64             `json:"created_at,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// ConversationContent is one content part of a message item
+type ConversationContent struct {
+	Type        string       `json:"type"` // "input_text" or "output_text"
+	Text        string       `json:"text"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// ConversationItem is one item of a conversation or of a response's input
+// and output: a message, a function call and its output, or a server-side
+// tool call such as file_search
+type ConversationItem struct {
+	Type    string                `json:"type"` // "message", "function_call", "function_call_output", "file_search_call", ...
+	ID      string                `json:"id,omitempty"`
+	Role    string                `json:"role,omitempty"`
+	Status  string                `json:"status,omitempty"`
+	Content []ConversationContent `json:"content,omitempty"`
+
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    string `json:"output,omitempty"`
+
+	Queries []string           `json:"queries,omitempty"` // of a file_search_call
+	Results []FileSearchResult `json:"results,omitempty"` // of a file_search_call, when included
+}
+
+// MessageItem returns a message item from role with text, as input text for
+// user and system messages and output text for assistant ones
+func MessageItem(role, text string) ConversationItem {
+	contentType := "input_text"
+	if role == "assistant" {
+		contentType = "output_text"
+	}
+	return ConversationItem{Type: "message", Role: role, Content: []ConversationContent{{Type: contentType, Text: text}}}
+}
+
+// Text returns the text of a message item's content parts
+func (item ConversationItem) Text() string {
+	var b strings.Builder
+	for _, part := range item.Content {
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
+
+func (item ConversationItem) pageID() string { return item.ID }
+
+// ListConversationItemsResponse is a page of a conversation's items
+type ListConversationItemsResponse = Page[ConversationItem]
+
+// CreateConversation starts a conversation with items, which may be empty
+func (c *LlamaStackClient) CreateConversation(ctx context.Context, items []ConversationItem, metadata map[string]string) (*ConversationInfo, error) {
+	body := struct {
+		Items    []ConversationItem `json:"items,omitempty"`
+		Metadata map[string]string  `json:"metadata,omitempty"`
+	}{items, metadata}
+	var conv ConversationInfo
+	if err := c.postJSON(ctx, "/v1/conversations", body, &conv); err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+// GetConversation returns a conversation without its items
+func (c *LlamaStackClient) GetConversation(ctx context.Context, conversationID string) (*ConversationInfo, error) {
+	var conv ConversationInfo
+	if err := c.getJSON(ctx, "/v1/conversations/"+url.PathEscape(conversationID), &conv); err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+// UpdateConversation replaces a conversation's metadata
+func (c *LlamaStackClient) UpdateConversation(ctx context.Context, conversationID string, metadata map[string]string) (*ConversationInfo, error) {
+	body := struct {
+		Metadata map[string]string `json:"metadata"`
+	}{metadata}
+	var conv ConversationInfo
+	if err := c.postJSON(ctx, "/v1/conversations/"+url.PathEscape(conversationID), body, &conv); err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+// DeleteConversation deletes a conversation and its items
+func (c *LlamaStackClient) DeleteConversation(ctx context.Context, conversationID string) error {
+	return c.sendDelete(ctx, "/v1/conversations/"+url.PathEscape(conversationID))
+}
+
+// AddConversationItems appends items to a conversation and returns them as
+// stored, with their IDs
+func (c *LlamaStackClient) AddConversationItems(ctx context.Context, conversationID string, items []ConversationItem) ([]ConversationItem, error) {
+	body := struct {
+		Items []ConversationItem `json:"items"`
+	}{items}
+	var page ListConversationItemsResponse
+	if err := c.postJSON(ctx, "/v1/conversations/"+url.PathEscape(conversationID)+"/items", body, &page); err != nil {
+		return nil, err
+	}
+	return page.Data, nil
+}
+
+// ListConversationItems returns every item of a conversation, oldest first
+func (c *LlamaStackClient) ListConversationItems(ctx context.Context, conversationID string) ([]ConversationItem, error) {
+	return listAfter[ConversationItem](ctx, c, "/v1/conversations/"+url.PathEscape(conversationID)+"/items?order=asc")
+}
+
+// ResponseParams creates a response with the Responses API. Input is a
+// string or a []ConversationItem. With Conversation set, the server reads
+// the conversation's items as context and appends the input and output to
+// it; PreviousResponseID chains responses without a conversation instead.
+type ResponseParams struct {
+	Model              string        `json:"model"`
+	Input              interface{}   `json:"input"`
+	Instructions       string        `json:"instructions,omitempty"`
+	Conversation       string        `json:"conversation,omitempty"`
+	PreviousResponseID string        `json:"previous_response_id,omitempty"`
+	Store              *bool         `json:"store,omitempty"`
+	Stream             bool          `json:"stream,omitempty"` // set by CreateResponseStream
+	Temperature        *float64      `json:"temperature,omitempty"`
+	Tools              []interface{} `json:"tools,omitempty"`   // FileSearchTool or raw tool definitions
+	Include            []string      `json:"include,omitempty"` // e.g. IncludeFileSearchResults
+
+	// Background makes CreateResponse return at once with the response
+	// queued; wait for it with WaitForResponse
+	Background bool `json:"background,omitempty"`
+}
+
+// ResponseUsage is the token usage of a response
+type ResponseUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// ResponseObject is a response of the Responses API
+type ResponseObject struct {
+	ID                 string             `json:"id"`
+	Object             string             `json:"object,omitempty"`
+	CreatedAt          int64              `json:"created_at,omitempty"`
+	Model              string             `json:"model"`
+	Status             string             `json:"status"`
+	Output             []ConversationItem `json:"output"`
+	PreviousResponseID string             `json:"previous_response_id,omitempty"`
+	Usage              *ResponseUsage     `json:"usage,omitempty"`
+	Error              *ResponseError     `json:"error,omitempty"`
+	Background         bool               `json:"background,omitempty"`
+}
+
+// ResponseError is why a response failed, or an error event of a response
+// stream
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+}
+
+func (e *ResponseError) Error() string {
+	if e.Code == "" {
+		return "response error: " + e.Message
+	}
+	return fmt.Sprintf("response error %s: %s", e.Code, e.Message)
+}
+
+// OutputText returns the text of the response's assistant messages
+func (r *ResponseObject) OutputText() string {
+	var parts []string
+	for _, item := range r.Output {
+		if item.Type == "message" && item.Role == "assistant" {
+			parts = append(parts, item.Text())
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// CreateResponse creates a response with the Responses API
+func (c *LlamaStackClient) CreateResponse(ctx context.Context, params ResponseParams) (*ResponseObject, error) {
+	if params.Input == nil {
+		return nil, errors.New("response input is required")
+	}
+	if err := validateResponseTools(params.Tools); err != nil {
+		return nil, err
+	}
+	params.Stream = false
+	var response ResponseObject
+	if err := c.postJSON(ctx, "/v1/openai/v1/responses", params, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// runConversationCommand handles "conversation": each prompt is answered by
+// the Responses API within one conversation, new or given with -id, whose
+// items are listed at the end
+func runConversationCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("conversation", flag.ContinueOnError)
+	model := fs.String("model", "", "model to ask; the first LLM by default")
+	conversationID := fs.String("id", "", "conversation to continue; a new one by default")
+	instructions := fs.String("instructions", "", "system instructions sent with each prompt")
+	stream := fs.Bool("stream", false, "print each reply as it is generated")
+	background := fs.Bool("background", false, "run each response in the background and poll for it; Ctrl-C cancels it")
+	chain := fs.Bool("chain", false, "chain responses with previous_response_id instead of storing a conversation")
+	after := fs.String("after", "", "with -chain, the response to continue from")
+	fileSearch := fs.String("file-search", "", "comma-separated vector stores the model can search, with sources listed after each reply")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || *stream && *background || *chain && (*conversationID != "" || *background) || *after != "" && !*chain {
+		return errors.New("usage: conversation [-id CONVERSATION] [-model MODEL] [-instructions TEXT] [-file-search STORES] [-stream | -background] PROMPT...\n       conversation -chain [-after RESPONSE] [-model MODEL] [-instructions TEXT] [-file-search STORES] [-stream] PROMPT...")
+	}
+
+	ctx := context.Background()
+	if *model == "" {
+		var err error
+		if *model, err = client.GetAvailableModel(ctx); err != nil {
+			return fmt.Errorf("no model to ask: %w", err)
+		}
+	}
+	var tools []interface{}
+	var include []string
+	if *fileSearch != "" {
+		tools = []interface{}{FileSearchTool{VectorStoreIDs: strings.Split(*fileSearch, ",")}}
+		include = []string{IncludeFileSearchResults}
+	}
+	if *chain {
+		thread := NewResponseThread(client, *model)
+		thread.Instructions, thread.LastResponseID = *instructions, *after
+		thread.Tools, thread.Include = tools, include
+		return runResponseThread(ctx, thread, fs.Args(), *stream)
+	}
+	if *conversationID == "" {
+		conv, err := client.CreateConversation(ctx, nil, map[string]string{"source": "llama-stack-playground"})
+		if err != nil {
+			return fmt.Errorf("failed to create conversation: %w", err)
+		}
+		*conversationID = conv.ID
+		fmt.Printf("Created conversation %s\n", conv.ID)
+	}
+
+	for _, prompt := range fs.Args() {
+		params := ResponseParams{
+			Model:        *model,
+			Input:        prompt,
+			Instructions: *instructions,
+			Conversation: *conversationID,
+			Tools:        tools,
+			Include:      include,
+		}
+		if *stream {
+			fmt.Printf("User: %s\nAssistant: ", prompt)
+			response, err := printResponseStream(client.CreateResponseStream(ctx, params))
+			if err != nil {
+				return fmt.Errorf("failed to stream response: %w", err)
+			}
+			printResponseSources(response)
+			continue
+		}
+		params.Background = *background
+		response, err := client.CreateResponse(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to create response: %w", err)
+		}
+		if *background {
+			if response, err = waitForBackgroundResponse(ctx, client, response); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("User: %s\nAssistant: %s\n", prompt, response.OutputText())
+		printResponseSources(response)
+	}
+
+	items, err := client.ListConversationItems(ctx, *conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to list conversation items: %w", err)
+	}
+	fmt.Printf("\nConversation %s has %s:\n", *conversationID, pluralize(len(items), "item"))
+	for _, item := range items {
+		switch item.Type {
+		case "message":
+			fmt.Printf("  %s  %s: %s\n", item.ID, item.Role, item.Text())
+		case "function_call":
+			fmt.Printf("  %s  call %s(%s)\n", item.ID, item.Name, item.Arguments)
+		case "file_search_call":
+			fmt.Printf("  %s  file_search %q: %s\n", item.ID, strings.Join(item.Queries, " | "), pluralize(len(item.Results), "result"))
+		default:
+			fmt.Printf("  %s  %s\n", item.ID, item.Type)
+		}
+	}
+	return nil
+}
+
+// runResponseThread answers each prompt as the next turn of thread and
+// prints the response to continue from
+func runResponseThread(ctx context.Context, thread *ResponseThread, prompts []string, stream bool) error {
+	for _, prompt := range prompts {
+		if stream {
+			fmt.Printf("User: %s\nAssistant: ", prompt)
+			response, err := printResponseStream(thread.Stream(ctx, prompt))
+			if err != nil {
+				return fmt.Errorf("failed to stream response: %w", err)
+			}
+			printResponseSources(response)
+			continue
+		}
+		response, err := thread.Send(ctx, prompt)
+		if err != nil {
+			return fmt.Errorf("failed to create response: %w", err)
+		}
+		fmt.Printf("User: %s\nAssistant: %s\n", prompt, response.OutputText())
+		printResponseSources(response)
+	}
+	fmt.Printf("\nContinue with -chain -after %s\n", thread.LastResponseID)
+	return nil
+}
+
+// printResponseSources lists the chunks a response's file_search calls
+// retrieved, as the rag examples list their sources
+func printResponseSources(response *ResponseObject) {
+	if citations := CitationsFromResponse(response); len(citations) > 0 {
+		fmt.Print(strings.TrimLeft(CitedAnswer{Citations: citations}.Render(CitationRenderOptions{SourceList: true}), "\n"))
+	}
+}
+
+// waitForBackgroundResponse polls a background response, printing its status
+// as it changes, and cancels it on Ctrl-C
+func waitForBackgroundResponse(ctx context.Context, client *LlamaStackClient, response *ResponseObject) (*ResponseObject, error) {
+	fmt.Printf("Response %s %s\n", response.ID, response.Status)
+	waitCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	last := response.Status
+	done, err := client.WaitForResponse(waitCtx, response.ID, PollOptions[*ResponseObject]{
+		Interval: 250 * time.Millisecond,
+		Progress: func(r *ResponseObject, attempt int) {
+			if r.Status != last {
+				fmt.Printf("Response %s %s\n", r.ID, r.Status)
+				last = r.Status
+			}
+		},
+	})
+	if waitCtx.Err() != nil && ctx.Err() == nil {
+		if _, cancelErr := client.CancelResponse(ctx, response.ID); cancelErr != nil {
+			return nil, fmt.Errorf("failed to cancel response %s: %w", response.ID, cancelErr)
+		}
+		return nil, fmt.Errorf("cancelled response %s", response.ID)
+	}
+	return done, err
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DocumentConverter turns the content of one file into RAG documents. name is
+// the file's path relative to the ingested directory and is used to derive
+// document IDs.
+type DocumentConverter interface {
+	Convert(name string, r io.Reader) ([]Document, error)
+}
+
+// DocumentConverterFunc adapts a function to DocumentConverter
+type DocumentConverterFunc func(name string, r io.Reader) ([]Document, error)
+
+// Convert calls f
+func (f DocumentConverterFunc) Convert(name string, r io.Reader) ([]Document, error) {
+	return f(name, r)
+}
+
+// Mime types of the built-in converters
+const (
+	MimeText     = "text/plain"
+	MimeMarkdown = "text/markdown"
+	MimeHTML     = "text/html"
+	MimeCSV      = "text/csv"
+	MimeJSONL    = "application/jsonl"
+	MimeDOCX     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+var (
+	convertersMu sync.RWMutex
+	converters   = map[string]DocumentConverter{
+		MimeText:     DocumentConverterFunc(convertText),
+		MimeMarkdown: DocumentConverterFunc(convertMarkdown),
+		MimeHTML:     DocumentConverterFunc(convertHTML),
+		MimeDOCX:     DocumentConverterFunc(convertDOCX),
+		MimeCSV:      CSVConverter{},
+		MimeJSONL:    JSONLConverter{},
+	}
+
+	// converterExtensions maps extensions that mime.TypeByExtension does not
+	// know everywhere
+	converterExtensions = map[string]string{
+		".txt":      MimeText,
+		".text":     MimeText,
+		".md":       MimeMarkdown,
+		".markdown": MimeMarkdown,
+		".html":     MimeHTML,
+		".htm":      MimeHTML,
+		".csv":      MimeCSV,
+		".jsonl":    MimeJSONL,
+		".ndjson":   MimeJSONL,
+		".docx":     MimeDOCX,
+	}
+)
+
+// RegisterConverter makes conv handle files of mimeType, replacing any
+// converter registered for it before
+func RegisterConverter(mimeType string, conv DocumentConverter) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	converters[mimeType] = conv
+}
+
+// ConverterFor returns the converter registered for mimeType; parameters
+// such as "; charset=utf-8" are ignored
+func ConverterFor(mimeType string) (DocumentConverter, bool) {
+	if media, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = media
+	}
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	conv, ok := converters[mimeType]
+	return conv, ok
+}
+
+// DetectMimeType guesses a file's mime type from its extension, falling back
+// to sniffing the first bytes of its content
+func DetectMimeType(name string, head []byte) string {
+	ext := strings.ToLower(path.Ext(name))
+	if mimeType, ok := converterExtensions[ext]; ok {
+		return mimeType
+	}
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return mimeType
+	}
+	return http.DetectContentType(head)
+}
+
+// ConvertDocument detects the mime type of a file and converts it with the
+// registered converter
+func ConvertDocument(name string, r io.Reader) ([]Document, error) {
+	var head [512]byte
+	n, err := io.ReadFull(r, head[:])
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	mimeType := DetectMimeType(name, head[:n])
+	conv, ok := ConverterFor(mimeType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (%s)", ErrNoConverter, name, mimeType)
+	}
+	docs, err := conv.Convert(name, io.MultiReader(bytes.NewReader(head[:n]), r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s: %w", name, err)
+	}
+	for i := range docs {
+		if docs[i].Metadata == nil {
+			docs[i].Metadata = map[string]interface{}{}
+		}
+		if _, ok := docs[i].Metadata["source"]; !ok {
+			docs[i].Metadata["source"] = name
+		}
+		docs[i].Metadata["source_mime_type"] = mimeType
+		if docs[i].MimeType == "" {
+			docs[i].MimeType = MimeText
+		}
+	}
+	return docs, nil
+}
+
+// ErrNoConverter is returned by ConvertDocument for unsupported file types
+var ErrNoConverter = errors.New("no converter registered")
+
+func convertText(name string, r io.Reader) ([]Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Document{{DocumentID: name, Content: string(data)}}, nil
+}
+
+// convertMarkdown keeps the Markdown as it is but moves YAML front matter into
+// the metadata, with the first heading as title when the front matter has none
+func convertMarkdown(name string, r io.Reader) ([]Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	metadata := map[string]interface{}{}
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		if front, body, ok := strings.Cut(rest+"\n", "\n---\n"); ok {
+			metadata = parseFrontMatter(front)
+			text = body
+		}
+	}
+	if _, ok := metadata["title"]; !ok {
+		for _, line := range strings.Split(text, "\n") {
+			if title, ok := strings.CutPrefix(line, "# "); ok {
+				metadata["title"] = strings.TrimSpace(title)
+				break
+			}
+		}
+	}
+	return []Document{{DocumentID: name, Content: strings.TrimSpace(text), Metadata: metadata}}, nil
+}
+
+// parseFrontMatter reads the flat "key: value" subset of YAML that front
+// matter uses in practice, including [a, b] lists; nested blocks are skipped
+func parseFrontMatter(front string) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	for _, line := range strings.Split(front, "\n") {
+		if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if inner, ok := strings.CutPrefix(value, "["); ok && strings.HasSuffix(inner, "]") {
+			// Attributes only hold scalars, so lists are kept as "a, b"
+			var items []string
+			for _, item := range strings.Split(strings.TrimSuffix(inner, "]"), ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, fmt.Sprint(frontMatterScalar(item)))
+				}
+			}
+			metadata[strings.TrimSpace(key)] = strings.Join(items, ", ")
+			continue
+		}
+		metadata[strings.TrimSpace(key)] = frontMatterScalar(value)
+	}
+	return metadata
+}
+
+func frontMatterScalar(value string) interface{} {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1]
+	}
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n
+	}
+	return value
+}
+
+var (
+	htmlDropRe  = regexp.MustCompile(`(?is)<!--.*?-->|<(script|style|noscript|template|svg)\b.*?</(script|style|noscript|template|svg)\s*>`)
+	htmlTitleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlBlockRe = regexp.MustCompile(`(?i)<(br|/?p|/?div|/?li|/?ul|/?ol|/?tr|/?table|/?h[1-6]|/?section|/?article|/?blockquote|/?pre|hr)\b[^>]*>`)
+	htmlTagRe   = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankRunRe  = regexp.MustCompile(`\n{3,}`)
+	spaceRunRe  = regexp.MustCompile(`[ \t\f\v]+`)
+)
+
+// convertHTML extracts the visible text of a page, keeping block elements on
+// their own lines, and its <title> as metadata
+func convertHTML(name string, r io.Reader) ([]Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	page := string(data)
+	metadata := map[string]interface{}{}
+	if m := htmlTitleRe.FindStringSubmatch(page); m != nil {
+		if title := strings.TrimSpace(html.UnescapeString(htmlTagRe.ReplaceAllString(m[1], ""))); title != "" {
+			metadata["title"] = title
+		}
+		page = strings.Replace(page, m[0], "", 1)
+	}
+	return []Document{{DocumentID: name, Content: htmlVisibleText(page), Metadata: metadata}}, nil
+}
+
+// htmlVisibleText drops scripts, styles and tags from a page, keeping block
+// elements on their own lines
+func htmlVisibleText(page string) string {
+	page = htmlDropRe.ReplaceAllString(page, "")
+	page = htmlBlockRe.ReplaceAllString(page, "\n")
+	page = html.UnescapeString(htmlTagRe.ReplaceAllString(page, ""))
+	return tidyText(page)
+}
+
+// tidyText collapses runs of spaces and blank lines
+func tidyText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaceRunRe.ReplaceAllString(strings.ReplaceAll(line, "\u00a0", " "), " "))
+	}
+	return strings.TrimSpace(blankRunRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// convertDOCX extracts the paragraphs of word/document.xml and the title and
+// author from docProps/core.xml
+func convertDOCX(name string, r io.Reader) ([]Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a DOCX archive: %w", err)
+	}
+
+	var text strings.Builder
+	metadata := map[string]interface{}{}
+	found := false
+	for _, f := range archive.File {
+		switch f.Name {
+		case "word/document.xml":
+			found = true
+			if err := readZipXML(f, func(d *xml.Decoder) error { return docxText(d, &text) }); err != nil {
+				return nil, err
+			}
+		case "docProps/core.xml":
+			readZipXML(f, func(d *xml.Decoder) error { return docxProperties(d, metadata) }) // optional
+		}
+	}
+	if !found {
+		return nil, errors.New("DOCX archive has no word/document.xml")
+	}
+	return []Document{{DocumentID: name, Content: tidyText(text.String()), Metadata: metadata}}, nil
+}
+
+func readZipXML(f *zip.File, read func(*xml.Decoder) error) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	if err := read(xml.NewDecoder(rc)); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// docxText writes the text runs of a WordprocessingML body, one paragraph per line
+func docxText(d *xml.Decoder, text *strings.Builder) error {
+	inText := false
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteByte('\t')
+			case "br", "cr":
+				text.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+// docxProperties reads dc:title, dc:creator and dc:subject
+func docxProperties(d *xml.Decoder, metadata map[string]interface{}) error {
+	keys := map[string]string{"title": "title", "creator": "author", "subject": "subject"}
+	current := ""
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			current = keys[t.Name.Local]
+		case xml.EndElement:
+			current = ""
+		case xml.CharData:
+			if value := strings.TrimSpace(string(t)); current != "" && value != "" {
+				metadata[current] = value
+			}
+		}
+	}
+}
+
+// CSVConverter turns every row of a CSV file with a header row into a
+// document. The row's fields become metadata; the content is the
+// ContentColumns, or every column, rendered as "column: value" lines.
+type CSVConverter struct {
+	ContentColumns []string // columns embedded as content; all columns when empty
+	IDColumn       string   // column holding a unique row ID; the row number when empty
+}
+
+// Convert implements DocumentConverter
+func (c CSVConverter) Convert(name string, r io.Reader) ([]Document, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+	columns := c.ContentColumns
+	if len(columns) == 0 {
+		columns = header
+	}
+
+	var docs []Document
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := make(map[string]interface{}, len(header)+1)
+		for i, column := range header {
+			if i < len(record) {
+				fields[column] = record[i]
+			}
+		}
+		var content strings.Builder
+		for _, column := range columns {
+			if value, _ := fields[column].(string); value != "" {
+				fmt.Fprintf(&content, "%s: %s\n", column, value)
+			}
+		}
+		id := strconv.Itoa(row)
+		if value, _ := fields[c.IDColumn].(string); c.IDColumn != "" && value != "" {
+			id = value
+		}
+		fields["row"] = row
+		docs = append(docs, Document{DocumentID: name + "#" + id, Content: strings.TrimSpace(content.String()), Metadata: fields})
+	}
+}
+
+// JSONLConverter turns every line of a JSON Lines file into a document. The
+// ContentField holds the text; the other scalar fields become metadata.
+type JSONLConverter struct {
+	ContentField string // defaults to "content", then "text"
+	IDField      string // defaults to "id", then "document_id"; the line number when absent
+}
+
+// Convert implements DocumentConverter
+func (c JSONLConverter) Convert(name string, r io.Reader) ([]Document, error) {
+	contentFields := []string{"content", "text"}
+	if c.ContentField != "" {
+		contentFields = []string{c.ContentField}
+	}
+	idFields := []string{"id", "document_id"}
+	if c.IDField != "" {
+		idFields = []string{c.IDField}
+	}
+
+	var docs []Document
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	for line := 1; ; line++ {
+		var record map[string]interface{}
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line, err)
+		}
+
+		doc := Document{DocumentID: name + "#" + strconv.Itoa(line), Metadata: map[string]interface{}{}}
+		contentKey := ""
+		for _, field := range contentFields {
+			if text, ok := record[field].(string); ok {
+				doc.Content, contentKey = text, field
+				break
+			}
+		}
+		for _, field := range idFields {
+			if id, ok := record[field]; ok && id != nil {
+				doc.DocumentID = name + "#" + fmt.Sprint(id)
+				break
+			}
+		}
+		keys := make([]string, 0, len(record))
+		for key := range record {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			switch value := record[key].(type) {
+			case string, bool, json.Number:
+				if key != contentKey {
+					doc.Metadata[key] = value
+				}
+			}
+		}
+		if contentKey == "" {
+			// No text field: embed the whole record
+			data, _ := json.Marshal(record)
+			doc.Content = string(data)
+		}
+		docs = append(docs, doc)
+	}
+}
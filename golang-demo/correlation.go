@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// CorrelationIDHeader carries the correlation ID on every request made with a
+// context from WithCorrelationID
+const CorrelationIDHeader = "X-Correlation-Id"
+
+type correlationIDKey struct{}
+
+// NewCorrelationID returns a random ID for one logical operation
+func NewCorrelationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "op-" + hex.EncodeToString(b)
+}
+
+// WithCorrelationID returns a context whose requests all carry id in the
+// X-Correlation-Id header, so the calls of one logical operation, such as a
+// whole RAG workflow, can be found together in client and server logs
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID of ctx, or "" if it has none
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// ensureCorrelationID keeps the caller's correlation ID, or starts a new one
+// for an operation that makes several calls
+func ensureCorrelationID(ctx context.Context) context.Context {
+	if CorrelationID(ctx) != "" {
+		return ctx
+	}
+	return WithCorrelationID(ctx, NewCorrelationID())
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModelPrice is what calls to one model cost: per token for hosted models,
+// or per GPU-second of call time for local ones. Both may be set.
+type ModelPrice struct {
+	PromptPer1K     float64 `json:"prompt_per_1k,omitempty"`     // dollars per 1,000 prompt tokens
+	CompletionPer1K float64 `json:"completion_per_1k,omitempty"` // dollars per 1,000 completion tokens
+	// PerGPUSecond is the dollars one GPU costs per second, charged for the
+	// duration of each call times GPUs
+	PerGPUSecond float64 `json:"per_gpu_second,omitempty"`
+	GPUs         float64 `json:"gpus,omitempty"`  // GPUs serving the model; defaults to 1
+	Watts        float64 `json:"watts,omitempty"` // power draw per GPU, for energy estimates
+}
+
+// PricingTable maps model IDs, or patterns with '*' wildcards as in
+// ModelProfiles, to their prices
+type PricingTable map[string]ModelPrice
+
+// For returns the price of model and whether the table has one
+func (t PricingTable) For(model string) (ModelPrice, bool) {
+	return lookupModel(t, model)
+}
+
+// CostEstimate is the estimated cost of one or more calls
+type CostEstimate struct {
+	USD        float64
+	GPUSeconds float64 // call time times GPUs, for models priced by time
+	EnergyWh   float64 // for models with a power draw
+}
+
+// Add returns the sum of e and o
+func (e CostEstimate) Add(o CostEstimate) CostEstimate {
+	return CostEstimate{USD: e.USD + o.USD, GPUSeconds: e.GPUSeconds + o.GPUSeconds, EnergyWh: e.EnergyWh + o.EnergyWh}
+}
+
+func (e CostEstimate) String() string {
+	// Local calls cost fractions of a cent, so small amounts keep more digits
+	s := fmt.Sprintf("$%.4f", e.USD)
+	if e.USD > 0 && e.USD < 0.01 {
+		s = fmt.Sprintf("$%.6f", e.USD)
+	}
+	if e.GPUSeconds > 0 {
+		s += fmt.Sprintf(", %.3f GPU-s", e.GPUSeconds)
+	}
+	if e.EnergyWh > 0 {
+		s += fmt.Sprintf(", %.4f Wh", e.EnergyWh)
+	}
+	return s
+}
+
+// Estimate prices a call to model with usage that took d
+func (t PricingTable) Estimate(model string, usage TokenUsage, d time.Duration) (CostEstimate, bool) {
+	price, ok := t.For(model)
+	if !ok {
+		return CostEstimate{}, false
+	}
+	gpus := price.GPUs
+	if gpus == 0 {
+		gpus = 1
+	}
+	var est CostEstimate
+	est.USD = float64(usage.PromptTokens)/1000*price.PromptPer1K + float64(usage.CompletionTokens)/1000*price.CompletionPer1K
+	if price.PerGPUSecond > 0 || price.Watts > 0 {
+		est.GPUSeconds = d.Seconds() * gpus
+		est.USD += est.GPUSeconds * price.PerGPUSecond
+		est.EnergyWh = est.GPUSeconds * price.Watts / 3600
+	}
+	return est, true
+}
+
+// LoadPricingTable reads prices from a YAML file, or JSON when path ends in
+// .json, keyed by model ID:
+//
+//	openai/gpt-4o-mini:
+//	  prompt_per_1k: 0.00015
+//	  completion_per_1k: 0.0006
+//	ollama/*:
+//	  per_gpu_second: 0.0004
+//	  watts: 350
+func LoadPricingTable(path string) (PricingTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing table: %w", err)
+	}
+	var table PricingTable
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &table)
+	} else {
+		err = unmarshalYAML(data, &table)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pricing table %s: %w", path, err)
+	}
+	return table, nil
+}
+
+// WithPricing estimates the cost of chat completion, text completion and
+// embedding calls with table. Estimates are in each call's metadata and log
+// line, and add up in the client's Costs.
+func WithPricing(table PricingTable) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.Pricing = table
+		c.Costs = &CostTracker{}
+	}
+}
+
+// ModelCost is what the calls to one model used and cost
+type ModelCost struct {
+	Model            string
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	CallTime         time.Duration
+	CostEstimate
+}
+
+// CostTracker adds up the estimated cost of a client's calls per model, for
+// the session's usage summary
+type CostTracker struct {
+	mu     sync.Mutex
+	models map[string]*ModelCost
+}
+
+func (t *CostTracker) add(model string, usage TokenUsage, d time.Duration, est CostEstimate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.models == nil {
+		t.models = map[string]*ModelCost{}
+	}
+	mc := t.models[model]
+	if mc == nil {
+		mc = &ModelCost{Model: model}
+		t.models[model] = mc
+	}
+	mc.Calls++
+	mc.PromptTokens += usage.PromptTokens
+	mc.CompletionTokens += usage.CompletionTokens
+	mc.CallTime += d
+	mc.CostEstimate = mc.CostEstimate.Add(est)
+}
+
+// Summary returns the usage and cost of each model, costliest first
+func (t *CostTracker) Summary() []ModelCost {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	summary := make([]ModelCost, 0, len(t.models))
+	for _, mc := range t.models {
+		summary = append(summary, *mc)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].USD != summary[j].USD {
+			return summary[i].USD > summary[j].USD
+		}
+		return summary[i].Model < summary[j].Model
+	})
+	return summary
+}
+
+// Total returns the usage and cost of every model together
+func (t *CostTracker) Total() ModelCost {
+	total := ModelCost{Model: "total"}
+	for _, mc := range t.Summary() {
+		total.Calls += mc.Calls
+		total.PromptTokens += mc.PromptTokens
+		total.CompletionTokens += mc.CompletionTokens
+		total.CallTime += mc.CallTime
+		total.CostEstimate = total.CostEstimate.Add(mc.CostEstimate)
+	}
+	return total
+}
+
+// Footer describes the session's cost in a few lines, with one line per
+// model when several were called
+func (t *CostTracker) Footer() string {
+	summary := t.Summary()
+	if len(summary) == 0 {
+		return "Estimated cost: no priced calls"
+	}
+	total := t.Total()
+	var b strings.Builder
+	fmt.Fprintf(&b, "Estimated cost: %s (%s, %d tokens, %s of calls)",
+		total.CostEstimate, pluralize(total.Calls, "call"), total.PromptTokens+total.CompletionTokens, total.CallTime.Round(time.Millisecond))
+	if len(summary) > 1 {
+		for _, mc := range summary {
+			fmt.Fprintf(&b, "\n  %s: %s (%s, %d tokens)", mc.Model, mc.CostEstimate, pluralize(mc.Calls, "call"), mc.PromptTokens+mc.CompletionTokens)
+		}
+	}
+	return b.String()
+}
+
+// costedPath reports whether calls to path are priced
+func costedPath(path string) bool {
+	return strings.HasSuffix(path, "/chat/completions") || strings.HasSuffix(path, "/completions") || strings.HasSuffix(path, "/embeddings")
+}
+
+type callCostKey struct{}
+
+// callCostSlot carries a call's estimate from the body that computes it to
+// the logging and metadata layers, which see the body closed after it
+type callCostSlot struct {
+	cost *CostEstimate
+}
+
+// withCostSlot gives req a slot for its cost estimate
+func withCostSlot(req *http.Request) (*http.Request, *callCostSlot) {
+	slot := &callCostSlot{}
+	return req.WithContext(context.WithValue(req.Context(), callCostKey{}, slot)), slot
+}
+
+// callCost returns the estimate of the call made with ctx, once known
+func callCost(ctx context.Context) *CostEstimate {
+	if slot, ok := ctx.Value(callCostKey{}).(*callCostSlot); ok {
+		return slot.cost
+	}
+	return nil
+}
+
+// costCall estimates a call's cost when its body is closed, from the usage
+// in the body or, for chat completions without one, an estimate. Failed
+// calls and error responses are not charged.
+func (c *LlamaStackClient) costCall(slot *callCostSlot, start time.Time, chat chatPromptEstimate, resp *http.Response, err error) (*http.Response, error) {
+	if err != nil || resp.StatusCode >= 400 {
+		return resp, err
+	}
+	resp.Body = &costBody{ReadCloser: resp.Body, client: c, slot: slot, start: start, contentType: resp.Header.Get("Content-Type"), chat: chat}
+	return resp, nil
+}
+
+// costBody keeps the start of a response body to find its token usage and model
+type costBody struct {
+	io.ReadCloser
+	client      *LlamaStackClient
+	slot        *callCostSlot
+	start       time.Time
+	contentType string
+	chat        chatPromptEstimate
+
+	buf  bytes.Buffer
+	once sync.Once
+}
+
+func (cb *costBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	if room := logBodyLimit + 1 - cb.buf.Len(); room > 0 {
+		cb.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+func (cb *costBody) Close() error {
+	err := cb.ReadCloser.Close()
+	cb.once.Do(func() {
+		d := time.Since(cb.start)
+		usage := callUsage(cb.contentType, cb.buf.Bytes(), cb.chat)
+		model := cb.chat.model
+		if model == "" {
+			model = responseModel(cb.contentType, cb.buf.Bytes())
+		}
+		est, ok := cb.client.Pricing.Estimate(model, usage, d)
+		if !ok {
+			return
+		}
+		cb.slot.cost = &est
+		if cb.client.Costs != nil {
+			cb.client.Costs.add(model, usage, d, est)
+		}
+	})
+	return err
+}
+
+// callUsage returns the token usage in a body, or for a chat completion that
+// reports none an estimate of its prompt and of the reply in the body. A
+// body longer than logBodyLimit was only kept in part and counts as none.
+func callUsage(contentType string, body []byte, chat chatPromptEstimate) TokenUsage {
+	complete := len(body) <= logBodyLimit
+	if complete {
+		if usage := usageFromBody(contentType, body); usage != nil && usage.TotalTokens > 0 {
+			return *usage
+		}
+	}
+	usage := TokenUsage{PromptTokens: chat.tokens}
+	if chat.tokens > 0 && complete {
+		usage.CompletionTokens = CountTokens(chat.model, replyText(contentType, body))
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// responseModel finds the model a JSON body, or the first event of an event
+// stream, says answered
+func responseModel(contentType string, body []byte) string {
+	var envelope struct {
+		Model string `json:"model"`
+	}
+	if !strings.HasPrefix(contentType, "text/event-stream") {
+		json.Unmarshal(body, &envelope)
+		return envelope.Model
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			if json.Unmarshal([]byte(strings.TrimSpace(data)), &envelope) == nil && envelope.Model != "" {
+				return envelope.Model
+			}
+		}
+	}
+	return ""
+}
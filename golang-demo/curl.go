@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CurlCommand returns a curl command equivalent to req. Secrets in
+// redactedHeaders become shell variables such as $LLAMASTACK_API_KEY, so the
+// command can be shared as is. Multipart uploads become -F fields with the
+// original file names, which must exist where the command is run. The body of
+// req is read and replaced, so req can still be sent afterwards.
+func CurlCommand(req *http.Request) (string, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	var form []string
+	if mediaType, params, err := mime.ParseMediaType(contentType); err == nil && mediaType == "multipart/form-data" {
+		if form, err = curlFormFields(body, params["boundary"]); err != nil {
+			return "", err
+		}
+	}
+
+	args := []string{"curl"}
+	var params struct {
+		Stream bool `json:"stream"`
+	}
+	if strings.Contains(req.Header.Get("Accept"), "text/event-stream") || (json.Unmarshal(body, &params) == nil && params.Stream) {
+		args = append(args, "-N") // print events as they arrive
+	}
+	if req.Method != http.MethodGet {
+		args = append(args, "-X "+req.Method)
+	}
+	args = append(args, shellQuote(req.URL.String()))
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if form != nil && name == "Content-Type" {
+			continue // curl sets it with its own boundary
+		}
+		for _, value := range req.Header[name] {
+			args = append(args, "-H "+curlHeader(name, value))
+		}
+	}
+
+	var note string
+	switch {
+	case form != nil:
+		args = append(args, form...)
+	case len(body) == 0:
+	case !utf8.Valid(body):
+		note = fmt.Sprintf("# the %d-byte binary request body is not shown; save it as request-body.bin\n", len(body))
+		args = append(args, "--data-binary @request-body.bin")
+	default:
+		args = append(args, "--data-raw "+shellQuote(string(body)))
+	}
+	return note + strings.Join(args, " \\\n  "), nil
+}
+
+// curlHeader renders a header for -H, replacing secret values with a shell
+// variable named after the header
+func curlHeader(name, value string) string {
+	for _, secret := range redactedHeaders {
+		if http.CanonicalHeaderKey(secret) != name {
+			continue
+		}
+		variable := "$" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if name == "Authorization" {
+			scheme, _, found := strings.Cut(value, " ")
+			if found {
+				return `"Authorization: ` + scheme + ` $LLAMASTACK_API_KEY"`
+			}
+			variable = "$LLAMASTACK_API_KEY"
+		}
+		return `"` + name + `: ` + variable + `"`
+	}
+	return shellQuote(name + ": " + value)
+}
+
+// curlFormFields turns a multipart body into -F arguments for files and
+// --form-string for values, which curl then sends verbatim
+func curlFormFields(body []byte, boundary string) ([]string, error) {
+	var fields []string
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return fields, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse multipart body: %w", err)
+		}
+		if part.FileName() != "" {
+			fields = append(fields, "-F "+shellQuote(part.FormName()+"=@"+part.FileName()))
+			continue
+		}
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse multipart body: %w", err)
+		}
+		fields = append(fields, "--form-string "+shellQuote(part.FormName()+"="+string(value)))
+	}
+}
+
+// shellQuote quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
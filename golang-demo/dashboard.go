@@ -0,0 +1,686 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The dashboard follows the model/update/view shape of bubbletea without the
+// dependency: key presses, refresh ticks and finished API calls arrive as
+// messages, update changes the state and may start a command (an API call
+// run in the background), and view renders the whole screen from the state.
+
+// dashboardPanel identifies a panel of the dashboard
+type dashboardPanel int
+
+const (
+	panelModels dashboardPanel = iota
+	panelAgents
+	panelSessions
+	panelVectorStores
+	panelFiles
+	panelCount
+)
+
+var (
+	dashboardPanelNames = [panelCount]string{"Models", "Agents", "Sessions", "Vector stores", "Files"}
+	dashboardItemNouns  = [panelCount]string{"model", "agent", "session", "vector store", "file"}
+)
+
+// dashboardItem is one row of a panel
+type dashboardItem struct {
+	ID      string
+	Parent  string   // the agent of a session
+	Columns []string // what the list shows
+	Value   interface{}
+}
+
+// dashboardSnapshot holds everything loaded by one refresh
+type dashboardSnapshot struct {
+	Items  [panelCount][]dashboardItem
+	Errors [panelCount]error
+	At     time.Time
+}
+
+// Messages handled by dashboard.update
+type (
+	dashboardKey       string
+	dashboardTick      struct{}
+	dashboardLoaded    dashboardSnapshot
+	dashboardInspected struct {
+		Item  dashboardItem
+		Value interface{}
+		Err   error
+	}
+	dashboardDeleted struct {
+		Panel dashboardPanel
+		Item  dashboardItem
+		Err   error
+	}
+)
+
+// dashboardCmd is work started by update, run off the UI loop; its result is
+// the next message
+type dashboardCmd func() interface{}
+
+// dashboardTimeout bounds every API call the dashboard makes
+const dashboardTimeout = 30 * time.Second
+
+type dashboard struct {
+	client *LlamaStackClient
+
+	snapshot dashboardSnapshot
+	loading  bool
+	focus    dashboardPanel
+	cursor   [panelCount]int
+
+	inspecting *dashboardItem
+	inspect    []string // lines of the inspected item
+	scroll     int
+	confirm    *dashboardItem // item waiting for a y/n before deletion
+	status     string
+
+	width, height int
+}
+
+func newDashboard(client *LlamaStackClient) *dashboard {
+	return &dashboard{client: client, width: 80, height: 24}
+}
+
+// init returns the command that loads the first snapshot
+func (d *dashboard) init() dashboardCmd {
+	d.loading = true
+	return d.loadCmd()
+}
+
+// update applies a message and reports any follow-up command and whether the
+// dashboard should exit
+func (d *dashboard) update(msg interface{}) (dashboardCmd, bool) {
+	switch msg := msg.(type) {
+	case dashboardTick:
+		if d.loading {
+			return nil, false
+		}
+		d.loading = true
+		return d.loadCmd(), false
+
+	case dashboardLoaded:
+		d.loading = false
+		d.snapshot = dashboardSnapshot(msg)
+		for p := range d.cursor {
+			d.cursor[p] = clampIndex(d.cursor[p], len(d.snapshot.Items[p]))
+		}
+		return nil, false
+
+	case dashboardInspected:
+		if d.inspecting == nil || d.inspecting.ID != msg.Item.ID {
+			return nil, false // closed or moved on while loading
+		}
+		if msg.Err != nil {
+			d.status = fmt.Sprintf("Failed to load %s: %v", msg.Item.ID, msg.Err)
+			msg.Value = msg.Item.Value
+		}
+		d.inspect = inspectLines(msg.Value)
+		return nil, false
+
+	case dashboardDeleted:
+		if msg.Err != nil {
+			d.status = fmt.Sprintf("Failed to delete %s: %v", msg.Item.ID, msg.Err)
+			return nil, false
+		}
+		d.status = fmt.Sprintf("Deleted %s %s", dashboardItemNouns[msg.Panel], msg.Item.ID)
+		d.loading = true
+		return d.loadCmd(), false
+
+	case dashboardKey:
+		return d.key(string(msg))
+	}
+	return nil, false
+}
+
+func (d *dashboard) key(k string) (dashboardCmd, bool) {
+	if k == "ctrl+c" {
+		return nil, true
+	}
+
+	if d.confirm != nil {
+		item := *d.confirm
+		d.confirm = nil
+		if k != "y" && k != "Y" {
+			d.status = "Delete cancelled"
+			return nil, false
+		}
+		d.inspecting, d.inspect = nil, nil
+		d.status = "Deleting " + item.ID + "..."
+		return d.deleteCmd(d.focus, item), false
+	}
+
+	if d.inspecting != nil {
+		switch k {
+		case "esc", "q", "backspace", "left", "h":
+			d.inspecting, d.inspect = nil, nil
+		case "up", "k":
+			d.scroll = max(d.scroll-1, 0)
+		case "down", "j":
+			d.scroll = min(d.scroll+1, max(len(d.inspect)-1, 0))
+		case "pgup":
+			d.scroll = max(d.scroll-d.bodyHeight(), 0)
+		case "pgdown", " ":
+			d.scroll = min(d.scroll+d.bodyHeight(), max(len(d.inspect)-1, 0))
+		case "d":
+			return d.askDelete(), false
+		}
+		return nil, false
+	}
+
+	items := d.snapshot.Items[d.focus]
+	switch k {
+	case "q", "esc":
+		return nil, true
+	case "tab", "right", "l":
+		d.focus = (d.focus + 1) % panelCount
+	case "shift+tab", "left", "h":
+		d.focus = (d.focus + panelCount - 1) % panelCount
+	case "1", "2", "3", "4", "5":
+		d.focus = dashboardPanel(k[0] - '1')
+	case "up", "k":
+		d.cursor[d.focus] = max(d.cursor[d.focus]-1, 0)
+	case "down", "j":
+		d.cursor[d.focus] = clampIndex(d.cursor[d.focus]+1, len(items))
+	case "home", "g":
+		d.cursor[d.focus] = 0
+	case "end", "G":
+		d.cursor[d.focus] = clampIndex(len(items)-1, len(items))
+	case "r":
+		d.status = ""
+		if !d.loading {
+			d.loading = true
+			return d.loadCmd(), false
+		}
+	case "enter", "i":
+		if item, ok := d.selected(); ok {
+			d.inspecting = &item
+			d.inspect = inspectLines(item.Value)
+			d.scroll = 0
+			return d.inspectCmd(d.focus, item), false
+		}
+	case "d":
+		return d.askDelete(), false
+	}
+	return nil, false
+}
+
+func (d *dashboard) askDelete() dashboardCmd {
+	item, ok := d.selected()
+	switch {
+	case !ok:
+	case d.focus == panelModels:
+		d.status = "Models are registered by the server's run config and cannot be deleted here"
+	default:
+		d.confirm = &item
+	}
+	return nil
+}
+
+func (d *dashboard) selected() (dashboardItem, bool) {
+	items := d.snapshot.Items[d.focus]
+	if len(items) == 0 {
+		return dashboardItem{}, false
+	}
+	return items[d.cursor[d.focus]], true
+}
+
+// loadCmd fetches every panel. A failing panel shows its error instead of
+// its items, so a server without e.g. the agents API still shows the rest.
+func (d *dashboard) loadCmd() dashboardCmd {
+	client := d.client
+	return func() interface{} {
+		ctx, cancel := context.WithTimeout(context.Background(), dashboardTimeout)
+		defer cancel()
+		return dashboardLoaded(loadDashboardSnapshot(ctx, client))
+	}
+}
+
+func loadDashboardSnapshot(ctx context.Context, client *LlamaStackClient) dashboardSnapshot {
+	var snap dashboardSnapshot
+	snap.At = time.Now()
+
+	var models ListModelsResponse
+	if err := client.getJSON(ctx, "/v1/models", &models); err != nil {
+		snap.Errors[panelModels] = err
+	}
+	for _, m := range models.Data {
+		snap.Items[panelModels] = append(snap.Items[panelModels], dashboardItem{
+			ID: m.Identifier, Columns: []string{m.Identifier, m.ModelType}, Value: m,
+		})
+	}
+
+	agents, err := client.ListAgents(ctx)
+	if err != nil {
+		snap.Errors[panelAgents] = err
+		snap.Errors[panelSessions] = err
+	}
+	// Sessions show their generated title, if any, instead of their name
+	titles := map[string]string{}
+	if client.Titles != nil {
+		titles, _ = client.Titles.All()
+	}
+	for _, a := range agents {
+		snap.Items[panelAgents] = append(snap.Items[panelAgents], dashboardItem{
+			ID: a.AgentID, Columns: []string{a.AgentID, a.AgentConfig.Name, a.AgentConfig.Model, pluralize(len(a.AgentConfig.Toolgroups), "toolgroup")}, Value: a,
+		})
+		sessions, err := client.ListSessions(ctx, a.AgentID)
+		if err != nil {
+			snap.Errors[panelSessions] = err
+			continue
+		}
+		for _, s := range sessions {
+			name := s.SessionName
+			if titles[s.SessionID] != "" {
+				name = titles[s.SessionID]
+			}
+			snap.Items[panelSessions] = append(snap.Items[panelSessions], dashboardItem{
+				ID: s.SessionID, Parent: a.AgentID, Columns: []string{s.SessionID, name, "agent " + a.AgentID, s.StartedAt}, Value: s,
+			})
+		}
+	}
+
+	stores, err := client.ListVectorStores(ctx)
+	if err != nil {
+		snap.Errors[panelVectorStores] = err
+	}
+	for _, vs := range stores {
+		snap.Items[panelVectorStores] = append(snap.Items[panelVectorStores], dashboardItem{
+			ID: vs.ID, Columns: []string{vs.ID, vs.Name, pluralize(vs.FileCounts["total"], "file"), vs.Status, vectorStoreExpiry(vs, time.Now())}, Value: vs,
+		})
+	}
+
+	var files ListFilesResponse
+	if err := client.getJSON(ctx, "/v1/openai/v1/files", &files); err != nil {
+		snap.Errors[panelFiles] = err
+	}
+	for _, f := range files.Data {
+		snap.Items[panelFiles] = append(snap.Items[panelFiles], dashboardItem{
+			ID: f.ID, Columns: []string{f.ID, f.Filename, strconv.Itoa(f.Bytes) + " bytes", f.Purpose}, Value: f,
+		})
+	}
+	return snap
+}
+
+// inspectCmd fetches the details the list does not have: a session's turns
+// and a vector store's files
+func (d *dashboard) inspectCmd(panel dashboardPanel, item dashboardItem) dashboardCmd {
+	client := d.client
+	return func() interface{} {
+		ctx, cancel := context.WithTimeout(context.Background(), dashboardTimeout)
+		defer cancel()
+		msg := dashboardInspected{Item: item, Value: item.Value}
+		switch panel {
+		case panelSessions:
+			var session Session
+			msg.Err = client.getJSON(ctx, "/v1/agents/"+url.PathEscape(item.Parent)+"/session/"+url.PathEscape(item.ID), &session)
+			msg.Value = session
+		case panelVectorStores:
+			files, err := client.ListVectorStoreFiles(ctx, item.ID)
+			msg.Err = err
+			msg.Value = struct {
+				VectorStore interface{}       `json:"vector_store"`
+				Files       []VectorStoreFile `json:"files"`
+			}{item.Value, files}
+		}
+		return msg
+	}
+}
+
+func (d *dashboard) deleteCmd(panel dashboardPanel, item dashboardItem) dashboardCmd {
+	client := d.client
+	return func() interface{} {
+		ctx, cancel := context.WithTimeout(context.Background(), dashboardTimeout)
+		defer cancel()
+		msg := dashboardDeleted{Panel: panel, Item: item}
+		switch panel {
+		case panelAgents:
+			msg.Err = client.DeleteAgent(ctx, item.ID)
+		case panelSessions:
+			msg.Err = client.DeleteSession(ctx, item.Parent, item.ID)
+		case panelVectorStores:
+			msg.Err = client.DeleteVectorStore(ctx, item.ID)
+		case panelFiles:
+			msg.Err = client.DeleteFile(ctx, item.ID)
+		default:
+			msg.Err = errors.New("cannot delete this kind of resource")
+		}
+		return msg
+	}
+}
+
+// bodyHeight is the number of screen lines between the header and footer
+func (d *dashboard) bodyHeight() int {
+	return max(d.height-2, 1)
+}
+
+// view renders the whole screen. Every panel is shown with a few rows and
+// the focused one gets the remaining space.
+func (d *dashboard) view() []string {
+	lines := []string{d.header()}
+	if d.inspecting != nil {
+		lines = append(lines, ansiStyle(ansiHeading, dashboardPanelNames[d.focus]+" › "+d.inspecting.ID))
+		end := min(d.scroll+d.bodyHeight()-1, len(d.inspect))
+		lines = append(lines, d.inspect[min(d.scroll, end):end]...)
+	} else {
+		lines = append(lines, d.panels()...)
+	}
+	for len(lines) < d.height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines[:d.height-1], d.footer())
+	for i, line := range lines {
+		lines[i] = truncateANSI(line, d.width)
+	}
+	return lines
+}
+
+func (d *dashboard) header() string {
+	state := "loading..."
+	if !d.snapshot.At.IsZero() {
+		state = "updated " + d.snapshot.At.Format("15:04:05")
+		if d.loading {
+			state += ", refreshing..."
+		}
+	}
+	return ansiStyle(ansiBold, "Llama Stack playground") + "  " + d.client.BaseURL + "  " + ansiStyle(ansiDim, state)
+}
+
+func (d *dashboard) footer() string {
+	switch {
+	case d.confirm != nil:
+		return ansiStyle(ansiBullet, fmt.Sprintf("Delete %s %s? y/n", dashboardItemNouns[d.focus], d.confirm.ID))
+	case d.status != "":
+		return d.status
+	case d.inspecting != nil:
+		return ansiStyle(ansiDim, "↑/↓ scroll  d delete  esc back")
+	}
+	return ansiStyle(ansiDim, "←/→ panel  ↑/↓ select  enter inspect  d delete  r refresh  q quit")
+}
+
+// dashboardCollapsedRows is how many rows unfocused panels show
+const dashboardCollapsedRows = 3
+
+func (d *dashboard) panels() []string {
+	var rows [panelCount]int
+	space := d.bodyHeight() - int(panelCount) // one title line per panel
+	for p := range panelCount {
+		if p != d.focus {
+			rows[p] = min(max(len(d.snapshot.Items[p]), 1), dashboardCollapsedRows)
+			space -= rows[p]
+		}
+	}
+	rows[d.focus] = max(space, 1)
+
+	var lines []string
+	for p := range panelCount {
+		items := d.snapshot.Items[p]
+		title := fmt.Sprintf("%d %s (%d)", p+1, dashboardPanelNames[p], len(items))
+		if p == d.focus {
+			lines = append(lines, ansiStyle(ansiHeading, "▸ "+title))
+		} else {
+			lines = append(lines, ansiStyle(ansiBold, "  "+title))
+		}
+
+		switch {
+		case d.snapshot.Errors[p] != nil:
+			lines = append(lines, "    "+ansiStyle(ansiBullet, d.snapshot.Errors[p].Error()))
+			continue
+		case len(items) == 0:
+			lines = append(lines, "    "+ansiStyle(ansiDim, "none"))
+			continue
+		}
+
+		columns := make([][]string, len(items))
+		for i, item := range items {
+			columns[i] = item.Columns
+		}
+		text := alignColumns(columns)
+		start := 0
+		if p == d.focus {
+			start = max(d.cursor[p]-rows[p]+1, 0)
+		}
+		for i := start; i < min(start+rows[p], len(items)); i++ {
+			if p == d.focus && i == d.cursor[p] {
+				lines = append(lines, "  \x1b[7m "+text[i]+" "+ansiReset)
+			} else {
+				lines = append(lines, "   "+text[i])
+			}
+		}
+	}
+	return lines
+}
+
+// alignColumns pads each column to its widest cell
+func alignColumns(rows [][]string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	out := make([]string, len(rows))
+	for r, row := range rows {
+		var b strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+			}
+		}
+		out[r] = b.String()
+	}
+	return out
+}
+
+func inspectLines(v interface{}) []string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return strings.Split(string(data), "\n")
+}
+
+func ansiStyle(style, s string) string {
+	return "\x1b[" + style + "m" + s + ansiReset
+}
+
+// truncateANSI cuts s to width visible runes, keeping escape sequences
+func truncateANSI(s string, width int) string {
+	visible := 0
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			end := strings.IndexByte(s[i:], 'm')
+			if end < 0 {
+				return s[:i]
+			}
+			i += end + 1
+			continue
+		}
+		if visible == width {
+			return s[:i] + ansiReset
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		visible++
+	}
+	return s
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func clampIndex(i, n int) int {
+	return max(min(i, n-1), 0)
+}
+
+// parseKeys splits terminal input into key names such as "up", "enter",
+// "ctrl+c" or the typed character
+func parseKeys(b []byte) []dashboardKey {
+	sequences := map[string]dashboardKey{
+		"\x1b[A": "up", "\x1b[B": "down", "\x1b[C": "right", "\x1b[D": "left",
+		"\x1bOA": "up", "\x1bOB": "down", "\x1bOC": "right", "\x1bOD": "left",
+		"\x1b[H": "home", "\x1b[F": "end", "\x1b[1~": "home", "\x1b[4~": "end",
+		"\x1b[5~": "pgup", "\x1b[6~": "pgdown", "\x1b[Z": "shift+tab",
+	}
+	var keys []dashboardKey
+	s := string(b)
+	for len(s) > 0 {
+		if s[0] == '\x1b' {
+			matched := false
+			for seq, key := range sequences {
+				if strings.HasPrefix(s, seq) {
+					keys, s, matched = append(keys, key), s[len(seq):], true
+					break
+				}
+			}
+			if !matched {
+				keys, s = append(keys, "esc"), s[1:]
+			}
+			continue
+		}
+		switch s[0] {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case '\t':
+			keys = append(keys, "tab")
+		case 3:
+			keys = append(keys, "ctrl+c")
+		case 127, 8:
+			keys = append(keys, "backspace")
+		default:
+			r, size := utf8.DecodeRuneInString(s)
+			keys, s = append(keys, dashboardKey(string(r))), s[size:]
+			continue
+		}
+		s = s[1:]
+	}
+	return keys
+}
+
+// stty runs stty on the controlling terminal; the standard library has no
+// portable way to switch a terminal to raw mode
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// terminalSize returns the terminal's width and height, 80x24 if unknown
+func terminalSize() (int, int) {
+	out, err := stty("size")
+	if err != nil {
+		return 80, 24
+	}
+	var rows, cols int
+	if _, err := fmt.Sscan(out, &rows, &cols); err != nil || rows <= 0 || cols <= 0 {
+		return 80, 24
+	}
+	return cols, rows
+}
+
+// runDashboardCommand handles "dashboard [-refresh 5s]"
+func runDashboardCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	refresh := fs.Duration("refresh", 5*time.Second, "how often panels are reloaded")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *refresh <= 0 {
+		return errors.New("usage: dashboard [-refresh 5s]")
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return errors.New("the dashboard needs an interactive terminal")
+	}
+
+	// Log lines would tear the screen, so the dashboard reports errors itself
+	client.Logger = nil
+
+	state, err := stty("-g")
+	if err != nil {
+		return err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return err
+	}
+	defer stty(state)
+	// Alternate screen and hidden cursor, restored on exit
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	defer os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+
+	msgs := make(chan interface{}, 16)
+	run := func(cmd dashboardCmd) {
+		if cmd != nil {
+			go func() { msgs <- cmd() }()
+		}
+	}
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				msgs <- dashboardKey("ctrl+c")
+				return
+			}
+			for _, k := range parseKeys(buf[:n]) {
+				msgs <- k
+			}
+		}
+	}()
+	ticker := time.NewTicker(*refresh)
+	defer ticker.Stop()
+
+	d := newDashboard(client)
+	d.width, d.height = terminalSize()
+	run(d.init())
+	for {
+		// Raw mode turns off newline translation, so lines end in \r\n
+		screen := "\x1b[H" + strings.Join(d.view(), "\x1b[K\r\n") + "\x1b[K\x1b[J"
+		os.Stdout.WriteString(screen)
+
+		var msg interface{}
+		select {
+		case msg = <-msgs:
+		case <-ticker.C:
+			d.width, d.height = terminalSize()
+			msg = dashboardTick{}
+		}
+		if _, ok := msg.(dashboardKey); ok && d.status != "" && d.confirm == nil && !strings.HasSuffix(d.status, "...") {
+			d.status = "" // a key press dismisses the last message
+		}
+		cmd, quit := d.update(msg)
+		if quit {
+			return nil
+		}
+		run(cmd)
+	}
+}
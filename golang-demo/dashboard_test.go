@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// newTestDashboard starts a mock stack holding one resource of every kind
+// and returns a dashboard on it with the first snapshot loaded
+func newTestDashboard(t *testing.T) *dashboard {
+	t.Helper()
+	client, _, _ := newMockAgent(t, lookupAgent())
+	ctx := context.Background()
+	if _, err := client.CreateVectorStore(ctx, "docs", nil); err != nil {
+		t.Fatalf("CreateVectorStore: %v", err)
+	}
+	if _, err := client.UploadFileFromReader(ctx, "notes.txt", strings.NewReader("Dora is a pug."), FilePurposeAssistants); err != nil {
+		t.Fatalf("UploadFileFromReader: %v", err)
+	}
+	d := newDashboard(client)
+	d.width, d.height = 100, 30
+	runDashboardCmd(t, d, d.init())
+	return d
+}
+
+// runDashboardCmd runs cmd and every command that follows from it
+// synchronously, as the UI loop would in the background
+func runDashboardCmd(t *testing.T, d *dashboard, cmd dashboardCmd) {
+	t.Helper()
+	for cmd != nil {
+		var quit bool
+		cmd, quit = d.update(cmd())
+		if quit {
+			t.Fatal("dashboard quit while handling a command result")
+		}
+	}
+}
+
+// press feeds keys to the dashboard, running the commands they start
+func press(t *testing.T, d *dashboard, keys ...string) (quit bool) {
+	t.Helper()
+	for _, k := range keys {
+		cmd, q := d.update(dashboardKey(k))
+		if q {
+			return true
+		}
+		runDashboardCmd(t, d, cmd)
+	}
+	return false
+}
+
+// ansiSequence matches the styles the dashboard writes
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// screenText renders the dashboard without styles
+func screenText(d *dashboard) string {
+	return ansiSequence.ReplaceAllString(strings.Join(d.view(), "\n"), "")
+}
+
+func TestDashboardLoadsEveryPanel(t *testing.T) {
+	d := newTestDashboard(t)
+	for p := range panelCount {
+		if err := d.snapshot.Errors[p]; err != nil {
+			t.Fatalf("%s panel failed: %v", dashboardPanelNames[p], err)
+		}
+		if len(d.snapshot.Items[p]) == 0 {
+			t.Fatalf("%s panel is empty", dashboardPanelNames[p])
+		}
+	}
+	session := d.snapshot.Items[panelSessions][0]
+	if session.Parent != d.snapshot.Items[panelAgents][0].ID {
+		t.Fatalf("session %s is not listed under its agent", session.ID)
+	}
+	screen := screenText(d)
+	for _, want := range []string{"1 Models", "2 Agents (1)", "3 Sessions (1)", "4 Vector stores (1)", "5 Files (1)", "notes.txt"} {
+		if !strings.Contains(screen, want) {
+			t.Fatalf("screen does not show %q:\n%s", want, screen)
+		}
+	}
+}
+
+func TestDashboardDelete(t *testing.T) {
+	d := newTestDashboard(t)
+	press(t, d, "4")
+	store := d.snapshot.Items[panelVectorStores][0]
+
+	press(t, d, "d")
+	if !strings.Contains(screenText(d), "Delete vector store "+store.ID+"? y/n") {
+		t.Fatalf("no confirmation shown:\n%s", screenText(d))
+	}
+	press(t, d, "n")
+	if d.status != "Delete cancelled" || len(d.snapshot.Items[panelVectorStores]) != 1 {
+		t.Fatalf("declined delete: status %q, %d stores", d.status, len(d.snapshot.Items[panelVectorStores]))
+	}
+
+	press(t, d, "d", "y")
+	if len(d.snapshot.Items[panelVectorStores]) != 0 {
+		t.Fatalf("vector store still listed after delete: %+v", d.snapshot.Items[panelVectorStores])
+	}
+	if want := "Deleted vector store " + store.ID; d.status != want {
+		t.Fatalf("status = %q, want %q", d.status, want)
+	}
+
+	// Sessions are deleted through their agent
+	press(t, d, "shift+tab", "d", "y")
+	if len(d.snapshot.Items[panelSessions]) != 0 || d.snapshot.Errors[panelSessions] != nil {
+		t.Fatalf("session not deleted: status %q", d.status)
+	}
+}
+
+func TestDashboardModelsCannotBeDeleted(t *testing.T) {
+	d := newTestDashboard(t)
+	press(t, d, "1", "d")
+	if d.confirm != nil || !strings.Contains(d.status, "cannot be deleted") {
+		t.Fatalf("delete of a model: confirm %v, status %q", d.confirm, d.status)
+	}
+}
+
+func TestDashboardInspect(t *testing.T) {
+	d := newTestDashboard(t)
+	press(t, d, "4", "enter")
+	if d.inspecting == nil {
+		t.Fatal("enter did not open the vector store")
+	}
+	// The inspect command adds the store's attached files
+	if text := strings.Join(d.inspect, "\n"); !strings.Contains(text, `"files"`) || !strings.Contains(text, `"vector_store"`) {
+		t.Fatalf("inspected store lacks its files:\n%s", text)
+	}
+	press(t, d, "esc")
+	if d.inspecting != nil {
+		t.Fatal("esc did not close the inspector")
+	}
+	if !press(t, d, "q") {
+		t.Fatal("q did not quit")
+	}
+}
+
+func TestDashboardNavigation(t *testing.T) {
+	d := newTestDashboard(t)
+	press(t, d, "right", "right")
+	if d.focus != panelSessions {
+		t.Fatalf("focus = %d after two rights, want sessions", d.focus)
+	}
+	press(t, d, "left", "left", "left")
+	if d.focus != panelFiles {
+		t.Fatalf("focus = %d after wrapping left, want files", d.focus)
+	}
+	press(t, d, "down", "down", "down")
+	if d.cursor[panelFiles] != 0 {
+		t.Fatalf("cursor moved past the only file: %d", d.cursor[panelFiles])
+	}
+}
+
+func TestDashboardViewFitsTerminal(t *testing.T) {
+	d := newTestDashboard(t)
+	for _, size := range [][2]int{{100, 30}, {40, 10}, {20, 4}} {
+		d.width, d.height = size[0], size[1]
+		lines := d.view()
+		if len(lines) != d.height {
+			t.Fatalf("%dx%d: view has %d lines", d.width, d.height, len(lines))
+		}
+		for _, line := range lines {
+			if n := utf8.RuneCountInString(ansiSequence.ReplaceAllString(line, "")); n > d.width {
+				t.Fatalf("%dx%d: line of %d columns: %q", d.width, d.height, n, line)
+			}
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []dashboardKey
+	}{
+		{"jk", []dashboardKey{"j", "k"}},
+		{"\x1b[A\x1b[B\x1bOC\x1b[D", []dashboardKey{"up", "down", "right", "left"}},
+		{"\x1b[5~\x1b[6~\x1b[Z", []dashboardKey{"pgup", "pgdown", "shift+tab"}},
+		{"\r\n\t\x7f\x03", []dashboardKey{"enter", "enter", "tab", "backspace", "ctrl+c"}},
+		{"\x1b", []dashboardKey{"esc"}},
+		{"\x1bq", []dashboardKey{"esc", "q"}},
+		{"é", []dashboardKey{"é"}},
+	}
+	for _, tt := range tests {
+		if got := parseKeys([]byte(tt.in)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseKeys(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateANSI(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"too long", 3, "too" + ansiReset},
+		{ansiStyle(ansiBold, "bold text"), 4, "\x1b[1mbold" + ansiReset},
+		{"ünïcode", 3, "ünï" + ansiReset},
+	}
+	for _, tt := range tests {
+		if got := truncateANSI(tt.in, tt.width); got != tt.want {
+			t.Errorf("truncateANSI(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Dataset is a dataset registered for evaluation or post-training
+type Dataset struct {
+	Identifier         string                 `json:"identifier"`
+	ProviderResourceID string                 `json:"provider_resource_id,omitempty"`
+	ProviderID         string                 `json:"provider_id,omitempty"`
+	Type               string                 `json:"type,omitempty"`
+	Purpose            SpecDatasetPurpose     `json:"purpose"`
+	Source             map[string]interface{} `json:"source,omitempty"`
+	Metadata           map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ListDatasetsResponse is the response of ListDatasets
+type ListDatasetsResponse = Page[Dataset]
+
+// RegisterDatasetParams registers a dataset. Source is a SpecRowsDataSource
+// for rows sent inline or a SpecURIDataSource for a file the server fetches;
+// see RowsSource and URISource.
+type RegisterDatasetParams struct {
+	DatasetID string                 `json:"dataset_id,omitempty"`
+	Purpose   SpecDatasetPurpose     `json:"purpose"`
+	Source    interface{}            `json:"source"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// DatasetRowsPage is one page of rows returned by GetDatasetRows
+type DatasetRowsPage = Page[map[string]interface{}]
+
+// RowsSource returns a dataset source holding rows inline
+func RowsSource(rows []map[string]interface{}) SpecRowsDataSource {
+	sourceType := "rows"
+	return SpecRowsDataSource{Type: &sourceType, Rows: rows}
+}
+
+// URISource returns a dataset source the server loads from uri, e.g. an
+// https URL or a data: URL
+func URISource(uri string) SpecURIDataSource {
+	sourceType := "uri"
+	return SpecURIDataSource{Type: &sourceType, URI: uri}
+}
+
+// datasetColumns lists the columns each purpose requires in every row
+var datasetColumns = map[SpecDatasetPurpose][]string{
+	SpecDatasetPurposeEvalQuestionAnswer:   {"input_query", "expected_answer"},
+	SpecDatasetPurposeEvalMessagesAnswer:   {"messages", "answer"},
+	SpecDatasetPurposePostTrainingMessages: {"messages"},
+}
+
+// ValidateDatasetRows checks that every row has the columns purpose
+// requires, so a malformed file fails before it is registered rather than
+// when an eval job reads it
+func ValidateDatasetRows(purpose SpecDatasetPurpose, rows []map[string]interface{}) error {
+	columns, ok := datasetColumns[purpose]
+	if !ok {
+		return fmt.Errorf("unknown dataset purpose %q", purpose)
+	}
+	var errs []error
+	for i, row := range rows {
+		for _, col := range columns {
+			if _, ok := row[col]; !ok {
+				errs = append(errs, fmt.Errorf("row %d: missing column %q", i+1, col))
+			}
+		}
+		if len(errs) >= 10 {
+			errs = append(errs, errors.New("too many errors"))
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterDataset registers a dataset
+func (c *LlamaStackClient) RegisterDataset(ctx context.Context, params RegisterDatasetParams) (*Dataset, error) {
+	var dataset Dataset
+	if err := c.postJSON(ctx, "/v1/datasets", params, &dataset); err != nil {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// ListDatasets lists the registered datasets
+func (c *LlamaStackClient) ListDatasets(ctx context.Context) (*ListDatasetsResponse, error) {
+	var response ListDatasetsResponse
+	if err := c.getJSON(ctx, "/v1/datasets", &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetDataset returns one dataset
+func (c *LlamaStackClient) GetDataset(ctx context.Context, datasetID string) (*Dataset, error) {
+	var dataset Dataset
+	if err := c.getJSON(ctx, "/v1/datasets/"+url.PathEscape(datasetID), &dataset); err != nil {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// DeleteDataset unregisters a dataset
+func (c *LlamaStackClient) DeleteDataset(ctx context.Context, datasetID string) error {
+	return c.sendDelete(ctx, "/v1/datasets/"+url.PathEscape(datasetID))
+}
+
+// GetDatasetRows returns up to limit rows of a dataset starting at
+// startIndex; a limit of zero or less lets the server choose
+func (c *LlamaStackClient) GetDatasetRows(ctx context.Context, datasetID string, startIndex, limit int) (*DatasetRowsPage, error) {
+	query := url.Values{"start_index": {strconv.Itoa(startIndex)}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var page DatasetRowsPage
+	endpoint := "/v1/datasetio/iterrows/" + url.PathEscape(datasetID) + "?" + query.Encode()
+	if err := c.getJSON(ctx, endpoint, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// ListDatasetRows returns every row of a dataset, following pages
+func (c *LlamaStackClient) ListDatasetRows(ctx context.Context, datasetID string) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	for start := 0; ; {
+		page, err := c.GetDatasetRows(ctx, datasetID, start, resourcePageSize)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, page.Data...)
+		next, ok := page.NextStartIndex(start)
+		if !ok {
+			return rows, nil
+		}
+		start = next
+	}
+}
+
+// AppendRows appends rows to a dataset
+func (c *LlamaStackClient) AppendRows(ctx context.Context, datasetID string, rows []map[string]interface{}) error {
+	var ignored interface{}
+	return c.postJSON(ctx, "/v1/datasetio/append-rows/"+url.PathEscape(datasetID), SpecAppendRowsRequest{Rows: rows}, &ignored)
+}
+
+// datasetUploadBatch is the number of rows sent per request by UploadDataset
+const datasetUploadBatch = 500
+
+// UploadDataset reads rows from a local JSONL, JSON or CSV file with
+// LoadDatasetRows, validates them for purpose and registers a dataset with
+// them. Large files are registered with the first rows and the rest are
+// appended in batches, keeping each request small.
+func (c *LlamaStackClient) UploadDataset(ctx context.Context, datasetID string, purpose SpecDatasetPurpose, path string) (*Dataset, error) {
+	rows, err := LoadDatasetRows(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateDatasetRows(purpose, rows); err != nil {
+		return nil, fmt.Errorf("invalid dataset %s: %w", path, err)
+	}
+
+	first := rows[:min(len(rows), datasetUploadBatch)]
+	dataset, err := c.RegisterDataset(ctx, RegisterDatasetParams{
+		DatasetID: datasetID,
+		Purpose:   purpose,
+		Source:    RowsSource(first),
+		Metadata:  map[string]interface{}{"source_file": filepath.Base(path)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register dataset: %w", err)
+	}
+	for start := len(first); start < len(rows); start += datasetUploadBatch {
+		end := min(start+datasetUploadBatch, len(rows))
+		if err := c.AppendRows(ctx, dataset.Identifier, rows[start:end]); err != nil {
+			return dataset, fmt.Errorf("failed to append rows %d-%d: %w", start+1, end, err)
+		}
+	}
+	return dataset, nil
+}
+
+// LoadDatasetRows reads dataset rows from a file by extension: JSONL with
+// one object per line, JSON holding an array of objects, or CSV with a
+// header row. CSV cells holding a JSON array or object, such as a messages
+// column, are decoded; other cells stay strings.
+func LoadDatasetRows(path string) ([]map[string]interface{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset file: %w", err)
+	}
+	defer file.Close()
+
+	var rows []map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl":
+		rows, err = readJSONLRows(file)
+	case ".json":
+		err = json.NewDecoder(file).Decode(&rows)
+	case ".csv":
+		rows, err = readCSVRows(file)
+	default:
+		return nil, fmt.Errorf("unsupported dataset file %s: use .jsonl, .json or .csv", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s has no rows", path)
+	}
+	return rows, nil
+}
+
+func readJSONLRows(r io.Reader) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var row map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &row); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+func readCSVRows(r io.Reader) ([]map[string]interface{}, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	rows := make([]map[string]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, col := range header {
+			if i >= len(record) {
+				break
+			}
+			row[col] = csvCellValue(record[i])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func csvCellValue(cell string) interface{} {
+	trimmed := strings.TrimSpace(cell)
+	if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		var v interface{}
+		if json.Unmarshal([]byte(trimmed), &v) == nil {
+			return v
+		}
+	}
+	return cell
+}
+
+// runDatasetCommand handles the "dataset list", "dataset upload" and "dataset rows" subcommands
+func runDatasetCommand(client *LlamaStackClient, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return runDatasetListCommand(client)
+		case "upload":
+			return runDatasetUploadCommand(client, args[1:])
+		case "rows":
+			return runDatasetRowsCommand(client, args[1:])
+		}
+	}
+	return errors.New("usage: dataset list|upload|rows ...")
+}
+
+func runDatasetListCommand(client *LlamaStackClient) error {
+	datasets, err := client.ListDatasets(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(datasets.Data) == 0 {
+		fmt.Println("No datasets registered")
+	}
+	for _, d := range datasets.Data {
+		fmt.Printf("%s  purpose=%s  provider=%s\n", d.Identifier, d.Purpose, d.ProviderID)
+	}
+	return nil
+}
+
+func runDatasetUploadCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("dataset upload", flag.ContinueOnError)
+	purpose := fs.String("purpose", string(SpecDatasetPurposeEvalQuestionAnswer), "eval/question-answer, eval/messages-answer or post-training/messages")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: dataset upload [-purpose eval/question-answer] FILE DATASET_ID")
+	}
+	dataset, err := client.UploadDataset(context.Background(), fs.Arg(1), SpecDatasetPurpose(*purpose), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Registered dataset %s\n", dataset.Identifier)
+	return nil
+}
+
+func runDatasetRowsCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("dataset rows", flag.ContinueOnError)
+	start := fs.Int("start", 0, "index of the first row")
+	limit := fs.Int("limit", 10, "number of rows")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: dataset rows [-start 0] [-limit 10] DATASET_ID")
+	}
+	page, err := client.GetDatasetRows(context.Background(), fs.Arg(0), *start, *limit)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, row := range page.Data {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	if page.HasMore {
+		fmt.Printf("... more rows from index %d\n", *start+len(page.Data))
+	}
+	return nil
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultSyncStateFile is created in the synced directory unless
+// SyncOptions.StateFile says otherwise
+const defaultSyncStateFile = ".llamastack-sync.json"
+
+// SyncOptions configures SyncDirectory and WatchDirectory
+type SyncOptions struct {
+	StateFile  string   // defaults to .llamastack-sync.json in the directory
+	Extensions []string // only sync these extensions, e.g. ".md"; all files when empty
+
+	// Attributes are added to every attached file, next to the path,
+	// document_id and content hash (sha256, or etag for objects) set by the sync
+	Attributes       map[string]interface{}
+	ChunkingStrategy map[string]interface{}
+
+	// DryRun reports what would change without uploading, detaching or
+	// writing the state file
+	DryRun bool
+
+	// Progress, if set, is called for every added, updated or removed file
+	Progress func(SyncEvent)
+}
+
+// SyncEvent reports one change made by a sync
+type SyncEvent struct {
+	Action string // "added", "updated" or "removed"
+	Path   string // relative to the synced directory, with forward slashes
+	FileID string
+	Err    error
+}
+
+// SyncResult lists the relative paths each sync action applied to
+type SyncResult struct {
+	Added     []string
+	Updated   []string
+	Removed   []string
+	Unchanged int
+}
+
+// Changed reports whether the sync touched the vector store
+func (r *SyncResult) Changed() bool {
+	return len(r.Added)+len(r.Updated)+len(r.Removed) > 0
+}
+
+// syncState is the state file: what was uploaded for each path
+type syncState struct {
+	VectorStoreID string                `json:"vector_store_id"`
+	Files         map[string]syncedFile `json:"files"`
+}
+
+type syncedFile struct {
+	FileID  string    `json:"file_id"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"` // SHA-256 of a local file, ETag of an object
+}
+
+// SyncDirectory makes a vector store mirror a local directory. Files whose
+// size and modification time are unchanged since the last sync are skipped
+// without reading them, and touched files whose content hash is unchanged are
+// not uploaded again. New and changed files are uploaded and attached; a
+// changed file's previous upload is detached and deleted only after the new
+// one is attached, so the store never lacks the document. Files deleted
+// locally are detached and deleted.
+//
+// The state is kept in a local JSON file and saved after every change, so an
+// interrupted sync resumes where it stopped. A file that fails does not stop
+// the sync; the failures are joined in the returned error.
+func (c *LlamaStackClient) SyncDirectory(ctx context.Context, dir, vectorStoreID string, opts SyncOptions) (*SyncResult, error) {
+	ctx = ensureCorrelationID(ctx)
+	if opts.StateFile == "" {
+		opts.StateFile = filepath.Join(dir, defaultSyncStateFile)
+	}
+	local, err := scanSyncDirectory(dir, opts.StateFile, opts.Extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]syncItem, len(local))
+	for rel, info := range local {
+		name := filepath.Join(dir, filepath.FromSlash(rel))
+		items[rel] = syncItem{
+			Size:     info.Size(),
+			ModTime:  info.ModTime(),
+			HashAttr: "sha256",
+			Hash:     func() (string, error) { return hashFile(name) },
+			Open: func() (io.ReadCloser, error) {
+				file, err := os.Open(name)
+				if err != nil {
+					return nil, fmt.Errorf("failed to open file: %w", err)
+				}
+				return file, nil
+			},
+		}
+	}
+	return c.syncItems(ctx, items, vectorStoreID, opts)
+}
+
+// syncItem is one document of a sync source, a local file or a bucket object
+type syncItem struct {
+	Size     int64
+	ModTime  time.Time
+	HashAttr string                        // attribute the hash is attached as
+	Hash     func() (string, error)        // content fingerprint, only asked for when size or mtime changed
+	Open     func() (io.ReadCloser, error) // content to upload
+}
+
+// syncItems brings the vector store in line with items, keyed by relative
+// path, using the state in opts.StateFile; without a state file every item
+// is uploaded
+func (c *LlamaStackClient) syncItems(ctx context.Context, items map[string]syncItem, vectorStoreID string, opts SyncOptions) (*SyncResult, error) {
+	statePath := opts.StateFile
+	state := &syncState{Files: make(map[string]syncedFile)}
+	if statePath != "" {
+		var err error
+		if state, err = loadSyncState(statePath); err != nil {
+			return nil, err
+		}
+	}
+	if state.VectorStoreID != "" && state.VectorStoreID != vectorStoreID {
+		return nil, fmt.Errorf("state file %s belongs to vector store %s, not %s", statePath, state.VectorStoreID, vectorStoreID)
+	}
+	state.VectorStoreID = vectorStoreID
+
+	result := &SyncResult{}
+	var errs []error
+	report := func(event SyncEvent) {
+		if event.Err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", event.Action, event.Path, event.Err))
+		}
+		if opts.Progress != nil {
+			opts.Progress(event)
+		}
+	}
+	save := func() {
+		if opts.DryRun || statePath == "" {
+			return
+		}
+		if err := saveSyncState(statePath, state); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	paths := make([]string, 0, len(items))
+	for rel := range items {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	for _, rel := range paths {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		item := items[rel]
+		previous, known := state.Files[rel]
+		if known && previous.Size == item.Size && previous.ModTime.Equal(item.ModTime) {
+			result.Unchanged++
+			continue
+		}
+
+		hash, err := item.Hash()
+		if err != nil {
+			report(SyncEvent{Action: "added", Path: rel, Err: err})
+			continue
+		}
+		if known && previous.Hash == hash {
+			// Touched but not changed: remember the new mtime so it is not hashed again
+			previous.Size, previous.ModTime = item.Size, item.ModTime
+			state.Files[rel] = previous
+			save()
+			result.Unchanged++
+			continue
+		}
+
+		action := "added"
+		if known {
+			action = "updated"
+		}
+		if opts.DryRun {
+			result.record(action, rel)
+			report(SyncEvent{Action: action, Path: rel, FileID: previous.FileID})
+			continue
+		}
+
+		fileID, err := c.syncUpload(ctx, rel, item, hash, vectorStoreID, opts)
+		if err != nil {
+			report(SyncEvent{Action: action, Path: rel, Err: err})
+			continue
+		}
+		if known {
+			if err := c.removeSyncedFile(ctx, vectorStoreID, previous.FileID); err != nil {
+				errs = append(errs, fmt.Errorf("updated %s but failed to remove its previous upload %s: %w", rel, previous.FileID, err))
+			}
+		}
+		state.Files[rel] = syncedFile{FileID: fileID, Size: item.Size, ModTime: item.ModTime, Hash: hash}
+		save()
+		result.record(action, rel)
+		report(SyncEvent{Action: action, Path: rel, FileID: fileID})
+	}
+
+	var removed []string
+	for rel := range state.Files {
+		if _, ok := items[rel]; !ok {
+			removed = append(removed, rel)
+		}
+	}
+	sort.Strings(removed)
+	for _, rel := range removed {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		fileID := state.Files[rel].FileID
+		if !opts.DryRun {
+			if err := c.removeSyncedFile(ctx, vectorStoreID, fileID); err != nil {
+				report(SyncEvent{Action: "removed", Path: rel, FileID: fileID, Err: err})
+				continue
+			}
+			delete(state.Files, rel)
+			save()
+		}
+		result.record("removed", rel)
+		report(SyncEvent{Action: "removed", Path: rel, FileID: fileID})
+	}
+
+	return result, errors.Join(errs...)
+}
+
+func (r *SyncResult) record(action, rel string) {
+	switch action {
+	case "added":
+		r.Added = append(r.Added, rel)
+	case "updated":
+		r.Updated = append(r.Updated, rel)
+	case "removed":
+		r.Removed = append(r.Removed, rel)
+	}
+}
+
+// WatchDirectory syncs the directory, then polls it every interval and syncs
+// again until ctx is done, for a live folder-to-RAG pipeline. Polling keeps
+// the demo free of platform file notification APIs; with the state file an
+// idle poll only stats the files. Errors of a single pass are passed to
+// onError, if set, and the watch goes on.
+func (c *LlamaStackClient) WatchDirectory(ctx context.Context, dir, vectorStoreID string, interval time.Duration, opts SyncOptions, onError func(error)) error {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := c.SyncDirectory(ctx, dir, vectorStoreID, opts); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if onError != nil {
+				onError(err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncUpload uploads and attaches one item, returning its file ID
+func (c *LlamaStackClient) syncUpload(ctx context.Context, rel string, item syncItem, hash, vectorStoreID string, opts SyncOptions) (string, error) {
+	content, err := item.Open()
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	uploaded, err := c.UploadFileFromReader(ctx, path.Base(rel), content, FilePurposeAssistants)
+	if err != nil {
+		return "", err
+	}
+
+	attrs := make(map[string]interface{}, len(opts.Attributes)+3)
+	for k, v := range opts.Attributes {
+		attrs[k] = v
+	}
+	attrs["path"] = rel
+	attrs["document_id"] = rel
+	attrs[item.HashAttr] = hash
+	if _, err := c.attachFile(ctx, vectorStoreID, uploaded.ID, checksumAttributes(attrs, uploaded), opts.ChunkingStrategy); err != nil {
+		c.DeleteFile(ctx, uploaded.ID) // best effort, so failed attempts do not pile up
+		return "", err
+	}
+	return uploaded.ID, nil
+}
+
+// removeSyncedFile detaches and deletes a previously synced upload. A file
+// that is already gone counts as removed.
+func (c *LlamaStackClient) removeSyncedFile(ctx context.Context, vectorStoreID, fileID string) error {
+	var apiErr *APIError
+	if err := c.DetachFileFromVectorStore(ctx, vectorStoreID, fileID); err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == 404) {
+		return err
+	}
+	if err := c.DeleteFile(ctx, fileID); err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == 404) {
+		return err
+	}
+	return nil
+}
+
+// scanSyncDirectory returns the regular files under dir by slash-separated
+// relative path, skipping hidden files and directories and the state file
+func scanSyncDirectory(dir, statePath string, extensions []string) (map[string]os.FileInfo, error) {
+	stateAbs, _ := filepath.Abs(statePath)
+	files := make(map[string]os.FileInfo)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if abs, _ := filepath.Abs(p); abs == stateAbs {
+			return nil
+		}
+		if len(extensions) > 0 && !hasExtension(p, extensions) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = info
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+func hasExtension(p string, extensions []string) bool {
+	ext := filepath.Ext(p)
+	for _, e := range extensions {
+		if strings.EqualFold(ext, e) || strings.EqualFold(ext, "."+e) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashFile(p string) (string, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func loadSyncState(p string) (*syncState, error) {
+	state := &syncState{Files: make(map[string]syncedFile)}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse sync state %s: %w", p, err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]syncedFile)
+	}
+	return state, nil
+}
+
+// saveSyncState writes the state through a temporary file so an interrupted
+// write cannot leave a truncated state behind
+func saveSyncState(p string, state *syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync state: %w", err)
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
+
+// runSyncCommand handles "vector-store sync [-watch] DIR STORE_ID"; DIR may be
+// an s3://bucket/prefix URL, using the AWS_* environment for credentials
+func runSyncCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("vector-store sync", flag.ContinueOnError)
+	watch := fs.Bool("watch", false, "keep polling the directory and sync every change")
+	interval := fs.Duration("interval", 5*time.Second, "polling interval with -watch")
+	stateFile := fs.String("state", "", "sync state file (default DIR/"+defaultSyncStateFile+")")
+	ext := fs.String("ext", "", "comma-separated extensions to sync, e.g. .md,.txt")
+	dryRun := fs.Bool("dry-run", false, "show what would change without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: vector-store sync [-watch] [-interval 5s] [-state FILE] [-ext .md,.txt] [-dry-run] DIR|s3://BUCKET/PREFIX STORE_ID")
+	}
+
+	bars := NewUploadProgressBars(os.Stderr, isTerminal(os.Stderr))
+	ctx := WithUploadProgress(context.Background(), bars.Update)
+	opts := SyncOptions{
+		StateFile: *stateFile,
+		DryRun:    *dryRun,
+		Progress: func(e SyncEvent) {
+			bars.FileDone(e.Err)
+			if e.Err != nil {
+				fmt.Printf("%-8s %s: %v\n", e.Action, e.Path, e.Err)
+				return
+			}
+			fmt.Printf("%-8s %s %s\n", e.Action, e.Path, e.FileID)
+		},
+	}
+	if *ext != "" {
+		opts.Extensions = strings.Split(*ext, ",")
+	}
+	dir, storeID := fs.Arg(0), fs.Arg(1)
+
+	if rest, ok := strings.CutPrefix(dir, "s3://"); ok {
+		if *watch {
+			return errors.New("-watch is only supported for local directories")
+		}
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if opts.StateFile == "" {
+			opts.StateFile = ".llamastack-sync-" + bucket + ".json"
+		}
+		result, err := client.IngestS3(ctx, NewS3SourceFromEnv(bucket, prefix), storeID, opts)
+		bars.Summary()
+		printSyncResult(dir, result)
+		return err
+	}
+
+	if *watch {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		fmt.Printf("Watching %s for changes every %s (Ctrl-C to stop)\n", dir, *interval)
+		err := client.WatchDirectory(ctx, dir, storeID, *interval, opts, func(err error) {
+			fmt.Printf("Sync error: %v\n", err)
+		})
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+
+	result, err := client.SyncDirectory(ctx, dir, storeID, opts)
+	bars.Summary()
+	printSyncResult(dir, result)
+	return err
+}
+
+func printSyncResult(source string, result *SyncResult) {
+	if result != nil {
+		fmt.Printf("Synced %s: %d added, %d updated, %d removed, %d unchanged\n",
+			source, len(result.Added), len(result.Updated), len(result.Removed), result.Unchanged)
+	}
+}
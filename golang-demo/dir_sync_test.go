@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newSyncTestStore starts a mock stack and creates an empty vector store
+func newSyncTestStore(t *testing.T) (*LlamaStackClient, string) {
+	t.Helper()
+	mock := NewMockStack()
+	t.Cleanup(mock.Close)
+	client := NewLlamaStackClient(mock.URL(), "test")
+	store, err := client.CreateVectorStore(context.Background(), "sync-test", nil)
+	if err != nil {
+		t.Fatalf("CreateVectorStore: %v", err)
+	}
+	return client, store.ID
+}
+
+// writeSyncFile writes content to the slash-separated path rel under dir
+func writeSyncFile(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	name := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storePaths returns the sorted path attributes of the files attached to a store
+func storePaths(t *testing.T, client *LlamaStackClient, storeID string) []string {
+	t.Helper()
+	files, err := client.ListVectorStoreFiles(context.Background(), storeID)
+	if err != nil {
+		t.Fatalf("ListVectorStoreFiles: %v", err)
+	}
+	var paths []string
+	for _, f := range files {
+		p, _ := f.Attributes["path"].(string)
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestSyncDirectory(t *testing.T) {
+	client, storeID := newSyncTestStore(t)
+	dir := t.TempDir()
+	ctx := context.Background()
+	writeSyncFile(t, dir, "a.md", "Dora is a pug.")
+	writeSyncFile(t, dir, "b.md", "Bella is a Cavalier King.")
+	writeSyncFile(t, dir, "touched.md", "Unchanged content.")
+	writeSyncFile(t, dir, ".hidden/skip.md", "never synced")
+
+	result, err := client.SyncDirectory(ctx, dir, storeID, SyncOptions{})
+	if err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	if want := []string{"a.md", "b.md", "touched.md"}; !reflect.DeepEqual(result.Added, want) {
+		t.Fatalf("first sync added %v, want %v", result.Added, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultSyncStateFile)); err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+
+	// Change, delete, add and touch one file each
+	writeSyncFile(t, dir, "a.md", "Dora is a pug owned by Ana.")
+	if err := os.Remove(filepath.Join(dir, "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeSyncFile(t, dir, "sub/c.md", "Ana lives in Campinas.")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "touched.md"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = client.SyncDirectory(ctx, dir, storeID, SyncOptions{})
+	if err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	want := &SyncResult{Added: []string{"sub/c.md"}, Updated: []string{"a.md"}, Removed: []string{"b.md"}, Unchanged: 1}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("second sync = %+v, want %+v", result, want)
+	}
+	if got, want := storePaths(t, client, storeID), []string{"a.md", "sub/c.md", "touched.md"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("store holds %v, want %v", got, want)
+	}
+
+	result, err = client.SyncDirectory(ctx, dir, storeID, SyncOptions{})
+	if err != nil {
+		t.Fatalf("idle sync: %v", err)
+	}
+	if result.Changed() || result.Unchanged != 3 {
+		t.Fatalf("idle sync = %+v, want 3 unchanged files", result)
+	}
+}
+
+func TestSyncDirectoryStateBelongsToOtherStore(t *testing.T) {
+	client, storeID := newSyncTestStore(t)
+	dir := t.TempDir()
+	writeSyncFile(t, dir, "a.md", "Dora is a pug.")
+	if _, err := client.SyncDirectory(context.Background(), dir, storeID, SyncOptions{}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if _, err := client.SyncDirectory(context.Background(), dir, "vs_other", SyncOptions{}); err == nil {
+		t.Fatal("sync into another store reused the state file")
+	}
+}
+
+func TestWatchDirectory(t *testing.T) {
+	client, storeID := newSyncTestStore(t)
+	dir := t.TempDir()
+	writeSyncFile(t, dir, "a.md", "Dora is a pug.")
+
+	events := make(chan SyncEvent, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		opts := SyncOptions{Progress: func(e SyncEvent) { events <- e }}
+		done <- client.WatchDirectory(ctx, dir, storeID, 10*time.Millisecond, opts, func(err error) {
+			t.Errorf("watch pass failed: %v", err)
+		})
+	}()
+
+	expect := func(action, path string) {
+		t.Helper()
+		select {
+		case e := <-events:
+			if e.Action != action || e.Path != path || e.Err != nil {
+				t.Fatalf("event %+v, want %s %s", e, action, path)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no event for %s %s", action, path)
+		}
+	}
+	expect("added", "a.md")
+
+	writeSyncFile(t, dir, "b.md", "Bella is a Cavalier King.")
+	expect("added", "b.md")
+	writeSyncFile(t, dir, "b.md", "Bella is a Cavalier King owned by Eder.")
+	expect("updated", "b.md")
+	if err := os.Remove(filepath.Join(dir, "a.md")); err != nil {
+		t.Fatal(err)
+	}
+	expect("removed", "a.md")
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("WatchDirectory returned %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchDirectory did not stop after cancel")
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %+v", e)
+	default:
+	}
+}
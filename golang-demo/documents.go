@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TurnDocument represents a document passed to, or attached by, an agent turn.
+// Content holds one of the typed variants: a string for inline text, SpecURL
+// for a remote or data: URL, SpecTextContentItem or SpecImageContentItem, or a
+// []interface{} of content items.
+type TurnDocument struct {
+	Content  interface{} `json:"content"`
+	MimeType string      `json:"mime_type"`
+}
+
+// TextDocument creates a turn document with inline text content
+func TextDocument(text, mimeType string) TurnDocument {
+	if mimeType == "" {
+		mimeType = "text/plain"
+	}
+	return TurnDocument{Content: text, MimeType: mimeType}
+}
+
+// URLDocument creates a turn document the server fetches from uri
+func URLDocument(uri, mimeType string) TurnDocument {
+	return TurnDocument{Content: SpecURL{URI: uri}, MimeType: mimeType}
+}
+
+// DataDocument creates a turn document that carries data inline as a base64 data: URL
+func DataDocument(data []byte, mimeType string) TurnDocument {
+	uri := "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	return TurnDocument{Content: SpecURL{URI: uri}, MimeType: mimeType}
+}
+
+// FileDocument creates a turn document referencing a file uploaded with
+// UploadFile; the server reads it back from the files API
+func (c *LlamaStackClient) FileDocument(fileID, mimeType string) TurnDocument {
+	return URLDocument(c.BaseURL+"/v1/openai/v1/files/"+fileID+"/content", mimeType)
+}
+
+// UnmarshalJSON decodes the content union into its typed variant
+func (d *TurnDocument) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Content  json.RawMessage `json:"content"`
+		MimeType string          `json:"mime_type"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	content, err := decodeDocumentContent(raw.Content)
+	if err != nil {
+		return err
+	}
+	d.Content = content
+	d.MimeType = raw.MimeType
+	return nil
+}
+
+// Text returns the text of inline and text-item content, and false for URLs and images
+func (d TurnDocument) Text() (string, bool) {
+	switch v := d.Content.(type) {
+	case string:
+		return v, true
+	case SpecTextContentItem:
+		return v.Text, true
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			text, ok := item.(SpecTextContentItem)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, text.Text)
+		}
+		return strings.Join(parts, ""), true
+	}
+	return "", false
+}
+
+// URI returns the URL of URL content, or "" for any other variant
+func (d TurnDocument) URI() string {
+	if u, ok := d.Content.(SpecURL); ok {
+		return u.URI
+	}
+	return ""
+}
+
+// decodeDocumentContent decodes one content value into a string, SpecURL,
+// content item, or a list of content items
+func decodeDocumentContent(raw json.RawMessage) (interface{}, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	switch {
+	case trimmed == "" || trimmed == "null":
+		return nil, nil
+	case strings.HasPrefix(trimmed, `"`):
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	case strings.HasPrefix(trimmed, "["):
+		var rawItems []json.RawMessage
+		if err := json.Unmarshal(raw, &rawItems); err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, 0, len(rawItems))
+		for _, rawItem := range rawItems {
+			item, err := decodeContentItem(rawItem)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	var probe struct {
+		Type string `json:"type"`
+		URI  string `json:"uri"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, err
+	}
+	if probe.Type == "" && probe.URI != "" {
+		return SpecURL{URI: probe.URI}, nil
+	}
+	return decodeContentItem(raw)
+}
+
+// decodeContentItem decodes a text or image content item by its type discriminator
+func decodeContentItem(raw json.RawMessage) (interface{}, error) {
+	var probe struct {
+		Type  string          `json:"type"`
+		Text  *string         `json:"text"`
+		Image json.RawMessage `json:"image"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, err
+	}
+
+	// The discriminator defaults server-side, so infer it when omitted
+	if probe.Type == "" {
+		switch {
+		case probe.Text != nil:
+			probe.Type = "text"
+		case probe.Image != nil:
+			probe.Type = "image"
+		}
+	}
+
+	switch probe.Type {
+	case "text":
+		var item SpecTextContentItem
+		err := json.Unmarshal(raw, &item)
+		return item, err
+	case "image":
+		var item SpecImageContentItem
+		err := json.Unmarshal(raw, &item)
+		return item, err
+	}
+	return nil, fmt.Errorf("unknown document content type %q", probe.Type)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// dryRunKey marks a context whose requests are rendered instead of sent
+type dryRunKey struct{}
+
+// WithDryRun returns a context whose requests are printed and answered with
+// a synthetic response instead of being sent, whatever the client's DryRun
+// setting
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// dryRunning reports whether requests made with ctx are dry runs
+func (c *LlamaStackClient) dryRunning(ctx context.Context) bool {
+	on, _ := ctx.Value(dryRunKey{}).(bool)
+	return on || c.DryRun
+}
+
+// dryRunBodyLimit caps how much of a non-JSON body a dry run prints
+const dryRunBodyLimit = 2048
+
+// dryRun writes the fully built request to DryRunOutput and answers with a
+// 200 response: an empty JSON object, or an empty event stream for streaming
+// requests. Callers decode it like a real response, so a dry run exercises
+// the same code path up to the wire.
+func (c *LlamaStackClient) dryRun(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	w := c.DryRunOutput
+	if w == nil {
+		w = os.Stdout
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== DRY RUN: %s %s ===\n", req.Method, req.URL)
+	headers := sanitizeHeaders(req.Header)
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, strings.Join(headers[name], ", "))
+	}
+	if len(body) > 0 {
+		b.WriteString("\n")
+		b.WriteString(renderDryRunBody(req.Header.Get("Content-Type"), body))
+		b.WriteString("\n")
+	}
+	b.WriteString("=== END DRY RUN ===\n\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return nil, fmt.Errorf("failed to write dry run: %w", err)
+	}
+
+	respBody, contentType := "{}", "application/json"
+	var params struct {
+		Stream bool `json:"stream"`
+	}
+	if json.Unmarshal(body, &params) == nil && params.Stream {
+		respBody, contentType = "data: [DONE]\n\n", "text/event-stream"
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}, "X-Dry-Run": {"true"}},
+		Body:          io.NopCloser(strings.NewReader(respBody)),
+		ContentLength: int64(len(respBody)),
+		Request:       req,
+	}, nil
+}
+
+// renderDryRunBody indents JSON bodies, lists the parts of multipart uploads
+// and shortens anything else that is binary or long
+func renderDryRunBody(contentType string, body []byte) string {
+	var indented bytes.Buffer
+	if json.Indent(&indented, body, "", "  ") == nil {
+		return indented.String()
+	}
+	if mediaType, params, err := mime.ParseMediaType(contentType); err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		if parts, err := renderMultipart(body, params["boundary"]); err == nil {
+			return parts
+		}
+	}
+	if !utf8.Valid(body) {
+		return fmt.Sprintf("[%d bytes of %s]", len(body), contentType)
+	}
+	if len(body) > dryRunBodyLimit {
+		return fmt.Sprintf("%s\n... [%d more bytes]", body[:dryRunBodyLimit], len(body)-dryRunBodyLimit)
+	}
+	return string(body)
+}
+
+func renderMultipart(body []byte, boundary string) (string, error) {
+	var b strings.Builder
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return strings.TrimSuffix(b.String(), "\n"), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return "", err
+		}
+		if part.FileName() != "" {
+			fmt.Fprintf(&b, "%s: file %q, %d bytes\n", part.FormName(), part.FileName(), len(data))
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\n", part.FormName(), data)
+	}
+}
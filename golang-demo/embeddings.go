@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// EmbeddingsRequest is the body of an OpenAI-compatible embeddings request
+type EmbeddingsRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+// EmbeddingsResponse is the OpenAI-compatible embeddings response
+type EmbeddingsResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  struct {
+		PromptTokens int `json:"prompt_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// EmbeddingData is one embedding of an EmbeddingsResponse
+type EmbeddingData struct {
+	Object    string    `json:"object"`
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+// CreateEmbeddings embeds inputs with an embedding model, returning one vector
+// per input in order. When the client has an EmbeddingCache, cached inputs
+// are not sent and new embeddings are stored.
+func (c *LlamaStackClient) CreateEmbeddings(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	vectors := make([][]float64, len(inputs))
+	var missing []int
+	for i, input := range inputs {
+		if c.EmbeddingCache != nil {
+			if vec, ok := c.EmbeddingCache.Get(model, input); ok {
+				vectors[i] = vec
+				continue
+			}
+		}
+		missing = append(missing, i)
+	}
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	request := EmbeddingsRequest{Model: model, Input: make([]string, len(missing))}
+	for j, i := range missing {
+		request.Input[j] = inputs[i]
+	}
+	var resp EmbeddingsResponse
+	if err := c.postJSON(ctx, "/v1/openai/v1/embeddings", request, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(missing) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(missing), len(resp.Data))
+	}
+
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(missing) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		i := missing[d.Index]
+		vectors[i] = d.Embedding
+		if c.EmbeddingCache != nil {
+			// A cache that cannot be written only costs a recomputation later
+			c.EmbeddingCache.Put(model, inputs[i], d.Embedding)
+		}
+	}
+	return vectors, nil
+}
+
+// CosineSimilarity returns the cosine of the angle between two vectors, or 0
+// when they differ in length or either is zero
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// embeddingCacheMagic starts every cache entry, so a format change is detected
+var embeddingCacheMagic = []byte("LSEMB1")
+
+// EmbeddingCache stores embeddings on disk keyed by model and content hash,
+// so repeated ingestion runs and similarity experiments only embed new or
+// changed text. Each entry is a small file named after the SHA-256 of the
+// model and text, so several processes can share a cache directory without
+// locking it, as a bbolt or SQLite file would need. Vectors are stored as
+// float64, exactly as the server returned them.
+//
+// An entry is written to a temporary file, synced and renamed into place, so
+// readers see a whole entry or none. A crash can at worst lose the entries
+// being written; a damaged entry reads as a miss and is replaced by the next
+// Put.
+type EmbeddingCache struct {
+	dir string
+
+	mu     sync.Mutex
+	hits   int
+	misses int
+}
+
+// OpenEmbeddingCache opens or creates a cache in dir
+func OpenEmbeddingCache(dir string) (*EmbeddingCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create embedding cache: %w", err)
+	}
+	return &EmbeddingCache{dir: dir}, nil
+}
+
+// embeddingCacheKey hashes the model with the text, so switching models never
+// returns vectors of the old one
+func embeddingCacheKey(model, text string) string {
+	h := sha256.New()
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *EmbeddingCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".emb")
+}
+
+// Get returns the cached embedding of text under model
+func (c *EmbeddingCache) Get(model, text string) ([]float64, bool) {
+	vec, err := c.read(c.path(embeddingCacheKey(model, text)))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	return vec, true
+}
+
+func (c *EmbeddingCache) read(p string) ([]float64, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, embeddingCacheMagic) || len(data) < len(embeddingCacheMagic)+4 {
+		return nil, errors.New("not an embedding cache entry")
+	}
+	data = data[len(embeddingCacheMagic):]
+	n := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+	if len(data) != 8*n {
+		return nil, errors.New("truncated embedding cache entry")
+	}
+	vec := make([]float64, n)
+	for i := range vec {
+		vec[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	return vec, nil
+}
+
+// Put stores the embedding of text under model
+func (c *EmbeddingCache) Put(model, text string, vec []float64) error {
+	p := c.path(embeddingCacheKey(model, text))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+
+	data := make([]byte, 0, len(embeddingCacheMagic)+4+8*len(vec))
+	data = append(data, embeddingCacheMagic...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(vec)))
+	for _, v := range vec {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+	// Without the sync a crash after the rename can leave an empty entry
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+	syncDir(filepath.Dir(p))
+	return nil
+}
+
+// syncDir makes a rename in dir durable. It is best effort: some platforms
+// cannot sync a directory, and a lost rename only costs a cache miss.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// Stats returns the hits and misses since the cache was opened
+func (c *EmbeddingCache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Clear removes every cached embedding
+func (c *EmbeddingCache) Clear() error {
+	entries, err := os.ReadDir(c.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to clear embedding cache: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() && len(e.Name()) == 2 {
+			if err := os.RemoveAll(filepath.Join(c.dir, e.Name())); err != nil {
+				return fmt.Errorf("failed to clear embedding cache: %w", err)
+			}
+		}
+	}
+	return nil
+}
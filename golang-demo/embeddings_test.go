@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newEmbeddingClient returns a mock stack client with a cache in dir and a
+// counter of the embedding requests it sends
+func newEmbeddingClient(t *testing.T, dir string) (*LlamaStackClient, *atomic.Int32) {
+	t.Helper()
+	mock := NewMockStack()
+	t.Cleanup(mock.Close)
+	client := NewLlamaStackClient(mock.URL(), "test")
+	cache, err := OpenEmbeddingCache(dir)
+	if err != nil {
+		t.Fatalf("OpenEmbeddingCache: %v", err)
+	}
+	client.EmbeddingCache = cache
+
+	var requests atomic.Int32
+	client.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests.Add(1)
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	return client, &requests
+}
+
+func TestCreateEmbeddingsUsesCache(t *testing.T) {
+	client, requests := newEmbeddingClient(t, t.TempDir())
+	ctx := context.Background()
+
+	first, err := client.CreateEmbeddings(ctx, "m", []string{"Dora is a pug", "Bella is a spaniel"})
+	if err != nil {
+		t.Fatalf("CreateEmbeddings: %v", err)
+	}
+	second, err := client.CreateEmbeddings(ctx, "m", []string{"Bella is a spaniel", "Ana owns Dora", "Dora is a pug"})
+	if err != nil {
+		t.Fatalf("CreateEmbeddings: %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("%d embedding requests, want 2", n)
+	}
+	if !reflect.DeepEqual(second[0], first[1]) || !reflect.DeepEqual(second[2], first[0]) {
+		t.Fatal("cached vectors differ from the ones first returned")
+	}
+	if hits, misses := client.EmbeddingCache.Stats(); hits != 2 || misses != 3 {
+		t.Fatalf("stats = %d hits, %d misses, want 2 and 3", hits, misses)
+	}
+
+	// Another model must not see these vectors
+	if _, err := client.CreateEmbeddings(ctx, "other", []string{"Dora is a pug"}); err != nil {
+		t.Fatalf("CreateEmbeddings: %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("%d embedding requests after switching models, want 3", n)
+	}
+}
+
+func TestEmbeddingCacheRecovery(t *testing.T) {
+	dir := t.TempDir()
+	client, requests := newEmbeddingClient(t, dir)
+	ctx := context.Background()
+	texts := []string{"truncated", "wrong magic", "empty", "interrupted"}
+	want, err := client.CreateEmbeddings(ctx, "m", texts)
+	if err != nil {
+		t.Fatalf("CreateEmbeddings: %v", err)
+	}
+
+	// Damage the entries the way a crash or a foreign file could
+	entry := func(text string) string { return client.EmbeddingCache.path(embeddingCacheKey("m", text)) }
+	data, err := os.ReadFile(entry("truncated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	damage := map[string][]byte{
+		"truncated":   data[:len(data)-3],
+		"wrong magic": append([]byte("XXXXXX"), data[6:]...),
+		"empty":       nil,
+	}
+	for text, content := range damage {
+		if err := os.WriteFile(entry(text), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A write that died before its rename leaves only a temporary file
+	interrupted := entry("interrupted")
+	if err := os.WriteFile(filepath.Join(filepath.Dir(interrupted), ".tmp-123"), data[:10], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(interrupted); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reopened cache treats every damaged entry as a miss and rewrites it
+	reopened, err := OpenEmbeddingCache(dir)
+	if err != nil {
+		t.Fatalf("OpenEmbeddingCache: %v", err)
+	}
+	client.EmbeddingCache = reopened
+	for _, text := range texts {
+		if _, ok := reopened.Get("m", text); ok {
+			t.Fatalf("damaged entry %q read as a hit", text)
+		}
+	}
+	got, err := client.CreateEmbeddings(ctx, "m", texts)
+	if err != nil {
+		t.Fatalf("CreateEmbeddings over damaged entries: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatal("recomputed vectors differ")
+	}
+	for i, text := range texts {
+		vec, ok := reopened.Get("m", text)
+		if !ok || !reflect.DeepEqual(vec, want[i]) {
+			t.Fatalf("entry %q was not repaired", text)
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("%d embedding requests, want 2", n)
+	}
+
+	if err := reopened.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if _, ok := reopened.Get("m", "empty"); ok {
+		t.Fatal("entry survived Clear")
+	}
+}
+
+func TestEmbeddingCacheConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	// Two caches on one directory stand in for two processes
+	caches := make([]*EmbeddingCache, 2)
+	for i := range caches {
+		cache, err := OpenEmbeddingCache(dir)
+		if err != nil {
+			t.Fatalf("OpenEmbeddingCache: %v", err)
+		}
+		caches[i] = cache
+	}
+	vector := func(key int) []float64 {
+		vec := make([]float64, 256)
+		for i := range vec {
+			vec[i] = float64(key*1000 + i)
+		}
+		return vec
+	}
+
+	const writers, keys = 8, 20
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			cache := caches[w%len(caches)]
+			for round := 0; round < 5; round++ {
+				for k := 0; k < keys; k++ {
+					// Every writer writes every key, so renames race
+					text := fmt.Sprintf("doc %d", k)
+					if err := cache.Put("m", text, vector(k)); err != nil {
+						t.Errorf("Put: %v", err)
+						return
+					}
+					if vec, ok := cache.Get("m", text); !ok || !reflect.DeepEqual(vec, vector(k)) {
+						t.Errorf("Get(%q) returned a torn or missing entry", text)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for k := 0; k < keys; k++ {
+		if vec, ok := caches[0].Get("m", fmt.Sprintf("doc %d", k)); !ok || !reflect.DeepEqual(vec, vector(k)) {
+			t.Fatalf("entry %d missing or wrong after concurrent writes", k)
+		}
+	}
+	// No temporary files are left behind
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "*", ".tmp-*"))
+	if len(leftovers) > 0 {
+		t.Fatalf("temporary files left behind: %v", leftovers)
+	}
+}
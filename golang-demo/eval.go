@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Benchmark is an evaluation benchmark: a dataset scored by scoring functions
+type Benchmark struct {
+	Identifier         string                 `json:"identifier"`
+	ProviderResourceID string                 `json:"provider_resource_id,omitempty"`
+	ProviderID         string                 `json:"provider_id,omitempty"`
+	Type               string                 `json:"type,omitempty"`
+	DatasetID          string                 `json:"dataset_id"`
+	ScoringFunctions   []string               `json:"scoring_functions"`
+	Metadata           map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ListBenchmarksResponse is the response of ListBenchmarks
+type ListBenchmarksResponse = Page[Benchmark]
+
+// RegisterBenchmarkParams registers a benchmark
+type RegisterBenchmarkParams struct {
+	BenchmarkID         string                 `json:"benchmark_id"`
+	DatasetID           string                 `json:"dataset_id"`
+	ScoringFunctions    []string               `json:"scoring_functions"`
+	ProviderBenchmarkID string                 `json:"provider_benchmark_id,omitempty"`
+	ProviderID          string                 `json:"provider_id,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// EvalJobStatus is the state of an evaluation job
+type EvalJobStatus string
+
+const (
+	EvalJobScheduled  EvalJobStatus = "scheduled"
+	EvalJobInProgress EvalJobStatus = "in_progress"
+	EvalJobCompleted  EvalJobStatus = "completed"
+	EvalJobFailed     EvalJobStatus = "failed"
+	EvalJobCancelled  EvalJobStatus = "cancelled"
+)
+
+// Done reports whether the job has reached a final state
+func (s EvalJobStatus) Done() bool {
+	return s == EvalJobCompleted || s == EvalJobFailed || s == EvalJobCancelled
+}
+
+// EvalJob is a submitted evaluation job
+type EvalJob struct {
+	JobID  string        `json:"job_id"`
+	Status EvalJobStatus `json:"status"`
+}
+
+// EvaluateResponse holds the generations of an evaluation and, per scoring
+// function, the score of every row and the aggregated results
+type EvaluateResponse struct {
+	Generations []map[string]interface{} `json:"generations"`
+	Scores      map[string]ScoringResult `json:"scores"`
+}
+
+// ScoringResult is the output of one scoring function
+type ScoringResult struct {
+	ScoreRows         []map[string]interface{} `json:"score_rows"`
+	AggregatedResults map[string]interface{}   `json:"aggregated_results"`
+}
+
+// ModelCandidate returns a benchmark config evaluating model with greedy
+// sampling. maxTokens and numExamples are ignored when zero.
+func ModelCandidate(model string, maxTokens, numExamples int) SpecBenchmarkConfig {
+	sampling := SpecSamplingParams{Strategy: map[string]interface{}{"type": "greedy"}}
+	if maxTokens > 0 {
+		sampling.MaxTokens = &maxTokens
+	}
+	candidateType := "model"
+	config := SpecBenchmarkConfig{
+		EvalCandidate: SpecModelCandidate{Type: &candidateType, Model: model, SamplingParams: sampling},
+		ScoringParams: map[string]interface{}{},
+	}
+	if numExamples > 0 {
+		config.NumExamples = &numExamples
+	}
+	return config
+}
+
+// ListBenchmarks lists the registered benchmarks
+func (c *LlamaStackClient) ListBenchmarks(ctx context.Context) (*ListBenchmarksResponse, error) {
+	var response ListBenchmarksResponse
+	if err := c.getJSON(ctx, "/v1/eval/benchmarks", &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetBenchmark returns one benchmark
+func (c *LlamaStackClient) GetBenchmark(ctx context.Context, benchmarkID string) (*Benchmark, error) {
+	var benchmark Benchmark
+	if err := c.getJSON(ctx, "/v1/eval/benchmarks/"+url.PathEscape(benchmarkID), &benchmark); err != nil {
+		return nil, err
+	}
+	return &benchmark, nil
+}
+
+// RegisterBenchmark registers a benchmark over a dataset
+func (c *LlamaStackClient) RegisterBenchmark(ctx context.Context, params RegisterBenchmarkParams) error {
+	if params.BenchmarkID == "" || params.DatasetID == "" || len(params.ScoringFunctions) == 0 {
+		return errors.New("benchmark id, dataset id and scoring functions are required")
+	}
+	var ignored interface{}
+	return c.postJSON(ctx, "/v1/eval/benchmarks", params, &ignored)
+}
+
+// RunEval submits an evaluation job for a benchmark
+func (c *LlamaStackClient) RunEval(ctx context.Context, benchmarkID string, config SpecBenchmarkConfig) (*EvalJob, error) {
+	var job EvalJob
+	body := SpecRunEvalRequest{BenchmarkConfig: config}
+	if err := c.postJSON(ctx, evalJobsPath(benchmarkID), body, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetEvalJob returns the status of an evaluation job
+func (c *LlamaStackClient) GetEvalJob(ctx context.Context, benchmarkID, jobID string) (*EvalJob, error) {
+	var job EvalJob
+	if err := c.getJSON(ctx, evalJobsPath(benchmarkID)+"/"+url.PathEscape(jobID), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelEvalJob cancels an evaluation job
+func (c *LlamaStackClient) CancelEvalJob(ctx context.Context, benchmarkID, jobID string) error {
+	return c.sendDelete(ctx, evalJobsPath(benchmarkID)+"/"+url.PathEscape(jobID))
+}
+
+// GetEvalJobResult returns the result of a completed evaluation job
+func (c *LlamaStackClient) GetEvalJobResult(ctx context.Context, benchmarkID, jobID string) (*EvaluateResponse, error) {
+	var result EvaluateResponse
+	if err := c.getJSON(ctx, evalJobsPath(benchmarkID)+"/"+url.PathEscape(jobID)+"/result", &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func evalJobsPath(benchmarkID string) string {
+	return "/v1/eval/benchmarks/" + url.PathEscape(benchmarkID) + "/jobs"
+}
+
+// WaitForEvalJob polls a job until it completes, fails or is cancelled, and
+// returns its result. If ctx is done or opts.Timeout passes first the job
+// keeps running on the server; cancel it with CancelEvalJob if needed.
+func (c *LlamaStackClient) WaitForEvalJob(ctx context.Context, benchmarkID, jobID string, opts PollOptions[*EvalJob]) (*EvaluateResponse, error) {
+	poller := Poller[*EvalJob]{
+		Poll: func(ctx context.Context) (*EvalJob, error) {
+			return c.GetEvalJob(ctx, benchmarkID, jobID)
+		},
+		Done: func(job *EvalJob) (bool, error) {
+			if job.Status == EvalJobFailed || job.Status == EvalJobCancelled {
+				return true, fmt.Errorf("eval job %s %s", jobID, job.Status)
+			}
+			return job.Status.Done(), nil
+		},
+		Options: opts,
+		Logger:  c.logger(),
+	}
+	if _, err := poller.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("failed to wait for eval job: %w", err)
+	}
+	return c.GetEvalJobResult(ctx, benchmarkID, jobID)
+}
+
+// EvalRun is the outcome of evaluating one model in CompareModels
+type EvalRun struct {
+	Model  string
+	JobID  string
+	Result *EvaluateResponse
+	Err    error
+}
+
+// CompareModels runs the same benchmark against several models concurrently
+// and waits for every job with opts. Results are returned in the order of
+// models; a failed job does not stop the others.
+func (c *LlamaStackClient) CompareModels(ctx context.Context, benchmarkID string, models []string, config func(model string) SpecBenchmarkConfig, opts PollOptions[*EvalJob]) []EvalRun {
+	runs := make([]EvalRun, len(models))
+	var wg sync.WaitGroup
+	for i, model := range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runs[i].Model = model
+			job, err := c.RunEval(ctx, benchmarkID, config(model))
+			if err != nil {
+				runs[i].Err = fmt.Errorf("failed to submit eval job: %w", err)
+				return
+			}
+			runs[i].JobID = job.JobID
+			runs[i].Result, runs[i].Err = c.WaitForEvalJob(ctx, benchmarkID, job.JobID, opts)
+		}()
+	}
+	wg.Wait()
+	return runs
+}
+
+// runEvalCommand handles the "eval benchmarks", "eval register" and "eval run" subcommands
+func runEvalCommand(client *LlamaStackClient, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "benchmarks":
+			return runEvalBenchmarksCommand(client)
+		case "register":
+			return runEvalRegisterCommand(client, args[1:])
+		case "run":
+			return runEvalRunCommand(client, args[1:])
+		}
+	}
+	return errors.New("usage: eval benchmarks|register|run ...")
+}
+
+func runEvalBenchmarksCommand(client *LlamaStackClient) error {
+	benchmarks, err := client.ListBenchmarks(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(benchmarks.Data) == 0 {
+		fmt.Println("No benchmarks registered")
+	}
+	for _, b := range benchmarks.Data {
+		fmt.Printf("%s  dataset=%s  scoring=%s\n", b.Identifier, b.DatasetID, strings.Join(b.ScoringFunctions, ","))
+	}
+	return nil
+}
+
+func runEvalRegisterCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("eval register", flag.ContinueOnError)
+	dataset := fs.String("dataset", "", "dataset id")
+	scoring := fs.String("scoring", "", "comma-separated scoring functions, e.g. basic::subset_of")
+	provider := fs.String("provider", "", "eval provider id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *dataset == "" || *scoring == "" {
+		return errors.New("usage: eval register -dataset DATASET_ID -scoring fn1,fn2 [-provider ID] BENCHMARK_ID")
+	}
+	err := client.RegisterBenchmark(context.Background(), RegisterBenchmarkParams{
+		BenchmarkID:      fs.Arg(0),
+		DatasetID:        *dataset,
+		ScoringFunctions: strings.Split(*scoring, ","),
+		ProviderID:       *provider,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Registered benchmark %s\n", fs.Arg(0))
+	return nil
+}
+
+func runEvalRunCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("eval run", flag.ContinueOnError)
+	models := fs.String("model", "", "comma-separated models to evaluate and compare")
+	numExamples := fs.Int("num-examples", 0, "evaluate only the first N rows")
+	maxTokens := fs.Int("max-tokens", 0, "max tokens per generation")
+	interval := fs.Duration("interval", 5*time.Second, "first job status polling interval, backing off up to a minute")
+	timeout := fs.Duration("timeout", 0, "give up waiting after this long")
+	output := fs.String("o", "", "write the full results as JSON to this file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *models == "" {
+		return errors.New("usage: eval run -model MODEL[,MODEL...] [-num-examples 10] [-max-tokens 512] [-o results.json] BENCHMARK_ID")
+	}
+	benchmarkID := fs.Arg(0)
+
+	fmt.Printf("Running %s against %s...\n", benchmarkID, *models)
+	var mu sync.Mutex
+	seen := make(map[string]EvalJobStatus)
+	runs := client.CompareModels(context.Background(), benchmarkID, strings.Split(*models, ","), func(model string) SpecBenchmarkConfig {
+		return ModelCandidate(model, *maxTokens, *numExamples)
+	}, PollOptions[*EvalJob]{
+		Interval:    *interval,
+		MaxInterval: time.Minute,
+		Jitter:      0.1,
+		Timeout:     *timeout,
+		Progress: func(job *EvalJob, attempt int) {
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[job.JobID] != job.Status {
+				seen[job.JobID] = job.Status
+				fmt.Printf("job %s: %s\n", job.JobID, job.Status)
+			}
+		},
+	})
+
+	var errs []error
+	for _, run := range runs {
+		if run.Err != nil {
+			fmt.Printf("%s: %v\n", run.Model, run.Err)
+			errs = append(errs, fmt.Errorf("%s: %w", run.Model, run.Err))
+			continue
+		}
+		fmt.Printf("%s (job %s, %d generations)\n", run.Model, run.JobID, len(run.Result.Generations))
+		fns := make([]string, 0, len(run.Result.Scores))
+		for fn := range run.Result.Scores {
+			fns = append(fns, fn)
+		}
+		sort.Strings(fns)
+		for _, fn := range fns {
+			aggregated, _ := json.Marshal(run.Result.Scores[fn].AggregatedResults)
+			fmt.Printf("  %s: %s\n", fn, aggregated)
+		}
+	}
+
+	if *output != "" {
+		results := make(map[string]*EvaluateResponse)
+		for _, run := range runs {
+			if run.Err == nil {
+				results[run.Model] = run.Result
+			}
+		}
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal results: %w", err)
+		}
+		if err := os.WriteFile(*output, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write results: %w", err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Conversation is a chat or agent session flattened to an ordered message
+// list, ready to be exported
+type Conversation struct {
+	Title    string
+	Model    string
+	System   string // system prompt or agent instructions, if known
+	Messages []ConversationMessage
+}
+
+// ConversationMessage is one message of an exported conversation
+type ConversationMessage struct {
+	Role       string
+	Content    string
+	ToolCalls  []ToolCall // assistant tool calls
+	ToolCallID string     // set on tool results
+	Name       string     // tool name on tool results
+	Chunks     []string   // retrieved RAG chunks, on knowledge_search results
+	Duration   time.Duration
+}
+
+// ConversationFromChat builds a conversation from chat completion messages and,
+// if resp is not nil, the assistant reply
+func ConversationFromChat(title string, messages []Message, resp *APIResponse) *Conversation {
+	conv := &Conversation{Title: title}
+	for _, m := range messages {
+		if m.Role == "system" && conv.System == "" && len(conv.Messages) == 0 {
+			conv.System = m.Content
+			continue
+		}
+		conv.Messages = append(conv.Messages, ConversationMessage{Role: m.Role, Content: m.Content, Name: m.Name})
+	}
+	if resp != nil && len(resp.Choices) > 0 {
+		conv.Model = resp.Model
+		reply := resp.Choices[0].Message
+		conv.Messages = append(conv.Messages, ConversationMessage{
+			Role:      "assistant",
+			Content:   reply.Content,
+			ToolCalls: reply.ToolCalls,
+		})
+	}
+	return conv
+}
+
+// ConversationFromSession builds a conversation from an agent session fetched
+// with GetSession, including tool calls, tool results and retrieved chunks
+// recorded in the turn steps
+func ConversationFromSession(session *Session, agent *AgentConfig) *Conversation {
+	conv := &Conversation{Title: session.SessionName}
+	if conv.Title == "" {
+		conv.Title = session.SessionID
+	}
+	if agent != nil {
+		conv.Model = agent.Model
+		conv.System = agent.Instructions
+	}
+
+	for _, turn := range session.Turns {
+		for _, m := range turn.InputMessages {
+			conv.Messages = append(conv.Messages, ConversationMessage{Role: m.Role, Content: m.Content, Name: m.Name})
+		}
+		for _, raw := range turn.Steps {
+			step, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, msg := range stepMessages(step) {
+				if n := len(conv.Messages); n > 0 && msg.Role == "assistant" && msg.Content == "" && sameToolCalls(conv.Messages[n-1].ToolCalls, msg.ToolCalls) {
+					continue
+				}
+				conv.Messages = append(conv.Messages, msg)
+			}
+		}
+
+		// The final inference step repeats the output message; only add it
+		// when the steps did not already end with it
+		final := ConversationMessage{Role: "assistant", Content: turn.OutputMessage.Content, Duration: turnDuration(turn)}
+		if n := len(conv.Messages); n > 0 && conv.Messages[n-1].Role == "assistant" && conv.Messages[n-1].Content == final.Content && len(conv.Messages[n-1].ToolCalls) == 0 {
+			conv.Messages[n-1].Duration = final.Duration
+		} else if final.Content != "" {
+			conv.Messages = append(conv.Messages, final)
+		}
+	}
+	return conv
+}
+
+// stepMessages converts one turn step into the messages it represents
+func stepMessages(step map[string]interface{}) []ConversationMessage {
+	duration := stepDuration(step)
+	switch step["step_type"] {
+	case "inference":
+		response, _ := step["model_response"].(map[string]interface{})
+		if response == nil {
+			return nil
+		}
+		msg := ConversationMessage{Role: "assistant", Content: contentText(response["content"]), Duration: duration}
+		calls, _ := response["tool_calls"].([]interface{})
+		for _, c := range calls {
+			msg.ToolCalls = append(msg.ToolCalls, agentToolCall(c))
+		}
+		return []ConversationMessage{msg}
+
+	case "tool_execution":
+		var messages []ConversationMessage
+		// Repeats the inference step's tool calls; ConversationFromSession
+		// drops the duplicate but keeps them when no inference step was recorded
+		calls, _ := step["tool_calls"].([]interface{})
+		if len(calls) > 0 {
+			msg := ConversationMessage{Role: "assistant"}
+			for _, c := range calls {
+				msg.ToolCalls = append(msg.ToolCalls, agentToolCall(c))
+			}
+			messages = append(messages, msg)
+		}
+		responses, _ := step["tool_responses"].([]interface{})
+		for _, r := range responses {
+			resp, _ := r.(map[string]interface{})
+			if resp == nil {
+				continue
+			}
+			name, _ := resp["tool_name"].(string)
+			callID, _ := resp["call_id"].(string)
+			msg := ConversationMessage{Role: "tool", Name: name, ToolCallID: callID, Content: contentText(resp["content"]), Duration: duration}
+			if name == "knowledge_search" {
+				msg.Chunks = contentTexts(resp["content"])
+			}
+			messages = append(messages, msg)
+		}
+		return messages
+
+	case "memory_retrieval":
+		chunks := contentTexts(step["inserted_context"])
+		if len(chunks) == 0 {
+			return nil
+		}
+		return []ConversationMessage{{Role: "tool", Name: "memory_retrieval", Content: strings.Join(chunks, "\n"), Chunks: chunks, Duration: duration}}
+	}
+	return nil
+}
+
+// sameToolCalls reports whether both lists hold calls with the same IDs
+func sameToolCalls(a, b []ToolCall) bool {
+	if len(a) == 0 || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+// agentToolCall converts an agent tool call ({call_id, tool_name, arguments})
+// to the OpenAI shape used everywhere else
+func agentToolCall(raw interface{}) ToolCall {
+	call, _ := raw.(map[string]interface{})
+	id, _ := call["call_id"].(string)
+	name, _ := call["tool_name"].(string)
+	var args string
+	switch a := call["arguments"].(type) {
+	case string:
+		args = a
+	case nil:
+		args = "{}"
+	default:
+		data, _ := json.Marshal(a)
+		args = string(data)
+	}
+	return ToolCall{ID: id, Type: "function", Function: ToolCallFunction{Name: name, Arguments: args}}
+}
+
+// contentText flattens string or content-item content to text
+func contentText(v interface{}) string {
+	return strings.Join(contentTexts(v), "\n")
+}
+
+// contentTexts returns the text parts of string or content-item content
+func contentTexts(v interface{}) []string {
+	switch c := v.(type) {
+	case string:
+		if c == "" {
+			return nil
+		}
+		return []string{c}
+	case map[string]interface{}:
+		if text, ok := c["text"].(string); ok {
+			return []string{text}
+		}
+	case []interface{}:
+		var texts []string
+		for _, item := range c {
+			texts = append(texts, contentTexts(item)...)
+		}
+		return texts
+	}
+	return nil
+}
+
+func turnDuration(turn Turn) time.Duration {
+	if turn.CompletedAt == nil {
+		return 0
+	}
+	return timeBetween(turn.StartedAt, *turn.CompletedAt)
+}
+
+func stepDuration(step map[string]interface{}) time.Duration {
+	started, _ := step["started_at"].(string)
+	completed, _ := step["completed_at"].(string)
+	return timeBetween(started, completed)
+}
+
+// timeBetween parses two RFC 3339 timestamps; unparsable input gives 0
+func timeBetween(start, end string) time.Duration {
+	s, err1 := time.Parse(time.RFC3339Nano, start)
+	e, err2 := time.Parse(time.RFC3339Nano, end)
+	if err1 != nil || err2 != nil || e.Before(s) {
+		return 0
+	}
+	return e.Sub(s)
+}
+
+// WriteMarkdown renders the conversation as Markdown for sharing
+func (conv *Conversation) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", conv.Title)
+	if conv.Model != "" {
+		fmt.Fprintf(&b, "_Model: `%s`_\n\n", conv.Model)
+	}
+	if conv.System != "" {
+		fmt.Fprintf(&b, "**System**\n\n%s\n\n", conv.System)
+	}
+
+	for _, m := range conv.Messages {
+		switch m.Role {
+		case "tool":
+			fmt.Fprintf(&b, "**Tool result** (`%s`)", m.Name)
+		case "":
+			b.WriteString("**Message**")
+		default:
+			fmt.Fprintf(&b, "**%s**", strings.ToUpper(m.Role[:1])+m.Role[1:])
+		}
+		if m.Duration > 0 {
+			fmt.Fprintf(&b, " _(%s)_", m.Duration.Round(time.Millisecond))
+		}
+		b.WriteString("\n\n")
+
+		if len(m.Chunks) > 0 {
+			fmt.Fprintf(&b, "<details><summary>%d retrieved chunks</summary>\n\n", len(m.Chunks))
+			for i, chunk := range m.Chunks {
+				fmt.Fprintf(&b, "%d. %s\n", i+1, strings.ReplaceAll(strings.TrimSpace(chunk), "\n", "\n   "))
+			}
+			b.WriteString("\n</details>\n\n")
+		} else if m.Content != "" {
+			fmt.Fprintf(&b, "%s\n\n", m.Content)
+		}
+
+		for _, call := range m.ToolCalls {
+			fmt.Fprintf(&b, "> Tool call `%s`: `%s`\n\n", call.Function.Name, call.Function.Arguments)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fineTuningMessage is a message in the OpenAI chat fine-tuning format
+type fineTuningMessage struct {
+	Role       string     `json:"role"`
+	Content    *string    `json:"content"`
+	Name       string     `json:"name,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// WriteJSONL writes each conversation as one line in the OpenAI chat
+// fine-tuning format: {"messages": [...]}
+func WriteJSONL(w io.Writer, conversations ...*Conversation) error {
+	enc := json.NewEncoder(w)
+	for _, conv := range conversations {
+		var messages []fineTuningMessage
+		if conv.System != "" {
+			system := conv.System
+			messages = append(messages, fineTuningMessage{Role: "system", Content: &system})
+		}
+		for _, m := range conv.Messages {
+			content := m.Content
+			msg := fineTuningMessage{Role: m.Role, Content: &content, ToolCalls: m.ToolCalls, ToolCallID: m.ToolCallID}
+			if m.Role == "assistant" && content == "" && len(m.ToolCalls) > 0 {
+				msg.Content = nil
+			}
+			if m.Role == "user" {
+				msg.Name = m.Name
+			}
+			messages = append(messages, msg)
+		}
+
+		if err := enc.Encode(struct {
+			Messages []fineTuningMessage `json:"messages"`
+		}{messages}); err != nil {
+			return fmt.Errorf("failed to write conversation %q: %w", conv.Title, err)
+		}
+	}
+	return nil
+}
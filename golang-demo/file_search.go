@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// IncludeFileSearchResults asks for the chunks file_search retrieved in its
+// file_search_call output items; without it servers may send only the queries
+const IncludeFileSearchResults = "file_search_call.results"
+
+// FileSearchTool lets a Responses API call search vector stores, the
+// OpenAI-style counterpart of the rag-tool. Filters are evaluated against
+// the attributes given when attaching files, as in SearchVectorStore.
+type FileSearchTool struct {
+	VectorStoreIDs []string              `json:"vector_store_ids"`
+	Filters        *Filter               `json:"filters,omitempty"`
+	MaxNumResults  int                   `json:"max_num_results,omitempty"`
+	RankingOptions *SearchRankingOptions `json:"ranking_options,omitempty"`
+}
+
+// MarshalJSON adds the tool type
+func (t FileSearchTool) MarshalJSON() ([]byte, error) {
+	type plain FileSearchTool
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{"file_search", plain(t)})
+}
+
+// Validate checks the tool before it is sent
+func (t FileSearchTool) Validate() error {
+	if len(t.VectorStoreIDs) == 0 {
+		return errors.New("file_search needs at least one vector store")
+	}
+	if t.MaxNumResults < 0 || t.MaxNumResults > 50 {
+		return fmt.Errorf("file_search max_num_results must be between 1 and 50, got %d", t.MaxNumResults)
+	}
+	if t.Filters != nil {
+		if err := t.Filters.Validate(); err != nil {
+			return fmt.Errorf("invalid file_search filters: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateResponseTools checks the typed tools of a Responses API call
+func validateResponseTools(tools []interface{}) error {
+	for _, tool := range tools {
+		switch tool := tool.(type) {
+		case FileSearchTool:
+			if err := tool.Validate(); err != nil {
+				return err
+			}
+		case *FileSearchTool:
+			if err := tool.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FileSearchResult is one chunk a file_search call retrieved
+type FileSearchResult struct {
+	FileID     string                 `json:"file_id"`
+	Filename   string                 `json:"filename,omitempty"`
+	Score      float64                `json:"score"`
+	Text       string                 `json:"text"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// Annotation marks the part of an output text a source supports. File
+// citations name the file and the index in the text they follow.
+type Annotation struct {
+	Type     string `json:"type"` // "file_citation", "url_citation" or "container_file_citation"
+	FileID   string `json:"file_id,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Index    int    `json:"index,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+// FileSearchResults returns the chunks retrieved by the response's
+// file_search calls, in order
+func (r *ResponseObject) FileSearchResults() []FileSearchResult {
+	var results []FileSearchResult
+	for _, item := range r.Output {
+		if item.Type == "file_search_call" {
+			results = append(results, item.Results...)
+		}
+	}
+	return results
+}
+
+// Annotations returns the annotations of the response's assistant messages
+func (r *ResponseObject) Annotations() []Annotation {
+	var annotations []Annotation
+	for _, item := range r.Output {
+		if item.Type != "message" || item.Role != "assistant" {
+			continue
+		}
+		for _, part := range item.Content {
+			annotations = append(annotations, part.Annotations...)
+		}
+	}
+	return annotations
+}
+
+// CitationsFromResponse converts the chunks retrieved by a response's
+// file_search calls into citations, numbered in retrieval order, so they
+// render like rag-tool citations with CitedAnswer
+func CitationsFromResponse(r *ResponseObject) []Citation {
+	var tracker CitationTracker
+	for _, result := range r.FileSearchResults() {
+		documentID := result.Filename
+		if documentID == "" {
+			documentID = result.FileID
+		}
+		tracker.Add(Citation{DocumentID: documentID, Content: result.Text, Score: result.Score, Metadata: result.Attributes})
+	}
+	return tracker.Citations()
+}
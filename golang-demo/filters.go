@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter is a metadata filter expression in the OpenAI vector store format:
+// a comparison {type, key, value} or a compound {type: and|or, filters}
+type Filter struct {
+	Type    string
+	Key     string
+	Value   interface{}
+	Filters []Filter
+}
+
+// Eq matches documents whose key equals value
+func Eq(key string, value interface{}) Filter { return Filter{Type: "eq", Key: key, Value: value} }
+
+// Ne matches documents whose key differs from value
+func Ne(key string, value interface{}) Filter { return Filter{Type: "ne", Key: key, Value: value} }
+
+// Gt matches documents whose key is greater than value
+func Gt(key string, value interface{}) Filter { return Filter{Type: "gt", Key: key, Value: value} }
+
+// Gte matches documents whose key is greater than or equal to value
+func Gte(key string, value interface{}) Filter { return Filter{Type: "gte", Key: key, Value: value} }
+
+// Lt matches documents whose key is less than value
+func Lt(key string, value interface{}) Filter { return Filter{Type: "lt", Key: key, Value: value} }
+
+// Lte matches documents whose key is less than or equal to value
+func Lte(key string, value interface{}) Filter { return Filter{Type: "lte", Key: key, Value: value} }
+
+// Between matches documents whose key lies in [lo, hi]
+func Between(key string, lo, hi interface{}) Filter { return And(Gte(key, lo), Lte(key, hi)) }
+
+// In matches documents whose key equals any of values. It is sent as an "or"
+// of equalities, which every provider understands.
+func In(key string, values ...interface{}) Filter {
+	filters := make([]Filter, len(values))
+	for i, v := range values {
+		filters[i] = Eq(key, v)
+	}
+	return Or(filters...)
+}
+
+// And matches documents matching every filter
+func And(filters ...Filter) Filter { return Filter{Type: "and", Filters: filters} }
+
+// Or matches documents matching any filter
+func Or(filters ...Filter) Filter { return Filter{Type: "or", Filters: filters} }
+
+func (f Filter) isCompound() bool { return f.Type == "and" || f.Type == "or" }
+
+// MarshalJSON always writes the value of a comparison, even when it is zero
+func (f Filter) MarshalJSON() ([]byte, error) {
+	if f.isCompound() {
+		filters := f.Filters
+		if filters == nil {
+			filters = []Filter{}
+		}
+		return json.Marshal(struct {
+			Type    string   `json:"type"`
+			Filters []Filter `json:"filters"`
+		}{f.Type, filters})
+	}
+	return json.Marshal(struct {
+		Type  string      `json:"type"`
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+	}{f.Type, f.Key, f.Value})
+}
+
+// UnmarshalJSON reads either filter shape
+func (f *Filter) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type    string      `json:"type"`
+		Key     string      `json:"key"`
+		Value   interface{} `json:"value"`
+		Filters []Filter    `json:"filters"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*f = Filter{Type: raw.Type, Key: raw.Key, Value: raw.Value, Filters: raw.Filters}
+	return nil
+}
+
+// Validate checks the expression is well formed
+func (f Filter) Validate() error {
+	switch f.Type {
+	case "and", "or":
+		if len(f.Filters) == 0 {
+			return fmt.Errorf("%s filter has no filters", f.Type)
+		}
+		var errs []error
+		for _, sub := range f.Filters {
+			if err := sub.Validate(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	case "eq", "ne", "gt", "gte", "lt", "lte":
+		if f.Key == "" {
+			return fmt.Errorf("%s filter has no key", f.Type)
+		}
+		switch f.Value.(type) {
+		case string, bool, float64, float32, int, int64, int32:
+		default:
+			return fmt.Errorf("%s filter on %s: unsupported value type %T", f.Type, f.Key, f.Value)
+		}
+		if _, ok := f.Value.(bool); ok && f.Type != "eq" && f.Type != "ne" {
+			return fmt.Errorf("%s filter on %s cannot compare booleans", f.Type, f.Key)
+		}
+		return nil
+	case "":
+		return errors.New("filter has no type")
+	}
+	return fmt.Errorf("unknown filter type %q", f.Type)
+}
+
+// Match evaluates the filter against a document's metadata or attributes, for
+// backends that cannot filter server-side. Values are compared as numbers when
+// both sides are numeric, including numeric strings, and as strings otherwise.
+// A missing key only matches "ne".
+func (f Filter) Match(attrs map[string]interface{}) bool {
+	switch f.Type {
+	case "and":
+		for _, sub := range f.Filters {
+			if !sub.Match(attrs) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		for _, sub := range f.Filters {
+			if sub.Match(attrs) {
+				return true
+			}
+		}
+		return false
+	}
+
+	actual, ok := attrs[f.Key]
+	if !ok {
+		return f.Type == "ne"
+	}
+	cmp, comparable := compareFilterValues(actual, f.Value)
+	switch f.Type {
+	case "eq":
+		return comparable && cmp == 0
+	case "ne":
+		return !comparable || cmp != 0
+	case "gt":
+		return comparable && cmp > 0
+	case "gte":
+		return comparable && cmp >= 0
+	case "lt":
+		return comparable && cmp < 0
+	case "lte":
+		return comparable && cmp <= 0
+	}
+	return false
+}
+
+// compareFilterValues orders a against b, reporting false when they cannot be compared
+func compareFilterValues(a, b interface{}) (int, bool) {
+	if x, ok := filterNumber(a); ok {
+		if y, ok := filterNumber(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	_, aBool := a.(bool)
+	_, bBool := b.(bool)
+	if aBool || bBool {
+		x, okA := filterBool(a)
+		y, okB := filterBool(b)
+		if !okA || !okB {
+			return 0, false
+		}
+		if x == y {
+			return 0, true
+		}
+		return 1, true
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), true
+}
+
+// filterBool reads booleans, including the "True"/"False" of metadata parsed
+// from chunk text
+func filterBool(v interface{}) (bool, bool) {
+	switch b := v.(type) {
+	case bool:
+		return b, true
+	case string:
+		switch strings.ToLower(b) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// filterNumber converts JSON and Go numbers and numeric strings to float64
+func filterNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
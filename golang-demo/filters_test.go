@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	attrs := map[string]interface{}{
+		"owner":  "Ana",
+		"age":    float64(3),
+		"weight": "7.5",
+		"vet":    "True",
+		"pug":    true,
+		"code":   "010",
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"eq string", Eq("owner", "Ana"), true},
+		{"eq is case sensitive", Eq("owner", "ana"), false},
+		{"eq number", Eq("age", 3), true},
+		{"ne", Ne("owner", "Bob"), true},
+		{"missing key matches ne", Ne("city", "Campinas"), true},
+		{"missing key fails eq", Eq("city", "Campinas"), false},
+		{"missing key fails gt", Gt("city", 0), false},
+		{"missing key fails lt", Lt("city", 0), false},
+		{"gt", Gt("age", 2), true},
+		{"gt equal", Gt("age", 3), false},
+		{"gte", Gte("age", 3), true},
+		{"lt", Lt("age", 3), false},
+		{"lte", Lte("age", 3.0), true},
+		{"numeric string against a number", Gt("weight", 7), true},
+		{"numeric strings compare as numbers", Lt("weight", "10"), true},
+		{"leading zeros are numeric", Eq("code", 10), true},
+		{"number against a word compares as strings", Lt("age", "cat"), true},
+		{"string order", Lt("owner", "Bob"), true},
+		{"bool", Eq("pug", true), true},
+		{"bool from text", Eq("vet", true), true},
+		{"bool against a number", Eq("pug", 1), false},
+		{"bool against a number is ne", Ne("pug", 1), true},
+		{"between", Between("age", 1, 3), true},
+		{"outside between", Between("age", 4, 9), false},
+		{"in", In("owner", "Bob", "Ana"), true},
+		{"not in", In("owner", "Bob", "Carla"), false},
+		{"empty in matches nothing", In("owner"), false},
+		{"empty and matches everything", And(), true},
+		{"and", And(Eq("owner", "Ana"), Gt("age", 5)), false},
+		{"or", Or(Eq("owner", "Bob"), Gt("age", 2)), true},
+		{"nested", And(Or(Eq("city", "x"), Ne("city", "x")), Eq("pug", true)), true},
+		{"unknown type", Filter{Type: "like", Key: "owner", Value: "A"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(attrs); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		filter  Filter
+		wantErr bool
+	}{
+		{And(Eq("owner", "Ana"), Between("age", 1, 3)), false},
+		{In("owner", "Ana"), false},
+		{In("owner"), true},
+		{Filter{}, true},
+		{Filter{Type: "like", Key: "owner", Value: "A"}, true},
+		{Eq("", "Ana"), true},
+		{Eq("tags", []string{"pug"}), true},
+		{Gt("pug", true), true},
+		{Ne("pug", true), false},
+		{Or(Eq("owner", "Ana"), Eq("", 1)), true},
+	}
+	for _, tt := range tests {
+		if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Validate() = %v, want error %v", tt.filter, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFilterJSON(t *testing.T) {
+	f := And(Eq("age", 0), Or())
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Zero values and empty filter lists are still written
+	want := `{"type":"and","filters":[{"type":"eq","key":"age","value":0},{"type":"or","filters":[]}]}`
+	if string(data) != want {
+		t.Fatalf("Marshal = %s, want %s", data, want)
+	}
+	var back Filter
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Type != "and" || len(back.Filters) != 2 || !reflect.DeepEqual(back.Filters[0], Eq("age", float64(0))) {
+		t.Fatalf("Unmarshal = %+v", back)
+	}
+}
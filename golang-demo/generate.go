@@ -0,0 +1,5 @@
+package main
+
+// Regenerate the Spec* models after updating ../openapi.json, then run the
+// generator with -check to list hand-written types that drifted from the spec.
+//go:generate go run tools/openapigen/main.go -spec ../openapi.json -out openapi_types_gen.go
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// ViolationLevel is how serious a shield considers a violation
+type ViolationLevel string
+
+const (
+	ViolationInfo  ViolationLevel = "info"
+	ViolationWarn  ViolationLevel = "warn"
+	ViolationError ViolationLevel = "error"
+)
+
+// rank orders levels; unknown levels rank as errors
+func (l ViolationLevel) rank() int {
+	switch l {
+	case ViolationInfo:
+		return 0
+	case ViolationWarn:
+		return 1
+	}
+	return 2
+}
+
+// SafetyViolation is what a shield found wrong with a conversation
+type SafetyViolation struct {
+	ViolationLevel ViolationLevel `json:"violation_level"`
+	// UserMessage is the server's explanation to show users, if any
+	UserMessage string                 `json:"user_message,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"` // e.g. violation_type
+}
+
+// RunShieldResponse is the result of RunShield; Violation is nil when the
+// messages passed
+type RunShieldResponse struct {
+	Violation *SafetyViolation `json:"violation,omitempty"`
+}
+
+// RunShield checks messages with a registered shield
+func (c *LlamaStackClient) RunShield(ctx context.Context, shieldID string, messages []Message, params map[string]interface{}) (*RunShieldResponse, error) {
+	body := SpecRunShieldRequest{ShieldID: shieldID, Params: params, Messages: make([]interface{}, len(messages))}
+	for i, msg := range messages {
+		body.Messages[i] = msg
+	}
+	if body.Params == nil {
+		body.Params = map[string]interface{}{}
+	}
+	var response RunShieldResponse
+	if err := c.postJSON(ctx, "/v1/safety/run-shield", body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GuardStage is the side of a completion a shield checked
+type GuardStage string
+
+const (
+	GuardInput  GuardStage = "input"
+	GuardOutput GuardStage = "output"
+)
+
+// GuardPolicy says which shields SafeChatCompletion runs and what stops a
+// completion
+type GuardPolicy struct {
+	// InputShields check the messages before the model is called
+	InputShields []string
+	// OutputShields check the conversation with the model's reply
+	OutputShields []string
+	// BlockLevel is the lowest violation level that blocks; defaults to
+	// ViolationError. Violations below it are reported but let through.
+	BlockLevel ViolationLevel
+	// FailOpen lets a completion through when a shield cannot be run; by
+	// default a shield error fails the completion
+	FailOpen bool
+	// Refusal replaces blocked content when the violation has no user
+	// message of its own
+	Refusal string
+}
+
+// DefaultRefusal is the content of blocked completions when neither the
+// violation nor the policy gives one
+const DefaultRefusal = "I can't help with that."
+
+// ShieldViolation is a violation with the shield and stage it came from
+type ShieldViolation struct {
+	ShieldID string
+	Stage    GuardStage
+	SafetyViolation
+}
+
+// GuardedCompletion is the result of SafeChatCompletion
+type GuardedCompletion struct {
+	// Response is the model's response; nil when the input was blocked
+	Response *APIResponse
+	// Content is the reply, or the refusal when the completion was blocked
+	Content string
+	// Blocked is set when a violation at or above the block level stopped
+	// the completion; BlockedBy is that violation
+	Blocked   bool
+	BlockedBy *ShieldViolation
+	// Violations are all the violations found, blocking or not, in order
+	Violations []ShieldViolation
+	// Unchecked are the shields that failed under a fail-open policy
+	Unchecked []string
+}
+
+// SafeChatCompletion runs the input shields of policy on params.Messages,
+// calls the model and runs the output shields on the reply, giving plain
+// chat completions the input and output shields agents have. A blocked
+// completion is not an error: the result says which shield blocked it and
+// carries a refusal as its content.
+func (c *LlamaStackClient) SafeChatCompletion(ctx context.Context, params ChatCompletionParams, policy GuardPolicy) (*GuardedCompletion, error) {
+	result := &GuardedCompletion{}
+	if blocked, err := c.runShields(ctx, policy, GuardInput, params.Messages, result); err != nil || blocked {
+		return result, err
+	}
+
+	resp, err := c.CreateChatCompletion(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("no choices in response")
+	}
+	result.Response = resp
+	result.Content = resp.Choices[0].Message.Content
+
+	conversation := append(append([]Message(nil), params.Messages...), Message{Role: "assistant", Content: result.Content})
+	if _, err := c.runShields(ctx, policy, GuardOutput, conversation, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// runShields runs the shields of one stage and records their violations in
+// result, blocking it on the first violation at the block level
+func (c *LlamaStackClient) runShields(ctx context.Context, policy GuardPolicy, stage GuardStage, messages []Message, result *GuardedCompletion) (bool, error) {
+	shields := policy.InputShields
+	if stage == GuardOutput {
+		shields = policy.OutputShields
+	}
+	blockLevel := policy.BlockLevel
+	if blockLevel == "" {
+		blockLevel = ViolationError
+	}
+	for _, shield := range shields {
+		resp, err := c.RunShield(ctx, shield, messages, nil)
+		if err != nil {
+			if !policy.FailOpen {
+				return false, fmt.Errorf("%s shield %s failed: %w", stage, shield, err)
+			}
+			c.logger().WarnContext(ctx, "shield failed, continuing unchecked", "shield", shield, "stage", stage, "error", err)
+			result.Unchecked = append(result.Unchecked, shield)
+			continue
+		}
+		if resp.Violation == nil {
+			continue
+		}
+		violation := ShieldViolation{ShieldID: shield, Stage: stage, SafetyViolation: *resp.Violation}
+		result.Violations = append(result.Violations, violation)
+		if violation.ViolationLevel.rank() >= blockLevel.rank() {
+			result.Blocked = true
+			result.BlockedBy = &result.Violations[len(result.Violations)-1]
+			result.Content = violation.UserMessage
+			if result.Content == "" {
+				result.Content = policy.Refusal
+			}
+			if result.Content == "" {
+				result.Content = DefaultRefusal
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// shieldList is a repeatable flag of shield IDs
+type shieldList []string
+
+func (s *shieldList) String() string { return strings.Join(*s, ",") }
+
+func (s *shieldList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// runSafeChatCommand handles "safe-chat": a chat completion checked by input
+// and output shields
+func runSafeChatCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("safe-chat", flag.ContinueOnError)
+	var inputShields, outputShields shieldList
+	fs.Var(&inputShields, "input-shield", "shield checking the prompt; repeatable")
+	fs.Var(&outputShields, "output-shield", "shield checking the reply; repeatable")
+	shield := fs.String("shield", "", "shield checking both the prompt and the reply")
+	register := fs.String("register", "", "register missing shields with llama-guard using this guard model, e.g. meta-llama/Llama-Guard-3-8B")
+	blockLevel := fs.String("block-level", string(ViolationError), "lowest violation level that blocks: info, warn or error")
+	failOpen := fs.Bool("fail-open", false, "answer unchecked when a shield cannot be run")
+	model := fs.String("model", "", "model to chat with; the first LLM by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *shield != "" {
+		inputShields = append(inputShields, *shield)
+		outputShields = append(outputShields, *shield)
+	}
+	if fs.NArg() != 1 || len(inputShields)+len(outputShields) == 0 {
+		return errors.New("usage: safe-chat (-shield ID | -input-shield ID | -output-shield ID)... [-register GUARD_MODEL] [-block-level error] [-fail-open] [-model MODEL] PROMPT")
+	}
+	level := ViolationLevel(*blockLevel)
+	if level != ViolationInfo && level != ViolationWarn && level != ViolationError {
+		return fmt.Errorf("unknown violation level %q", *blockLevel)
+	}
+
+	ctx := context.Background()
+	if *register != "" {
+		for _, id := range append(append([]string(nil), inputShields...), outputShields...) {
+			if _, err := client.EnsureShield(ctx, id, "llama-guard", RegisterShieldParams{ProviderShieldID: *register}); err != nil {
+				return fmt.Errorf("failed to register shield %s: %w", id, err)
+			}
+		}
+	}
+	var err error
+	if *model == "" {
+		if *model, err = client.GetAvailableModel(ctx); err != nil {
+			return fmt.Errorf("no model to chat with: %w", err)
+		}
+	}
+
+	result, err := client.SafeChatCompletion(ctx, ChatCompletionParams{
+		Model:    *model,
+		Messages: []Message{{Role: "user", Content: fs.Arg(0)}},
+	}, GuardPolicy{InputShields: inputShields, OutputShields: outputShields, BlockLevel: level, FailOpen: *failOpen})
+	if err != nil {
+		return err
+	}
+	fmt.Println(result.Content)
+	for _, v := range result.Violations {
+		fmt.Printf("%s violation (%s) from %s shield %s: %v\n", v.ViolationLevel, v.UserMessage, v.Stage, v.ShieldID, v.Metadata)
+	}
+	if result.Blocked {
+		fmt.Printf("Blocked at the %s by shield %s\n", result.BlockedBy.Stage, result.BlockedBy.ShieldID)
+	}
+	for _, s := range result.Unchecked {
+		fmt.Printf("Warning: shield %s could not be run\n", s)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WithReplicas adds the base URLs of further replicas serving the same stack
+// as the client's BaseURL; see Replicas
+func WithReplicas(baseURLs ...string) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.Replicas = append(c.Replicas, baseURLs...)
+	}
+}
+
+// WithHedging sends a duplicate of a hedgeable request to a second endpoint
+// when the first has not answered after delay; see HedgeDelay
+func WithHedging(delay time.Duration) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.HedgeDelay = delay
+	}
+}
+
+// hedgedPaths are the POST endpoints that are safe to send twice: they run
+// inference or search and create nothing on the server
+var hedgedPaths = []string{
+	"/v1/openai/v1/chat/completions",
+	"/v1/openai/v1/completions",
+	"/v1/openai/v1/embeddings",
+	"/v1/inference/",
+	"/v1/tool-runtime/rag-tool/query",
+	"/v1/vector-io/query",
+}
+
+// hedgeable reports whether req may be sent to two replicas at once. Reads
+// always may; writes only if they create nothing, so a hedge never makes a
+// second agent, session or file.
+func hedgeable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		path := req.URL.Path
+		if strings.HasPrefix(path, "/v1/openai/v1/vector_stores/") && strings.HasSuffix(path, "/search") {
+			return true
+		}
+		for _, p := range hedgedPaths {
+			if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// send sends req with HTTPClient. With Replicas, it is sent to the endpoint
+// chosen by LoadBalance, failing over or hedging to the others.
+func (c *LlamaStackClient) send(req *http.Request) (*http.Response, error) {
+	bases := c.endpoints(req)
+	switch {
+	case len(bases) == 0:
+		return c.HTTPClient.Do(req)
+	case c.HedgeDelay > 0 && hedgeable(req) && replayable(req):
+		return c.hedge(req, bases)
+	default:
+		return c.failover(req, bases)
+	}
+}
+
+// hedgeAttempt is the outcome of one copy of a hedged request
+type hedgeAttempt struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+// answered reports whether the attempt got a usable answer; a replica that
+// failed or answered 5xx does not end the race while the other is running
+func (a hedgeAttempt) answered() bool {
+	return a.err == nil && a.resp.StatusCode < 500
+}
+
+// hedge sends req to the first endpoint and, if no answer arrived within
+// HedgeDelay, a copy to the second. The first answer wins and the other
+// request is cancelled. An endpoint that cannot be connected to is hedged
+// right away; any other failure before the delay is returned as is, since
+// hedging cuts tail latency and does not retry. When neither endpoint can
+// be connected to, the request fails over to the remaining ones.
+func (c *LlamaStackClient) hedge(req *http.Request, bases []string) (*http.Response, error) {
+	results := make(chan hedgeAttempt, 2)
+	var cancels []context.CancelFunc
+	launch := func(base string) {
+		ctx, cancel := context.WithCancel(req.Context())
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := c.sendTo(ctx, req, base)
+			results <- hedgeAttempt{index: index, resp: resp, err: err}
+		}()
+	}
+	// cancelOthers stops every attempt but the winner and releases whatever
+	// the losers still return
+	cancelOthers := func(winner hedgeAttempt, pending int) {
+		for i, cancel := range cancels {
+			if i != winner.index {
+				cancel()
+			}
+		}
+		go func() {
+			for ; pending > 0; pending-- {
+				if lost := <-results; lost.resp != nil {
+					lost.resp.Body.Close()
+				}
+			}
+		}()
+	}
+
+	launch(bases[0])
+	timer := time.NewTimer(c.HedgeDelay)
+	defer timer.Stop()
+
+	pending, hedged := 1, false
+	hedgeNow := func(reason string) {
+		c.logger().DebugContext(req.Context(), "hedging request", "endpoint", req.URL.Path, "replica", bases[1], "reason", reason)
+		launch(bases[1])
+		pending++
+		hedged = true
+	}
+	var failed hedgeAttempt
+	for {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedgeNow("no answer after " + c.HedgeDelay.String())
+			}
+		case attempt := <-results:
+			pending--
+			if !hedged && attempt.err != nil && isConnectError(attempt.err) && req.Context().Err() == nil {
+				hedgeNow("connection failed")
+				continue
+			}
+			if !attempt.answered() && pending > 0 {
+				failed = attempt
+				continue
+			}
+			if failed.resp != nil {
+				failed.resp.Body.Close()
+			}
+			cancelOthers(attempt, pending)
+			if attempt.err != nil {
+				cancels[attempt.index]()
+				if isConnectError(attempt.err) && len(bases) > 2 && req.Context().Err() == nil {
+					// Neither hedged endpoint could be reached, so the
+					// request is still unsent; the rest may be up
+					c.logger().WarnContext(req.Context(), "hedged endpoints unavailable, failing over", "endpoint", req.URL.Path, "to", bases[2], "error", attempt.err)
+					return c.failover(req, bases[2:])
+				}
+				return nil, attempt.err
+			}
+			if hedged {
+				c.logger().DebugContext(req.Context(), "hedged request answered", "endpoint", req.URL.Path, "by", bases[attempt.index])
+			}
+			// The winner's context lives until its body has been read
+			attempt.resp.Body = &cancelOnClose{ReadCloser: attempt.resp.Body, cancel: cancels[attempt.index]}
+			return attempt.resp, nil
+		}
+	}
+}
+
+// cancelOnClose releases a hedged attempt's context when its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (cc *cancelOnClose) Close() error {
+	err := cc.ReadCloser.Close()
+	cc.cancel()
+	return err
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ImportConversations reads conversations exported from other tools. It
+// accepts a ChatGPT data export (conversations.json), a single OpenAI-style
+// {"messages": [...]} object or bare message array as used by OpenAI and
+// OpenRouter, and JSONL with one such object per line, e.g. from WriteJSONL.
+func ImportConversations(r io.Reader) ([]*Conversation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conversations: %w", err)
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("no conversations found")
+	}
+
+	var whole interface{}
+	if err := json.Unmarshal(data, &whole); err != nil {
+		// Not a single JSON document, so try one object per line
+		return importJSONL(data)
+	}
+
+	switch v := whole.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, errors.New("no conversations found")
+		}
+		if first, ok := v[0].(map[string]interface{}); ok && first["mapping"] != nil {
+			var convs []*Conversation
+			for i, item := range v {
+				conv, err := importChatGPT(item)
+				if err != nil {
+					return nil, fmt.Errorf("conversation %d: %w", i+1, err)
+				}
+				convs = append(convs, conv)
+			}
+			return convs, nil
+		}
+		conv, err := importMessages("", v)
+		if err != nil {
+			return nil, err
+		}
+		return []*Conversation{conv}, nil
+	case map[string]interface{}:
+		conv, err := importObject(v)
+		if err != nil {
+			return nil, err
+		}
+		return []*Conversation{conv}, nil
+	}
+	return nil, fmt.Errorf("unsupported conversation format: top-level %T", whole)
+}
+
+// importJSONL reads one conversation object per non-empty line
+func importJSONL(data []byte) ([]*Conversation, error) {
+	var convs []*Conversation
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal(text, &obj); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		conv, err := importObject(obj)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		convs = append(convs, conv)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read conversations: %w", err)
+	}
+	return convs, nil
+}
+
+// importObject handles a single ChatGPT conversation or a {"messages": [...]} object
+func importObject(obj map[string]interface{}) (*Conversation, error) {
+	if obj["mapping"] != nil {
+		return importChatGPT(obj)
+	}
+	messages, ok := obj["messages"].([]interface{})
+	if !ok {
+		return nil, errors.New(`expected a "messages" array or a ChatGPT "mapping"`)
+	}
+	title, _ := obj["title"].(string)
+	conv, err := importMessages(title, messages)
+	if err != nil {
+		return nil, err
+	}
+	conv.Model, _ = obj["model"].(string)
+	return conv, nil
+}
+
+// importMessages converts OpenAI chat messages
+func importMessages(title string, raw []interface{}) (*Conversation, error) {
+	conv := &Conversation{Title: title}
+	for i, item := range raw {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("message %d is not an object", i+1)
+		}
+		role, _ := m["role"].(string)
+		if role == "" {
+			return nil, fmt.Errorf("message %d has no role", i+1)
+		}
+		msg := ConversationMessage{Role: role, Content: contentText(m["content"])}
+		msg.Name, _ = m["name"].(string)
+		msg.ToolCallID, _ = m["tool_call_id"].(string)
+		if calls, ok := m["tool_calls"]; ok {
+			data, _ := json.Marshal(calls)
+			if err := json.Unmarshal(data, &msg.ToolCalls); err != nil {
+				return nil, fmt.Errorf("message %d: invalid tool_calls: %w", i+1, err)
+			}
+		}
+
+		if role == "system" && conv.System == "" && len(conv.Messages) == 0 {
+			conv.System = msg.Content
+			continue
+		}
+		conv.Messages = append(conv.Messages, msg)
+	}
+	if title == "" {
+		conv.Title = conversationTitle(conv)
+	}
+	return conv, nil
+}
+
+// chatGPTNode is one entry of the message tree in a ChatGPT export
+type chatGPTNode struct {
+	Parent  string `json:"parent"`
+	Message *struct {
+		Author struct {
+			Role string `json:"role"`
+			Name string `json:"name"`
+		} `json:"author"`
+		Content struct {
+			ContentType string        `json:"content_type"`
+			Parts       []interface{} `json:"parts"`
+			Text        string        `json:"text"`
+		} `json:"content"`
+		CreateTime float64 `json:"create_time"`
+		Metadata   struct {
+			IsVisuallyHiddenFromConversation bool   `json:"is_visually_hidden_from_conversation"`
+			ModelSlug                        string `json:"model_slug"`
+		} `json:"metadata"`
+	} `json:"message"`
+}
+
+// importChatGPT follows the current branch of a ChatGPT conversation tree from
+// its leaf back to the root
+func importChatGPT(item interface{}) (*Conversation, error) {
+	data, _ := json.Marshal(item)
+	var export struct {
+		Title       string                 `json:"title"`
+		CurrentNode string                 `json:"current_node"`
+		Mapping     map[string]chatGPTNode `json:"mapping"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid ChatGPT conversation: %w", err)
+	}
+
+	leaf := export.CurrentNode
+	if leaf == "" {
+		// Fall back to the newest message when the export has no current node
+		ids := make([]string, 0, len(export.Mapping))
+		for id, node := range export.Mapping {
+			if node.Message != nil {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return export.Mapping[ids[i]].Message.CreateTime < export.Mapping[ids[j]].Message.CreateTime
+		})
+		if len(ids) > 0 {
+			leaf = ids[len(ids)-1]
+		}
+	}
+
+	var branch []chatGPTNode
+	seen := make(map[string]bool)
+	for id := leaf; id != "" && !seen[id]; {
+		seen[id] = true
+		node, ok := export.Mapping[id]
+		if !ok {
+			return nil, fmt.Errorf("ChatGPT conversation references missing node %s", id)
+		}
+		branch = append(branch, node)
+		id = node.Parent
+	}
+
+	conv := &Conversation{Title: export.Title}
+	for i := len(branch) - 1; i >= 0; i-- {
+		msg := branch[i].Message
+		if msg == nil || msg.Metadata.IsVisuallyHiddenFromConversation {
+			continue
+		}
+		var text []string
+		for _, part := range msg.Content.Parts {
+			text = append(text, contentTexts(part)...)
+		}
+		if msg.Content.Text != "" {
+			text = append(text, msg.Content.Text)
+		}
+		content := strings.Join(text, "\n")
+		if content == "" {
+			continue
+		}
+
+		switch msg.Author.Role {
+		case "system":
+			if conv.System == "" && len(conv.Messages) == 0 {
+				conv.System = content
+			}
+		case "user", "assistant":
+			conv.Messages = append(conv.Messages, ConversationMessage{Role: msg.Author.Role, Content: content})
+			if msg.Metadata.ModelSlug != "" {
+				conv.Model = msg.Metadata.ModelSlug
+			}
+		case "tool":
+			conv.Messages = append(conv.Messages, ConversationMessage{Role: "tool", Name: msg.Author.Name, Content: content})
+		}
+	}
+	if conv.Title == "" {
+		conv.Title = conversationTitle(conv)
+	}
+	return conv, nil
+}
+
+// conversationTitle derives a title from the first user message
+func conversationTitle(conv *Conversation) string {
+	for _, m := range conv.Messages {
+		if m.Role == "user" && m.Content != "" {
+			title := strings.Join(strings.Fields(m.Content), " ")
+			if r := []rune(title); len(r) > 60 {
+				title = string(r[:60]) + "..."
+			}
+			return title
+		}
+	}
+	return "Imported conversation"
+}
+
+// History returns the conversation as chat completion messages, starting with
+// the system prompt, so it can be continued with CreateChatCompletion. Tool
+// messages are dropped because Message cannot carry tool calls.
+func (conv *Conversation) History() []Message {
+	var messages []Message
+	if conv.System != "" {
+		messages = append(messages, Message{Role: "system", Content: conv.System})
+	}
+	for _, m := range conv.Messages {
+		if m.Role == "tool" || (m.Role == "assistant" && m.Content == "") {
+			continue
+		}
+		messages = append(messages, Message{Role: m.Role, Content: m.Content})
+	}
+	return messages
+}
+
+// ReplayIntoSession creates a session of agentID and sends each user message
+// of conv as its own turn, so the local model answers every step again
+func (c *LlamaStackClient) ReplayIntoSession(ctx context.Context, agentID string, conv *Conversation) (*Session, error) {
+	var inputs [][]Message
+	for _, m := range conv.Messages {
+		if m.Role == "user" {
+			inputs = append(inputs, []Message{{Role: "user", Content: m.Content}})
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("conversation has no user messages to replay")
+	}
+
+	session, err := c.CreateSession(ctx, agentID, SessionCreateParams{SessionName: conv.Title})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	session.AgentID = agentID
+	session.SessionName = conv.Title
+
+	if err := c.replayTurns(ctx, agentID, session, inputs); err != nil {
+		return session, err
+	}
+	return session, nil
+}
+
+// SeedSession creates a session of agentID whose first turn hands the model
+// the imported transcript, keeping the original answers instead of
+// regenerating them. The returned session is ready for the next user message.
+func (c *LlamaStackClient) SeedSession(ctx context.Context, agentID string, conv *Conversation) (*Session, error) {
+	var transcript strings.Builder
+	transcript.WriteString("Here is our conversation so far. Continue it from where it left off; reply only \"OK\" to this message.\n\n")
+	for _, m := range conv.History() {
+		if m.Role == "system" || m.Role == "" {
+			continue
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", strings.ToUpper(m.Role[:1])+m.Role[1:], m.Content)
+	}
+
+	session, err := c.CreateSession(ctx, agentID, SessionCreateParams{SessionName: conv.Title})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	session.AgentID = agentID
+	session.SessionName = conv.Title
+
+	inputs := [][]Message{{{Role: "user", Content: strings.TrimSpace(transcript.String())}}}
+	if err := c.replayTurns(ctx, agentID, session, inputs); err != nil {
+		return session, err
+	}
+	return session, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IngestOptions configures IngestDirectory
+type IngestOptions struct {
+	ChunkSizeInTokens int      // defaults to 512, or twice Chunking.MaxTokens
+	BatchSize         int      // documents per insert request; defaults to 20
+	Extensions        []string // only ingest these extensions; all files with a converter when empty
+
+	// Chunking, if set, splits documents on the client with ChunkText before
+	// inserting them, instead of leaving it to the server's fixed-size chunks
+	Chunking *ChunkerOptions
+
+	// Metadata is added to every document, without overriding what the
+	// converter extracted
+	Metadata map[string]interface{}
+
+	// Progress, if set, is called after each file is converted
+	Progress func(path string, documents int, err error)
+}
+
+// IngestResult summarizes an ingestion
+type IngestResult struct {
+	Files     int
+	Documents int
+	Skipped   []string // files no converter is registered for
+}
+
+// IngestDirectory converts every file under dir with the DocumentConverter
+// registered for its mime type and inserts the documents into a vector DB
+// through the rag-tool, so a folder of HTML, Markdown, DOCX, CSV, JSONL and
+// text files becomes searchable. Files without a converter are skipped and
+// listed in the result; files that fail to convert are reported in the
+// returned error without stopping the rest.
+func (c *LlamaStackClient) IngestDirectory(ctx context.Context, dir, vectorDBID string, opts IngestOptions) (*IngestResult, error) {
+	ctx = ensureCorrelationID(ctx)
+	if opts.Chunking != nil {
+		chunking := opts.Chunking.withDefaults()
+		opts.Chunking = &chunking
+		if opts.ChunkSizeInTokens < 2*chunking.MaxTokens {
+			opts.ChunkSizeInTokens = 2 * chunking.MaxTokens // see InsertChunkedDocuments
+		}
+	}
+	if opts.ChunkSizeInTokens <= 0 {
+		opts.ChunkSizeInTokens = 512
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 20
+	}
+	local, err := scanSyncDirectory(dir, "", opts.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(local))
+	for rel := range local {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	result := &IngestResult{}
+	var errs []error
+	var batch []Document
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := c.InsertDocumentsIntoRAG(ctx, RagToolInsertParams{
+			ChunkSizeInTokens: opts.ChunkSizeInTokens,
+			Documents:         batch,
+			VectorDBID:        vectorDBID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to insert documents: %w", err)
+		}
+		result.Documents += len(batch)
+		batch = nil
+		return nil
+	}
+
+	for _, rel := range paths {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		docs, err := convertFile(filepath.Join(dir, filepath.FromSlash(rel)), rel)
+		if opts.Progress != nil {
+			opts.Progress(rel, len(docs), err)
+		}
+		if errors.Is(err, ErrNoConverter) {
+			result.Skipped = append(result.Skipped, rel)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result.Files++
+		if opts.Chunking != nil {
+			docs = ChunkDocuments(docs, *opts.Chunking)
+		}
+
+		for _, doc := range docs {
+			for k, v := range opts.Metadata {
+				if _, ok := doc.Metadata[k]; !ok {
+					doc.Metadata[k] = v
+				}
+			}
+			batch = append(batch, doc)
+			if len(batch) >= opts.BatchSize {
+				if err := flush(); err != nil {
+					return result, errors.Join(append(errs, err)...)
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		errs = append(errs, err)
+	}
+	return result, errors.Join(errs...)
+}
+
+func convertFile(name, rel string) ([]Document, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	return ConvertDocument(rel, file)
+}
+
+// runIngestCommand handles "vector-store ingest DIR VECTOR_DB_ID"
+func runIngestCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("vector-store ingest", flag.ContinueOnError)
+	chunkSize := fs.Int("chunk-size", 512, "chunk size in tokens")
+	overlap := fs.Int("overlap", -1, "chunk on the client with this many tokens of overlap; server-side chunking when negative")
+	ext := fs.String("ext", "", "comma-separated extensions to ingest, e.g. .md,.html")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: vector-store ingest [-chunk-size 512] [-overlap 64] [-ext .md,.html] DIR VECTOR_DB_ID")
+	}
+
+	opts := IngestOptions{
+		ChunkSizeInTokens: *chunkSize,
+		Progress: func(path string, documents int, err error) {
+			switch {
+			case errors.Is(err, ErrNoConverter):
+				fmt.Printf("skipped   %s (no converter)\n", path)
+			case err != nil:
+				fmt.Printf("failed    %s: %v\n", path, err)
+			default:
+				fmt.Printf("converted %s: %d documents\n", path, documents)
+			}
+		},
+	}
+	if *ext != "" {
+		opts.Extensions = strings.Split(*ext, ",")
+	}
+	if *overlap >= 0 {
+		opts.Chunking = &ChunkerOptions{MaxTokens: *chunkSize, OverlapTokens: *overlap}
+	}
+	result, err := client.IngestDirectory(context.Background(), fs.Arg(0), fs.Arg(1), opts)
+	if result != nil {
+		fmt.Printf("Ingested %d documents from %d files (%d skipped)\n", result.Documents, result.Files, len(result.Skipped))
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// integrationQuestion is asked of both the RAG tool and the agent; the
+// inserted corpus states that Ana's dog is Dora
+const integrationQuestion = "Who is Dora's owner?"
+
+// integrationCorpus is inserted alongside the uploaded PDF
+const integrationCorpus = "Eder dog is Bella, a Cavalier King breed. Ana dog is Dora, a Pug breed."
+
+// TestIntegrationRAGAgent runs the PDF -> vector store -> RAG agent flow
+// against a live stack, by default the one from integration/docker-compose.yml
+// or else LLAMASTACK_BASE_URL, and checks retrieval and answer content. It
+// is skipped when nothing listens at the stack's address.
+func TestIntegrationRAGAgent(t *testing.T) {
+	baseURL := "http://localhost:8321"
+	if u := os.Getenv("LLAMASTACK_BASE_URL"); u != "" {
+		baseURL = u
+	}
+	skipUnlessListening(t, baseURL)
+
+	client := NewLlamaStackClient(baseURL, "your-api-key-here")
+	// CPU-only models answer slowly; rely on the idle timeout instead
+	client.HTTPClient.Timeout = 0
+	client.StreamIdleTimeout = 5 * time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+	ctx = WithCorrelationID(ctx, NewCorrelationID())
+	t.Logf("correlation ID %s", CorrelationID(ctx))
+
+	// The stack may still be starting after docker compose up
+	if err := waitForHealthy(ctx, client, 5*time.Minute); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	model := os.Getenv("INTEGRATION_MODEL")
+	if model == "" {
+		var err error
+		model, err = client.GetAvailableModel(ctx)
+		if err != nil {
+			t.Fatalf("no model to test with: %v", err)
+		}
+	}
+	t.Logf("model %s", model)
+
+	file, err := client.UploadFile(ctx, "sample.pdf", FilePurposeAssistants)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if file.ID == "" || file.Bytes == 0 {
+		t.Fatalf("upload: unexpected response %+v", file)
+	}
+	t.Cleanup(func() { client.DeleteFile(context.Background(), file.ID) })
+
+	store, err := client.CreateVectorStoreWithParams(ctx, VectorStoreCreateParams{
+		Name:         fmt.Sprintf("integration-%d", time.Now().Unix()),
+		Metadata:     map[string]interface{}{"source": "go-integration"},
+		ExpiresAfter: ExpireAfterDays(1), // in case the run dies before cleaning up
+	})
+	if err != nil {
+		t.Fatalf("create vector store: %v", err)
+	}
+	t.Cleanup(func() { client.DeleteVectorStore(context.Background(), store.ID) })
+
+	if _, err := client.AttachFileWithAttributes(ctx, store.ID, file.ID, checksumAttributes(nil, file)); err != nil {
+		t.Fatalf("attach file: %v", err)
+	}
+	if err := client.DownloadVectorStoreFile(ctx, store.ID, file.ID, io.Discard); err != nil {
+		t.Fatalf("download file: %v", err)
+	}
+
+	err = client.InsertDocumentsIntoRAG(ctx, RagToolInsertParams{
+		ChunkSizeInTokens: 512,
+		Documents: []Document{
+			{
+				Content:    integrationCorpus,
+				DocumentID: "integration-doc",
+				Metadata:   map[string]interface{}{"source": "integration"},
+			},
+		},
+		VectorDBID: store.ID,
+	})
+	if err != nil {
+		t.Fatalf("rag insert: %v", err)
+	}
+
+	result, err := client.QueryRAG(ctx, RagToolQueryParams{
+		Content:     integrationQuestion,
+		VectorDBIDs: []string{store.ID},
+	})
+	if err != nil {
+		t.Fatalf("rag query: %v", err)
+	}
+	if !queryResultContains(result, "Dora") {
+		t.Fatalf("rag query: retrieved content does not mention Dora: %+v", result.Content)
+	}
+
+	agent, err := client.CreateAgent(ctx, AgentCreateParams{
+		AgentConfig: AgentConfig{
+			Instructions: "Answer using the knowledge_search tool. Be brief.",
+			Model:        model,
+			Name:         "integration-agent",
+			ToolChoice:   "auto",
+			Toolgroups: []interface{}{
+				map[string]interface{}{
+					"name": "builtin::rag",
+					"args": map[string]interface{}{"vector_db_ids": []string{store.ID}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	t.Cleanup(func() { client.DeleteAgent(context.Background(), agent.AgentID) })
+
+	session, err := client.CreateSession(ctx, agent.AgentID, SessionCreateParams{SessionName: "integration"})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	stream := true
+	turn, err := client.CreateTurn(ctx, agent.AgentID, session.SessionID, TurnCreateParams{
+		Messages: []Message{{Role: "user", Content: integrationQuestion}},
+		Stream:   &stream,
+	})
+	if err != nil {
+		t.Fatalf("create turn: %v", err)
+	}
+	answer := turn.OutputMessage.Content
+	if !strings.Contains(strings.ToLower(answer), "ana") {
+		t.Fatalf("agent answer does not name Ana: %q", answer)
+	}
+	t.Logf("agent answered %q", answer)
+}
+
+// skipUnlessListening skips the test when nothing accepts connections at
+// baseURL's host
+func skipUnlessListening(t *testing.T, baseURL string) {
+	t.Helper()
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		t.Fatalf("invalid base URL %q: %v", baseURL, err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, 2*time.Second)
+	if err != nil {
+		t.Skipf("Llama Stack not reachable at %s: %v", baseURL, err)
+	}
+	conn.Close()
+}
+
+// waitForHealthy polls the health endpoint until it succeeds or timeout passes
+func waitForHealthy(ctx context.Context, client *LlamaStackClient, timeout time.Duration) error {
+	poller := Poller[struct{}]{
+		Poll: func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, client.Health(ctx)
+		},
+		Done:      func(struct{}) (bool, error) { return true, nil },
+		Options:   PollOptions[struct{}]{Interval: 5 * time.Second, Multiplier: 1, Timeout: timeout},
+		Retryable: func(error) bool { return true }, // the server may still be starting
+		Logger:    client.logger(),
+	}
+	if _, err := poller.Wait(ctx); err != nil {
+		return fmt.Errorf("server not healthy: %w", err)
+	}
+	return nil
+}
+
+// queryResultContains reports whether any chunk of a RAG result contains s
+func queryResultContains(result *QueryResult, s string) bool {
+	for _, chunk := range ParseQueryResult(result) {
+		if strings.Contains(chunk.Content, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadProfileKind selects how the request rate changes over a load test
+type LoadProfileKind string
+
+const (
+	LoadConstant LoadProfileKind = "constant" // Rate for the whole Duration
+	LoadStep     LoadProfileKind = "step"     // Rate, then StepRate more at each of Steps equal steps
+	LoadSpike    LoadProfileKind = "spike"    // Rate, SpikeRate for SpikeDuration in the middle, then Rate again
+)
+
+// LoadProfile describes the arrival rate of a load test. Requests are sent
+// at the scheduled rate whether or not earlier ones have finished, so a
+// server that cannot keep up shows growing latency and errors instead of
+// silently slowing the test down.
+type LoadProfile struct {
+	Kind          LoadProfileKind
+	Rate          float64 // requests per second
+	Duration      time.Duration
+	StepRate      float64       // step: rate added at each step
+	Steps         int           // step: number of steps; defaults to 5
+	SpikeRate     float64       // spike: rate during the spike
+	SpikeDuration time.Duration // spike: defaults to a fifth of Duration
+}
+
+// LoadStage is a period of constant request rate within a profile
+type LoadStage struct {
+	Name     string        `json:"name"`
+	Rate     float64       `json:"rate"`
+	Duration time.Duration `json:"-"`
+}
+
+// Stages expands the profile into its constant-rate stages
+func (p LoadProfile) Stages() ([]LoadStage, error) {
+	if p.Duration <= 0 {
+		return nil, errors.New("load profile duration must be positive")
+	}
+	if p.Rate < 0 {
+		return nil, errors.New("load profile rate must not be negative")
+	}
+	switch p.Kind {
+	case LoadConstant, "":
+		if p.Rate == 0 {
+			return nil, errors.New("constant load profile needs a rate")
+		}
+		return []LoadStage{{Name: "constant", Rate: p.Rate, Duration: p.Duration}}, nil
+
+	case LoadStep:
+		steps := p.Steps
+		if steps <= 0 {
+			steps = 5
+		}
+		if p.StepRate <= 0 {
+			return nil, errors.New("step load profile needs a positive step rate")
+		}
+		stages := make([]LoadStage, steps)
+		for i := range stages {
+			stages[i] = LoadStage{
+				Name:     fmt.Sprintf("step %d", i+1),
+				Rate:     p.Rate + float64(i)*p.StepRate,
+				Duration: p.Duration / time.Duration(steps),
+			}
+		}
+		return stages, nil
+
+	case LoadSpike:
+		spike := p.SpikeDuration
+		if spike <= 0 {
+			spike = p.Duration / 5
+		}
+		if spike >= p.Duration {
+			return nil, errors.New("spike duration must be shorter than the profile duration")
+		}
+		if p.SpikeRate <= p.Rate {
+			return nil, errors.New("spike rate must exceed the base rate")
+		}
+		before := (p.Duration - spike) / 2
+		return []LoadStage{
+			{Name: "before spike", Rate: p.Rate, Duration: before},
+			{Name: "spike", Rate: p.SpikeRate, Duration: spike},
+			{Name: "after spike", Rate: p.Rate, Duration: p.Duration - spike - before},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown load profile %q", p.Kind)
+}
+
+// LoadRequestKind is one kind of request in a load test mix
+type LoadRequestKind string
+
+const (
+	LoadChat       LoadRequestKind = "chat"
+	LoadRAGQuery   LoadRequestKind = "rag"
+	LoadEmbeddings LoadRequestKind = "embeddings"
+)
+
+// LoadMix weighs the kinds of requests a load test sends, e.g. {Chat: 7,
+// RAG: 2, Embeddings: 1}. Requests are interleaved in proportion to the
+// weights rather than drawn at random, so runs are repeatable.
+type LoadMix struct {
+	Chat       int
+	RAG        int
+	Embeddings int
+}
+
+// LoadTestOptions configures RunLoadTest
+type LoadTestOptions struct {
+	Profile LoadProfile
+	Mix     LoadMix // defaults to chat only
+
+	Model          string        // chat model
+	Prompts        []BenchPrompt // cycled through; RAG queries and embeddings use the last message
+	VectorDBIDs    []string      // required when the mix has RAG queries
+	EmbeddingModel string        // required when the mix has embeddings
+	MaxTokens      int           // optional max_tokens for chat requests
+
+	MaxInFlight int           // requests outstanding at once; arrivals beyond it are dropped; defaults to 64
+	Timeout     time.Duration // per request; defaults to 60s
+
+	// ErrorBudget stops the test early once more than this fraction of at
+	// least MinRequests completed requests failed; disabled when zero
+	ErrorBudget float64
+	MinRequests int // defaults to 20
+}
+
+// LoadStats summarizes a set of load test requests
+type LoadStats struct {
+	Requests  int          `json:"requests"`
+	Errors    int          `json:"errors"`
+	ErrorRate float64      `json:"error_rate"`
+	Latency   LatencyStats `json:"latency"`
+
+	latencies []time.Duration
+}
+
+func (s *LoadStats) record(latency time.Duration, err error) {
+	s.Requests++
+	if err != nil {
+		s.Errors++
+		return
+	}
+	s.latencies = append(s.latencies, latency)
+}
+
+func (s *LoadStats) finish() {
+	if s.Requests > 0 {
+		s.ErrorRate = float64(s.Errors) / float64(s.Requests)
+	}
+	s.Latency = latencyStats(s.latencies)
+}
+
+// LoadStageResult reports one stage of a load test
+type LoadStageResult struct {
+	LoadStage
+	AchievedRate float64 `json:"achieved_rate"` // successful requests per second
+	Dropped      int     `json:"dropped"`
+	LoadStats
+}
+
+// LoadTestResult summarizes a load test
+type LoadTestResult struct {
+	Profile         LoadProfileKind                `json:"profile"`
+	Duration        time.Duration                  `json:"-"`
+	DurationSeconds float64                        `json:"duration_seconds"`
+	Total           LoadStats                      `json:"total"`
+	ByKind          map[LoadRequestKind]*LoadStats `json:"by_kind"`
+	Stages          []LoadStageResult              `json:"stages"`
+	Dropped         int                            `json:"dropped"`
+	BudgetExceeded  bool                           `json:"budget_exceeded"`
+
+	// MaxSustainedRate is the highest stage rate the server kept up with:
+	// within the error budget (or error-free without one), no drops, and
+	// at least 90% of the target rate achieved
+	MaxSustainedRate float64 `json:"max_sustained_rate"`
+}
+
+// RunLoadTest sends a mix of chat completions, RAG queries and embedding
+// requests at the rates of a LoadProfile and reports latency and errors per
+// stage and per kind. The stage results of a step profile show where latency
+// starts to climb, which is the number to size a deployment by. Requests are
+// sent without the demo's request logging.
+func (c *LlamaStackClient) RunLoadTest(ctx context.Context, opts LoadTestOptions) (*LoadTestResult, error) {
+	stages, err := opts.Profile.Stages()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Mix == (LoadMix{}) {
+		opts.Mix.Chat = 1
+	}
+	if opts.Mix.Chat < 0 || opts.Mix.RAG < 0 || opts.Mix.Embeddings < 0 {
+		return nil, errors.New("request mix weights must not be negative")
+	}
+	if len(opts.Prompts) == 0 {
+		return nil, errors.New("no prompts")
+	}
+	if opts.Mix.Chat > 0 && opts.Model == "" {
+		return nil, errors.New("chat requests need a model")
+	}
+	if opts.Mix.RAG > 0 && len(opts.VectorDBIDs) == 0 {
+		return nil, errors.New("RAG queries need vector DB ids")
+	}
+	if opts.Mix.Embeddings > 0 && opts.EmbeddingModel == "" {
+		return nil, errors.New("embedding requests need an embedding model")
+	}
+	if opts.MaxInFlight <= 0 {
+		opts.MaxInFlight = 64
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 60 * time.Second
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 20
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := &LoadTestResult{
+		Profile: opts.Profile.Kind,
+		ByKind:  make(map[LoadRequestKind]*LoadStats),
+		Stages:  make([]LoadStageResult, len(stages)),
+	}
+	if result.Profile == "" {
+		result.Profile = LoadConstant
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	inFlight := make(chan struct{}, opts.MaxInFlight)
+	mix := newLoadMixer(opts.Mix)
+	start := time.Now()
+	sent := 0
+
+	record := func(stage int, kind LoadRequestKind, latency time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Requests cut short by the end of the test are not server failures
+		if err != nil && runCtx.Err() != nil && errors.Is(err, context.Canceled) {
+			return
+		}
+		result.Total.record(latency, err)
+		result.Stages[stage].record(latency, err)
+		if result.ByKind[kind] == nil {
+			result.ByKind[kind] = &LoadStats{}
+		}
+		result.ByKind[kind].record(latency, err)
+		total := &result.Total
+		if opts.ErrorBudget > 0 && total.Requests >= opts.MinRequests &&
+			float64(total.Errors)/float64(total.Requests) > opts.ErrorBudget {
+			result.BudgetExceeded = true
+			cancel()
+		}
+	}
+
+stages:
+	for i, stage := range stages {
+		result.Stages[i].LoadStage = stage
+		stageEnd := time.Now().Add(stage.Duration)
+		if stage.Rate <= 0 {
+			// An idle stage, e.g. the first step of a ramp starting at zero
+			if !sleepUntil(runCtx, stageEnd) {
+				break
+			}
+			continue
+		}
+		interval := time.Duration(float64(time.Second) / stage.Rate)
+		for next := time.Now(); next.Before(stageEnd); next = next.Add(interval) {
+			if !sleepUntil(runCtx, next) {
+				break stages
+			}
+			select {
+			case inFlight <- struct{}{}:
+			default:
+				mu.Lock()
+				result.Stages[i].Dropped++
+				result.Dropped++
+				mu.Unlock()
+				continue
+			}
+			kind := mix.next()
+			prompt := opts.Prompts[sent%len(opts.Prompts)]
+			sent++
+			wg.Add(1)
+			go func(stage int) {
+				defer wg.Done()
+				defer func() { <-inFlight }()
+				reqCtx, cancelReq := context.WithTimeout(runCtx, opts.Timeout)
+				defer cancelReq()
+				began := time.Now()
+				err := c.loadRequest(reqCtx, kind, prompt, opts)
+				record(stage, kind, time.Since(began), err)
+			}(i)
+		}
+		if !sleepUntil(runCtx, stageEnd) {
+			break
+		}
+	}
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+	result.DurationSeconds = result.Duration.Seconds()
+	result.Total.finish()
+	for _, stats := range result.ByKind {
+		stats.finish()
+	}
+	for i := range result.Stages {
+		s := &result.Stages[i]
+		s.finish()
+		if secs := s.Duration.Seconds(); secs > 0 {
+			s.AchievedRate = float64(s.Requests-s.Errors) / secs
+		}
+		withinBudget := s.Errors == 0 || (opts.ErrorBudget > 0 && s.ErrorRate <= opts.ErrorBudget)
+		if s.Requests > 0 && withinBudget && s.Dropped == 0 && s.AchievedRate >= 0.9*s.Rate && s.Rate > result.MaxSustainedRate {
+			result.MaxSustainedRate = s.Rate
+		}
+	}
+	if result.BudgetExceeded {
+		return result, fmt.Errorf("error budget of %.1f%% exceeded: %d of %d requests failed",
+			100*opts.ErrorBudget, result.Total.Errors, result.Total.Requests)
+	}
+	return result, ctx.Err()
+}
+
+// sleepUntil waits for t, returning false if ctx is done first
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// loadRequest sends one request of kind and discards the response
+func (c *LlamaStackClient) loadRequest(ctx context.Context, kind LoadRequestKind, prompt BenchPrompt, opts LoadTestOptions) error {
+	messages := prompt.messages()
+	text := messages[len(messages)-1].Content
+	switch kind {
+	case LoadRAGQuery:
+		var result QueryResult
+		return c.postJSON(ctx, "/v1/tool-runtime/rag-tool/query", RagToolQueryParams{Content: text, VectorDBIDs: opts.VectorDBIDs}, &result)
+	case LoadEmbeddings:
+		var resp EmbeddingsResponse
+		return c.postJSON(ctx, "/v1/openai/v1/embeddings", EmbeddingsRequest{Model: opts.EmbeddingModel, Input: []string{text}}, &resp)
+	default:
+		params := ChatCompletionParams{Model: opts.Model, Messages: messages}
+		if opts.MaxTokens > 0 {
+			params.MaxTokens = &opts.MaxTokens
+		}
+		var resp APIResponse
+		return c.postJSON(ctx, "/v1/openai/v1/chat/completions", params, &resp)
+	}
+}
+
+// loadMixer interleaves request kinds by weight with smooth weighted
+// round-robin, so 7:2:1 spreads the RAG queries and embeddings evenly
+// between the chats
+type loadMixer struct {
+	kinds   []LoadRequestKind
+	weights []int
+	current []int
+	total   int
+}
+
+func newLoadMixer(mix LoadMix) *loadMixer {
+	m := &loadMixer{}
+	for _, kw := range []struct {
+		kind   LoadRequestKind
+		weight int
+	}{{LoadChat, mix.Chat}, {LoadRAGQuery, mix.RAG}, {LoadEmbeddings, mix.Embeddings}} {
+		if kw.weight > 0 {
+			m.kinds = append(m.kinds, kw.kind)
+			m.weights = append(m.weights, kw.weight)
+			m.total += kw.weight
+		}
+	}
+	m.current = make([]int, len(m.kinds))
+	return m
+}
+
+func (m *loadMixer) next() LoadRequestKind {
+	best := 0
+	for i, w := range m.weights {
+		m.current[i] += w
+		if m.current[i] > m.current[best] {
+			best = i
+		}
+	}
+	m.current[best] -= m.total
+	return m.kinds[best]
+}
+
+// WriteJSON writes the result as indented JSON
+func (r *LoadTestResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteSummary prints a per-stage and per-kind table of the result
+func (r *LoadTestResult) WriteSummary(w io.Writer) {
+	fmt.Fprintf(w, "Profile: %s, %.1fs, %d requests, %d errors (%.1f%%), %d dropped\n",
+		r.Profile, r.DurationSeconds, r.Total.Requests, r.Total.Errors, 100*r.Total.ErrorRate, r.Dropped)
+	if r.BudgetExceeded {
+		fmt.Fprintln(w, "Stopped early: error budget exceeded")
+	}
+	fmt.Fprintf(w, "%-14s %8s %9s %6s %7s %9s %9s %9s\n", "stage", "target/s", "achieved", "errors", "dropped", "p50 ms", "p95 ms", "p99 ms")
+	for _, s := range r.Stages {
+		fmt.Fprintf(w, "%-14s %8.1f %9.1f %6d %7d %9.1f %9.1f %9.1f\n",
+			s.Name, s.Rate, s.AchievedRate, s.Errors, s.Dropped, s.Latency.P50, s.Latency.P95, s.Latency.P99)
+	}
+	for _, kind := range []LoadRequestKind{LoadChat, LoadRAGQuery, LoadEmbeddings} {
+		if s := r.ByKind[kind]; s != nil {
+			fmt.Fprintf(w, "%-14s %d requests, %d errors, p50 %.1f ms, p95 %.1f ms\n", kind, s.Requests, s.Errors, s.Latency.P50, s.Latency.P95)
+		}
+	}
+	fmt.Fprintf(w, "Max sustained rate: %.1f requests/s\n", r.MaxSustainedRate)
+}
+
+// parseLoadMix parses "chat=7,rag=2,embeddings=1"
+func parseLoadMix(s string) (LoadMix, error) {
+	var mix LoadMix
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		weight, err := strconv.Atoi(value)
+		if !ok || err != nil || weight < 0 {
+			return mix, fmt.Errorf("invalid mix entry %q", part)
+		}
+		switch LoadRequestKind(name) {
+		case LoadChat:
+			mix.Chat = weight
+		case LoadRAGQuery:
+			mix.RAG = weight
+		case LoadEmbeddings:
+			mix.Embeddings = weight
+		default:
+			return mix, fmt.Errorf("unknown request kind %q", name)
+		}
+	}
+	return mix, nil
+}
+
+// runLoadTestCommand handles "loadtest -model X -profile step -rate 1 -step-rate 2 -duration 5m"
+func runLoadTestCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	profile := fs.String("profile", "constant", "load profile: constant, step or spike")
+	rate := fs.Float64("rate", 1, "requests per second; the first step or the spike's base rate")
+	duration := fs.Duration("duration", time.Minute, "total test duration")
+	stepRate := fs.Float64("step-rate", 1, "step: rate added at each step")
+	steps := fs.Int("steps", 5, "step: number of steps")
+	spikeRate := fs.Float64("spike-rate", 10, "spike: rate during the spike")
+	spikeDuration := fs.Duration("spike-duration", 0, "spike: spike length; a fifth of -duration by default")
+	mixFlag := fs.String("mix", "chat=1", "request mix, e.g. chat=7,rag=2,embeddings=1")
+	model := fs.String("model", "", "chat model")
+	embeddingModel := fs.String("embedding-model", "", "embedding model")
+	vectorDBs := fs.String("vector-db", "", "comma-separated vector DB ids for RAG queries")
+	promptFile := fs.String("prompt-file", "", "JSONL file with a \"prompt\" or \"messages\" per line")
+	maxTokens := fs.Int("max-tokens", 0, "max_tokens for chat requests")
+	maxInFlight := fs.Int("max-in-flight", 64, "requests outstanding at once")
+	budget := fs.Float64("error-budget", 0, "stop when more than this fraction of requests fail, e.g. 0.05")
+	format := fs.String("format", "text", "report format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *promptFile == "" || fs.NArg() != 0 {
+		return errors.New("usage: loadtest -prompt-file prompts.jsonl [-model MODEL] [-profile constant|step|spike] [-rate 1] [-duration 1m] [-mix chat=7,rag=2,embeddings=1] [-vector-db ID] [-embedding-model MODEL] [-error-budget 0.05] [-format text|json]")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown report format %q", *format)
+	}
+
+	mix, err := parseLoadMix(*mixFlag)
+	if err != nil {
+		return err
+	}
+	prompts, err := LoadBenchPrompts(*promptFile)
+	if err != nil {
+		return err
+	}
+	opts := LoadTestOptions{
+		Profile: LoadProfile{
+			Kind:          LoadProfileKind(*profile),
+			Rate:          *rate,
+			Duration:      *duration,
+			StepRate:      *stepRate,
+			Steps:         *steps,
+			SpikeRate:     *spikeRate,
+			SpikeDuration: *spikeDuration,
+		},
+		Mix:            mix,
+		Model:          *model,
+		Prompts:        prompts,
+		EmbeddingModel: *embeddingModel,
+		MaxTokens:      *maxTokens,
+		MaxInFlight:    *maxInFlight,
+		ErrorBudget:    *budget,
+	}
+	if *vectorDBs != "" {
+		opts.VectorDBIDs = strings.Split(*vectorDBs, ",")
+	}
+
+	client.ensureIdleConns(*maxInFlight)
+	result, err := client.RunLoadTest(context.Background(), opts)
+	if result != nil {
+		if *format == "json" {
+			if werr := result.WriteJSON(os.Stdout); werr != nil {
+				return fmt.Errorf("failed to write report: %w", werr)
+			}
+		} else {
+			result.WriteSummary(os.Stdout)
+		}
+	}
+	return err
+}
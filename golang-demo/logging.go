@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bodies longer than this are cut short in debug logs and not searched for
+// token usage
+const logBodyLimit = 64 << 10
+
+// WithLogger makes the client log through logger: a summary of every call
+// (endpoint, status, duration and token usage) at info level, request and
+// response bodies at debug level, and failures and retries at warn level.
+// Credential headers are always redacted.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.Logger = logger
+	}
+}
+
+// discardLogger drops everything; its level is above every record's, so
+// nothing is formatted. (slog.DiscardHandler needs Go 1.24.)
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+
+// logger returns the client's Logger, or one that discards everything
+func (c *LlamaStackClient) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return discardLogger
+}
+
+// logRequest logs the request about to be sent at debug level
+func (c *LlamaStackClient) logRequest(req *http.Request) {
+	logger, ctx := c.logger(), req.Context()
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []any{"method", req.Method, "url", req.URL.String(), "headers", sanitizeHeaders(req.Header)}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if err == nil && len(body) > 0 {
+			attrs = append(attrs, "body", logBody(req.Header.Get("Content-Type"), body))
+		}
+	}
+	logger.DebugContext(ctx, "request", attrs...)
+}
+
+// logResponse logs a failed call right away. For a response it wraps the
+// body so the call summary is logged when the caller closes it, once the
+// duration and any token usage in the body are known.
+func (c *LlamaStackClient) logResponse(req *http.Request, start time.Time, resp *http.Response, err error) (*http.Response, error) {
+	logger, ctx := c.logger(), req.Context()
+	if err != nil {
+		attrs := []any{"method", req.Method, "endpoint", req.URL.Path, "duration", time.Since(start), "error", err}
+		logger.WarnContext(ctx, "call failed", append(attrs, callIDAttrs(req, nil)...)...)
+		return resp, err
+	}
+	level := slog.LevelInfo
+	if resp.StatusCode >= 400 {
+		level = slog.LevelWarn
+	}
+	if !logger.Enabled(ctx, level) {
+		return resp, nil
+	}
+	resp.Body = &loggedBody{ReadCloser: resp.Body, logger: logger, level: level, req: req, resp: resp, start: start}
+	return resp, nil
+}
+
+// loggedBody keeps the start of a response body and logs the call when closed
+type loggedBody struct {
+	io.ReadCloser
+	logger *slog.Logger
+	level  slog.Level
+	req    *http.Request
+	resp   *http.Response
+	start  time.Time
+
+	buf  bytes.Buffer
+	once sync.Once
+}
+
+func (lb *loggedBody) Read(p []byte) (int, error) {
+	n, err := lb.ReadCloser.Read(p)
+	if room := logBodyLimit + 1 - lb.buf.Len(); room > 0 {
+		lb.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+func (lb *loggedBody) Close() error {
+	err := lb.ReadCloser.Close()
+	lb.once.Do(lb.log)
+	return err
+}
+
+func (lb *loggedBody) log() {
+	ctx := lb.req.Context()
+	if ctx.Err() != nil {
+		ctx = context.WithoutCancel(ctx) // still log calls the caller cancelled
+	}
+	body := lb.buf.Bytes()
+	contentType := lb.resp.Header.Get("Content-Type")
+
+	attrs := []any{
+		"method", lb.req.Method,
+		"endpoint", lb.req.URL.Path,
+		"status", lb.resp.StatusCode,
+		"duration", time.Since(lb.start),
+	}
+	attrs = append(attrs, callIDAttrs(lb.req, lb.resp)...)
+	if seed := callSeed(ctx); seed != nil {
+		attrs = append(attrs, "seed", *seed)
+	}
+	if cost := callCost(ctx); cost != nil {
+		attrs = append(attrs, "cost", cost.String())
+	}
+	if len(body) <= logBodyLimit {
+		if usage := usageFromBody(contentType, body); usage != nil {
+			attrs = append(attrs, slog.Group("tokens", "prompt", usage.PromptTokens, "completion", usage.CompletionTokens, "total", usage.TotalTokens))
+		}
+	}
+	lb.logger.Log(ctx, lb.level, "call", attrs...)
+
+	if lb.logger.Enabled(ctx, slog.LevelDebug) {
+		lb.logger.DebugContext(ctx, "response",
+			"endpoint", lb.req.URL.Path,
+			"headers", sanitizeHeaders(lb.resp.Header),
+			"body", logBody(contentType, body),
+		)
+	}
+}
+
+// callIDAttrs returns the correlation and server request IDs of a call, for
+// whichever of them are known
+func callIDAttrs(req *http.Request, resp *http.Response) []any {
+	var attrs []any
+	if id := req.Header.Get(CorrelationIDHeader); id != "" {
+		attrs = append(attrs, "correlation_id", id)
+	}
+	if resp != nil {
+		if id := resp.Header.Get("X-Request-Id"); id != "" {
+			attrs = append(attrs, "request_id", id)
+		}
+	}
+	return attrs
+}
+
+// TokenUsage is the token usage reported by chat and completion endpoints
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// usageFromBody finds token usage in a JSON body, or in the last event of an
+// event stream that reports it
+func usageFromBody(contentType string, body []byte) *TokenUsage {
+	var envelope struct {
+		Usage *TokenUsage `json:"usage"`
+	}
+	if !strings.HasPrefix(contentType, "text/event-stream") {
+		if json.Unmarshal(body, &envelope) != nil {
+			return nil
+		}
+		return envelope.Usage
+	}
+
+	var usage *TokenUsage
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64<<10), logBodyLimit)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		envelope.Usage = nil
+		if json.Unmarshal([]byte(strings.TrimSpace(data)), &envelope) == nil && envelope.Usage != nil {
+			usage = envelope.Usage
+		}
+	}
+	return usage
+}
+
+// logBody renders a body for a debug log: JSON on one line, uploads as a
+// list of parts, and anything longer than logBodyLimit cut short
+func logBody(contentType string, body []byte) string {
+	var compact bytes.Buffer
+	if json.Compact(&compact, body) == nil {
+		body = compact.Bytes()
+	} else if !strings.HasPrefix(contentType, "text/event-stream") {
+		return renderDryRunBody(contentType, body)
+	}
+	if len(body) > logBodyLimit {
+		return string(body[:logBodyLimit]) + "... [truncated]"
+	}
+	return string(body)
+}
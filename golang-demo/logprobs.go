@@ -0,0 +1,57 @@
+package main
+
+import "math"
+
+// ChoiceLogprobs represents the per-token log probabilities of a choice
+type ChoiceLogprobs struct {
+	Content []TokenLogprob `json:"content"`
+	Refusal []TokenLogprob `json:"refusal,omitempty"`
+}
+
+// TokenLogprob represents the log probability of one generated token
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Bytes       []int        `json:"bytes,omitempty"`
+	Logprob     float64      `json:"logprob"`
+	TopLogprobs []TopLogprob `json:"top_logprobs,omitempty"`
+}
+
+// TopLogprob represents one of the most likely alternatives for a token
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Bytes   []int   `json:"bytes,omitempty"`
+	Logprob float64 `json:"logprob"`
+}
+
+// SumLogprob returns the total log probability of the generated content
+func (l *ChoiceLogprobs) SumLogprob() float64 {
+	if l == nil {
+		return 0
+	}
+	var sum float64
+	for _, t := range l.Content {
+		sum += t.Logprob
+	}
+	return sum
+}
+
+// MeanLogprob returns the average per-token log probability, or 0 without tokens
+func (l *ChoiceLogprobs) MeanLogprob() float64 {
+	if l == nil || len(l.Content) == 0 {
+		return 0
+	}
+	return l.SumLogprob() / float64(len(l.Content))
+}
+
+// Perplexity returns exp(-mean logprob); lower means the model was more confident
+func (l *ChoiceLogprobs) Perplexity() float64 {
+	if l == nil || len(l.Content) == 0 {
+		return 0
+	}
+	return math.Exp(-l.MeanLogprob())
+}
+
+// Probability converts a token's log probability to a probability in [0, 1]
+func (t TokenLogprob) Probability() float64 {
+	return math.Exp(t.Logprob)
+}
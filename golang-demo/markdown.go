@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ANSI styles used by MarkdownRenderer
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "1"
+	ansiDim     = "2"
+	ansiItalic  = "3"
+	ansiHeading = "1;35"
+	ansiCode    = "36"
+	ansiBullet  = "33"
+	ansiKeyword = "1;34"
+	ansiString  = "32"
+	ansiNumber  = "33"
+	ansiComment = "2;3"
+)
+
+// MarkdownRenderer renders markdown to a terminal as it streams in. Inline
+// text is written as soon as it arrives; only the few characters that decide
+// what a line is (a heading, list item, quote, rule or code fence) and
+// emphasis markers are held back until the next character settles them.
+// Lines inside code fences are written whole with simple syntax
+// highlighting. Call Flush when the stream ends.
+type MarkdownRenderer struct {
+	w   io.Writer
+	out bytes.Buffer
+	err error
+
+	partial []byte // incomplete UTF-8 sequence from the previous Write
+
+	atLineStart bool
+	head        []rune // start of the current line, held until its block type is known
+	fenceLine   bool   // the current line opens or closes a code fence
+	line        []rune // the current line inside a fence
+
+	inFence    bool
+	fence      string // the marker that opened the fence, ``` or ~~~
+	fenceLang  string
+	lineStyle  string // style of the whole line, e.g. a heading
+	bold       bool
+	italic     bool
+	code       bool
+	pending    rune // emphasis marker held to see whether it repeats
+	pendingN   int
+	prev       rune   // last rune written, to tell word-internal underscores apart
+	styleShown string // style currently active on the terminal
+}
+
+// NewMarkdownRenderer returns a renderer writing ANSI-styled text to w
+func NewMarkdownRenderer(w io.Writer) *MarkdownRenderer {
+	return &MarkdownRenderer{w: w, atLineStart: true}
+}
+
+// Write renders the next piece of markdown
+func (m *MarkdownRenderer) Write(p []byte) (int, error) {
+	data := append(m.partial, p...)
+	m.partial = nil
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && !utf8.FullRune(data) {
+			m.partial = append([]byte(nil), data...)
+			break
+		}
+		m.feed(r)
+		data = data[size:]
+	}
+	m.flushOut()
+	return len(p), m.err
+}
+
+// WriteString renders the next piece of markdown
+func (m *MarkdownRenderer) WriteString(s string) (int, error) {
+	return m.Write([]byte(s))
+}
+
+// Flush renders anything held back and resets the terminal style
+func (m *MarkdownRenderer) Flush() error {
+	switch {
+	case m.inFence || m.fenceLine:
+		if len(m.line) > 0 {
+			m.endFenceLine()
+		}
+	case len(m.head) > 0:
+		m.startLine(true)
+	}
+	m.resolvePending(0)
+	m.bold, m.italic, m.code, m.lineStyle = false, false, false, ""
+	m.applyStyle()
+	m.flushOut()
+	return m.err
+}
+
+func (m *MarkdownRenderer) flushOut() {
+	if m.out.Len() == 0 || m.err != nil {
+		m.out.Reset()
+		return
+	}
+	_, m.err = m.w.Write(m.out.Bytes())
+	m.out.Reset()
+}
+
+func (m *MarkdownRenderer) feed(r rune) {
+	if r == '\r' {
+		return
+	}
+	if m.inFence || m.fenceLine {
+		if r == '\n' {
+			m.endFenceLine()
+			return
+		}
+		m.line = append(m.line, r)
+		return
+	}
+	if m.atLineStart {
+		if r == '\n' {
+			m.startLine(true)
+			m.newline()
+			return
+		}
+		m.head = append(m.head, r)
+		if len(m.head) < 8 && isBlockPrefix(string(m.head)) {
+			return
+		}
+		m.startLine(false)
+		return
+	}
+	m.inline(r)
+}
+
+// isBlockPrefix reports whether s could still grow into a block marker
+func isBlockPrefix(s string) bool {
+	s = strings.TrimLeft(s, " ")
+	if s == "" {
+		return true
+	}
+	first := s[0]
+	switch {
+	case first == '#':
+		return strings.Trim(s, "#") == ""
+	case first == '`' || first == '~':
+		return strings.Trim(s, s[:1]) == "" && len(s) < 3
+	case first == '-' || first == '*' || first == '+' || first == '_':
+		return strings.Trim(s, s[:1]) == ""
+	case first == '>':
+		return s == ">"
+	case first >= '0' && first <= '9':
+		rest := strings.TrimLeft(s, "0123456789")
+		return rest == "" || rest == "." || rest == ")"
+	}
+	return false
+}
+
+// startLine decides the block type of the current line from its head,
+// writes the block marker and passes the rest of the head to inline
+// rendering. complete is set when the line ended within the head.
+func (m *MarkdownRenderer) startLine(complete bool) {
+	head := string(m.head)
+	m.head = m.head[:0]
+	m.atLineStart = false
+	indent := len(head) - len(strings.TrimLeft(head, " "))
+	s := head[indent:]
+
+	switch {
+	case strings.HasPrefix(s, "```") || strings.HasPrefix(s, "~~~"):
+		m.fenceLine = true
+		m.line = []rune(head)
+		return
+
+	case strings.HasPrefix(s, "#"):
+		level := len(s) - len(strings.TrimLeft(s, "#"))
+		if level <= 6 && (len(s) > level && s[level] == ' ') {
+			m.lineStyle = ansiHeading
+			m.applyStyle()
+			m.feedInline(strings.TrimLeft(s[level:], " "))
+			return
+		}
+
+	case len(s) >= 3 && strings.Trim(s, s[:1]) == "" && strings.Contains("-*_", s[:1]) && complete:
+		m.styled(ansiDim, strings.Repeat("─", 40))
+		return
+
+	case len(s) >= 2 && strings.Contains("-*+", s[:1]) && s[1] == ' ':
+		m.out.WriteString(strings.Repeat(" ", indent))
+		m.styled(ansiBullet, "• ")
+		m.feedInline(s[2:])
+		return
+
+	case strings.HasPrefix(s, ">"):
+		m.out.WriteString(strings.Repeat(" ", indent))
+		m.styled(ansiDim, "│ ")
+		m.lineStyle = ansiDim
+		m.applyStyle()
+		m.feedInline(strings.TrimPrefix(s[1:], " "))
+		return
+
+	case len(s) > 0 && s[0] >= '0' && s[0] <= '9':
+		digits := len(s) - len(strings.TrimLeft(s, "0123456789"))
+		if len(s) > digits+1 && (s[digits] == '.' || s[digits] == ')') && s[digits+1] == ' ' {
+			m.out.WriteString(strings.Repeat(" ", indent))
+			m.styled(ansiBullet, s[:digits+1]+" ")
+			m.feedInline(s[digits+2:])
+			return
+		}
+	}
+	m.feedInline(head)
+}
+
+func (m *MarkdownRenderer) feedInline(s string) {
+	for _, r := range s {
+		m.inline(r)
+	}
+}
+
+// endFenceLine writes a complete line inside or around a code fence
+func (m *MarkdownRenderer) endFenceLine() {
+	line := string(m.line)
+	m.line = m.line[:0]
+	m.fenceLine = false
+	trimmed := strings.TrimSpace(line)
+
+	switch {
+	case !m.inFence:
+		m.inFence = true
+		m.fence = trimmed[:3]
+		m.fenceLang = strings.ToLower(strings.TrimSpace(strings.TrimLeft(trimmed, m.fence[:1])))
+		label := m.fenceLang
+		if label == "" {
+			label = "code"
+		}
+		m.styled(ansiDim, "┌─ "+label)
+	case strings.HasPrefix(trimmed, m.fence) && strings.Trim(trimmed, m.fence[:1]) == "":
+		m.inFence = false
+		m.styled(ansiDim, "└─")
+	default:
+		m.styled(ansiDim, "│ ")
+		m.out.WriteString(highlightCode(m.fenceLang, line))
+	}
+	m.out.WriteByte('\n')
+	m.atLineStart = true
+}
+
+// inline renders one rune of inline text: emphasis, code spans and text
+func (m *MarkdownRenderer) inline(r rune) {
+	if r == '\n' {
+		m.resolvePending(0)
+		m.newline()
+		return
+	}
+	if m.code {
+		if r == '`' {
+			m.code = false
+			m.applyStyle()
+			return
+		}
+		m.write(r)
+		return
+	}
+	if r == '*' || r == '_' {
+		if m.pendingN > 0 && r == m.pending && m.pendingN < 3 {
+			m.pendingN++
+			return
+		}
+		m.resolvePending(r)
+		m.pending, m.pendingN = r, 1
+		return
+	}
+	m.resolvePending(r)
+	if r == '`' {
+		m.code = true
+		m.applyStyle()
+		return
+	}
+	m.write(r)
+}
+
+// resolvePending turns a held emphasis marker into a style change or
+// literal text, now that the rune after it is known (0 at the end of a line)
+func (m *MarkdownRenderer) resolvePending(next rune) {
+	n, marker := m.pendingN, m.pending
+	if n == 0 {
+		return
+	}
+	m.pendingN = 0
+	literal := strings.Repeat(string(marker), n)
+
+	// snake_case and 2*3 are not emphasis
+	wordInside := isWordRune(m.prev) && isWordRune(next)
+	if marker == '_' && (n == 1 || wordInside) {
+		m.writeString(literal)
+		return
+	}
+	opening := next != 0 && next != ' ' && next != '\n'
+
+	switch n {
+	case 1:
+		if m.italic {
+			m.italic = false
+		} else if opening && !wordInside {
+			m.italic = true
+		} else {
+			m.writeString(literal)
+			return
+		}
+	case 2:
+		if m.bold {
+			m.bold = false
+		} else if opening {
+			m.bold = true
+		} else {
+			m.writeString(literal)
+			return
+		}
+	default:
+		if m.bold || m.italic {
+			m.bold, m.italic = false, false
+		} else if opening {
+			m.bold, m.italic = true, true
+		} else {
+			m.writeString(literal)
+			return
+		}
+	}
+	m.applyStyle()
+}
+
+func isWordRune(r rune) bool {
+	return r != 0 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// newline ends the current line; emphasis never runs past a line end, so
+// an unclosed marker cannot style the rest of the answer
+func (m *MarkdownRenderer) newline() {
+	m.bold, m.italic, m.code, m.lineStyle = false, false, false, ""
+	m.applyStyle()
+	m.out.WriteByte('\n')
+	m.prev = 0
+	m.atLineStart = true
+}
+
+func (m *MarkdownRenderer) write(r rune) {
+	m.out.WriteRune(r)
+	m.prev = r
+}
+
+func (m *MarkdownRenderer) writeString(s string) {
+	for _, r := range s {
+		m.write(r)
+	}
+}
+
+// styled writes s in style and restores the current style
+func (m *MarkdownRenderer) styled(style, s string) {
+	m.out.WriteString("\x1b[" + style + "m" + s + ansiReset)
+	m.styleShown = ""
+	m.applyStyle()
+}
+
+// applyStyle switches the terminal to the combination of active styles
+func (m *MarkdownRenderer) applyStyle() {
+	var codes []string
+	if m.lineStyle != "" {
+		codes = append(codes, m.lineStyle)
+	}
+	if m.bold {
+		codes = append(codes, ansiBold)
+	}
+	if m.italic {
+		codes = append(codes, ansiItalic)
+	}
+	if m.code {
+		codes = append(codes, ansiCode)
+	}
+	style := strings.Join(codes, ";")
+	if style == m.styleShown {
+		return
+	}
+	m.out.WriteString(ansiReset)
+	if style != "" {
+		m.out.WriteString("\x1b[" + style + "m")
+	}
+	m.styleShown = style
+}
+
+// codeKeywords lists the keywords highlighted for each fence language
+var codeKeywords = map[string][]string{
+	"go":         strings.Fields("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false"),
+	"python":     strings.Fields("and as assert async await break class continue def del elif else except False finally for from global if import in is lambda None nonlocal not or pass raise return True try while with yield"),
+	"javascript": strings.Fields("async await break case catch class const continue default delete do else export extends false finally for function if import in instanceof let new null return super switch this throw true try typeof undefined var void while yield"),
+	"bash":       strings.Fields("case do done echo elif else esac export fi for function if in local return then while"),
+	"json":       strings.Fields("true false null"),
+	"yaml":       strings.Fields("true false null"),
+	"sql":        strings.Fields("SELECT FROM WHERE AND OR NOT INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE JOIN ON GROUP BY ORDER LIMIT AS NULL select from where and or not insert into values update set delete create table join on group by order limit as null"),
+}
+
+// codeLanguageAliases maps fence labels to a codeKeywords language
+var codeLanguageAliases = map[string]string{
+	"golang": "go", "py": "python", "js": "javascript", "ts": "javascript", "typescript": "javascript",
+	"sh": "bash", "shell": "bash", "zsh": "bash", "console": "bash", "yml": "yaml",
+}
+
+// codeComments lists the line comment markers of each language
+var codeComments = map[string][]string{
+	"go": {"//"}, "javascript": {"//"}, "python": {"#"}, "bash": {"#"}, "yaml": {"#"}, "sql": {"--"}, "json": nil,
+}
+
+// highlightCode colors keywords, strings, numbers and comments of one line
+// of code. It is a lexer-free approximation, good enough for reading.
+func highlightCode(lang, line string) string {
+	if alias, ok := codeLanguageAliases[lang]; ok {
+		lang = alias
+	}
+	keywords := make(map[string]bool)
+	for _, k := range codeKeywords[lang] {
+		keywords[k] = true
+	}
+	comments, ok := codeComments[lang]
+	if !ok {
+		comments = []string{"//", "#"}
+	}
+
+	var b strings.Builder
+	color := func(style, s string) {
+		b.WriteString("\x1b[" + style + "m" + s + ansiReset)
+	}
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		rest := string(runes[i:])
+		if isCommentStart(rest, comments) {
+			color(ansiComment, rest)
+			break
+		}
+		switch {
+		case r == '"' || r == '\'' || r == '`':
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(runes))
+			color(ansiString, string(runes[i:j]))
+			i = j
+		case unicode.IsDigit(r) && (i == 0 || !isIdentRune(runes[i-1])):
+			j := i
+			for j < len(runes) && (isIdentRune(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			color(ansiNumber, string(runes[i:j]))
+			i = j
+		case isIdentRune(r):
+			j := i
+			for j < len(runes) && isIdentRune(runes[j]) {
+				j++
+			}
+			if word := string(runes[i:j]); keywords[word] {
+				color(ansiKeyword, word)
+			} else {
+				b.WriteString(word)
+			}
+			i = j
+		default:
+			b.WriteRune(r)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isCommentStart(s string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.HasPrefix(s, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isTerminal reports whether f is a character device such as a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// newStreamWriter returns where streamed assistant output should go: a
+// MarkdownRenderer on an interactive terminal, or stdout unchanged when raw
+// is set, NO_COLOR is set or stdout is redirected. The returned flush must be
+// called when the stream ends.
+func newStreamWriter(raw bool) (io.Writer, func() error) {
+	if raw || os.Getenv("NO_COLOR") != "" || !isTerminal(os.Stdout) {
+		return os.Stdout, func() error { return nil }
+	}
+	r := NewMarkdownRenderer(os.Stdout)
+	return r, r.Flush
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CallMetadata describes one HTTP call made by the client
+type CallMetadata struct {
+	Method        string
+	Endpoint      string
+	RequestID     string // the server's X-Request-Id, if it sent one
+	CorrelationID string // the operation the call belonged to; see WithCorrelationID
+	StatusCode    int    // 0 if no response arrived
+	Start         time.Time
+	// Duration runs from sending the request until its response body was
+	// closed, so it includes reading the whole body or stream
+	Duration time.Duration
+	// TTFT is the time to the first bytes of an event stream, which carry
+	// the first token; it is zero for other responses
+	TTFT    time.Duration
+	Retries int  // earlier attempts at the same logical call
+	Seed    *int // the seed sent with a completion, to reproduce it with
+	// Cost is the estimated cost of an inference call to a model in the
+	// client's pricing table; see WithPricing
+	Cost   *CostEstimate
+	Header http.Header
+	Err    error // the transport error if no response arrived
+}
+
+// CallRecorder collects the metadata of every call made with a context from
+// WithCallRecorder, once the call's response body has been closed
+type CallRecorder struct {
+	parent *CallRecorder
+	mu     sync.Mutex
+	calls  []CallMetadata
+}
+
+type callRecorderKey struct{}
+
+// WithCallRecorder returns a context that records the metadata of every call
+// made with it. Recorders nest: a call is also recorded by any recorder
+// already in ctx.
+func WithCallRecorder(ctx context.Context) (context.Context, *CallRecorder) {
+	parent, _ := ctx.Value(callRecorderKey{}).(*CallRecorder)
+	rec := &CallRecorder{parent: parent}
+	return context.WithValue(ctx, callRecorderKey{}, rec), rec
+}
+
+// Calls returns the calls recorded so far, oldest first
+func (r *CallRecorder) Calls() []CallMetadata {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]CallMetadata(nil), r.calls...)
+}
+
+// Last returns the most recently finished call
+func (r *CallRecorder) Last() (CallMetadata, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.calls) == 0 {
+		return CallMetadata{}, false
+	}
+	return r.calls[len(r.calls)-1], true
+}
+
+func (r *CallRecorder) record(md CallMetadata) {
+	for ; r != nil; r = r.parent {
+		r.mu.Lock()
+		r.calls = append(r.calls, md)
+		r.mu.Unlock()
+	}
+}
+
+type retriesKey struct{}
+
+// withRetries marks calls made with ctx as a retry preceded by n failed attempts
+func withRetries(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, retriesKey{}, n)
+}
+
+// recordCall hands the metadata of a call to the recorder in the request's
+// context, if any. A response is recorded when its body is closed.
+func recordCall(req *http.Request, start time.Time, resp *http.Response, err error) (*http.Response, error) {
+	rec, _ := req.Context().Value(callRecorderKey{}).(*CallRecorder)
+	if rec == nil {
+		return resp, err
+	}
+	retries, _ := req.Context().Value(retriesKey{}).(int)
+	md := CallMetadata{
+		Method:        req.Method,
+		Endpoint:      req.URL.Path,
+		CorrelationID: req.Header.Get(CorrelationIDHeader),
+		Start:         start,
+		Retries:       retries,
+		Seed:          callSeed(req.Context()),
+	}
+	if err != nil {
+		md.Duration, md.Err = time.Since(start), err
+		rec.record(md)
+		return resp, err
+	}
+	md.StatusCode = resp.StatusCode
+	md.RequestID = resp.Header.Get("X-Request-Id")
+	md.Header = resp.Header.Clone()
+	stream := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	resp.Body = &metadataBody{ReadCloser: resp.Body, rec: rec, md: md, stream: stream, ctx: req.Context()}
+	return resp, nil
+}
+
+// metadataBody times a response body and records the call when it is closed
+type metadataBody struct {
+	io.ReadCloser
+	rec    *CallRecorder
+	md     CallMetadata
+	stream bool
+	ctx    context.Context
+	once   sync.Once
+}
+
+func (mb *metadataBody) Read(p []byte) (int, error) {
+	n, err := mb.ReadCloser.Read(p)
+	if mb.stream && n > 0 && mb.md.TTFT == 0 {
+		mb.md.TTFT = time.Since(mb.md.Start)
+	}
+	return n, err
+}
+
+func (mb *metadataBody) Close() error {
+	err := mb.ReadCloser.Close()
+	mb.once.Do(func() {
+		mb.md.Duration = time.Since(mb.md.Start)
+		mb.md.Cost = callCost(mb.ctx)
+		mb.rec.record(mb.md)
+	})
+	return err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MigrateOptions configures MigrateVectorStore
+type MigrateOptions struct {
+	Name               string // defaults to "<source name> (<embedding model>)"
+	EmbeddingModel     string // embedding model of the new store
+	EmbeddingDimension int    // checked against the model's metadata when both are known
+	ProviderID         string // vector_io provider of the new store; server default when empty
+
+	// ChunkingStrategy overrides the strategy each file was attached with,
+	// e.g. to use larger chunks with a model that has a longer context
+	ChunkingStrategy map[string]interface{}
+
+	// DryRun checks the embedding model and lists what would be migrated
+	// without creating anything
+	DryRun bool
+
+	// Progress, if set, is called after each file is handled
+	Progress func(MigrateProgress)
+}
+
+// MigrateProgress reports one handled file of a migration
+type MigrateProgress struct {
+	Done     int
+	Total    int
+	Filename string
+	Err      error
+}
+
+// MigratedFile is the outcome of migrating one file
+type MigratedFile struct {
+	FileID   string
+	Filename string
+	Bytes    int
+	Err      error
+}
+
+// MigrateResult summarizes a migration
+type MigrateResult struct {
+	Store *VectorStore // the new store; nil in a dry run
+	Files []MigratedFile
+}
+
+// MigrateVectorStore copies a vector store into a new one that embeds with a
+// different model, e.g. after switching embedding models. The source files
+// are already on the server, so they are attached to the new store as they
+// are and the server chunks and embeds them again with the new model; the
+// source store is left untouched.
+//
+// A file that fails to attach does not stop the migration; it is recorded in
+// the result and reported in the returned error.
+func (c *LlamaStackClient) MigrateVectorStore(ctx context.Context, srcID string, opts MigrateOptions) (*MigrateResult, error) {
+	ctx = ensureCorrelationID(ctx)
+	if opts.EmbeddingModel == "" {
+		return nil, errors.New("migration needs an embedding model")
+	}
+	if err := c.checkEmbeddingModel(ctx, opts.EmbeddingModel, opts.EmbeddingDimension); err != nil {
+		return nil, err
+	}
+
+	src, err := c.GetVectorStore(ctx, srcID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source vector store: %w", err)
+	}
+	files, err := c.ListVectorStoreFiles(ctx, srcID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source files: %w", err)
+	}
+
+	result := &MigrateResult{}
+	name := opts.Name
+	if name == "" {
+		name = fmt.Sprintf("%s (%s)", src.Name, opts.EmbeddingModel)
+	}
+	if !opts.DryRun {
+		metadata := make(map[string]interface{}, len(src.Metadata)+1)
+		for k, v := range src.Metadata {
+			metadata[k] = v
+		}
+		metadata["migrated_from"] = srcID
+		result.Store, err = c.CreateVectorStoreWithParams(ctx, VectorStoreCreateParams{
+			Name:               name,
+			Metadata:           metadata,
+			EmbeddingModel:     opts.EmbeddingModel,
+			EmbeddingDimension: opts.EmbeddingDimension,
+			ProviderID:         opts.ProviderID,
+			ExpiresAfter:       src.ExpiresAfter,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vector store: %w", err)
+		}
+	}
+
+	var failed []error
+	for i, f := range files {
+		migrated := MigratedFile{FileID: f.ID, Filename: f.ID, Bytes: f.UsageBytes}
+		if info, err := c.GetFile(ctx, f.ID); err == nil {
+			migrated.Filename = info.Filename
+		}
+		if !opts.DryRun {
+			strategy := f.ChunkingStrategy
+			if opts.ChunkingStrategy != nil {
+				strategy = opts.ChunkingStrategy
+			}
+			if _, err := c.attachFile(ctx, result.Store.ID, f.ID, f.Attributes, strategy); err != nil {
+				migrated.Err = err
+				failed = append(failed, fmt.Errorf("%s: %w", migrated.Filename, err))
+			}
+		}
+		result.Files = append(result.Files, migrated)
+		if opts.Progress != nil {
+			opts.Progress(MigrateProgress{Done: i + 1, Total: len(files), Filename: migrated.Filename, Err: migrated.Err})
+		}
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+	}
+
+	if len(failed) > 0 {
+		return result, fmt.Errorf("%d of %d files failed to migrate: %w", len(failed), len(files), errors.Join(failed...))
+	}
+	return result, nil
+}
+
+// checkEmbeddingModel verifies model is a registered embedding model and,
+// when both are known, that its dimension matches
+func (c *LlamaStackClient) checkEmbeddingModel(ctx context.Context, model string, dimension int) error {
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	var embedding []string
+	for _, m := range models.Data {
+		if m.ModelType != "embedding" {
+			continue
+		}
+		embedding = append(embedding, m.Identifier)
+		if m.Identifier != model {
+			continue
+		}
+		if dim, ok := m.Metadata["embedding_dimension"].(float64); ok && dimension > 0 && int(dim) != dimension {
+			return fmt.Errorf("embedding model %s has dimension %d, not %d", model, int(dim), dimension)
+		}
+		return nil
+	}
+	return fmt.Errorf("embedding model %s not registered on this distro (available: %s)", model, listOrNone(embedding))
+}
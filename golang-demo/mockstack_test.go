@@ -0,0 +1,2657 @@
+// The mock routes by method and path patterns, which tests run as a GOPATH
+// package would otherwise get the Go 1.21 ServeMux for
+//go:debug httpmuxgo121=0
+
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MockStack is an in-process Llama Stack server implementing the subset of
+// endpoints this client uses. It lives in a test file of package main, not a
+// mockstack package, because it is written against the client's own types
+// and nothing can import package main; a separate package would need the
+// client split into a library with an import path, which this tree, having
+// no go.mod, does not have. A test file also keeps it out of the demo binary. Agent turns for agents with a RAG toolgroup
+// pause with a knowledge_search tool call, so the client-side agentic loop
+// can be exercised end to end without a model.
+type MockStack struct {
+	Server *httptest.Server
+
+	// Models listed by /v1/models
+	Models []Model
+	// ToolGroups listed by /v1/toolgroups
+	ToolGroups []ToolGroup
+	// Shields listed by /v1/shields; registering one adds it
+	Shields []Shield
+	// ChatReplies are returned by chat completions and final agent answers in
+	// order; once exhausted, Reply is used
+	ChatReplies []string
+	// Reply builds an answer from the conversation when no scripted reply is left
+	Reply func(messages []Message) string
+	// Transcribe returns the text of audio sent for transcription
+	Transcribe func(filename string, audio []byte) string
+	// Latency delays every response, e.g. to play a slow replica
+	Latency time.Duration
+	// RejectGzip answers gzipped request bodies with 415, like a server that
+	// does not decompress requests
+	RejectGzip bool
+
+	mu            sync.Mutex
+	nextID        int
+	files         []FileResponse
+	fileContent   map[string][]byte
+	storeFiles    map[string][]VectorStoreFile // attached files of each vector store
+	vectorStores  map[string]*VectorStore
+	documents     map[string][]Document
+	agents        map[string]AgentConfig
+	sessions      map[string]Session
+	pending       map[string]pendingTurn
+	turns         map[string]Turn
+	sessionTurns  map[string][]string // turn IDs of each session in creation order
+	datasets      map[string]*mockDataset
+	benchmarks    map[string]Benchmark
+	evalJobs      map[string]*mockEvalJob
+	prompts       map[string][]Prompt // versions of each stored prompt, oldest first
+	conversations map[string]*mockConversation
+	responses     map[string]*mockResponse
+	uploads       map[string]*mockUpload
+	requests      []string
+}
+
+// mockUpload is an upload with the parts added to it
+type mockUpload struct {
+	Upload
+	parts map[string][]byte
+}
+
+// mockDataset is a registered dataset with its rows
+type mockDataset struct {
+	Dataset
+	rows []map[string]interface{}
+}
+
+// mockEvalJob is an eval job that completes on its first status poll
+type mockEvalJob struct {
+	benchmarkID string
+	status      EvalJobStatus
+	result      EvaluateResponse
+}
+
+// pendingTurn is a turn waiting for client-side tool responses
+type pendingTurn struct {
+	sessionID string
+	input     []Message
+	steps     []interface{}
+	source    string // what the answer cites the tool output as
+}
+
+// NewMockStack starts a mock server; call Close when done
+func NewMockStack() *MockStack {
+	m := &MockStack{
+		Models: []Model{
+			{Identifier: "ollama/llama3.2:3b", ModelType: "llm"},
+			{Identifier: "all-MiniLM-L6-v2", ModelType: "embedding", Metadata: map[string]interface{}{"embedding_dimension": 384}},
+			{Identifier: "openai/whisper-1", ModelType: "llm"},
+			{Identifier: "meta-llama/Llama-3.2-11B-Vision-Instruct", ModelType: "llm"},
+		},
+		ToolGroups: []ToolGroup{
+			{Identifier: "builtin::rag", ProviderID: "rag-runtime"},
+			{Identifier: "builtin::websearch", ProviderID: "tavily-search"},
+			{Identifier: "builtin::code_interpreter", ProviderID: "code-interpreter"},
+		},
+		fileContent:   make(map[string][]byte),
+		storeFiles:    make(map[string][]VectorStoreFile),
+		vectorStores:  make(map[string]*VectorStore),
+		documents:     make(map[string][]Document),
+		agents:        make(map[string]AgentConfig),
+		sessions:      make(map[string]Session),
+		pending:       make(map[string]pendingTurn),
+		turns:         make(map[string]Turn),
+		sessionTurns:  make(map[string][]string),
+		datasets:      make(map[string]*mockDataset),
+		benchmarks:    make(map[string]Benchmark),
+		evalJobs:      make(map[string]*mockEvalJob),
+		prompts:       make(map[string][]Prompt),
+		conversations: make(map[string]*mockConversation),
+		responses:     make(map[string]*mockResponse),
+		uploads:       make(map[string]*mockUpload),
+	}
+	m.Reply = func(messages []Message) string {
+		if len(messages) == 0 {
+			return "Hello from the mock stack."
+		}
+		last := messages[len(messages)-1]
+		if n := last.Images(); n > 0 {
+			return fmt.Sprintf("Mock answer to: %s (looking at %d image(s))", last.Content, n)
+		}
+		return "Mock answer to: " + last.Content
+	}
+	m.Transcribe = func(filename string, audio []byte) string {
+		return fmt.Sprintf("What is in the recording %s?", filename)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", m.handleListModels)
+	mux.HandleFunc("GET /v1/toolgroups", m.handleListToolGroups)
+	mux.HandleFunc("GET /v1/shields", m.handleListShields)
+	mux.HandleFunc("POST /v1/shields", m.handleRegisterShield)
+	mux.HandleFunc("GET /v1/shields/{identifier}", m.handleGetShield)
+	mux.HandleFunc("DELETE /v1/shields/{identifier}", m.handleUnregisterShield)
+	mux.HandleFunc("POST /v1/safety/run-shield", m.handleRunShield)
+	mux.HandleFunc("POST /v1/openai/v1/files", m.handleUploadFile)
+	mux.HandleFunc("POST /v1/openai/v1/uploads", m.handleCreateUpload)
+	mux.HandleFunc("POST /v1/openai/v1/uploads/{upload_id}/parts", m.handleAddUploadPart)
+	mux.HandleFunc("POST /v1/openai/v1/uploads/{upload_id}/complete", m.handleCompleteUpload)
+	mux.HandleFunc("POST /v1/openai/v1/uploads/{upload_id}/cancel", m.handleCancelUpload)
+	mux.HandleFunc("GET /v1/openai/v1/files", m.handleListFiles)
+	mux.HandleFunc("GET /v1/openai/v1/files/{file_id}", m.handleGetFile)
+	mux.HandleFunc("GET /v1/openai/v1/files/{file_id}/content", m.handleGetFileContent)
+	mux.HandleFunc("DELETE /v1/openai/v1/files/{file_id}", m.handleDeleteFile)
+	mux.HandleFunc("POST /v1/openai/v1/vector_stores", m.handleCreateVectorStore)
+	mux.HandleFunc("GET /v1/openai/v1/vector_stores", m.handleListVectorStores)
+	mux.HandleFunc("GET /v1/openai/v1/vector_stores/{vector_store_id}", m.handleGetVectorStore)
+	mux.HandleFunc("DELETE /v1/openai/v1/vector_stores/{vector_store_id}", m.handleDeleteVectorStore)
+	mux.HandleFunc("POST /v1/openai/v1/vector_stores/{vector_store_id}/files", m.handleAttachFile)
+	mux.HandleFunc("GET /v1/openai/v1/vector_stores/{vector_store_id}/files", m.handleListVectorStoreFiles)
+	mux.HandleFunc("GET /v1/openai/v1/vector_stores/{vector_store_id}/files/{file_id}", m.handleGetVectorStoreFile)
+	mux.HandleFunc("GET /v1/openai/v1/vector_stores/{vector_store_id}/files/{file_id}/content", m.handleVectorStoreFileContent)
+	mux.HandleFunc("DELETE /v1/openai/v1/vector_stores/{vector_store_id}/files/{file_id}", m.handleDetachFile)
+	mux.HandleFunc("POST /v1/tool-runtime/rag-tool/insert", m.handleRAGInsert)
+	mux.HandleFunc("POST /v1/tool-runtime/rag-tool/query", m.handleRAGQuery)
+	mux.HandleFunc("POST /v1/openai/v1/chat/completions", m.handleChatCompletion)
+	mux.HandleFunc("POST /v1/openai/v1/embeddings", m.handleEmbeddings)
+	mux.HandleFunc("POST /v1/openai/v1/audio/transcriptions", m.handleTranscription)
+	mux.HandleFunc("POST /v1/openai/v1/moderations", m.handleModeration)
+	mux.HandleFunc("GET /v1/datasets", m.handleListDatasets)
+	mux.HandleFunc("POST /v1/datasets", m.handleRegisterDataset)
+	mux.HandleFunc("GET /v1/datasets/{dataset_id}", m.handleGetDataset)
+	mux.HandleFunc("DELETE /v1/datasets/{dataset_id}", m.handleDeleteDataset)
+	mux.HandleFunc("GET /v1/datasetio/iterrows/{dataset_id}", m.handleIterRows)
+	mux.HandleFunc("POST /v1/datasetio/append-rows/{dataset_id}", m.handleAppendRows)
+	mux.HandleFunc("POST /v1/synthetic-data-generation/generate", m.handleSyntheticData)
+	mux.HandleFunc("GET /v1/eval/benchmarks", m.handleListBenchmarks)
+	mux.HandleFunc("POST /v1/eval/benchmarks", m.handleRegisterBenchmark)
+	mux.HandleFunc("GET /v1/eval/benchmarks/{benchmark_id}", m.handleGetBenchmark)
+	mux.HandleFunc("POST /v1/eval/benchmarks/{benchmark_id}/jobs", m.handleRunEval)
+	mux.HandleFunc("GET /v1/eval/benchmarks/{benchmark_id}/jobs/{job_id}", m.handleGetEvalJob)
+	mux.HandleFunc("DELETE /v1/eval/benchmarks/{benchmark_id}/jobs/{job_id}", m.handleCancelEvalJob)
+	mux.HandleFunc("GET /v1/eval/benchmarks/{benchmark_id}/jobs/{job_id}/result", m.handleEvalJobResult)
+	mux.HandleFunc("GET /v1/prompts", m.handleListPrompts)
+	mux.HandleFunc("POST /v1/prompts", m.handleCreatePrompt)
+	mux.HandleFunc("GET /v1/prompts/{prompt_id}", m.handleGetPrompt)
+	mux.HandleFunc("POST /v1/prompts/{prompt_id}", m.handleUpdatePrompt)
+	mux.HandleFunc("DELETE /v1/prompts/{prompt_id}", m.handleDeletePrompt)
+	mux.HandleFunc("GET /v1/prompts/{prompt_id}/versions", m.handleListPromptVersions)
+	mux.HandleFunc("POST /v1/prompts/{prompt_id}/set-default-version", m.handleSetDefaultPromptVersion)
+	mux.HandleFunc("POST /v1/conversations", m.handleCreateConversation)
+	mux.HandleFunc("GET /v1/conversations/{conversation_id}", m.handleGetConversation)
+	mux.HandleFunc("POST /v1/conversations/{conversation_id}", m.handleUpdateConversation)
+	mux.HandleFunc("DELETE /v1/conversations/{conversation_id}", m.handleDeleteConversation)
+	mux.HandleFunc("POST /v1/conversations/{conversation_id}/items", m.handleAddConversationItems)
+	mux.HandleFunc("GET /v1/conversations/{conversation_id}/items", m.handleListConversationItems)
+	mux.HandleFunc("POST /v1/openai/v1/responses", m.handleCreateResponse)
+	mux.HandleFunc("GET /v1/openai/v1/responses/{response_id}", m.handleGetResponse)
+	mux.HandleFunc("POST /v1/openai/v1/responses/{response_id}/cancel", m.handleCancelResponse)
+	mux.HandleFunc("GET /v1/agents", m.handleListAgents)
+	mux.HandleFunc("POST /v1/agents", m.handleCreateAgent)
+	mux.HandleFunc("DELETE /v1/agents/{agent_id}", m.handleDeleteAgent)
+	mux.HandleFunc("POST /v1/agents/{agent_id}/session", m.handleCreateSession)
+	mux.HandleFunc("GET /v1/agents/{agent_id}", m.handleGetAgent)
+	mux.HandleFunc("GET /v1/agents/{agent_id}/sessions", m.handleListSessions)
+	mux.HandleFunc("GET /v1/agents/{agent_id}/session/{session_id}", m.handleGetSession)
+	mux.HandleFunc("DELETE /v1/agents/{agent_id}/session/{session_id}", m.handleDeleteSession)
+	mux.HandleFunc("POST /v1/agents/{agent_id}/session/{session_id}/turn", m.handleCreateTurn)
+	mux.HandleFunc("POST /v1/agents/{agent_id}/session/{session_id}/turn/{turn_id}/resume", m.handleResumeTurn)
+	mux.HandleFunc("GET /v1/agents/{agent_id}/session/{session_id}/turn/{turn_id}", m.handleGetTurn)
+	mux.HandleFunc("GET /v1/agents/{agent_id}/session/{session_id}/turn/{turn_id}/step/{step_id}", m.handleGetStep)
+
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		m.requests = append(m.requests, r.Method+" "+r.URL.Path)
+		requestID := len(m.requests)
+		m.mu.Unlock()
+		w.Header().Set("X-Request-Id", fmt.Sprintf("req-%d", requestID))
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			if m.RejectGzip {
+				writeMockError(w, http.StatusUnsupportedMediaType, "gzip request bodies are not supported")
+				return
+			}
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				writeMockError(w, http.StatusBadRequest, "invalid gzip body")
+				return
+			}
+			r.Body = zr
+		}
+		if m.Latency > 0 {
+			select {
+			case <-time.After(m.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	return m
+}
+
+// URL returns the base URL of the mock server
+func (m *MockStack) URL() string {
+	return m.Server.URL
+}
+
+// Close shuts the mock server down
+func (m *MockStack) Close() {
+	m.Server.Close()
+}
+
+// Requests returns the "METHOD /path" of every request received so far
+func (m *MockStack) Requests() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.requests...)
+}
+
+// newID returns a unique identifier with the given prefix; callers hold m.mu
+func (m *MockStack) newID(prefix string) string {
+	m.nextID++
+	return fmt.Sprintf("%s-%d", prefix, m.nextID)
+}
+
+// reply returns the next scripted reply or a generated one; callers hold m.mu
+func (m *MockStack) reply(messages []Message) string {
+	if len(m.ChatReplies) > 0 {
+		r := m.ChatReplies[0]
+		m.ChatReplies = m.ChatReplies[1:]
+		return r
+	}
+	return m.Reply(messages)
+}
+
+// mockSchemaInstance builds a value matching schema, as a model honoring the
+// json_schema response format would reply with
+func mockSchemaInstance(schema, root map[string]interface{}) interface{} {
+	if ref, ok := schema["$ref"].(string); ok {
+		v := schemaValidator{root: root}
+		if target, err := v.resolve(ref); err == nil {
+			return mockSchemaInstance(target, root)
+		}
+		return nil
+	}
+	if c, ok := schema["const"]; ok {
+		return c
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		if list, ok := schema[key].([]interface{}); ok && len(list) > 0 {
+			if sub, ok := list[0].(map[string]interface{}); ok {
+				return mockSchemaInstance(sub, root)
+			}
+		}
+	}
+	t := schema["type"]
+	if list, ok := t.([]interface{}); ok && len(list) > 0 {
+		t = list[0]
+	}
+	switch t {
+	case "object":
+		obj := map[string]interface{}{}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, prop := range properties {
+			if sub, ok := prop.(map[string]interface{}); ok {
+				obj[name] = mockSchemaInstance(sub, root)
+			}
+		}
+		return obj
+	case "array":
+		n, _ := schemaNumber(schema, "minItems")
+		items, _ := schema["items"].(map[string]interface{})
+		arr := []interface{}{}
+		for i := 0; i < max(int(n), 1) && items != nil; i++ {
+			arr = append(arr, mockSchemaInstance(items, root))
+		}
+		return arr
+	case "string":
+		n, _ := schemaNumber(schema, "minLength")
+		return "mock" + strings.Repeat("x", max(int(n)-4, 0))
+	case "integer", "number":
+		if min, ok := schemaNumber(schema, "minimum"); ok {
+			return math.Ceil(min)
+		}
+		if min, ok := schemaNumber(schema, "exclusiveMinimum"); ok {
+			return math.Floor(min) + 1
+		}
+		return 1
+	case "boolean":
+		return true
+	}
+	return nil
+}
+
+func (m *MockStack) handleListModels(w http.ResponseWriter, r *http.Request) {
+	writeMockJSON(w, http.StatusOK, ListModelsResponse{Data: m.Models})
+}
+
+func (m *MockStack) handleListToolGroups(w http.ResponseWriter, r *http.Request) {
+	writeMockJSON(w, http.StatusOK, ListToolGroupsResponse{Data: m.ToolGroups})
+}
+
+func (m *MockStack) handleListShields(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, ListShieldsResponse{Data: append([]Shield{}, m.Shields...)})
+}
+
+func (m *MockStack) handleRegisterShield(w http.ResponseWriter, r *http.Request) {
+	var params SpecRegisterShieldRequest
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	if params.ShieldID == "" || params.ProviderID == nil {
+		writeMockError(w, http.StatusBadRequest, "shield_id and provider_id are required")
+		return
+	}
+	if *params.ProviderID != "llama-guard" && *params.ProviderID != "prompt-guard" {
+		writeMockError(w, http.StatusBadRequest, "provider %s not found", *params.ProviderID)
+		return
+	}
+	shield := Shield{Identifier: params.ShieldID, ProviderID: *params.ProviderID, ProviderResourceID: params.ShieldID, Type: "shield", Params: params.Params}
+	if params.ProviderShieldID != nil {
+		shield.ProviderResourceID = *params.ProviderShieldID
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if slices.ContainsFunc(m.Shields, func(s Shield) bool { return s.Identifier == shield.Identifier }) {
+		writeMockError(w, http.StatusBadRequest, "shield %s already exists", shield.Identifier)
+		return
+	}
+	m.Shields = append(m.Shields, shield)
+	writeMockJSON(w, http.StatusOK, shield)
+}
+
+func (m *MockStack) handleGetShield(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.Shields {
+		if s.Identifier == r.PathValue("identifier") {
+			writeMockJSON(w, http.StatusOK, s)
+			return
+		}
+	}
+	writeMockError(w, http.StatusNotFound, "shield %s not found", r.PathValue("identifier"))
+}
+
+func (m *MockStack) handleUnregisterShield(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.Shields, func(s Shield) bool { return s.Identifier == r.PathValue("identifier") })
+	if i < 0 {
+		writeMockError(w, http.StatusNotFound, "shield %s not found", r.PathValue("identifier"))
+		return
+	}
+	m.Shields = slices.Delete(m.Shields, i, i+1)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *MockStack) handleUploadFile(w http.ResponseWriter, r *http.Request) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeMockError(w, http.StatusBadRequest, "missing file: %v", err)
+		return
+	}
+	defer file.Close()
+	if err := FilePurpose(r.FormValue("purpose")).Validate(); err != nil {
+		writeMockError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeMockError(w, http.StatusBadRequest, "failed to read file: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	resp := FileResponse{
+		ID:        m.newID("file"),
+		Object:    "file",
+		Bytes:     len(content),
+		CreatedAt: time.Now().Unix(),
+		Filename:  header.Filename,
+		Purpose:   r.FormValue("purpose"),
+	}
+	m.files = append(m.files, resp)
+	m.fileContent[resp.ID] = content
+	m.mu.Unlock()
+
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+func (m *MockStack) handleCreateUpload(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Filename string      `json:"filename"`
+		Purpose  FilePurpose `json:"purpose"`
+		Bytes    int64       `json:"bytes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeMockError(w, http.StatusBadRequest, "invalid request: %v", err)
+		return
+	}
+	if err := req.Purpose.Validate(); err != nil {
+		writeMockError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	m.mu.Lock()
+	upload := &mockUpload{
+		Upload: Upload{
+			ID:        m.newID("upload"),
+			Object:    "upload",
+			Bytes:     req.Bytes,
+			CreatedAt: time.Now().Unix(),
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			Filename:  req.Filename,
+			Purpose:   string(req.Purpose),
+			Status:    "pending",
+		},
+		parts: make(map[string][]byte),
+	}
+	m.uploads[upload.ID] = upload
+	resp := upload.Upload
+	m.mu.Unlock()
+
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+// pendingUpload returns the upload in the path if parts can still be added
+func (m *MockStack) pendingUpload(w http.ResponseWriter, r *http.Request) *mockUpload {
+	upload, ok := m.uploads[r.PathValue("upload_id")]
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "upload %s not found", r.PathValue("upload_id"))
+		return nil
+	}
+	if upload.Status != "pending" {
+		writeMockError(w, http.StatusBadRequest, "upload %s is %s", upload.ID, upload.Status)
+		return nil
+	}
+	return upload
+}
+
+func (m *MockStack) handleAddUploadPart(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("data")
+	if err != nil {
+		writeMockError(w, http.StatusBadRequest, "missing data: %v", err)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeMockError(w, http.StatusBadRequest, "failed to read part: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload := m.pendingUpload(w, r)
+	if upload == nil {
+		return
+	}
+	part := UploadPart{ID: m.newID("part"), Object: "upload.part", CreatedAt: time.Now().Unix(), UploadID: upload.ID}
+	upload.parts[part.ID] = data
+	writeMockJSON(w, http.StatusOK, part)
+}
+
+func (m *MockStack) handleCompleteUpload(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PartIDs []string `json:"part_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeMockError(w, http.StatusBadRequest, "invalid request: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload := m.pendingUpload(w, r)
+	if upload == nil {
+		return
+	}
+	var content []byte
+	for _, id := range req.PartIDs {
+		data, ok := upload.parts[id]
+		if !ok {
+			writeMockError(w, http.StatusBadRequest, "part %s is not part of upload %s", id, upload.ID)
+			return
+		}
+		content = append(content, data...)
+	}
+	if int64(len(content)) != upload.Bytes {
+		writeMockError(w, http.StatusBadRequest, "parts add up to %d bytes, upload was created for %d", len(content), upload.Bytes)
+		return
+	}
+
+	file := FileResponse{
+		ID:        m.newID("file"),
+		Object:    "file",
+		Bytes:     len(content),
+		CreatedAt: time.Now().Unix(),
+		Filename:  upload.Filename,
+		Purpose:   upload.Purpose,
+	}
+	m.files = append(m.files, file)
+	m.fileContent[file.ID] = content
+	upload.Status, upload.File, upload.parts = "completed", &file, nil
+	writeMockJSON(w, http.StatusOK, upload.Upload)
+}
+
+func (m *MockStack) handleCancelUpload(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload := m.pendingUpload(w, r)
+	if upload == nil {
+		return
+	}
+	upload.Status, upload.parts = "cancelled", nil
+	writeMockJSON(w, http.StatusOK, upload.Upload)
+}
+
+func (m *MockStack) handleTranscription(w http.ResponseWriter, r *http.Request) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeMockError(w, http.StatusBadRequest, "missing file: %v", err)
+		return
+	}
+	defer file.Close()
+	audio, err := io.ReadAll(file)
+	if err != nil {
+		writeMockError(w, http.StatusBadRequest, "failed to read file: %v", err)
+		return
+	}
+	if r.FormValue("model") == "" {
+		writeMockError(w, http.StatusBadRequest, "model is required")
+		return
+	}
+	language := r.FormValue("language")
+	if language == "" {
+		language = "en"
+	}
+	writeMockJSON(w, http.StatusOK, Transcription{Text: m.Transcribe(header.Filename, audio), Language: language})
+}
+
+// mockModerationTerms flag inputs in the mock's moderation endpoint
+var mockModerationTerms = map[ModerationCategory][]string{
+	ModerationViolence:   {"kill", "attack", "hurt"},
+	ModerationIllicit:    {"steal", "bomb", "hack into"},
+	ModerationHate:       {"hate"},
+	ModerationHarassment: {"idiot", "stupid"},
+	ModerationSelfHarm:   {"hurt myself"},
+}
+
+func (m *MockStack) handleModeration(w http.ResponseWriter, r *http.Request) {
+	var params ModerationParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	m.mu.Lock()
+	resp := ModerationResponse{ID: m.newID("modr"), Model: params.Model}
+	m.mu.Unlock()
+	if resp.Model == "" {
+		resp.Model = "meta-llama/Llama-Guard-3-8B"
+	}
+	for _, input := range params.Input {
+		text := strings.ToLower(input)
+		result := ModerationResult{Categories: make(map[ModerationCategory]bool), CategoryScores: make(map[ModerationCategory]float64)}
+		for category, terms := range mockModerationTerms {
+			score := 0.01
+			for _, term := range terms {
+				if strings.Contains(text, term) {
+					score = 0.9
+				}
+			}
+			result.CategoryScores[category] = score
+			result.Categories[category] = score >= 0.5
+			result.Flagged = result.Flagged || score >= 0.5
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+// handleRunShield flags the last message of the conversation when it holds
+// one of mockModerationTerms
+func (m *MockStack) handleRunShield(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		ShieldID string    `json:"shield_id"`
+		Messages []Message `json:"messages"`
+	}
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	m.mu.Lock()
+	registered := slices.ContainsFunc(m.Shields, func(s Shield) bool { return s.Identifier == params.ShieldID })
+	m.mu.Unlock()
+	if !registered {
+		writeMockError(w, http.StatusNotFound, "shield %s not found", params.ShieldID)
+		return
+	}
+	if len(params.Messages) == 0 {
+		writeMockError(w, http.StatusBadRequest, "messages are required")
+		return
+	}
+	text := strings.ToLower(params.Messages[len(params.Messages)-1].Content)
+	for category, terms := range mockModerationTerms {
+		for _, term := range terms {
+			if strings.Contains(text, term) {
+				writeMockJSON(w, http.StatusOK, RunShieldResponse{Violation: &SafetyViolation{
+					ViolationLevel: ViolationError,
+					UserMessage:    "I can't answer that. Can I help with something else?",
+					Metadata:       map[string]interface{}{"violation_type": string(category)},
+				}})
+				return
+			}
+		}
+	}
+	writeMockJSON(w, http.StatusOK, RunShieldResponse{})
+}
+
+func (m *MockStack) handleListFiles(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	resp := ListFilesResponse{Data: append([]FileResponse{}, m.files...), Object: "list"}
+	m.mu.Unlock()
+	if len(resp.Data) > 0 {
+		resp.FirstID = resp.Data[0].ID
+		resp.LastID = resp.Data[len(resp.Data)-1].ID
+	}
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+func (m *MockStack) handleGetFile(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.files {
+		if f.ID == r.PathValue("file_id") {
+			writeMockJSON(w, http.StatusOK, f)
+			return
+		}
+	}
+	writeMockError(w, http.StatusNotFound, "file %s not found", r.PathValue("file_id"))
+}
+
+func (m *MockStack) handleGetFileContent(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	content, ok := m.fileContent[r.PathValue("file_id")]
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "file %s not found", r.PathValue("file_id"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(content)
+}
+
+func (m *MockStack) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
+	fileID := r.PathValue("file_id")
+	m.mu.Lock()
+	_, ok := m.fileContent[fileID]
+	for i, f := range m.files {
+		if f.ID == fileID {
+			m.files = append(m.files[:i], m.files[i+1:]...)
+			break
+		}
+	}
+	delete(m.fileContent, fileID)
+	m.mu.Unlock()
+
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "file %s not found", fileID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, map[string]interface{}{"id": fileID, "object": "file", "deleted": true})
+}
+
+func (m *MockStack) handleCreateVectorStore(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Name         string                 `json:"name"`
+		Metadata     map[string]interface{} `json:"metadata"`
+		ExpiresAfter *VectorStoreExpiration `json:"expires_after"`
+	}
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	if params.ExpiresAfter != nil {
+		if err := params.ExpiresAfter.Validate(); err != nil {
+			writeMockError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+	}
+
+	m.mu.Lock()
+	now := time.Now()
+	vs := &VectorStore{
+		ID:           m.newID("vs"),
+		Object:       "vector_store",
+		Name:         params.Name,
+		CreatedAt:    now.Unix(),
+		FileCounts:   map[string]int{"completed": 0, "total": 0},
+		Metadata:     params.Metadata,
+		Status:       "completed",
+		ExpiresAfter: params.ExpiresAfter,
+	}
+	if params.ExpiresAfter != nil {
+		expiresAt := now.AddDate(0, 0, params.ExpiresAfter.Days).Unix()
+		vs.ExpiresAt = &expiresAt
+	}
+	m.vectorStores[vs.ID] = vs
+	resp := *vs
+	m.mu.Unlock()
+
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+func (m *MockStack) handleGetVectorStore(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	vs, ok := m.vectorStores[r.PathValue("vector_store_id")]
+	var resp VectorStore
+	if ok {
+		resp = *vs
+	}
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "vector store %s not found", r.PathValue("vector_store_id"))
+		return
+	}
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+func (m *MockStack) handleListVectorStores(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	response := ListVectorStoresResponse{Data: []VectorStore{}, Object: "list"}
+	for _, vs := range m.vectorStores {
+		response.Data = append(response.Data, *vs)
+	}
+	m.mu.Unlock()
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].ID < response.Data[j].ID })
+	if len(response.Data) > 0 {
+		response.FirstID = response.Data[0].ID
+		response.LastID = response.Data[len(response.Data)-1].ID
+	}
+	writeMockJSON(w, http.StatusOK, response)
+}
+
+func (m *MockStack) handleDeleteVectorStore(w http.ResponseWriter, r *http.Request) {
+	storeID := r.PathValue("vector_store_id")
+	m.mu.Lock()
+	_, ok := m.vectorStores[storeID]
+	delete(m.vectorStores, storeID)
+	delete(m.storeFiles, storeID)
+	m.mu.Unlock()
+
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "vector store %s not found", storeID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, map[string]interface{}{"id": storeID, "object": "vector_store.deleted", "deleted": true})
+}
+
+func (m *MockStack) handleListVectorStoreFiles(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	_, ok := m.vectorStores[r.PathValue("vector_store_id")]
+	_, inserted := m.documents[r.PathValue("vector_store_id")]
+	ok = ok || inserted // rag-tool inserts into vector DBs the mock never created
+	files := append([]VectorStoreFile{}, m.storeFiles[r.PathValue("vector_store_id")]...)
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "vector store %s not found", r.PathValue("vector_store_id"))
+		return
+	}
+
+	// Page with after/limit like the server
+	if after := r.URL.Query().Get("after"); after != "" {
+		for i, f := range files {
+			if f.ID == after {
+				files = files[i+1:]
+				break
+			}
+		}
+	}
+	resp := ListVectorStoreFilesResponse{Object: "list"}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit > 0 && len(files) > limit {
+		files, resp.HasMore = files[:limit], true
+	}
+	resp.Data = files
+	if len(files) > 0 {
+		resp.FirstID, resp.LastID = files[0].ID, files[len(files)-1].ID
+	}
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+func (m *MockStack) handleGetVectorStoreFile(w http.ResponseWriter, r *http.Request) {
+	storeID, fileID := r.PathValue("vector_store_id"), r.PathValue("file_id")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.storeFiles[storeID] {
+		if f.ID == fileID {
+			writeMockJSON(w, http.StatusOK, f)
+			return
+		}
+	}
+	writeMockError(w, http.StatusNotFound, "file %s not found in vector store %s", fileID, storeID)
+}
+
+// handleVectorStoreFileContent returns an attached file split into
+// paragraph chunks; PDFs are not parsed, so they come back as one raw chunk
+func (m *MockStack) handleVectorStoreFileContent(w http.ResponseWriter, r *http.Request) {
+	storeID, fileID := r.PathValue("vector_store_id"), r.PathValue("file_id")
+	m.mu.Lock()
+	var attached *VectorStoreFile
+	for i, f := range m.storeFiles[storeID] {
+		if f.ID == fileID {
+			attached = &m.storeFiles[storeID][i]
+			break
+		}
+	}
+	content := m.fileContent[fileID]
+	filename := ""
+	for _, f := range m.files {
+		if f.ID == fileID {
+			filename = f.Filename
+		}
+	}
+	m.mu.Unlock()
+	if attached == nil {
+		writeMockError(w, http.StatusNotFound, "file %s not found in vector store %s", fileID, storeID)
+		return
+	}
+
+	var chunks []map[string]interface{}
+	for _, paragraph := range strings.Split(string(content), "\n\n") {
+		if text := strings.TrimSpace(paragraph); text != "" {
+			chunks = append(chunks, map[string]interface{}{"type": "text", "text": text})
+		}
+	}
+	writeMockJSON(w, http.StatusOK, map[string]interface{}{
+		"file_id":    fileID,
+		"filename":   filename,
+		"attributes": attached.Attributes,
+		"content":    chunks,
+	})
+}
+
+func (m *MockStack) handleAttachFile(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		FileID           string                 `json:"file_id"`
+		Attributes       map[string]interface{} `json:"attributes"`
+		ChunkingStrategy interface{}            `json:"chunking_strategy"`
+	}
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+
+	m.mu.Lock()
+	vs, ok := m.vectorStores[r.PathValue("vector_store_id")]
+	var resp VectorStoreFile
+	if ok {
+		vs.FileCounts["completed"]++
+		vs.FileCounts["total"]++
+		resp = VectorStoreFile{
+			ID:               params.FileID,
+			Object:           "vector_store.file",
+			CreatedAt:        time.Now().Unix(),
+			VectorStoreID:    vs.ID,
+			Status:           "completed",
+			UsageBytes:       len(m.fileContent[params.FileID]),
+			Attributes:       params.Attributes,
+			ChunkingStrategy: params.ChunkingStrategy,
+		}
+		if resp.ChunkingStrategy == nil {
+			resp.ChunkingStrategy = map[string]interface{}{"type": "auto"}
+		}
+		m.storeFiles[vs.ID] = append(m.storeFiles[vs.ID], resp)
+	}
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "vector store %s not found", r.PathValue("vector_store_id"))
+		return
+	}
+
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+// handleDetachFile removes a file from a vector store along with the
+// documents rag-tool inserted for it
+func (m *MockStack) handleDetachFile(w http.ResponseWriter, r *http.Request) {
+	storeID, fileID := r.PathValue("vector_store_id"), r.PathValue("file_id")
+	m.mu.Lock()
+	detached := false
+	for i, f := range m.storeFiles[storeID] {
+		if f.ID == fileID {
+			m.storeFiles[storeID] = append(m.storeFiles[storeID][:i], m.storeFiles[storeID][i+1:]...)
+			detached = true
+			break
+		}
+	}
+	if vs, ok := m.vectorStores[storeID]; ok && detached {
+		vs.FileCounts["completed"]--
+		vs.FileCounts["total"]--
+	}
+	filename := ""
+	for _, f := range m.files {
+		if f.ID == fileID {
+			filename = f.Filename
+		}
+	}
+	var kept []Document
+	for _, doc := range m.documents[storeID] {
+		if doc.DocumentID != filename {
+			kept = append(kept, doc)
+		}
+	}
+	if detached {
+		m.documents[storeID] = kept
+	}
+	m.mu.Unlock()
+
+	if !detached {
+		writeMockError(w, http.StatusNotFound, "file %s not found in vector store %s", fileID, storeID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, map[string]interface{}{"id": fileID, "object": "vector_store.file.deleted", "deleted": true})
+}
+
+// handleRAGInsert stores each document as a file named after its
+// document_id and attached with its metadata, like the server does
+func (m *MockStack) handleRAGInsert(w http.ResponseWriter, r *http.Request) {
+	var params RagToolInsertParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+
+	m.mu.Lock()
+	m.documents[params.VectorDBID] = append(m.documents[params.VectorDBID], params.Documents...)
+	for _, doc := range params.Documents {
+		text, _ := doc.Content.(string)
+		file := FileResponse{
+			ID:        m.newID("file"),
+			Object:    "file",
+			Bytes:     len(text),
+			CreatedAt: time.Now().Unix(),
+			Filename:  doc.DocumentID,
+			Purpose:   "assistants",
+		}
+		m.files = append(m.files, file)
+		m.fileContent[file.ID] = []byte(text)
+		m.storeFiles[params.VectorDBID] = append(m.storeFiles[params.VectorDBID], VectorStoreFile{
+			ID:               file.ID,
+			Object:           "vector_store.file",
+			CreatedAt:        file.CreatedAt,
+			VectorStoreID:    params.VectorDBID,
+			Status:           "completed",
+			UsageBytes:       len(text),
+			Attributes:       doc.Metadata,
+			ChunkingStrategy: map[string]interface{}{"type": "auto"},
+		})
+	}
+	m.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *MockStack) handleRAGQuery(w http.ResponseWriter, r *http.Request) {
+	var params RagToolQueryParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+
+	m.mu.Lock()
+	hits := m.search(params.Content, params.VectorDBIDs)
+	m.mu.Unlock()
+
+	// Mirror the server's knowledge_search layout: a header, one item per
+	// chunk rendered with the default chunk template, and a footer
+	var content []interface{}
+	documentIDs, chunks, scores := []string{}, []string{}, []float64{}
+	if len(hits) > 0 {
+		content = append(content, map[string]interface{}{
+			"type": "text",
+			"text": fmt.Sprintf("knowledge_search tool found %d chunks:\nBEGIN of knowledge_search tool results.\n", len(hits)),
+		})
+		for i, doc := range hits {
+			text, _ := doc.Content.(string)
+			content = append(content, map[string]interface{}{
+				"type": "text",
+				"text": fmt.Sprintf("Result %d\nContent: %s\nMetadata: %s\n", i+1, text, formatChunkMetadata(Citation{DocumentID: doc.DocumentID, Metadata: doc.Metadata})),
+			})
+			documentIDs = append(documentIDs, doc.DocumentID)
+			chunks = append(chunks, text)
+			scores = append(scores, 1/float64(i+1))
+		}
+		content = append(content, map[string]interface{}{"type": "text", "text": "END of knowledge_search tool results.\n"})
+	}
+	writeMockJSON(w, http.StatusOK, QueryResult{
+		Content: content,
+		Metadata: map[string]interface{}{
+			"document_ids": documentIDs,
+			"chunks":       chunks,
+			"scores":       scores,
+		},
+	})
+}
+
+// search returns every inserted document sharing a word with the query;
+// callers hold m.mu. An empty store list searches every store, which keeps
+// the demo's fixed "my-documents" name working against generated IDs.
+func (m *MockStack) search(query string, storeIDs []string) []Document {
+	stores := storeIDs
+	if len(stores) == 0 || !m.hasDocuments(stores) {
+		stores = nil
+		for id := range m.documents {
+			stores = append(stores, id)
+		}
+		sort.Strings(stores)
+	}
+
+	words := strings.Fields(strings.ToLower(strings.Trim(query, "?.!")))
+	var hits []Document
+	for _, id := range stores {
+		for _, doc := range m.documents[id] {
+			text, ok := doc.Content.(string)
+			if !ok {
+				continue
+			}
+			lower := strings.ToLower(text)
+			for _, word := range words {
+				if len(word) > 2 && strings.Contains(lower, strings.TrimSuffix(word, "'s")) {
+					hits = append(hits, doc)
+					break
+				}
+			}
+		}
+	}
+	return hits
+}
+
+// hasDocuments reports whether any of the stores holds documents; callers hold m.mu
+func (m *MockStack) hasDocuments(storeIDs []string) bool {
+	for _, id := range storeIDs {
+		if len(m.documents[id]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockStack) handleChatCompletion(w http.ResponseWriter, r *http.Request) {
+	var params ChatCompletionParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+
+	for _, msg := range params.Messages {
+		if msg.Images() > 0 && !slices.ContainsFunc(m.Models, func(model Model) bool { return model.Identifier == params.Model && isVisionModel(model) }) {
+			writeMockError(w, http.StatusBadRequest, "model %s does not accept image input", params.Model)
+			return
+		}
+	}
+
+	m.mu.Lock()
+	id := m.newID("chatcmpl")
+	var answer string
+	if f := params.ResponseFormat; len(m.ChatReplies) == 0 && f != nil && f.JSONSchema != nil {
+		// Indented so streamed replies arrive a few fields at a time
+		data, _ := json.MarshalIndent(mockSchemaInstance(f.JSONSchema.Schema, f.JSONSchema.Schema), "", "  ")
+		answer = string(data)
+	} else {
+		answer = m.reply(params.Messages)
+	}
+	m.mu.Unlock()
+
+	if params.Stream == nil || !*params.Stream {
+		prompt := 0
+		for _, msg := range params.Messages {
+			prompt += estimateTokens(msg.Content)
+		}
+		completion := estimateTokens(answer)
+		resp := map[string]interface{}{
+			"id":      id,
+			"object":  "chat.completion",
+			"created": time.Now().Unix(),
+			"model":   params.Model,
+			"choices": []interface{}{
+				map[string]interface{}{
+					"index":         0,
+					"finish_reason": "stop",
+					"message":       map[string]interface{}{"role": "assistant", "content": answer},
+				},
+			},
+			"usage": map[string]interface{}{
+				"prompt_tokens":     prompt,
+				"completion_tokens": completion,
+				"total_tokens":      prompt + completion,
+			},
+		}
+		writeMockJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	words := strings.SplitAfter(answer, " ")
+	for i, word := range words {
+		choice := ChunkChoice{Delta: ChunkDelta{Content: word}}
+		if i == 0 {
+			choice.Delta.Role = "assistant"
+		}
+		if i == len(words)-1 {
+			choice.FinishReason = "stop"
+		}
+		writeMockSSE(w, ChatCompletionChunk{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: time.Now().Unix(),
+			Model:   params.Model,
+			Choices: []ChunkChoice{choice},
+		})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// handleEmbeddings returns 384-dimensional hashed bag-of-words vectors, so
+// texts sharing words are similar and the same text always embeds the same
+func (m *MockStack) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var params EmbeddingsRequest
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	resp := EmbeddingsResponse{Object: "list", Model: params.Model}
+	for i, input := range params.Input {
+		vec := make([]float64, 384)
+		for _, word := range strings.Fields(strings.ToLower(input)) {
+			h := fnv.New32a()
+			h.Write([]byte(strings.Trim(word, ".,!?")))
+			vec[h.Sum32()%384]++
+		}
+		resp.Data = append(resp.Data, EmbeddingData{Object: "embedding", Embedding: vec, Index: i})
+		resp.Usage.PromptTokens += estimateTokens(input)
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+func (m *MockStack) handleListDatasets(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	response := ListDatasetsResponse{Data: []Dataset{}}
+	for _, d := range m.datasets {
+		response.Data = append(response.Data, d.Dataset)
+	}
+	m.mu.Unlock()
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].Identifier < response.Data[j].Identifier })
+	writeMockJSON(w, http.StatusOK, response)
+}
+
+// handleRegisterDataset keeps the rows of a rows source; a uri source is
+// registered without rows
+func (m *MockStack) handleRegisterDataset(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		DatasetID string                 `json:"dataset_id"`
+		Purpose   SpecDatasetPurpose     `json:"purpose"`
+		Source    map[string]interface{} `json:"source"`
+		Metadata  map[string]interface{} `json:"metadata"`
+	}
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	var source struct {
+		Rows []map[string]interface{} `json:"rows"`
+	}
+	data, _ := json.Marshal(params.Source)
+	json.Unmarshal(data, &source)
+
+	m.mu.Lock()
+	if params.DatasetID == "" {
+		params.DatasetID = m.newID("dataset")
+	}
+	d := &mockDataset{
+		Dataset: Dataset{
+			Identifier:         params.DatasetID,
+			ProviderResourceID: params.DatasetID,
+			ProviderID:         "localfs",
+			Type:               "dataset",
+			Purpose:            params.Purpose,
+			Source:             params.Source,
+			Metadata:           params.Metadata,
+		},
+		rows: source.Rows,
+	}
+	m.datasets[params.DatasetID] = d
+	m.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, d.Dataset)
+}
+
+func (m *MockStack) handleGetDataset(w http.ResponseWriter, r *http.Request) {
+	datasetID := r.PathValue("dataset_id")
+	m.mu.Lock()
+	d, ok := m.datasets[datasetID]
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "dataset %s not found", datasetID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, d.Dataset)
+}
+
+func (m *MockStack) handleDeleteDataset(w http.ResponseWriter, r *http.Request) {
+	datasetID := r.PathValue("dataset_id")
+	m.mu.Lock()
+	_, ok := m.datasets[datasetID]
+	delete(m.datasets, datasetID)
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "dataset %s not found", datasetID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, nil)
+}
+
+func (m *MockStack) handleIterRows(w http.ResponseWriter, r *http.Request) {
+	datasetID := r.PathValue("dataset_id")
+	start, _ := strconv.Atoi(r.URL.Query().Get("start_index"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = -1
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.datasets[datasetID]
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "dataset %s not found", datasetID)
+		return
+	}
+	page := DatasetRowsPage{Data: []map[string]interface{}{}}
+	if start < len(d.rows) {
+		end := len(d.rows)
+		if limit > 0 && start+limit < end {
+			end = start + limit
+		}
+		page.Data = d.rows[start:end]
+		page.HasMore = end < len(d.rows)
+	}
+	writeMockJSON(w, http.StatusOK, page)
+}
+
+func (m *MockStack) handleAppendRows(w http.ResponseWriter, r *http.Request) {
+	datasetID := r.PathValue("dataset_id")
+	var params SpecAppendRowsRequest
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.datasets[datasetID]
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "dataset %s not found", datasetID)
+		return
+	}
+	d.rows = append(d.rows, params.Rows...)
+	writeMockJSON(w, http.StatusOK, nil)
+}
+
+// handleSyntheticData turns each user message of the dialog into a
+// question-answer sample answered by the mock reply; filtering is ignored
+func (m *MockStack) handleSyntheticData(w http.ResponseWriter, r *http.Request) {
+	var params SyntheticDataRequest
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	response := SyntheticDataResponse{SyntheticData: []map[string]interface{}{}}
+	m.mu.Lock()
+	for _, msg := range params.Dialogs {
+		if msg.Role != "user" {
+			continue
+		}
+		response.SyntheticData = append(response.SyntheticData, map[string]interface{}{
+			"input_query":     msg.Content,
+			"expected_answer": m.reply([]Message{msg}),
+			"score":           1.0,
+		})
+	}
+	m.mu.Unlock()
+	n := len(response.SyntheticData)
+	response.Statistics = map[string]interface{}{"generated": n, "kept": n, "filtering_function": params.FilteringFunction}
+	writeMockJSON(w, http.StatusOK, response)
+}
+
+func (m *MockStack) handleListBenchmarks(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	response := ListBenchmarksResponse{Data: []Benchmark{}}
+	for _, b := range m.benchmarks {
+		response.Data = append(response.Data, b)
+	}
+	m.mu.Unlock()
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].Identifier < response.Data[j].Identifier })
+	writeMockJSON(w, http.StatusOK, response)
+}
+
+func (m *MockStack) handleRegisterBenchmark(w http.ResponseWriter, r *http.Request) {
+	var params RegisterBenchmarkParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	m.mu.Lock()
+	m.benchmarks[params.BenchmarkID] = Benchmark{
+		Identifier:         params.BenchmarkID,
+		ProviderResourceID: params.BenchmarkID,
+		ProviderID:         "meta-reference",
+		Type:               "benchmark",
+		DatasetID:          params.DatasetID,
+		ScoringFunctions:   params.ScoringFunctions,
+		Metadata:           params.Metadata,
+	}
+	m.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, nil)
+}
+
+func (m *MockStack) handleGetBenchmark(w http.ResponseWriter, r *http.Request) {
+	benchmarkID := r.PathValue("benchmark_id")
+	m.mu.Lock()
+	b, ok := m.benchmarks[benchmarkID]
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "benchmark %s not found", benchmarkID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, b)
+}
+
+// handleRunEval answers the input_query of each row of the benchmark's
+// dataset, or three made-up questions without one, with the mock reply and
+// scores every answer as correct
+func (m *MockStack) handleRunEval(w http.ResponseWriter, r *http.Request) {
+	benchmarkID := r.PathValue("benchmark_id")
+	var params struct {
+		BenchmarkConfig struct {
+			EvalCandidate SpecModelCandidate `json:"eval_candidate"`
+			NumExamples   *int               `json:"num_examples"`
+		} `json:"benchmark_config"`
+	}
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.benchmarks[benchmarkID]
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "benchmark %s not found", benchmarkID)
+		return
+	}
+	questions := []string{"Question 1", "Question 2", "Question 3"}
+	if d, ok := m.datasets[b.DatasetID]; ok {
+		questions = questions[:0]
+		for _, row := range d.rows {
+			question, _ := row["input_query"].(string)
+			questions = append(questions, question)
+		}
+	}
+	if num := params.BenchmarkConfig.NumExamples; num != nil && *num < len(questions) {
+		questions = questions[:*num]
+	}
+	n := len(questions)
+	job := &mockEvalJob{benchmarkID: benchmarkID, status: EvalJobInProgress}
+	job.result.Scores = make(map[string]ScoringResult)
+	rows := make([]map[string]interface{}, n)
+	for i, question := range questions {
+		job.result.Generations = append(job.result.Generations, map[string]interface{}{
+			"generated_answer": m.reply([]Message{{Role: "user", Content: question}}),
+		})
+		rows[i] = map[string]interface{}{"score": 1.0}
+	}
+	for _, fn := range b.ScoringFunctions {
+		job.result.Scores[fn] = ScoringResult{
+			ScoreRows:         rows,
+			AggregatedResults: map[string]interface{}{"accuracy": map[string]interface{}{"accuracy": 1.0, "num_correct": n, "num_total": n}},
+		}
+	}
+	id := m.newID("job")
+	m.evalJobs[id] = job
+	writeMockJSON(w, http.StatusOK, EvalJob{JobID: id, Status: EvalJobScheduled})
+}
+
+func (m *MockStack) evalJob(w http.ResponseWriter, r *http.Request) *mockEvalJob {
+	jobID := r.PathValue("job_id")
+	job, ok := m.evalJobs[jobID]
+	if !ok || job.benchmarkID != r.PathValue("benchmark_id") {
+		writeMockError(w, http.StatusNotFound, "job %s not found", jobID)
+		return nil
+	}
+	return job
+}
+
+func (m *MockStack) handleGetEvalJob(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.evalJob(w, r)
+	if job == nil {
+		return
+	}
+	status := job.status
+	if job.status == EvalJobInProgress {
+		job.status = EvalJobCompleted
+	}
+	writeMockJSON(w, http.StatusOK, EvalJob{JobID: r.PathValue("job_id"), Status: status})
+}
+
+func (m *MockStack) handleCancelEvalJob(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job := m.evalJob(w, r); job != nil {
+		job.status = EvalJobCancelled
+		writeMockJSON(w, http.StatusOK, nil)
+	}
+}
+
+func (m *MockStack) handleEvalJobResult(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.evalJob(w, r)
+	if job == nil {
+		return
+	}
+	if job.status != EvalJobCompleted {
+		writeMockError(w, http.StatusBadRequest, "job %s is %s", r.PathValue("job_id"), job.status)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, job.result)
+}
+
+// mockPromptVariablesError reports variables used in text but not declared,
+// which the server rejects
+func mockPromptVariablesError(text string, declared []string) string {
+	var undeclared []string
+	for _, name := range PromptVariables(text) {
+		if !slices.Contains(declared, name) {
+			undeclared = append(undeclared, name)
+		}
+	}
+	if len(undeclared) == 0 {
+		return ""
+	}
+	return "undeclared variables: " + strings.Join(undeclared, ", ")
+}
+
+// defaultPrompt returns the default version of a stored prompt; callers hold m.mu
+func (m *MockStack) defaultPrompt(promptID string) (Prompt, bool) {
+	for _, p := range m.prompts[promptID] {
+		if p.IsDefault {
+			return p, true
+		}
+	}
+	return Prompt{}, false
+}
+
+func (m *MockStack) handleListPrompts(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	resp := ListPromptsResponse{Data: []Prompt{}}
+	for id := range m.prompts {
+		p, _ := m.defaultPrompt(id)
+		resp.Data = append(resp.Data, p)
+	}
+	m.mu.Unlock()
+	sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].PromptID < resp.Data[j].PromptID })
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+func (m *MockStack) handleCreatePrompt(w http.ResponseWriter, r *http.Request) {
+	var params CreatePromptParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	if msg := mockPromptVariablesError(params.Prompt, params.Variables); msg != "" {
+		writeMockError(w, http.StatusBadRequest, "%s", msg)
+		return
+	}
+	m.mu.Lock()
+	p := Prompt{PromptID: m.newID("pmpt"), Prompt: params.Prompt, Version: 1, Variables: params.Variables, IsDefault: true}
+	m.prompts[p.PromptID] = []Prompt{p}
+	m.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, p)
+}
+
+func (m *MockStack) handleGetPrompt(w http.ResponseWriter, r *http.Request) {
+	promptID := r.PathValue("prompt_id")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	versions, ok := m.prompts[promptID]
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "prompt %s not found", promptID)
+		return
+	}
+	if v := r.URL.Query().Get("version"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil || version < 1 || version > len(versions) {
+			writeMockError(w, http.StatusNotFound, "prompt %s has no version %s", promptID, v)
+			return
+		}
+		writeMockJSON(w, http.StatusOK, versions[version-1])
+		return
+	}
+	p, _ := m.defaultPrompt(promptID)
+	writeMockJSON(w, http.StatusOK, p)
+}
+
+func (m *MockStack) handleUpdatePrompt(w http.ResponseWriter, r *http.Request) {
+	promptID := r.PathValue("prompt_id")
+	var params UpdatePromptParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	if msg := mockPromptVariablesError(params.Prompt, params.Variables); msg != "" {
+		writeMockError(w, http.StatusBadRequest, "%s", msg)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	versions, ok := m.prompts[promptID]
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "prompt %s not found", promptID)
+		return
+	}
+	if params.Version != len(versions) {
+		writeMockError(w, http.StatusBadRequest, "version %d is not the latest version %d of prompt %s", params.Version, len(versions), promptID)
+		return
+	}
+	p := Prompt{PromptID: promptID, Prompt: params.Prompt, Version: len(versions) + 1, Variables: params.Variables}
+	if params.SetAsDefault {
+		for i := range versions {
+			versions[i].IsDefault = false
+		}
+		p.IsDefault = true
+	}
+	m.prompts[promptID] = append(versions, p)
+	writeMockJSON(w, http.StatusOK, p)
+}
+
+func (m *MockStack) handleDeletePrompt(w http.ResponseWriter, r *http.Request) {
+	promptID := r.PathValue("prompt_id")
+	m.mu.Lock()
+	_, ok := m.prompts[promptID]
+	delete(m.prompts, promptID)
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "prompt %s not found", promptID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, nil)
+}
+
+func (m *MockStack) handleListPromptVersions(w http.ResponseWriter, r *http.Request) {
+	promptID := r.PathValue("prompt_id")
+	m.mu.Lock()
+	versions, ok := m.prompts[promptID]
+	resp := ListPromptsResponse{Data: append([]Prompt(nil), versions...)}
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "prompt %s not found", promptID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+func (m *MockStack) handleSetDefaultPromptVersion(w http.ResponseWriter, r *http.Request) {
+	promptID := r.PathValue("prompt_id")
+	var params struct {
+		Version int `json:"version"`
+	}
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	versions, ok := m.prompts[promptID]
+	if !ok || params.Version < 1 || params.Version > len(versions) {
+		writeMockError(w, http.StatusNotFound, "prompt %s has no version %d", promptID, params.Version)
+		return
+	}
+	for i := range versions {
+		versions[i].IsDefault = versions[i].Version == params.Version
+	}
+	writeMockJSON(w, http.StatusOK, versions[params.Version-1])
+}
+
+// mockResponse is a stored Responses API response
+type mockResponse struct {
+	ResponseObject
+	history []ConversationItem // context and output, for previous_response_id
+
+	// request of a background response that has not run yet
+	params mockResponseParams
+	input  []ConversationItem
+}
+
+// mockConversation is a stored conversation with its items, oldest first
+type mockConversation struct {
+	ConversationInfo
+	items []ConversationItem
+}
+
+// storeItems gives items IDs and appends them to conv; callers hold m.mu
+func (m *MockStack) storeItems(conv *mockConversation, items []ConversationItem) []ConversationItem {
+	stored := make([]ConversationItem, len(items))
+	for i, item := range items {
+		if item.ID == "" {
+			item.ID = m.newID("item")
+		}
+		if item.Status == "" {
+			item.Status = "completed"
+		}
+		stored[i] = item
+	}
+	conv.items = append(conv.items, stored...)
+	return stored
+}
+
+// mockItemsPage writes items as one list page
+func mockItemsPage(items []ConversationItem) ListConversationItemsResponse {
+	page := ListConversationItemsResponse{Object: "list", Data: items}
+	if len(items) > 0 {
+		page.FirstID, page.LastID = items[0].ID, items[len(items)-1].ID
+	}
+	return page
+}
+
+func (m *MockStack) handleCreateConversation(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Items    []ConversationItem `json:"items"`
+		Metadata map[string]string  `json:"metadata"`
+	}
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	m.mu.Lock()
+	conv := &mockConversation{ConversationInfo: ConversationInfo{
+		ID:        m.newID("conv"),
+		Object:    "conversation",
+		CreatedAt: time.Now().Unix(),
+		Metadata:  params.Metadata,
+	}}
+	m.storeItems(conv, params.Items)
+	m.conversations[conv.ID] = conv
+	info := conv.ConversationInfo
+	m.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, info)
+}
+
+func (m *MockStack) handleGetConversation(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	conv, ok := m.conversations[r.PathValue("conversation_id")]
+	var info ConversationInfo
+	if ok {
+		info = conv.ConversationInfo
+	}
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "conversation %s not found", r.PathValue("conversation_id"))
+		return
+	}
+	writeMockJSON(w, http.StatusOK, info)
+}
+
+func (m *MockStack) handleUpdateConversation(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Metadata map[string]string `json:"metadata"`
+	}
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	m.mu.Lock()
+	conv, ok := m.conversations[r.PathValue("conversation_id")]
+	var info ConversationInfo
+	if ok {
+		conv.Metadata = params.Metadata
+		info = conv.ConversationInfo
+	}
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "conversation %s not found", r.PathValue("conversation_id"))
+		return
+	}
+	writeMockJSON(w, http.StatusOK, info)
+}
+
+func (m *MockStack) handleDeleteConversation(w http.ResponseWriter, r *http.Request) {
+	conversationID := r.PathValue("conversation_id")
+	m.mu.Lock()
+	_, ok := m.conversations[conversationID]
+	delete(m.conversations, conversationID)
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "conversation %s not found", conversationID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, map[string]interface{}{"id": conversationID, "object": "conversation.deleted", "deleted": true})
+}
+
+func (m *MockStack) handleAddConversationItems(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Items []ConversationItem `json:"items"`
+	}
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	m.mu.Lock()
+	conv, ok := m.conversations[r.PathValue("conversation_id")]
+	var stored []ConversationItem
+	if ok {
+		stored = m.storeItems(conv, params.Items)
+	}
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "conversation %s not found", r.PathValue("conversation_id"))
+		return
+	}
+	writeMockJSON(w, http.StatusOK, mockItemsPage(stored))
+}
+
+func (m *MockStack) handleListConversationItems(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	conv, ok := m.conversations[r.PathValue("conversation_id")]
+	var items []ConversationItem
+	if ok {
+		items = append(items, conv.items...)
+	}
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "conversation %s not found", r.PathValue("conversation_id"))
+		return
+	}
+
+	// Newest first unless order=asc, like the server
+	if r.URL.Query().Get("order") != "asc" {
+		slices.Reverse(items)
+	}
+	if after := r.URL.Query().Get("after"); after != "" {
+		for i, item := range items {
+			if item.ID == after {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+	hasMore := false
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit > 0 && len(items) > limit {
+		items, hasMore = items[:limit], true
+	}
+	page := mockItemsPage(items)
+	page.HasMore = hasMore
+	writeMockJSON(w, http.StatusOK, page)
+}
+
+// mockResponseInput decodes the input of a Responses API call: a string, or
+// items whose message content is a string or a list of content parts
+func mockResponseInput(raw json.RawMessage) ([]ConversationItem, error) {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return []ConversationItem{MessageItem("user", text)}, nil
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, errors.New("input must be a string or a list of items")
+	}
+	items := make([]ConversationItem, 0, len(entries))
+	for _, entry := range entries {
+		if content, ok := entry["content"].(string); ok {
+			role, _ := entry["role"].(string)
+			items = append(items, MessageItem(role, content))
+			continue
+		}
+		data, _ := json.Marshal(entry)
+		var item ConversationItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("invalid input item: %v", err)
+		}
+		if item.Type == "" {
+			item.Type = "message"
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// mockResponseParams is the part of a Responses API request the mock reads
+type mockResponseParams struct {
+	Model              string                   `json:"model"`
+	Input              json.RawMessage          `json:"input"`
+	Instructions       string                   `json:"instructions"`
+	Conversation       string                   `json:"conversation"`
+	PreviousResponseID string                   `json:"previous_response_id"`
+	Stream             bool                     `json:"stream"`
+	Background         bool                     `json:"background"`
+	Tools              []map[string]interface{} `json:"tools"`
+	Include            []string                 `json:"include"`
+}
+
+func (m *MockStack) handleCreateResponse(w http.ResponseWriter, r *http.Request) {
+	var params mockResponseParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+	input, err := mockResponseInput(params.Input)
+	if err != nil {
+		writeMockError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	if params.Conversation != "" && params.PreviousResponseID != "" {
+		writeMockError(w, http.StatusBadRequest, "conversation and previous_response_id cannot both be set")
+		return
+	}
+
+	m.mu.Lock()
+	if params.Background && !params.Stream {
+		// Queued now and run over the next polls, like a busy server
+		resp := ResponseObject{ID: m.newID("resp"), Object: "response", CreatedAt: time.Now().Unix(), Model: params.Model, Status: "queued", Output: []ConversationItem{}, Background: true}
+		m.responses[resp.ID] = &mockResponse{ResponseObject: resp, params: params, input: input}
+		m.mu.Unlock()
+		writeMockJSON(w, http.StatusOK, resp)
+		return
+	}
+	resp, status, msg := m.createResponse("", params, input)
+	m.mu.Unlock()
+	if status != http.StatusOK {
+		writeMockError(w, status, "%s", msg)
+		return
+	}
+	if !params.Stream {
+		writeMockJSON(w, http.StatusOK, resp)
+		return
+	}
+	writeMockResponseStream(w, resp)
+}
+
+// createResponse answers input in the context of the conversation or previous
+// response and stores the result under id, or a new ID when empty; callers
+// hold m.mu
+func (m *MockStack) createResponse(id string, params mockResponseParams, input []ConversationItem) (ResponseObject, int, string) {
+	var history []ConversationItem
+	conv, ok := m.conversations[params.Conversation]
+	switch {
+	case params.Conversation != "" && !ok:
+		return ResponseObject{}, http.StatusNotFound, fmt.Sprintf("conversation %s not found", params.Conversation)
+	case ok:
+		history = conv.items
+	case params.PreviousResponseID != "":
+		prev, ok := m.responses[params.PreviousResponseID]
+		if !ok {
+			return ResponseObject{}, http.StatusNotFound, fmt.Sprintf("response %s not found", params.PreviousResponseID)
+		}
+		if prev.Status != "completed" {
+			return ResponseObject{}, http.StatusBadRequest, fmt.Sprintf("response %s is %s", params.PreviousResponseID, prev.Status)
+		}
+		history = prev.history
+	}
+
+	var messages []Message
+	if params.Instructions != "" {
+		messages = append(messages, Message{Role: "system", Content: params.Instructions})
+	}
+	for _, item := range slices.Concat(history, input) {
+		if item.Type == "message" {
+			messages = append(messages, Message{Role: item.Role, Content: item.Text()})
+		}
+	}
+	reply := MessageItem("assistant", m.reply(messages))
+	if conv != nil {
+		m.storeItems(conv, input)
+	}
+	var output []ConversationItem
+	for _, tool := range params.Tools {
+		if tool["type"] != "file_search" {
+			continue
+		}
+		call, results := m.fileSearch(lastUserText(input), tool, slices.Contains(params.Include, IncludeFileSearchResults))
+		output = append(output, call)
+		// Cite each retrieved file at the end of the answer
+		for _, result := range results {
+			reply.Content[0].Annotations = append(reply.Content[0].Annotations, Annotation{Type: "file_citation", FileID: result.FileID, Filename: result.Filename, Index: len(reply.Content[0].Text)})
+		}
+	}
+	reply.ID, reply.Status = m.newID("msg"), "completed"
+	output = append(output, reply)
+	if conv != nil {
+		m.storeItems(conv, output)
+	}
+
+	if id == "" {
+		id = m.newID("resp")
+	}
+	resp := ResponseObject{
+		ID:                 id,
+		Object:             "response",
+		CreatedAt:          time.Now().Unix(),
+		Model:              params.Model,
+		Status:             "completed",
+		Output:             output,
+		PreviousResponseID: params.PreviousResponseID,
+	}
+	var prompt strings.Builder
+	for _, msg := range messages {
+		prompt.WriteString(msg.Content + "\n")
+	}
+	usage := ResponseUsage{InputTokens: CountTokens(params.Model, prompt.String()), OutputTokens: CountTokens(params.Model, reply.Text())}
+	usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	resp.Usage = &usage
+	resp.Background = params.Background
+	m.responses[resp.ID] = &mockResponse{ResponseObject: resp, history: slices.Concat(history, input, resp.Output)}
+	return resp, http.StatusOK, ""
+}
+
+// lastUserText returns the text of the last user message among items
+func lastUserText(items []ConversationItem) string {
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].Type == "message" && items[i].Role == "user" {
+			return items[i].Text()
+		}
+	}
+	return ""
+}
+
+// fileSearch runs a file_search tool call: it returns the files attached to
+// the tool's stores that match its filters and share a word with query.
+// Results are left out of the call item unless included. Callers hold m.mu.
+func (m *MockStack) fileSearch(query string, tool map[string]interface{}, include bool) (ConversationItem, []FileSearchResult) {
+	var config FileSearchTool
+	data, _ := json.Marshal(tool)
+	json.Unmarshal(data, &config)
+	limit := config.MaxNumResults
+	if limit <= 0 {
+		limit = 10
+	}
+
+	words := strings.Fields(strings.ToLower(strings.Trim(query, "?.!")))
+	var results []FileSearchResult
+	for _, storeID := range config.VectorStoreIDs {
+		for _, f := range m.storeFiles[storeID] {
+			if config.Filters != nil && !config.Filters.Match(f.Attributes) {
+				continue
+			}
+			text := string(m.fileContent[f.ID])
+			lower := strings.ToLower(text)
+			if !slices.ContainsFunc(words, func(word string) bool { return len(word) > 2 && strings.Contains(lower, word) }) {
+				continue
+			}
+			result := FileSearchResult{FileID: f.ID, Text: text, Attributes: f.Attributes}
+			for _, file := range m.files {
+				if file.ID == f.ID {
+					result.Filename = file.Filename
+				}
+			}
+			results = append(results, result)
+		}
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	for i := range results {
+		results[i].Score = 1 / float64(i+1)
+	}
+
+	call := ConversationItem{Type: "file_search_call", ID: m.newID("fs"), Status: "completed", Queries: []string{query}}
+	if include {
+		call.Results = results
+	}
+	return call, results
+}
+
+func (m *MockStack) handleGetResponse(w http.ResponseWriter, r *http.Request) {
+	responseID := r.PathValue("response_id")
+	m.mu.Lock()
+	stored, ok := m.responses[responseID]
+	if ok {
+		// Each poll moves a background response one step along
+		switch stored.Status {
+		case "queued":
+			stored.Status = "in_progress"
+		case "in_progress":
+			created := stored.CreatedAt
+			if _, status, msg := m.createResponse(responseID, stored.params, stored.input); status != http.StatusOK {
+				stored.Status, stored.Error = "failed", &ResponseError{Code: "invalid_request", Message: msg}
+			} else {
+				stored = m.responses[responseID] // replaced with the finished response
+				stored.CreatedAt = created
+			}
+		}
+	}
+	var resp ResponseObject
+	if ok {
+		resp = stored.ResponseObject
+	}
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "response %s not found", responseID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+func (m *MockStack) handleCancelResponse(w http.ResponseWriter, r *http.Request) {
+	responseID := r.PathValue("response_id")
+	m.mu.Lock()
+	stored, ok := m.responses[responseID]
+	var resp ResponseObject
+	status, msg := http.StatusOK, ""
+	switch {
+	case !ok:
+		status, msg = http.StatusNotFound, fmt.Sprintf("response %s not found", responseID)
+	case !stored.Background:
+		status, msg = http.StatusBadRequest, "only background responses can be cancelled"
+	case stored.Status == "queued" || stored.Status == "in_progress":
+		stored.Status = "cancelled"
+		fallthrough
+	default:
+		resp = stored.ResponseObject
+	}
+	m.mu.Unlock()
+	if status != http.StatusOK {
+		writeMockError(w, status, "%s", msg)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, resp)
+}
+
+// writeMockResponseStream writes resp as the event sequence of a streamed
+// Responses API call, with each message's text sent a word at a time
+func writeMockResponseStream(w http.ResponseWriter, resp ResponseObject) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	seq := 0
+	event := func(eventType string, fields map[string]interface{}) {
+		seq++
+		fields["type"], fields["sequence_number"] = eventType, seq
+		writeMockSSE(w, fields)
+	}
+
+	started := resp
+	started.Status, started.Output, started.Usage = "in_progress", []ConversationItem{}, nil
+	event("response.created", map[string]interface{}{"response": started})
+	event("response.in_progress", map[string]interface{}{"response": started})
+	for i, item := range resp.Output {
+		pending := item
+		pending.Status, pending.Content, pending.Results = "in_progress", nil, nil
+		event("response.output_item.added", map[string]interface{}{"output_index": i, "item": pending})
+		if tool, ok := strings.CutSuffix(item.Type, "_call"); ok && item.Type != "function_call" {
+			for _, status := range []string{"in_progress", "searching", "completed"} {
+				event("response."+tool+"_call."+status, map[string]interface{}{"item_id": item.ID, "output_index": i})
+			}
+		}
+		for j, part := range item.Content {
+			ids := func(fields map[string]interface{}) map[string]interface{} {
+				fields["item_id"], fields["output_index"], fields["content_index"] = item.ID, i, j
+				return fields
+			}
+			event("response.content_part.added", ids(map[string]interface{}{"part": ConversationContent{Type: part.Type}}))
+			for _, word := range strings.SplitAfter(part.Text, " ") {
+				event("response.output_text.delta", ids(map[string]interface{}{"delta": word}))
+			}
+			event("response.output_text.done", ids(map[string]interface{}{"text": part.Text}))
+			event("response.content_part.done", ids(map[string]interface{}{"part": part}))
+		}
+		event("response.output_item.done", map[string]interface{}{"output_index": i, "item": item})
+	}
+	event("response.completed", map[string]interface{}{"response": resp})
+}
+
+func (m *MockStack) handleListAgents(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	response := ListAgentsResponse{Data: []AgentInfo{}}
+	for id, config := range m.agents {
+		response.Data = append(response.Data, AgentInfo{AgentID: id, AgentConfig: config})
+	}
+	m.mu.Unlock()
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].AgentID < response.Data[j].AgentID })
+	writeMockJSON(w, http.StatusOK, response)
+}
+
+func (m *MockStack) handleGetAgent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("agent_id")
+	m.mu.Lock()
+	config, ok := m.agents[id]
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "agent %s not found", id)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, AgentInfo{AgentID: id, AgentConfig: config})
+}
+
+func (m *MockStack) handleCreateAgent(w http.ResponseWriter, r *http.Request) {
+	var params AgentCreateParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+
+	m.mu.Lock()
+	id := m.newID("agent")
+	m.agents[id] = params.AgentConfig
+	m.mu.Unlock()
+
+	writeMockJSON(w, http.StatusOK, APIResponse{AgentID: id})
+}
+
+func (m *MockStack) handleDeleteAgent(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	_, ok := m.agents[r.PathValue("agent_id")]
+	delete(m.agents, r.PathValue("agent_id"))
+	m.mu.Unlock()
+
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "agent %s not found", r.PathValue("agent_id"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *MockStack) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var params SessionCreateParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+
+	agentID := r.PathValue("agent_id")
+	m.mu.Lock()
+	_, ok := m.agents[agentID]
+	session := Session{
+		SessionID:   m.newID("session"),
+		AgentID:     agentID,
+		SessionName: params.SessionName,
+		CreatedAt:   time.Now().Unix(),
+	}
+	if ok {
+		m.sessions[session.SessionID] = session
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "agent %s not found", agentID)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, session)
+}
+
+func (m *MockStack) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	agentID, sessionID := r.PathValue("agent_id"), r.PathValue("session_id")
+	m.mu.Lock()
+	session, ok := m.sessions[sessionID]
+	turns := []Turn{}
+	for _, id := range m.sessionTurns[sessionID] {
+		turns = append(turns, m.turns[id])
+	}
+	m.mu.Unlock()
+
+	if !ok || session.AgentID != agentID {
+		writeMockError(w, http.StatusNotFound, "session %s not found for agent %s", sessionID, agentID)
+		return
+	}
+	session.Turns = turns
+	session.StartedAt = time.Unix(session.CreatedAt, 0).Format(time.RFC3339)
+	writeMockJSON(w, http.StatusOK, session)
+}
+
+func (m *MockStack) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("agent_id")
+	m.mu.Lock()
+	_, ok := m.agents[agentID]
+	response := ListSessionsResponse{Data: []Session{}}
+	for _, session := range m.sessions {
+		if session.AgentID == agentID {
+			session.StartedAt = time.Unix(session.CreatedAt, 0).Format(time.RFC3339)
+			response.Data = append(response.Data, session)
+		}
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "agent %s not found", agentID)
+		return
+	}
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].SessionID < response.Data[j].SessionID })
+	writeMockJSON(w, http.StatusOK, response)
+}
+
+func (m *MockStack) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	agentID, sessionID := r.PathValue("agent_id"), r.PathValue("session_id")
+	m.mu.Lock()
+	session, ok := m.sessions[sessionID]
+	ok = ok && session.AgentID == agentID
+	if ok {
+		delete(m.sessions, sessionID)
+		delete(m.sessionTurns, sessionID)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "session %s not found for agent %s", sessionID, agentID)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *MockStack) handleCreateTurn(w http.ResponseWriter, r *http.Request) {
+	var params TurnCreateParams
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+
+	agentID, sessionID := r.PathValue("agent_id"), r.PathValue("session_id")
+	m.mu.Lock()
+	agent, ok := m.agents[agentID]
+	if _, exists := m.sessions[sessionID]; !exists {
+		ok = false
+	}
+	turnID := m.newID("turn")
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "session %s not found for agent %s", sessionID, agentID)
+		return
+	}
+
+	webSearch := mockAgentHasToolgroup(agent, "builtin::websearch") && len(params.Messages) > 0
+	if webSearch && !mockSearchAPIKey(r) {
+		writeMockError(w, http.StatusBadRequest, "pass a search provider's API key in the %s header, e.g. {\"tavily_search_api_key\": <your api key>}", ProviderDataHeader)
+		return
+	}
+
+	stream := params.Stream != nil && *params.Stream
+	if stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeMockTurnEvent(w, map[string]interface{}{"event_type": "turn_start", "turn_id": turnID})
+	}
+
+	if webSearch {
+		// The server runs web_search itself and answers from its results
+		step, snippet := mockWebSearchStep(turnID, params.Messages[len(params.Messages)-1].Content)
+		writeMockStepEvents(w, stream, step, true)
+		m.completeTurn(w, stream, turnID, sessionID, params.Messages, []interface{}{step}, "According to the web: "+snippet, nil)
+		return
+	}
+	if mockAgentHasToolgroup(agent, "builtin::code_interpreter") && len(params.Messages) > 0 {
+		step, attachments := m.codeInterpreterStep(turnID, params.Messages[len(params.Messages)-1].Content)
+		writeMockStepEvents(w, stream, step, true)
+		m.completeTurn(w, stream, turnID, sessionID, params.Messages, []interface{}{step}, "", attachments)
+		return
+	}
+	// Pause with a call for the client to execute: knowledge_search for RAG
+	// agents, else the first client tool with the prompt as its first parameter
+	var toolName, source string
+	var args map[string]interface{}
+	switch {
+	case len(params.Messages) == 0:
+	case mockAgentHasToolgroup(agent, "builtin::rag"):
+		toolName, source = "knowledge_search", "the documents"
+		args = map[string]interface{}{"query": params.Messages[len(params.Messages)-1].Content}
+	case len(agent.ClientTools) > 0:
+		tool := agent.ClientTools[0]
+		toolName, source = tool.Name, tool.Name
+		args = map[string]interface{}{}
+		if len(tool.Parameters) > 0 {
+			args[tool.Parameters[0].Name] = params.Messages[len(params.Messages)-1].Content
+		}
+	}
+	if toolName == "" {
+		m.completeTurn(w, stream, turnID, sessionID, params.Messages, nil, "", nil)
+		return
+	}
+
+	steps := []interface{}{
+		map[string]interface{}{
+			"step_type": "tool_execution",
+			"step_id":   turnID + "-tool",
+			"turn_id":   turnID,
+			"tool_calls": []interface{}{
+				map[string]interface{}{
+					"call_id":   turnID + "-call-1",
+					"tool_name": toolName,
+					"arguments": args,
+				},
+			},
+		},
+	}
+	m.mu.Lock()
+	m.pending[turnID] = pendingTurn{sessionID: sessionID, input: params.Messages, steps: steps, source: source}
+	m.mu.Unlock()
+
+	m.writeTurn(w, stream, "turn_awaiting_input", Turn{
+		TurnID:        turnID,
+		SessionID:     sessionID,
+		InputMessages: params.Messages,
+		Steps:         steps,
+		StartedAt:     time.Now().Format(time.RFC3339),
+	})
+}
+
+func (m *MockStack) handleResumeTurn(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		ToolResponses []struct {
+			CallID   string `json:"call_id"`
+			ToolName string `json:"tool_name"`
+			Content  struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"tool_responses"`
+		Stream *bool `json:"stream"`
+	}
+	if !decodeMockJSON(w, r, &params) {
+		return
+	}
+
+	turnID := r.PathValue("turn_id")
+	m.mu.Lock()
+	pending, ok := m.pending[turnID]
+	delete(m.pending, turnID)
+	m.mu.Unlock()
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "turn %s is not awaiting input", turnID)
+		return
+	}
+
+	// Answer from the chunk contents when the client forwarded knowledge_search output
+	var texts []string
+	var responses []interface{}
+	for _, tr := range params.ToolResponses {
+		responses = append(responses, map[string]interface{}{
+			"call_id":   tr.CallID,
+			"tool_name": tr.ToolName,
+			"content":   map[string]interface{}{"type": "text", "text": tr.Content.Text},
+		})
+		found := false
+		for _, line := range strings.Split(tr.Content.Text, "\n") {
+			if chunk, ok := strings.CutPrefix(line, "Content: "); ok {
+				texts = append(texts, chunk)
+				found = true
+			}
+		}
+		if !found {
+			texts = append(texts, tr.Content.Text)
+		}
+	}
+
+	// The paused tool_execution step completes with the client's responses;
+	// like the server, only its step_complete is streamed on resume
+	step := pending.steps[0].(map[string]interface{})
+	m.mu.Lock()
+	step["tool_responses"] = responses
+	m.mu.Unlock()
+	stream := params.Stream != nil && *params.Stream
+	if stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeMockStepEvents(w, stream, step, false)
+	}
+	m.completeTurn(w, stream, turnID, pending.sessionID, pending.input, pending.steps, "According to "+pending.source+": "+strings.Join(texts, "\n"), nil)
+}
+
+func (m *MockStack) handleGetTurn(w http.ResponseWriter, r *http.Request) {
+	turn, ok := m.lookupTurn(r)
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "turn %s not found", r.PathValue("turn_id"))
+		return
+	}
+	writeMockJSON(w, http.StatusOK, turn)
+}
+
+func (m *MockStack) handleGetStep(w http.ResponseWriter, r *http.Request) {
+	turn, ok := m.lookupTurn(r)
+	if !ok {
+		writeMockError(w, http.StatusNotFound, "turn %s not found", r.PathValue("turn_id"))
+		return
+	}
+	stepID := r.PathValue("step_id")
+	for _, step := range turn.Steps {
+		if s, _ := step.(map[string]interface{}); s != nil && s["step_id"] == stepID {
+			writeMockJSON(w, http.StatusOK, map[string]interface{}{"step": s})
+			return
+		}
+	}
+	writeMockError(w, http.StatusNotFound, "step %s not found in turn %s", stepID, turn.TurnID)
+}
+
+// lookupTurn returns the recorded turn addressed by the request path
+func (m *MockStack) lookupTurn(r *http.Request) (Turn, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	turn, ok := m.turns[r.PathValue("turn_id")]
+	if !ok || turn.SessionID != r.PathValue("session_id") {
+		return Turn{}, false
+	}
+	return turn, true
+}
+
+// completeTurn writes an inference step and the completed turn. The answer
+// is toolAnswer when it was built from tool output, else the next reply.
+func (m *MockStack) completeTurn(w http.ResponseWriter, stream bool, turnID, sessionID string, input []Message, steps []interface{}, toolAnswer string, attachments []TurnDocument) {
+	m.mu.Lock()
+	answer := m.reply(input)
+	m.mu.Unlock()
+	if toolAnswer != "" {
+		answer = toolAnswer
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	inference := map[string]interface{}{
+		"step_type": "inference",
+		"step_id":   turnID + "-inference",
+		"turn_id":   turnID,
+		"model_response": map[string]interface{}{
+			"role":    "assistant",
+			"content": answer,
+		},
+	}
+	steps = append(steps, inference)
+	writeMockStepEvents(w, stream, inference, true)
+	m.writeTurn(w, stream, "turn_complete", Turn{
+		TurnID:            turnID,
+		SessionID:         sessionID,
+		InputMessages:     input,
+		OutputMessage:     Message{Role: "assistant", Content: answer},
+		Steps:             steps,
+		StartedAt:         now,
+		CompletedAt:       &now,
+		OutputAttachments: attachments,
+	})
+}
+
+// mockAgentHasToolgroup reports whether the agent was configured with the
+// toolgroup, or one of its tools
+func mockAgentHasToolgroup(agent AgentConfig, toolgroup string) bool {
+	for _, tg := range agent.Toolgroups {
+		name, _ := tg.(string)
+		if v, ok := tg.(map[string]interface{}); ok {
+			name, _ = v["name"].(string)
+		}
+		if name == toolgroup || strings.HasPrefix(name, toolgroup+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mockSearchAPIKey reports whether the request passes a web search API key in
+// its provider data
+func mockSearchAPIKey(r *http.Request) bool {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(r.Header.Get(ProviderDataHeader)), &data); err != nil {
+		return false
+	}
+	for _, p := range WebSearchProviders {
+		if key, _ := data[p.APIKeyField()].(string); key != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// mockPlotPNG is a 1x1 PNG standing in for a plot the code drew
+const mockPlotPNG = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg=="
+
+// codeInterpreterStep is a code_interpreter step the server ran for prompt:
+// the code prints the prompt, draws a plot returned inline and writes a CSV
+// file, which is stored in the files API and attached to the turn
+func (m *MockStack) codeInterpreterStep(turnID, prompt string) (map[string]interface{}, []TurnDocument) {
+	csv := []byte("prompt,length\n" + strconv.Quote(prompt) + "," + strconv.Itoa(len(prompt)) + "\n")
+	m.mu.Lock()
+	file := FileResponse{
+		ID:        m.newID("file"),
+		Object:    "file",
+		Bytes:     len(csv),
+		CreatedAt: time.Now().Unix(),
+		Filename:  "result.csv",
+		Purpose:   "assistants",
+	}
+	m.files = append(m.files, file)
+	m.fileContent[file.ID] = csv
+	m.mu.Unlock()
+
+	callID := turnID + "-code-1"
+	return map[string]interface{}{
+		"step_type": "tool_execution",
+		"step_id":   turnID + "-code",
+		"turn_id":   turnID,
+		"tool_calls": []interface{}{
+			map[string]interface{}{"call_id": callID, "tool_name": "code_interpreter", "arguments": map[string]interface{}{"code": fmt.Sprintf("print(%q)", prompt)}},
+		},
+		"tool_responses": []interface{}{
+			map[string]interface{}{
+				"call_id":   callID,
+				"tool_name": "code_interpreter",
+				"content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "[process_status]\ncompleted\n[/process_status]\n[stdout]\n" + prompt + "\n[/stdout]\n[stderr]\n\n[/stderr]"},
+					map[string]interface{}{"type": "image", "image": map[string]interface{}{"data": mockPlotPNG}},
+				},
+			},
+		},
+	}, []TurnDocument{URLDocument(m.URL()+"/v1/openai/v1/files/"+file.ID+"/content", "text/csv")}
+}
+
+// mockWebSearchStep is a web_search step the server ran for query, answering
+// like the Tavily provider
+func mockWebSearchStep(turnID, query string) (map[string]interface{}, string) {
+	snippet := "Search result for: " + query
+	results, _ := json.Marshal(map[string]interface{}{
+		"query": query,
+		"top_k": []map[string]interface{}{
+			{"title": "Mock result", "url": "https://example.com/search?q=" + url.QueryEscape(query), "content": snippet, "score": 0.9},
+		},
+	})
+	callID := turnID + "-search-1"
+	return map[string]interface{}{
+		"step_type": "tool_execution",
+		"step_id":   turnID + "-search",
+		"turn_id":   turnID,
+		"tool_calls": []interface{}{
+			map[string]interface{}{"call_id": callID, "tool_name": "web_search", "arguments": map[string]interface{}{"query": query}},
+		},
+		"tool_responses": []interface{}{
+			map[string]interface{}{"call_id": callID, "tool_name": "web_search", "content": string(results)},
+		},
+	}, snippet
+}
+
+// decodeMockJSON decodes the request body, writing a 400 on failure
+func decodeMockJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeMockError(w, http.StatusBadRequest, "invalid request body: %v", err)
+		return false
+	}
+	return true
+}
+
+// estimateTokens stands in for the server's tokenizer in reported usage:
+// about four characters per token
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// writeMockJSON writes v as a JSON response
+func writeMockJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeMockError writes an error body in the server's {"detail": ...} shape
+func writeMockError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeMockJSON(w, status, map[string]string{"detail": fmt.Sprintf(format, args...)})
+}
+
+// writeMockSSE writes v as a single SSE data event and flushes it
+func writeMockSSE(w http.ResponseWriter, v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeTurn records turn for later retrieval and writes it as an SSE event
+// when streaming, or as plain JSON otherwise
+func (m *MockStack) writeTurn(w http.ResponseWriter, stream bool, eventType string, turn Turn) {
+	m.mu.Lock()
+	if _, exists := m.turns[turn.TurnID]; !exists {
+		m.sessionTurns[turn.SessionID] = append(m.sessionTurns[turn.SessionID], turn.TurnID)
+	}
+	m.turns[turn.TurnID] = turn
+	m.mu.Unlock()
+
+	if !stream {
+		writeMockJSON(w, http.StatusOK, turn)
+		return
+	}
+	writeMockTurnEvent(w, map[string]interface{}{"event_type": eventType, "turn": turn})
+}
+
+// writeMockStepEvents streams a step: step_start when started is set, the
+// answer word by word for inference steps, then step_complete
+func writeMockStepEvents(w http.ResponseWriter, stream bool, step map[string]interface{}, started bool) {
+	if !stream {
+		return
+	}
+	stepType, stepID := step["step_type"], step["step_id"]
+	if started {
+		writeMockTurnEvent(w, map[string]interface{}{"event_type": "step_start", "step_type": stepType, "step_id": stepID})
+		response, _ := step["model_response"].(map[string]interface{})
+		text, _ := response["content"].(string)
+		for _, word := range strings.SplitAfter(text, " ") {
+			if word == "" {
+				continue
+			}
+			writeMockTurnEvent(w, map[string]interface{}{
+				"event_type": "step_progress", "step_type": stepType, "step_id": stepID,
+				"delta": map[string]interface{}{"type": "text", "text": word},
+			})
+		}
+	}
+	writeMockTurnEvent(w, map[string]interface{}{"event_type": "step_complete", "step_type": stepType, "step_id": stepID, "step_details": step})
+}
+
+// writeMockTurnEvent wraps payload in the agent turn event envelope
+func writeMockTurnEvent(w http.ResponseWriter, payload map[string]interface{}) {
+	writeMockSSE(w, map[string]interface{}{
+		"event": map[string]interface{}{"payload": payload},
+	})
+}
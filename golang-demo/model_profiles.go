@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ModelProfile holds the default request parameters of one model. Requests
+// that set a parameter keep their own value.
+type ModelProfile struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	// ToolPromptFormat is how agents present tools to the model: "json",
+	// "function_tag" or "python_list"
+	ToolPromptFormat string `json:"tool_prompt_format,omitempty"`
+}
+
+// ModelProfiles maps model IDs to their profiles. A key may contain '*'
+// wildcards, e.g. "vllm/*" or "*70B*"; an exact ID wins over patterns, and
+// longer patterns over shorter ones.
+type ModelProfiles map[string]ModelProfile
+
+// For returns the profile of model and whether one applies
+func (p ModelProfiles) For(model string) (ModelProfile, bool) {
+	return lookupModel(p, model)
+}
+
+// lookupModel finds the entry of model in a map keyed by model IDs and
+// wildcard patterns: its own entry, or that of the longest matching pattern
+func lookupModel[V any](m map[string]V, model string) (V, bool) {
+	if v, ok := m[model]; ok {
+		return v, true
+	}
+	best := ""
+	for pattern := range m {
+		if strings.Contains(pattern, "*") && wildcardMatch(pattern, model) &&
+			(len(pattern) > len(best) || len(pattern) == len(best) && pattern < best) {
+			best = pattern
+		}
+	}
+	if best == "" {
+		var zero V
+		return zero, false
+	}
+	return m[best], true
+}
+
+// wildcardMatch reports whether s matches pattern, where '*' matches any
+// run of characters, slashes included
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 {
+			return strings.HasSuffix(s, part)
+		}
+		idx := strings.Index(s, part)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(part):]
+	}
+	return s == ""
+}
+
+// LoadModelProfiles reads profiles from a YAML file, or JSON when path ends
+// in .json, keyed by model ID:
+//
+//	ollama/llama3.2:3b:
+//	  temperature: 0.7
+//	  max_tokens: 512
+//	vllm/*:
+//	  temperature: 0.2
+//	  top_p: 0.9
+//	  tool_prompt_format: json
+func LoadModelProfiles(path string) (ModelProfiles, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model profiles: %w", err)
+	}
+	var profiles ModelProfiles
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &profiles)
+	} else {
+		err = unmarshalYAML(data, &profiles)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode model profiles %s: %w", path, err)
+	}
+	for model, profile := range profiles {
+		switch profile.ToolPromptFormat {
+		case "", "json", "function_tag", "python_list":
+		default:
+			return nil, fmt.Errorf("invalid model profile %s: unknown tool_prompt_format %q", model, profile.ToolPromptFormat)
+		}
+	}
+	return profiles, nil
+}
+
+// WithModelProfiles merges the profile of each request's model into chat
+// completions, text completions and new agents
+func WithModelProfiles(profiles ModelProfiles) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.ModelProfiles = profiles
+	}
+}
+
+// applyProfile fills the parameters a completion leaves unset from the
+// profile of its model
+func (c *LlamaStackClient) applyProfile(model string, temperature, topP **float64, maxTokens **int, stop *[]string) {
+	profile, ok := c.ModelProfiles.For(model)
+	if !ok {
+		return
+	}
+	if *temperature == nil {
+		*temperature = profile.Temperature
+	}
+	if *topP == nil {
+		*topP = profile.TopP
+	}
+	if *maxTokens == nil {
+		*maxTokens = profile.MaxTokens
+	}
+	if *stop == nil {
+		*stop = profile.Stop
+	}
+}
+
+// applyAgentProfile fills an agent config's tool prompt format and sampling
+// params from the profile of its model. A config with sampling params of
+// its own only gets the max tokens and stop sequences it leaves unset.
+func (c *LlamaStackClient) applyAgentProfile(cfg *AgentConfig) {
+	profile, ok := c.ModelProfiles.For(cfg.Model)
+	if !ok {
+		return
+	}
+	if cfg.ToolPromptFormat == "" {
+		cfg.ToolPromptFormat = profile.ToolPromptFormat
+	}
+	if cfg.SamplingParams == nil {
+		if profile.Temperature == nil && profile.TopP == nil && profile.MaxTokens == nil && profile.Stop == nil {
+			return
+		}
+		strategy := SamplingStrategy{Type: "top_p", Temperature: profile.Temperature, TopP: profile.TopP}
+		if profile.Temperature != nil && *profile.Temperature == 0 {
+			strategy = SamplingStrategy{Type: "greedy"}
+		}
+		cfg.SamplingParams = &SamplingParams{Strategy: strategy}
+	} else {
+		params := *cfg.SamplingParams
+		cfg.SamplingParams = &params
+	}
+	if cfg.SamplingParams.MaxTokens == nil {
+		cfg.SamplingParams.MaxTokens = profile.MaxTokens
+	}
+	if cfg.SamplingParams.Stop == nil {
+		cfg.SamplingParams.Stop = profile.Stop
+	}
+}
+
+// runProfilesCommand handles "profiles": it prints the profile each
+// registered LLM gets
+func runProfilesCommand(client *LlamaStackClient) error {
+	if len(client.ModelProfiles) == 0 {
+		return errors.New("no model profiles loaded; pass -model-profiles FILE or set LLAMASTACK_MODEL_PROFILES")
+	}
+	models, err := client.ListModels(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	for _, model := range models.Data {
+		if model.ModelType != "llm" {
+			continue
+		}
+		profile, ok := client.ModelProfiles.For(model.Identifier)
+		if !ok {
+			fmt.Printf("%s  (no profile)\n", model.Identifier)
+			continue
+		}
+		fmt.Printf("%s  ", model.Identifier)
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.Encode(profile)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ModerationCategory is a harm category of the moderation endpoint. Servers
+// may report categories beyond the ones below, e.g. those of their guard
+// model.
+type ModerationCategory string
+
+const (
+	ModerationHarassment            ModerationCategory = "harassment"
+	ModerationHarassmentThreatening ModerationCategory = "harassment/threatening"
+	ModerationHate                  ModerationCategory = "hate"
+	ModerationHateThreatening       ModerationCategory = "hate/threatening"
+	ModerationIllicit               ModerationCategory = "illicit"
+	ModerationIllicitViolent        ModerationCategory = "illicit/violent"
+	ModerationSelfHarm              ModerationCategory = "self-harm"
+	ModerationSelfHarmIntent        ModerationCategory = "self-harm/intent"
+	ModerationSelfHarmInstructions  ModerationCategory = "self-harm/instructions"
+	ModerationSexual                ModerationCategory = "sexual"
+	ModerationSexualMinors          ModerationCategory = "sexual/minors"
+	ModerationViolence              ModerationCategory = "violence"
+	ModerationViolenceGraphic       ModerationCategory = "violence/graphic"
+)
+
+// ModerationParams is the body of a moderation request
+type ModerationParams struct {
+	Input []string `json:"input"`
+	// Model is the guard model, e.g. meta-llama/Llama-Guard-3-8B; the
+	// server's default when empty
+	Model string `json:"model,omitempty"`
+}
+
+// ModerationResponse holds one result per input, in order
+type ModerationResponse struct {
+	ID      string             `json:"id"`
+	Model   string             `json:"model"`
+	Results []ModerationResult `json:"results"`
+}
+
+// ModerationResult is the verdict on one input
+type ModerationResult struct {
+	Flagged        bool                           `json:"flagged"`
+	Categories     map[ModerationCategory]bool    `json:"categories"`
+	CategoryScores map[ModerationCategory]float64 `json:"category_scores"`
+	// UserMessage is the server's explanation to show users, if any
+	UserMessage string                 `json:"user_message,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// FlaggedCategories returns the categories r flags, highest score first
+func (r ModerationResult) FlaggedCategories() []ModerationCategory {
+	var flagged []ModerationCategory
+	for category, ok := range r.Categories {
+		if ok {
+			flagged = append(flagged, category)
+		}
+	}
+	sort.Slice(flagged, func(i, j int) bool {
+		if si, sj := r.CategoryScores[flagged[i]], r.CategoryScores[flagged[j]]; si != sj {
+			return si > sj
+		}
+		return flagged[i] < flagged[j]
+	})
+	return flagged
+}
+
+// Summary describes the flagged categories with their scores, e.g.
+// "violence 0.92, hate 0.41"
+func (r ModerationResult) Summary() string {
+	var parts []string
+	for _, category := range r.FlaggedCategories() {
+		parts = append(parts, fmt.Sprintf("%s %.2f", category, r.CategoryScores[category]))
+	}
+	if len(parts) == 0 && r.Flagged {
+		return "unspecified category"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Flagged reports whether any input was flagged
+func (r *ModerationResponse) Flagged() bool {
+	for _, result := range r.Results {
+		if result.Flagged {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateModeration classifies input with the server's default moderation
+// model
+func (c *LlamaStackClient) CreateModeration(ctx context.Context, input string) (*ModerationResponse, error) {
+	return c.CreateModerationWithParams(ctx, ModerationParams{Input: []string{input}})
+}
+
+// CreateModerationWithParams classifies several inputs at once or with a
+// chosen model, using the OpenAI-compatible moderations endpoint
+func (c *LlamaStackClient) CreateModerationWithParams(ctx context.Context, params ModerationParams) (*ModerationResponse, error) {
+	var response ModerationResponse
+	if err := c.postJSON(ctx, "/v1/openai/v1/moderations", params, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Results) != len(params.Input) {
+		return nil, fmt.Errorf("expected %d moderation results, got %d", len(params.Input), len(response.Results))
+	}
+	return &response, nil
+}
+
+// ModerationPolicy is what a Moderator does with flagged input
+type ModerationPolicy string
+
+const (
+	// ModerationBlock stops flagged input from being sent, with a
+	// *ModerationBlocked error to warn the user with
+	ModerationBlock ModerationPolicy = "block"
+	// ModerationAnnotate lets flagged input through; the caller annotates
+	// the exchange with the result
+	ModerationAnnotate ModerationPolicy = "annotate"
+)
+
+// ParseModerationPolicy parses "block" or "annotate"
+func ParseModerationPolicy(s string) (ModerationPolicy, error) {
+	switch p := ModerationPolicy(s); p {
+	case ModerationBlock, ModerationAnnotate:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown moderation policy %q (want block or annotate)", s)
+}
+
+// ModerationBlocked is returned by Moderator.Check for input the block
+// policy stopped
+type ModerationBlocked struct {
+	Result ModerationResult
+}
+
+func (e *ModerationBlocked) Error() string {
+	msg := "input blocked by moderation: " + e.Result.Summary()
+	if e.Result.UserMessage != "" {
+		msg += " (" + e.Result.UserMessage + ")"
+	}
+	return msg
+}
+
+// Moderator checks user input with the moderation endpoint before it is
+// sent to a model, as a pre-flight for chats and agent turns
+type Moderator struct {
+	Client *LlamaStackClient
+	// Model is the moderation model; the server's default when empty
+	Model string
+	// Policy defaults to ModerationBlock
+	Policy ModerationPolicy
+	// OnFlagged, if set, is called with flagged results the annotate policy
+	// lets through, e.g. to mark the message in a transcript
+	OnFlagged func(result ModerationResult)
+}
+
+// Check moderates text. Flagged text under the block policy returns a
+// *ModerationBlocked error; otherwise the result is returned for the caller
+// to annotate. A failed moderation call returns its error, and callers
+// decide whether to send the text unchecked.
+func (m *Moderator) Check(ctx context.Context, text string) (*ModerationResult, error) {
+	resp, err := m.Client.CreateModerationWithParams(ctx, ModerationParams{Input: []string{text}, Model: m.Model})
+	if err != nil {
+		return nil, fmt.Errorf("moderation failed: %w", err)
+	}
+	result := resp.Results[0]
+	if !result.Flagged {
+		return &result, nil
+	}
+	if m.Policy != ModerationAnnotate {
+		return &result, &ModerationBlocked{Result: result}
+	}
+	m.Client.logger().InfoContext(ctx, "input flagged by moderation", "categories", result.Summary())
+	if m.OnFlagged != nil {
+		m.OnFlagged(result)
+	}
+	return &result, nil
+}
+
+// CheckMessages moderates the text of the user messages among messages as
+// one input
+func (m *Moderator) CheckMessages(ctx context.Context, messages []Message) (*ModerationResult, error) {
+	var texts []string
+	for _, msg := range messages {
+		if msg.Role == "user" && msg.Content != "" {
+			texts = append(texts, msg.Content)
+		}
+	}
+	if len(texts) == 0 {
+		return &ModerationResult{}, nil
+	}
+	return m.Check(ctx, strings.Join(texts, "\n\n"))
+}
+
+// newModerator returns a Moderator with policy, or nil when policy is "" or
+// "off"
+func newModerator(client *LlamaStackClient, policy, model string) (*Moderator, error) {
+	if policy == "" || policy == "off" {
+		return nil, nil
+	}
+	p, err := ParseModerationPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	return &Moderator{Client: client, Model: model, Policy: p}, nil
+}
+
+// isModerationBlocked reports whether err is a *ModerationBlocked
+func isModerationBlocked(err error) bool {
+	var blocked *ModerationBlocked
+	return errors.As(err, &blocked)
+}
@@ -0,0 +1,1228 @@
+// Code generated by tools/openapigen from openapi.json; DO NOT EDIT.
+
+package main
+
+// SpecAgentCandidate is generated from the AgentCandidate schema: An agent candidate for evaluation.
+type SpecAgentCandidate struct {
+	Config SpecAgentConfig `json:"config"`
+	Type   *string         `json:"type,omitempty"`
+}
+
+// SpecAgentConfig is generated from the AgentConfig schema: Configuration for an agent.
+type SpecAgentConfig struct {
+	ClientTools              []SpecToolDef         `json:"client_tools,omitempty"`
+	EnableSessionPersistence *bool                 `json:"enable_session_persistence,omitempty"`
+	InputShields             []string              `json:"input_shields,omitempty"`
+	Instructions             string                `json:"instructions"`
+	MaxInferIters            *int                  `json:"max_infer_iters,omitempty"`
+	Model                    string                `json:"model"`
+	Name                     *string               `json:"name,omitempty"`
+	OutputShields            []string              `json:"output_shields,omitempty"`
+	ResponseFormat           interface{}           `json:"response_format,omitempty"`
+	SamplingParams           *SpecSamplingParams   `json:"sampling_params,omitempty"`
+	ToolChoice               *SpecToolChoice       `json:"tool_choice,omitempty"`
+	ToolConfig               *SpecToolConfig       `json:"tool_config,omitempty"`
+	ToolPromptFormat         *SpecToolPromptFormat `json:"tool_prompt_format,omitempty"`
+	Toolgroups               []interface{}         `json:"toolgroups,omitempty"`
+}
+
+// SpecAgentToolGroupWithArgs is generated from the AgentToolGroupWithArgs schema
+type SpecAgentToolGroupWithArgs struct {
+	Args map[string]interface{} `json:"args"`
+	Name string                 `json:"name"`
+}
+
+// SpecAgentTurnInputType is generated from the AgentTurnInputType schema
+type SpecAgentTurnInputType struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecAggregationFunctionType is generated from the AggregationFunctionType schema
+type SpecAggregationFunctionType string
+
+const (
+	SpecAggregationFunctionTypeAverage          SpecAggregationFunctionType = "average"
+	SpecAggregationFunctionTypeWeightedAverage  SpecAggregationFunctionType = "weighted_average"
+	SpecAggregationFunctionTypeMedian           SpecAggregationFunctionType = "median"
+	SpecAggregationFunctionTypeCategoricalCount SpecAggregationFunctionType = "categorical_count"
+	SpecAggregationFunctionTypeAccuracy         SpecAggregationFunctionType = "accuracy"
+)
+
+// SpecAllowedToolsFilter is generated from the AllowedToolsFilter schema
+type SpecAllowedToolsFilter struct {
+	ToolNames []string `json:"tool_names,omitempty"`
+}
+
+// SpecApprovalFilter is generated from the ApprovalFilter schema
+type SpecApprovalFilter struct {
+	Always []string `json:"always,omitempty"`
+	Never  []string `json:"never,omitempty"`
+}
+
+// SpecArrayType is generated from the ArrayType schema
+type SpecArrayType struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecBasicScoringFnParams is generated from the BasicScoringFnParams schema
+type SpecBasicScoringFnParams struct {
+	AggregationFunctions []SpecAggregationFunctionType `json:"aggregation_functions,omitempty"`
+	Type                 *string                       `json:"type,omitempty"`
+}
+
+// SpecBenchmarkConfig is generated from the BenchmarkConfig schema: A benchmark configuration for evaluation.
+type SpecBenchmarkConfig struct {
+	EvalCandidate interface{}            `json:"eval_candidate"`
+	NumExamples   *int                   `json:"num_examples,omitempty"`
+	ScoringParams map[string]interface{} `json:"scoring_params,omitempty"`
+}
+
+// SpecAppendRowsRequest is generated from the Body_append_rows_v1_datasetio_append_rows__dataset_id__post schema
+type SpecAppendRowsRequest struct {
+	Rows []map[string]interface{} `json:"rows"`
+}
+
+// SpecBatchChatCompletionRequest is generated from the Body_batch_chat_completion_v1_inference_batch_chat_completion_post schema
+type SpecBatchChatCompletionRequest struct {
+	Logprobs       *SpecLogProbConfig   `json:"logprobs,omitempty"`
+	MessagesBatch  [][]interface{}      `json:"messages_batch"`
+	ModelID        string               `json:"model_id"`
+	ResponseFormat interface{}          `json:"response_format,omitempty"`
+	SamplingParams *SpecSamplingParams  `json:"sampling_params,omitempty"`
+	ToolConfig     *SpecToolConfig      `json:"tool_config,omitempty"`
+	Tools          []SpecToolDefinition `json:"tools,omitempty"`
+}
+
+// SpecBatchCompletionRequest is generated from the Body_batch_completion_v1_inference_batch_completion_post schema
+type SpecBatchCompletionRequest struct {
+	ContentBatch   []interface{}       `json:"content_batch"`
+	Logprobs       *SpecLogProbConfig  `json:"logprobs,omitempty"`
+	ModelID        string              `json:"model_id"`
+	ResponseFormat interface{}         `json:"response_format,omitempty"`
+	SamplingParams *SpecSamplingParams `json:"sampling_params,omitempty"`
+}
+
+// SpecChatCompletionRequest is generated from the Body_chat_completion_v1_inference_chat_completion_post schema
+type SpecChatCompletionRequest struct {
+	Logprobs         *SpecLogProbConfig    `json:"logprobs,omitempty"`
+	Messages         []interface{}         `json:"messages"`
+	ModelID          string                `json:"model_id"`
+	ResponseFormat   interface{}           `json:"response_format,omitempty"`
+	SamplingParams   *SpecSamplingParams   `json:"sampling_params,omitempty"`
+	Stream           *bool                 `json:"stream,omitempty"`
+	ToolChoice       *SpecToolChoice       `json:"tool_choice,omitempty"`
+	ToolConfig       *SpecToolConfig       `json:"tool_config,omitempty"`
+	ToolPromptFormat *SpecToolPromptFormat `json:"tool_prompt_format,omitempty"`
+	Tools            []SpecToolDefinition  `json:"tools,omitempty"`
+}
+
+// SpecCompletionRequest is generated from the Body_completion_v1_inference_completion_post schema
+type SpecCompletionRequest struct {
+	Content        interface{}         `json:"content"`
+	Logprobs       *SpecLogProbConfig  `json:"logprobs,omitempty"`
+	ModelID        string              `json:"model_id"`
+	ResponseFormat interface{}         `json:"response_format,omitempty"`
+	SamplingParams *SpecSamplingParams `json:"sampling_params,omitempty"`
+	Stream         *bool               `json:"stream,omitempty"`
+}
+
+// SpecCreateAgentSessionRequest is generated from the Body_create_agent_session_v1_agents__agent_id__session_post schema
+type SpecCreateAgentSessionRequest struct {
+	SessionName string `json:"session_name"`
+}
+
+// SpecCreateAgentTurnRequest is generated from the Body_create_agent_turn_v1_agents__agent_id__session__session_id__turn_post schema
+type SpecCreateAgentTurnRequest struct {
+	Documents  []SpecDocument  `json:"documents,omitempty"`
+	Messages   []interface{}   `json:"messages"`
+	Stream     *bool           `json:"stream,omitempty"`
+	ToolConfig *SpecToolConfig `json:"tool_config,omitempty"`
+	Toolgroups []interface{}   `json:"toolgroups,omitempty"`
+}
+
+// SpecCreateAgentRequest is generated from the Body_create_agent_v1_agents_post schema
+type SpecCreateAgentRequest struct {
+	AgentConfig SpecAgentConfig `json:"agent_config"`
+}
+
+// SpecCreateOpenaiResponseRequest is generated from the Body_create_openai_response_v1_openai_v1_responses_post schema
+type SpecCreateOpenaiResponseRequest struct {
+	Input              interface{}             `json:"input"`
+	Instructions       *string                 `json:"instructions,omitempty"`
+	MaxInferIters      *int                    `json:"max_infer_iters,omitempty"`
+	Model              string                  `json:"model"`
+	PreviousResponseID *string                 `json:"previous_response_id,omitempty"`
+	Store              *bool                   `json:"store,omitempty"`
+	Stream             *bool                   `json:"stream,omitempty"`
+	Temperature        *float64                `json:"temperature,omitempty"`
+	Text               *SpecOpenAIResponseText `json:"text,omitempty"`
+	Tools              []interface{}           `json:"tools,omitempty"`
+}
+
+// SpecEmbeddingsRequest is generated from the Body_embeddings_v1_inference_embeddings_post schema
+type SpecEmbeddingsRequest struct {
+	Contents        interface{}            `json:"contents"`
+	ModelID         string                 `json:"model_id"`
+	OutputDimension *int                   `json:"output_dimension,omitempty"`
+	TaskType        *SpecEmbeddingTaskType `json:"task_type,omitempty"`
+	TextTruncation  *SpecTextTruncation    `json:"text_truncation,omitempty"`
+}
+
+// SpecEvaluateRowsRequest is generated from the Body_evaluate_rows_v1_eval_benchmarks__benchmark_id__evaluations_post schema
+type SpecEvaluateRowsRequest struct {
+	BenchmarkConfig  SpecBenchmarkConfig      `json:"benchmark_config"`
+	InputRows        []map[string]interface{} `json:"input_rows"`
+	ScoringFunctions []string                 `json:"scoring_functions"`
+}
+
+// SpecGetSpanTreeRequest is generated from the Body_get_span_tree_v1_telemetry_spans__span_id__tree_post schema
+type SpecGetSpanTreeRequest struct {
+	AttributesToReturn []string `json:"attributes_to_return,omitempty"`
+	MaxDepth           *int     `json:"max_depth,omitempty"`
+}
+
+// SpecInsertChunksRequest is generated from the Body_insert_chunks_v1_vector_io_insert_post schema
+type SpecInsertChunksRequest struct {
+	Chunks     []SpecChunk `json:"chunks"`
+	TTLSeconds *int        `json:"ttl_seconds,omitempty"`
+	VectorDBID string      `json:"vector_db_id"`
+}
+
+// SpecInsertRequest is generated from the Body_insert_v1_tool_runtime_rag_tool_insert_post schema
+type SpecInsertRequest struct {
+	ChunkSizeInTokens *int              `json:"chunk_size_in_tokens,omitempty"`
+	Documents         []SpecRAGDocument `json:"documents"`
+	VectorDBID        string            `json:"vector_db_id"`
+}
+
+// SpecInvokeToolRequest is generated from the Body_invoke_tool_v1_tool_runtime_invoke_post schema
+type SpecInvokeToolRequest struct {
+	Kwargs   map[string]interface{} `json:"kwargs"`
+	ToolName string                 `json:"tool_name"`
+}
+
+// SpecLogEventRequest is generated from the Body_log_event_v1_telemetry_events_post schema
+type SpecLogEventRequest struct {
+	Event      interface{} `json:"event"`
+	TTLSeconds *int        `json:"ttl_seconds,omitempty"`
+}
+
+// SpecOpenaiAttachFileToVectorStoreRequest is generated from the Body_openai_attach_file_to_vector_store_v1_openai_v1_vector_stores__vector_store_id__files_post schema
+type SpecOpenaiAttachFileToVectorStoreRequest struct {
+	Attributes       map[string]interface{} `json:"attributes,omitempty"`
+	ChunkingStrategy interface{}            `json:"chunking_strategy,omitempty"`
+	FileID           string                 `json:"file_id"`
+}
+
+// SpecOpenaiChatCompletionRequest is generated from the Body_openai_chat_completion_v1_openai_v1_chat_completions_post schema
+type SpecOpenaiChatCompletionRequest struct {
+	FrequencyPenalty    *float64                 `json:"frequency_penalty,omitempty"`
+	FunctionCall        interface{}              `json:"function_call,omitempty"`
+	Functions           []map[string]interface{} `json:"functions,omitempty"`
+	LogitBias           map[string]interface{}   `json:"logit_bias,omitempty"`
+	Logprobs            *bool                    `json:"logprobs,omitempty"`
+	MaxCompletionTokens *int                     `json:"max_completion_tokens,omitempty"`
+	MaxTokens           *int                     `json:"max_tokens,omitempty"`
+	Messages            []interface{}            `json:"messages"`
+	Model               string                   `json:"model"`
+	N                   *int                     `json:"n,omitempty"`
+	ParallelToolCalls   *bool                    `json:"parallel_tool_calls,omitempty"`
+	PresencePenalty     *float64                 `json:"presence_penalty,omitempty"`
+	ResponseFormat      interface{}              `json:"response_format,omitempty"`
+	Seed                *int                     `json:"seed,omitempty"`
+	Stop                interface{}              `json:"stop,omitempty"`
+	Stream              *bool                    `json:"stream,omitempty"`
+	StreamOptions       map[string]interface{}   `json:"stream_options,omitempty"`
+	Temperature         *float64                 `json:"temperature,omitempty"`
+	ToolChoice          interface{}              `json:"tool_choice,omitempty"`
+	Tools               []map[string]interface{} `json:"tools,omitempty"`
+	TopLogprobs         *int                     `json:"top_logprobs,omitempty"`
+	TopP                *float64                 `json:"top_p,omitempty"`
+	User                *string                  `json:"user,omitempty"`
+}
+
+// SpecOpenaiCompletionRequest is generated from the Body_openai_completion_v1_openai_v1_completions_post schema
+type SpecOpenaiCompletionRequest struct {
+	BestOf           *int                   `json:"best_of,omitempty"`
+	Echo             *bool                  `json:"echo,omitempty"`
+	FrequencyPenalty *float64               `json:"frequency_penalty,omitempty"`
+	GuidedChoice     []string               `json:"guided_choice,omitempty"`
+	LogitBias        map[string]interface{} `json:"logit_bias,omitempty"`
+	Logprobs         *bool                  `json:"logprobs,omitempty"`
+	MaxTokens        *int                   `json:"max_tokens,omitempty"`
+	Model            string                 `json:"model"`
+	N                *int                   `json:"n,omitempty"`
+	PresencePenalty  *float64               `json:"presence_penalty,omitempty"`
+	Prompt           interface{}            `json:"prompt"`
+	PromptLogprobs   *int                   `json:"prompt_logprobs,omitempty"`
+	Seed             *int                   `json:"seed,omitempty"`
+	Stop             interface{}            `json:"stop,omitempty"`
+	Stream           *bool                  `json:"stream,omitempty"`
+	StreamOptions    map[string]interface{} `json:"stream_options,omitempty"`
+	Suffix           *string                `json:"suffix,omitempty"`
+	Temperature      *float64               `json:"temperature,omitempty"`
+	TopP             *float64               `json:"top_p,omitempty"`
+	User             *string                `json:"user,omitempty"`
+}
+
+// SpecOpenaiCreateVectorStoreRequest is generated from the Body_openai_create_vector_store_v1_openai_v1_vector_stores_post schema
+type SpecOpenaiCreateVectorStoreRequest struct {
+	ChunkingStrategy   map[string]interface{} `json:"chunking_strategy,omitempty"`
+	EmbeddingDimension *int                   `json:"embedding_dimension,omitempty"`
+	EmbeddingModel     *string                `json:"embedding_model,omitempty"`
+	ExpiresAfter       map[string]interface{} `json:"expires_after,omitempty"`
+	FileIDS            []string               `json:"file_ids,omitempty"`
+	Metadata           map[string]interface{} `json:"metadata,omitempty"`
+	Name               string                 `json:"name"`
+	ProviderID         *string                `json:"provider_id,omitempty"`
+	ProviderVectorDBID *string                `json:"provider_vector_db_id,omitempty"`
+}
+
+// SpecOpenaiEmbeddingsRequest is generated from the Body_openai_embeddings_v1_openai_v1_embeddings_post schema
+type SpecOpenaiEmbeddingsRequest struct {
+	Dimensions     *int        `json:"dimensions,omitempty"`
+	EncodingFormat *string     `json:"encoding_format,omitempty"`
+	Input          interface{} `json:"input"`
+	Model          string      `json:"model"`
+	User           *string     `json:"user,omitempty"`
+}
+
+// SpecOpenaiSearchVectorStoreRequest is generated from the Body_openai_search_vector_store_v1_openai_v1_vector_stores__vector_store_id__search_post schema
+type SpecOpenaiSearchVectorStoreRequest struct {
+	Filters        map[string]interface{}    `json:"filters,omitempty"`
+	MaxNumResults  *int                      `json:"max_num_results,omitempty"`
+	Query          interface{}               `json:"query"`
+	RankingOptions *SpecSearchRankingOptions `json:"ranking_options,omitempty"`
+	RewriteQuery   *bool                     `json:"rewrite_query,omitempty"`
+	SearchMode     *string                   `json:"search_mode,omitempty"`
+}
+
+// SpecOpenaiUpdateVectorStoreFileRequest is generated from the Body_openai_update_vector_store_file_v1_openai_v1_vector_stores__vector_store_id__files__file_id__post schema
+type SpecOpenaiUpdateVectorStoreFileRequest struct {
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// SpecOpenaiUpdateVectorStoreRequest is generated from the Body_openai_update_vector_store_v1_openai_v1_vector_stores__vector_store_id__post schema
+type SpecOpenaiUpdateVectorStoreRequest struct {
+	ExpiresAfter map[string]interface{} `json:"expires_after,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	Name         *string                `json:"name,omitempty"`
+}
+
+// SpecOpenaiUploadFileRequest is generated from the Body_openai_upload_file_v1_openai_v1_files_post schema
+type SpecOpenaiUploadFileRequest struct {
+	File    string                `json:"file"`
+	Purpose SpecOpenAIFilePurpose `json:"purpose"`
+}
+
+// SpecQueryChunksRequest is generated from the Body_query_chunks_v1_vector_io_query_post schema
+type SpecQueryChunksRequest struct {
+	Params     map[string]interface{} `json:"params,omitempty"`
+	Query      interface{}            `json:"query"`
+	VectorDBID string                 `json:"vector_db_id"`
+}
+
+// SpecQueryMetricsRequest is generated from the Body_query_metrics_v1_telemetry_metrics__metric_name__post schema
+type SpecQueryMetricsRequest struct {
+	EndTime       *int                     `json:"end_time,omitempty"`
+	Granularity   *string                  `json:"granularity,omitempty"`
+	LabelMatchers []SpecMetricLabelMatcher `json:"label_matchers,omitempty"`
+	QueryType     *SpecMetricQueryType     `json:"query_type,omitempty"`
+	StartTime     int                      `json:"start_time"`
+}
+
+// SpecQuerySpansRequest is generated from the Body_query_spans_v1_telemetry_spans_post schema
+type SpecQuerySpansRequest struct {
+	AttributeFilters   []SpecQueryCondition `json:"attribute_filters"`
+	AttributesToReturn []string             `json:"attributes_to_return"`
+	MaxDepth           *int                 `json:"max_depth,omitempty"`
+}
+
+// SpecQueryTracesRequest is generated from the Body_query_traces_v1_telemetry_traces_post schema
+type SpecQueryTracesRequest struct {
+	AttributeFilters []SpecQueryCondition `json:"attribute_filters,omitempty"`
+	Limit            *int                 `json:"limit,omitempty"`
+	Offset           *int                 `json:"offset,omitempty"`
+	OrderBy          []string             `json:"order_by,omitempty"`
+}
+
+// SpecQueryRequest is generated from the Body_query_v1_tool_runtime_rag_tool_query_post schema
+type SpecQueryRequest struct {
+	Content     interface{}         `json:"content"`
+	QueryConfig *SpecRAGQueryConfig `json:"query_config,omitempty"`
+	VectorDBIDS []string            `json:"vector_db_ids"`
+}
+
+// SpecRegisterBenchmarkRequest is generated from the Body_register_benchmark_v1_eval_benchmarks_post schema
+type SpecRegisterBenchmarkRequest struct {
+	BenchmarkID         string                 `json:"benchmark_id"`
+	DatasetID           string                 `json:"dataset_id"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	ProviderBenchmarkID *string                `json:"provider_benchmark_id,omitempty"`
+	ProviderID          *string                `json:"provider_id,omitempty"`
+	ScoringFunctions    []string               `json:"scoring_functions"`
+}
+
+// SpecRegisterDatasetRequest is generated from the Body_register_dataset_v1_datasets_post schema
+type SpecRegisterDatasetRequest struct {
+	DatasetID *string                `json:"dataset_id,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Purpose   SpecDatasetPurpose     `json:"purpose"`
+	Source    interface{}            `json:"source"`
+}
+
+// SpecRegisterModelRequest is generated from the Body_register_model_v1_models_post schema
+type SpecRegisterModelRequest struct {
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	ModelID         string                 `json:"model_id"`
+	ModelType       *SpecModelType         `json:"model_type,omitempty"`
+	ProviderID      *string                `json:"provider_id,omitempty"`
+	ProviderModelID *string                `json:"provider_model_id,omitempty"`
+}
+
+// SpecRegisterScoringFunctionRequest is generated from the Body_register_scoring_function_v1_scoring_functions_post schema
+type SpecRegisterScoringFunctionRequest struct {
+	Description         string      `json:"description"`
+	Params              interface{} `json:"params,omitempty"`
+	ProviderID          *string     `json:"provider_id,omitempty"`
+	ProviderScoringFnID *string     `json:"provider_scoring_fn_id,omitempty"`
+	ReturnType          interface{} `json:"return_type"`
+	ScoringFnID         string      `json:"scoring_fn_id"`
+}
+
+// SpecRegisterShieldRequest is generated from the Body_register_shield_v1_shields_post schema
+type SpecRegisterShieldRequest struct {
+	Params           map[string]interface{} `json:"params,omitempty"`
+	ProviderID       *string                `json:"provider_id,omitempty"`
+	ProviderShieldID *string                `json:"provider_shield_id,omitempty"`
+	ShieldID         string                 `json:"shield_id"`
+}
+
+// SpecRegisterToolGroupRequest is generated from the Body_register_tool_group_v1_toolgroups_post schema
+type SpecRegisterToolGroupRequest struct {
+	Args        map[string]interface{} `json:"args,omitempty"`
+	MCPEndpoint *SpecURL               `json:"mcp_endpoint,omitempty"`
+	ProviderID  string                 `json:"provider_id"`
+	ToolgroupID string                 `json:"toolgroup_id"`
+}
+
+// SpecRegisterVectorDBRequest is generated from the Body_register_vector_db_v1_vector_dbs_post schema
+type SpecRegisterVectorDBRequest struct {
+	EmbeddingDimension *int    `json:"embedding_dimension,omitempty"`
+	EmbeddingModel     string  `json:"embedding_model"`
+	ProviderID         *string `json:"provider_id,omitempty"`
+	ProviderVectorDBID *string `json:"provider_vector_db_id,omitempty"`
+	VectorDBID         string  `json:"vector_db_id"`
+}
+
+// SpecResumeAgentTurnRequest is generated from the Body_resume_agent_turn_v1_agents__agent_id__session__session_id__turn__turn_id__resume_post schema
+type SpecResumeAgentTurnRequest struct {
+	Stream        *bool              `json:"stream,omitempty"`
+	ToolResponses []SpecToolResponse `json:"tool_responses"`
+}
+
+// SpecRunEvalRequest is generated from the Body_run_eval_v1_eval_benchmarks__benchmark_id__jobs_post schema
+type SpecRunEvalRequest struct {
+	BenchmarkConfig SpecBenchmarkConfig `json:"benchmark_config"`
+}
+
+// SpecRunShieldRequest is generated from the Body_run_shield_v1_safety_run_shield_post schema
+type SpecRunShieldRequest struct {
+	Messages []interface{}          `json:"messages"`
+	Params   map[string]interface{} `json:"params,omitempty"`
+	ShieldID string                 `json:"shield_id"`
+}
+
+// SpecSaveSpansToDatasetRequest is generated from the Body_save_spans_to_dataset_v1_telemetry_spans_export_post schema
+type SpecSaveSpansToDatasetRequest struct {
+	AttributeFilters []SpecQueryCondition `json:"attribute_filters"`
+	AttributesToSave []string             `json:"attributes_to_save"`
+	DatasetID        string               `json:"dataset_id"`
+	MaxDepth         *int                 `json:"max_depth,omitempty"`
+}
+
+// SpecScoreBatchRequest is generated from the Body_score_batch_v1_scoring_score_batch_post schema
+type SpecScoreBatchRequest struct {
+	DatasetID          string                 `json:"dataset_id"`
+	SaveResultsDataset *bool                  `json:"save_results_dataset,omitempty"`
+	ScoringFunctions   map[string]interface{} `json:"scoring_functions,omitempty"`
+}
+
+// SpecScoreRequest is generated from the Body_score_v1_scoring_score_post schema
+type SpecScoreRequest struct {
+	InputRows        []map[string]interface{} `json:"input_rows"`
+	ScoringFunctions map[string]interface{}   `json:"scoring_functions,omitempty"`
+}
+
+// SpecBooleanType is generated from the BooleanType schema
+type SpecBooleanType struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecBuiltinTool is generated from the BuiltinTool schema
+type SpecBuiltinTool string
+
+const (
+	SpecBuiltinToolBraveSearch     SpecBuiltinTool = "brave_search"
+	SpecBuiltinToolWolframAlpha    SpecBuiltinTool = "wolfram_alpha"
+	SpecBuiltinToolPhotogen        SpecBuiltinTool = "photogen"
+	SpecBuiltinToolCodeInterpreter SpecBuiltinTool = "code_interpreter"
+)
+
+// SpecChatCompletionInputType is generated from the ChatCompletionInputType schema
+type SpecChatCompletionInputType struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecChunk is generated from the Chunk schema: A chunk of content that can be inserted into a vector database. :param content: The content of the chunk, which can be interleaved text, images, or other types. :param embedding: Optional embedding for the chunk. If not provided, it will be computed later. :param metadata: Metadata associated with the chunk that will be used in the model context during inference. :param stored_chunk_id: The chunk ID that is stored in the vector database. Used for backend functionality. :param chunk_metadata: Metadata for the chunk that will NOT be used in the context during inference. The `chunk_metadata` is required backend functionality.
+type SpecChunk struct {
+	ChunkID       *string                `json:"chunk_id,omitempty"`
+	ChunkMetadata *SpecChunkMetadata     `json:"chunk_metadata,omitempty"`
+	Content       interface{}            `json:"content"`
+	Embedding     []float64              `json:"embedding,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// SpecChunkMetadata is generated from the ChunkMetadata schema: `ChunkMetadata` is backend metadata for a `Chunk` that is used to store additional information about the chunk that will not be used in the context during inference, but is required for backend functionality. The `ChunkMetadata` is set during chunk creation in `MemoryToolRuntimeImpl().insert()`and is not expected to change after. Use `Chunk.metadata` for metadata that will be used in the context during inference. :param chunk_id: The ID of the chunk. If not set, it will be generated based on the document ID and content. :param document_id: The ID of the document this chunk belongs to. :param source: The source of the content, such as a URL, file path, or other identifier. :param created_timestamp: An optional timestamp indicating when the chunk was created. :param updated_timestamp: An optional timestamp indicating when the chunk was last updated. :param chunk_window: The window of the chunk, which can be used to group related chunks together. :param chunk_tokenizer: The tokenizer used to create the chunk. Default is Tiktoken. :param chunk_embedding_model: The embedding model used to create the chunk's embedding. :param chunk_embedding_dimension: The dimension of the embedding vector for the chunk. :param content_token_count: The number of tokens in the content of the chunk. :param metadata_token_count: The number of tokens in the metadata of the chunk.
+type SpecChunkMetadata struct {
+	ChunkEmbeddingDimension *int    `json:"chunk_embedding_dimension,omitempty"`
+	ChunkEmbeddingModel     *string `json:"chunk_embedding_model,omitempty"`
+	ChunkID                 *string `json:"chunk_id,omitempty"`
+	ChunkTokenizer          *string `json:"chunk_tokenizer,omitempty"`
+	ChunkWindow             *string `json:"chunk_window,omitempty"`
+	ContentTokenCount       *int    `json:"content_token_count,omitempty"`
+	CreatedTimestamp        *int    `json:"created_timestamp,omitempty"`
+	DocumentID              *string `json:"document_id,omitempty"`
+	MetadataTokenCount      *int    `json:"metadata_token_count,omitempty"`
+	Source                  *string `json:"source,omitempty"`
+	UpdatedTimestamp        *int    `json:"updated_timestamp,omitempty"`
+}
+
+// SpecCompletionInputType is generated from the CompletionInputType schema
+type SpecCompletionInputType struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecCompletionMessage is generated from the CompletionMessage schema: A message containing the model's (assistant) response in a chat conversation.
+type SpecCompletionMessage struct {
+	Content    interface{}    `json:"content"`
+	Role       *string        `json:"role,omitempty"`
+	StopReason SpecStopReason `json:"stop_reason"`
+	ToolCalls  []SpecToolCall `json:"tool_calls,omitempty"`
+}
+
+// SpecDatasetPurpose is generated from the DatasetPurpose schema: Purpose of the dataset. Each purpose has a required input data schema.
+type SpecDatasetPurpose string
+
+const (
+	SpecDatasetPurposePostTrainingMessages SpecDatasetPurpose = "post-training/messages"
+	SpecDatasetPurposeEvalQuestionAnswer   SpecDatasetPurpose = "eval/question-answer"
+	SpecDatasetPurposeEvalMessagesAnswer   SpecDatasetPurpose = "eval/messages-answer"
+)
+
+// SpecDefaultRAGQueryGeneratorConfig is generated from the DefaultRAGQueryGeneratorConfig schema
+type SpecDefaultRAGQueryGeneratorConfig struct {
+	Separator *string `json:"separator,omitempty"`
+	Type      *string `json:"type,omitempty"`
+}
+
+// SpecDocument is generated from the Document schema: A document to be used by an agent.
+type SpecDocument struct {
+	Content  interface{} `json:"content"`
+	MimeType string      `json:"mime_type"`
+}
+
+// SpecEmbeddingTaskType is generated from the EmbeddingTaskType schema: How is the embedding being used? This is only supported by asymmetric embedding models.
+type SpecEmbeddingTaskType string
+
+const (
+	SpecEmbeddingTaskTypeQuery    SpecEmbeddingTaskType = "query"
+	SpecEmbeddingTaskTypeDocument SpecEmbeddingTaskType = "document"
+)
+
+// SpecGrammarResponseFormat is generated from the GrammarResponseFormat schema: Configuration for grammar-guided response generation.
+type SpecGrammarResponseFormat struct {
+	Bnf  map[string]interface{} `json:"bnf"`
+	Type *string                `json:"type,omitempty"`
+}
+
+// SpecGreedySamplingStrategy is generated from the GreedySamplingStrategy schema
+type SpecGreedySamplingStrategy struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecHTTPValidationError is generated from the HTTPValidationError schema
+type SpecHTTPValidationError struct {
+	Detail []SpecValidationError `json:"detail,omitempty"`
+}
+
+// SpecImageContentItem is generated from the ImageContentItem schema: A image content item
+type SpecImageContentItem struct {
+	Image SpecURLOrData `json:"image"`
+	Type  *string       `json:"type,omitempty"`
+}
+
+// SpecJsonSchemaResponseFormat is generated from the JsonSchemaResponseFormat schema: Configuration for JSON schema-guided response generation.
+type SpecJsonSchemaResponseFormat struct {
+	JSONSchema map[string]interface{} `json:"json_schema"`
+	Type       *string                `json:"type,omitempty"`
+}
+
+// SpecJsonType is generated from the JsonType schema
+type SpecJsonType struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecLLMAsJudgeScoringFnParams is generated from the LLMAsJudgeScoringFnParams schema
+type SpecLLMAsJudgeScoringFnParams struct {
+	AggregationFunctions []SpecAggregationFunctionType `json:"aggregation_functions,omitempty"`
+	JudgeModel           string                        `json:"judge_model"`
+	JudgeScoreRegexes    []string                      `json:"judge_score_regexes,omitempty"`
+	PromptTemplate       *string                       `json:"prompt_template,omitempty"`
+	Type                 *string                       `json:"type,omitempty"`
+}
+
+// SpecLLMRAGQueryGeneratorConfig is generated from the LLMRAGQueryGeneratorConfig schema
+type SpecLLMRAGQueryGeneratorConfig struct {
+	Model    string  `json:"model"`
+	Template string  `json:"template"`
+	Type     *string `json:"type,omitempty"`
+}
+
+// SpecLogProbConfig is generated from the LogProbConfig schema: :param top_k: How many tokens (for each position) to return log probabilities for.
+type SpecLogProbConfig struct {
+	TopK *int `json:"top_k,omitempty"`
+}
+
+// SpecLogSeverity is generated from the LogSeverity schema
+type SpecLogSeverity string
+
+const (
+	SpecLogSeverityVerbose  SpecLogSeverity = "verbose"
+	SpecLogSeverityDebug    SpecLogSeverity = "debug"
+	SpecLogSeverityInfo     SpecLogSeverity = "info"
+	SpecLogSeverityWarn     SpecLogSeverity = "warn"
+	SpecLogSeverityError    SpecLogSeverity = "error"
+	SpecLogSeverityCritical SpecLogSeverity = "critical"
+)
+
+// SpecMetricEvent is generated from the MetricEvent schema
+type SpecMetricEvent struct {
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Metric     string                 `json:"metric"`
+	SpanID     string                 `json:"span_id"`
+	Timestamp  string                 `json:"timestamp"`
+	TraceID    string                 `json:"trace_id"`
+	Type       *string                `json:"type,omitempty"`
+	Unit       string                 `json:"unit"`
+	Value      interface{}            `json:"value"`
+}
+
+// SpecMetricLabelMatcher is generated from the MetricLabelMatcher schema
+type SpecMetricLabelMatcher struct {
+	Name     string                   `json:"name"`
+	Operator *SpecMetricLabelOperator `json:"operator,omitempty"`
+	Value    string                   `json:"value"`
+}
+
+// SpecMetricLabelOperator is generated from the MetricLabelOperator schema
+type SpecMetricLabelOperator string
+
+const (
+	SpecMetricLabelOperatorEqual         SpecMetricLabelOperator = "="
+	SpecMetricLabelOperatorNotEqual      SpecMetricLabelOperator = "!="
+	SpecMetricLabelOperatorRegexMatch    SpecMetricLabelOperator = "=~"
+	SpecMetricLabelOperatorRegexNotMatch SpecMetricLabelOperator = "!~"
+)
+
+// SpecMetricQueryType is generated from the MetricQueryType schema
+type SpecMetricQueryType string
+
+const (
+	SpecMetricQueryTypeRange   SpecMetricQueryType = "range"
+	SpecMetricQueryTypeInstant SpecMetricQueryType = "instant"
+)
+
+// SpecModelCandidate is generated from the ModelCandidate schema: A model candidate for evaluation.
+type SpecModelCandidate struct {
+	Model          string             `json:"model"`
+	SamplingParams SpecSamplingParams `json:"sampling_params"`
+	SystemMessage  *SpecSystemMessage `json:"system_message,omitempty"`
+	Type           *string            `json:"type,omitempty"`
+}
+
+// SpecModelType is generated from the ModelType schema
+type SpecModelType string
+
+const (
+	SpecModelTypeLLM       SpecModelType = "llm"
+	SpecModelTypeEmbedding SpecModelType = "embedding"
+)
+
+// SpecNumberType is generated from the NumberType schema
+type SpecNumberType struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecObjectType is generated from the ObjectType schema
+type SpecObjectType struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIAssistantMessageParam is generated from the OpenAIAssistantMessageParam schema: A message containing the model's (assistant) response in an OpenAI-compatible chat completion request.
+type SpecOpenAIAssistantMessageParam struct {
+	Content   interface{}                        `json:"content,omitempty"`
+	Name      *string                            `json:"name,omitempty"`
+	Role      *string                            `json:"role,omitempty"`
+	ToolCalls []SpecOpenAIChatCompletionToolCall `json:"tool_calls,omitempty"`
+}
+
+// SpecOpenAIChatCompletionContentPartImageParam is generated from the OpenAIChatCompletionContentPartImageParam schema
+type SpecOpenAIChatCompletionContentPartImageParam struct {
+	ImageURL SpecOpenAIImageURL `json:"image_url"`
+	Type     *string            `json:"type,omitempty"`
+}
+
+// SpecOpenAIChatCompletionContentPartTextParam is generated from the OpenAIChatCompletionContentPartTextParam schema
+type SpecOpenAIChatCompletionContentPartTextParam struct {
+	Text string  `json:"text"`
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIChatCompletionToolCall is generated from the OpenAIChatCompletionToolCall schema
+type SpecOpenAIChatCompletionToolCall struct {
+	Function *SpecOpenAIChatCompletionToolCallFunction `json:"function,omitempty"`
+	ID       *string                                   `json:"id,omitempty"`
+	Index    *int                                      `json:"index,omitempty"`
+	Type     *string                                   `json:"type,omitempty"`
+}
+
+// SpecOpenAIChatCompletionToolCallFunction is generated from the OpenAIChatCompletionToolCallFunction schema
+type SpecOpenAIChatCompletionToolCallFunction struct {
+	Arguments *string `json:"arguments,omitempty"`
+	Name      *string `json:"name,omitempty"`
+}
+
+// SpecOpenAIDeveloperMessageParam is generated from the OpenAIDeveloperMessageParam schema: A message from the developer in an OpenAI-compatible chat completion request.
+type SpecOpenAIDeveloperMessageParam struct {
+	Content interface{} `json:"content"`
+	Name    *string     `json:"name,omitempty"`
+	Role    *string     `json:"role,omitempty"`
+}
+
+// SpecOpenAIFilePurpose is generated from the OpenAIFilePurpose schema: Valid purpose values for OpenAI Files API.
+type SpecOpenAIFilePurpose string
+
+const (
+	SpecOpenAIFilePurposeAssistants SpecOpenAIFilePurpose = "assistants"
+)
+
+// SpecOpenAIImageURL is generated from the OpenAIImageURL schema
+type SpecOpenAIImageURL struct {
+	Detail *string `json:"detail,omitempty"`
+	URL    string  `json:"url"`
+}
+
+// SpecOpenAIJSONSchema is generated from the OpenAIJSONSchema schema
+type SpecOpenAIJSONSchema struct {
+	Description *string                `json:"description,omitempty"`
+	Name        *string                `json:"name,omitempty"`
+	Schema      map[string]interface{} `json:"schema,omitempty"`
+	Strict      *bool                  `json:"strict,omitempty"`
+}
+
+// SpecOpenAIResponseAnnotationCitation is generated from the OpenAIResponseAnnotationCitation schema
+type SpecOpenAIResponseAnnotationCitation struct {
+	EndIndex   int     `json:"end_index"`
+	StartIndex int     `json:"start_index"`
+	Title      string  `json:"title"`
+	Type       *string `json:"type,omitempty"`
+	URL        string  `json:"url"`
+}
+
+// SpecOpenAIResponseAnnotationContainerFileCitation is generated from the OpenAIResponseAnnotationContainerFileCitation schema
+type SpecOpenAIResponseAnnotationContainerFileCitation struct {
+	ContainerID string  `json:"container_id"`
+	EndIndex    int     `json:"end_index"`
+	FileID      string  `json:"file_id"`
+	Filename    string  `json:"filename"`
+	StartIndex  int     `json:"start_index"`
+	Type        *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseAnnotationFileCitation is generated from the OpenAIResponseAnnotationFileCitation schema
+type SpecOpenAIResponseAnnotationFileCitation struct {
+	FileID   string  `json:"file_id"`
+	Filename string  `json:"filename"`
+	Index    int     `json:"index"`
+	Type     *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseAnnotationFilePath is generated from the OpenAIResponseAnnotationFilePath schema
+type SpecOpenAIResponseAnnotationFilePath struct {
+	FileID string  `json:"file_id"`
+	Index  int     `json:"index"`
+	Type   *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseFormatJSONObject is generated from the OpenAIResponseFormatJSONObject schema
+type SpecOpenAIResponseFormatJSONObject struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseFormatJSONSchema is generated from the OpenAIResponseFormatJSONSchema schema
+type SpecOpenAIResponseFormatJSONSchema struct {
+	JSONSchema SpecOpenAIJSONSchema `json:"json_schema"`
+	Type       *string              `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseFormatText is generated from the OpenAIResponseFormatText schema
+type SpecOpenAIResponseFormatText struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseInputFunctionToolCallOutput is generated from the OpenAIResponseInputFunctionToolCallOutput schema: This represents the output of a function call that gets passed back to the model.
+type SpecOpenAIResponseInputFunctionToolCallOutput struct {
+	CallID string  `json:"call_id"`
+	ID     *string `json:"id,omitempty"`
+	Output string  `json:"output"`
+	Status *string `json:"status,omitempty"`
+	Type   *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseInputMessageContentImage is generated from the OpenAIResponseInputMessageContentImage schema
+type SpecOpenAIResponseInputMessageContentImage struct {
+	Detail   interface{} `json:"detail,omitempty"`
+	ImageURL *string     `json:"image_url,omitempty"`
+	Type     *string     `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseInputMessageContentText is generated from the OpenAIResponseInputMessageContentText schema
+type SpecOpenAIResponseInputMessageContentText struct {
+	Text string  `json:"text"`
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseInputToolFileSearch is generated from the OpenAIResponseInputToolFileSearch schema
+type SpecOpenAIResponseInputToolFileSearch struct {
+	Filters        map[string]interface{}    `json:"filters,omitempty"`
+	MaxNumResults  *int                      `json:"max_num_results,omitempty"`
+	RankingOptions *SpecSearchRankingOptions `json:"ranking_options,omitempty"`
+	Type           *string                   `json:"type,omitempty"`
+	VectorStoreIDS []string                  `json:"vector_store_ids"`
+}
+
+// SpecOpenAIResponseInputToolFunction is generated from the OpenAIResponseInputToolFunction schema
+type SpecOpenAIResponseInputToolFunction struct {
+	Description *string                `json:"description,omitempty"`
+	Name        string                 `json:"name"`
+	Parameters  map[string]interface{} `json:"parameters"`
+	Strict      *bool                  `json:"strict,omitempty"`
+	Type        *string                `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseInputToolMCP is generated from the OpenAIResponseInputToolMCP schema
+type SpecOpenAIResponseInputToolMCP struct {
+	AllowedTools    interface{}            `json:"allowed_tools,omitempty"`
+	Headers         map[string]interface{} `json:"headers,omitempty"`
+	RequireApproval interface{}            `json:"require_approval,omitempty"`
+	ServerLabel     string                 `json:"server_label"`
+	ServerURL       string                 `json:"server_url"`
+	Type            *string                `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseInputToolWebSearch is generated from the OpenAIResponseInputToolWebSearch schema
+type SpecOpenAIResponseInputToolWebSearch struct {
+	SearchContextSize *string     `json:"search_context_size,omitempty"`
+	Type              interface{} `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseMessage is generated from the OpenAIResponseMessage schema: Corresponds to the various Message types in the Responses API. They are all under one type because the Responses API gives them all the same "type" value, and there is no way to tell them apart in certain scenarios.
+type SpecOpenAIResponseMessage struct {
+	Content interface{} `json:"content"`
+	ID      *string     `json:"id,omitempty"`
+	Role    interface{} `json:"role"`
+	Status  *string     `json:"status,omitempty"`
+	Type    *string     `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseOutputMessageContentOutputText is generated from the OpenAIResponseOutputMessageContentOutputText schema
+type SpecOpenAIResponseOutputMessageContentOutputText struct {
+	Annotations []interface{} `json:"annotations,omitempty"`
+	Text        string        `json:"text"`
+	Type        *string       `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseOutputMessageFileSearchToolCall is generated from the OpenAIResponseOutputMessageFileSearchToolCall schema
+type SpecOpenAIResponseOutputMessageFileSearchToolCall struct {
+	ID      string                   `json:"id"`
+	Queries []string                 `json:"queries"`
+	Results []map[string]interface{} `json:"results,omitempty"`
+	Status  string                   `json:"status"`
+	Type    *string                  `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseOutputMessageFunctionToolCall is generated from the OpenAIResponseOutputMessageFunctionToolCall schema
+type SpecOpenAIResponseOutputMessageFunctionToolCall struct {
+	Arguments string  `json:"arguments"`
+	CallID    string  `json:"call_id"`
+	ID        *string `json:"id,omitempty"`
+	Name      string  `json:"name"`
+	Status    *string `json:"status,omitempty"`
+	Type      *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseOutputMessageWebSearchToolCall is generated from the OpenAIResponseOutputMessageWebSearchToolCall schema
+type SpecOpenAIResponseOutputMessageWebSearchToolCall struct {
+	ID     string  `json:"id"`
+	Status string  `json:"status"`
+	Type   *string `json:"type,omitempty"`
+}
+
+// SpecOpenAIResponseText is generated from the OpenAIResponseText schema
+type SpecOpenAIResponseText struct {
+	Format *SpecOpenAIResponseTextFormat `json:"format,omitempty"`
+}
+
+// SpecOpenAIResponseTextFormat is generated from the OpenAIResponseTextFormat schema: Configuration for Responses API text format.
+type SpecOpenAIResponseTextFormat struct {
+	Description *string                `json:"description,omitempty"`
+	Name        *string                `json:"name,omitempty"`
+	Schema      map[string]interface{} `json:"schema,omitempty"`
+	Strict      *bool                  `json:"strict,omitempty"`
+	Type        interface{}            `json:"type,omitempty"`
+}
+
+// SpecOpenAISystemMessageParam is generated from the OpenAISystemMessageParam schema: A system message providing instructions or context to the model.
+type SpecOpenAISystemMessageParam struct {
+	Content interface{} `json:"content"`
+	Name    *string     `json:"name,omitempty"`
+	Role    *string     `json:"role,omitempty"`
+}
+
+// SpecOpenAIToolMessageParam is generated from the OpenAIToolMessageParam schema: A message representing the result of a tool invocation in an OpenAI-compatible chat completion request.
+type SpecOpenAIToolMessageParam struct {
+	Content    interface{} `json:"content"`
+	Role       *string     `json:"role,omitempty"`
+	ToolCallID string      `json:"tool_call_id"`
+}
+
+// SpecOpenAIUserMessageParam is generated from the OpenAIUserMessageParam schema: A message from the user in an OpenAI-compatible chat completion request.
+type SpecOpenAIUserMessageParam struct {
+	Content interface{} `json:"content"`
+	Name    *string     `json:"name,omitempty"`
+	Role    *string     `json:"role,omitempty"`
+}
+
+// SpecOrder is generated from the Order schema
+type SpecOrder string
+
+const (
+	SpecOrderAsc  SpecOrder = "asc"
+	SpecOrderDesc SpecOrder = "desc"
+)
+
+// SpecQueryCondition is generated from the QueryCondition schema
+type SpecQueryCondition struct {
+	Key   string               `json:"key"`
+	Op    SpecQueryConditionOp `json:"op"`
+	Value interface{}          `json:"value"`
+}
+
+// SpecQueryConditionOp is generated from the QueryConditionOp schema
+type SpecQueryConditionOp string
+
+const (
+	SpecQueryConditionOpEq SpecQueryConditionOp = "eq"
+	SpecQueryConditionOpNe SpecQueryConditionOp = "ne"
+	SpecQueryConditionOpGt SpecQueryConditionOp = "gt"
+	SpecQueryConditionOpLt SpecQueryConditionOp = "lt"
+)
+
+// SpecRAGDocument is generated from the RAGDocument schema: A document to be used for document ingestion in the RAG Tool.
+type SpecRAGDocument struct {
+	Content    interface{}            `json:"content"`
+	DocumentID string                 `json:"document_id"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	MimeType   *string                `json:"mime_type,omitempty"`
+}
+
+// SpecRAGQueryConfig is generated from the RAGQueryConfig schema: Configuration for the RAG query generation.
+type SpecRAGQueryConfig struct {
+	ChunkTemplate        *string     `json:"chunk_template,omitempty"`
+	MaxChunks            *int        `json:"max_chunks,omitempty"`
+	MaxTokensInContext   *int        `json:"max_tokens_in_context,omitempty"`
+	Mode                 *string     `json:"mode,omitempty"`
+	QueryGeneratorConfig interface{} `json:"query_generator_config,omitempty"`
+	Ranker               interface{} `json:"ranker,omitempty"`
+}
+
+// SpecRRFRanker is generated from the RRFRanker schema: Reciprocal Rank Fusion (RRF) ranker configuration.
+type SpecRRFRanker struct {
+	ImpactFactor *float64 `json:"impact_factor,omitempty"`
+	Type         *string  `json:"type,omitempty"`
+}
+
+// SpecRegexParserScoringFnParams is generated from the RegexParserScoringFnParams schema
+type SpecRegexParserScoringFnParams struct {
+	AggregationFunctions []SpecAggregationFunctionType `json:"aggregation_functions,omitempty"`
+	ParsingRegexes       []string                      `json:"parsing_regexes,omitempty"`
+	Type                 *string                       `json:"type,omitempty"`
+}
+
+// SpecRowsDataSource is generated from the RowsDataSource schema: A dataset stored in rows. :param rows: The dataset is stored in rows. E.g. - [ {"messages": [{"role": "user", "content": "Hello, world!"}, {"role": "assistant", "content": "Hello, world!"}]} ]
+type SpecRowsDataSource struct {
+	Rows []map[string]interface{} `json:"rows"`
+	Type *string                  `json:"type,omitempty"`
+}
+
+// SpecSamplingParams is generated from the SamplingParams schema: Sampling parameters.
+type SpecSamplingParams struct {
+	MaxTokens         *int        `json:"max_tokens,omitempty"`
+	RepetitionPenalty *float64    `json:"repetition_penalty,omitempty"`
+	Stop              []string    `json:"stop,omitempty"`
+	Strategy          interface{} `json:"strategy,omitempty"`
+}
+
+// SpecSearchRankingOptions is generated from the SearchRankingOptions schema
+type SpecSearchRankingOptions struct {
+	Ranker         *string  `json:"ranker,omitempty"`
+	ScoreThreshold *float64 `json:"score_threshold,omitempty"`
+}
+
+// SpecSpanEndPayload is generated from the SpanEndPayload schema
+type SpecSpanEndPayload struct {
+	Status SpecSpanStatus `json:"status"`
+	Type   *string        `json:"type,omitempty"`
+}
+
+// SpecSpanStartPayload is generated from the SpanStartPayload schema
+type SpecSpanStartPayload struct {
+	Name         string  `json:"name"`
+	ParentSpanID *string `json:"parent_span_id,omitempty"`
+	Type         *string `json:"type,omitempty"`
+}
+
+// SpecSpanStatus is generated from the SpanStatus schema
+type SpecSpanStatus string
+
+const (
+	SpecSpanStatusOk    SpecSpanStatus = "ok"
+	SpecSpanStatusError SpecSpanStatus = "error"
+)
+
+// SpecStopReason is generated from the StopReason schema
+type SpecStopReason string
+
+const (
+	SpecStopReasonEndOfTurn    SpecStopReason = "end_of_turn"
+	SpecStopReasonEndOfMessage SpecStopReason = "end_of_message"
+	SpecStopReasonOutOfTokens  SpecStopReason = "out_of_tokens"
+)
+
+// SpecStringType is generated from the StringType schema
+type SpecStringType struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecStructuredLogEvent is generated from the StructuredLogEvent schema
+type SpecStructuredLogEvent struct {
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Payload    interface{}            `json:"payload"`
+	SpanID     string                 `json:"span_id"`
+	Timestamp  string                 `json:"timestamp"`
+	TraceID    string                 `json:"trace_id"`
+	Type       *string                `json:"type,omitempty"`
+}
+
+// SpecSystemMessage is generated from the SystemMessage schema: A system message providing instructions or context to the model.
+type SpecSystemMessage struct {
+	Content interface{} `json:"content"`
+	Role    *string     `json:"role,omitempty"`
+}
+
+// SpecSystemMessageBehavior is generated from the SystemMessageBehavior schema: Config for how to override the default system prompt.
+type SpecSystemMessageBehavior string
+
+const (
+	SpecSystemMessageBehaviorAppend  SpecSystemMessageBehavior = "append"
+	SpecSystemMessageBehaviorReplace SpecSystemMessageBehavior = "replace"
+)
+
+// SpecTextContentItem is generated from the TextContentItem schema: A text content item
+type SpecTextContentItem struct {
+	Text string  `json:"text"`
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecTextTruncation is generated from the TextTruncation schema: Config for how to truncate text for embedding when text is longer than the model's max sequence length. Start and End semantics depend on whether the language is left-to-right or right-to-left.
+type SpecTextTruncation string
+
+const (
+	SpecTextTruncationNone  SpecTextTruncation = "none"
+	SpecTextTruncationStart SpecTextTruncation = "start"
+	SpecTextTruncationEnd   SpecTextTruncation = "end"
+)
+
+// SpecToolCall is generated from the ToolCall schema
+type SpecToolCall struct {
+	Arguments     interface{} `json:"arguments"`
+	ArgumentsJSON *string     `json:"arguments_json,omitempty"`
+	CallID        string      `json:"call_id"`
+	ToolName      interface{} `json:"tool_name"`
+}
+
+// SpecToolChoice is generated from the ToolChoice schema: Whether tool use is required or automatic. This is a hint to the model which may not be followed. It depends on the Instruction Following capabilities of the model.
+type SpecToolChoice string
+
+const (
+	SpecToolChoiceAuto     SpecToolChoice = "auto"
+	SpecToolChoiceRequired SpecToolChoice = "required"
+	SpecToolChoiceNone     SpecToolChoice = "none"
+)
+
+// SpecToolConfig is generated from the ToolConfig schema: Configuration for tool use.
+type SpecToolConfig struct {
+	SystemMessageBehavior *SpecSystemMessageBehavior `json:"system_message_behavior,omitempty"`
+	ToolChoice            interface{}                `json:"tool_choice,omitempty"`
+	ToolPromptFormat      *SpecToolPromptFormat      `json:"tool_prompt_format,omitempty"`
+}
+
+// SpecToolDef is generated from the ToolDef schema
+type SpecToolDef struct {
+	Description *string                `json:"description,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Name        string                 `json:"name"`
+	Parameters  []SpecToolParameter    `json:"parameters,omitempty"`
+}
+
+// SpecToolDefinition is generated from the ToolDefinition schema
+type SpecToolDefinition struct {
+	Description *string                `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	ToolName    interface{}            `json:"tool_name"`
+}
+
+// SpecToolParamDefinition is generated from the ToolParamDefinition schema
+type SpecToolParamDefinition struct {
+	Default     interface{} `json:"default,omitempty"`
+	Description *string     `json:"description,omitempty"`
+	ParamType   string      `json:"param_type"`
+	Required    *bool       `json:"required,omitempty"`
+}
+
+// SpecToolParameter is generated from the ToolParameter schema
+type SpecToolParameter struct {
+	Default       interface{} `json:"default,omitempty"`
+	Description   string      `json:"description"`
+	Name          string      `json:"name"`
+	ParameterType string      `json:"parameter_type"`
+	Required      *bool       `json:"required,omitempty"`
+}
+
+// SpecToolPromptFormat is generated from the ToolPromptFormat schema: Prompt format for calling custom / zero shot tools.
+type SpecToolPromptFormat string
+
+const (
+	SpecToolPromptFormatJSON        SpecToolPromptFormat = "json"
+	SpecToolPromptFormatFunctionTag SpecToolPromptFormat = "function_tag"
+	SpecToolPromptFormatPythonList  SpecToolPromptFormat = "python_list"
+)
+
+// SpecToolResponse is generated from the ToolResponse schema
+type SpecToolResponse struct {
+	CallID   string                 `json:"call_id"`
+	Content  interface{}            `json:"content"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	ToolName interface{}            `json:"tool_name"`
+}
+
+// SpecToolResponseMessage is generated from the ToolResponseMessage schema: A message representing the result of a tool invocation.
+type SpecToolResponseMessage struct {
+	CallID  string      `json:"call_id"`
+	Content interface{} `json:"content"`
+	Role    *string     `json:"role,omitempty"`
+}
+
+// SpecTopKSamplingStrategy is generated from the TopKSamplingStrategy schema
+type SpecTopKSamplingStrategy struct {
+	TopK int     `json:"top_k"`
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecTopPSamplingStrategy is generated from the TopPSamplingStrategy schema
+type SpecTopPSamplingStrategy struct {
+	Temperature *float64 `json:"temperature"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Type        *string  `json:"type,omitempty"`
+}
+
+// SpecURIDataSource is generated from the URIDataSource schema: A dataset that can be obtained from a URI. :param uri: The dataset can be obtained from a URI. E.g. - "https://mywebsite.com/mydata.jsonl" - "lsfs://mydata.jsonl" - "data:csv;base64,{base64_content}"
+type SpecURIDataSource struct {
+	Type *string `json:"type,omitempty"`
+	URI  string  `json:"uri"`
+}
+
+// SpecURL is generated from the URL schema
+type SpecURL struct {
+	URI string `json:"uri"`
+}
+
+// SpecUnionType is generated from the UnionType schema
+type SpecUnionType struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecUnstructuredLogEvent is generated from the UnstructuredLogEvent schema
+type SpecUnstructuredLogEvent struct {
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Message    string                 `json:"message"`
+	Severity   SpecLogSeverity        `json:"severity"`
+	SpanID     string                 `json:"span_id"`
+	Timestamp  string                 `json:"timestamp"`
+	TraceID    string                 `json:"trace_id"`
+	Type       *string                `json:"type,omitempty"`
+}
+
+// SpecUserMessage is generated from the UserMessage schema: A message from the user in a chat conversation.
+type SpecUserMessage struct {
+	Content interface{} `json:"content"`
+	Context interface{} `json:"context,omitempty"`
+	Role    *string     `json:"role,omitempty"`
+}
+
+// SpecValidationError is generated from the ValidationError schema
+type SpecValidationError struct {
+	Loc  []interface{} `json:"loc"`
+	Msg  string        `json:"msg"`
+	Type string        `json:"type"`
+}
+
+// SpecVectorStoreChunkingStrategyAuto is generated from the VectorStoreChunkingStrategyAuto schema
+type SpecVectorStoreChunkingStrategyAuto struct {
+	Type *string `json:"type,omitempty"`
+}
+
+// SpecVectorStoreChunkingStrategyStatic is generated from the VectorStoreChunkingStrategyStatic schema
+type SpecVectorStoreChunkingStrategyStatic struct {
+	Static SpecVectorStoreChunkingStrategyStaticConfig `json:"static"`
+	Type   *string                                     `json:"type,omitempty"`
+}
+
+// SpecVectorStoreChunkingStrategyStaticConfig is generated from the VectorStoreChunkingStrategyStaticConfig schema
+type SpecVectorStoreChunkingStrategyStaticConfig struct {
+	ChunkOverlapTokens *int `json:"chunk_overlap_tokens,omitempty"`
+	MaxChunkSizeTokens *int `json:"max_chunk_size_tokens,omitempty"`
+}
+
+// SpecWeightedRanker is generated from the WeightedRanker schema: Weighted ranker configuration that combines vector and keyword scores.
+type SpecWeightedRanker struct {
+	Alpha *float64 `json:"alpha,omitempty"`
+	Type  *string  `json:"type,omitempty"`
+}
+
+// SpecURLOrData is generated from the _URLOrData schema: A URL or a base64 encoded string
+type SpecURLOrData struct {
+	Data *string  `json:"data,omitempty"`
+	URL  *SpecURL `json:"url,omitempty"`
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// OutputHook receives a streamed reply in sentence-sized segments as each
+// one completes, e.g. to speak it with a text-to-speech engine while the
+// rest of the reply is still being generated. Hooks are called on the
+// goroutine reading the stream, so slow ones should be wrapped in AsyncHook.
+type OutputHook interface {
+	// OnSegment is called with each complete segment, in order
+	OnSegment(segment string) error
+	// OnEnd is called once after the last segment
+	OnEnd() error
+}
+
+// DefaultMaxSegmentRunes is where a sentence without an end is split, at
+// its last space, so a hook is not kept waiting by a run-on reply
+const DefaultMaxSegmentRunes = 300
+
+// sentenceAbbreviations end with a period without ending a sentence
+var sentenceAbbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true,
+	"st": true, "vs": true, "e.g": true, "i.e": true, "cf": true, "fig": true, "approx": true,
+}
+
+// SegmentWriter splits the text written to it into segments for output
+// hooks: sentences, lines such as list items and headings, and whole fenced
+// code blocks. Write it the deltas of a stream, e.g. next to the terminal
+// with io.MultiWriter, and Close it when the stream ends. A hook that
+// returns an error is not called again; the errors are returned by Close,
+// so a failing hook never interrupts the reply.
+type SegmentWriter struct {
+	// MaxSegmentRunes splits longer segments; zero means DefaultMaxSegmentRunes
+	MaxSegmentRunes int
+
+	hooks  []OutputHook
+	failed []bool
+	errs   []error
+	buf    string
+}
+
+// NewSegmentWriter returns a writer feeding hooks
+func NewSegmentWriter(hooks ...OutputHook) *SegmentWriter {
+	return &SegmentWriter{hooks: hooks, failed: make([]bool, len(hooks))}
+}
+
+// Write buffers p and passes every segment it completes to the hooks. It
+// never fails.
+func (w *SegmentWriter) Write(p []byte) (int, error) {
+	w.buf += string(p)
+	for {
+		end, next := w.nextSegment(false)
+		if end < 0 {
+			return len(p), nil
+		}
+		w.emit(w.buf[:end])
+		w.buf = w.buf[next:]
+	}
+}
+
+// Close passes the rest of the text to the hooks as the last segment, ends
+// them and returns their errors
+func (w *SegmentWriter) Close() error {
+	for w.buf != "" {
+		end, next := w.nextSegment(true)
+		w.emit(w.buf[:end])
+		w.buf = w.buf[next:]
+	}
+	for i, h := range w.hooks {
+		if !w.failed[i] {
+			if err := h.OnEnd(); err != nil {
+				w.errs = append(w.errs, err)
+			}
+		}
+	}
+	return errors.Join(w.errs...)
+}
+
+func (w *SegmentWriter) emit(segment string) {
+	segment = strings.TrimSpace(segment)
+	if segment == "" {
+		return
+	}
+	for i, h := range w.hooks {
+		if w.failed[i] {
+			continue
+		}
+		if err := h.OnSegment(segment); err != nil {
+			w.failed[i] = true
+			w.errs = append(w.errs, fmt.Errorf("output hook %d: %w", i+1, err))
+		}
+	}
+}
+
+// nextSegment finds the end of the first segment of the buffer and where
+// the one after it starts, or -1 if more text is needed to tell. At the
+// end of the stream, the whole buffer is a segment if nothing ends sooner.
+func (w *SegmentWriter) nextSegment(final bool) (end, next int) {
+	s := w.buf
+	start := len(s) - len(strings.TrimLeftFunc(s, unicode.IsSpace))
+
+	// A fenced code block is one segment, up to the end of its closing fence
+	if strings.HasPrefix(s[start:], "```") {
+		if i := strings.Index(s[start+3:], "\n```"); i >= 0 {
+			fence := start + 3 + i + 4
+			if nl := strings.IndexByte(s[fence:], '\n'); nl >= 0 {
+				return fence + nl, fence + nl + 1
+			}
+		}
+		if final {
+			return len(s), len(s)
+		}
+		return -1, -1
+	}
+
+	maxRunes := w.MaxSegmentRunes
+	if maxRunes <= 0 {
+		maxRunes = DefaultMaxSegmentRunes
+	}
+	runes, lastSpace := 0, -1
+	for i := start; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size <= 1 && !final && !utf8.FullRuneInString(s[i:]) {
+			return -1, -1 // a rune split between writes
+		}
+		switch {
+		case r == '\n':
+			return i, i + 1
+		case r == '。' || r == '！' || r == '？':
+			j := skipClosers(s, i+size)
+			if j < len(s) || final {
+				return j, j
+			}
+			return -1, -1
+		case r == '.' || r == '!' || r == '?' || r == '…':
+			j := i + size
+			for j < len(s) && strings.ContainsRune(".!?…", rune(s[j])) {
+				j++
+			}
+			j = skipClosers(s, j)
+			if j == len(s) {
+				if final {
+					return j, j
+				}
+				return -1, -1
+			}
+			if next, _ := utf8.DecodeRuneInString(s[j:]); unicode.IsSpace(next) && (r != '.' || !w.continuesAfterPeriod(s[start:i])) {
+				return j, j
+			}
+		case unicode.IsSpace(r):
+			lastSpace = i
+		}
+		runes++
+		if runes > maxRunes && lastSpace > start {
+			return lastSpace, lastSpace + 1
+		}
+		i += size
+	}
+	if final {
+		return len(s), len(s)
+	}
+	return -1, -1
+}
+
+// continuesAfterPeriod reports whether a period after text is part of an
+// abbreviation, an initial or a list number rather than a sentence end
+func (w *SegmentWriter) continuesAfterPeriod(text string) bool {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return true
+	}
+	word := strings.TrimLeft(fields[len(fields)-1], "(\"'“‘*_")
+	if len(fields) == 1 && word != "" && strings.Trim(word, "0123456789") == "" {
+		return true // "1." of a numbered list
+	}
+	if r, size := utf8.DecodeRuneInString(word); size == len(word) && unicode.IsUpper(r) {
+		return true // an initial, as in "J. R. R. Tolkien"
+	}
+	return sentenceAbbreviations[strings.ToLower(word)]
+}
+
+// skipClosers skips quotes, brackets and emphasis closing a sentence
+func skipClosers(s string, i int) int {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !strings.ContainsRune("\"')]”’*_", r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// SegmentPrinter is a sample OutputHook writing each segment on its own
+// line with the time since the reply started, to see when a TTS engine
+// could have started speaking it
+type SegmentPrinter struct {
+	W     io.Writer
+	start time.Time
+}
+
+// NewSegmentPrinter returns a SegmentPrinter writing to w, timing from now
+// and then from the end of each reply
+func NewSegmentPrinter(w io.Writer) *SegmentPrinter {
+	return &SegmentPrinter{W: w, start: time.Now()}
+}
+
+func (p *SegmentPrinter) OnSegment(segment string) error {
+	_, err := fmt.Fprintf(p.W, "[segment +%.1fs] %s\n", time.Since(p.start).Seconds(), segment)
+	return err
+}
+
+func (p *SegmentPrinter) OnEnd() error {
+	p.start = time.Now()
+	return nil
+}
+
+// CommandHook is an OutputHook running a command for each segment with the
+// segment as its last argument, e.g. {"espeak"} or {"say", "-v", "Samantha"}
+// to speak a reply. Wrap it in AsyncHook so the stream does not wait for
+// the speech.
+type CommandHook struct {
+	Command []string
+}
+
+func (h *CommandHook) OnSegment(segment string) error {
+	if len(h.Command) == 0 {
+		return errors.New("output hook has no command")
+	}
+	cmd := exec.Command(h.Command[0], append(h.Command[1:], segment)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s failed: %w: %s", h.Command[0], err, msg)
+		}
+		return fmt.Errorf("%s failed: %w", h.Command[0], err)
+	}
+	return nil
+}
+
+func (h *CommandHook) OnEnd() error {
+	return nil
+}
+
+// asyncHook runs a hook on a goroutine of its own for each reply
+type asyncHook struct {
+	hook     OutputHook
+	segments chan string
+	done     chan error
+}
+
+// AsyncHook runs hook on its own goroutine, so the stream goes on while it
+// works. Segments are queued, up to 64 before the stream waits, and handed
+// to hook in order. OnEnd waits for the queue to drain and returns hook's
+// first error of the reply; the hook can then be used for the next reply.
+func AsyncHook(hook OutputHook) OutputHook {
+	return &asyncHook{hook: hook}
+}
+
+// OnSegment queues segment; errors of the hook are reported by OnEnd
+func (h *asyncHook) OnSegment(segment string) error {
+	if h.segments == nil {
+		h.segments, h.done = make(chan string, 64), make(chan error, 1)
+		go func(segments <-chan string, done chan<- error) {
+			var err error
+			for segment := range segments {
+				if err == nil {
+					err = h.hook.OnSegment(segment)
+				}
+			}
+			done <- err
+		}(h.segments, h.done)
+	}
+	h.segments <- segment
+	return nil
+}
+
+func (h *asyncHook) OnEnd() error {
+	var err error
+	if h.segments != nil {
+		close(h.segments)
+		err = <-h.done
+		h.segments, h.done = nil, nil
+	}
+	return errors.Join(err, h.hook.OnEnd())
+}
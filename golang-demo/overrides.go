@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type contextAPIKeyKey struct{}
+
+type contextBaseURLKey struct{}
+
+// WithContextAPIKey returns a context whose requests authenticate with key
+// instead of the client's APIKey, so a multi-tenant service can share one
+// client across users
+func WithContextAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, contextAPIKeyKey{}, key)
+}
+
+// WithContextBaseURL returns a context whose requests go to baseURL instead
+// of the client's BaseURL. Replicas, load balancing and hedging apply to the
+// client's own endpoints only, so such requests go straight to baseURL.
+func WithContextBaseURL(ctx context.Context, baseURL string) context.Context {
+	return context.WithValue(ctx, contextBaseURLKey{}, strings.TrimSuffix(baseURL, "/"))
+}
+
+// baseURL returns the base URL requests made with ctx go to
+func (c *LlamaStackClient) baseURL(ctx context.Context) string {
+	if u, ok := ctx.Value(contextBaseURLKey{}).(string); ok && u != "" {
+		return u
+	}
+	return c.BaseURL
+}
+
+// applyOverrides points req at the context's base URL and sets its API key
+// and provider data
+func (c *LlamaStackClient) applyOverrides(req *http.Request) error {
+	ctx := req.Context()
+	if key, ok := ctx.Value(contextAPIKeyKey{}).(string); ok {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	if req.Header.Get(ProviderDataHeader) == "" {
+		header, err := c.providerDataHeader(ctx)
+		if err != nil {
+			return err
+		}
+		if header != "" {
+			req.Header.Set(ProviderDataHeader, header)
+		}
+	}
+	base := c.baseURL(ctx)
+	if base == c.BaseURL || !strings.HasPrefix(req.URL.String(), c.BaseURL) {
+		return nil
+	}
+	u, err := url.Parse(base + strings.TrimPrefix(req.URL.String(), c.BaseURL))
+	if err != nil {
+		return fmt.Errorf("invalid context base URL: %w", err)
+	}
+	req.URL, req.Host = u, u.Host
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Page is one page of a list endpoint, and the response type of every list
+// method. Endpoints that do not page fill Data only. OpenAI-style endpoints
+// also send the first_id/last_id cursors, while Llama Stack's own paged
+// endpoints, such as agents and sessions, send has_more and are paged by
+// start index.
+type Page[T any] struct {
+	Data    []T    `json:"data"`
+	Object  string `json:"object,omitempty"`
+	FirstID string `json:"first_id,omitempty"`
+	LastID  string `json:"last_id,omitempty"`
+	HasMore bool   `json:"has_more,omitempty"`
+}
+
+// pageItem is implemented by items of cursor-paged lists, whose IDs are the
+// cursors
+type pageItem interface {
+	pageID() string
+}
+
+// NextCursor returns the "after" cursor of the next page, and false on the
+// last page. Servers that omit last_id get the ID of the last item.
+func (p *Page[T]) NextCursor() (string, bool) {
+	if !p.HasMore || len(p.Data) == 0 {
+		return "", false
+	}
+	if p.LastID != "" {
+		return p.LastID, true
+	}
+	if item, ok := any(p.Data[len(p.Data)-1]).(pageItem); ok && item.pageID() != "" {
+		return item.pageID(), true
+	}
+	return "", false
+}
+
+// NextStartIndex returns the start index of the page after this one, which
+// began at start, and false on the last page
+func (p *Page[T]) NextStartIndex(start int) (int, bool) {
+	if !p.HasMore || len(p.Data) == 0 {
+		return 0, false
+	}
+	return start + len(p.Data), true
+}
+
+func (s VectorStore) pageID() string     { return s.ID }
+func (f VectorStoreFile) pageID() string { return f.ID }
+func (f FileResponse) pageID() string    { return f.ID }
+
+// listAfter returns every item of a cursor-paged list endpoint, following
+// next-page cursors
+func listAfter[T any](ctx context.Context, c *LlamaStackClient, path string) ([]T, error) {
+	var items []T
+	after := ""
+	for {
+		query := url.Values{"limit": {fmt.Sprint(resourcePageSize)}}
+		if after != "" {
+			query.Set("after", after)
+		}
+		var page Page[T]
+		if err := c.getJSON(ctx, withQuery(path, query), &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Data...)
+		next, ok := page.NextCursor()
+		if !ok {
+			return items, nil
+		}
+		after = next
+	}
+}
+
+// listFrom returns every item of a list endpoint paged by start index
+func listFrom[T any](ctx context.Context, c *LlamaStackClient, path string) ([]T, error) {
+	var items []T
+	start := 0
+	for {
+		query := url.Values{"start_index": {fmt.Sprint(start)}, "limit": {fmt.Sprint(resourcePageSize)}}
+		var page Page[T]
+		if err := c.getJSON(ctx, withQuery(path, query), &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Data...)
+		next, ok := page.NextStartIndex(start)
+		if !ok {
+			return items, nil
+		}
+		start = next
+	}
+}
+
+// withQuery appends query to a path that may already have one
+func withQuery(path string, query url.Values) string {
+	if strings.Contains(path, "?") {
+		return path + "&" + query.Encode()
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// errPartialEOF marks input that ends inside a value
+var errPartialEOF = errors.New("unexpected end of partial JSON")
+
+// PartialJSON parses JSON as it streams in. After each Write, Value returns
+// the document received so far with only its completed fields: strings,
+// numbers and literals appear once they are whole, while objects and arrays
+// appear as soon as they open and fill in as their members complete. Text
+// before the first '{' or '[', such as a markdown fence, is skipped.
+type PartialJSON struct {
+	buf      strings.Builder
+	value    interface{}
+	complete bool
+	err      error
+}
+
+// Write appends streamed text and reparses the document. It returns an
+// error once the text can no longer be the prefix of a JSON document.
+func (p *PartialJSON) Write(text string) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.buf.WriteString(text)
+	s := p.buf.String()
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return nil
+	}
+	parser := partialParser{s: s, i: start}
+	value, err := parser.value()
+	switch {
+	case err == nil:
+		p.value, p.complete = value, true
+	case errors.Is(err, errPartialEOF):
+		p.value, p.complete = value, false
+	default:
+		p.err = err
+	}
+	return p.err
+}
+
+// Value returns the completed part of the document; nil until it opens
+func (p *PartialJSON) Value() interface{} {
+	return p.value
+}
+
+// Complete reports whether the whole document has been received
+func (p *PartialJSON) Complete() bool {
+	return p.complete
+}
+
+// Text returns everything written so far
+func (p *PartialJSON) Text() string {
+	return p.buf.String()
+}
+
+// partialParser is a recursive descent JSON parser that returns what it
+// parsed along with errPartialEOF when the input ends inside a value
+type partialParser struct {
+	s string
+	i int
+}
+
+func (p *partialParser) skipSpace() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *partialParser) value() (interface{}, error) {
+	p.skipSpace()
+	if p.i >= len(p.s) {
+		return nil, errPartialEOF
+	}
+	switch c := p.s[p.i]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		return p.str()
+	case c == 't':
+		return p.literal("true", true)
+	case c == 'f':
+		return p.literal("false", false)
+	case c == 'n':
+		return p.literal("null", nil)
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	default:
+		return nil, fmt.Errorf("invalid character %q at offset %d", c, p.i)
+	}
+}
+
+func (p *partialParser) object() (interface{}, error) {
+	obj := map[string]interface{}{}
+	p.i++ // '{'
+	for first := true; ; first = false {
+		p.skipSpace()
+		if p.i >= len(p.s) {
+			return obj, errPartialEOF
+		}
+		if p.s[p.i] == '}' {
+			p.i++
+			return obj, nil
+		}
+		if !first {
+			if p.s[p.i] != ',' {
+				return obj, fmt.Errorf("expected ',' or '}' at offset %d", p.i)
+			}
+			p.i++
+			p.skipSpace()
+			if p.i >= len(p.s) {
+				return obj, errPartialEOF
+			}
+		}
+		if p.s[p.i] != '"' {
+			return obj, fmt.Errorf("expected a property name at offset %d", p.i)
+		}
+		key, err := p.str()
+		if err != nil {
+			return obj, err
+		}
+		p.skipSpace()
+		if p.i >= len(p.s) {
+			return obj, errPartialEOF
+		}
+		if p.s[p.i] != ':' {
+			return obj, fmt.Errorf("expected ':' at offset %d", p.i)
+		}
+		p.i++
+		v, err := p.value()
+		if err != nil {
+			// Open containers are shown with what they hold so far
+			if errors.Is(err, errPartialEOF) && isContainer(v) {
+				obj[key.(string)] = v
+			}
+			return obj, err
+		}
+		obj[key.(string)] = v
+	}
+}
+
+func (p *partialParser) array() (interface{}, error) {
+	arr := []interface{}{}
+	p.i++ // '['
+	for first := true; ; first = false {
+		p.skipSpace()
+		if p.i >= len(p.s) {
+			return arr, errPartialEOF
+		}
+		if p.s[p.i] == ']' {
+			p.i++
+			return arr, nil
+		}
+		if !first {
+			if p.s[p.i] != ',' {
+				return arr, fmt.Errorf("expected ',' or ']' at offset %d", p.i)
+			}
+			p.i++
+		}
+		v, err := p.value()
+		if err != nil {
+			if errors.Is(err, errPartialEOF) && isContainer(v) {
+				arr = append(arr, v)
+			}
+			return arr, err
+		}
+		arr = append(arr, v)
+	}
+}
+
+func (p *partialParser) str() (interface{}, error) {
+	start := p.i
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch p.s[p.i] {
+		case '\\':
+			p.i++
+		case '"':
+			p.i++
+			var s string
+			if err := json.Unmarshal([]byte(p.s[start:p.i]), &s); err != nil {
+				return nil, fmt.Errorf("invalid string at offset %d: %w", start, err)
+			}
+			return s, nil
+		}
+	}
+	return nil, errPartialEOF
+}
+
+// number is incomplete until a character follows it, since more digits may
+// still be on their way
+func (p *partialParser) number() (interface{}, error) {
+	start := p.i
+	for p.i < len(p.s) && strings.IndexByte("+-0123456789.eE", p.s[p.i]) >= 0 {
+		p.i++
+	}
+	if p.i >= len(p.s) {
+		return nil, errPartialEOF
+	}
+	var f float64
+	if err := json.Unmarshal([]byte(p.s[start:p.i]), &f); err != nil {
+		return nil, fmt.Errorf("invalid number at offset %d", start)
+	}
+	return f, nil
+}
+
+func (p *partialParser) literal(word string, v interface{}) (interface{}, error) {
+	rest := p.s[p.i:]
+	if len(rest) < len(word) {
+		if strings.HasPrefix(word, rest) {
+			return nil, errPartialEOF
+		}
+	} else if rest[:len(word)] == word {
+		p.i += len(word)
+		return v, nil
+	}
+	return nil, fmt.Errorf("invalid literal at offset %d", p.i)
+}
+
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// StreamStructured streams a structured completion and yields a T each time
+// another field of the reply completes, so a UI can render the result as it
+// arrives. The T holds zero values for the fields still to come. The last T
+// is the whole reply, which is then validated against out.Schema; a reply
+// that does not match yields a *SchemaValidationError. Streaming replies are
+// not repaired, since earlier values have already been shown.
+func StreamStructured[T any](ctx context.Context, client *LlamaStackClient, params ChatCompletionParams, out StructuredOutput) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if out.Schema == nil {
+			yield(zero, errors.New("structured output needs a schema"))
+			return
+		}
+		name := out.Name
+		if name == "" {
+			name = "response"
+		}
+		params.ResponseFormat = JSONSchemaResponseFormat(name, out.Schema)
+
+		var partial PartialJSON
+		last := ""
+		for chunk, err := range client.StreamChatCompletion(ctx, params) {
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+				continue
+			}
+			if err := partial.Write(chunk.Choices[0].Delta.Content); err != nil {
+				yield(zero, &SchemaValidationError{
+					Errors:   []SchemaError{{Path: "$", Message: "invalid JSON: " + err.Error()}},
+					Output:   partial.Text(),
+					Attempts: 1,
+				})
+				return
+			}
+			if partial.Value() == nil {
+				continue
+			}
+			data, _ := json.Marshal(partial.Value())
+			if string(data) == last {
+				continue
+			}
+			last = string(data)
+			var v T
+			if err := json.Unmarshal(data, &v); err != nil {
+				yield(zero, fmt.Errorf("failed to decode partial reply: %w", err))
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+
+		var errs []SchemaError
+		if !partial.Complete() {
+			errs = []SchemaError{{Path: "$", Message: "reply ended before the JSON did"}}
+		} else {
+			errs = ValidateJSONSchema(out.Schema, partial.Value())
+		}
+		if len(errs) > 0 {
+			yield(zero, &SchemaValidationError{Errors: errs, Output: partial.Text(), Attempts: 1})
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPartialJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		want     string // JSON of Value(), "" for nil
+		complete bool
+		invalid  bool
+	}{
+		{name: "nothing yet", text: ""},
+		{name: "fence before the document", text: "```json\n"},
+		{name: "open object", text: `{`, want: `{}`},
+		{name: "unfinished key", text: `{"na`, want: `{}`},
+		{name: "unfinished string", text: `{"name": "Do`, want: `{}`},
+		{name: "completed string", text: `{"name": "Dora", "age": `, want: `{"name":"Dora"}`},
+		{name: "unfinished number", text: `{"age": 12`, want: `{}`},
+		{name: "number ended by a comma", text: `{"age": 12,`, want: `{"age":12}`},
+		{name: "unfinished literal", text: `{"ok": tr`, want: `{}`},
+		{name: "completed literal", text: `{"ok": true, "x": nu`, want: `{"ok":true}`},
+		{name: "open array", text: `{"tags": ["pug", "sm`, want: `{"tags":["pug"]}`},
+		{name: "open nested object", text: `{"owner": {"name": "Ana", "city"`, want: `{"owner":{"name":"Ana"}}`},
+		{name: "top-level array", text: `[1, 2`, want: `[1]`},
+		{name: "escaped quote", text: `{"quote": "say \"hi\"", "n`, want: `{"quote":"say \"hi\""}`},
+		{name: "complete", text: `{"name": "Dora", "tags": []}`, want: `{"name":"Dora","tags":[]}`, complete: true},
+		{name: "trailing fence", text: "```json\n{\"name\": \"Dora\"}\n```", want: `{"name":"Dora"}`, complete: true},
+		{name: "missing colon", text: `{"name" "Dora"}`, invalid: true},
+		{name: "unquoted key", text: `{name: 1}`, invalid: true},
+		{name: "bad literal", text: `{"ok": trve}`, invalid: true},
+		{name: "bad value", text: `{"ok": yes}`, invalid: true},
+		{name: "missing comma", text: `[1 2]`, invalid: true},
+		{name: "bad number", text: `{"n": 1-2}`, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p PartialJSON
+			err := p.Write(tt.text)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("Write(%q) accepted invalid JSON", tt.text)
+				}
+				if p.Write(`}`) == nil {
+					t.Fatal("Write after an error succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Write(%q): %v", tt.text, err)
+			}
+			got := ""
+			if v := p.Value(); v != nil {
+				data, _ := json.Marshal(v)
+				got = string(data)
+			}
+			if got != tt.want || p.Complete() != tt.complete {
+				t.Fatalf("Value() = %s, Complete() = %v, want %s and %v", got, p.Complete(), tt.want, tt.complete)
+			}
+		})
+	}
+}
+
+func TestPartialJSONByteByByte(t *testing.T) {
+	doc := `{"name": "Dora", "age": 12.5, "owner": {"name": "Ana"}, "tags": ["pug", "small"], "ok": false}`
+	var want interface{}
+	if err := json.Unmarshal([]byte(doc), &want); err != nil {
+		t.Fatal(err)
+	}
+	var p PartialJSON
+	fields := 0
+	for i := range len(doc) {
+		if err := p.Write(doc[i : i+1]); err != nil {
+			t.Fatalf("after %q: %v", doc[:i+1], err)
+		}
+		// Fields only ever fill in
+		if obj, ok := p.Value().(map[string]interface{}); ok {
+			if len(obj) < fields {
+				t.Fatalf("after %q the value lost fields: %v", doc[:i+1], obj)
+			}
+			fields = len(obj)
+		}
+		if p.Complete() != (i == len(doc)-1) {
+			t.Fatalf("after %q Complete() = %v", doc[:i+1], p.Complete())
+		}
+	}
+	if !reflect.DeepEqual(p.Value(), want) || p.Text() != doc {
+		t.Fatalf("final value %v, want %v", p.Value(), want)
+	}
+}
+
+func TestStreamStructured(t *testing.T) {
+	type dog struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"name": map[string]interface{}{"type": "string"}, "age": map[string]interface{}{"type": "integer"}},
+		"required":   []interface{}{"name", "age"},
+	}
+	tests := []struct {
+		name    string
+		reply   string
+		last    dog
+		wantErr string
+	}{
+		{name: "valid", reply: "```json\n{\"name\": \"Dora\", \"age\": 3}\n```", last: dog{"Dora", 3}},
+		{name: "does not match", reply: `{"name": "Dora", "owner": "Ana"}`, last: dog{Name: "Dora"}, wantErr: `missing required property "age"`},
+		{name: "cut short", reply: `{"name": "Dora", "age": 3`, last: dog{Name: "Dora"}, wantErr: "reply ended before the JSON did"},
+		{name: "invalid JSON", reply: `{"name" "Dora"}`, wantErr: "invalid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockStack()
+			defer mock.Close()
+			mock.ChatReplies = []string{tt.reply}
+			client := NewLlamaStackClient(mock.URL(), "test")
+
+			var values []dog
+			var err error
+			for v, e := range StreamStructured[dog](context.Background(), client, ChatCompletionParams{Model: "m"}, StructuredOutput{Schema: schema}) {
+				if e != nil {
+					err = e
+					break
+				}
+				values = append(values, v)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("StreamStructured: %v", err)
+			}
+			if tt.wantErr != "" {
+				var verr *SchemaValidationError
+				if !errors.As(err, &verr) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want a *SchemaValidationError with %q", err, tt.wantErr)
+				}
+			}
+			if len(values) == 0 || values[len(values)-1] != tt.last {
+				t.Fatalf("streamed %+v, want values ending with %+v", values, tt.last)
+			}
+			// The reply arrives a word at a time, so fields complete one by one
+			if tt.wantErr == "" && len(values) < 2 {
+				t.Fatalf("streamed %+v all at once", values)
+			}
+		})
+	}
+}
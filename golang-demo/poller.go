@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// ErrPollTimeout is returned by Poller.Wait when PollOptions.Timeout passes
+// before the polled value is done
+var ErrPollTimeout = errors.New("polling timed out")
+
+// PollOptions configures how a Poller waits between polls
+type PollOptions[T any] struct {
+	Interval    time.Duration // delay before the second poll; defaults to 1s
+	MaxInterval time.Duration // cap on the delay; defaults to 30s
+	Multiplier  float64       // delay growth per poll; defaults to 2, use 1 for a fixed interval
+	Jitter      float64       // random spread of each delay as a fraction, e.g. 0.1 for ±10%
+	Timeout     time.Duration // give up after this long; wait until ctx is done when zero
+
+	// Progress, if set, is called with every polled value, so callers can
+	// report status changes while waiting
+	Progress func(value T, attempt int)
+}
+
+// Poller polls a job-style resource until it reaches a final state. Poll
+// fetches the current value and Done decides whether it is final; a non-nil
+// error from Done, such as a failed job, ends polling with that error.
+// Delays grow exponentially from Interval up to MaxInterval with optional
+// jitter, so many waiting clients do not poll a server in lockstep.
+type Poller[T any] struct {
+	Poll    func(ctx context.Context) (T, error)
+	Done    func(value T) (bool, error)
+	Options PollOptions[T]
+
+	// Retryable reports whether a Poll error is worth another poll. By
+	// default network errors, 429s and 5xx responses are retried and any
+	// other error ends polling.
+	Retryable func(err error) bool
+
+	// Logger, if set, receives a warning for every retried Poll error
+	Logger *slog.Logger
+}
+
+// Wait polls until Done reports the value is final and returns it
+func (p *Poller[T]) Wait(ctx context.Context) (T, error) {
+	opts := p.Options
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.MaxInterval <= 0 {
+		opts.MaxInterval = 30 * time.Second
+	}
+	if opts.Multiplier <= 0 {
+		opts.Multiplier = 2
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var zero T
+	var lastErr error
+	delay := opts.Interval
+	for attempt := 1; ; attempt++ {
+		value, err := p.Poll(ctx)
+		switch {
+		case err == nil:
+			lastErr = nil
+			if opts.Progress != nil {
+				opts.Progress(value, attempt)
+			}
+			done, err := p.Done(value)
+			if err != nil {
+				return value, err
+			}
+			if done {
+				return value, nil
+			}
+		case ctx.Err() != nil:
+			// Handled below, so the error names the timeout
+		case p.retryable(ctx, err):
+			lastErr = err
+			if p.Logger != nil {
+				p.Logger.WarnContext(ctx, "poll failed, retrying", "attempt", attempt, "error", err)
+			}
+		default:
+			return zero, err
+		}
+
+		select {
+		case <-time.After(jitter(delay, opts.Jitter)):
+		case <-ctx.Done():
+			if opts.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				if lastErr != nil {
+					return zero, fmt.Errorf("%w after %s: %w", ErrPollTimeout, opts.Timeout, lastErr)
+				}
+				return zero, fmt.Errorf("%w after %s", ErrPollTimeout, opts.Timeout)
+			}
+			return zero, errors.Join(ctx.Err(), lastErr)
+		}
+		delay = min(time.Duration(float64(delay)*opts.Multiplier), opts.MaxInterval)
+	}
+}
+
+func (p *Poller[T]) retryable(ctx context.Context, err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return isRetryable(ctx, err)
+}
+
+// jitter spreads d randomly by up to ±fraction of its length
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + fraction*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SaveAgentPreset writes cfg to path as YAML, or as JSON when path ends in .json
+func SaveAgentPreset(path string, cfg AgentConfig) error {
+	var data []byte
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err = json.MarshalIndent(cfg, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = marshalYAML(cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode agent preset: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create preset directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write agent preset: %w", err)
+	}
+	return nil
+}
+
+// LoadAgentPreset reads an agent config saved by SaveAgentPreset, or written
+// by hand, and validates it
+func LoadAgentPreset(path string) (AgentConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AgentConfig{}, fmt.Errorf("failed to read agent preset: %w", err)
+	}
+
+	var cfg AgentConfig
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &cfg)
+	} else {
+		err = unmarshalYAML(data, &cfg)
+	}
+	if err != nil {
+		return AgentConfig{}, fmt.Errorf("failed to decode agent preset %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return AgentConfig{}, fmt.Errorf("invalid agent preset %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// PromptExample is a few-shot example: a user message and the answer the
+// model should give to it. Both are templates like the rest of the prompt.
+type PromptExample struct {
+	Input  string
+	Output string
+}
+
+// PromptTemplate is a named chat prompt. System, the examples and User are
+// text/template sources rendered with the caller's variables, e.g.
+// "Summarize:\n\n{{.doc}}". A variable the template uses but the caller did
+// not set is an error rather than "<no value>".
+type PromptTemplate struct {
+	Name     string
+	System   string
+	Examples []PromptExample // sent as user/assistant pairs before User
+	User     string
+
+	// Defaults are the values of variables the caller may leave out
+	Defaults map[string]interface{}
+}
+
+// PromptLibrary holds prompt templates and partials, templates that other
+// templates include with {{template "name" .}}. Templates loaded from a
+// directory replace built-in ones of the same name.
+type PromptLibrary struct {
+	prompts map[string]PromptTemplate
+	set     *template.Template
+}
+
+// builtinPrompts are always in a PromptLibrary
+var builtinPrompts = []PromptTemplate{
+	{
+		Name:     "summarize",
+		System:   "You write faithful, concise summaries. Never add facts that are not in the text.",
+		User:     "Summarize the following text in {{.length}}.\n\n{{.doc}}",
+		Defaults: map[string]interface{}{"length": "a few sentences"},
+	},
+	{
+		Name:   "translate",
+		System: "You are a translator. Reply with the translation only.",
+		User:   "Translate into {{.language}}:\n\n{{.text}}",
+	},
+	{
+		Name:   "sentiment",
+		System: "Classify the sentiment of the text as positive, negative or neutral. Reply with the label only.",
+		Examples: []PromptExample{
+			{Input: "The update fixed every crash I had, thank you!", Output: "positive"},
+			{Input: "Support never answered and the app still does not start.", Output: "negative"},
+			{Input: "The package arrived on Tuesday.", Output: "neutral"},
+		},
+		User: "{{.text}}",
+	},
+}
+
+// promptSectionPattern matches the role markers of a template file
+var promptSectionPattern = regexp.MustCompile(`(?m)^---[ \t]*(system|user|assistant)[ \t]*---[ \t]*\r?\n?`)
+
+// NewPromptLibrary returns a library with the built-in templates:
+// "summarize" (doc, optional length), "translate" (text, language) and
+// "sentiment" (text, with few-shot examples)
+func NewPromptLibrary() *PromptLibrary {
+	l := &PromptLibrary{prompts: make(map[string]PromptTemplate), set: template.New("")}
+	for _, p := range builtinPrompts {
+		if err := l.Add(p); err != nil {
+			panic(err)
+		}
+	}
+	return l
+}
+
+// LoadPromptLibrary returns the built-in templates plus those in dir. Each
+// NAME.tmpl file is a template called NAME. Files starting with "_" are
+// partials: _NAME.tmpl is included as {{template "NAME" .}} but is not a
+// prompt of its own. A file without role markers is the user message;
+// otherwise "--- system ---", "--- user ---" and "--- assistant ---" lines
+// start messages, and user/assistant pairs before the last user message are
+// the few-shot examples:
+//
+//	--- system ---
+//	Answer in the style of a {{.persona}}.
+//	--- user ---
+//	What is Go?
+//	--- assistant ---
+//	{{template "go-answer"}}
+//	--- user ---
+//	{{.question}}
+func LoadPromptLibrary(dir string) (*PromptLibrary, error) {
+	l := NewPromptLibrary()
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		if partial, ok := strings.CutPrefix(name, "_"); ok {
+			err = l.AddPartial(partial, string(data))
+		} else {
+			var p PromptTemplate
+			if p, err = ParsePromptTemplate(name, string(data)); err == nil {
+				err = l.Add(p)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return l, nil
+}
+
+// ParsePromptTemplate parses the file format described at LoadPromptLibrary
+func ParsePromptTemplate(name, text string) (PromptTemplate, error) {
+	p := PromptTemplate{Name: name}
+	markers := promptSectionPattern.FindAllStringSubmatchIndex(text, -1)
+	if len(markers) == 0 {
+		p.User = strings.TrimSpace(text)
+		return p, nil
+	}
+	if strings.TrimSpace(text[:markers[0][0]]) != "" {
+		return p, fmt.Errorf("template %s has text before its first role marker", name)
+	}
+
+	type section struct{ role, text string }
+	var sections []section
+	for i, m := range markers {
+		end := len(text)
+		if i+1 < len(markers) {
+			end = markers[i+1][0]
+		}
+		sections = append(sections, section{role: text[m[2]:m[3]], text: strings.TrimSpace(text[m[1]:end])})
+	}
+	if sections[0].role == "system" {
+		p.System = sections[0].text
+		sections = sections[1:]
+	}
+	if len(sections) == 0 || sections[len(sections)-1].role != "user" {
+		return p, fmt.Errorf("template %s must end with a user message", name)
+	}
+	p.User = sections[len(sections)-1].text
+	examples := sections[:len(sections)-1]
+	for i := 0; i < len(examples); i += 2 {
+		if examples[i].role != "user" || i+1 == len(examples) || examples[i+1].role != "assistant" {
+			return p, fmt.Errorf("template %s: examples must be user messages each followed by an assistant message", name)
+		}
+		p.Examples = append(p.Examples, PromptExample{Input: examples[i].text, Output: examples[i+1].text})
+	}
+	return p, nil
+}
+
+// Add adds p, or replaces a template of the same name. Every part of p is
+// parsed now, so syntax errors surface here rather than when rendering.
+func (l *PromptLibrary) Add(p PromptTemplate) error {
+	if p.Name == "" {
+		return errors.New("prompt template without a name")
+	}
+	if p.User == "" {
+		return fmt.Errorf("template %s has no user message", p.Name)
+	}
+	for part, text := range p.parts() {
+		if _, err := l.set.New(part).Parse(text); err != nil {
+			return fmt.Errorf("invalid template %s: %w", p.Name, err)
+		}
+	}
+	l.prompts[p.Name] = p
+	return nil
+}
+
+// AddPartial adds a template other templates can include with
+// {{template "name" .}}
+func (l *PromptLibrary) AddPartial(name, text string) error {
+	if _, err := l.set.New(name).Parse(text); err != nil {
+		return fmt.Errorf("invalid partial %s: %w", name, err)
+	}
+	return nil
+}
+
+// parts returns the template sources of p by the name they are parsed under
+func (p PromptTemplate) parts() map[string]string {
+	parts := map[string]string{p.Name + "/user": p.User}
+	if p.System != "" {
+		parts[p.Name+"/system"] = p.System
+	}
+	for i, ex := range p.Examples {
+		parts[fmt.Sprintf("%s/example-%d/input", p.Name, i)] = ex.Input
+		parts[fmt.Sprintf("%s/example-%d/output", p.Name, i)] = ex.Output
+	}
+	return parts
+}
+
+// Names returns the names of the prompt templates, sorted
+func (l *PromptLibrary) Names() []string {
+	names := make([]string, 0, len(l.prompts))
+	for name := range l.prompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the template called name
+func (l *PromptLibrary) Get(name string) (PromptTemplate, bool) {
+	p, ok := l.prompts[name]
+	return p, ok
+}
+
+// Render renders the template called name with vars into the messages of a
+// chat completion: the system message, the few-shot examples and the user
+// message
+func (l *PromptLibrary) Render(name string, vars map[string]interface{}) ([]Message, error) {
+	p, ok := l.prompts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt template %q (have %s)", name, strings.Join(l.Names(), ", "))
+	}
+	data := make(map[string]interface{}, len(p.Defaults)+len(vars))
+	for k, v := range p.Defaults {
+		data[k] = v
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+	render := func(part string) (string, error) {
+		var b strings.Builder
+		if err := l.set.Lookup(part).Option("missingkey=error").Execute(&b, data); err != nil {
+			return "", fmt.Errorf("failed to render template %s: %w", name, err)
+		}
+		return strings.TrimSpace(b.String()), nil
+	}
+
+	var messages []Message
+	if p.System != "" {
+		content, err := render(p.Name + "/system")
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, Message{Role: "system", Content: content})
+	}
+	for i := range p.Examples {
+		input, err := render(fmt.Sprintf("%s/example-%d/input", p.Name, i))
+		if err != nil {
+			return nil, err
+		}
+		output, err := render(fmt.Sprintf("%s/example-%d/output", p.Name, i))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, Message{Role: "user", Content: input}, Message{Role: "assistant", Content: output})
+	}
+	content, err := render(p.Name + "/user")
+	if err != nil {
+		return nil, err
+	}
+	return append(messages, Message{Role: "user", Content: content}), nil
+}
+
+// PromptVars collects "key=value" variables from repeated command line
+// flags; "key=@path" reads the value from a file and "key=@-" from stdin
+type PromptVars map[string]interface{}
+
+func (v PromptVars) String() string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// Set parses one variable
+func (v PromptVars) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("variable %q is not key=value", s)
+	}
+	if path, ok := strings.CutPrefix(value, "@"); ok {
+		var data []byte
+		var err error
+		if path == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read variable %s: %w", key, err)
+		}
+		value = string(data)
+	}
+	v[key] = value
+	return nil
+}
+
+// runPromptCommand handles "prompt": it renders a template from the library
+// with -var variables and streams the model's reply, or prints the messages
+// with -render
+func runPromptCommand(client *LlamaStackClient, args []string, raw bool) error {
+	fs := flag.NewFlagSet("prompt", flag.ContinueOnError)
+	name := fs.String("template", "", "prompt template to render")
+	dir := fs.String("templates", os.Getenv("LLAMASTACK_TEMPLATES"), "directory of NAME.tmpl templates and _NAME.tmpl partials")
+	model := fs.String("model", "", "model to send the prompt to; the first LLM by default")
+	renderOnly := fs.Bool("render", false, "print the rendered messages instead of sending them")
+	list := fs.Bool("list", false, "list the templates")
+	vars := PromptVars{}
+	fs.Var(vars, "var", "template variable as key=value, key=@file or key=@- for stdin; repeatable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || (*name == "") == !*list {
+		return errors.New("usage: prompt (-list | -template NAME [-var key=value]... [-render]) [-templates DIR] [-model MODEL]")
+	}
+
+	library := NewPromptLibrary()
+	if *dir != "" {
+		var err error
+		if library, err = LoadPromptLibrary(*dir); err != nil {
+			return err
+		}
+	}
+	if *list {
+		for _, n := range library.Names() {
+			p, _ := library.Get(n)
+			fmt.Printf("%s (%d examples)\n", n, len(p.Examples))
+		}
+		return nil
+	}
+
+	messages, err := library.Render(*name, vars)
+	if err != nil {
+		return err
+	}
+	if *renderOnly {
+		for _, msg := range messages {
+			fmt.Printf("--- %s ---\n%s\n", msg.Role, msg.Content)
+		}
+		return nil
+	}
+	ctx := context.Background()
+	if *model == "" {
+		if *model, err = client.GetAvailableModel(ctx); err != nil {
+			return fmt.Errorf("no model to send the prompt to: %w", err)
+		}
+	}
+	_, err = streamReplReply(ctx, client, *model, messages, raw)
+	return err
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Prompt is one version of a prompt stored by the server's Prompts API.
+// Variables in the text are written {{ name }} and must be declared in
+// Variables.
+type Prompt struct {
+	PromptID  string   `json:"prompt_id"`
+	Prompt    string   `json:"prompt"`
+	Version   int      `json:"version"`
+	Variables []string `json:"variables,omitempty"`
+	IsDefault bool     `json:"is_default"`
+}
+
+// ListPromptsResponse is the response of ListPrompts and ListPromptVersions
+type ListPromptsResponse = Page[Prompt]
+
+// CreatePromptParams creates a prompt; Variables defaults to the variables
+// found in Prompt
+type CreatePromptParams struct {
+	Prompt    string   `json:"prompt"`
+	Variables []string `json:"variables,omitempty"`
+}
+
+// UpdatePromptParams adds a version to a prompt. Version is the latest
+// version, which the update is based on; the server rejects updates of
+// older versions.
+type UpdatePromptParams struct {
+	Prompt       string   `json:"prompt"`
+	Version      int      `json:"version"`
+	Variables    []string `json:"variables,omitempty"`
+	SetAsDefault bool     `json:"set_as_default"`
+}
+
+// promptVariablePattern matches a {{ name }} variable of a stored prompt
+var promptVariablePattern = regexp.MustCompile(`{{\s*([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// PromptVariables returns the variables used in text, sorted
+func PromptVariables(text string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range promptVariablePattern.FindAllStringSubmatch(text, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render returns the prompt text with its variables replaced by vars. Every
+// declared variable must be given.
+func (p *Prompt) Render(vars map[string]string) (string, error) {
+	var missing []string
+	for _, name := range p.Variables {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("prompt %s version %d needs variables %s", p.PromptID, p.Version, strings.Join(missing, ", "))
+	}
+	return promptVariablePattern.ReplaceAllStringFunc(p.Prompt, func(match string) string {
+		name := promptVariablePattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	}), nil
+}
+
+// CreatePrompt stores a new prompt as version 1
+func (c *LlamaStackClient) CreatePrompt(ctx context.Context, params CreatePromptParams) (*Prompt, error) {
+	if params.Variables == nil {
+		params.Variables = PromptVariables(params.Prompt)
+	}
+	var prompt Prompt
+	if err := c.postJSON(ctx, "/v1/prompts", params, &prompt); err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// ListPrompts lists the default version of every prompt
+func (c *LlamaStackClient) ListPrompts(ctx context.Context) (*ListPromptsResponse, error) {
+	var response ListPromptsResponse
+	if err := c.getJSON(ctx, "/v1/prompts", &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetPrompt returns the default version of a prompt
+func (c *LlamaStackClient) GetPrompt(ctx context.Context, promptID string) (*Prompt, error) {
+	var prompt Prompt
+	if err := c.getJSON(ctx, "/v1/prompts/"+url.PathEscape(promptID), &prompt); err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// GetPromptVersion returns one version of a prompt
+func (c *LlamaStackClient) GetPromptVersion(ctx context.Context, promptID string, version int) (*Prompt, error) {
+	var prompt Prompt
+	endpoint := "/v1/prompts/" + url.PathEscape(promptID) + "?version=" + strconv.Itoa(version)
+	if err := c.getJSON(ctx, endpoint, &prompt); err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// ListPromptVersions lists every version of a prompt
+func (c *LlamaStackClient) ListPromptVersions(ctx context.Context, promptID string) (*ListPromptsResponse, error) {
+	var response ListPromptsResponse
+	if err := c.getJSON(ctx, "/v1/prompts/"+url.PathEscape(promptID)+"/versions", &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpdatePrompt stores a new version of a prompt and returns it
+func (c *LlamaStackClient) UpdatePrompt(ctx context.Context, promptID string, params UpdatePromptParams) (*Prompt, error) {
+	if params.Variables == nil {
+		params.Variables = PromptVariables(params.Prompt)
+	}
+	var prompt Prompt
+	if err := c.postJSON(ctx, "/v1/prompts/"+url.PathEscape(promptID), params, &prompt); err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// SetDefaultVersion makes version the one GetPrompt, ListPrompts and
+// references without a version use
+func (c *LlamaStackClient) SetDefaultVersion(ctx context.Context, promptID string, version int) (*Prompt, error) {
+	var prompt Prompt
+	body := map[string]int{"version": version}
+	if err := c.postJSON(ctx, "/v1/prompts/"+url.PathEscape(promptID)+"/set-default-version", body, &prompt); err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// DeletePrompt deletes a prompt with all its versions
+func (c *LlamaStackClient) DeletePrompt(ctx context.Context, promptID string) error {
+	return c.sendDelete(ctx, "/v1/prompts/"+url.PathEscape(promptID))
+}
+
+// PromptRef refers to a stored prompt from the chat and agent helpers
+type PromptRef struct {
+	ID        string
+	Version   int // the default version when zero
+	Variables map[string]string
+}
+
+// RenderPrompt fetches the prompt ref refers to and renders it with the
+// ref's variables
+func (c *LlamaStackClient) RenderPrompt(ctx context.Context, ref PromptRef) (string, error) {
+	var prompt *Prompt
+	var err error
+	if ref.Version > 0 {
+		prompt, err = c.GetPromptVersion(ctx, ref.ID, ref.Version)
+	} else {
+		prompt, err = c.GetPrompt(ctx, ref.ID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get prompt %s: %w", ref.ID, err)
+	}
+	return prompt.Render(ref.Variables)
+}
+
+// CreateChatCompletionWithPrompt sends params with the stored prompt ref
+// refers to as the system message, before params.Messages
+func (c *LlamaStackClient) CreateChatCompletionWithPrompt(ctx context.Context, ref PromptRef, params ChatCompletionParams) (*APIResponse, error) {
+	system, err := c.RenderPrompt(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	params.Messages = append([]Message{{Role: "system", Content: system}}, params.Messages...)
+	return c.CreateChatCompletion(ctx, params)
+}
+
+// runPromptsCommand handles "prompts": managing stored prompts and chatting
+// with one as the system message
+func runPromptsCommand(client *LlamaStackClient, args []string, raw bool) error {
+	usage := errors.New("usage: prompts list | create TEXT | get ID [VERSION] | versions ID | update ID VERSION TEXT [-default] | set-default ID VERSION | delete ID | chat ID [-version N] [-var key=value]... MESSAGE")
+	if len(args) == 0 {
+		return usage
+	}
+	ctx := context.Background()
+	printPrompt := func(p *Prompt) {
+		def := ""
+		if p.IsDefault {
+			def = ", default"
+		}
+		fmt.Printf("%s version %d%s, variables [%s]\n  %s\n", p.PromptID, p.Version, def, strings.Join(p.Variables, ", "), p.Prompt)
+	}
+	atoi := func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid version %q", s)
+		}
+		return n, nil
+	}
+
+	switch cmd, rest := args[0], args[1:]; {
+	case cmd == "list" && len(rest) == 0, cmd == "versions" && len(rest) == 1:
+		var resp *ListPromptsResponse
+		var err error
+		if cmd == "list" {
+			resp, err = client.ListPrompts(ctx)
+		} else {
+			resp, err = client.ListPromptVersions(ctx, rest[0])
+		}
+		if err != nil {
+			return err
+		}
+		if len(resp.Data) == 0 {
+			fmt.Println("No prompts")
+		}
+		for i := range resp.Data {
+			printPrompt(&resp.Data[i])
+		}
+	case cmd == "create" && len(rest) == 1:
+		p, err := client.CreatePrompt(ctx, CreatePromptParams{Prompt: rest[0]})
+		if err != nil {
+			return err
+		}
+		printPrompt(p)
+	case cmd == "get" && (len(rest) == 1 || len(rest) == 2):
+		var p *Prompt
+		var err error
+		if len(rest) == 1 {
+			p, err = client.GetPrompt(ctx, rest[0])
+		} else {
+			var version int
+			if version, err = atoi(rest[1]); err != nil {
+				return err
+			}
+			p, err = client.GetPromptVersion(ctx, rest[0], version)
+		}
+		if err != nil {
+			return err
+		}
+		printPrompt(p)
+	case cmd == "update":
+		fs := flag.NewFlagSet("prompts update", flag.ContinueOnError)
+		setDefault := fs.Bool("default", false, "make the new version the default")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if fs.NArg() != 3 {
+			return usage
+		}
+		version, err := atoi(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		p, err := client.UpdatePrompt(ctx, fs.Arg(0), UpdatePromptParams{Prompt: fs.Arg(2), Version: version, SetAsDefault: *setDefault})
+		if err != nil {
+			return err
+		}
+		printPrompt(p)
+	case cmd == "set-default" && len(rest) == 2:
+		version, err := atoi(rest[1])
+		if err != nil {
+			return err
+		}
+		p, err := client.SetDefaultVersion(ctx, rest[0], version)
+		if err != nil {
+			return err
+		}
+		printPrompt(p)
+	case cmd == "delete" && len(rest) == 1:
+		if err := client.DeletePrompt(ctx, rest[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted prompt %s\n", rest[0])
+	case cmd == "chat" && len(rest) > 0:
+		fs := flag.NewFlagSet("prompts chat", flag.ContinueOnError)
+		version := fs.Int("version", 0, "prompt version; the default version when 0")
+		model := fs.String("model", "", "model to chat with; the first LLM by default")
+		vars := PromptVars{}
+		fs.Var(vars, "var", "prompt variable as key=value, key=@file or key=@- for stdin; repeatable")
+		// The prompt ID comes first, flags after it
+		if err := fs.Parse(rest[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return usage
+		}
+		ref := PromptRef{ID: rest[0], Version: *version, Variables: make(map[string]string, len(vars))}
+		for k, v := range vars {
+			ref.Variables[k] = fmt.Sprint(v)
+		}
+		system, err := client.RenderPrompt(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if *model == "" {
+			if *model, err = client.GetAvailableModel(ctx); err != nil {
+				return fmt.Errorf("no model to chat with: %w", err)
+			}
+		}
+		messages := []Message{{Role: "system", Content: system}, {Role: "user", Content: fs.Arg(0)}}
+		_, err = streamReplReply(ctx, client, *model, messages, raw)
+		return err
+	default:
+		return usage
+	}
+	return nil
+}
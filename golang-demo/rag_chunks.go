@@ -0,0 +1,118 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// RAGChunk is one chunk returned by a rag-tool query or a knowledge_search
+// tool call
+type RAGChunk struct {
+	DocumentID string
+	Content    string
+	Score      float64                // zero when the server sent no scores
+	Metadata   map[string]interface{} // chunk metadata rendered into the result text, when present
+}
+
+// knowledgeSearchResultPattern starts each chunk of a knowledge_search result:
+// "Result 1" in the default chunk template, "Result 1:" in older servers
+var knowledgeSearchResultPattern = regexp.MustCompile(`(?m)^Result \d+:?$`)
+
+// ParseQueryResult decodes every chunk of a rag-tool query result. It uses
+// the document_ids/chunks/scores metadata the server attaches and falls back
+// to parsing the chunk text items, which also carry each chunk's metadata.
+func ParseQueryResult(result *QueryResult) []RAGChunk {
+	if result == nil {
+		return nil
+	}
+	return parseRAGContent(result.Content, result.Metadata)
+}
+
+// ParseKnowledgeSearchResponse decodes the chunks of a knowledge_search tool
+// response, as found in the tool_responses of a turn's tool_execution step
+func ParseKnowledgeSearchResponse(resp map[string]interface{}) []RAGChunk {
+	metadata, _ := resp["metadata"].(map[string]interface{})
+	return parseRAGContent(resp["content"], metadata)
+}
+
+// ParseKnowledgeSearchText decodes the chunks of a knowledge_search result
+// flattened to text, such as the output of a client-side knowledge_search
+// handler
+func ParseKnowledgeSearchText(text string) []RAGChunk {
+	return parseRAGContent(text, nil)
+}
+
+// parseRAGContent decodes chunks from string or content-item content and the
+// metadata lists sent with it
+func parseRAGContent(content interface{}, metadata map[string]interface{}) []RAGChunk {
+	var parsed []RAGChunk
+	for _, text := range contentTexts(content) {
+		parsed = append(parsed, parseChunkTexts(text)...)
+	}
+
+	ids := stringList(metadata["document_ids"])
+	chunks := stringList(metadata["chunks"])
+	scores := floatList(metadata["scores"])
+	if len(ids) == 0 {
+		return parsed
+	}
+
+	result := make([]RAGChunk, len(ids))
+	for i, id := range ids {
+		result[i].DocumentID = id
+		if i < len(chunks) {
+			result[i].Content = chunks[i]
+		}
+		if i < len(scores) {
+			result[i].Score = scores[i]
+		}
+		if i < len(parsed) && parsed[i].DocumentID == id {
+			result[i].Metadata = parsed[i].Metadata
+			if result[i].Content == "" {
+				result[i].Content = parsed[i].Content
+			}
+		}
+	}
+	return result
+}
+
+// parseChunkTexts parses the chunks rendered into one text, each starting
+// with a "Result n" line followed by its content and, in the default chunk
+// template, a "Metadata: {...}" line. Header and footer text is skipped.
+func parseChunkTexts(text string) []RAGChunk {
+	starts := knowledgeSearchResultPattern.FindAllStringIndex(text, -1)
+	chunks := make([]RAGChunk, 0, len(starts))
+	for i, start := range starts {
+		end := len(text)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		chunks = append(chunks, parseChunkText(strings.TrimPrefix(text[start[1]:end], "\n")))
+	}
+	return chunks
+}
+
+// parseChunkText parses the body of one rendered chunk
+func parseChunkText(body string) RAGChunk {
+	var chunk RAGChunk
+	if id, rest, ok := strings.Cut(body, "\n"); ok && strings.HasPrefix(id, "Document_id:") {
+		chunk.DocumentID = strings.TrimSpace(strings.TrimPrefix(id, "Document_id:"))
+		body = rest
+	}
+	body = strings.TrimPrefix(body, "Content: ")
+	if content, meta, ok := strings.Cut(body, "\nMetadata: "); ok {
+		meta, _, _ = strings.Cut(meta, "\n")
+		chunk.Content = content
+		chunk.Metadata = parsePythonDict(meta)
+		if id, _ := chunk.Metadata["document_id"].(string); id != "" {
+			chunk.DocumentID = id
+		}
+		return chunk
+	}
+	// Without a metadata line the chunk runs to the footer, if any
+	if i := strings.Index(body, "\nEND of knowledge_search"); i >= 0 {
+		body = body[:i]
+	}
+	chunk.Content = strings.TrimRight(body, "\n")
+	return chunk
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// DetachFileFromVectorStore removes a file and its chunks from a vector store;
+// the uploaded file itself is kept
+func (c *LlamaStackClient) DetachFileFromVectorStore(ctx context.Context, vectorStoreID, fileID string) error {
+	return c.sendDelete(ctx, fmt.Sprintf("/v1/openai/v1/vector_stores/%s/files/%s", url.PathEscape(vectorStoreID), url.PathEscape(fileID)))
+}
+
+// DeleteFile deletes an uploaded file
+func (c *LlamaStackClient) DeleteFile(ctx context.Context, fileID string) error {
+	return c.sendDelete(ctx, "/v1/openai/v1/files/"+url.PathEscape(fileID))
+}
+
+// sendDelete sends a DELETE request and checks the status
+func (c *LlamaStackClient) sendDelete(ctx context.Context, path string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.BaseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp, body)
+	}
+	return nil
+}
+
+// DeleteDocumentsOptions selects the documents DeleteDocuments removes. At
+// least one of DocumentIDs and Filter must be set; when both are, a document
+// must match both.
+type DeleteDocumentsOptions struct {
+	DocumentIDs []string
+	// Filter is matched against each file's attributes (the document metadata
+	// given to InsertDocumentsIntoRAG) plus document_id and filename
+	Filter *Filter
+	// KeepFiles only detaches the files; by default the uploaded files are
+	// deleted as well
+	KeepFiles bool
+	// DryRun returns what would be removed without removing anything
+	DryRun bool
+}
+
+// DeleteDocuments removes documents from a vector store by document_id or
+// metadata. The server stores each document inserted with
+// InsertDocumentsIntoRAG as a vector store file named after its document_id,
+// with the document metadata as attributes, so documents are removed by
+// detaching those files; files attached directly are matched the same way.
+// It returns the removed files.
+func (c *LlamaStackClient) DeleteDocuments(ctx context.Context, vectorStoreID string, opts DeleteDocumentsOptions) ([]VectorStoreFile, error) {
+	ctx = ensureCorrelationID(ctx)
+	if len(opts.DocumentIDs) == 0 && opts.Filter == nil {
+		return nil, errors.New("no documents selected: set DocumentIDs or Filter")
+	}
+	if opts.Filter != nil {
+		if err := opts.Filter.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+
+	files, err := c.ListVectorStoreFiles(ctx, vectorStoreID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vector store files: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(opts.DocumentIDs))
+	for _, id := range opts.DocumentIDs {
+		wanted[id] = true
+	}
+
+	var removed []VectorStoreFile
+	var errs []error
+	for _, f := range files {
+		attrs := make(map[string]interface{}, len(f.Attributes)+2)
+		for k, v := range f.Attributes {
+			attrs[k] = v
+		}
+		if info, err := c.GetFile(ctx, f.ID); err == nil {
+			attrs["filename"] = info.Filename
+			if _, ok := attrs["document_id"]; !ok {
+				attrs["document_id"] = info.Filename
+			}
+		}
+		documentID, _ := attrs["document_id"].(string)
+
+		if len(wanted) > 0 && !wanted[documentID] {
+			continue
+		}
+		if opts.Filter != nil && !opts.Filter.Match(attrs) {
+			continue
+		}
+
+		if !opts.DryRun {
+			if err := c.DetachFileFromVectorStore(ctx, vectorStoreID, f.ID); err != nil {
+				errs = append(errs, fmt.Errorf("failed to detach %s (%s): %w", documentID, f.ID, err))
+				continue
+			}
+			if !opts.KeepFiles {
+				if err := c.DeleteFile(ctx, f.ID); err != nil {
+					errs = append(errs, fmt.Errorf("detached %s but failed to delete file %s: %w", documentID, f.ID, err))
+				}
+			}
+		}
+		removed = append(removed, f)
+	}
+	return removed, errors.Join(errs...)
+}
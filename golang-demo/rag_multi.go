@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MergeOptions configures QueryRAGMulti
+type MergeOptions struct {
+	QueryConfig *RAGQueryConfig // sent with every per-store query
+	MaxChunks   int             // chunks kept after merging; zero keeps all
+	Concurrency int             // stores queried at once; zero queries all together
+
+	// NormalizeScores rescales each store's scores to 0..1 before merging, for
+	// stores whose providers score on different scales
+	NormalizeScores bool
+	// KeepDuplicates keeps a chunk found in more than one store once per store;
+	// by default only the highest-scoring copy is kept
+	KeepDuplicates bool
+}
+
+// MultiQueryResult is the merged outcome of QueryRAGMulti
+type MultiQueryResult struct {
+	Chunks []Citation       // best first, numbered from 1
+	Errors map[string]error // stores whose query failed, by vector DB ID
+}
+
+// QueryRAGMulti queries each vector DB on its own, concurrently, and merges the
+// chunks by score. A failing store does not fail the query: its error is
+// reported in Errors and the other stores' chunks are still returned. An
+// error is only returned when every store fails. A Rewrite in
+// opts.QueryConfig is applied once and every store is searched with each
+// rewritten query.
+func (c *LlamaStackClient) QueryRAGMulti(ctx context.Context, query string, storeIDs []string, opts MergeOptions) (*MultiQueryResult, error) {
+	ctx = ensureCorrelationID(ctx)
+	if len(storeIDs) == 0 {
+		return nil, errors.New("no vector DBs to query")
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 || concurrency > len(storeIDs) {
+		concurrency = len(storeIDs)
+	}
+
+	// Rewrite once for all stores rather than once per store
+	queries, cfg, err := c.rewriteRAGQuery(ctx, query, opts.QueryConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	perStore := make([][]Citation, len(storeIDs))
+	errs := make([]error, len(storeIDs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, id := range storeIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			for _, q := range queries {
+				result, err := c.QueryRAG(ctx, RagToolQueryParams{
+					Content:     q,
+					VectorDBIDs: []string{id},
+					QueryConfig: cfg,
+				})
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				perStore[i] = append(perStore[i], storeCitations(id, result, opts.NormalizeScores)...)
+			}
+		}()
+	}
+	wg.Wait()
+
+	merged := &MultiQueryResult{}
+	for i, id := range storeIDs {
+		if errs[i] != nil {
+			if merged.Errors == nil {
+				merged.Errors = make(map[string]error)
+			}
+			merged.Errors[id] = errs[i]
+		}
+	}
+	if len(merged.Errors) == len(storeIDs) {
+		joined := make([]error, len(storeIDs))
+		for i, id := range storeIDs {
+			joined[i] = fmt.Errorf("%s: %w", id, errs[i])
+		}
+		return merged, fmt.Errorf("every vector DB query failed: %w", errors.Join(joined...))
+	}
+
+	merged.Chunks = mergeCitations(perStore, opts)
+	return merged, nil
+}
+
+// storeCitations extracts one store's chunks, filling in rank-based scores
+// when the server sent none
+func storeCitations(storeID string, result *QueryResult, normalize bool) []Citation {
+	citations := CitationsFromQueryResult(result)
+	scored := false
+	for _, c := range citations {
+		if c.Score != 0 {
+			scored = true
+			break
+		}
+	}
+	lo, hi := 0.0, 0.0
+	for i := range citations {
+		citations[i].VectorDBID = storeID
+		if !scored {
+			citations[i].Score = 1 / float64(i+1)
+		}
+		if i == 0 || citations[i].Score < lo {
+			lo = citations[i].Score
+		}
+		if i == 0 || citations[i].Score > hi {
+			hi = citations[i].Score
+		}
+	}
+	if normalize {
+		for i := range citations {
+			if hi > lo {
+				citations[i].Score = (citations[i].Score - lo) / (hi - lo)
+			} else {
+				citations[i].Score = 1
+			}
+		}
+	}
+	return citations
+}
+
+// mergeCitations combines per-store chunks best first, dropping duplicates
+// unless asked to keep them
+func mergeCitations(perStore [][]Citation, opts MergeOptions) []Citation {
+	var all []Citation
+	for _, citations := range perStore {
+		all = append(all, citations...)
+	}
+	// Stable, so equal scores keep the order of storeIDs
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Score > all[j].Score })
+
+	var merged []Citation
+	seen := make(map[string]bool)
+	for _, c := range all {
+		if !opts.KeepDuplicates {
+			key := strings.Join(strings.Fields(c.Content), " ")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		if opts.MaxChunks > 0 && len(merged) == opts.MaxChunks {
+			break
+		}
+		c.Index = len(merged) + 1
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// QueryResult renders the merged chunks the way the server renders a single
+// query, so they can be sent back as a knowledge_search tool response
+func (r *MultiQueryResult) QueryResult() *QueryResult {
+	result := &QueryResult{Metadata: map[string]interface{}{}}
+	if len(r.Chunks) == 0 {
+		return result
+	}
+
+	documentIDs := make([]string, len(r.Chunks))
+	chunks := make([]string, len(r.Chunks))
+	scores := make([]float64, len(r.Chunks))
+	result.Content = append(result.Content, map[string]interface{}{
+		"type": "text",
+		"text": fmt.Sprintf("knowledge_search tool found %d chunks:\nBEGIN of knowledge_search tool results.\n", len(r.Chunks)),
+	})
+	for i, c := range r.Chunks {
+		result.Content = append(result.Content, map[string]interface{}{
+			"type": "text",
+			"text": fmt.Sprintf("Result %d\nContent: %s\nMetadata: %s\n", i+1, c.Content, formatChunkMetadata(c)),
+		})
+		documentIDs[i], chunks[i], scores[i] = c.DocumentID, c.Content, c.Score
+	}
+	result.Content = append(result.Content, map[string]interface{}{"type": "text", "text": "END of knowledge_search tool results.\n"})
+	result.Metadata["document_ids"] = documentIDs
+	result.Metadata["chunks"] = chunks
+	result.Metadata["scores"] = scores
+	return result
+}
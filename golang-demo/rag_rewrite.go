@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query rewrite modes
+const (
+	// RewriteMultiQuery asks the model for alternative phrasings of the query
+	RewriteMultiQuery = "multi_query"
+	// RewriteHyDE asks the model for hypothetical answer passages and searches
+	// with those, which often sit closer to the stored chunks than the question
+	RewriteHyDE = "hyde"
+)
+
+// QueryRewrite configures query rewriting for RAGQueryConfig.Rewrite
+type QueryRewrite struct {
+	Mode         string // RewriteMultiQuery or RewriteHyDE
+	Model        string // chat model that writes the queries
+	Count        int    // queries or passages to generate; defaults to 3 for multi-query and 1 for HyDE
+	KeepOriginal bool   // also search with the original query
+
+	// Prompt replaces the default instructions; {query} and {count} are
+	// substituted. Multi-query prompts must ask for one query per line.
+	Prompt string
+}
+
+const multiQueryPrompt = `Write {count} different search queries that would retrieve documents answering the question below. Vary the wording and focus of each one. Reply with one query per line and nothing else.
+
+Question: {query}`
+
+const hydePrompt = `Write a short passage of 3 to 5 sentences that answers the question below, as if it were quoted from a document about the topic. Reply with the passage only.
+
+Question: {query}`
+
+// rewriteQuery returns the queries to search with instead of query
+func (c *LlamaStackClient) rewriteQuery(ctx context.Context, query string, rw *QueryRewrite) ([]string, error) {
+	if rw.Model == "" {
+		return nil, errors.New("query rewrite needs a model")
+	}
+
+	count := rw.Count
+	prompt := rw.Prompt
+	var queries []string
+	switch rw.Mode {
+	case RewriteMultiQuery:
+		if count <= 0 {
+			count = 3
+		}
+		if prompt == "" {
+			prompt = multiQueryPrompt
+		}
+		reply, err := c.rewriteCompletion(ctx, rw.Model, expandRewritePrompt(prompt, query, count), nil)
+		if err != nil {
+			return nil, err
+		}
+		queries = parseQueryLines(reply, count)
+
+	case RewriteHyDE:
+		if count <= 0 {
+			count = 1
+		}
+		if prompt == "" {
+			prompt = hydePrompt
+		}
+		// Vary the passages when more than one is asked for
+		var temperature *float64
+		if count > 1 {
+			t := 0.8
+			temperature = &t
+		}
+		for i := 0; i < count; i++ {
+			reply, err := c.rewriteCompletion(ctx, rw.Model, expandRewritePrompt(prompt, query, count), temperature)
+			if err != nil {
+				return nil, err
+			}
+			if passage := strings.TrimSpace(reply); passage != "" {
+				queries = append(queries, passage)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown query rewrite mode %q", rw.Mode)
+	}
+
+	if rw.KeepOriginal || len(queries) == 0 {
+		queries = append([]string{query}, queries...)
+	}
+	return queries, nil
+}
+
+// rewriteCompletion sends a single-message chat completion and returns the reply
+func (c *LlamaStackClient) rewriteCompletion(ctx context.Context, model, prompt string, temperature *float64) (string, error) {
+	resp, err := c.CreateChatCompletion(ctx, ChatCompletionParams{
+		Model:       model,
+		Messages:    []Message{{Role: "user", Content: prompt}},
+		Temperature: temperature,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to rewrite query: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("failed to rewrite query: no choices in response")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+func expandRewritePrompt(prompt, query string, count int) string {
+	return strings.NewReplacer("{query}", query, "{count}", strconv.Itoa(count)).Replace(prompt)
+}
+
+// parseQueryLines reads up to max queries from a one-per-line reply, dropping
+// list numbering, bullets, quotes and repeats
+func parseQueryLines(reply string, max int) []string {
+	var queries []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "-*• ")
+		if i := strings.IndexAny(line, ".)"); i > 0 && i <= 3 {
+			if _, err := strconv.Atoi(line[:i]); err == nil {
+				line = strings.TrimSpace(line[i+1:])
+			}
+		}
+		line = strings.Trim(line, `"'`)
+		key := strings.ToLower(line)
+		if line == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		queries = append(queries, line)
+		if len(queries) == max {
+			break
+		}
+	}
+	return queries
+}
+
+// queryRAGRewritten runs QueryRAG once per rewritten query and merges the
+// chunks into a single result in the server's layout
+func (c *LlamaStackClient) queryRAGRewritten(ctx context.Context, params RagToolQueryParams) (*QueryResult, error) {
+	queries, cfg, err := c.rewriteRAGQuery(ctx, params.Content, params.QueryConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	storeID := ""
+	if len(params.VectorDBIDs) == 1 {
+		storeID = params.VectorDBIDs[0]
+	}
+	perQuery := make([][]Citation, len(queries))
+	for i, q := range queries {
+		result, err := c.QueryRAG(ctx, RagToolQueryParams{Content: q, VectorDBIDs: params.VectorDBIDs, QueryConfig: cfg})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query rewritten query %d: %w", i+1, err)
+		}
+		perQuery[i] = storeCitations(storeID, result, false)
+	}
+
+	merged := MultiQueryResult{Chunks: mergeCitations(perQuery, MergeOptions{MaxChunks: cfg.MaxChunks})}
+	return merged.QueryResult(), nil
+}
+
+// rewriteRAGQuery rewrites query as cfg.Rewrite asks and returns the queries
+// with a copy of cfg that no longer rewrites
+func (c *LlamaStackClient) rewriteRAGQuery(ctx context.Context, query string, cfg *RAGQueryConfig) ([]string, *RAGQueryConfig, error) {
+	if cfg == nil || cfg.Rewrite == nil {
+		return []string{query}, cfg, nil
+	}
+	queries, err := c.rewriteQuery(ctx, query, cfg.Rewrite)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain := *cfg
+	plain.Rewrite = nil
+	return queries, &plain, nil
+}
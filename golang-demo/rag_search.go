@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RAGSearchMode selects how the RAG tool and vector store search find chunks
+type RAGSearchMode string
+
+// Search modes supported by the server
+const (
+	RAGSearchVector  RAGSearchMode = "vector"  // embedding similarity
+	RAGSearchKeyword RAGSearchMode = "keyword" // full-text match
+	RAGSearchHybrid  RAGSearchMode = "hybrid"  // both, combined by a Ranker
+)
+
+// Ranker types
+const (
+	RankerRRF      = "rrf"
+	RankerWeighted = "weighted"
+)
+
+// Ranker combines vector and keyword scores in hybrid mode
+type Ranker struct {
+	Type         string  // RankerRRF or RankerWeighted
+	ImpactFactor float64 // RRF k; zero uses the server default of 60
+	Alpha        float64 // weighted: 0 keyword scores only, 1 vector scores only
+}
+
+// RRFRanker fuses vector and keyword rankings with Reciprocal Rank Fusion,
+// scoring each chunk 1/(k + rank); higher k flattens the difference between ranks
+func RRFRanker(k float64) *Ranker {
+	return &Ranker{Type: RankerRRF, ImpactFactor: k}
+}
+
+// WeightedRanker blends normalized scores as alpha*vector + (1-alpha)*keyword
+func WeightedRanker(alpha float64) *Ranker {
+	return &Ranker{Type: RankerWeighted, Alpha: alpha}
+}
+
+// MarshalJSON writes only the field of the ranker's type, keeping an alpha of 0
+func (r Ranker) MarshalJSON() ([]byte, error) {
+	switch r.Type {
+	case RankerWeighted:
+		return json.Marshal(struct {
+			Type  string  `json:"type"`
+			Alpha float64 `json:"alpha"`
+		}{r.Type, r.Alpha})
+	default:
+		return json.Marshal(struct {
+			Type         string  `json:"type"`
+			ImpactFactor float64 `json:"impact_factor,omitempty"`
+		}{r.Type, r.ImpactFactor})
+	}
+}
+
+// UnmarshalJSON reads either ranker, applying the server's defaults
+func (r *Ranker) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type         string   `json:"type"`
+		ImpactFactor float64  `json:"impact_factor"`
+		Alpha        *float64 `json:"alpha"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = Ranker{Type: raw.Type, ImpactFactor: raw.ImpactFactor, Alpha: 0.5}
+	if raw.Alpha != nil {
+		r.Alpha = *raw.Alpha
+	}
+	return nil
+}
+
+// Validate checks the ranker against the server's schema
+func (r Ranker) Validate() error {
+	switch r.Type {
+	case RankerRRF:
+		if r.ImpactFactor < 0 {
+			return fmt.Errorf("rrf impact_factor must be greater than 0, got %g", r.ImpactFactor)
+		}
+	case RankerWeighted:
+		if r.Alpha < 0 || r.Alpha > 1 {
+			return fmt.Errorf("weighted ranker alpha must be between 0 and 1, got %g", r.Alpha)
+		}
+	case "":
+		return errors.New("ranker has no type")
+	default:
+		return fmt.Errorf("unknown ranker type %q", r.Type)
+	}
+	return nil
+}
+
+// Validate checks the search mode is one the server knows; empty means the
+// server default, vector
+func (m RAGSearchMode) Validate() error {
+	switch m {
+	case "", RAGSearchVector, RAGSearchKeyword, RAGSearchHybrid:
+		return nil
+	}
+	return fmt.Errorf("unknown search mode %q (want vector, keyword or hybrid)", string(m))
+}
+
+// Validate checks the config for values the server would reject, and for
+// client-side options that cannot work
+func (cfg RAGQueryConfig) Validate() error {
+	var errs []error
+	if cfg.MaxChunks < 0 {
+		errs = append(errs, fmt.Errorf("max_chunks must not be negative, got %d", cfg.MaxChunks))
+	}
+	if cfg.MaxTokensInContext < 0 {
+		errs = append(errs, fmt.Errorf("max_tokens_in_context must not be negative, got %d", cfg.MaxTokensInContext))
+	}
+	if err := cfg.Mode.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Ranker != nil {
+		if cfg.Mode != RAGSearchHybrid {
+			errs = append(errs, fmt.Errorf("a ranker only applies to hybrid mode, not %q", string(cfg.Mode)))
+		}
+		if err := cfg.Ranker.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.ChunkTemplate != "" {
+		for _, placeholder := range []string{"{index}", "{chunk.content}"} {
+			if !strings.Contains(cfg.ChunkTemplate, placeholder) {
+				errs = append(errs, fmt.Errorf("chunk_template must contain %s", placeholder))
+			}
+		}
+	}
+	if cfg.Filter != nil {
+		if err := cfg.Filter.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("filter: %w", err))
+		}
+	}
+	if cfg.Rewrite != nil {
+		if cfg.Rewrite.Model == "" {
+			errs = append(errs, errors.New("rewrite needs a model"))
+		}
+		if cfg.Rewrite.Mode != RewriteMultiQuery && cfg.Rewrite.Mode != RewriteHyDE {
+			errs = append(errs, fmt.Errorf("unknown query rewrite mode %q", cfg.Rewrite.Mode))
+		}
+	}
+	return errors.Join(errs...)
+}
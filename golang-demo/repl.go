@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// runReplCommand handles "repl": a multi-turn chat on stdin that keeps the
+// history within the model's context with a ContextManager. Replies are also
+// passed to hooks, sentence by sentence.
+func runReplCommand(client *LlamaStackClient, args []string, raw bool, hooks ...OutputHook) error {
+	fs := flag.NewFlagSet("repl", flag.ContinueOnError)
+	model := fs.String("model", "", "model to chat with; the first LLM by default")
+	maxContext := fs.Int("max-context", 4096, "token budget for the history sent with each message")
+	strategy := fs.String("strategy", string(TruncateDropOldest), "how to shrink the history: drop-oldest, sliding-window or summarize")
+	window := fs.Int("window", 20, "messages kept by the sliding-window strategy")
+	system := fs.String("system", "You are a helpful assistant.", "system prompt")
+	autoTitle := fs.Bool("title", false, "title the conversation in the background after the first exchange")
+	moderation := fs.String("moderation", os.Getenv("LLAMASTACK_MODERATION"), "check messages with the moderation endpoint first: block, annotate or off")
+	moderationModel := fs.String("moderation-model", os.Getenv("LLAMASTACK_MODERATION_MODEL"), "moderation model; the server's default when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: repl [-model MODEL] [-max-context 4096] [-strategy drop-oldest|sliding-window|summarize] [-window 20] [-system PROMPT] [-title] [-moderation block|annotate|off]")
+	}
+	truncation, err := ParseTruncationStrategy(*strategy)
+	if err != nil {
+		return err
+	}
+	moderator, err := newModerator(client, *moderation, *moderationModel)
+	if err != nil {
+		return err
+	}
+	if moderator != nil {
+		moderator.OnFlagged = func(result ModerationResult) {
+			fmt.Printf("(flagged by moderation: %s; sent anyway)\n", result.Summary())
+		}
+	}
+
+	ctx := context.Background()
+	if *model == "" {
+		if *model, err = client.GetAvailableModel(ctx); err != nil {
+			return fmt.Errorf("no model to chat with: %w", err)
+		}
+	}
+	manager := &ContextManager{
+		MaxTokens:  *maxContext,
+		Strategy:   truncation,
+		WindowSize: *window,
+		Client:     client,
+		Model:      *model,
+	}
+
+	// The title arrives from a background completion; it is shown before
+	// the next prompt rather than in the middle of a reply
+	conversationID := "chat-" + time.Now().Format("20060102-150405")
+	titles := make(chan string, 1)
+	var title string
+	takeTitle := func() {
+		select {
+		case title = <-titles:
+			fmt.Printf("(conversation titled %q)\n", title)
+		default:
+		}
+	}
+	var titler *AutoTitler
+	if *autoTitle {
+		titler = &AutoTitler{Client: client, Model: *model, OnTitle: func(_, t string) { titles <- t }}
+		defer func() {
+			titler.Wait()
+			takeTitle()
+		}()
+	}
+
+	fmt.Printf("Chatting with %s; /exit quits, /tokens shows the history size, /save FILE writes it as Markdown\n", *model)
+	history := []Message{{Role: "system", Content: *system}}
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		takeTitle()
+		fmt.Print("> ")
+		if !in.Scan() {
+			fmt.Println()
+			return in.Err()
+		}
+		line := strings.TrimSpace(in.Text())
+		switch {
+		case line == "":
+			continue
+		case line == "/exit", line == "/quit":
+			return nil
+		case line == "/tokens":
+			fmt.Printf("%d messages, about %d of %d tokens\n", len(history), manager.Tokens(history), manager.maxTokens())
+			continue
+		case strings.HasPrefix(line, "/save "):
+			takeTitle()
+			if err := saveReplConversation(strings.TrimSpace(strings.TrimPrefix(line, "/save ")), title, *model, history); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			continue
+		}
+
+		if moderator != nil {
+			if _, err := moderator.Check(ctx, line); isModerationBlocked(err) {
+				fmt.Printf("Warning: %v; the message was not sent\n", err)
+				continue
+			} else if err != nil {
+				fmt.Printf("Warning: %v; sending the message unchecked\n", err)
+			}
+		}
+
+		history = append(history, Message{Role: "user", Content: line})
+		before := len(history)
+		fitted, err := manager.Fit(ctx, history)
+		if err != nil {
+			// Forget the message so the next one can still be sent
+			history = history[:len(history)-1]
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+		history = fitted
+		if len(history) != before {
+			fmt.Printf("(history shrunk from %d to %d messages with %s)\n", before, len(history), truncation)
+		}
+
+		reply, err := streamReplReply(ctx, client, *model, history, raw, hooks...)
+		if err != nil {
+			history = history[:len(history)-1]
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+		history = append(history, Message{Role: "assistant", Content: reply})
+		if titler != nil {
+			titler.Title(conversationID, history)
+		}
+	}
+}
+
+// saveReplConversation writes the history to path as Markdown, under title
+// once the conversation has one
+func saveReplConversation(path, title, model string, history []Message) error {
+	if title == "" {
+		title = "Chat with " + model
+	}
+	conv := ConversationFromChat(title, history, nil)
+	conv.Model = model
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	if err := conv.WriteMarkdown(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	fmt.Printf("Saved %q to %s\n", title, path)
+	return nil
+}
+
+// streamReplReply streams the reply to messages to stdout and to hooks,
+// feeding both the same deltas, and returns it. Hook failures are logged; they do not fail the reply.
+func streamReplReply(ctx context.Context, client *LlamaStackClient, model string, messages []Message, raw bool, hooks ...OutputHook) (string, error) {
+	out, flush := newStreamWriter(raw)
+	segments := NewSegmentWriter(hooks...)
+	var reply strings.Builder
+	for chunk, err := range client.StreamChatCompletion(ctx, ChatCompletionParams{Model: model, Messages: messages}) {
+		if err != nil {
+			flush()
+			fmt.Println()
+			segments.Close()
+			return "", err
+		}
+		if len(chunk.Choices) > 0 {
+			reply.WriteString(chunk.Choices[0].Delta.Content)
+			io.WriteString(out, chunk.Choices[0].Delta.Content)
+			io.WriteString(segments, chunk.Choices[0].Delta.Content)
+		}
+	}
+	flush()
+	fmt.Println()
+	if err := segments.Close(); err != nil {
+		client.logger().Warn("output hook failed", "error", err)
+	}
+	return reply.String(), nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// do sends req with HTTPClient. Every client call goes through here, so
+// behavior that applies to all requests, such as dry runs, curl output,
+// capture files, logging, call metadata, correlation IDs, context overrides,
+// budgets, cost estimates and tracking calls for Close, lives in one place.
+func (c *LlamaStackClient) do(req *http.Request) (*http.Response, error) {
+	release := func() {}
+	if c.inflight != nil {
+		var err error
+		if req, release, err = c.inflight.start(req); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyOverrides(req); err != nil {
+		release()
+		return nil, err
+	}
+	budgeted := c.Budget != nil && !c.dryRunning(req.Context())
+	var costSlot *callCostSlot
+	if c.Pricing != nil && costedPath(req.URL.Path) && !c.dryRunning(req.Context()) {
+		req, costSlot = withCostSlot(req)
+	}
+	var chat chatPromptEstimate
+	if budgeted {
+		if err := c.Budget.admit(); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	if budgeted || costSlot != nil {
+		chat = chatPromptTokens(req)
+	}
+	if id := CorrelationID(req.Context()); id != "" && req.Header.Get(CorrelationIDHeader) == "" {
+		req.Header.Set(CorrelationIDHeader, id)
+	}
+	if c.CurlOutput != nil {
+		cmd, err := CurlCommand(req)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		fmt.Fprintf(c.CurlOutput, "%s\n\n", cmd)
+	}
+	var capture *requestCapture
+	if c.CaptureDir != "" {
+		capture = c.startCapture(req)
+	}
+
+	c.logRequest(req)
+
+	start := time.Now()
+	var resp *http.Response
+	var err error
+	if c.dryRunning(req.Context()) {
+		resp, err = c.dryRun(req)
+	} else {
+		resp, err = c.sendCompressed(req)
+	}
+	if budgeted {
+		resp, err = c.Budget.chargeCall(start, chat, resp, err)
+	}
+	if costSlot != nil {
+		resp, err = c.costCall(costSlot, start, chat, resp, err)
+	}
+	if capture != nil {
+		resp, err = capture.finish(resp, err)
+	}
+	resp, err = c.logResponse(req, start, resp, err)
+	resp, err = recordCall(req, start, resp, err)
+	return finishInflight(release, resp, err)
+}
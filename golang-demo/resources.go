@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// AgentInfo is an agent as returned by ListAgents
+type AgentInfo struct {
+	AgentID     string      `json:"agent_id"`
+	AgentConfig AgentConfig `json:"agent_config"`
+	CreatedAt   string      `json:"created_at,omitempty"`
+}
+
+// ListAgentsResponse is one page of agents
+type ListAgentsResponse = Page[AgentInfo]
+
+// ListSessionsResponse is one page of an agent's sessions
+type ListSessionsResponse = Page[Session]
+
+// ListVectorStoresResponse is one page of vector stores
+type ListVectorStoresResponse = Page[VectorStore]
+
+// resourcePageSize is the page size used when listing every resource of a kind
+const resourcePageSize = 100
+
+// ListAgents lists all agents, following pagination
+func (c *LlamaStackClient) ListAgents(ctx context.Context) ([]AgentInfo, error) {
+	return listFrom[AgentInfo](ctx, c, "/v1/agents")
+}
+
+// GetAgent returns an agent and the config it was created with
+func (c *LlamaStackClient) GetAgent(ctx context.Context, agentID string) (*AgentInfo, error) {
+	var agent AgentInfo
+	if err := c.getJSON(ctx, "/v1/agents/"+url.PathEscape(agentID), &agent); err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// AgentVectorDBIDs returns the vector DBs an existing agent's RAG toolgroup
+// searches, for callers that only have the agent's ID
+func (c *LlamaStackClient) AgentVectorDBIDs(ctx context.Context, agentID string) ([]string, error) {
+	agent, err := c.GetAgent(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent %s: %w", agentID, err)
+	}
+	return RAGVectorDBIDs(agent.AgentConfig), nil
+}
+
+// ListSessions lists all sessions of an agent, following pagination. Turns
+// are not included; use GetSession for those.
+func (c *LlamaStackClient) ListSessions(ctx context.Context, agentID string) ([]Session, error) {
+	sessions, err := listFrom[Session](ctx, c, "/v1/agents/"+url.PathEscape(agentID)+"/sessions")
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		if sessions[i].AgentID == "" {
+			sessions[i].AgentID = agentID
+		}
+		sessions[i].Turns = nil
+	}
+	return sessions, nil
+}
+
+// DeleteSession deletes an agent session and its turns
+func (c *LlamaStackClient) DeleteSession(ctx context.Context, agentID, sessionID string) error {
+	return c.sendDelete(ctx, "/v1/agents/"+url.PathEscape(agentID)+"/session/"+url.PathEscape(sessionID))
+}
+
+// ListVectorStores lists all vector stores, following pagination
+func (c *LlamaStackClient) ListVectorStores(ctx context.Context) ([]VectorStore, error) {
+	return listAfter[VectorStore](ctx, c, "/v1/openai/v1/vector_stores")
+}
+
+// DeleteVectorStore deletes a vector store. Files attached to it are
+// detached but stay uploaded.
+func (c *LlamaStackClient) DeleteVectorStore(ctx context.Context, vectorStoreID string) error {
+	return c.sendDelete(ctx, "/v1/openai/v1/vector_stores/"+url.PathEscape(vectorStoreID))
+}
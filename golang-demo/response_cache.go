@@ -0,0 +1,300 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseCacheOptions configures a ResponseCache
+type ResponseCacheOptions struct {
+	MaxEntries int           // in-memory entries kept; defaults to 256
+	TTL        time.Duration // entries older than this are ignored; never expire when zero
+	Dir        string        // if set, entries are also persisted here across runs
+
+	// CacheAll caches every non-streaming completion. By default only
+	// deterministic requests are cached: temperature 0 or a fixed seed.
+	CacheAll bool
+}
+
+// ResponseCache caches non-streaming chat completions keyed by a hash of the
+// model, messages and sampling parameters, so repeated demo and eval runs do
+// not pay for the same deterministic completion twice. Entries live in an
+// in-memory LRU and, when a directory is configured, in one JSON file per key
+// that is shared across processes.
+type ResponseCache struct {
+	opts ResponseCacheOptions
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+	hits    int
+	misses  int
+}
+
+type responseCacheEntry struct {
+	Key      string       `json:"key"`
+	Created  time.Time    `json:"created"`
+	Response *APIResponse `json:"response"`
+}
+
+// NewResponseCache creates a response cache, creating opts.Dir if needed
+func NewResponseCache(opts ResponseCacheOptions) (*ResponseCache, error) {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 256
+	}
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create response cache: %w", err)
+		}
+	}
+	return &ResponseCache{
+		opts:    opts,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}, nil
+}
+
+type responseCacheModeKey struct{}
+
+type responseCacheMode int
+
+const (
+	responseCacheBypass responseCacheMode = iota + 1
+	responseCacheRefresh
+)
+
+// BypassResponseCache returns a context whose chat completions neither read
+// from nor write to the client's ResponseCache
+func BypassResponseCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, responseCacheModeKey{}, responseCacheBypass)
+}
+
+// RefreshResponseCache returns a context whose chat completions always reach
+// the server, replacing any cached response with the new one
+func RefreshResponseCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, responseCacheModeKey{}, responseCacheRefresh)
+}
+
+func responseCacheModeOf(ctx context.Context) responseCacheMode {
+	mode, _ := ctx.Value(responseCacheModeKey{}).(responseCacheMode)
+	return mode
+}
+
+// cacheable reports whether params may be served from the cache
+func (c *ResponseCache) cacheable(params ChatCompletionParams) bool {
+	if params.Stream != nil && *params.Stream {
+		return false
+	}
+	if c.opts.CacheAll {
+		return true
+	}
+	return params.Seed != nil || (params.Temperature != nil && *params.Temperature == 0)
+}
+
+// responseCacheKey hashes everything that affects the completion. Stream and
+// User do not change the content and are left out.
+func responseCacheKey(params ChatCompletionParams) (string, error) {
+	params.Stream = nil
+	params.User = ""
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal chat completion params: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.opts.Dir, key[:2], key+".json")
+}
+
+func (c *ResponseCache) expired(e *responseCacheEntry) bool {
+	return c.opts.TTL > 0 && time.Since(e.Created) > c.opts.TTL
+}
+
+// Get returns the cached response for params
+func (c *ResponseCache) Get(params ChatCompletionParams) (*APIResponse, bool) {
+	key, err := responseCacheKey(params)
+	if err != nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*responseCacheEntry)
+		if !c.expired(entry) {
+			c.order.MoveToFront(el)
+			c.hits++
+			c.mu.Unlock()
+			return entry.Response, true
+		}
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	entry, ok := c.readDisk(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.add(entry)
+	c.hits++
+	return entry.Response, true
+}
+
+func (c *ResponseCache) readDisk(key string) (*responseCacheEntry, bool) {
+	if c.opts.Dir == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry responseCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key || entry.Response == nil {
+		return nil, false
+	}
+	if c.expired(&entry) {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+	return &entry, true
+}
+
+// add inserts entry as the most recently used, evicting the oldest when
+// full. The caller holds c.mu.
+func (c *ResponseCache) add(entry *responseCacheEntry) {
+	if el, ok := c.entries[entry.Key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[entry.Key] = c.order.PushFront(entry)
+	for c.order.Len() > c.opts.MaxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*responseCacheEntry).Key)
+	}
+}
+
+// Put stores the response for params
+func (c *ResponseCache) Put(params ChatCompletionParams, response *APIResponse) error {
+	key, err := responseCacheKey(params)
+	if err != nil {
+		return err
+	}
+	entry := &responseCacheEntry{Key: key, Created: time.Now(), Response: response}
+
+	c.mu.Lock()
+	c.add(entry)
+	c.mu.Unlock()
+
+	if c.opts.Dir == "" {
+		return nil
+	}
+	return c.writeDisk(entry)
+}
+
+func (c *ResponseCache) writeDisk(entry *responseCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to write response cache: %w", err)
+	}
+	p := c.path(entry.Key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("failed to write response cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write response cache: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write response cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write response cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write response cache: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the hits and misses since the cache was created
+func (c *ResponseCache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Clear removes every cached response, in memory and on disk
+func (c *ResponseCache) Clear() error {
+	c.mu.Lock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.mu.Unlock()
+
+	if c.opts.Dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(c.opts.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to clear response cache: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() && len(e.Name()) == 2 {
+			if err := os.RemoveAll(filepath.Join(c.opts.Dir, e.Name())); err != nil {
+				return fmt.Errorf("failed to clear response cache: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseResponseCacheSpec parses LLAMASTACK_RESPONSE_CACHE: "memory" for an
+// in-memory cache, or a directory, each optionally followed by ",ttl=DURATION"
+func parseResponseCacheSpec(spec string) (ResponseCacheOptions, error) {
+	var opts ResponseCacheOptions
+	parts := strings.Split(spec, ",")
+	if parts[0] != "memory" {
+		opts.Dir = parts[0]
+	}
+	for _, part := range parts[1:] {
+		name, value, _ := strings.Cut(part, "=")
+		switch name {
+		case "ttl":
+			ttl, err := time.ParseDuration(value)
+			if err != nil {
+				return opts, fmt.Errorf("invalid response cache ttl: %w", err)
+			}
+			opts.TTL = ttl
+		case "all":
+			opts.CacheAll = true
+		default:
+			return opts, fmt.Errorf("unknown response cache option %q", name)
+		}
+	}
+	return opts, nil
+}
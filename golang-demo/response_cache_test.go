@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// cacheParams returns deterministic params that differ by prompt
+func cacheParams(prompt string) ChatCompletionParams {
+	temperature := 0.0
+	return ChatCompletionParams{Model: "m", Messages: []Message{{Role: "user", Content: prompt}}, Temperature: &temperature}
+}
+
+func cachedReply(text string) *APIResponse {
+	return &APIResponse{Choices: []ChatCompletionChoice{{Message: ChatCompletionMessage{Role: "assistant", Content: text}}}}
+}
+
+// ageEntries makes every in-memory entry look created d ago
+func ageEntries(c *ResponseCache, d time.Duration) {
+	for _, el := range c.entries {
+		el.Value.(*responseCacheEntry).Created = time.Now().Add(-d)
+	}
+}
+
+func TestResponseCacheLRU(t *testing.T) {
+	c, err := NewResponseCache(ResponseCacheOptions{MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put(cacheParams("a"), cachedReply("A"))
+	c.Put(cacheParams("b"), cachedReply("B"))
+	// Reading a makes b the least recently used
+	if r, ok := c.Get(cacheParams("a")); !ok || r.Choices[0].Message.Content != "A" {
+		t.Fatalf("Get(a) = %v, %v", r, ok)
+	}
+	c.Put(cacheParams("c"), cachedReply("C"))
+
+	for _, tt := range []struct {
+		prompt string
+		cached bool
+	}{{"a", true}, {"b", false}, {"c", true}} {
+		if _, ok := c.Get(cacheParams(tt.prompt)); ok != tt.cached {
+			t.Errorf("Get(%s) cached = %v, want %v", tt.prompt, ok, tt.cached)
+		}
+	}
+	if hits, misses := c.Stats(); hits != 3 || misses != 1 {
+		t.Fatalf("Stats() = %d hits, %d misses, want 3 and 1", hits, misses)
+	}
+
+	// Putting an existing key replaces it without evicting anything
+	c.Put(cacheParams("a"), cachedReply("A2"))
+	if r, _ := c.Get(cacheParams("a")); r.Choices[0].Message.Content != "A2" || c.order.Len() != 2 {
+		t.Fatalf("replaced entry = %v, %d entries", r, c.order.Len())
+	}
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewResponseCache(ResponseCacheOptions{TTL: time.Minute, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := cacheParams("a")
+	if err := c.Put(params, cachedReply("A")); err != nil {
+		t.Fatal(err)
+	}
+	ageEntries(c, 30*time.Second)
+	if _, ok := c.Get(params); !ok {
+		t.Fatal("entry expired before its TTL")
+	}
+
+	// A second process reads the entry from disk
+	other, _ := NewResponseCache(ResponseCacheOptions{TTL: time.Minute, Dir: dir})
+	if r, ok := other.Get(params); !ok || r.Choices[0].Message.Content != "A" {
+		t.Fatalf("disk Get = %v, %v", r, ok)
+	}
+
+	// The disk entry is still fresh, so it replaces the expired memory one
+	ageEntries(c, 2*time.Minute)
+	if _, ok := c.Get(params); !ok {
+		t.Fatal("fresh disk entry was ignored")
+	}
+
+	// Once both copies have expired the entry is gone, file included
+	key, _ := responseCacheKey(params)
+	ageEntries(c, 2*time.Minute)
+	if err := c.writeDisk(c.entries[key].Value.(*responseCacheEntry)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(params); ok {
+		t.Fatal("Get returned an expired entry")
+	}
+	if _, err := os.Stat(c.path(key)); !os.IsNotExist(err) {
+		t.Fatalf("expired file was kept: %v", err)
+	}
+
+	// Without a TTL nothing expires
+	forever, _ := NewResponseCache(ResponseCacheOptions{})
+	forever.Put(params, cachedReply("A"))
+	ageEntries(forever, 24*365*time.Hour)
+	if _, ok := forever.Get(params); !ok {
+		t.Fatal("entry expired without a TTL")
+	}
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	stream := false
+	base, _ := responseCacheKey(cacheParams("a"))
+	withUser := cacheParams("a")
+	withUser.User, withUser.Stream = "ana", &stream
+	seed := 7
+	withSeed := cacheParams("a")
+	withSeed.Seed = &seed
+
+	tests := []struct {
+		name   string
+		params ChatCompletionParams
+		same   bool
+	}{
+		{"user and stream are left out", withUser, true},
+		{"another prompt", cacheParams("b"), false},
+		{"a seed", withSeed, false},
+	}
+	for _, tt := range tests {
+		if key, _ := responseCacheKey(tt.params); (key == base) != tt.same {
+			t.Errorf("%s: same key = %v, want %v", tt.name, key == base, tt.same)
+		}
+	}
+}
+
+func TestCreateChatCompletionResponseCache(t *testing.T) {
+	mock := NewMockStack()
+	defer mock.Close()
+	mock.ChatReplies = []string{"first", "second", "third", "fourth"}
+	client := NewLlamaStackClient(mock.URL(), "test")
+	client.ResponseCache, _ = NewResponseCache(ResponseCacheOptions{})
+	ctx := context.Background()
+
+	reply := func(ctx context.Context, params ChatCompletionParams) string {
+		t.Helper()
+		r, err := client.CreateChatCompletion(ctx, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Choices[0].Message.Content
+	}
+	sampled := cacheParams("a")
+	sampled.Temperature = nil
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		params ChatCompletionParams
+		want   string
+	}{
+		{"miss", ctx, cacheParams("a"), "first"},
+		{"hit", ctx, cacheParams("a"), "first"},
+		{"bypass reaches the server", BypassResponseCache(ctx), cacheParams("a"), "second"},
+		{"bypass did not store its reply", ctx, cacheParams("a"), "first"},
+		{"refresh reaches the server", RefreshResponseCache(ctx), cacheParams("a"), "third"},
+		{"refresh replaced the entry", ctx, cacheParams("a"), "third"},
+		{"sampled requests are not cached", ctx, sampled, "fourth"},
+	}
+	for _, tt := range tests {
+		if got := reply(tt.ctx, tt.params); got != tt.want {
+			t.Fatalf("%s: reply = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseResponseCacheSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    ResponseCacheOptions
+		wantErr bool
+	}{
+		{spec: "memory", want: ResponseCacheOptions{}},
+		{spec: "/tmp/cache,ttl=1h,all", want: ResponseCacheOptions{Dir: "/tmp/cache", TTL: time.Hour, CacheAll: true}},
+		{spec: "memory,ttl=soon", wantErr: true},
+		{spec: "memory,size=3", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseResponseCacheSpec(tt.spec)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("parseResponseCacheSpec(%q) = %+v, %v", tt.spec, got, err)
+		}
+	}
+}
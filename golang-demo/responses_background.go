@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// responseDone reports whether a response's status is final
+func responseDone(status string) bool {
+	switch status {
+	case "completed", "failed", "cancelled", "incomplete":
+		return true
+	}
+	return false
+}
+
+// GetResponse returns a stored response, such as a background response
+// being polled
+func (c *LlamaStackClient) GetResponse(ctx context.Context, responseID string) (*ResponseObject, error) {
+	var response ResponseObject
+	if err := c.getJSON(ctx, "/v1/openai/v1/responses/"+url.PathEscape(responseID), &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CancelResponse cancels a queued or in-progress background response and
+// returns it. A response that already finished is returned unchanged.
+func (c *LlamaStackClient) CancelResponse(ctx context.Context, responseID string) (*ResponseObject, error) {
+	var response ResponseObject
+	if err := c.postJSON(ctx, "/v1/openai/v1/responses/"+url.PathEscape(responseID)+"/cancel", struct{}{}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// WaitForResponse polls a background response until it completes and
+// returns it. A response that fails, is cancelled or ends incomplete is
+// returned with an error, so partial output can still be read. If ctx is
+// done or opts.Timeout passes first the response keeps running on the
+// server; cancel it with CancelResponse if needed.
+func (c *LlamaStackClient) WaitForResponse(ctx context.Context, responseID string, opts PollOptions[*ResponseObject]) (*ResponseObject, error) {
+	poller := Poller[*ResponseObject]{
+		Poll: func(ctx context.Context) (*ResponseObject, error) {
+			return c.GetResponse(ctx, responseID)
+		},
+		Done: func(response *ResponseObject) (bool, error) {
+			if !responseDone(response.Status) {
+				return false, nil
+			}
+			if response.Status == "completed" {
+				return true, nil
+			}
+			if response.Error != nil {
+				return true, fmt.Errorf("response %s %s: %w", responseID, response.Status, response.Error)
+			}
+			return true, fmt.Errorf("response %s %s", responseID, response.Status)
+		},
+		Options: opts,
+		Logger:  c.logger(),
+	}
+	response, err := poller.Wait(ctx)
+	if err != nil {
+		return response, fmt.Errorf("failed to wait for response: %w", err)
+	}
+	return response, nil
+}
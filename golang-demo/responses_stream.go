@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"sort"
+	"strings"
+)
+
+// ResponseEvent is one event of a streamed Responses API call. It holds one
+// of the typed variants below; switch on the type, or on EventType for the
+// exact event name.
+type ResponseEvent interface {
+	EventType() string
+}
+
+// responseEventBase holds the fields every response event carries
+type responseEventBase struct {
+	Type           string `json:"type"`
+	SequenceNumber int    `json:"sequence_number,omitempty"`
+}
+
+func (e responseEventBase) EventType() string { return e.Type }
+
+// ResponseCreatedEvent is response.created, response.queued or
+// response.in_progress, carrying the response so far
+type ResponseCreatedEvent struct {
+	responseEventBase
+	Response ResponseObject `json:"response"`
+}
+
+// ResponseCompletedEvent ends the stream: response.completed, or
+// response.incomplete or response.failed with the reason in Response.Error
+type ResponseCompletedEvent struct {
+	responseEventBase
+	Response ResponseObject `json:"response"`
+}
+
+// OutputItemEvent is response.output_item.added, when an output item
+// starts, or response.output_item.done with the finished item
+type OutputItemEvent struct {
+	responseEventBase
+	OutputIndex int              `json:"output_index"`
+	Item        ConversationItem `json:"item"`
+}
+
+// ContentPartEvent is response.content_part.added or .done for a part of a
+// message item
+type ContentPartEvent struct {
+	responseEventBase
+	ItemID       string              `json:"item_id"`
+	OutputIndex  int                 `json:"output_index"`
+	ContentIndex int                 `json:"content_index"`
+	Part         ConversationContent `json:"part"`
+}
+
+// OutputTextDeltaEvent is response.output_text.delta, the next piece of a
+// message's text
+type OutputTextDeltaEvent struct {
+	responseEventBase
+	ItemID       string `json:"item_id"`
+	OutputIndex  int    `json:"output_index"`
+	ContentIndex int    `json:"content_index"`
+	Delta        string `json:"delta"`
+}
+
+// OutputTextDoneEvent is response.output_text.done with the whole text
+type OutputTextDoneEvent struct {
+	responseEventBase
+	ItemID       string `json:"item_id"`
+	OutputIndex  int    `json:"output_index"`
+	ContentIndex int    `json:"content_index"`
+	Text         string `json:"text"`
+}
+
+// FunctionCallArgumentsDeltaEvent is response.function_call_arguments.delta,
+// the next piece of a function call's arguments
+type FunctionCallArgumentsDeltaEvent struct {
+	responseEventBase
+	ItemID      string `json:"item_id"`
+	OutputIndex int    `json:"output_index"`
+	Delta       string `json:"delta"`
+}
+
+// FunctionCallArgumentsDoneEvent is response.function_call_arguments.done
+// with the whole arguments
+type FunctionCallArgumentsDoneEvent struct {
+	responseEventBase
+	ItemID      string `json:"item_id"`
+	OutputIndex int    `json:"output_index"`
+	Arguments   string `json:"arguments"`
+}
+
+// ToolCallEvent reports the progress of a server-side tool call, such as
+// response.web_search_call.searching or response.mcp_call.completed
+type ToolCallEvent struct {
+	responseEventBase
+	ItemID      string `json:"item_id"`
+	OutputIndex int    `json:"output_index"`
+}
+
+// Tool returns the kind of call, e.g. "web_search_call"
+func (e ToolCallEvent) Tool() string {
+	tool, _ := parseToolCallEventType(e.Type)
+	return tool
+}
+
+// Status returns the call's progress, e.g. "in_progress" or "completed"
+func (e ToolCallEvent) Status() string {
+	_, status := parseToolCallEventType(e.Type)
+	return status
+}
+
+// parseToolCallEventType splits response.<tool>_call.<status>
+func parseToolCallEventType(eventType string) (tool, status string) {
+	name, ok := strings.CutPrefix(eventType, "response.")
+	if !ok {
+		return "", ""
+	}
+	tool, status, ok = strings.Cut(name, ".")
+	if !ok || !strings.HasSuffix(tool, "_call") || strings.Contains(status, ".") {
+		return "", ""
+	}
+	return tool, status
+}
+
+// UnknownResponseEvent is an event this client has no type for, kept raw
+type UnknownResponseEvent struct {
+	responseEventBase
+	Data json.RawMessage
+}
+
+// decodeResponseEvent decodes one event of a response stream into its typed
+// variant; an error event is returned as a *ResponseError
+func decodeResponseEvent(data string) (ResponseEvent, error) {
+	var base responseEventBase
+	if err := decodeSSEData(data, &base); err != nil {
+		return nil, err
+	}
+	var ev ResponseEvent
+	switch base.Type {
+	case "error":
+		streamErr := &ResponseError{}
+		if err := decodeSSEData(data, streamErr); err != nil {
+			return nil, err
+		}
+		return nil, streamErr
+	case "response.created", "response.queued", "response.in_progress":
+		ev = &ResponseCreatedEvent{}
+	case "response.completed", "response.incomplete", "response.failed":
+		ev = &ResponseCompletedEvent{}
+	case "response.output_item.added", "response.output_item.done":
+		ev = &OutputItemEvent{}
+	case "response.content_part.added", "response.content_part.done":
+		ev = &ContentPartEvent{}
+	case "response.output_text.delta":
+		ev = &OutputTextDeltaEvent{}
+	case "response.output_text.done":
+		ev = &OutputTextDoneEvent{}
+	case "response.function_call_arguments.delta":
+		ev = &FunctionCallArgumentsDeltaEvent{}
+	case "response.function_call_arguments.done":
+		ev = &FunctionCallArgumentsDoneEvent{}
+	default:
+		if tool, _ := parseToolCallEventType(base.Type); tool != "" {
+			ev = &ToolCallEvent{}
+			break
+		}
+		return &UnknownResponseEvent{responseEventBase: base, Data: json.RawMessage(data)}, nil
+	}
+	if err := decodeSSEData(data, ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// CreateResponseStream creates a response with the Responses API and streams
+// its events as they arrive.
+//
+// The sequence ends after the *ResponseCompletedEvent. Breaking out of the
+// range loop closes the underlying response body. A non-nil error is always
+// the last value yielded.
+func (c *LlamaStackClient) CreateResponseStream(ctx context.Context, params ResponseParams) iter.Seq2[ResponseEvent, error] {
+	return func(yield func(ResponseEvent, error) bool) {
+		if params.Input == nil {
+			yield(nil, errors.New("response input is required"))
+			return
+		}
+		if err := validateResponseTools(params.Tools); err != nil {
+			yield(nil, err)
+			return
+		}
+		params.Stream = true
+		resp, err := c.postStream(ctx, "/v1/openai/v1/responses", params)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		stopped, done := false, false
+		err = readSSE(resp.Body, func(sse sseEvent) bool {
+			ev, err := decodeResponseEvent(sse.Data)
+			if err != nil {
+				yield(nil, err)
+				stopped = true
+				return false
+			}
+			if !yield(ev, nil) {
+				stopped = true
+				return false
+			}
+			if _, ok := ev.(*ResponseCompletedEvent); ok {
+				done = true
+				return false
+			}
+			return true
+		})
+		if stopped || done {
+			return
+		}
+		if ctx.Err() != nil {
+			yield(nil, ctx.Err())
+			return
+		}
+		if errors.Is(err, ErrSSELineTooLong) {
+			yield(nil, err)
+			return
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		yield(nil, fmt.Errorf("%w: %w", ErrStreamInterrupted, err))
+	}
+}
+
+// ResponseAccumulator rebuilds the response object from its stream events.
+// Feed every event to Add in arrival order, then read Response.
+type ResponseAccumulator struct {
+	response ResponseObject
+	items    map[int]*ConversationItem
+	final    *ResponseObject
+}
+
+// NewResponseAccumulator creates an empty accumulator
+func NewResponseAccumulator() *ResponseAccumulator {
+	return &ResponseAccumulator{items: make(map[int]*ConversationItem)}
+}
+
+// item returns the output item at index, creating it for events that arrive
+// before the item's output_item.added
+func (a *ResponseAccumulator) item(index int, id string) *ConversationItem {
+	if a.items == nil {
+		a.items = make(map[int]*ConversationItem)
+	}
+	item, ok := a.items[index]
+	if !ok {
+		item = &ConversationItem{ID: id}
+		a.items[index] = item
+	}
+	return item
+}
+
+// part returns the content part at index of a message item
+func (item *ConversationItem) part(index int) *ConversationContent {
+	if item.Type == "" {
+		item.Type, item.Role = "message", "assistant"
+	}
+	for len(item.Content) <= index {
+		item.Content = append(item.Content, ConversationContent{Type: "output_text"})
+	}
+	return &item.Content[index]
+}
+
+// Add merges an event into the accumulated response
+func (a *ResponseAccumulator) Add(ev ResponseEvent) {
+	switch ev := ev.(type) {
+	case *ResponseCreatedEvent:
+		a.response = ev.Response
+		a.response.Output = nil
+	case *ResponseCompletedEvent:
+		final := ev.Response
+		a.final = &final
+	case *OutputItemEvent:
+		*a.item(ev.OutputIndex, ev.Item.ID) = ev.Item
+	case *ContentPartEvent:
+		*a.item(ev.OutputIndex, ev.ItemID).part(ev.ContentIndex) = ev.Part
+	case *OutputTextDeltaEvent:
+		a.item(ev.OutputIndex, ev.ItemID).part(ev.ContentIndex).Text += ev.Delta
+	case *OutputTextDoneEvent:
+		a.item(ev.OutputIndex, ev.ItemID).part(ev.ContentIndex).Text = ev.Text
+	case *FunctionCallArgumentsDeltaEvent:
+		a.item(ev.OutputIndex, ev.ItemID).Arguments += ev.Delta
+	case *FunctionCallArgumentsDoneEvent:
+		a.item(ev.OutputIndex, ev.ItemID).Arguments = ev.Arguments
+	}
+}
+
+// Done reports whether the stream's final event has been added
+func (a *ResponseAccumulator) Done() bool {
+	return a.final != nil
+}
+
+// Response returns the response: the final one sent at the end of the stream
+// or, before it or when it omits the output, one built from the events so far
+func (a *ResponseAccumulator) Response() *ResponseObject {
+	if a.final != nil && len(a.final.Output) > 0 {
+		final := *a.final
+		return &final
+	}
+	response := a.response
+	if a.final != nil {
+		response = *a.final
+	}
+	indexes := make([]int, 0, len(a.items))
+	for i := range a.items {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	response.Output = make([]ConversationItem, 0, len(indexes))
+	for _, i := range indexes {
+		response.Output = append(response.Output, *a.items[i])
+	}
+	return &response
+}
+
+// AccumulateResponseStream drains an event sequence into a new accumulator.
+// On error the accumulator holds everything received before the failure.
+func AccumulateResponseStream(stream iter.Seq2[ResponseEvent, error]) (*ResponseAccumulator, error) {
+	acc := NewResponseAccumulator()
+	for ev, err := range stream {
+		if err != nil {
+			return acc, err
+		}
+		acc.Add(ev)
+	}
+	return acc, nil
+}
+
+// printResponseStream prints a streamed response's text as it arrives, with
+// tool calls on lines of their own, and returns the response
+func printResponseStream(stream iter.Seq2[ResponseEvent, error]) (*ResponseObject, error) {
+	acc := NewResponseAccumulator()
+	names := make(map[int]string) // function names by output index
+	lineStart := false
+	printLine := func(format string, args ...interface{}) {
+		if !lineStart {
+			fmt.Println()
+		}
+		fmt.Printf(format+"\n", args...)
+		lineStart = true
+	}
+	for ev, err := range stream {
+		if err != nil {
+			fmt.Println()
+			return nil, err
+		}
+		acc.Add(ev)
+		switch ev := ev.(type) {
+		case *OutputItemEvent:
+			names[ev.OutputIndex] = ev.Item.Name
+		case *OutputTextDeltaEvent:
+			fmt.Print(ev.Delta)
+			lineStart = strings.HasSuffix(ev.Delta, "\n")
+		case *FunctionCallArgumentsDoneEvent:
+			printLine("[call %s(%s)]", names[ev.OutputIndex], ev.Arguments)
+		case *ToolCallEvent:
+			printLine("[%s %s]", ev.Tool(), ev.Status())
+		}
+	}
+	if !lineStart {
+		fmt.Println()
+	}
+	response := acc.Response()
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response, nil
+}
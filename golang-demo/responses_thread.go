@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// ResponseThread is a multi-turn conversation over the Responses API without
+// a stored conversation: each call is chained to the last completed one
+// with previous_response_id, so the server supplies the earlier turns.
+// Calls on one thread run one at a time.
+type ResponseThread struct {
+	Client *LlamaStackClient
+	Model  string
+	// Instructions are sent with every call; the server does not carry them
+	// over from previous responses
+	Instructions string
+	Temperature  *float64
+	Tools        []interface{}
+	Include      []string
+
+	// LastResponseID is the response the next call continues from. Set it to
+	// pick up an earlier thread; it is empty before the first call.
+	LastResponseID string
+
+	mu sync.Mutex
+}
+
+// NewResponseThread starts a thread answered by model
+func NewResponseThread(client *LlamaStackClient, model string) *ResponseThread {
+	return &ResponseThread{Client: client, Model: model}
+}
+
+// params builds the call for input, chained to the last response
+func (t *ResponseThread) params(input interface{}) ResponseParams {
+	store := true
+	return ResponseParams{
+		Model:              t.Model,
+		Input:              input,
+		Instructions:       t.Instructions,
+		PreviousResponseID: t.LastResponseID,
+		Store:              &store,
+		Temperature:        t.Temperature,
+		Tools:              t.Tools,
+		Include:            t.Include,
+	}
+}
+
+// advance makes a completed response the one the next call continues from.
+// A failed or incomplete response leaves the thread where it was, so the
+// next call retries from the last good turn.
+func (t *ResponseThread) advance(response *ResponseObject) {
+	if response.Status == "completed" && response.ID != "" {
+		t.LastResponseID = response.ID
+	}
+}
+
+// Send sends the next turn, a string or a []ConversationItem, and returns
+// the response
+func (t *ResponseThread) Send(ctx context.Context, input interface{}) (*ResponseObject, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	response, err := t.Client.CreateResponse(ctx, t.params(input))
+	if err != nil {
+		return nil, err
+	}
+	t.advance(response)
+	return response, nil
+}
+
+// Stream sends the next turn and streams the response's events, like
+// CreateResponseStream. The thread advances when the stream completes.
+func (t *ResponseThread) Stream(ctx context.Context, input interface{}) iter.Seq2[ResponseEvent, error] {
+	return func(yield func(ResponseEvent, error) bool) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for ev, err := range t.Client.CreateResponseStream(ctx, t.params(input)) {
+			if completed, ok := ev.(*ResponseCompletedEvent); ok {
+				t.advance(&completed.Response)
+			}
+			if !yield(ev, err) {
+				return
+			}
+		}
+	}
+}
+
+// Fork returns a new thread continuing from the same response, so two
+// follow-ups can branch from one point without affecting each other
+func (t *ResponseThread) Fork() *ResponseThread {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &ResponseThread{
+		Client:         t.Client,
+		Model:          t.Model,
+		Instructions:   t.Instructions,
+		Temperature:    t.Temperature,
+		Tools:          t.Tools,
+		Include:        t.Include,
+		LastResponseID: t.LastResponseID,
+	}
+}
+
+// Reset starts the thread over; the next call has no earlier turns
+func (t *ResponseThread) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.LastResponseID = ""
+}
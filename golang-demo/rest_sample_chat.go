@@ -4,32 +4,64 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // APIResponse represents a generic API response
 type APIResponse struct {
-	AgentID string `json:"agent_id,omitempty"`
-	ID      string `json:"id,omitempty"`
-	Object  string `json:"object,omitempty"`
-	Created int64  `json:"created,omitempty"`
-	Model   string `json:"model,omitempty"`
-	Choices []struct {
-		Index        int    `json:"index"`
-		FinishReason string `json:"finish_reason"`
-		Message      struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices,omitempty"`
+	AgentID string                 `json:"agent_id,omitempty"`
+	ID      string                 `json:"id,omitempty"`
+	Object  string                 `json:"object,omitempty"`
+	Created int64                  `json:"created,omitempty"`
+	Model   string                 `json:"model,omitempty"`
+	Choices []ChatCompletionChoice `json:"choices,omitempty"`
+	Usage   *TokenUsage            `json:"usage,omitempty"`
+
+	calls *CallRecorder // the call that produced this response, if any
+}
+
+// Metadata returns the request ID, latency and headers of the call that
+// produced a chat completion. It returns nil for responses served from the
+// response cache or decoded from elsewhere.
+func (r *APIResponse) Metadata() *CallMetadata {
+	if r.calls == nil {
+		return nil
+	}
+	md, ok := r.calls.Last()
+	if !ok {
+		return nil
+	}
+	return &md
+}
+
+// ChatCompletionChoice represents one of the n choices of a chat completion
+type ChatCompletionChoice struct {
+	Index        int                   `json:"index"`
+	FinishReason string                `json:"finish_reason"`
+	Message      ChatCompletionMessage `json:"message"`
+	Logprobs     *ChoiceLogprobs       `json:"logprobs,omitempty"`
+}
+
+// ChatCompletionMessage represents the assistant message of a completion choice
+type ChatCompletionMessage struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 }
 
 // FileResponse represents a file upload response
@@ -40,6 +72,9 @@ type FileResponse struct {
 	CreatedAt int64  `json:"created_at"`
 	Filename  string `json:"filename"`
 	Purpose   string `json:"purpose"`
+	// SHA256 is the hex SHA-256 of the uploaded content, computed by the
+	// client while uploading
+	SHA256 string `json:"-"`
 }
 
 // VectorStore represents a vector store
@@ -53,6 +88,8 @@ type VectorStore struct {
 	Status     string                 `json:"status"`
 	ExpiresAt  *int64                 `json:"expires_at,omitempty"`
 	LastUsedAt *int64                 `json:"last_used_at,omitempty"`
+	// ExpiresAfter is the expiration policy the store was created with
+	ExpiresAfter *VectorStoreExpiration `json:"expires_after,omitempty"`
 }
 
 // VectorStoreFile represents a file attached to a vector store
@@ -104,6 +141,11 @@ type AgentConfig struct {
 	EnableSessionPersistence bool            `json:"enable_session_persistence,omitempty"`
 	MaxInferIters            int             `json:"max_infer_iters,omitempty"`
 	Toolgroups               []interface{}   `json:"toolgroups,omitempty"`
+
+	// Fields from the server schema, typed by the generated Spec models
+	ClientTools    []SpecToolDef   `json:"client_tools,omitempty"`
+	ToolConfig     *SpecToolConfig `json:"tool_config,omitempty"`
+	ResponseFormat interface{}     `json:"response_format,omitempty"`
 }
 
 // SamplingParams represents the sampling parameters for the agent
@@ -132,6 +174,9 @@ type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
 	Name    string `json:"name,omitempty"`
+	// Parts, such as images, are sent after Content as a list of content
+	// parts; see ImageMessage
+	Parts []ContentPart `json:"-"`
 }
 
 // ChatCompletionParams represents the parameters for creating a chat completion
@@ -141,6 +186,20 @@ type ChatCompletionParams struct {
 	Temperature *float64  `json:"temperature,omitempty"`
 	MaxTokens   *int      `json:"max_tokens,omitempty"`
 	Stream      *bool     `json:"stream,omitempty"`
+	Logprobs    *bool     `json:"logprobs,omitempty"`
+	TopLogprobs *int      `json:"top_logprobs,omitempty"`
+
+	TopP                *float64 `json:"top_p,omitempty"`
+	TopK                *int     `json:"top_k,omitempty"` // not in the OpenAI schema; honored by providers that support it
+	Stop                []string `json:"stop,omitempty"`
+	Seed                *int     `json:"seed,omitempty"`
+	FrequencyPenalty    *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty     *float64 `json:"presence_penalty,omitempty"`
+	N                   *int     `json:"n,omitempty"` // number of choices to generate
+	MaxCompletionTokens *int     `json:"max_completion_tokens,omitempty"`
+	User                string   `json:"user,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 }
 
 // LlamaStackClient represents a client for the Llama Stack API
@@ -148,21 +207,155 @@ type LlamaStackClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
 	APIKey     string
+
+	// ProviderData is sent with every request in the provider-data header,
+	// e.g. search API keys; see WithWebSearchAPIKey
+	ProviderData map[string]interface{}
+
+	// StreamIdleTimeout aborts a streaming response with ErrStreamIdle when no
+	// data or heartbeat arrives for this long. Zero disables the check. Long
+	// agent turns usually also need HTTPClient.Timeout raised or disabled.
+	StreamIdleTimeout time.Duration
+
+	// EmbeddingCache, if set, lets CreateEmbeddings skip text it has embedded
+	// before with the same model
+	EmbeddingCache *EmbeddingCache
+
+	// ResponseCache, if set, lets CreateChatCompletion reuse responses to
+	// identical deterministic requests
+	ResponseCache *ResponseCache
+
+	// Ledger, if set, records created agents, sessions and files so the
+	// cleanup command can find them later
+	Ledger *ResourceLedger
+
+	// TokenCounter, if set, is calibrated with the prompt usage of every chat
+	// completion; see WithTokenCalibration
+	TokenCounter *TokenCounter
+
+	// Titles, if set, holds the titles AutoTitler generates for chats and
+	// agent sessions
+	Titles *TitleStore
+
+	// Budget, if set, rejects calls with a BudgetExceededError once its
+	// request, token or call time limits are used up
+	Budget *BudgetGuard
+
+	// DryRun prints every request to DryRunOutput (stdout when nil) instead
+	// of sending it and returns a synthetic response; see also WithDryRun
+	DryRun       bool
+	DryRunOutput io.Writer
+
+	// CurlOutput, if set, receives an equivalent curl command for every
+	// request, with secrets replaced by shell variables
+	CurlOutput io.Writer
+
+	// CaptureDir, if set, receives one file per request with the request and
+	// the full response, including SSE transcripts, with secrets redacted
+	CaptureDir string
+
+	// Logger receives call summaries, bodies at debug level and warnings;
+	// nothing is logged when nil. See WithLogger.
+	Logger *slog.Logger
+
+	// Replicas are base URLs of further servers behind the same stack as
+	// BaseURL. LoadBalance picks the endpoint for each request, and requests
+	// that cannot connect fail over to the next one. With HedgeDelay set, a
+	// read or inference request that has not been answered after HedgeDelay
+	// is also sent to the next endpoint; the first answer is used and the
+	// other request cancelled.
+	Replicas    []string
+	LoadBalance LoadBalancePolicy
+	HedgeDelay  time.Duration
+
+	// CompressRequestsOver, if positive, gzips JSON and text request bodies
+	// of at least this many bytes. Servers that refuse them with 415 get
+	// uncompressed bodies from then on. Gzipped responses are always
+	// decompressed.
+	CompressRequestsOver int
+
+	// Seed, if set, is sent with every chat and text completion that sets
+	// none; Deterministic also sends them with temperature 0. See WithSeed
+	// and WithDeterministic.
+	Seed          *int
+	Deterministic bool
+
+	// ModelProfiles, if set, fill in the parameters requests leave unset
+	// with the defaults of their model; see WithModelProfiles
+	ModelProfiles ModelProfiles
+
+	// Pricing, if set, estimates the cost of inference calls, which Costs
+	// adds up; see WithPricing
+	Pricing PricingTable
+	Costs   *CostTracker
+
+	pool        *endpointPool
+	compression *compressionState
+	inflight    *inflightCalls
+}
+
+// APIError is returned when the server answers with an unexpected status
+type APIError struct {
+	StatusCode int
+	Body       string
+	Header     http.Header
+	// RequestID is the server's X-Request-Id for the failed request, and
+	// CorrelationID the operation it belonged to; either may be empty
+	RequestID     string
+	CorrelationID string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, strings.TrimSpace(e.Body))
+	switch {
+	case e.RequestID != "" && e.CorrelationID != "":
+		msg += fmt.Sprintf(" (request %s, correlation %s)", e.RequestID, e.CorrelationID)
+	case e.RequestID != "":
+		msg += fmt.Sprintf(" (request %s)", e.RequestID)
+	case e.CorrelationID != "":
+		msg += fmt.Sprintf(" (correlation %s)", e.CorrelationID)
+	}
+	return msg
+}
+
+// Retryable reports whether the request may succeed if sent again
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// newAPIError builds an APIError from a failed response and its body
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Body: string(body), Header: resp.Header, RequestID: resp.Header.Get("X-Request-Id")}
+	if resp.Request != nil {
+		apiErr.CorrelationID = resp.Request.Header.Get(CorrelationIDHeader)
+	}
+	return apiErr
 }
 
 // NewLlamaStackClient creates a new Llama Stack client
-func NewLlamaStackClient(baseURL, apiKey string) *LlamaStackClient {
-	return &LlamaStackClient{
+func NewLlamaStackClient(baseURL, apiKey string, opts ...ClientOption) *LlamaStackClient {
+	c := &LlamaStackClient{
 		BaseURL: baseURL,
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		APIKey: apiKey,
+		APIKey:      apiKey,
+		pool:        newEndpointPool(),
+		compression: &compressionState{},
+		inflight:    newInflightCalls(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
-// UploadFile uploads a file to the Llama Stack API
-func (c *LlamaStackClient) UploadFile(ctx context.Context, filePath, purpose string) (*FileResponse, error) {
+// ClientOption configures a client in NewLlamaStackClient
+type ClientOption func(*LlamaStackClient)
+
+// UploadFile uploads a file to the Llama Stack API. Files the purpose does
+// not accept fail with ErrInvalidUpload before anything is sent.
+func (c *LlamaStackClient) UploadFile(ctx context.Context, filePath string, purpose FilePurpose) (*FileResponse, error) {
 	// Open the file
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -170,84 +363,194 @@ func (c *LlamaStackClient) UploadFile(ctx context.Context, filePath, purpose str
 	}
 	defer file.Close()
 
-	// Create a buffer to store the multipart form data
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-
-	// Create the file field
-	part, err := writer.CreateFormFile("file", filepath.Base(filePath))
+	info, err := file.Stat()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create form file: %w", err)
+		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
-
-	// Copy file content to the form field
-	_, err = io.Copy(part, file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to copy file content: %w", err)
+	if err := ValidateFileUpload(purpose, filepath.Base(filePath), info.Size()); err != nil {
+		return nil, err
 	}
 
-	// Add the purpose field
-	err = writer.WriteField("purpose", purpose)
-	if err != nil {
-		return nil, fmt.Errorf("failed to write purpose field: %w", err)
+	return c.UploadFileFromReader(ctx, filepath.Base(filePath), file, purpose)
+}
+
+// UploadFileFromReader uploads content read from r under the given filename,
+// validated like UploadFile. The content is streamed and hashed as it is
+// sent, not held in memory, and content over the purpose's size limit fails
+// the upload once the limit is passed. When r knows its size, as files and
+// bytes or strings readers do, the request gets a Content-Length; otherwise
+// it is sent chunked.
+func (c *LlamaStackClient) UploadFileFromReader(ctx context.Context, filename string, file io.Reader, purpose FilePurpose) (*FileResponse, error) {
+	size := readerSize(file)
+	if err := ValidateFileUpload(purpose, filename, size); err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		// Reject empty content before anything is sent
+		var first [1]byte
+		n, err := io.ReadFull(file, first[:])
+		if n == 0 {
+			if err != io.EOF {
+				return nil, fmt.Errorf("failed to read file content: %w", err)
+			}
+			return nil, ValidateFileUpload(purpose, filename, 0)
+		}
+		file = io.MultiReader(bytes.NewReader(first[:n]), file)
 	}
 
-	// Close the writer
-	err = writer.Close()
+	// Hash the content as the transport reads it, failing once it passes the
+	// limit
+	h := sha256.New()
+	limited := &uploadLimitReader{r: io.TeeReader(file, h), max: purpose.MaxBytes(), filename: filename, purpose: purpose}
+	var content io.Reader = limited
+	if size >= 0 {
+		content = io.LimitReader(content, size)
+	}
+	body, contentType, length, err := streamingFormBody("file", filename, progressBody(ctx, filename, content, size), size, map[string]string{"purpose": string(purpose)})
 	if err != nil {
-		return nil, fmt.Errorf("failed to close writer: %w", err)
+		return nil, err
 	}
 
-	// Create the request
 	url := c.BaseURL + "/v1/openai/v1/files"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, &buf)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.ContentLength = length
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	fmt.Println("=== REST CALL: Upload File ===")
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-	fmt.Printf("File: %s\n", filePath)
-	fmt.Printf("Purpose: %s\n", purpose)
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
+	if limited.err != nil {
+		// The server may have answered before the transport gave up
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return nil, limited.err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Response Body:\n%s\n", string(body))
-	fmt.Println("=== END REST CALL ===\n")
+	respBody, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	var response FileResponse
-	if err := json.Unmarshal(body, &response); err != nil {
+	if err := json.Unmarshal(respBody, &response); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	response.SHA256 = hex.EncodeToString(h.Sum(nil))
+	c.trackResource(ctx, ResourceFile, response.ID, "")
 
 	return &response, nil
 }
 
+// readerSize returns the number of bytes left in r when r can tell, or -1
+func readerSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }: // bytes.Buffer, bytes.Reader, strings.Reader
+		return int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+// uploadLimitReader fails the upload of filename once more than max bytes
+// have been read from r; err keeps the failure for after the request
+type uploadLimitReader struct {
+	r        io.Reader
+	n, max   int64
+	filename string
+	purpose  FilePurpose
+	err      error
+}
+
+func (l *uploadLimitReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		l.err = fmt.Errorf("%w: %s is over the %s limit for files with purpose %q", ErrInvalidUpload, l.filename, formatBytes(l.max), l.purpose)
+		return 0, l.err
+	}
+	return n, err
+}
+
+// streamingFormBody returns a multipart/form-data body that streams content
+// as the file field, followed by fields, with its content type and length.
+// The length is -1 when size, content's length, is unknown.
+func streamingFormBody(field, filename string, content io.Reader, size int64, fields map[string]string) (io.Reader, string, int64, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if _, err := writer.CreateFormFile(field, filename); err != nil {
+		return nil, "", 0, fmt.Errorf("failed to create form file: %w", err)
+	}
+	// Everything written from here on follows the file content
+	split := buf.Len()
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writer.WriteField(name, fields[name]); err != nil {
+			return nil, "", 0, fmt.Errorf("failed to write %s field: %w", name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", 0, fmt.Errorf("failed to close writer: %w", err)
+	}
+	head, tail := buf.Bytes()[:split], buf.Bytes()[split:]
+
+	length := int64(-1)
+	if size >= 0 {
+		length = int64(len(head)) + size + int64(len(tail))
+	}
+	return io.MultiReader(bytes.NewReader(head), content, bytes.NewReader(tail)), writer.FormDataContentType(), length, nil
+}
+
+// VectorStoreCreateParams represents parameters for creating a vector store
+type VectorStoreCreateParams struct {
+	Name               string                 `json:"name"`
+	Metadata           map[string]interface{} `json:"metadata"`
+	FileIDs            []string               `json:"file_ids,omitempty"`
+	ChunkingStrategy   map[string]interface{} `json:"chunking_strategy,omitempty"`
+	EmbeddingModel     string                 `json:"embedding_model,omitempty"` // server default when empty
+	EmbeddingDimension int                    `json:"embedding_dimension,omitempty"`
+	ProviderID         string                 `json:"provider_id,omitempty"`   // vector_io provider, e.g. faiss
+	ExpiresAfter       *VectorStoreExpiration `json:"expires_after,omitempty"` // e.g. ExpireAfterDays(7); never expires when nil
+}
+
 // CreateVectorStore creates a new vector store
 func (c *LlamaStackClient) CreateVectorStore(ctx context.Context, name string, metadata map[string]interface{}) (*VectorStore, error) {
-	payload := map[string]interface{}{
-		"name":     name,
-		"metadata": metadata,
-	}
+	return c.CreateVectorStoreWithParams(ctx, VectorStoreCreateParams{Name: name, Metadata: metadata})
+}
 
-	jsonData, err := json.Marshal(payload)
+// CreateVectorStoreWithParams creates a new vector store with a chosen
+// embedding model, provider, chunking strategy or expiration
+func (c *LlamaStackClient) CreateVectorStoreWithParams(ctx context.Context, params VectorStoreCreateParams) (*VectorStore, error) {
+	if params.ExpiresAfter != nil {
+		if err := params.ExpiresAfter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	params.Metadata = tagMetadata(params.Metadata)
+	jsonData, err := json.Marshal(params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal vector store params: %w", err)
 	}
@@ -261,27 +564,16 @@ func (c *LlamaStackClient) CreateVectorStore(ctx context.Context, name string, m
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	fmt.Println("=== REST CALL: Create Vector Store ===")
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-	fmt.Printf("Request Body:\n%s\n", string(jsonData))
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-
 	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Response Body:\n%s\n", string(body))
-	fmt.Println("=== END REST CALL ===\n")
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp, body)
 	}
 
 	var response VectorStore
@@ -294,9 +586,26 @@ func (c *LlamaStackClient) CreateVectorStore(ctx context.Context, name string, m
 
 // AttachFileToVectorStore attaches a file to a vector store
 func (c *LlamaStackClient) AttachFileToVectorStore(ctx context.Context, vectorStoreID, fileID string) (*VectorStoreFile, error) {
+	return c.AttachFileWithAttributes(ctx, vectorStoreID, fileID, nil)
+}
+
+// AttachFileWithAttributes attaches a file to a vector store with attributes
+// that searches can filter on, e.g. {"tenant": "acme", "year": 2024}
+func (c *LlamaStackClient) AttachFileWithAttributes(ctx context.Context, vectorStoreID, fileID string, attributes map[string]interface{}) (*VectorStoreFile, error) {
+	return c.attachFile(ctx, vectorStoreID, fileID, attributes, nil)
+}
+
+// attachFile attaches a file with optional attributes and chunking strategy
+func (c *LlamaStackClient) attachFile(ctx context.Context, vectorStoreID, fileID string, attributes map[string]interface{}, chunkingStrategy interface{}) (*VectorStoreFile, error) {
 	payload := map[string]interface{}{
 		"file_id": fileID,
 	}
+	if attributes != nil {
+		payload["attributes"] = attributes
+	}
+	if chunkingStrategy != nil {
+		payload["chunking_strategy"] = chunkingStrategy
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
@@ -312,27 +621,16 @@ func (c *LlamaStackClient) AttachFileToVectorStore(ctx context.Context, vectorSt
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	fmt.Println("=== REST CALL: Attach File to Vector Store ===")
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-	fmt.Printf("Request Body:\n%s\n", string(jsonData))
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-
 	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Response Body:\n%s\n", string(body))
-	fmt.Println("=== END REST CALL ===\n")
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp, body)
 	}
 
 	var response VectorStoreFile
@@ -360,34 +658,28 @@ func (c *LlamaStackClient) InsertDocumentsIntoRAG(ctx context.Context, params Ra
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 	req.Header.Set("Accept", "*/*")
 
-	fmt.Println("=== REST CALL: Insert Documents into RAG ===")
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-	fmt.Printf("Request Body:\n%s\n", string(jsonData))
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-
 	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Response Body:\n%s\n", string(body))
-	fmt.Println("=== END REST CALL ===\n")
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return newAPIError(resp, body)
 	}
 
 	return nil
 }
 
-// CreateAgent creates a new agent
+// CreateAgent validates the config and creates a new agent
 func (c *LlamaStackClient) CreateAgent(ctx context.Context, params AgentCreateParams) (*APIResponse, error) {
+	c.applyAgentProfile(&params.AgentConfig)
+	if err := params.AgentConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid agent config: %w", err)
+	}
+
 	jsonData, err := json.Marshal(params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal agent params: %w", err)
@@ -402,33 +694,23 @@ func (c *LlamaStackClient) CreateAgent(ctx context.Context, params AgentCreatePa
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	fmt.Println("=== REST CALL: Create Agent ===")
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-	fmt.Printf("Request Body:\n%s\n", string(jsonData))
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-
 	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Response Body:\n%s\n", string(body))
-	fmt.Println("=== END REST CALL ===\n")
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp, body)
 	}
 
 	var response APIResponse
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	c.trackResource(ctx, ResourceAgent, response.AgentID, "")
 
 	return &response, nil
 }
@@ -443,7 +725,7 @@ func (c *LlamaStackClient) DeleteAgent(ctx context.Context, agentID string) erro
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 	req.Header.Set("Accept", "*/*")
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to make request: %w", err)
 	}
@@ -451,19 +733,36 @@ func (c *LlamaStackClient) DeleteAgent(ctx context.Context, agentID string) erro
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return newAPIError(resp, body)
 	}
 
 	return nil
 }
 
 // CreateChatCompletion creates a chat completion (non-streaming)
+//
+// When the client has a ResponseCache, cacheable requests are answered from it
+// without a REST call; see BypassResponseCache and RefreshResponseCache.
 func (c *LlamaStackClient) CreateChatCompletion(ctx context.Context, params ChatCompletionParams) (*APIResponse, error) {
+	c.applyProfile(params.Model, &params.Temperature, &params.TopP, &params.MaxTokens, &params.Stop)
+	ctx = c.applySampling(ctx, &params.Seed, &params.Temperature)
+	cache := c.ResponseCache
+	if cache != nil && (responseCacheModeOf(ctx) == responseCacheBypass || !cache.cacheable(params) || c.dryRunning(ctx) || c.baseURL(ctx) != c.BaseURL) {
+		cache = nil
+	}
+	if cache != nil && responseCacheModeOf(ctx) != responseCacheRefresh {
+		if response, ok := cache.Get(params); ok {
+			c.logger().InfoContext(ctx, "response cache hit", "endpoint", "/v1/openai/v1/chat/completions", "model", params.Model)
+			return response, nil
+		}
+	}
+
 	jsonData, err := json.Marshal(params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal chat completion params: %w", err)
 	}
 
+	ctx, calls := WithCallRecorder(ctx)
 	url := c.BaseURL + "/v1/openai/v1/chat/completions"
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -473,27 +772,16 @@ func (c *LlamaStackClient) CreateChatCompletion(ctx context.Context, params Chat
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	fmt.Println("=== REST CALL: Create Chat Completion ===")
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-	fmt.Printf("Request Body:\n%s\n", string(jsonData))
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-
 	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Response Body:\n%s\n", string(body))
-	fmt.Println("=== END REST CALL ===\n")
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp, body)
 	}
 
 	var response APIResponse
@@ -501,65 +789,61 @@ func (c *LlamaStackClient) CreateChatCompletion(ctx context.Context, params Chat
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	if c.TokenCounter != nil && response.Usage != nil {
+		c.TokenCounter.CalibrateMessages(params.Model, params.Messages, response.Usage.PromptTokens)
+	}
+	if cache != nil {
+		// A cache that cannot be written only costs a repeated call later
+		cached := response
+		cache.Put(params, &cached)
+	}
+	response.calls = calls
 	return &response, nil
 }
 
-// CreateStreamingChatCompletion creates a streaming chat completion
+// CreateStreamingChatCompletion creates a streaming chat completion.
+//
+// The returned channel is closed when the stream ends, when an error occurs,
+// or when ctx is done. Callers that stop reading before the channel is closed
+// must cancel ctx; the reader goroutine then exits and closes the response
+// body, so no goroutine or connection is leaked.
 func (c *LlamaStackClient) CreateStreamingChatCompletion(ctx context.Context, params ChatCompletionParams) (<-chan string, error) {
-	// Set streaming to true
-	stream := true
-	params.Stream = &stream
-
-	jsonData, err := json.Marshal(params)
+	resp, err := c.openChatCompletionStream(ctx, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal chat completion params: %w", err)
-	}
-
-	url := c.BaseURL + "/v1/openai/v1/chat/completions"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.APIKey)
-
-	fmt.Println("=== REST CALL: Create Streaming Chat Completion ===")
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-	fmt.Printf("Request Body:\n%s\n", string(jsonData))
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-	fmt.Println("=== END REST CALL ===\n")
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
 
 	// Create channel for streaming responses
 	ch := make(chan string)
 
+	// Close the body as soon as ctx is done so a read blocked on a silent
+	// server returns immediately instead of waiting for the next chunk.
+	stop := context.AfterFunc(ctx, func() {
+		resp.Body.Close()
+	})
+
 	go func() {
+		defer stop()
 		defer resp.Body.Close()
 		defer close(ch)
 
+		send := func(s string) bool {
+			select {
+			case ch <- s:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
 		reader := bufio.NewReader(resp.Body)
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
-				if err == io.EOF {
+				if err == io.EOF || ctx.Err() != nil {
 					break
 				}
-				ch <- fmt.Sprintf("Error reading stream: %v", err)
+				send(fmt.Sprintf("Error reading stream: %v", err))
 				return
 			}
 
@@ -578,24 +862,62 @@ func (c *LlamaStackClient) CreateStreamingChatCompletion(ctx context.Context, pa
 				break
 			}
 
-			ch <- line
+			if !send(line) {
+				return
+			}
 		}
 	}()
 
 	return ch, nil
 }
 
+// openChatCompletionStream sends a streaming chat completion request and returns
+// the response once the server has accepted it. The caller owns resp.Body.
+func (c *LlamaStackClient) openChatCompletionStream(ctx context.Context, params ChatCompletionParams) (*http.Response, error) {
+	// Set streaming to true
+	stream := true
+	params.Stream = &stream
+	c.applyProfile(params.Model, &params.Temperature, &params.TopP, &params.MaxTokens, &params.Stop)
+	ctx = c.applySampling(ctx, &params.Seed, &params.Temperature)
+
+	jsonData, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat completion params: %w", err)
+	}
+
+	url := c.BaseURL + "/v1/openai/v1/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.doStream(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, newAPIError(resp, body)
+	}
+
+	return resp, nil
+}
+
 // Model represents a model from the API
 type Model struct {
-	Identifier string `json:"identifier"`
-	ModelType  string `json:"model_type"`
-	Name       string `json:"name,omitempty"`
+	Identifier string                 `json:"identifier"`
+	ModelType  string                 `json:"model_type"`
+	Name       string                 `json:"name,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"` // e.g. embedding_dimension for embedding models
 }
 
 // ListModelsResponse represents the response from listing models
-type ListModelsResponse struct {
-	Data []Model `json:"data"`
-}
+type ListModelsResponse = Page[Model]
 
 // ListModels lists available models
 func (c *LlamaStackClient) ListModels(ctx context.Context) (*ListModelsResponse, error) {
@@ -606,7 +928,7 @@ func (c *LlamaStackClient) ListModels(ctx context.Context) (*ListModelsResponse,
 
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -614,7 +936,7 @@ func (c *LlamaStackClient) ListModels(ctx context.Context) (*ListModelsResponse,
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp, body)
 	}
 
 	var response ListModelsResponse
@@ -625,6 +947,39 @@ func (c *LlamaStackClient) ListModels(ctx context.Context) (*ListModelsResponse,
 	return &response, nil
 }
 
+// Health checks that the server is up and ready to serve requests
+func (c *LlamaStackClient) Health(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/v1/health", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp, body)
+	}
+
+	var response struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !strings.EqualFold(response.Status, "ok") {
+		return fmt.Errorf("server reported status %q", response.Status)
+	}
+
+	return nil
+}
+
 // GetAvailableModel gets the first available LLM model
 func (c *LlamaStackClient) GetAvailableModel(ctx context.Context) (string, error) {
 	models, err := c.ListModels(ctx)
@@ -655,6 +1010,8 @@ type Session struct {
 	AgentID     string `json:"agent_id"`
 	SessionName string `json:"session_name"`
 	CreatedAt   int64  `json:"created_at"`
+	StartedAt   string `json:"started_at,omitempty"`
+	Turns       []Turn `json:"turns,omitempty"` // only filled in by GetSession
 }
 
 // SessionCreateParams represents parameters for creating a session
@@ -664,21 +1021,21 @@ type SessionCreateParams struct {
 
 // Turn represents a turn in an agent session
 type Turn struct {
-	TurnID            string        `json:"turn_id"`
-	SessionID         string        `json:"session_id"`
-	InputMessages     []Message     `json:"input_messages"`
-	OutputMessage     Message       `json:"output_message"`
-	Steps             []interface{} `json:"steps"`
-	StartedAt         string        `json:"started_at"`
-	CompletedAt       *string       `json:"completed_at,omitempty"`
-	OutputAttachments []interface{} `json:"output_attachments,omitempty"`
+	TurnID            string         `json:"turn_id"`
+	SessionID         string         `json:"session_id"`
+	InputMessages     []Message      `json:"input_messages"`
+	OutputMessage     Message        `json:"output_message"`
+	Steps             []interface{}  `json:"steps"`
+	StartedAt         string         `json:"started_at"`
+	CompletedAt       *string        `json:"completed_at,omitempty"`
+	OutputAttachments []TurnDocument `json:"output_attachments,omitempty"`
 }
 
 // TurnCreateParams represents parameters for creating a turn
 type TurnCreateParams struct {
-	Messages   []Message  `json:"messages"`
-	Stream     *bool      `json:"stream,omitempty"`
-	Documents  []Document `json:"documents,omitempty"`
+	Messages   []Message      `json:"messages"`
+	Stream     *bool          `json:"stream,omitempty"`
+	Documents  []TurnDocument `json:"documents,omitempty"`
 	ToolConfig *struct {
 		ToolChoice string `json:"tool_choice,omitempty"`
 	} `json:"tool_config,omitempty"`
@@ -687,13 +1044,30 @@ type TurnCreateParams struct {
 
 // RagToolQueryParams represents parameters for RAG tool query
 type RagToolQueryParams struct {
-	Content     string   `json:"content"`
-	VectorDBIDs []string `json:"vector_db_ids"`
-	QueryConfig *struct {
-		MaxChunks          int    `json:"max_chunks"`
-		MaxTokensInContext int    `json:"max_tokens_in_context"`
-		Mode               string `json:"mode"`
-	} `json:"query_config,omitempty"`
+	Content     string          `json:"content"`
+	VectorDBIDs []string        `json:"vector_db_ids"`
+	QueryConfig *RAGQueryConfig `json:"query_config,omitempty"`
+}
+
+// RAGQueryConfig represents how the RAG tool retrieves chunks
+type RAGQueryConfig struct {
+	MaxChunks          int           `json:"max_chunks"`
+	MaxTokensInContext int           `json:"max_tokens_in_context"`
+	Mode               RAGSearchMode `json:"mode,omitempty"`
+	Ranker             *Ranker       `json:"ranker,omitempty"` // hybrid mode only
+
+	// ChunkTemplate formats each chunk; it must contain {index} and
+	// {chunk.content}. Empty uses the server's default template.
+	ChunkTemplate        string                 `json:"chunk_template,omitempty"`
+	QueryGeneratorConfig map[string]interface{} `json:"query_generator_config,omitempty"`
+
+	// Rewrite turns the query into several before retrieval. It is applied by
+	// QueryRAG and QueryRAGMulti and never sent to the server.
+	Rewrite *QueryRewrite `json:"-"`
+	// Filter keeps only chunks whose metadata matches. The rag-tool query has
+	// no server-side filter, so QueryRAG applies it to the returned chunks;
+	// raise MaxChunks to leave enough after filtering.
+	Filter *Filter `json:"-"`
 }
 
 // QueryResult represents the result of a RAG query
@@ -718,39 +1092,66 @@ func (c *LlamaStackClient) CreateSession(ctx context.Context, agentID string, pa
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	fmt.Println("=== REST CALL: Create Session ===")
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-	fmt.Printf("Request Body:\n%s\n", string(jsonData))
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-
 	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Response Body:\n%s\n", string(body))
-	fmt.Println("=== END REST CALL ===\n")
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp, body)
 	}
 
 	var response Session
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	c.trackResource(ctx, ResourceSession, response.SessionID, agentID)
 
 	return &response, nil
 }
 
-// CreateTurn creates a new turn for an agent session (supports streaming SSE)
+// CreateTurn creates a new turn for an agent session. With params.Stream set it
+// consumes the SSE stream until turn_complete; otherwise it decodes the plain
+// JSON turn. Both modes return the same completed Turn.
 func (c *LlamaStackClient) CreateTurn(ctx context.Context, agentID, sessionID string, params TurnCreateParams) (*Turn, error) {
+	resp, err := c.openTurn(ctx, agentID, sessionID, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	streaming := params.Stream != nil && *params.Stream
+	if streaming || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// Parse SSE events
+		turn, err := parseAgentTurnSSE(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SSE: %w", err)
+		}
+		return turn, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var turn Turn
+	if err := json.Unmarshal(body, &turn); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if turn.TurnID == "" {
+		return nil, fmt.Errorf("response did not contain a turn: %s", string(body))
+	}
+
+	return &turn, nil
+}
+
+// openTurn sends a create turn request and returns the response once the
+// server has accepted it. The caller owns resp.Body.
+func (c *LlamaStackClient) openTurn(ctx context.Context, agentID, sessionID string, params TurnCreateParams) (*http.Response, error) {
 	jsonData, err := json.Marshal(params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal turn params: %w", err)
@@ -765,162 +1166,115 @@ func (c *LlamaStackClient) CreateTurn(ctx context.Context, agentID, sessionID st
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	fmt.Println("=== REST CALL: Create Turn (Streaming) ===")
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-	fmt.Printf("Request Body:\n%s\n", string(jsonData))
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.doStream(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
-	// Do not defer resp.Body.Close() here, as we need to stream
-
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-
+	// Do not close resp.Body here, as the caller needs to stream it
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp, body)
 	}
 
-	// Parse SSE events
-	turn, err := parseAgentTurnSSE(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse SSE: %w", err)
-	}
-
-	return turn, nil
+	return resp, nil
 }
 
-// parseAgentTurnSSE parses the SSE stream and returns the final Turn when turn_complete is received
+// parseAgentTurnSSE parses the SSE stream and returns the final Turn when turn_complete is received.
+// Malformed events fail with a *StreamDecodeError instead of being skipped.
 func parseAgentTurnSSE(body io.Reader) (*Turn, error) {
-	scanner := bufio.NewScanner(body)
-	var turn Turn
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "data: ") {
-			jsonPart := strings.TrimPrefix(line, "data: ")
-			var sse struct {
-				Event struct {
-					Payload struct {
-						EventType string `json:"event_type"`
-						Turn      *Turn  `json:"turn,omitempty"`
-						// For step_progress, etc, you could add more fields if needed
-					} `json:"payload"`
-				} `json:"event"`
-			}
-			err := json.Unmarshal([]byte(jsonPart), &sse)
-			if err != nil {
-				fmt.Printf("[SSE] Failed to parse event: %v\n", err)
-				continue
-			}
-			if sse.Event.Payload.EventType == "turn_complete" && sse.Event.Payload.Turn != nil {
-				turn = *sse.Event.Payload.Turn
-				break
-			}
+	var turn *Turn
+	var decodeErr error
+	err := readSSE(body, func(ev sseEvent) bool {
+		var sse struct {
+			Event struct {
+				Payload TurnEvent `json:"payload"`
+			} `json:"event"`
+		}
+		if err := decodeSSEData(ev.Data, &sse); err != nil {
+			decodeErr = err
+			return false
 		}
+		if sse.Event.Payload.EventType != "turn_complete" {
+			return true
+		}
+		if sse.Event.Payload.Turn == nil || sse.Event.Payload.Turn.TurnID == "" {
+			decodeErr = newStreamDecodeError(ev.Data, errors.New("turn_complete event without a turn"))
+			return false
+		}
+		turn = sse.Event.Payload.Turn
+		return false
+	})
+	if decodeErr != nil {
+		return nil, decodeErr
 	}
-	if err := scanner.Err(); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("scanner error: %w", err)
 	}
-	if turn.TurnID == "" {
-		return nil, fmt.Errorf("no turn_complete event received")
+	if turn == nil {
+		return nil, fmt.Errorf("no turn_complete event received: %w", ErrStreamInterrupted)
 	}
-	return &turn, nil
+	return turn, nil
 }
 
-// QueryRAG queries the RAG system for context
-func (c *LlamaStackClient) QueryRAG(ctx context.Context, params RagToolQueryParams) (*QueryResult, error) {
-	jsonData, err := json.Marshal(params)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal RAG query params: %w", err)
-	}
+// AgentStepResponse represents the response from retrieving a single turn step
+type AgentStepResponse struct {
+	Step map[string]interface{} `json:"step"`
+}
 
-	url := c.BaseURL + "/v1/tool-runtime/rag-tool/query"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+// GetTurn retrieves a turn, e.g. to recover the state of an interrupted agentic loop
+func (c *LlamaStackClient) GetTurn(ctx context.Context, agentID, sessionID, turnID string) (*Turn, error) {
+	url := fmt.Sprintf("%s/v1/agents/%s/session/%s/turn/%s", c.BaseURL, agentID, sessionID, turnID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	fmt.Println("=== REST CALL: Query RAG ===")
-	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-	fmt.Printf("Request Body:\n%s\n", string(jsonData))
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-
 	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Response Body:\n%s\n", string(body))
-	fmt.Println("=== END REST CALL ===\n")
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp, body)
 	}
 
-	var response QueryResult
-	if err := json.Unmarshal(body, &response); err != nil {
+	var turn Turn
+	if err := json.Unmarshal(body, &turn); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return &response, nil
-}
-
-// ListFilesResponse represents the response from listing files
-type ListFilesResponse struct {
-	Data    []FileResponse `json:"data"`
-	FirstID string         `json:"first_id"`
-	HasMore bool           `json:"has_more"`
-	LastID  string         `json:"last_id"`
-	Object  string         `json:"object"`
+	return &turn, nil
 }
 
-// ListFiles lists uploaded files
-func (c *LlamaStackClient) ListFiles(ctx context.Context) (*ListFilesResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/v1/openai/v1/files", nil)
+// GetStep retrieves a single step of a turn
+func (c *LlamaStackClient) GetStep(ctx context.Context, agentID, sessionID, turnID, stepID string) (*AgentStepResponse, error) {
+	url := fmt.Sprintf("%s/v1/agents/%s/session/%s/turn/%s/step/%s", c.BaseURL, agentID, sessionID, turnID, stepID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	fmt.Println("=== REST CALL: List Files ===")
-	fmt.Printf("URL: %s\n", req.URL)
-	fmt.Printf("Method: %s\n", req.Method)
-	fmt.Printf("Headers: %v\n", req.Header)
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("Response Status: %s\n", resp.Status)
-	fmt.Printf("Response Headers: %v\n", resp.Header)
-
 	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Response Body:\n%s\n", string(body))
-	fmt.Println("=== END REST CALL ===\n")
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp, body)
 	}
 
-	var response ListFilesResponse
+	var response AgentStepResponse
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
@@ -928,13 +1282,93 @@ func (c *LlamaStackClient) ListFiles(ctx context.Context) (*ListFilesResponse, e
 	return &response, nil
 }
 
-// Example usage functions
-func exampleCreateAgent(client *LlamaStackClient) {
-	ctx := context.Background()
-
-	selectedModel := "ollama/llama3.2:3b"
-	fmt.Printf("Using model: %s\n", selectedModel)
-
+// QueryRAG queries the RAG system for context
+func (c *LlamaStackClient) QueryRAG(ctx context.Context, params RagToolQueryParams) (*QueryResult, error) {
+	if params.QueryConfig != nil {
+		if err := params.QueryConfig.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid query config: %w", err)
+		}
+	}
+	if params.QueryConfig != nil && params.QueryConfig.Rewrite != nil {
+		return c.queryRAGRewritten(ctx, params)
+	}
+
+	jsonData, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal RAG query params: %w", err)
+	}
+
+	url := c.BaseURL + "/v1/tool-runtime/rag-tool/query"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, body)
+	}
+
+	var response QueryResult
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if params.QueryConfig != nil && params.QueryConfig.Filter != nil {
+		return filterQueryResult(&response, *params.QueryConfig.Filter), nil
+	}
+	return &response, nil
+}
+
+// ListFilesResponse represents the response from listing files
+type ListFilesResponse = Page[FileResponse]
+
+// ListFiles lists uploaded files
+func (c *LlamaStackClient) ListFiles(ctx context.Context) (*ListFilesResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/v1/openai/v1/files", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, body)
+	}
+
+	var response ListFilesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// Example usage functions
+func exampleCreateAgent(client *LlamaStackClient) {
+	ctx := context.Background()
+
+	selectedModel := "ollama/llama3.2:3b"
+	fmt.Printf("Using model: %s\n", selectedModel)
+
 	// Create agent configuration with required instructions and fields matching TypeScript example
 	temperature := 1.0
 	topP := 0.9
@@ -1035,73 +1469,76 @@ func exampleChatCompletion(client *LlamaStackClient, userPrompt string) {
 	}
 }
 
-func exampleStreamingChatCompletion(client *LlamaStackClient, userPrompt string) {
-	ctx := context.Background()
+// exampleStreamingChatCompletion streams an answer, rendering its markdown on
+// a terminal unless raw is set, and passes it to hooks sentence by sentence
+func exampleStreamingChatCompletion(client *LlamaStackClient, userPrompt string, raw bool, hooks ...OutputHook) {
+	// Cancel on return so the reader goroutine exits even if we stop early
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	selectedModel := "ollama/llama3.2:3b"
 	fmt.Printf("Using model: %s\n", selectedModel)
 
-	// Create streaming chat completion parameters
-	params := ChatCompletionParams{
-		Model: selectedModel,
-		Messages: []Message{
-			{
-				Role:    "system",
-				Content: "You are a helpful assistant.",
-			},
-			{
-				Role:    "user",
-				Content: userPrompt,
-			},
+	messages := []Message{
+		{
+			Role:    "system",
+			Content: "You are a helpful assistant.",
+		},
+		{
+			Role:    "user",
+			Content: userPrompt,
 		},
-	}
-
-	stream, err := client.CreateStreamingChatCompletion(ctx, params)
-	if err != nil {
-		fmt.Printf("Error creating streaming chat completion: %v\n", err)
-		return
 	}
 
 	fmt.Println("Streaming response:")
-	for chunk := range stream {
-		fmt.Print(chunk)
+	if _, err := streamReplReply(ctx, client, selectedModel, messages, raw, hooks...); err != nil {
+		fmt.Printf("Error streaming chat completion: %v\n", err)
 	}
-	fmt.Println()
 }
 
-// New function: Example PDF upload and RAG workflow
-func examplePDFUploadAndRAG(client *LlamaStackClient, pdfPath string) {
-	ctx := context.Background()
+// New function: Example PDF upload and RAG workflow. It returns the ID of
+// the vector store it created, or "" if the workflow failed before that.
+func examplePDFUploadAndRAG(client *LlamaStackClient, pdfPath, storeName string, expireDays int) string {
+	// One correlation ID ties the upload, vector store and query calls together
+	ctx := WithCorrelationID(context.Background(), NewCorrelationID())
 
 	fmt.Println("=== PDF Upload and RAG Workflow ===")
+	fmt.Printf("Correlation ID: %s\n", CorrelationID(ctx))
 
 	// Step 1: Upload the PDF file
 	fmt.Println("Step 1: Uploading PDF file...")
-	fileResponse, err := client.UploadFile(ctx, pdfPath, "assistants")
+	fileResponse, err := client.UploadFile(ctx, pdfPath, FilePurposeAssistants)
 	if err != nil {
 		fmt.Printf("Error uploading file: %v\n", err)
-		return
+		return ""
 	}
 	fmt.Printf("File uploaded successfully! File ID: %s\n", fileResponse.ID)
 
 	// Step 2: Create a vector store
 	fmt.Println("Step 2: Creating vector store...")
-	vectorStore, err := client.CreateVectorStore(ctx, "my-documents", map[string]interface{}{
-		"description": "Vector store for PDF documents",
-		"source":      "go-client",
-	})
+	params := VectorStoreCreateParams{
+		Name: storeName,
+		Metadata: map[string]interface{}{
+			"description": "Vector store for PDF documents",
+			"source":      "go-client",
+		},
+	}
+	if expireDays > 0 {
+		params.ExpiresAfter = ExpireAfterDays(expireDays)
+	}
+	vectorStore, err := client.CreateVectorStoreWithParams(ctx, params)
 	if err != nil {
 		fmt.Printf("Error creating vector store: %v\n", err)
-		return
+		return ""
 	}
-	fmt.Printf("Vector store created successfully! Vector Store ID: %s\n", vectorStore.ID)
+	fmt.Printf("Vector store created successfully! Vector Store ID: %s (%s)\n", vectorStore.ID, vectorStoreExpiry(*vectorStore, time.Now()))
 
 	// Step 3: Attach the file to the vector store
 	fmt.Println("Step 3: Attaching file to vector store...")
-	vectorStoreFile, err := client.AttachFileToVectorStore(ctx, vectorStore.ID, fileResponse.ID)
+	vectorStoreFile, err := client.AttachFileWithAttributes(ctx, vectorStore.ID, fileResponse.ID, checksumAttributes(nil, fileResponse))
 	if err != nil {
 		fmt.Printf("Error attaching file to vector store: %v\n", err)
-		return
+		return vectorStore.ID
 	}
 	fmt.Printf("File attached successfully! Status: %s\n", vectorStoreFile.Status)
 
@@ -1131,54 +1568,76 @@ func examplePDFUploadAndRAG(client *LlamaStackClient, pdfPath string) {
 	err = client.InsertDocumentsIntoRAG(ctx, ragParams)
 	if err != nil {
 		fmt.Printf("Error inserting documents into RAG: %v\n", err)
-		return
+		return vectorStore.ID
 	}
 	fmt.Println("Documents inserted into RAG system successfully!")
 
 	fmt.Println("=== PDF Upload and RAG Workflow Completed ===")
+	return vectorStore.ID
 }
 
-// New function: Agent-based chat with RAG
-func exampleAgentChatWithRAG(client *LlamaStackClient, userPrompt string) {
-	ctx := context.Background()
-
-	fmt.Println("=== Agent Chat with RAG (Agentic Loop) ===")
-
-	selectedModel := "ollama/llama3.2:3b"
-	fmt.Printf("Using model: %s\n", selectedModel)
-
-	// Step 1: Create an agent with RAG toolgroups
-	fmt.Println("Step 1: Creating agent with RAG capabilities...")
+// defaultRAGAgentConfig returns the demo RAG agent searching vectorDBIDs
+func defaultRAGAgentConfig(model string, vectorDBIDs ...string) (AgentConfig, error) {
 	temperature := 1.0
 	topP := 0.9
 	maxInferIters := 10
 
-	agentConfig := AgentConfig{
-		Instructions: "You are a helpful assistant that can access documents through RAG tools. When asked about documents, use the RAG tools to find relevant information.",
-		Model:        selectedModel,
-		Name:         "RAG Agent",
-		Description:  "An agent with RAG capabilities",
-		SamplingParams: &SamplingParams{
+	return NewAgentConfig(model).
+		Instructions("You are a helpful assistant that can access documents through RAG tools. When asked about documents, use the RAG tools to find relevant information.").
+		Name("RAG Agent").
+		Description("An agent with RAG capabilities").
+		Sampling(SamplingParams{
 			Strategy: SamplingStrategy{
 				Type:        "top_p",
 				Temperature: &temperature,
 				TopP:        &topP,
 			},
-		},
-		ToolChoice:               "auto",
-		ToolPromptFormat:         "python_list",
-		InputShields:             []string{},
-		OutputShields:            []string{},
-		EnableSessionPersistence: false,
-		MaxInferIters:            maxInferIters,
-		Toolgroups: []interface{}{
-			map[string]interface{}{
-				"name": "builtin::rag",
-				"args": map[string]interface{}{
-					"vector_db_ids": []string{"my-documents"},
-				},
-			},
-		},
+		}).
+		ToolChoice("auto").
+		ToolPromptFormat("python_list").
+		MaxInferIters(maxInferIters).
+		WithRAG(vectorDBIDs...).
+		Build()
+}
+
+// New function: Agent-based chat with RAG over the vector DB vectorDBID. A
+// non-nil preset replaces the default agent config and names the vector DBs
+// itself.
+func exampleAgentChatWithRAG(client *LlamaStackClient, userPrompt string, preset *AgentConfig, vectorDBID string) {
+	ctx := WithCorrelationID(context.Background(), NewCorrelationID())
+
+	fmt.Println("=== Agent Chat with RAG (Agentic Loop) ===")
+	fmt.Printf("Correlation ID: %s\n", CorrelationID(ctx))
+
+	selectedModel := "ollama/llama3.2:3b"
+	if preset != nil {
+		selectedModel = preset.Model
+	}
+	fmt.Printf("Using model: %s\n", selectedModel)
+
+	// Step 1: Create an agent with RAG toolgroups
+	fmt.Println("Step 1: Creating agent with RAG capabilities...")
+	var agentConfig AgentConfig
+	if preset != nil {
+		agentConfig = *preset
+	} else {
+		var err error
+		agentConfig, err = defaultRAGAgentConfig(selectedModel, vectorDBID)
+		if err != nil {
+			fmt.Printf("Invalid agent config: %v\n", err)
+			return
+		}
+	}
+
+	// Catch unknown models, toolgroups and shields before creating the agent
+	if err := client.ValidateAgentConfig(ctx, agentConfig); err != nil {
+		fmt.Printf("Agent config does not match the server: %v\n", err)
+		return
+	}
+	// knowledge_search calls search the vector DBs the agent was given
+	vectorDBIDs := RAGVectorDBIDs(agentConfig)
+	if len(vectorDBIDs) > 0 {
+		fmt.Printf("Searching vector DBs: %s\n", strings.Join(vectorDBIDs, ", "))
 	}
 
 	params := AgentCreateParams{
@@ -1209,222 +1668,329 @@ func exampleAgentChatWithRAG(client *LlamaStackClient, userPrompt string) {
 	sessionID := session.SessionID
 	fmt.Printf("Session created successfully! Session ID: %s\n", sessionID)
 
-	// Step 3: Create a turn with the user prompt (streaming)
+	// Step 3: Create a turn with the user prompt and run the agentic loop,
+	// remembering which chunks the answer was built from
 	fmt.Println("Step 3: Creating turn with user prompt (streaming)...")
-	// turnParams := TurnCreateParams{ ... } // REMOVE this line, now handled in initParams
+	var citations CitationTracker
+	// LLAMASTACK_MODERATION=block|annotate checks the prompt first
+	moderator, err := newModerator(client, os.Getenv("LLAMASTACK_MODERATION"), os.Getenv("LLAMASTACK_MODERATION_MODEL"))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if moderator != nil {
+		moderator.OnFlagged = func(result ModerationResult) {
+			fmt.Printf("Prompt flagged by moderation: %s; sending it anyway\n", result.Summary())
+		}
+	}
+	runner := AgentRunner{
+		Client: client,
+		Tools: map[string]AgentToolHandler{
+			"knowledge_search": func(ctx context.Context, call AgentToolCall) (string, error) {
+				return ragToolHandler(ctx, client, vectorDBIDs, &citations, call)
+			},
+		},
+		// A RAG query answered with a 503 or dropped is tried again
+		ToolRetry: ToolRetryPolicy{MaxRetries: 2},
+		// Keep retrieved chunks from crowding the small model's context
+		ToolResultTransformer: TruncateToolResults(agentConfig.Model, 2000),
+		Moderator:             moderator,
+		Progress:              NewTurnProgress(os.Stderr, isTerminal(os.Stderr)),
+	}
+	finalTurn, err := runner.Run(ctx, agentID, sessionID, []Message{{Role: "user", Content: userPrompt}})
+	var aborted *LoopAborted
+	if errors.As(err, &aborted) {
+		fmt.Printf("Agent loop stopped (%s): %v\n", aborted.Reason, aborted)
+		return
+	}
+	if isModerationBlocked(err) {
+		fmt.Printf("Warning: %v; the prompt was not sent\n", err)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error running agent turn: %v\n", err)
+		return
+	}
 
-	// Start the agentic loop
-	agenticLoop := func(agentID, sessionID string, turnParams map[string]interface{}) {
-		turnID := ""
-		for {
-			url := fmt.Sprintf("%s/v1/agents/%s/session/%s/turn", client.BaseURL, agentID, sessionID)
-			if turnID != "" {
-				url = fmt.Sprintf("%s/v1/agents/%s/session/%s/turn/%s/resume", client.BaseURL, agentID, sessionID, turnID)
-			}
+	// LLAMASTACK_TRANSCRIPT=text|json prints every step of the turn first
+	if format := os.Getenv("LLAMASTACK_TRANSCRIPT"); format != "" {
+		fmt.Println("\n=== Agent Turn Transcript ===")
+		if err := writeTranscript(os.Stdout, NewTurnTranscript(finalTurn), format == "json"); err != nil {
+			fmt.Printf("Error writing transcript: %v\n", err)
+		}
+	}
+	fmt.Printf("\n=== Agent Final Response ===\n%s\n", finalTurn.OutputMessage.Content)
+	citations.Add(CitationsFromTurn(finalTurn)...)
+	if cited := citations.Citations(); len(cited) > 0 {
+		answer := CitedAnswer{Answer: finalTurn.OutputMessage.Content, Citations: cited}
+		fmt.Printf("\n=== Agent Response with Citations ===\n%s", answer.Render(CitationRenderOptions{InlineMarkers: true, SourceList: true}))
+	}
+	fmt.Println("=== Agent Chat with RAG Completed ===")
+}
 
-			jsonData, err := json.Marshal(turnParams)
-			if err != nil {
-				fmt.Printf("Failed to marshal turn params: %v\n", err)
-				return
-			}
+// exampleAgentWebSearch asks an agent that searches the web with the server's
+// builtin::websearch toolgroup, then lists the pages it found
+func exampleAgentWebSearch(client *LlamaStackClient, prompt string) {
+	ctx := WithCorrelationID(context.Background(), NewCorrelationID())
+	fmt.Println("=== Agent Chat with Web Search ===")
 
-			method := "POST"
-			req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(jsonData))
-			if err != nil {
-				fmt.Printf("Failed to create request: %v\n", err)
-				return
-			}
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Authorization", "Bearer "+client.APIKey)
+	model, err := client.GetAvailableModel(ctx)
+	if err != nil {
+		fmt.Printf("Error finding a model: %v\n", err)
+		return
+	}
 
-			fmt.Println("=== REST CALL: Agent Turn (Streaming) ===")
-			fmt.Printf("URL: %s\n", url)
-			fmt.Printf("Method: %s\n", req.Method)
-			fmt.Printf("Headers: %v\n", req.Header)
-			fmt.Printf("Request Body:\n%s\n", string(jsonData))
+	agentConfig, err := NewAgentConfig(model).
+		Instructions("Search the web to answer questions about current events. Cite the pages you used.").
+		WithWebSearch().
+		ToolChoice("auto").
+		Build()
+	if err != nil {
+		fmt.Printf("Invalid agent config: %v\n", err)
+		return
+	}
+	agent, err := client.CreateAgent(ctx, AgentCreateParams{AgentConfig: agentConfig})
+	if err != nil {
+		fmt.Printf("Error creating agent: %v\n", err)
+		return
+	}
+	session, err := client.CreateSession(ctx, agent.AgentID, SessionCreateParams{SessionName: "web-search-session"})
+	if err != nil {
+		fmt.Printf("Error creating session: %v\n", err)
+		return
+	}
+	turn, err := client.CreateTurn(ctx, agent.AgentID, session.SessionID, TurnCreateParams{
+		Messages: []Message{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		fmt.Printf("Error creating turn (is a search API key set?): %v\n", err)
+		return
+	}
 
-			resp, err := client.HTTPClient.Do(req)
-			if err != nil {
-				fmt.Printf("Failed to make request: %v\n", err)
-				return
-			}
-			defer resp.Body.Close()
-
-			scanner := bufio.NewScanner(resp.Body)
-			var awaitingInputTurn *Turn
-			var finalTurn *Turn
-			for scanner.Scan() {
-				line := scanner.Text()
-				if strings.HasPrefix(line, "data: ") {
-					jsonPart := strings.TrimPrefix(line, "data: ")
-					fmt.Printf("[DEBUG] SSE Event: %s\n", jsonPart)
-					var sse struct {
-						Event struct {
-							Payload struct {
-								EventType string `json:"event_type"`
-								Turn      *Turn  `json:"turn,omitempty"`
-							} `json:"payload"`
-						} `json:"event"`
-					}
-					err := json.Unmarshal([]byte(jsonPart), &sse)
-					if err != nil {
-						fmt.Printf("[SSE] Failed to parse event: %v\n", err)
-						continue
-					}
-					eventType := sse.Event.Payload.EventType
-					fmt.Printf("[DEBUG] Event type: %s\n", eventType)
-					if eventType == "turn_complete" && sse.Event.Payload.Turn != nil {
-						finalTurn = sse.Event.Payload.Turn
-						fmt.Printf("[DEBUG] Turn complete received\n")
-						break
-					} else if eventType == "turn_awaiting_input" && sse.Event.Payload.Turn != nil {
-						awaitingInputTurn = sse.Event.Payload.Turn
-						fmt.Printf("[DEBUG] Turn awaiting input received\n")
-						break
-					}
-				}
-			}
-			if err := scanner.Err(); err != nil {
-				fmt.Printf("Scanner error: %v\n", err)
-				return
-			}
+	fmt.Printf("\n=== Agent Final Response ===\n%s\n", turn.OutputMessage.Content)
+	for _, search := range WebSearchResultsFromTurn(turn) {
+		fmt.Printf("\nSearched for %q:\n", search.Query)
+		for i, r := range search.Results {
+			fmt.Printf("[%d] %s - %s\n", i+1, r.Title, r.URL)
+		}
+	}
+}
 
-			if finalTurn != nil {
-				fmt.Printf("\n=== Agent Final Response ===\n%s\n", finalTurn.OutputMessage.Content)
-				fmt.Println("=== Agent Chat with RAG Completed ===")
-				return
-			}
+// exampleAgentCodeInterpreter asks an agent that runs Python with the
+// server's builtin::code_interpreter toolgroup, prints what the code printed
+// and downloads the files it produced to dir
+func exampleAgentCodeInterpreter(client *LlamaStackClient, prompt, dir string) {
+	ctx := WithCorrelationID(context.Background(), NewCorrelationID())
+	fmt.Println("=== Agent Chat with Code Interpreter ===")
 
-			if awaitingInputTurn != nil {
-				// Find tool calls in steps
-				var toolCalls []struct {
-					CallID    string
-					ToolName  string
-					Arguments interface{}
-				}
-				for _, step := range awaitingInputTurn.Steps {
-					stepMap, ok := step.(map[string]interface{})
-					if !ok {
-						continue
-					}
-					if stepMap["step_type"] == "tool_execution" {
-						calls, ok := stepMap["tool_calls"].([]interface{})
-						if !ok {
-							continue
-						}
-						for _, call := range calls {
-							callMap, ok := call.(map[string]interface{})
-							if !ok {
-								continue
-							}
-							callID, _ := callMap["call_id"].(string)
-							toolName, _ := callMap["tool_name"].(string)
-							arguments := callMap["arguments"]
-							toolCalls = append(toolCalls, struct {
-								CallID    string
-								ToolName  string
-								Arguments interface{}
-							}{
-								CallID:    callID,
-								ToolName:  toolName,
-								Arguments: arguments,
-							})
-						}
-					}
-				}
-				if len(toolCalls) == 0 {
-					fmt.Println("No tool calls found in awaiting_input turn.")
-					return
-				}
-				// For each tool call, handle RAG
-				var toolResponses []map[string]interface{}
-				for _, call := range toolCalls {
-					if strings.Contains(call.ToolName, "rag") || strings.Contains(call.ToolName, "knowledge_search") {
-						// Assume arguments is a string or map with 'query' or 'content'
-						var query string
-						switch v := call.Arguments.(type) {
-						case string:
-							query = v
-						case map[string]interface{}:
-							if q, ok := v["query"].(string); ok {
-								query = q
-							} else if c, ok := v["content"].(string); ok {
-								query = c
-							}
-						}
-						if query == "" {
-							fmt.Printf("No query found in tool call arguments: %+v\n", call.Arguments)
-							continue
-						}
-						// Call RAG
-						ragParams := RagToolQueryParams{
-							Content:     query,
-							VectorDBIDs: []string{"my-documents"}, // TODO: make dynamic if needed
-						}
-						ragResult, err := client.QueryRAG(ctx, ragParams)
-						if err != nil {
-							fmt.Printf("Error querying RAG: %v\n", err)
-							continue
-						}
-						// Compose tool response
-						var ragText string
-						if len(ragResult.Content) > 0 {
-							if itemMap, ok := ragResult.Content[0].(map[string]interface{}); ok {
-								if text, exists := itemMap["text"].(string); exists {
-									ragText = text
-								}
-							}
-						}
-						if ragText == "" {
-							ragText = "[No relevant context found in RAG]"
-						}
-						toolResponses = append(toolResponses, map[string]interface{}{
-							"call_id":   call.CallID,
-							"tool_name": call.ToolName,
-							"content": map[string]interface{}{
-								"type": "text",
-								"text": ragText,
-							},
-						})
-					} else {
-						fmt.Printf("Skipping non-RAG tool call: %s\n", call.ToolName)
-					}
-				}
-				if len(toolResponses) == 0 {
-					fmt.Println("No tool responses to send.")
-					return
-				}
-				// Resume the turn with tool responses
-				resumeParams := map[string]interface{}{
-					"tool_responses": toolResponses,
-					"stream":         true,
-				}
-				// Next loop iteration will POST to /turn/{turn_id}/resume
-				turnID = awaitingInputTurn.TurnID
-				turnParams = resumeParams
-				continue
-			}
-			fmt.Println("No final or awaiting_input turn received.")
-			return
+	model, err := client.GetAvailableModel(ctx)
+	if err != nil {
+		fmt.Printf("Error finding a model: %v\n", err)
+		return
+	}
+	agentConfig, err := NewAgentConfig(model).
+		Instructions("Write and run Python code to answer. Save any files you produce.").
+		WithCodeInterpreter().
+		ToolChoice("auto").
+		Build()
+	if err != nil {
+		fmt.Printf("Invalid agent config: %v\n", err)
+		return
+	}
+	agent, err := client.CreateAgent(ctx, AgentCreateParams{AgentConfig: agentConfig})
+	if err != nil {
+		fmt.Printf("Error creating agent: %v\n", err)
+		return
+	}
+	session, err := client.CreateSession(ctx, agent.AgentID, SessionCreateParams{SessionName: "code-interpreter-session"})
+	if err != nil {
+		fmt.Printf("Error creating session: %v\n", err)
+		return
+	}
+	turn, err := client.CreateTurn(ctx, agent.AgentID, session.SessionID, TurnCreateParams{
+		Messages: []Message{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		fmt.Printf("Error creating turn: %v\n", err)
+		return
+	}
+
+	for _, exec := range CodeExecutionsFromTurn(turn) {
+		fmt.Printf("\n--- Ran (%s) ---\n%s\n", exec.Status, exec.Code)
+		if exec.Stdout != "" {
+			fmt.Printf("stdout:\n%s\n", exec.Stdout)
+		}
+		if exec.Failed() || exec.Stderr != "" {
+			fmt.Printf("stderr:\n%s\n", exec.Stderr)
 		}
 	}
+	fmt.Printf("\n=== Agent Final Response ===\n%s\n", turn.OutputMessage.Content)
 
-	// Prepare initial turnParams as map[string]interface{}
-	initParams := map[string]interface{}{
-		"messages": []Message{
-			{
-				Role:    "user",
-				Content: userPrompt,
+	if artifacts := CodeArtifactsFromTurn(turn); len(artifacts) > 0 {
+		paths, err := client.DownloadCodeArtifacts(ctx, artifacts, dir)
+		for _, path := range paths {
+			fmt.Printf("Saved %s\n", path)
+		}
+		if err != nil {
+			fmt.Printf("Error downloading artifacts: %v\n", err)
+		}
+	}
+}
+
+// exampleAgentPipeline is a two-agent pipeline: a writer agent calls a
+// researcher agent, registered as its client-side tool, then writes a short
+// article from what the researcher found
+func exampleAgentPipeline(client *LlamaStackClient, topic string) {
+	ctx := WithCorrelationID(context.Background(), NewCorrelationID())
+	fmt.Println("=== Agent Pipeline: Researcher -> Writer ===")
+
+	model, err := client.GetAvailableModel(ctx)
+	if err != nil {
+		fmt.Printf("Error finding a model: %v\n", err)
+		return
+	}
+	researcherConfig, err := NewAgentConfig(model).
+		Name("researcher").
+		Instructions("Research the question you are given and answer with the key facts as a bullet list.").
+		Build()
+	if err != nil {
+		fmt.Printf("Invalid researcher config: %v\n", err)
+		return
+	}
+	researcher, err := NewAgentTool(ctx, client, "researcher", "Researches a question and returns the key facts", researcherConfig)
+	if err != nil {
+		fmt.Printf("Error creating researcher: %v\n", err)
+		return
+	}
+
+	writerConfig, err := NewAgentConfig(model).
+		Name("writer").
+		Instructions("Write a short article on the topic. Ask the researcher for facts first.").
+		WithClientTools(researcher.Definition()).
+		ToolChoice("auto").
+		Build()
+	if err != nil {
+		fmt.Printf("Invalid writer config: %v\n", err)
+		return
+	}
+	writer, err := client.CreateAgent(ctx, AgentCreateParams{AgentConfig: writerConfig})
+	if err != nil {
+		fmt.Printf("Error creating writer: %v\n", err)
+		return
+	}
+	session, err := client.CreateSession(ctx, writer.AgentID, SessionCreateParams{SessionName: "pipeline-session"})
+	if err != nil {
+		fmt.Printf("Error creating session: %v\n", err)
+		return
+	}
+
+	runner := AgentRunner{
+		Client: client,
+		Tools: map[string]AgentToolHandler{
+			researcher.Name: researcher.Handler(),
+		},
+		// The researcher runs a whole turn of its own
+		ToolTimeouts: map[string]time.Duration{researcher.Name: 5 * time.Minute},
+		Progress:     NewTurnProgress(os.Stderr, isTerminal(os.Stderr)),
+	}
+	turn, err := runner.Run(ctx, writer.AgentID, session.SessionID, []Message{{Role: "user", Content: topic}})
+	if err != nil {
+		fmt.Printf("Error running pipeline: %v\n", err)
+		return
+	}
+	fmt.Printf("\n=== Writer Final Response ===\n%s\n", turn.OutputMessage.Content)
+}
+
+// exampleWorkflow researches a topic and finds an angle on it concurrently,
+// has a writer agent draft from both, then counts the words of the draft,
+// printing each step's progress
+func exampleWorkflow(client *LlamaStackClient, topic string) error {
+	ctx := WithCorrelationID(context.Background(), NewCorrelationID())
+	fmt.Println("=== Workflow: research + angle -> draft -> stats ===")
+
+	model, err := client.GetAvailableModel(ctx)
+	if err != nil {
+		return fmt.Errorf("no model: %w", err)
+	}
+	writerConfig, err := NewAgentConfig(model).
+		Name("writer").
+		Instructions("Write a short, engaging article from the notes you are given.").
+		Build()
+	if err != nil {
+		return err
+	}
+	writer, err := client.CreateAgent(ctx, AgentCreateParams{AgentConfig: writerConfig})
+	if err != nil {
+		return fmt.Errorf("failed to create writer: %w", err)
+	}
+
+	steps := []WorkflowStep{
+		ChatStep(client, "research", model, "List the key facts about "+topic+"."),
+		ChatStep(client, "angle", model, "Suggest one surprising angle for an article about "+topic+"."),
+		AgentStep(&AgentRunner{Client: client}, "draft", writer.AgentID,
+			"Facts:\n{{.research}}\n\nAngle:\n{{.angle}}", "research", "angle"),
+		{
+			Name:      "stats",
+			DependsOn: []string{"draft"},
+			Run: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+				draft, _ := inputs["draft"].(string)
+				return fmt.Sprintf("%d words", len(strings.Fields(draft))), nil
 			},
 		},
-		"stream": true,
 	}
+	result, err := RunWorkflow(ctx, steps, WorkflowOptions{
+		Progress: func(e WorkflowEvent) {
+			switch e.Status {
+			case "started":
+				fmt.Printf("[%s] started\n", e.Step)
+			case "done":
+				fmt.Printf("[%s] done in %s\n", e.Step, e.Duration.Round(time.Millisecond))
+			default:
+				fmt.Printf("[%s] %s: %v\n", e.Step, e.Status, e.Err)
+			}
+		},
+	})
+	if result != nil && result.Outputs["draft"] != nil {
+		fmt.Printf("\n=== Draft (%s) ===\n%s\n", result.Outputs["stats"], result.Outputs["draft"])
+	}
+	return err
+}
 
-	agenticLoop(agentID, sessionID, initParams)
+// ragToolHandler answers a knowledge_search call with a RAG query over
+// vectorDBIDs, adding the chunks it retrieved to citations
+func ragToolHandler(ctx context.Context, client *LlamaStackClient, vectorDBIDs []string, citations *CitationTracker, call AgentToolCall) (string, error) {
+	// Arguments are a string or a map with 'query' or 'content'
+	var query string
+	switch v := call.Arguments.(type) {
+	case string:
+		query = v
+	case map[string]interface{}:
+		if q, ok := v["query"].(string); ok {
+			query = q
+		} else if c, ok := v["content"].(string); ok {
+			query = c
+		}
+	}
+	if query == "" {
+		return "", NewToolError(`Error: call knowledge_search with a "query" argument`, fmt.Errorf("no query found in tool call arguments: %+v", call.Arguments))
+	}
+	ragResult, err := client.QueryRAG(ctx, RagToolQueryParams{
+		Content:     query,
+		VectorDBIDs: vectorDBIDs,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error querying RAG: %w", err)
+	}
+	citations.Add(CitationsFromQueryResult(ragResult)...)
+	ragText := contentText(ragResult.Content)
+	if ragText == "" {
+		ragText = "[No relevant context found in RAG]"
+	}
+	return ragText, nil
 }
 
 // New function: Direct RAG query
-func exampleDirectRAGQuery(client *LlamaStackClient, userPrompt string) {
+func exampleDirectRAGQuery(client *LlamaStackClient, userPrompt string, vectorDBIDs []string) {
 	ctx := context.Background()
 
 	fmt.Println("=== Direct RAG Query ===")
@@ -1432,15 +1998,11 @@ func exampleDirectRAGQuery(client *LlamaStackClient, userPrompt string) {
 	// Query the RAG system directly
 	queryParams := RagToolQueryParams{
 		Content:     userPrompt,
-		VectorDBIDs: []string{"my-documents"}, // Use the vector store we created
-		QueryConfig: &struct {
-			MaxChunks          int    `json:"max_chunks"`
-			MaxTokensInContext int    `json:"max_tokens_in_context"`
-			Mode               string `json:"mode"`
-		}{
+		VectorDBIDs: vectorDBIDs,
+		QueryConfig: &RAGQueryConfig{
 			MaxChunks:          5,
 			MaxTokensInContext: 1000,
-			Mode:               "vector",
+			Mode:               RAGSearchVector,
 		},
 	}
 
@@ -1451,12 +2013,8 @@ func exampleDirectRAGQuery(client *LlamaStackClient, userPrompt string) {
 	}
 
 	fmt.Printf("RAG Query Result:\n")
-	for i, item := range result.Content {
-		if itemMap, ok := item.(map[string]interface{}); ok {
-			if text, exists := itemMap["text"]; exists {
-				fmt.Printf("Item %d: %s\n", i+1, text)
-			}
-		}
+	for i, chunk := range ParseQueryResult(result) {
+		fmt.Printf("Chunk %d (%s, score %.2f): %s\n", i+1, chunk.DocumentID, chunk.Score, chunk.Content)
 	}
 	fmt.Println("=== Direct RAG Query Completed ===")
 }
@@ -1521,29 +2079,516 @@ func exampleListFiles(client *LlamaStackClient) {
 }
 
 func main() {
+	agentPreset := flag.String("agent-preset", "", "YAML or JSON agent config to use instead of the built-in RAG agent")
+	savePreset := flag.String("save-agent-preset", "", "write the built-in RAG agent config to this file and exit")
+	vectorDB := flag.String("vector-db", "my-documents", "name of the vector store the demo creates and the RAG agent searches")
+	storeExpireDays := flag.Int("store-expire-days", 7, "days without use after which the server deletes the demo's vector store; 0 keeps it")
+	raw := flag.Bool("raw", false, "print streamed answers as raw tokens instead of rendering markdown")
+	printSegments := flag.Bool("segments", false, "print the sentence segments of streamed answers to stderr, as a TTS engine would get them")
+	ttsCommand := flag.String("tts-command", "", "command run with each sentence of streamed answers, e.g. \"espeak\" or \"say\"")
+	micFile := flag.String("mic-file", "", "audio file to transcribe and use as the chat prompt")
+	sttModel := flag.String("stt-model", os.Getenv("LLAMASTACK_STT_MODEL"), "speech-to-text model for -mic-file; the first registered whisper model by default")
+	deterministic := flag.Bool("deterministic", false, "send every completion with temperature 0 and a fixed seed, for reproducible runs")
+	seed := flag.Int("seed", DefaultDeterministicSeed, "seed sent with every completion that sets none; used when given or with -deterministic")
+	modelProfiles := flag.String("model-profiles", os.Getenv("LLAMASTACK_MODEL_PROFILES"), "YAML or JSON file of per-model default parameters")
+	pricing := flag.String("pricing", os.Getenv("LLAMASTACK_PRICING"), "YAML or JSON pricing table; estimated costs are logged and summed up on exit")
+	flag.Parse()
+
+	// Output hooks for the chat and repl commands
+	var hooks []OutputHook
+	if *printSegments {
+		hooks = append(hooks, NewSegmentPrinter(os.Stderr))
+	}
+	if *ttsCommand != "" {
+		hooks = append(hooks, AsyncHook(&CommandHook{Command: strings.Fields(*ttsCommand)}))
+	}
+
 	// Check for command line arguments
 	var userPrompt string
 	var pdfPath string
 
-	if len(os.Args) > 1 {
-		userPrompt = os.Args[1]
+	if flag.NArg() > 0 {
+		userPrompt = flag.Arg(0)
 	} else {
 		userPrompt = "Who is Dora's owner?" // default prompt
 	}
 
 	// Check for PDF file path argument
-	if len(os.Args) > 2 {
-		pdfPath = os.Args[2]
+	if flag.NArg() > 1 {
+		pdfPath = flag.Arg(1)
 	} else {
 		pdfPath = "sample.pdf" // default PDF path
 	}
 
+	if *savePreset != "" {
+		cfg, err := defaultRAGAgentConfig("ollama/llama3.2:3b", *vectorDB)
+		if err == nil {
+			err = SaveAgentPreset(*savePreset, cfg)
+		}
+		if err != nil {
+			fmt.Printf("Error saving agent preset: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Agent preset written to %s\n", *savePreset)
+		return
+	}
+
+	var preset *AgentConfig
+	if *agentPreset != "" {
+		cfg, err := LoadAgentPreset(*agentPreset)
+		if err != nil {
+			fmt.Printf("Error loading agent preset: %v\n", err)
+			os.Exit(1)
+		}
+		preset = &cfg
+	}
+
 	// Initialize the client
 	// Use localhost like the TypeScript examples
 	baseURL := "http://localhost:8321"
 	apiKey := "your-api-key-here"
+	if u := os.Getenv("LLAMASTACK_BASE_URL"); u != "" {
+		baseURL = u
+	}
+
+	// Calls are logged to stderr: summaries at info level, bodies with
+	// LLAMASTACK_LOG=debug. Subcommands that make many calls only log
+	// warnings unless LLAMASTACK_LOG says otherwise.
+	level := slog.LevelInfo
+	switch flag.Arg(0) {
+	case "bench", "loadtest", "dashboard":
+		level = slog.LevelWarn
+	}
+	if name := os.Getenv("LLAMASTACK_LOG"); name != "" {
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			fmt.Printf("Error: invalid LLAMASTACK_LOG: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	opts := []ClientOption{WithLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))}
+	// TAVILY_SEARCH_API_KEY, BRAVE_SEARCH_API_KEY or BING_SEARCH_API_KEY lets
+	// agents with builtin::websearch search
+	for _, provider := range WebSearchProviders {
+		if key := os.Getenv(strings.ToUpper(provider.APIKeyField())); key != "" {
+			opts = append(opts, WithWebSearchAPIKey(provider, key))
+		}
+	}
+	// LLAMASTACK_CAPTURE_DIR=DIR writes every exchange to a file for bug reports
+	if dir := os.Getenv("LLAMASTACK_CAPTURE_DIR"); dir != "" {
+		opts = append(opts, WithCaptureDir(dir))
+	}
+	if *modelProfiles != "" {
+		profiles, err := LoadModelProfiles(*modelProfiles)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithModelProfiles(profiles))
+	}
+	if *pricing != "" {
+		table, err := LoadPricingTable(*pricing)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithPricing(table))
+	}
+	seedSet := false
+	flag.Visit(func(f *flag.Flag) { seedSet = seedSet || f.Name == "seed" })
+	switch {
+	case *deterministic:
+		opts = append(opts, WithDeterministic(*seed))
+	case seedSet:
+		opts = append(opts, WithSeed(*seed))
+	}
+	// LLAMASTACK_REPLICAS=URL,... fails over to further replicas;
+	// LLAMASTACK_LOAD_BALANCE=round-robin|least-pending spreads calls over
+	// them and LLAMASTACK_HEDGE_DELAY=300ms hedges slow reads and inference
+	if replicas := os.Getenv("LLAMASTACK_REPLICAS"); replicas != "" {
+		opts = append(opts, WithReplicas(strings.Split(replicas, ",")...))
+	}
+	if name := os.Getenv("LLAMASTACK_LOAD_BALANCE"); name != "" {
+		policy, err := ParseLoadBalancePolicy(name)
+		if err != nil {
+			fmt.Printf("Error: invalid LLAMASTACK_LOAD_BALANCE: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithLoadBalancing(policy))
+	}
+	// LLAMASTACK_TRANSPORT=max-idle-per-host=32,dial-timeout=5s,... tunes
+	// connections for concurrent uploads and batches
+	if spec := os.Getenv("LLAMASTACK_TRANSPORT"); spec != "" {
+		transport, err := parseTransportSpec(spec)
+		if err != nil {
+			fmt.Printf("Error: invalid LLAMASTACK_TRANSPORT: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithTransport(transport))
+	}
+	// LLAMASTACK_COMPRESS=BYTES gzips request bodies of at least that size
+	if size := os.Getenv("LLAMASTACK_COMPRESS"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			fmt.Printf("Error: invalid LLAMASTACK_COMPRESS: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithRequestCompression(n))
+	}
+	// LLAMASTACK_BUDGET=requests=500,tokens=200000,time=10m,window=1h stops
+	// runaway loops from hogging a shared stack
+	if spec := os.Getenv("LLAMASTACK_BUDGET"); spec != "" {
+		limits, err := parseBudgetSpec(spec)
+		if err != nil {
+			fmt.Printf("Error: invalid LLAMASTACK_BUDGET: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithBudget(limits))
+	}
+	if delay := os.Getenv("LLAMASTACK_HEDGE_DELAY"); delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			fmt.Printf("Error: invalid LLAMASTACK_HEDGE_DELAY: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithHedging(d))
+	}
+	client := NewLlamaStackClient(baseURL, apiKey, opts...)
+	if client.Costs != nil {
+		defer func() { fmt.Fprintln(os.Stderr, client.Costs.Footer()) }()
+	}
+
+	// Record or replay REST traffic so the demo can run without a live server:
+	// LLAMASTACK_VCR=record|replay, LLAMASTACK_VCR_FILE=fixtures/demo.json
+	if mode := os.Getenv("LLAMASTACK_VCR"); mode != "" {
+		fixture := os.Getenv("LLAMASTACK_VCR_FILE")
+		if fixture == "" {
+			fixture = "fixtures/demo.json"
+		}
+		vcr, err := NewVCRTransport(VCRMode(mode), fixture)
+		if err != nil {
+			fmt.Printf("Error setting up VCR: %v\n", err)
+			os.Exit(1)
+		}
+		vcr.Next = client.HTTPClient.Transport
+		client.HTTPClient.Transport = vcr
+		fmt.Printf("VCR %s mode using %s\n", mode, fixture)
+	}
 
-	client := NewLlamaStackClient(baseURL, apiKey)
+	// LLAMASTACK_EMBEDDING_CACHE=DIR keeps embeddings across runs
+	if dir := os.Getenv("LLAMASTACK_EMBEDDING_CACHE"); dir != "" {
+		cache, err := OpenEmbeddingCache(dir)
+		if err != nil {
+			fmt.Printf("Error opening embedding cache: %v\n", err)
+			os.Exit(1)
+		}
+		client.EmbeddingCache = cache
+	}
+
+	// LLAMASTACK_RESPONSE_CACHE=memory|DIR[,ttl=1h][,all] reuses deterministic
+	// chat completions
+	if spec := os.Getenv("LLAMASTACK_RESPONSE_CACHE"); spec != "" {
+		opts, err := parseResponseCacheSpec(spec)
+		if err == nil {
+			client.ResponseCache, err = NewResponseCache(opts)
+		}
+		if err != nil {
+			fmt.Printf("Error opening response cache: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// LLAMASTACK_DRY_RUN=1 prints requests instead of sending them
+	if os.Getenv("LLAMASTACK_DRY_RUN") != "" {
+		client.DryRun = true
+	}
+
+	// LLAMASTACK_CURL=1 prints a curl command for every request to stderr
+	if os.Getenv("LLAMASTACK_CURL") != "" {
+		client.CurlOutput = os.Stderr
+	}
+
+	// Created agents, sessions and files are recorded for the cleanup
+	// command; LLAMASTACK_LEDGER=FILE moves the ledger, =off disables it
+	if ledgerPath := os.Getenv("LLAMASTACK_LEDGER"); ledgerPath != "off" {
+		if ledgerPath == "" {
+			ledgerPath, _ = DefaultResourceLedgerPath()
+		}
+		if ledgerPath != "" {
+			ledger, err := OpenResourceLedger(ledgerPath)
+			if err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			client.Ledger = ledger
+		}
+	}
+
+	// Generated conversation titles are kept in the user's cache directory;
+	// LLAMASTACK_TITLES=FILE moves them, =off disables the store
+	if titlesPath := os.Getenv("LLAMASTACK_TITLES"); titlesPath != "off" {
+		if titlesPath == "" {
+			titlesPath, _ = DefaultTitleStorePath()
+		}
+		if titlesPath != "" {
+			titles, err := OpenTitleStore(titlesPath)
+			if err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			client.Titles = titles
+		}
+	}
+
+	// "cleanup -older-than 24h -dry-run" deletes what earlier runs left behind
+	if flag.Arg(0) == "cleanup" {
+		if err := runCleanupCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "vector-store list" lists stores with their expiry;
+	// "vector-store inspect STORE_ID [FILE_ID]" shows how files were chunked;
+	// "vector-store sync DIR STORE_ID" mirrors a directory into a store;
+	// "vector-store ingest DIR VECTOR_DB_ID" converts and inserts mixed documents
+	if flag.Arg(0) == "vector-store" {
+		if err := runVectorStoreCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "files upload FILE..." uploads files, large ones in resumable parts
+	if flag.Arg(0) == "files" {
+		if err := runFilesCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "bench -model X -prompt-file prompts.jsonl" measures streaming latency
+	if flag.Arg(0) == "bench" {
+		if err := runBenchCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "loadtest -prompt-file prompts.jsonl -profile step ..." ramps up load
+	if flag.Arg(0) == "loadtest" {
+		if err := runLoadTestCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "shields register -model GUARD_MODEL SHIELD_ID" sets up a safety shield
+	if flag.Arg(0) == "shields" {
+		if err := runShieldsCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "profiles" prints the profile each registered LLM gets
+	if flag.Arg(0) == "profiles" {
+		if err := runProfilesCommand(client); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "transcript AGENT_ID SESSION_ID TURN_ID" prints the transcript of a turn
+	if flag.Arg(0) == "transcript" {
+		if err := runTranscriptCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "conversation PROMPT..." answers prompts within one Responses conversation
+	if flag.Arg(0) == "conversation" {
+		if err := runConversationCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "structured -schema FILE PROMPT" returns a reply matching a JSON schema
+	if flag.Arg(0) == "structured" {
+		if err := runStructuredCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "safe-chat -shield ID PROMPT" checks a chat completion with shields
+	if flag.Arg(0) == "safe-chat" {
+		if err := runSafeChatCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "dataset upload FILE DATASET_ID" registers a local JSONL/CSV dataset
+	if flag.Arg(0) == "dataset" {
+		if err := runDatasetCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "synthetic -prompt-file seeds.jsonl -o samples.jsonl" generates samples
+	if flag.Arg(0) == "synthetic" {
+		if err := runSyntheticCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "chat PROMPT" streams a chat completion; with -mic-file the prompt is
+	// transcribed from an audio file instead
+	if flag.Arg(0) == "chat" {
+		prompt := flag.Arg(1)
+		if *micFile != "" && flag.NArg() == 1 {
+			text, err := transcribeFile(context.Background(), client, *micFile, *sttModel)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Transcribed prompt: %s\n", text)
+			prompt = text
+		} else if flag.NArg() != 2 || *micFile != "" {
+			fmt.Println("Error: usage: chat PROMPT | -mic-file AUDIO chat")
+			os.Exit(1)
+		}
+		exampleStreamingChatCompletion(client, prompt, *raw, hooks...)
+		return
+	}
+
+	// "search PROMPT" asks an agent with the builtin::websearch toolgroup
+	if flag.Arg(0) == "search" {
+		if flag.NArg() != 2 {
+			fmt.Println("Error: usage: search PROMPT")
+			os.Exit(1)
+		}
+		exampleAgentWebSearch(client, flag.Arg(1))
+		return
+	}
+
+	// "code PROMPT [DIR]" asks an agent with builtin::code_interpreter and
+	// saves the files its code produced to DIR
+	if flag.Arg(0) == "code" {
+		if flag.NArg() != 2 && flag.NArg() != 3 {
+			fmt.Println("Error: usage: code PROMPT [DIR]")
+			os.Exit(1)
+		}
+		dir := flag.Arg(2)
+		if dir == "" {
+			dir = "artifacts"
+		}
+		exampleAgentCodeInterpreter(client, flag.Arg(1), dir)
+		return
+	}
+
+	// "pipeline TOPIC" has a writer agent ask a researcher agent, which it
+	// calls as a client-side tool
+	if flag.Arg(0) == "pipeline" {
+		if flag.NArg() != 2 {
+			fmt.Println("Error: usage: pipeline TOPIC")
+			os.Exit(1)
+		}
+		exampleAgentPipeline(client, flag.Arg(1))
+		return
+	}
+
+	// "workflow TOPIC" runs a small DAG of chat, agent and Go steps
+	if flag.Arg(0) == "workflow" {
+		if flag.NArg() != 2 {
+			fmt.Println("Error: usage: workflow TOPIC")
+			os.Exit(1)
+		}
+		if err := exampleWorkflow(client, flag.Arg(1)); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "vision IMAGE QUESTION..." asks a vision model about an image
+	if flag.Arg(0) == "vision" {
+		if err := runVisionCommand(client, flag.Args()[1:], *raw); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "repl" chats on stdin, keeping the history within the context length
+	if flag.Arg(0) == "repl" {
+		if err := runReplCommand(client, flag.Args()[1:], *raw, hooks...); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "prompt -template summarize -var doc=@file.txt" sends a prompt template
+	if flag.Arg(0) == "prompt" {
+		if err := runPromptCommand(client, flag.Args()[1:], *raw); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "prompts list|create|get|versions|update|set-default|delete|chat" manages
+	// prompts stored with the Prompts API
+	if flag.Arg(0) == "prompts" {
+		if err := runPromptsCommand(client, flag.Args()[1:], *raw); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "tokens [-calibrate] FILE" estimates the tokens of a text
+	if flag.Arg(0) == "tokens" {
+		if err := runTokensCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "dashboard" browses and cleans up models, agents, sessions, vector stores and files
+	if flag.Arg(0) == "dashboard" {
+		if err := runDashboardCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "eval run -model A,B BENCHMARK_ID" evaluates and compares models
+	if flag.Arg(0) == "eval" {
+		if err := runEvalCommand(client, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	fmt.Println("=== Llama Stack API Go Sample ===")
 	fmt.Printf("Using base URL: %s\n", baseURL)
@@ -1553,11 +2598,14 @@ func main() {
 
 	// Only run the PDF upload and agentic RAG test for debugging
 	fmt.Println("1. PDF Upload and RAG workflow...")
-	examplePDFUploadAndRAG(client, pdfPath)
+	vectorDBID := examplePDFUploadAndRAG(client, pdfPath, *vectorDB, *storeExpireDays)
+	if vectorDBID == "" {
+		vectorDBID = *vectorDB // search an existing store of that name
+	}
 	fmt.Println()
 
 	fmt.Println("2. Agent-based chat with RAG...")
-	exampleAgentChatWithRAG(client, userPrompt)
+	exampleAgentChatWithRAG(client, userPrompt, preset, vectorDBID)
 	fmt.Println()
 
 	// List files first to see what's already uploaded
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+)
+
+// DefaultDeterministicSeed is the seed of -deterministic runs
+const DefaultDeterministicSeed = 42
+
+type contextSeedKey struct{}
+
+type callSeedKey struct{}
+
+// WithSeed sends seed with every chat and text completion that does not set
+// its own, so repeated runs sample the same tokens on providers that honor
+// seeds
+func WithSeed(seed int) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.Seed = &seed
+	}
+}
+
+// WithDeterministic makes completions reproducible: every chat and text
+// completion is sent with temperature 0, overriding its own, and with seed
+// unless it sets one. Agent turns keep the sampling params of their config.
+func WithDeterministic(seed int) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.Seed = &seed
+		c.Deterministic = true
+	}
+}
+
+// WithContextSeed returns a context whose completions are sent with seed
+// unless they set their own, taking precedence over the client's Seed
+func WithContextSeed(ctx context.Context, seed int) context.Context {
+	return context.WithValue(ctx, contextSeedKey{}, seed)
+}
+
+// seedFor returns the seed completions made with ctx default to, if any
+func (c *LlamaStackClient) seedFor(ctx context.Context) *int {
+	if seed, ok := ctx.Value(contextSeedKey{}).(int); ok {
+		return &seed
+	}
+	return c.Seed
+}
+
+// applySampling fills in the default seed and, in deterministic mode, zeroes
+// the temperature of a completion. The returned context carries the seed
+// sent, for the call's metadata.
+func (c *LlamaStackClient) applySampling(ctx context.Context, seed **int, temperature **float64) context.Context {
+	if *seed == nil {
+		*seed = c.seedFor(ctx)
+	}
+	if c.Deterministic {
+		zero := 0.0
+		*temperature = &zero
+	}
+	if *seed == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, callSeedKey{}, **seed)
+}
+
+// callSeed returns the seed sent with the completion made with ctx
+func callSeed(ctx context.Context) *int {
+	if seed, ok := ctx.Value(callSeedKey{}).(int); ok {
+		return &seed
+	}
+	return nil
+}
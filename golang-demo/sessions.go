@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// GetSession retrieves a session together with its turns
+func (c *LlamaStackClient) GetSession(ctx context.Context, agentID, sessionID string) (*Session, error) {
+	url := fmt.Sprintf("%s/v1/agents/%s/session/%s", c.BaseURL, agentID, sessionID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, body)
+	}
+
+	var session Session
+	if err := json.Unmarshal(body, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if session.AgentID == "" {
+		session.AgentID = agentID
+	}
+
+	return &session, nil
+}
+
+// ForkSession creates a new session of the same agent holding the history of
+// sessionID up to and including atTurnID, or all of it when atTurnID is empty.
+//
+// Llama Stack has no native fork endpoint, so the history is replayed: the
+// input messages of each turn are sent again, in order, to the new session.
+// The model regenerates every answer, so the branch may already differ from
+// the original before the fork point. Replay stops with an error at a turn
+// that waits for client-side tool responses; the partial fork is returned
+// with it so the caller can continue or delete it.
+func (c *LlamaStackClient) ForkSession(ctx context.Context, agentID, sessionID, atTurnID string) (*Session, error) {
+	source, err := c.GetSession(ctx, agentID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session to fork: %w", err)
+	}
+
+	turns := source.Turns
+	if atTurnID != "" {
+		end := -1
+		for i, turn := range turns {
+			if turn.TurnID == atTurnID {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("turn %s not found in session %s", atTurnID, sessionID)
+		}
+		turns = turns[:end+1]
+	}
+
+	name := source.SessionName
+	if name == "" {
+		name = sessionID
+	}
+	fork, err := c.CreateSession(ctx, agentID, SessionCreateParams{SessionName: name + " (fork)"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fork session: %w", err)
+	}
+	fork.AgentID = agentID
+	fork.SessionName = name + " (fork)"
+
+	inputs := make([][]Message, len(turns))
+	for i, turn := range turns {
+		inputs[i] = turn.InputMessages
+	}
+	if err := c.replayTurns(ctx, agentID, fork, inputs); err != nil {
+		return fork, err
+	}
+	return fork, nil
+}
+
+// replayTurns sends each input as a non-streaming turn of session, in order,
+// and appends the resulting turns to session.Turns
+func (c *LlamaStackClient) replayTurns(ctx context.Context, agentID string, session *Session, inputs [][]Message) error {
+	stream := false
+	for i, messages := range inputs {
+		replayed, err := c.CreateTurn(ctx, agentID, session.SessionID, TurnCreateParams{
+			Messages: messages,
+			Stream:   &stream,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to replay turn %d: %w", i+1, err)
+		}
+		session.Turns = append(session.Turns, *replayed)
+		if replayed.CompletedAt == nil {
+			return fmt.Errorf("replayed turn %d is awaiting client tool responses", i+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+)
+
+// RegisterShieldParams configures a shield at registration
+type RegisterShieldParams struct {
+	// ProviderShieldID is what the provider calls the shield; for llama-guard
+	// it is the guard model, e.g. meta-llama/Llama-Guard-3-8B. Defaults to
+	// the shield ID.
+	ProviderShieldID string
+	// Params are provider-specific settings, e.g. excluded_categories
+	Params map[string]interface{}
+}
+
+// RegisterShield registers a shield served by a safety provider, e.g.
+// RegisterShield(ctx, "content-safety", "llama-guard", params), so agents
+// can name it in their input and output shields
+func (c *LlamaStackClient) RegisterShield(ctx context.Context, shieldID, providerID string, params RegisterShieldParams) (*Shield, error) {
+	if shieldID == "" || providerID == "" {
+		return nil, errors.New("shield id and provider id are required")
+	}
+	body := SpecRegisterShieldRequest{ShieldID: shieldID, ProviderID: &providerID, Params: params.Params}
+	if params.ProviderShieldID != "" {
+		body.ProviderShieldID = &params.ProviderShieldID
+	}
+	var shield Shield
+	if err := c.postJSON(ctx, "/v1/shields", body, &shield); err != nil {
+		return nil, err
+	}
+	return &shield, nil
+}
+
+// GetShield returns one registered shield
+func (c *LlamaStackClient) GetShield(ctx context.Context, shieldID string) (*Shield, error) {
+	var shield Shield
+	if err := c.getJSON(ctx, "/v1/shields/"+url.PathEscape(shieldID), &shield); err != nil {
+		return nil, err
+	}
+	return &shield, nil
+}
+
+// UnregisterShield removes a shield. Agents created with it keep naming it,
+// so their turns fail until it is registered again.
+func (c *LlamaStackClient) UnregisterShield(ctx context.Context, shieldID string) error {
+	return c.sendDelete(ctx, "/v1/shields/"+url.PathEscape(shieldID))
+}
+
+// EnsureShield registers a shield unless one with the same ID is already
+// registered, and returns it; for setting up shields before creating agents
+// that reference them
+func (c *LlamaStackClient) EnsureShield(ctx context.Context, shieldID, providerID string, params RegisterShieldParams) (*Shield, error) {
+	shields, err := c.ListShields(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shields: %w", err)
+	}
+	for i, s := range shields.Data {
+		if s.Identifier == shieldID {
+			return &shields.Data[i], nil
+		}
+	}
+	return c.RegisterShield(ctx, shieldID, providerID, params)
+}
+
+// runShieldsCommand handles "shields list", "shields register" and
+// "shields unregister"
+func runShieldsCommand(client *LlamaStackClient, args []string) error {
+	usage := errors.New("usage: shields list | register [-provider llama-guard] [-model GUARD_MODEL] SHIELD_ID | unregister SHIELD_ID")
+	if len(args) == 0 {
+		return usage
+	}
+	ctx := context.Background()
+	switch args[0] {
+	case "list":
+		shields, err := client.ListShields(ctx)
+		if err != nil {
+			return err
+		}
+		if len(shields.Data) == 0 {
+			fmt.Println("No shields registered")
+		}
+		for _, s := range shields.Data {
+			fmt.Printf("%s  provider=%s  resource=%s\n", s.Identifier, s.ProviderID, s.ProviderResourceID)
+		}
+		return nil
+	case "register":
+		fs := flag.NewFlagSet("shields register", flag.ContinueOnError)
+		provider := fs.String("provider", "llama-guard", "safety provider serving the shield")
+		model := fs.String("model", "", "provider shield ID, e.g. the llama-guard model; the shield ID by default")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return usage
+		}
+		shield, err := client.RegisterShield(ctx, fs.Arg(0), *provider, RegisterShieldParams{ProviderShieldID: *model})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Registered shield %s with provider %s\n", shield.Identifier, shield.ProviderID)
+		return nil
+	case "unregister":
+		if len(args) != 2 {
+			return usage
+		}
+		if err := client.UnregisterShield(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Unregistered shield %s\n", args[1])
+		return nil
+	}
+	return usage
+}
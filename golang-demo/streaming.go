@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrStreamInterrupted is returned when a stream ends before the server marks it complete
+var ErrStreamInterrupted = errors.New("stream interrupted")
+
+// ErrStreamIdle is returned when a stream receives no data, not even a
+// heartbeat comment, for longer than the client's StreamIdleTimeout
+var ErrStreamIdle = errors.New("stream idle")
+
+// ErrSSELineTooLong is returned when a single SSE line exceeds maxSSELineSize
+var ErrSSELineTooLong = errors.New("sse line too long")
+
+// ErrInvalidUTF8 is returned when a stream event is not valid UTF-8
+var ErrInvalidUTF8 = errors.New("invalid utf-8 in stream event")
+
+// StreamDecodeError is returned when a stream event cannot be decoded
+type StreamDecodeError struct {
+	Data string // offending event data, truncated for display
+	Err  error
+}
+
+func (e *StreamDecodeError) Error() string {
+	return fmt.Sprintf("failed to decode stream event %q: %v", e.Data, e.Err)
+}
+
+func (e *StreamDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// newStreamDecodeError builds a StreamDecodeError with printable, bounded data
+func newStreamDecodeError(data string, err error) *StreamDecodeError {
+	const maxShown = 200
+	if len(data) > maxShown {
+		data = data[:maxShown] + "..."
+	}
+	return &StreamDecodeError{Data: strings.ToValidUTF8(data, "\uFFFD"), Err: err}
+}
+
+// decodeSSEData decodes a single event's data into v. Invalid UTF-8, truncated
+// JSON, and several JSON values squeezed into one event are all rejected.
+func decodeSSEData(data string, v interface{}) error {
+	if !utf8.ValidString(data) {
+		return newStreamDecodeError(data, ErrInvalidUTF8)
+	}
+	dec := json.NewDecoder(strings.NewReader(data))
+	if err := dec.Decode(v); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return newStreamDecodeError(data, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return newStreamDecodeError(data, errors.New("unexpected data after JSON value"))
+	}
+	return nil
+}
+
+// ChatCompletionChunk represents a single chunk of a streaming chat completion
+type ChatCompletionChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+}
+
+// ChunkChoice represents one choice within a streaming chunk
+type ChunkChoice struct {
+	Index        int             `json:"index"`
+	Delta        ChunkDelta      `json:"delta"`
+	FinishReason string          `json:"finish_reason,omitempty"`
+	Logprobs     *ChoiceLogprobs `json:"logprobs,omitempty"`
+}
+
+// ChunkDelta represents the incremental content carried by a chunk choice
+type ChunkDelta struct {
+	Role      string          `json:"role,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// ToolCallDelta represents a fragment of a tool call in a streaming chunk
+type ToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments,omitempty"`
+	} `json:"function"`
+}
+
+// TurnEvent represents a single event payload from an agent turn stream
+type TurnEvent struct {
+	EventType   string                 `json:"event_type"`
+	TurnID      string                 `json:"turn_id,omitempty"`
+	StepType    string                 `json:"step_type,omitempty"`
+	StepID      string                 `json:"step_id,omitempty"`
+	Delta       map[string]interface{} `json:"delta,omitempty"`
+	StepDetails map[string]interface{} `json:"step_details,omitempty"`
+	Turn        *Turn                  `json:"turn,omitempty"`
+}
+
+// maxSSELineSize bounds a single SSE line; turn_complete events carry the whole turn
+const maxSSELineSize = 4 * 1024 * 1024
+
+// idleTimeoutReader cancels its request when no data arrives within timeout.
+// Cancelling fails a pending read without closing the body under it, which
+// the logging and metadata wrappers beneath do not allow.
+type idleTimeoutReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	idle    atomic.Bool
+}
+
+// newIdleTimeoutReader wraps body so that a silent stream fails with
+// ErrStreamIdle; cancel cancels the request body belongs to
+func newIdleTimeoutReader(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutReader {
+	r := &idleTimeoutReader{body: body, timeout: timeout, cancel: cancel}
+	r.timer = time.AfterFunc(timeout, func() {
+		r.idle.Store(true)
+		cancel()
+	})
+	return r
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if r.idle.Load() {
+		return n, fmt.Errorf("%w for %s", ErrStreamIdle, r.timeout)
+	}
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+func (r *idleTimeoutReader) Close() error {
+	r.timer.Stop()
+	err := r.body.Close()
+	r.cancel()
+	return err
+}
+
+// doStream sends a streaming request like do and applies the client's
+// StreamIdleTimeout to the response body
+func (c *LlamaStackClient) doStream(req *http.Request) (*http.Response, error) {
+	if c.StreamIdleTimeout <= 0 {
+		return c.do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := c.do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = newIdleTimeoutReader(resp.Body, c.StreamIdleTimeout, cancel)
+	return resp, nil
+}
+
+// sseEvent represents a single server-sent event
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// readSSE reads server-sent events from r and calls fn for each one until fn
+// returns false or the stream ends
+func readSSE(r io.Reader, fn func(sseEvent) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+
+	var ev sseEvent
+	var data []string
+	dispatch := func() bool {
+		if len(data) == 0 {
+			ev = sseEvent{}
+			return true
+		}
+		ev.Data = strings.Join(data, "\n")
+		ok := fn(ev)
+		ev, data = sseEvent{}, nil
+		return ok
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if !dispatch() {
+				return nil
+			}
+		case strings.HasPrefix(line, ":"):
+			// Comment line, used by some servers as a heartbeat
+		default:
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "data":
+				data = append(data, value)
+			case "event":
+				ev.Event = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("%w: limit is %d bytes", ErrSSELineTooLong, maxSSELineSize)
+		}
+		return err
+	}
+	dispatch()
+	return nil
+}
+
+// StreamChatCompletion streams a chat completion as a sequence of chunks.
+//
+// Breaking out of the range loop closes the underlying response body. A
+// non-nil error is always the last value yielded.
+func (c *LlamaStackClient) StreamChatCompletion(ctx context.Context, params ChatCompletionParams) iter.Seq2[ChatCompletionChunk, error] {
+	return func(yield func(ChatCompletionChunk, error) bool) {
+		resp, err := c.openChatCompletionStream(ctx, params)
+		if err != nil {
+			yield(ChatCompletionChunk{}, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		stopped, done := false, false
+		err = readSSE(resp.Body, func(ev sseEvent) bool {
+			if ev.Data == "[DONE]" {
+				done = true
+				return false
+			}
+			var chunk ChatCompletionChunk
+			if err := decodeSSEData(ev.Data, &chunk); err != nil {
+				yield(ChatCompletionChunk{}, err)
+				stopped = true
+				return false
+			}
+			if !yield(chunk, nil) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if stopped || done {
+			return
+		}
+		if ctx.Err() != nil {
+			yield(ChatCompletionChunk{}, ctx.Err())
+			return
+		}
+		if errors.Is(err, ErrSSELineTooLong) {
+			yield(ChatCompletionChunk{}, err)
+			return
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		yield(ChatCompletionChunk{}, fmt.Errorf("%w: %w", ErrStreamInterrupted, err))
+	}
+}
+
+// StreamResumeOptions configures StreamChatCompletionWithResume
+type StreamResumeOptions struct {
+	MaxRetries int           // reconnect attempts after an interruption
+	Backoff    time.Duration // delay before each reconnect attempt
+}
+
+// StreamChatCompletionWithResume streams a chat completion like
+// StreamChatCompletion, but when the stream is interrupted it reconnects and
+// asks the model to continue from what was already received, appended as a
+// trailing assistant message. Chunks from every attempt are yielded in order,
+// so consumers see one continuous answer.
+//
+// Continuation depends on the model's chat template honoring a trailing
+// assistant prefix. Streams with tool calls or more than one choice are not
+// resumed since a partial tool call cannot be continued reliably.
+func (c *LlamaStackClient) StreamChatCompletionWithResume(ctx context.Context, params ChatCompletionParams, opts StreamResumeOptions) iter.Seq2[ChatCompletionChunk, error] {
+	return func(yield func(ChatCompletionChunk, error) bool) {
+		acc := NewChunkAccumulator()
+		attempt := params
+		for retries := 0; ; retries++ {
+			var streamErr error
+			for chunk, err := range c.StreamChatCompletion(withRetries(ctx, retries), attempt) {
+				if err != nil {
+					streamErr = err
+					break
+				}
+				acc.Add(chunk)
+				if !yield(chunk, nil) {
+					return
+				}
+			}
+			if streamErr == nil {
+				return
+			}
+
+			msg := acc.Message()
+			resumable := errors.Is(streamErr, ErrStreamInterrupted) &&
+				len(acc.Choices()) <= 1 && len(msg.ToolCalls) == 0
+			if !resumable || retries >= opts.MaxRetries {
+				yield(ChatCompletionChunk{}, streamErr)
+				return
+			}
+
+			c.logger().WarnContext(ctx, "stream interrupted, resuming", "received_chars", len(msg.Content),
+				"attempt", retries+1, "max_retries", opts.MaxRetries, "error", streamErr)
+			if opts.Backoff > 0 {
+				select {
+				case <-time.After(opts.Backoff):
+				case <-ctx.Done():
+					yield(ChatCompletionChunk{}, ctx.Err())
+					return
+				}
+			}
+
+			attempt = params
+			attempt.Messages = append(append([]Message{}, params.Messages...), Message{
+				Role:    "assistant",
+				Content: msg.Content,
+			})
+		}
+	}
+}
+
+// StreamTurn creates a turn and streams its events as they arrive.
+//
+// The sequence ends after the turn_complete or turn_awaiting_input event; a
+// stream that ends before either fails with ErrStreamInterrupted. Breaking
+// out of the range loop closes the underlying response body.
+func (c *LlamaStackClient) StreamTurn(ctx context.Context, agentID, sessionID string, params TurnCreateParams) iter.Seq2[TurnEvent, error] {
+	return func(yield func(TurnEvent, error) bool) {
+		stream := true
+		params.Stream = &stream
+
+		resp, err := c.openTurn(ctx, agentID, sessionID, params)
+		if err != nil {
+			yield(TurnEvent{}, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		stopped := false
+		err = readSSE(resp.Body, func(ev sseEvent) bool {
+			var sse struct {
+				Event struct {
+					Payload TurnEvent `json:"payload"`
+				} `json:"event"`
+			}
+			if err := decodeSSEData(ev.Data, &sse); err != nil {
+				yield(TurnEvent{}, err)
+				stopped = true
+				return false
+			}
+			payload := sse.Event.Payload
+			if !yield(payload, nil) {
+				stopped = true
+				return false
+			}
+			if payload.EventType == "turn_complete" || payload.EventType == "turn_awaiting_input" {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if stopped {
+			return
+		}
+		if ctx.Err() != nil {
+			yield(TurnEvent{}, ctx.Err())
+			return
+		}
+		if errors.Is(err, ErrSSELineTooLong) {
+			yield(TurnEvent{}, err)
+			return
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		yield(TurnEvent{}, fmt.Errorf("%w: %w", ErrStreamInterrupted, err))
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// closeTrackingTransport counts the response bodies closed by the client
+type closeTrackingTransport struct {
+	base   http.RoundTripper
+	opened atomic.Int32
+	closed atomic.Int32
+}
+
+func (t *closeTrackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.opened.Add(1)
+	resp.Body = &trackedBody{ReadCloser: resp.Body, closed: &t.closed}
+	return resp, nil
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	closed *atomic.Int32
+	once   atomic.Bool
+}
+
+func (b *trackedBody) Close() error {
+	if b.once.CompareAndSwap(false, true) {
+		b.closed.Add(1)
+	}
+	return b.ReadCloser.Close()
+}
+
+// newEndlessChatStream starts a server whose chat completion stream sends a
+// chunk and then stays open until the client goes away, and a client for it.
+// The test fails unless every response body it opened was closed.
+func newEndlessChatStream(t *testing.T) *LlamaStackClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	// A leaked body would keep its handler, and so srv.Close, waiting
+	t.Cleanup(srv.CloseClientConnections)
+
+	base := &http.Transport{}
+	tr := &closeTrackingTransport{base: base}
+	t.Cleanup(base.CloseIdleConnections)
+	t.Cleanup(func() {
+		if opened, closed := tr.opened.Load(), tr.closed.Load(); closed != opened {
+			t.Errorf("%d of %d response bodies closed", closed, opened)
+		}
+	})
+	client := NewLlamaStackClient(srv.URL, "test")
+	client.HTTPClient = &http.Client{Transport: tr}
+	return client
+}
+
+// checkNoGoroutineLeaks fails the test if, once its other cleanups have run,
+// more goroutines are running than when it was called
+func checkNoGoroutineLeaks(t *testing.T) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(2 * time.Second)
+		for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if n := runtime.NumGoroutine(); n > before {
+			buf := make([]byte, 1<<16)
+			t.Errorf("%d goroutines left behind:\n%s", n-before, buf[:runtime.Stack(buf, true)])
+		}
+	})
+}
+
+func TestCreateStreamingChatCompletionCancel(t *testing.T) {
+	checkNoGoroutineLeaks(t)
+	client := newEndlessChatStream(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := client.CreateStreamingChatCompletion(ctx, ChatCompletionParams{Model: "m"})
+	if err != nil {
+		t.Fatalf("CreateStreamingChatCompletion: %v", err)
+	}
+	if line := <-ch; line == "" {
+		t.Fatal("expected a first chunk")
+	}
+
+	// Stop reading mid-stream; the channel must close without the server
+	// ending the stream
+	cancel()
+	select {
+	case _, ok := <-ch:
+		for ok {
+			_, ok = <-ch
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+}
+
+func TestStreamChatCompletionBreak(t *testing.T) {
+	checkNoGoroutineLeaks(t)
+	client := newEndlessChatStream(t)
+
+	chunks := 0
+	for _, err := range client.StreamChatCompletion(context.Background(), ChatCompletionParams{Model: "m"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		chunks++
+		break
+	}
+	if chunks != 1 {
+		t.Fatalf("got %d chunks, want 1", chunks)
+	}
+}
+
+// recordingHook collects the segments passed to it
+type recordingHook struct{ segments []string }
+
+func (h *recordingHook) OnSegment(segment string) error {
+	h.segments = append(h.segments, segment)
+	return nil
+}
+
+func (h *recordingHook) OnEnd() error { return nil }
+
+func TestStreamReplReplyFeedsHooksTheOutput(t *testing.T) {
+	mock := NewMockStack()
+	defer mock.Close()
+	const answer = "Dora is a pug. She lives in Campinas with Ana."
+	mock.ChatReplies = []string{answer}
+	client := NewLlamaStackClient(mock.URL(), "test")
+
+	// The reply is written to stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	printed := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		printed <- string(data)
+	}()
+
+	hook := &recordingHook{}
+	reply, err := streamReplReply(context.Background(), client, "m", []Message{{Role: "user", Content: "Who is Dora?"}}, true, hook)
+	w.Close()
+	os.Stdout = stdout
+	out := <-printed
+	if err != nil {
+		t.Fatalf("streamReplReply: %v", err)
+	}
+
+	if reply != answer {
+		t.Fatalf("reply = %q, want %q", reply, answer)
+	}
+	if strings.TrimSpace(out) != answer {
+		t.Fatalf("printed %q, want the answer text", out)
+	}
+	if got := strings.Join(hook.segments, " "); got != answer {
+		t.Fatalf("hooks got %q, want %q", got, answer)
+	}
+}
+
+func TestStreamChatCompletionCancel(t *testing.T) {
+	checkNoGoroutineLeaks(t)
+	client := newEndlessChatStream(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	var last error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, err := range client.StreamChatCompletion(ctx, ChatCompletionParams{Model: "m"}) {
+			last = err
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream not ended after cancel")
+	}
+	if !errors.Is(last, context.Canceled) {
+		t.Fatalf("last error = %v, want context.Canceled", last)
+	}
+}
+
+func TestStreamIdleTimeout(t *testing.T) {
+	checkNoGoroutineLeaks(t)
+	client := newEndlessChatStream(t)
+	client.StreamIdleTimeout = 50 * time.Millisecond
+	client.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// The logger and call recorder wrap the body too; the timeout must not
+	// close it under the pending read
+	ctx, rec := WithCallRecorder(context.Background())
+	var last error
+	chunks := 0
+	for _, err := range client.StreamChatCompletion(ctx, ChatCompletionParams{Model: "m"}) {
+		if err == nil {
+			chunks++
+		}
+		last = err
+	}
+	if chunks != 1 {
+		t.Fatalf("got %d chunks, want 1", chunks)
+	}
+	if !errors.Is(last, ErrStreamIdle) || !errors.Is(last, ErrStreamInterrupted) {
+		t.Fatalf("last error = %v, want ErrStreamIdle", last)
+	}
+	if md, ok := rec.Last(); !ok || md.TTFT == 0 {
+		t.Fatalf("call not recorded with its time to first token: %+v", md)
+	}
+}
+
+func TestStreamTurnEndsBeforeTurnComplete(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"event\":{\"payload\":{\"event_type\":\"turn_start\",\"turn_id\":\"t1\"}}}\n\n")
+		fmt.Fprint(w, "data: {\"event\":{\"payload\":{\"event_type\":\"step_progress\",\"step_type\":\"inference\"}}}\n\n")
+	}))
+	defer srv.Close()
+	client := NewLlamaStackClient(srv.URL, "test")
+
+	var events []string
+	var last error
+	for ev, err := range client.StreamTurn(context.Background(), "a1", "s1", TurnCreateParams{}) {
+		if err != nil {
+			last = err
+			continue
+		}
+		events = append(events, ev.EventType)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got events %v, want turn_start and step_progress", events)
+	}
+	if !errors.Is(last, ErrStreamInterrupted) || !errors.Is(last, io.ErrUnexpectedEOF) {
+		t.Fatalf("last error = %v, want ErrStreamInterrupted wrapping io.ErrUnexpectedEOF", last)
+	}
+}
+
+// sseFuzzSeeds are stream bodies that have tripped up SSE parsers: truncated
+// and concatenated JSON, invalid UTF-8, a line over maxSSELineSize and
+// events split across data lines
+var sseFuzzSeeds = []string{
+	"data: {\"id\":\"c1\"}\n\n",
+	"data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\n\n",
+	"data: {\"a\":1}{\"b\":2}\n\n",
+	"data: {\"a\":1} {\"b\":2}\n\ndata: [DONE]\n\n",
+	"data: \"\xff\xfe\"\n\n",
+	"data: {\"text\":\"caf\xc3\"}\n\n",
+	"event: message\ndata: {\"a\":\ndata: 1}\n\n",
+	": heartbeat\n\n: heartbeat\ndata: {}\n",
+	"data:{}\r\n\r\n",
+	"data: " + strings.Repeat("x", maxSSELineSize+1) + "\n\n",
+	"",
+	"\n\n\n",
+	"data",
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func FuzzReadSSE(f *testing.F) {
+	for _, seed := range sseFuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, body string) {
+		events := 0
+		err := readSSE(strings.NewReader(body), func(ev sseEvent) bool {
+			events++
+			return true
+		})
+		if err != nil && !errors.Is(err, ErrSSELineTooLong) {
+			t.Fatalf("unexpected error %v", err)
+		}
+		// The scanner needs room for a line ending too, so lines of exactly
+		// the limit may go either way
+		if n := longestLine(body); (n > maxSSELineSize && err == nil) || (n < maxSSELineSize-1 && err != nil) {
+			t.Fatalf("err = %v for a longest line of %d bytes", err, n)
+		}
+		if lines := strings.Count(body, "data"); events > lines {
+			t.Fatalf("%d events from %d data lines", events, lines)
+		}
+	})
+}
+
+// longestLine returns the length of the longest line of s, without its
+// line ending
+func longestLine(s string) int {
+	longest := 0
+	for _, line := range strings.Split(s, "\n") {
+		longest = max(longest, len(strings.TrimSuffix(line, "\r")))
+	}
+	return longest
+}
+
+func FuzzDecodeSSEData(f *testing.F) {
+	for _, seed := range []string{`{"id":"c1"}`, `{"id":`, `{"a":1}{"b":2}`, `1 2`, "\"\xff\"", `  {}  `, `[DONE]`, ``, strings.Repeat("[", 20000)} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		var v interface{}
+		err := decodeSSEData(data, &v)
+		if valid := utf8.ValidString(data) && json.Valid([]byte(data)); (err == nil) != valid {
+			t.Fatalf("decodeSSEData(%q) = %v, want success %v", data, err, valid)
+		}
+		if err == nil {
+			return
+		}
+		var decodeErr *StreamDecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("error %v is not a *StreamDecodeError", err)
+		}
+		if !utf8.ValidString(decodeErr.Data) || len(decodeErr.Data) > 3*(200+3) {
+			t.Fatalf("unbounded or invalid error data %q", decodeErr.Data)
+		}
+	})
+}
+
+func FuzzStreamTurn(f *testing.F) {
+	f.Add("data: {\"event\":{\"payload\":{\"event_type\":\"turn_start\",\"turn_id\":\"t1\"}}}\n\n" +
+		"data: {\"event\":{\"payload\":{\"event_type\":\"turn_complete\",\"turn\":{\"turn_id\":\"t1\"}}}}\n\n")
+	f.Add("data: {\"event\":{\"payload\":{\"event_type\":\"turn_awaiting_input\"}}}\n\ndata: {}\n\n")
+	for _, seed := range sseFuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, body string) {
+		client := NewLlamaStackClient("http://stack.test", "test")
+		client.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		})}
+
+		// The sequence ends with exactly one error, or with the event that
+		// ends the turn
+		var last TurnEvent
+		var lastErr error
+		for ev, err := range client.StreamTurn(context.Background(), "a1", "s1", TurnCreateParams{}) {
+			if lastErr != nil {
+				t.Fatalf("value yielded after error %v", lastErr)
+			}
+			last, lastErr = ev, err
+		}
+		if lastErr != nil {
+			var decodeErr *StreamDecodeError
+			if !errors.As(lastErr, &decodeErr) && !errors.Is(lastErr, ErrStreamInterrupted) && !errors.Is(lastErr, ErrSSELineTooLong) {
+				t.Fatalf("unexpected error %v", lastErr)
+			}
+			return
+		}
+		if last.EventType != "turn_complete" && last.EventType != "turn_awaiting_input" {
+			t.Fatalf("stream ended after %q without an error", last.EventType)
+		}
+	})
+}
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ResponseFormat constrains what a chat completion replies with: "text",
+// "json_object" for any JSON object or "json_schema" for JSON matching a
+// schema
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat is the schema of a "json_schema" response format
+type JSONSchemaFormat struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
+	Strict      *bool                  `json:"strict,omitempty"`
+}
+
+// JSONSchemaResponseFormat asks for replies matching schema
+func JSONSchemaResponseFormat(name string, schema map[string]interface{}) *ResponseFormat {
+	return &ResponseFormat{Type: "json_schema", JSONSchema: &JSONSchemaFormat{Name: name, Schema: schema}}
+}
+
+// SchemaError is one way a value does not match a schema, at a JSONPath-like
+// location such as "$.items[2].price"
+type SchemaError struct {
+	Path    string
+	Message string
+}
+
+func (e SchemaError) String() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidateJSONSchema checks value, as decoded by encoding/json, against a
+// JSON Schema and returns every mismatch. It covers the keywords structured
+// outputs use: type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, uniqueItems, minLength,
+// maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum,
+// multipleOf, allOf, anyOf, oneOf, not and $ref to #/$defs or
+// #/definitions. Other keywords, such as format, are ignored.
+func ValidateJSONSchema(schema map[string]interface{}, value interface{}) []SchemaError {
+	v := schemaValidator{root: schema}
+	v.validate(schema, value, "$")
+	return v.errs
+}
+
+type schemaValidator struct {
+	root map[string]interface{}
+	errs []SchemaError
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, SchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches reports whether value matches schema without recording errors
+func (v *schemaValidator) matches(schema map[string]interface{}, value interface{}) bool {
+	sub := schemaValidator{root: v.root}
+	sub.validate(schema, value, "$")
+	return len(sub.errs) == 0
+}
+
+func (v *schemaValidator) validate(schema map[string]interface{}, value interface{}, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			v.fail(path, "%v", err)
+			return
+		}
+		v.validate(target, value, path)
+		return
+	}
+
+	if t, ok := schema["type"]; ok && !matchesSchemaType(t, value) {
+		v.fail(path, "expected %s, got %s", schemaTypeNames(t), jsonTypeName(value))
+		return
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of %s", compactJSON(enum))
+		}
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		v.fail(path, "must be %s", compactJSON(c))
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(schema, val, path)
+	case []interface{}:
+		v.validateArray(schema, val, path)
+	case string:
+		n := utf8.RuneCountInString(val)
+		if min, ok := schemaNumber(schema, "minLength"); ok && float64(n) < min {
+			v.fail(path, "must be at least %v characters long", min)
+		}
+		if max, ok := schemaNumber(schema, "maxLength"); ok && float64(n) > max {
+			v.fail(path, "must be at most %v characters long", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(val) {
+				v.fail(path, "must match %s", pattern)
+			}
+		}
+	case float64:
+		if min, ok := schemaNumber(schema, "minimum"); ok && val < min {
+			v.fail(path, "must be >= %v", min)
+		}
+		if max, ok := schemaNumber(schema, "maximum"); ok && val > max {
+			v.fail(path, "must be <= %v", max)
+		}
+		if min, ok := schemaNumber(schema, "exclusiveMinimum"); ok && val <= min {
+			v.fail(path, "must be > %v", min)
+		}
+		if max, ok := schemaNumber(schema, "exclusiveMaximum"); ok && val >= max {
+			v.fail(path, "must be < %v", max)
+		}
+		if m, ok := schemaNumber(schema, "multipleOf"); ok && m > 0 {
+			if q := val / m; math.Abs(q-math.Round(q)) > 1e-9 {
+				v.fail(path, "must be a multiple of %v", m)
+			}
+		}
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range all {
+			if sub, ok := s.(map[string]interface{}); ok {
+				v.validate(sub, value, path)
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok && v.countMatches(anyOf, value) == 0 {
+		v.fail(path, "must match at least one of the anyOf schemas")
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		if n := v.countMatches(oneOf, value); n != 1 {
+			v.fail(path, "must match exactly one of the oneOf schemas, matches %d", n)
+		}
+	}
+	if not, ok := schema["not"].(map[string]interface{}); ok && v.matches(not, value) {
+		v.fail(path, "must not match the schema in not")
+	}
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, obj map[string]interface{}, path string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, present := obj[name]; !present {
+					v.fail(path, "missing required property %q", name)
+				}
+			}
+		}
+	}
+	// Sorted so the errors, which go back to the model, are stable
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		childPath := path + "." + name
+		if prop, ok := properties[name].(map[string]interface{}); ok {
+			v.validate(prop, obj[name], childPath)
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				v.fail(path, "unexpected property %q", name)
+			}
+		case map[string]interface{}:
+			v.validate(extra, obj[name], childPath)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]interface{}, arr []interface{}, path string) {
+	if min, ok := schemaNumber(schema, "minItems"); ok && float64(len(arr)) < min {
+		v.fail(path, "must have at least %v items", min)
+	}
+	if max, ok := schemaNumber(schema, "maxItems"); ok && float64(len(arr)) > max {
+		v.fail(path, "must have at most %v items", max)
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(arr[i], arr[j]) {
+					v.fail(fmt.Sprintf("%s[%d]", path, i), "duplicates item %d", j)
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range arr {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *schemaValidator) countMatches(schemas []interface{}, value interface{}) int {
+	n := 0
+	for _, s := range schemas {
+		if sub, ok := s.(map[string]interface{}); ok && v.matches(sub, value) {
+			n++
+		}
+	}
+	return n
+}
+
+// resolve finds the schema a local $ref such as "#/$defs/item" points to
+func (v *schemaValidator) resolve(ref string) (map[string]interface{}, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %s: only references within the schema are", ref)
+	}
+	var node interface{} = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %s", ref)
+		}
+		node = obj[part]
+	}
+	target, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %s", ref)
+	}
+	return target, nil
+}
+
+// matchesSchemaType checks value against a type or list of types
+func matchesSchemaType(t interface{}, value interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		return matchesType(t, value)
+	case []interface{}:
+		for _, name := range t {
+			if s, ok := name.(string); ok && matchesType(s, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesType(name string, value interface{}) bool {
+	switch name {
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	}
+	return jsonTypeName(value) == name
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func schemaTypeNames(t interface{}) string {
+	if list, ok := t.([]interface{}); ok {
+		names := make([]string, len(list))
+		for i, n := range list {
+			names[i] = fmt.Sprint(n)
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	switch n := schema[key].(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func compactJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// extractJSON returns the JSON value in a reply, without the markdown fence
+// or the sentence around it that some models add despite the schema
+func extractJSON(reply string) string {
+	text := strings.TrimSpace(reply)
+	if start := strings.Index(text, "```"); start >= 0 {
+		body := text[start+3:]
+		if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+			body = body[nl+1:]
+		}
+		if end := strings.Index(body, "```"); end >= 0 {
+			return strings.TrimSpace(body[:end])
+		}
+	}
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closer := byte('}')
+	if text[start] == '[' {
+		closer = ']'
+	}
+	if end := strings.LastIndexByte(text, closer); end > start {
+		return text[start : end+1]
+	}
+	return text[start:]
+}
+
+// DefaultMaxRepairs is how often a structured completion is re-prompted
+// with its validation errors before it fails
+const DefaultMaxRepairs = 2
+
+// StructuredOutput is the schema a structured completion must match
+type StructuredOutput struct {
+	Name   string // defaults to "response"
+	Schema map[string]interface{}
+	// MaxRepairs is how many times an invalid reply is sent back with its
+	// errors; zero means DefaultMaxRepairs and a negative value none
+	MaxRepairs int
+}
+
+// SchemaValidationError is returned when a structured completion still does
+// not match its schema after the last repair
+type SchemaValidationError struct {
+	Errors   []SchemaError
+	Output   string // the last reply
+	Attempts int    // completions made
+}
+
+func (e *SchemaValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, se := range e.Errors {
+		msgs[i] = se.String()
+	}
+	return fmt.Sprintf("reply does not match the schema after %s: %s", pluralize(e.Attempts, "attempt"), strings.Join(msgs, "; "))
+}
+
+// CreateStructuredCompletion asks for a reply matching out.Schema, using the
+// json_schema response format, and validates it. An invalid reply is sent
+// back to the model with the validation errors, up to out.MaxRepairs times,
+// before a *SchemaValidationError is returned. The valid JSON is returned
+// without any fence or prose around it.
+func (c *LlamaStackClient) CreateStructuredCompletion(ctx context.Context, params ChatCompletionParams, out StructuredOutput) (json.RawMessage, error) {
+	if out.Schema == nil {
+		return nil, errors.New("structured output needs a schema")
+	}
+	name := out.Name
+	if name == "" {
+		name = "response"
+	}
+	maxRepairs := out.MaxRepairs
+	if maxRepairs == 0 {
+		maxRepairs = DefaultMaxRepairs
+	}
+	params.ResponseFormat = JSONSchemaResponseFormat(name, out.Schema)
+	params.Messages = append([]Message(nil), params.Messages...)
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.CreateChatCompletion(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Choices) == 0 {
+			return nil, errors.New("no choices in response")
+		}
+		reply := resp.Choices[0].Message.Content
+		raw := extractJSON(reply)
+
+		var value interface{}
+		var errs []SchemaError
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			errs = []SchemaError{{Path: "$", Message: "invalid JSON: " + err.Error()}}
+		} else {
+			errs = ValidateJSONSchema(out.Schema, value)
+		}
+		if len(errs) == 0 {
+			return json.RawMessage(raw), nil
+		}
+		if attempt > max(maxRepairs, 0) {
+			return nil, &SchemaValidationError{Errors: errs, Output: reply, Attempts: attempt}
+		}
+
+		c.logger().InfoContext(ctx, "structured reply invalid, asking for a repair", "attempt", attempt, "errors", len(errs))
+		var feedback strings.Builder
+		feedback.WriteString("Your reply does not match the required JSON schema:\n")
+		for _, e := range errs {
+			fmt.Fprintf(&feedback, "- %s\n", e)
+		}
+		feedback.WriteString("Reply again with only the corrected JSON.")
+		params.Messages = append(params.Messages,
+			Message{Role: "assistant", Content: reply},
+			Message{Role: "user", Content: feedback.String()})
+	}
+}
+
+// StructuredCompletion runs CreateStructuredCompletion and decodes the valid
+// reply into a T
+func StructuredCompletion[T any](ctx context.Context, client *LlamaStackClient, params ChatCompletionParams, out StructuredOutput) (T, error) {
+	var result T
+	raw, err := client.CreateStructuredCompletion(ctx, params, out)
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return result, fmt.Errorf("failed to decode structured reply: %w", err)
+	}
+	return result, nil
+}
+
+// runStructuredCommand handles "structured": a completion whose reply must
+// match a JSON schema file, printed as indented JSON
+func runStructuredCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("structured", flag.ContinueOnError)
+	schemaPath := fs.String("schema", "", "JSON schema file the reply must match")
+	name := fs.String("name", "response", "name of the schema sent to the model")
+	repairs := fs.Int("repairs", DefaultMaxRepairs, "times an invalid reply is sent back with its errors")
+	model := fs.String("model", "", "model to ask; the first LLM by default")
+	stream := fs.Bool("stream", false, "print the reply as its fields complete; streamed replies are not repaired")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *schemaPath == "" {
+		return errors.New("usage: structured -schema FILE [-name NAME] [-repairs 2] [-stream] [-model MODEL] PROMPT")
+	}
+	data, err := os.ReadFile(*schemaPath)
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("invalid schema %s: %w", *schemaPath, err)
+	}
+	if *repairs == 0 {
+		*repairs = -1
+	}
+
+	ctx := context.Background()
+	if *model == "" {
+		if *model, err = client.GetAvailableModel(ctx); err != nil {
+			return fmt.Errorf("no model to ask: %w", err)
+		}
+	}
+	params := ChatCompletionParams{
+		Model:    *model,
+		Messages: []Message{{Role: "user", Content: fs.Arg(0)}},
+	}
+	out := StructuredOutput{Name: *name, Schema: schema, MaxRepairs: *repairs}
+	var invalid *SchemaValidationError
+	if *stream {
+		for partial, err := range StreamStructured[interface{}](ctx, client, params, out) {
+			if errors.As(err, &invalid) {
+				fmt.Printf("Last reply:\n%s\n", invalid.Output)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Println(compactJSON(partial))
+		}
+		return nil
+	}
+	raw, err := client.CreateStructuredCompletion(ctx, params, out)
+	if errors.As(err, &invalid) {
+		fmt.Printf("Last reply:\n%s\n", invalid.Output)
+	}
+	if err != nil {
+		return err
+	}
+	var pretty strings.Builder
+	enc := json.NewEncoder(&pretty)
+	enc.SetIndent("", "  ")
+	var value interface{}
+	json.Unmarshal(raw, &value)
+	enc.Encode(value)
+	fmt.Print(pretty.String())
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// decodeJSON decodes JSON written inline in a test
+func decodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("bad test JSON %s: %v", s, err)
+	}
+	return v
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		want   []string // "path: message" of every error
+	}{
+		{"valid", `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`, `{"name":"Dora"}`, nil},
+		{"wrong type", `{"type":"string"}`, `3`, []string{"$: expected string, got number"}},
+		{"type list", `{"type":["string","null"]}`, `true`, []string{"$: expected string or null, got boolean"}},
+		{"integer", `{"type":"integer"}`, `2.5`, []string{"$: expected integer, got number"}},
+		{"enum", `{"enum":["pug","spaniel"]}`, `"poodle"`, []string{`$: must be one of ["pug","spaniel"]`}},
+		{"const", `{"const":1}`, `2`, []string{"$: must be 1"}},
+		{"required and nested paths", `{"type":"object","required":["name","owner"],"properties":{"owner":{"type":"object","properties":{"age":{"minimum":18}}}}}`,
+			`{"owner":{"age":12}}`, []string{`$: missing required property "name"`, "$.owner.age: must be >= 18"}},
+		{"additional properties", `{"type":"object","properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"c":2,"b":3}`,
+			[]string{`$: unexpected property "b"`, `$: unexpected property "c"`}},
+		{"additional properties schema", `{"additionalProperties":{"type":"number"}}`, `{"x":"1"}`, []string{"$.x: expected number, got string"}},
+		{"array items", `{"type":"array","items":{"type":"string"},"minItems":4,"uniqueItems":true}`, `["a",1,"a"]`,
+			[]string{"$: must have at least 4 items", "$[2]: duplicates item 0", "$[1]: expected string, got number"}},
+		{"max items", `{"maxItems":1}`, `[1,2]`, []string{"$: must have at most 1 items"}},
+		{"string limits", `{"minLength":3,"maxLength":4,"pattern":"^[a-z]+$"}`, `"Ab"`,
+			[]string{"$: must be at least 3 characters long", "$: must match ^[a-z]+$"}},
+		{"runes not bytes", `{"maxLength":2}`, `"éé"`, nil},
+		{"number limits", `{"exclusiveMinimum":0,"exclusiveMaximum":10,"multipleOf":0.5}`, `10`, []string{"$: must be < 10"}},
+		{"multiple of", `{"multipleOf":0.1}`, `0.3`, nil},
+		{"not a multiple", `{"multipleOf":3}`, `7`, []string{"$: must be a multiple of 3"}},
+		{"anyOf", `{"anyOf":[{"type":"string"},{"type":"number"}]}`, `null`, []string{"$: must match at least one of the anyOf schemas"}},
+		{"oneOf", `{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `3`, []string{"$: must match exactly one of the oneOf schemas, matches 2"}},
+		{"allOf", `{"allOf":[{"minimum":1},{"maximum":2}]}`, `5`, []string{"$: must be <= 2"}},
+		{"not", `{"not":{"type":"null"}}`, `null`, []string{"$: must not match the schema in not"}},
+		{"ref", `{"$defs":{"dog":{"type":"object","required":["name"]}},"items":{"$ref":"#/$defs/dog"}}`, `[{"name":"Dora"},{}]`,
+			[]string{`$[1]: missing required property "name"`}},
+		{"unresolvable ref", `{"$ref":"#/$defs/missing"}`, `1`, []string{"$: unresolvable $ref #/$defs/missing"}},
+		{"remote ref", `{"$ref":"https://example.com/dog.json"}`, `1`, []string{"$: unsupported $ref https://example.com/dog.json: only references within the schema are"}},
+		{"format is ignored", `{"format":"email"}`, `"not an email"`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := decodeJSON(t, tt.schema).(map[string]interface{})
+			var got []string
+			for _, e := range ValidateJSONSchema(schema, decodeJSON(t, tt.value)) {
+				got = append(got, e.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("errors = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		reply, want string
+	}{
+		{`{"a":1}`, `{"a":1}`},
+		{"```json\n{\"a\":1}\n```", `{"a":1}`},
+		{"Here you go:\n```\n[1,2]\n```\nAnything else?", `[1,2]`},
+		{`Sure! {"a":{"b":2}} Hope that helps.`, `{"a":{"b":2}}`},
+		{`The list is [1, 2] as asked.`, `[1, 2]`},
+		{`{"a":`, `{"a":`},
+		{"no JSON at all", "no JSON at all"},
+	}
+	for _, tt := range tests {
+		if got := extractJSON(tt.reply); got != tt.want {
+			t.Errorf("extractJSON(%q) = %q, want %q", tt.reply, got, tt.want)
+		}
+	}
+}
+
+func TestCreateStructuredCompletionRepairs(t *testing.T) {
+	schema := decodeJSON(t, `{"type":"object","properties":{"age":{"type":"integer"}},"required":["age"]}`).(map[string]interface{})
+	tests := []struct {
+		name       string
+		replies    []string
+		maxRepairs int
+		want       string
+		attempts   int // for a failure, the completions made
+	}{
+		{name: "valid first time", replies: []string{"```json\n{\"age\": 3}\n```"}, want: `{"age": 3}`},
+		{name: "repaired", replies: []string{`{"age": "three"}`, `not JSON`, `{"age": 3}`}, want: `{"age": 3}`},
+		{name: "still invalid", replies: []string{`{}`, `{}`, `{}`}, attempts: 3},
+		{name: "no repairs", replies: []string{`{}`}, maxRepairs: -1, attempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockStack()
+			defer mock.Close()
+			mock.ChatReplies = append([]string(nil), tt.replies...)
+			client := NewLlamaStackClient(mock.URL(), "test")
+
+			raw, err := client.CreateStructuredCompletion(context.Background(), ChatCompletionParams{Model: "m"}, StructuredOutput{Schema: schema, MaxRepairs: tt.maxRepairs})
+			if tt.attempts == 0 {
+				if err != nil || string(raw) != tt.want {
+					t.Fatalf("got %s, %v, want %s", raw, err, tt.want)
+				}
+				if len(mock.ChatReplies) != 0 {
+					t.Fatalf("%d scripted replies left unused", len(mock.ChatReplies))
+				}
+				return
+			}
+			var verr *SchemaValidationError
+			if !errors.As(err, &verr) || verr.Attempts != tt.attempts || verr.Output != "{}" {
+				t.Fatalf("err = %v, want a *SchemaValidationError after %d attempts", err, tt.attempts)
+			}
+		})
+	}
+
+	if _, err := (&LlamaStackClient{}).CreateStructuredCompletion(context.Background(), ChatCompletionParams{}, StructuredOutput{}); err == nil {
+		t.Fatal("a structured completion without a schema was sent")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SyntheticDataFilter selects how generated samples are filtered by score
+type SyntheticDataFilter string
+
+const (
+	SyntheticFilterNone     SyntheticDataFilter = "none"
+	SyntheticFilterRandom   SyntheticDataFilter = "random"
+	SyntheticFilterTopK     SyntheticDataFilter = "top_k"
+	SyntheticFilterTopP     SyntheticDataFilter = "top_p"
+	SyntheticFilterTopKTopP SyntheticDataFilter = "top_k_top_p"
+	SyntheticFilterSigmoid  SyntheticDataFilter = "sigmoid"
+)
+
+func (f SyntheticDataFilter) valid() bool {
+	switch f {
+	case SyntheticFilterNone, SyntheticFilterRandom, SyntheticFilterTopK, SyntheticFilterTopP, SyntheticFilterTopKTopP, SyntheticFilterSigmoid:
+		return true
+	}
+	return false
+}
+
+// SyntheticDataRequest asks the synthetic-data-generation API for samples
+// seeded by a dialog
+type SyntheticDataRequest struct {
+	Dialogs           []Message           `json:"dialogs"`
+	FilteringFunction SyntheticDataFilter `json:"filtering_function"`
+	Model             string              `json:"model,omitempty"`
+}
+
+// SyntheticDataResponse holds the generated samples and, depending on the
+// provider, statistics about the generation and filtering
+type SyntheticDataResponse struct {
+	SyntheticData []map[string]interface{} `json:"synthetic_data"`
+	Statistics    map[string]interface{}   `json:"statistics,omitempty"`
+}
+
+// GenerateSyntheticData generates samples from a dialog. The API is only
+// served by distributions with a synthetic-data-generation provider; others
+// answer 404.
+func (c *LlamaStackClient) GenerateSyntheticData(ctx context.Context, params SyntheticDataRequest) (*SyntheticDataResponse, error) {
+	if len(params.Dialogs) == 0 {
+		return nil, errors.New("at least one dialog message is required")
+	}
+	if params.FilteringFunction == "" {
+		params.FilteringFunction = SyntheticFilterNone
+	}
+	if !params.FilteringFunction.valid() {
+		return nil, fmt.Errorf("unknown filtering function %q", params.FilteringFunction)
+	}
+	var response SyntheticDataResponse
+	if err := c.postJSON(ctx, "/v1/synthetic-data-generation/generate", params, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// WriteSyntheticData writes samples as JSONL, one object per line, ready for
+// UploadDataset
+func WriteSyntheticData(w io.Writer, samples []map[string]interface{}) error {
+	enc := json.NewEncoder(w)
+	for _, sample := range samples {
+		if err := enc.Encode(sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runSyntheticCommand handles "synthetic -prompt-file prompts.jsonl -o samples.jsonl"
+func runSyntheticCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("synthetic", flag.ContinueOnError)
+	model := fs.String("model", "", "model generating the samples; the provider's default when empty")
+	filter := fs.String("filter", string(SyntheticFilterNone), "filtering function: none, random, top_k, top_p, top_k_top_p or sigmoid")
+	promptFile := fs.String("prompt-file", "", "JSONL file with a \"prompt\" or \"messages\" per line, one request each")
+	prompt := fs.String("prompt", "", "a single seed prompt instead of -prompt-file")
+	output := fs.String("o", "", "append samples to this JSONL file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*promptFile == "") == (*prompt == "") || fs.NArg() != 0 {
+		return errors.New("usage: synthetic (-prompt TEXT | -prompt-file prompts.jsonl) [-model MODEL] [-filter top_k] [-o samples.jsonl]")
+	}
+
+	prompts := []BenchPrompt{{Prompt: *prompt}}
+	if *promptFile != "" {
+		var err error
+		if prompts, err = LoadBenchPrompts(*promptFile); err != nil {
+			return err
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open output: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	total := 0
+	for i, p := range prompts {
+		response, err := client.GenerateSyntheticData(context.Background(), SyntheticDataRequest{
+			Dialogs:           p.messages(),
+			FilteringFunction: SyntheticDataFilter(*filter),
+			Model:             *model,
+		})
+		if err != nil {
+			return fmt.Errorf("prompt %d: %w", i+1, err)
+		}
+		if err := WriteSyntheticData(w, response.SyntheticData); err != nil {
+			return fmt.Errorf("failed to write samples: %w", err)
+		}
+		total += len(response.SyntheticData)
+	}
+	if *output != "" {
+		fmt.Printf("Wrote %d samples from %d prompts to %s\n", total, len(prompts), *output)
+	}
+	return nil
+}
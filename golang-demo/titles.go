@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TitleStore keeps the titles of chat conversations and agent sessions in a
+// local JSON file, keyed by session ID or any other conversation ID. Llama
+// Stack cannot rename a session once it is created, so titles generated
+// after the first exchange live here.
+type TitleStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultTitleStorePath is the title store in the user's cache directory
+func DefaultTitleStorePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, PlaygroundTag, "titles.json"), nil
+}
+
+// OpenTitleStore opens or creates the title store at path
+func OpenTitleStore(path string) (*TitleStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create title store: %w", err)
+	}
+	return &TitleStore{path: path}, nil
+}
+
+// Get returns the title of a conversation, or "" if it has none
+func (s *TitleStore) Get(id string) string {
+	titles, _ := s.All()
+	return titles[id]
+}
+
+// All returns every stored title by conversation ID
+func (s *TitleStore) All() (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+func (s *TitleStore) read() (map[string]string, error) {
+	titles := make(map[string]string)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return titles, nil
+	}
+	if err != nil {
+		return titles, err
+	}
+	if err := json.Unmarshal(data, &titles); err != nil {
+		return titles, fmt.Errorf("invalid title store %s: %w", s.path, err)
+	}
+	return titles, nil
+}
+
+// Set stores the title of a conversation, replacing any earlier one
+func (s *TitleStore) Set(id, title string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	titles, err := s.read()
+	if err != nil {
+		return err
+	}
+	titles[id] = title
+	data, err := json.MarshalIndent(titles, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write title store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write title store: %w", err)
+	}
+	return nil
+}
+
+// maxTitleRunes caps generated titles; models do not always keep to the
+// word limit they are given
+const maxTitleRunes = 60
+
+// GenerateTitle asks model for a short title summing up messages, usually
+// the first exchange of a conversation
+func GenerateTitle(ctx context.Context, client *LlamaStackClient, model string, messages []Message) (string, error) {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			continue
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, msg.Content)
+	}
+	maxTokens := 20
+	resp, err := client.CreateChatCompletion(ctx, ChatCompletionParams{
+		Model: model,
+		Messages: []Message{
+			{Role: "system", Content: "Write a title of at most six words for the conversation below. Reply with the title only, without quotes."},
+			{Role: "user", Content: transcript.String()},
+		},
+		MaxTokens: &maxTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate title: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("failed to generate title: no choices in response")
+	}
+	title := cleanTitle(resp.Choices[0].Message.Content)
+	if title == "" {
+		return "", errors.New("failed to generate title: empty reply")
+	}
+	return title, nil
+}
+
+// cleanTitle strips what models like to wrap titles in: a "Title:" label,
+// quotes, markdown and a final period
+func cleanTitle(reply string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(reply), "\n")
+	title = strings.TrimSpace(title)
+	if label, rest, ok := strings.Cut(title, ":"); ok && strings.EqualFold(strings.TrimSpace(label), "title") {
+		title = rest
+	}
+	title = strings.Trim(title, " \"'`*#_")
+	title = strings.TrimSuffix(title, ".")
+	if r := []rune(title); len(r) > maxTitleRunes {
+		title = strings.TrimSpace(string(r[:maxTitleRunes])) + "..."
+	}
+	return title
+}
+
+// AutoTitler titles conversations in the background after their first
+// exchange, so saved conversations can be told apart. Each conversation is
+// titled once; conversations the store already has a title for are skipped.
+type AutoTitler struct {
+	Client *LlamaStackClient
+	Model  string // defaults to the first LLM
+	// Store, if set, receives the titles; defaults to Client.Titles
+	Store *TitleStore
+	// OnTitle, if set, is called with each new title from the background
+	// goroutine that generated it
+	OnTitle func(id, title string)
+	// Timeout bounds each title completion; defaults to 30 seconds
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	titled map[string]bool
+	wg     sync.WaitGroup
+}
+
+// Title starts titling conversation id from messages unless it has been
+// titled before, and returns at once. Failures are logged with the
+// client's logger; a conversation without a title is no reason to fail.
+func (t *AutoTitler) Title(id string, messages []Message) {
+	store := t.Store
+	if store == nil {
+		store = t.Client.Titles
+	}
+	t.mu.Lock()
+	if t.titled == nil {
+		t.titled = make(map[string]bool)
+	}
+	if t.titled[id] || (store != nil && store.Get(id) != "") {
+		t.mu.Unlock()
+		return
+	}
+	t.titled[id] = true
+	t.mu.Unlock()
+
+	messages = append([]Message(nil), messages...)
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		timeout := t.Timeout
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		title, err := t.generate(ctx, messages)
+		if err == nil && store != nil {
+			err = store.Set(id, title)
+		}
+		if err != nil {
+			t.Client.logger().Warn("failed to title conversation", "id", id, "error", err)
+			return
+		}
+		if t.OnTitle != nil {
+			t.OnTitle(id, title)
+		}
+	}()
+}
+
+func (t *AutoTitler) generate(ctx context.Context, messages []Message) (string, error) {
+	model := t.Model
+	if model == "" {
+		var err error
+		if model, err = t.Client.GetAvailableModel(ctx); err != nil {
+			return "", err
+		}
+	}
+	return GenerateTitle(ctx, t.Client, model, messages)
+}
+
+// Wait waits for the titles being generated, e.g. before the program exits
+func (t *AutoTitler) Wait() {
+	t.wg.Wait()
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenizerProfile describes how a model family's BPE vocabulary splits text
+// closely enough to estimate counts without the vocabulary itself
+type tokenizerProfile struct {
+	name string
+	// wordLetters is the length up to which a word, with its leading space,
+	// is usually one token; every further letterRun letters add one more
+	wordLetters int
+	letterRun   int
+	// digitRun is how many digits make one token
+	digitRun int
+	// spaceRun is how many spaces of indentation make one token
+	spaceRun int
+	// scriptRunes is how many letters of alphabetic scripts other than Latin
+	// make one token; ideographs are a token each
+	scriptRunes int
+}
+
+var (
+	// Llama 3 and 4 use a 128K tiktoken-style vocabulary: common words are
+	// one token, numbers are split into groups of three digits
+	llama3Tokenizer = tokenizerProfile{name: "llama3", wordLetters: 8, letterRun: 4, digitRun: 3, spaceRun: 8, scriptRunes: 3}
+	// Llama 2 and Code Llama use a 32K SentencePiece vocabulary that splits
+	// longer words and every digit
+	llama2Tokenizer = tokenizerProfile{name: "llama2", wordLetters: 5, letterRun: 3, digitRun: 1, spaceRun: 4, scriptRunes: 2}
+)
+
+// tokenizerFor picks the profile for a model ID such as
+// "meta-llama/Llama-3.2-3B-Instruct"; models of other families, and "", get
+// the Llama 3 profile, which is close for most recent vocabularies
+func tokenizerFor(model string) tokenizerProfile {
+	name := strings.NewReplacer("-", "", "_", "", ".", "", " ", "").Replace(strings.ToLower(model))
+	if strings.Contains(name, "llama2") || strings.Contains(name, "codellama") {
+		return llama2Tokenizer
+	}
+	return llama3Tokenizer
+}
+
+// approximateTokens splits text the way Llama pre-tokenizers do, into words
+// with their leading space, digit groups, punctuation runs and whitespace,
+// and estimates the BPE tokens of each piece
+func approximateTokens(p tokenizerProfile, text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r == ' ' && attachesSpace(text[i+size:]) {
+			// A single space is part of the word or punctuation after it
+			i += size
+			r, size = utf8.DecodeRuneInString(text[i:])
+		}
+		switch {
+		case r == '\'' && contractionLen(text[i:]) > 0:
+			// 's, 't, 're, 've, 'm, 'll and 'd are tokens of their own
+			i += contractionLen(text[i:])
+			tokens++
+		case unicode.IsLetter(r):
+			start := i
+			latin, ideographs, other := 0, 0, 0
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsLetter(r) {
+					break
+				}
+				switch {
+				case r < utf8.RuneSelf:
+					latin++
+				case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+					ideographs++
+				default:
+					other++
+				}
+				i += size
+			}
+			tokens += wordTokens(p, latin, text[start:i]) + ideographs + ceilDiv(other, p.scriptRunes)
+		case unicode.IsDigit(r):
+			digits := 0
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsDigit(r) {
+					break
+				}
+				digits++
+				i += size
+			}
+			tokens += ceilDiv(digits, p.digitRun)
+		case unicode.IsSpace(r):
+			spaces, newlines := 0, false
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsSpace(r) || (r == ' ' && attachesSpace(text[i+size:])) {
+					break
+				}
+				if r == '\n' || r == '\r' {
+					newlines = true
+				} else {
+					spaces++
+				}
+				i += size
+			}
+			// A run of newlines is one token, indentation after it another
+			if newlines {
+				tokens++
+			}
+			tokens += ceilDiv(spaces, p.spaceRun)
+		default:
+			// Punctuation and symbols merge in pairs, e.g. "),", "**" or "{}"
+			marks := 0
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+					break
+				}
+				// Symbols outside ASCII, such as emoji, take a token per UTF-8
+				// byte pair
+				marks += max(1, size/2)
+				i += size
+			}
+			tokens += ceilDiv(marks, 2)
+		}
+	}
+	return tokens
+}
+
+// wordTokens estimates the tokens of a word of latin letters. Capitalized
+// words are as cheap as lowercase ones, but all-caps words split much more.
+func wordTokens(p tokenizerProfile, letters int, word string) int {
+	if letters == 0 {
+		return 0
+	}
+	if letters > 3 && strings.ToUpper(word) == word {
+		return ceilDiv(letters, 3)
+	}
+	if letters <= p.wordLetters {
+		return 1
+	}
+	return 1 + ceilDiv(letters-p.wordLetters, p.letterRun)
+}
+
+// attachesSpace reports whether text starts with a letter or punctuation, so
+// a space before it belongs to the same token
+func attachesSpace(text string) bool {
+	if text == "" {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(text)
+	return !unicode.IsSpace(r) && !unicode.IsDigit(r)
+}
+
+// contractionLen returns the length of the English contraction text starts
+// with, or 0
+func contractionLen(text string) int {
+	for _, c := range []string{"'ll", "'re", "'ve", "'s", "'t", "'m", "'d"} {
+		if len(text) >= len(c) && strings.EqualFold(text[:len(c)], c) {
+			if r, _ := utf8.DecodeRuneInString(text[len(c):]); !unicode.IsLetter(r) {
+				return len(c)
+			}
+		}
+	}
+	return 0
+}
+
+func ceilDiv(n, d int) int {
+	return (n + d - 1) / d
+}
+
+// TokenCounter estimates token counts without the model's vocabulary, from
+// an approximation of Llama BPE tokenizers. Calibrating it with the usage
+// the server reports scales its estimates per model, which corrects for
+// chat templates and models whose vocabulary differs. It is safe for
+// concurrent use.
+type TokenCounter struct {
+	mu     sync.Mutex
+	scales map[string]tokenScale
+}
+
+// tokenScale is the calibration of one model
+type tokenScale struct {
+	factor  float64
+	samples int
+}
+
+// DefaultTokenCounter is used by CountTokens, and by ContextManager and
+// ChunkText when no CountTokens func is given
+var DefaultTokenCounter = &TokenCounter{}
+
+// CountTokens estimates the tokens text takes up for model with
+// DefaultTokenCounter. Estimates are closest for Llama models; calibrate with
+// server usage where the count matters.
+func CountTokens(model, text string) int {
+	return DefaultTokenCounter.Count(model, text)
+}
+
+// Count estimates the tokens text takes up for model, scaled by what
+// calibration has learned about model
+func (tc *TokenCounter) Count(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	n := approximateTokens(tokenizerFor(model), text)
+	return max(1, int(math.Round(float64(n)*tc.Scale(model))))
+}
+
+// CountMessages estimates the prompt tokens of a chat completion sending
+// messages to model, including the chat template around each message
+func (tc *TokenCounter) CountMessages(model string, messages []Message) int {
+	return int(math.Round(float64(rawMessageTokens(model, messages)) * tc.Scale(model)))
+}
+
+// rawMessageTokens is the uncalibrated estimate CountMessages scales
+func rawMessageTokens(model string, messages []Message) int {
+	p := tokenizerFor(model)
+	total := 0
+	for _, msg := range messages {
+		total += approximateTokens(p, msg.Content) + messageOverheadTokens
+	}
+	return total
+}
+
+// Scale returns the factor estimates for model are multiplied by; 1 until
+// model has been calibrated
+func (tc *TokenCounter) Scale(model string) float64 {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if s, ok := tc.scales[model]; ok {
+		return s.factor
+	}
+	return 1
+}
+
+// Calibrate teaches the counter that text the uncalibrated estimate put at
+// estimated tokens was reported by the server as reported tokens. The first
+// observation sets the scale; later ones move it a third of the way, so one
+// odd response does not undo the rest. Ratios beyond 4x either way are
+// ignored as not being about the same text.
+func (tc *TokenCounter) Calibrate(model string, estimated, reported int) {
+	if estimated <= 0 || reported <= 0 {
+		return
+	}
+	ratio := float64(reported) / float64(estimated)
+	if ratio > 4 || ratio < 0.25 {
+		return
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.scales == nil {
+		tc.scales = make(map[string]tokenScale)
+	}
+	s, ok := tc.scales[model]
+	if !ok {
+		s.factor = ratio
+	} else {
+		s.factor += (ratio - s.factor) / 3
+	}
+	s.samples++
+	tc.scales[model] = s
+}
+
+// CalibrateMessages calibrates model with the prompt tokens the server
+// reported for a chat completion of messages
+func (tc *TokenCounter) CalibrateMessages(model string, messages []Message, promptTokens int) {
+	tc.Calibrate(model, rawMessageTokens(model, messages), promptTokens)
+}
+
+// Samples returns how many observations model's scale is based on
+func (tc *TokenCounter) Samples(model string) int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.scales[model].samples
+}
+
+// WithTokenCalibration makes CreateChatCompletion calibrate counter with the
+// prompt usage of every response, so its estimates, and those of context
+// managers and chunkers using it, track the server's tokenizer
+func WithTokenCalibration(counter *TokenCounter) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.TokenCounter = counter
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApproximateTokens(t *testing.T) {
+	tests := []struct {
+		text           string
+		llama3, llama2 int
+	}{
+		{"", 0, 0},
+		{"Dora", 1, 1},
+		// Words take their leading space; the period is its own token
+		{"Dora is a pug.", 5, 5},
+		// Long words split into runs of letters
+		{"internationalization", 4, 6},
+		// All-caps words split into threes
+		{"NASA", 2, 2},
+		// Llama 3 groups digits in threes, Llama 2 splits every one
+		{"1234567", 3, 7},
+		{"don't", 2, 2},
+		// Newlines are one token, the indentation after them another, and the
+		// last space goes with the word
+		{"\n\n    x", 3, 3},
+		{"日本語", 3, 3},
+		{"Привет", 2, 3},
+		{"),;", 2, 2},
+	}
+	for _, tt := range tests {
+		if got := approximateTokens(llama3Tokenizer, tt.text); got != tt.llama3 {
+			t.Errorf("llama3 tokens of %q = %d, want %d", tt.text, got, tt.llama3)
+		}
+		if got := approximateTokens(llama2Tokenizer, tt.text); got != tt.llama2 {
+			t.Errorf("llama2 tokens of %q = %d, want %d", tt.text, got, tt.llama2)
+		}
+	}
+}
+
+func TestTokenizerFor(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"meta-llama/Llama-3.2-3B-Instruct", "llama3"},
+		{"meta-llama/Llama-2-7b-chat", "llama2"},
+		{"codellama:13b", "llama2"},
+		{"ollama/llama3.2:3b", "llama3"},
+		{"", "llama3"},
+	}
+	for _, tt := range tests {
+		if got := tokenizerFor(tt.model).name; got != tt.want {
+			t.Errorf("tokenizerFor(%q) = %s, want %s", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestTokenCounterCalibration(t *testing.T) {
+	tc := &TokenCounter{}
+	const model = "m"
+	text := "Dora is a pug owned by Ana, who lives in Campinas."
+	raw := tc.Count(model, text)
+	if tc.Scale(model) != 1 || tc.Count(model, "") != 0 || tc.Count(model, ".") != 1 {
+		t.Fatal("an uncalibrated counter scales its estimates")
+	}
+
+	steps := []struct {
+		estimated, reported int
+		want                float64 // scale afterwards
+	}{
+		{100, 120, 1.2}, // the first observation sets the scale
+		{100, 150, 1.3}, // later ones move it a third of the way
+		{100, 500, 1.3}, // ratios beyond 4x are ignored
+		{100, 20, 1.3},
+		{0, 50, 1.3},
+	}
+	for _, s := range steps {
+		tc.Calibrate(model, s.estimated, s.reported)
+		if got := tc.Scale(model); math.Abs(got-s.want) > 1e-9 {
+			t.Fatalf("after %d/%d scale = %v, want %v", s.reported, s.estimated, got, s.want)
+		}
+	}
+	if n := tc.Samples(model); n != 2 {
+		t.Fatalf("Samples = %d, want 2", n)
+	}
+	if got, want := tc.Count(model, text), int(math.Round(float64(raw)*1.3)); got != want {
+		t.Fatalf("calibrated Count = %d, want %d", got, want)
+	}
+	if tc.Scale("other") != 1 {
+		t.Fatal("calibration leaked to another model")
+	}
+
+	messages := []Message{{Role: "user", Content: "Who is Dora?"}}
+	if got, want := (&TokenCounter{}).CountMessages(model, messages), approximateTokens(llama3Tokenizer, "Who is Dora?")+messageOverheadTokens; got != want {
+		t.Fatalf("CountMessages = %d, want %d", got, want)
+	}
+}
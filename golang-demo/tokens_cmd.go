@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// runTokensCommand handles "tokens": it estimates the tokens of a file, or
+// stdin, and with -calibrate compares the estimate with the prompt usage the
+// server reports for the same text
+func runTokensCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	model := fs.String("model", "", "model whose tokenizer to estimate; the first LLM with -calibrate")
+	calibrate := fs.Bool("calibrate", false, "send the text as a chat completion and calibrate with the reported usage")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var text []byte
+	var err error
+	switch fs.NArg() {
+	case 0:
+		text, err = io.ReadAll(os.Stdin)
+	case 1:
+		text, err = os.ReadFile(fs.Arg(0))
+	default:
+		return errors.New("usage: tokens [-model MODEL] [-calibrate] [FILE]")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read text: %w", err)
+	}
+
+	ctx := context.Background()
+	if *calibrate && *model == "" {
+		if *model, err = client.GetAvailableModel(ctx); err != nil {
+			return fmt.Errorf("no model to calibrate with: %w", err)
+		}
+	}
+	estimate := CountTokens(*model, string(text))
+	fmt.Printf("%d tokens (%s tokenizer estimate, %d characters)\n", estimate, tokenizerFor(*model).name, utf8.RuneCount(text))
+	if !*calibrate {
+		return nil
+	}
+
+	counter := &TokenCounter{}
+	messages := []Message{{Role: "user", Content: string(text)}}
+	maxTokens := 1
+	client.TokenCounter = counter
+	resp, err := client.CreateChatCompletion(ctx, ChatCompletionParams{Model: *model, Messages: messages, MaxTokens: &maxTokens})
+	if err != nil {
+		return fmt.Errorf("failed to calibrate: %w", err)
+	}
+	if resp.Usage == nil {
+		return fmt.Errorf("%s reported no usage to calibrate with", *model)
+	}
+	fmt.Printf("%s reported %d prompt tokens for the text as a chat message, estimated %d\n",
+		*model, resp.Usage.PromptTokens, DefaultTokenCounter.CountMessages(*model, messages))
+	if counter.Samples(*model) == 0 {
+		fmt.Println("The counts are too far apart to calibrate with")
+		return nil
+	}
+	fmt.Printf("Calibrated scale: %.2f, so the text is about %d tokens\n", counter.Scale(*model), counter.Count(*model, string(text)))
+	return nil
+}
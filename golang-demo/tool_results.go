@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ToolResultTransformer post-processes the text output of a client-side
+// tool call before it is sent back to the model, e.g. to keep it within a
+// token budget
+type ToolResultTransformer func(ctx context.Context, call AgentToolCall, text string) (string, error)
+
+// ChainToolResults runs transformers one after another
+func ChainToolResults(transformers ...ToolResultTransformer) ToolResultTransformer {
+	return func(ctx context.Context, call AgentToolCall, text string) (string, error) {
+		for _, t := range transformers {
+			var err error
+			if text, err = t(ctx, call, text); err != nil {
+				return "", err
+			}
+		}
+		return text, nil
+	}
+}
+
+// htmlMarkupRe detects output that is an HTML page or fragment rather than
+// text that merely contains angle brackets
+var htmlMarkupRe = regexp.MustCompile(`(?i)<(!doctype|html|head|body|div|p|br|span|a|table|ul|li|h[1-6])\b[^>]*>`)
+
+// StripHTMLToolResults reduces HTML output, such as a fetched web page, to
+// its visible text. Other output is passed through.
+func StripHTMLToolResults() ToolResultTransformer {
+	return func(ctx context.Context, call AgentToolCall, text string) (string, error) {
+		if !htmlMarkupRe.MatchString(text) {
+			return text, nil
+		}
+		return htmlVisibleText(htmlTitleRe.ReplaceAllString(text, "")), nil
+	}
+}
+
+// TruncateToolResults cuts output longer than maxTokens tokens of model at a
+// line or word boundary and notes how much was left out, so the model knows
+// the result is partial
+func TruncateToolResults(model string, maxTokens int) ToolResultTransformer {
+	return func(ctx context.Context, call AgentToolCall, text string) (string, error) {
+		total := CountTokens(model, text)
+		if total <= maxTokens {
+			return text, nil
+		}
+		cut := truncateToTokens(model, text, maxTokens)
+		return fmt.Sprintf("%s\n[truncated: showing about %d of %d tokens]", cut, CountTokens(model, cut), total), nil
+	}
+}
+
+// truncateToTokens returns the longest prefix of text within maxTokens,
+// shortened to the last line or word break
+func truncateToTokens(model, text string, maxTokens int) string {
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if CountTokens(model, string(runes[:mid])) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	cut := string(runes[:lo])
+	if i := strings.LastIndex(cut, "\n"); i > len(cut)/2 {
+		cut = cut[:i]
+	} else if i := strings.LastIndexAny(cut, " \t"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \t\n")
+}
+
+// SummarizeToolResults has model summarize output longer than maxTokens
+// tokens, keeping what bears on the call's arguments, e.g. the query of a
+// knowledge_search. Shorter output is passed through.
+func SummarizeToolResults(client *LlamaStackClient, model string, maxTokens int) ToolResultTransformer {
+	return func(ctx context.Context, call AgentToolCall, text string) (string, error) {
+		if CountTokens(model, text) <= maxTokens {
+			return text, nil
+		}
+		resp, err := client.CreateChatCompletion(ctx, ChatCompletionParams{
+			Model: model,
+			Messages: []Message{
+				{Role: "system", Content: fmt.Sprintf("Summarize the output of the %s tool below in at most %d tokens. Keep every fact, name, number and source that bears on the call's arguments: %v", call.ToolName, maxTokens, call.Arguments)},
+				{Role: "user", Content: text},
+			},
+			MaxTokens: &maxTokens,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to summarize %s result: %w", call.ToolName, err)
+		}
+		if len(resp.Choices) == 0 {
+			return "", errors.New("failed to summarize tool result: no choices in response")
+		}
+		return "Summary of the tool output: " + resp.Choices[0].Message.Content, nil
+	}
+}
+
+// transformToolResult applies the transformer of the tool registered as
+// tool. A failing transformer is logged and the output sent unchanged, so a
+// summarizer outage does not fail the call.
+func (r *AgentRunner) transformToolResult(ctx context.Context, tool string, call AgentToolCall, text string) string {
+	transform, ok := r.ToolResultTransformers[tool]
+	if !ok {
+		transform = r.ToolResultTransformer
+	}
+	if transform == nil {
+		return text
+	}
+	transformed, err := transform(ctx, call, text)
+	if err != nil {
+		r.Client.logger().WarnContext(ctx, "failed to transform tool result", "tool", call.ToolName, "call_id", call.CallID, "error", err)
+		return text
+	}
+	return transformed
+}
@@ -0,0 +1,390 @@
+// Command openapigen generates Go models from the Llama Stack OpenAPI document.
+//
+// Every component schema becomes a Spec-prefixed type so generated models can
+// sit next to the hand-written ones in package main. With -check it instead
+// compares hand-written request structs against the spec and reports drift.
+//
+// Usage (from golang-demo):
+//
+//	go run tools/openapigen/main.go -spec ../openapi.json -out openapi_types_gen.go
+//	go run tools/openapigen/main.go -spec ../openapi.json -check
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// handWritten maps hand-written types to the spec schema they mirror
+var handWritten = map[string]string{
+	"AgentConfig":          "AgentConfig",
+	"SamplingParams":       "SamplingParams",
+	"Document":             "RAGDocument",
+	"RagToolInsertParams":  "Body_insert_v1_tool_runtime_rag_tool_insert_post",
+	"RagToolQueryParams":   "Body_query_v1_tool_runtime_rag_tool_query_post",
+	"ChatCompletionParams": "Body_openai_chat_completion_v1_openai_v1_chat_completions_post",
+	"CompletionParams":     "Body_openai_completion_v1_openai_v1_completions_post",
+	"SessionCreateParams":  "Body_create_agent_session_v1_agents__agent_id__session_post",
+	"TurnCreateParams":     "Body_create_agent_turn_v1_agents__agent_id__session__session_id__turn_post",
+	"TurnDocument":         "Document",
+	"RAGQueryConfig":       "RAGQueryConfig",
+}
+
+// initialisms are upper-cased as a whole when converting names
+var initialisms = map[string]bool{
+	"id": true, "ids": true, "url": true, "uri": true, "api": true, "json": true,
+	"http": true, "uuid": true, "llm": true, "rag": true, "mcp": true, "db": true,
+	"rrf": true, "ttl": true, "sql": true, "ip": true,
+}
+
+// operatorNames names enum values that are pure symbols
+var operatorNames = map[string]string{
+	"=":  "Equal",
+	"!=": "NotEqual",
+	"=~": "RegexMatch",
+	"!~": "RegexNotMatch",
+	">":  "GreaterThan",
+	">=": "GreaterThanOrEqual",
+	"<":  "LessThan",
+	"<=": "LessThanOrEqual",
+}
+
+// schema is the subset of JSON Schema used by the Llama Stack spec
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Title                string             `json:"title"`
+	Description          string             `json:"description"`
+	Enum                 []interface{}      `json:"enum"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	AnyOf                []*schema          `json:"anyOf"`
+	OneOf                []*schema          `json:"oneOf"`
+	AdditionalProperties interface{}        `json:"additionalProperties"`
+}
+
+type spec struct {
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+func main() {
+	specPath := flag.String("spec", "../openapi.json", "path to the Llama Stack OpenAPI document")
+	out := flag.String("out", "openapi_types_gen.go", "generated file to write")
+	check := flag.Bool("check", false, "report drift between hand-written types and the spec instead of generating")
+	dir := flag.String("dir", ".", "directory holding the hand-written client, used with -check")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		fatalf("failed to read spec: %v", err)
+	}
+	var s spec
+	if err := json.Unmarshal(data, &s); err != nil {
+		fatalf("failed to decode spec: %v", err)
+	}
+
+	if *check {
+		drift, err := checkDrift(s, *dir, *out)
+		if err != nil {
+			fatalf("drift check failed: %v", err)
+		}
+		for _, line := range drift {
+			fmt.Println(line)
+		}
+		if len(drift) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	src, err := generate(s, *specPath)
+	if err != nil {
+		fatalf("generation failed: %v", err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		fatalf("failed to write %s: %v", *out, err)
+	}
+	fmt.Printf("wrote %d schemas to %s\n", len(s.Components.Schemas), *out)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "openapigen: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// generate renders every component schema as Go source
+func generate(s spec, specPath string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by tools/openapigen from %s; DO NOT EDIT.\n\n", filepath.Base(specPath))
+	buf.WriteString("package main\n\n")
+
+	names := make([]string, 0, len(s.Components.Schemas))
+	for name := range s.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		writeSchema(&buf, typeName(name), s.Components.Schemas[name])
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// writeSchema emits one named type
+func writeSchema(buf *bytes.Buffer, name string, sc *schema) {
+	fmt.Fprintf(buf, "// %s %s\n", name, summary(sc))
+
+	if len(sc.Enum) > 0 && sc.Type == "string" {
+		fmt.Fprintf(buf, "type %s string\n\n", name)
+		buf.WriteString("const (\n")
+		for _, v := range sc.Enum {
+			value := fmt.Sprint(v)
+			constName := goName(value)
+			if op, ok := operatorNames[value]; ok {
+				constName = op
+			}
+			fmt.Fprintf(buf, "\t%s%s %s = %s\n", name, constName, name, strconv.Quote(value))
+		}
+		buf.WriteString(")\n\n")
+		return
+	}
+
+	if len(sc.Properties) == 0 {
+		fmt.Fprintf(buf, "type %s %s\n\n", name, goType(sc, true))
+		return
+	}
+
+	required := make(map[string]bool, len(sc.Required))
+	for _, r := range sc.Required {
+		required[r] = true
+	}
+	props := make([]string, 0, len(sc.Properties))
+	for p := range sc.Properties {
+		props = append(props, p)
+	}
+	sort.Strings(props)
+
+	fmt.Fprintf(buf, "type %s struct {\n", name)
+	for _, p := range props {
+		tag := p
+		if !required[p] {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(buf, "\t%s %s `json:%q`\n", goName(p), goType(sc.Properties[p], required[p]), tag)
+	}
+	buf.WriteString("}\n\n")
+}
+
+// summary returns a one-line doc comment body for a schema
+func summary(sc *schema) string {
+	desc := sc.Description
+	if i := strings.Index(desc, "\n\n"); i >= 0 {
+		desc = desc[:i]
+	}
+	desc = strings.Join(strings.Fields(desc), " ")
+	if desc == "" {
+		return "is generated from the " + sc.Title + " schema"
+	}
+	return "is generated from the " + sc.Title + " schema: " + desc
+}
+
+// goType maps a schema to a Go type expression
+func goType(sc *schema, required bool) string {
+	if sc.Ref != "" {
+		ref := typeName(strings.TrimPrefix(sc.Ref, "#/components/schemas/"))
+		if required {
+			return ref
+		}
+		return "*" + ref
+	}
+
+	// anyOf [X, null] is an optional X; any other union stays untyped
+	variants := sc.AnyOf
+	if len(variants) == 0 {
+		variants = sc.OneOf
+	}
+	if len(variants) > 0 {
+		var nonNull []*schema
+		for _, v := range variants {
+			if v.Type != "null" {
+				nonNull = append(nonNull, v)
+			}
+		}
+		if len(nonNull) == 1 {
+			return goType(nonNull[0], false)
+		}
+		return "interface{}"
+	}
+
+	switch sc.Type {
+	case "string":
+		return optional("string", required)
+	case "integer":
+		return optional("int", required)
+	case "number":
+		return optional("float64", required)
+	case "boolean":
+		return optional("bool", required)
+	case "array":
+		if sc.Items == nil {
+			return "[]interface{}"
+		}
+		return "[]" + strings.TrimPrefix(goType(sc.Items, true), "*")
+	case "object":
+		return "map[string]interface{}"
+	}
+	return "interface{}"
+}
+
+// optional turns scalars into pointers so unset values are omitted
+func optional(t string, required bool) string {
+	if required {
+		return t
+	}
+	return "*" + t
+}
+
+// typeName derives the Go type name for a component schema. FastAPI body
+// schemas like Body_create_agent_v1_agents_post become SpecCreateAgentRequest.
+func typeName(schemaName string) string {
+	if rest, ok := strings.CutPrefix(schemaName, "Body_"); ok {
+		if i := strings.Index(rest, "_v1_"); i >= 0 {
+			rest = rest[:i]
+		}
+		return "Spec" + goName(rest) + "Request"
+	}
+	return "Spec" + goName(strings.TrimLeft(schemaName, "_"))
+}
+
+// goName converts snake_case, kebab-case or dotted names to an exported identifier
+func goName(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, p := range parts {
+		if initialisms[strings.ToLower(p)] {
+			b.WriteString(strings.ToUpper(p))
+			continue
+		}
+		runes := []rune(p)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "V" + name
+	}
+	return name
+}
+
+// checkDrift compares JSON fields of hand-written structs with their spec schemas
+func checkDrift(s spec, dir, generated string) ([]string, error) {
+	fields, err := structFields(dir, generated)
+	if err != nil {
+		return nil, err
+	}
+
+	goNames := make([]string, 0, len(handWritten))
+	for name := range handWritten {
+		goNames = append(goNames, name)
+	}
+	sort.Strings(goNames)
+
+	var drift []string
+	for _, goName := range goNames {
+		schemaName := handWritten[goName]
+		sc, ok := s.Components.Schemas[schemaName]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("%s: schema %s not found in spec", goName, schemaName))
+			continue
+		}
+		have, ok := fields[goName]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("%s: type not found in %s", goName, dir))
+			continue
+		}
+
+		var missing, extra []string
+		for p := range sc.Properties {
+			if !have[p] {
+				missing = append(missing, p)
+			}
+		}
+		for f := range have {
+			if _, ok := sc.Properties[f]; !ok {
+				extra = append(extra, f)
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(extra)
+		if len(missing) > 0 {
+			drift = append(drift, fmt.Sprintf("%s: missing spec fields %s", goName, strings.Join(missing, ", ")))
+		}
+		if len(extra) > 0 {
+			drift = append(drift, fmt.Sprintf("%s: fields not in spec %s", goName, strings.Join(extra, ", ")))
+		}
+	}
+	return drift, nil
+}
+
+// structFields returns the JSON field names of every struct declared in dir
+func structFields(dir, skip string) (map[string]map[string]bool, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	result := make(map[string]map[string]bool)
+	for _, path := range paths {
+		if filepath.Base(path) == filepath.Base(skip) || strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			ts, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			names := make(map[string]bool)
+			for _, f := range st.Fields.List {
+				if f.Tag == nil {
+					continue
+				}
+				tag, _ := strconv.Unquote(f.Tag.Value)
+				name, _, _ := strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+				if name != "" && name != "-" {
+					names[name] = true
+				}
+			}
+			result[ts.Name.Name] = names
+			return true
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// transcriptSnippetLength bounds tool results and chunks in text transcripts
+const transcriptSnippetLength = 160
+
+// TurnTranscript is a completed agent turn broken down for reading: its
+// input, each step with its tool calls, results and retrieved chunks, the
+// final answer and timings
+type TurnTranscript struct {
+	TurnID     string           `json:"turn_id"`
+	SessionID  string           `json:"session_id,omitempty"`
+	Input      []Message        `json:"input"`
+	Steps      []TranscriptStep `json:"steps"`
+	Answer     string           `json:"answer"`
+	DurationMS int64            `json:"duration_ms,omitempty"`
+}
+
+// TranscriptStep is one step of a turn
+type TranscriptStep struct {
+	Type        string                 `json:"type"` // inference, tool_execution, shield_call or memory_retrieval
+	StepID      string                 `json:"step_id,omitempty"`
+	DurationMS  int64                  `json:"duration_ms,omitempty"`
+	Text        string                 `json:"text,omitempty"` // model output of an inference step
+	ToolCalls   []ToolCall             `json:"tool_calls,omitempty"`
+	ToolResults []TranscriptToolResult `json:"tool_results,omitempty"`
+	Chunks      []TranscriptChunk      `json:"chunks,omitempty"`    // context a memory_retrieval step inserted
+	Violation   string                 `json:"violation,omitempty"` // message of a shield_call that flagged the input
+}
+
+// TranscriptToolResult is the result of one tool call
+type TranscriptToolResult struct {
+	CallID   string            `json:"call_id,omitempty"`
+	ToolName string            `json:"tool_name"`
+	Content  string            `json:"content,omitempty"`
+	Chunks   []TranscriptChunk `json:"chunks,omitempty"` // chunks a knowledge_search call retrieved
+}
+
+// TranscriptChunk is one retrieved chunk
+type TranscriptChunk struct {
+	DocumentID string  `json:"document_id,omitempty"`
+	Content    string  `json:"content"`
+	Score      float64 `json:"score,omitempty"`
+}
+
+// NewTurnTranscript breaks turn down into a transcript
+func NewTurnTranscript(turn *Turn) *TurnTranscript {
+	t := &TurnTranscript{
+		TurnID:     turn.TurnID,
+		SessionID:  turn.SessionID,
+		Input:      turn.InputMessages,
+		Answer:     turn.OutputMessage.Content,
+		DurationMS: turnDuration(*turn).Milliseconds(),
+	}
+	for _, raw := range turn.Steps {
+		step, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		t.Steps = append(t.Steps, transcriptStep(step))
+	}
+	return t
+}
+
+// transcriptStep converts one raw turn step
+func transcriptStep(step map[string]interface{}) TranscriptStep {
+	s := TranscriptStep{DurationMS: stepDuration(step).Milliseconds()}
+	s.Type, _ = step["step_type"].(string)
+	s.StepID, _ = step["step_id"].(string)
+	switch s.Type {
+	case "inference":
+		response, _ := step["model_response"].(map[string]interface{})
+		s.Text = contentText(response["content"])
+		calls, _ := response["tool_calls"].([]interface{})
+		for _, c := range calls {
+			s.ToolCalls = append(s.ToolCalls, agentToolCall(c))
+		}
+	case "tool_execution":
+		calls, _ := step["tool_calls"].([]interface{})
+		for _, c := range calls {
+			s.ToolCalls = append(s.ToolCalls, agentToolCall(c))
+		}
+		responses, _ := step["tool_responses"].([]interface{})
+		for _, r := range responses {
+			resp, _ := r.(map[string]interface{})
+			if resp == nil {
+				continue
+			}
+			result := TranscriptToolResult{Content: contentText(resp["content"])}
+			result.CallID, _ = resp["call_id"].(string)
+			result.ToolName, _ = resp["tool_name"].(string)
+			if result.ToolName == "knowledge_search" {
+				for _, c := range ParseKnowledgeSearchResponse(resp) {
+					result.Chunks = append(result.Chunks, TranscriptChunk{DocumentID: c.DocumentID, Content: c.Content, Score: c.Score})
+				}
+			}
+			s.ToolResults = append(s.ToolResults, result)
+		}
+	case "shield_call":
+		if violation, ok := step["violation"].(map[string]interface{}); ok {
+			s.Violation, _ = violation["user_message"].(string)
+			if s.Violation == "" {
+				s.Violation = "flagged"
+			}
+		}
+	case "memory_retrieval":
+		for _, chunk := range contentTexts(step["inserted_context"]) {
+			s.Chunks = append(s.Chunks, TranscriptChunk{Content: chunk})
+		}
+	}
+	return s
+}
+
+// WriteJSON writes the transcript as indented JSON
+func (t *TurnTranscript) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(t)
+}
+
+// WriteText writes the transcript as indented text, with long tool results
+// and chunks shortened to one line
+func (t *TurnTranscript) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Turn %s%s\n", t.TurnID, transcriptDuration(t.DurationMS))
+	b.WriteString("  Input:\n")
+	for _, m := range t.Input {
+		fmt.Fprintf(&b, "    %s: %s\n", m.Role, indentContinuation(m.Content, "      "))
+	}
+	for i, s := range t.Steps {
+		fmt.Fprintf(&b, "  Step %d: %s%s\n", i+1, s.Type, transcriptDuration(s.DurationMS))
+		if s.Text != "" {
+			fmt.Fprintf(&b, "    %s\n", indentContinuation(s.Text, "    "))
+		}
+		// Tool execution steps repeat the calls of the inference step
+		// before them; their results say what was called
+		if s.Type != "tool_execution" || len(s.ToolResults) == 0 {
+			for _, call := range s.ToolCalls {
+				fmt.Fprintf(&b, "    call %s(%s)\n", call.Function.Name, call.Function.Arguments)
+			}
+		}
+		for _, r := range s.ToolResults {
+			if len(r.Chunks) > 0 {
+				fmt.Fprintf(&b, "    %s returned %s\n", r.ToolName, pluralize(len(r.Chunks), "chunk"))
+				writeTranscriptChunks(&b, r.Chunks)
+				continue
+			}
+			fmt.Fprintf(&b, "    %s returned: %s\n", r.ToolName, snippet(r.Content))
+		}
+		if len(s.Chunks) > 0 {
+			fmt.Fprintf(&b, "    inserted %s\n", pluralize(len(s.Chunks), "chunk"))
+			writeTranscriptChunks(&b, s.Chunks)
+		}
+		if s.Violation != "" {
+			fmt.Fprintf(&b, "    violation: %s\n", s.Violation)
+		}
+	}
+	fmt.Fprintf(&b, "  Answer:\n    %s\n", indentContinuation(t.Answer, "    "))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeTranscriptChunks lists chunks under a step, numbered from 1
+func writeTranscriptChunks(b *strings.Builder, chunks []TranscriptChunk) {
+	for i, c := range chunks {
+		line := snippet(c.Content)
+		if c.DocumentID != "" {
+			line = strings.TrimSpace(c.DocumentID + ": " + line)
+		}
+		fmt.Fprintf(b, "      [%d] %s\n", i+1, line)
+	}
+}
+
+// transcriptDuration formats a duration suffix, or nothing when unknown
+func transcriptDuration(ms int64) string {
+	if ms <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", time.Duration(ms)*time.Millisecond)
+}
+
+// snippet collapses text to one line of at most transcriptSnippetLength runes
+func snippet(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > transcriptSnippetLength {
+		return string(r[:transcriptSnippetLength]) + "..."
+	}
+	return text
+}
+
+// indentContinuation indents every line of text after the first
+func indentContinuation(text, indent string) string {
+	return strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\n"+indent)
+}
+
+// writeTranscript writes t as JSON or indented text
+func writeTranscript(w io.Writer, t *TurnTranscript, asJSON bool) error {
+	if asJSON {
+		return t.WriteJSON(w)
+	}
+	return t.WriteText(w)
+}
+
+// runTranscriptCommand prints the transcript of a stored turn
+func runTranscriptCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("transcript", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON instead of text")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 3 {
+		return errors.New("usage: transcript [-json] AGENT_ID SESSION_ID TURN_ID")
+	}
+	turn, err := client.GetTurn(context.Background(), fs.Arg(0), fs.Arg(1), fs.Arg(2))
+	if err != nil {
+		return err
+	}
+	return writeTranscript(os.Stdout, NewTurnTranscript(turn), *asJSON)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrTranscriptionUnsupported is returned by CreateTranscription when the
+// distro has no OpenAI-compatible transcription endpoint
+var ErrTranscriptionUnsupported = errors.New("this distro does not expose an audio transcription endpoint")
+
+// TranscriptionParams are the form fields of a transcription request
+type TranscriptionParams struct {
+	// Model is a speech-to-text model such as Whisper; defaults to the first
+	// registered model with "whisper" in its ID
+	Model string
+	// Filename is sent with the audio; servers tell the format from its
+	// extension, e.g. "speech.wav", "note.mp3" or "memo.m4a"
+	Filename string
+	// Language is the ISO-639-1 code of the speech, e.g. "en"; detected when
+	// empty
+	Language string
+	// Prompt is text that guides the spelling and style of the transcript,
+	// e.g. names it contains
+	Prompt      string
+	Temperature *float64
+}
+
+// Transcription is the text of an audio file
+type Transcription struct {
+	Text     string  `json:"text"`
+	Language string  `json:"language,omitempty"`
+	Duration float64 `json:"duration,omitempty"` // seconds of audio
+}
+
+// CreateTranscription turns speech read from audio into text with the
+// OpenAI-compatible /audio/transcriptions endpoint
+func (c *LlamaStackClient) CreateTranscription(ctx context.Context, audio io.Reader, params TranscriptionParams) (*Transcription, error) {
+	if params.Model == "" {
+		model, err := c.TranscriptionModel(ctx)
+		if err != nil {
+			return nil, err
+		}
+		params.Model = model
+	}
+	if params.Filename == "" {
+		params.Filename = "audio.wav"
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("file", params.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	fields := [][2]string{{"model", params.Model}, {"response_format", "json"}}
+	if params.Language != "" {
+		fields = append(fields, [2]string{"language", params.Language})
+	}
+	if params.Prompt != "" {
+		fields = append(fields, [2]string{"prompt", params.Prompt})
+	}
+	if params.Temperature != nil {
+		fields = append(fields, [2]string{"temperature", strconv.FormatFloat(*params.Temperature, 'f', -1, 64)})
+	}
+	for _, f := range fields {
+		if err := writer.WriteField(f[0], f[1]); err != nil {
+			return nil, fmt.Errorf("failed to write %s field: %w", f[0], err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/v1/openai/v1/audio/transcriptions", &buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, fmt.Errorf("%w: %w", ErrTranscriptionUnsupported, newAPIError(resp, body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, body)
+	}
+
+	var transcription Transcription
+	if err := json.Unmarshal(body, &transcription); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &transcription, nil
+}
+
+// TranscriptionModel returns the first registered model with "whisper" in
+// its ID, the speech-to-text models distros usually serve
+func (c *LlamaStackClient) TranscriptionModel(ctx context.Context) (string, error) {
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list models: %w", err)
+	}
+	for _, model := range models.Data {
+		if strings.Contains(strings.ToLower(model.Identifier), "whisper") {
+			return model.Identifier, nil
+		}
+	}
+	return "", errors.New("no speech-to-text model registered on this distro")
+}
+
+// transcribeFile transcribes the audio file at path with model, or the
+// default transcription model when model is empty
+func transcribeFile(ctx context.Context, client *LlamaStackClient, path, model string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer f.Close()
+	transcription, err := client.CreateTranscription(ctx, f, TranscriptionParams{Model: model, Filename: filepath.Base(path)})
+	if err != nil {
+		return "", fmt.Errorf("failed to transcribe %s: %w", path, err)
+	}
+	text := strings.TrimSpace(transcription.Text)
+	if text == "" {
+		return "", fmt.Errorf("no speech found in %s", path)
+	}
+	return text, nil
+}
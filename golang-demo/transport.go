@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TransportOptions tune the connections a client keeps to the server. Zero
+// fields keep the values of http.DefaultTransport.
+//
+// The default keeps only 2 idle connections per host, so concurrent uploads,
+// batch completions and benchmarks above that close and reopen connections
+// on every call; raise MaxIdleConnsPerHost to at least their concurrency.
+type TransportOptions struct {
+	MaxIdleConns        int           // idle connections across all hosts
+	MaxIdleConnsPerHost int           // idle connections kept per host
+	MaxConnsPerHost     int           // connections per host, including active ones; 0 is unlimited
+	IdleConnTimeout     time.Duration // how long an idle connection is kept
+	KeepAlive           time.Duration // TCP keep-alive probe interval; negative disables probes
+	DisableKeepAlives   bool          // use each connection for a single request
+	DisableHTTP2        bool          // stay on HTTP/1.1 even if a TLS server offers HTTP/2
+
+	DialTimeout           time.Duration // establishing the TCP connection
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration // from sending the request to the response headers; bodies and streams are not limited
+}
+
+// NewTransport returns a copy of http.DefaultTransport with opts applied
+func NewTransport(opts TransportOptions) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if opts.DialTimeout > 0 {
+		dialer.Timeout = opts.DialTimeout
+	}
+	if opts.KeepAlive != 0 {
+		dialer.KeepAlive = opts.KeepAlive
+	}
+	t.DialContext = dialer.DialContext
+
+	if opts.MaxIdleConns > 0 {
+		t.MaxIdleConns = opts.MaxIdleConns
+	}
+	if opts.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	}
+	if opts.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = opts.MaxConnsPerHost
+	}
+	if opts.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = opts.IdleConnTimeout
+	}
+	if opts.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	}
+	if opts.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	}
+	t.DisableKeepAlives = opts.DisableKeepAlives
+	if opts.DisableHTTP2 {
+		// A non-nil empty map is how net/http is told not to upgrade
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return t
+}
+
+// WithTransport makes the client send requests over a transport built from
+// opts; see TransportOptions
+func WithTransport(opts TransportOptions) ClientOption {
+	return func(c *LlamaStackClient) {
+		c.HTTPClient.Transport = NewTransport(opts)
+	}
+}
+
+// ensureIdleConns gives a client on the default transport enough idle
+// connections for n requests in flight, so a concurrent run reuses them
+// instead of redialing; a transport set by the caller is left alone
+func (c *LlamaStackClient) ensureIdleConns(n int) {
+	if c.HTTPClient.Transport == nil && n > 2 {
+		c.HTTPClient.Transport = NewTransport(TransportOptions{MaxIdleConnsPerHost: n})
+	}
+}
+
+// parseTransportSpec parses LLAMASTACK_TRANSPORT: comma-separated settings
+// such as "max-idle-per-host=32,dial-timeout=5s,http2=off"
+func parseTransportSpec(spec string) (TransportOptions, error) {
+	var opts TransportOptions
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return opts, fmt.Errorf("transport setting %q is not key=value", field)
+		}
+		var err error
+		switch key {
+		case "max-idle":
+			opts.MaxIdleConns, err = strconv.Atoi(value)
+		case "max-idle-per-host":
+			opts.MaxIdleConnsPerHost, err = strconv.Atoi(value)
+		case "max-conns-per-host":
+			opts.MaxConnsPerHost, err = strconv.Atoi(value)
+		case "idle-timeout":
+			opts.IdleConnTimeout, err = time.ParseDuration(value)
+		case "keep-alive":
+			if value == "off" {
+				opts.DisableKeepAlives = true
+			} else {
+				opts.KeepAlive, err = time.ParseDuration(value)
+			}
+		case "http2":
+			switch value {
+			case "on":
+			case "off":
+				opts.DisableHTTP2 = true
+			default:
+				err = errors.New("want on or off")
+			}
+		case "dial-timeout":
+			opts.DialTimeout, err = time.ParseDuration(value)
+		case "tls-timeout":
+			opts.TLSHandshakeTimeout, err = time.ParseDuration(value)
+		case "header-timeout":
+			opts.ResponseHeaderTimeout, err = time.ParseDuration(value)
+		default:
+			return opts, fmt.Errorf("unknown transport setting %q", key)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("invalid transport setting %s: %w", key, err)
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TurnProgress renders what an agent turn is doing, one status line per
+// step, from the turn's events. On a terminal the running step's line is
+// rewritten in place as it progresses and kept with its timing when the step
+// ends; elsewhere only finished steps are printed. The zero value and a nil
+// *TurnProgress print nothing. It is safe for concurrent use.
+type TurnProgress struct {
+	w    io.Writer
+	live bool
+
+	mu      sync.Mutex
+	status  string    // status of the running step, empty between steps
+	started time.Time // when the running step started
+	// ranTools is set after client-side tools ran, whose tool_execution step
+	// the server completes again when the turn resumes
+	ranTools bool
+}
+
+// NewTurnProgress writes progress to w, rewriting lines in place when live
+// is set; pass isTerminal(os.Stderr) to animate only on a terminal
+func NewTurnProgress(w io.Writer, live bool) *TurnProgress {
+	return &TurnProgress{w: w, live: live}
+}
+
+// Event updates the status line from a turn event
+func (p *TurnProgress) Event(ev TurnEvent) {
+	switch ev.EventType {
+	case "step_start":
+		p.start(stepStartStatus(ev.StepType))
+	case "step_progress":
+		if status := stepProgressStatus(ev.Delta); status != "" {
+			p.update(status)
+		}
+	case "step_complete":
+		if ev.StepType == "tool_execution" && p.takeRanTools() {
+			return
+		}
+		p.finish(stepCompleteStatus(ev.StepType, ev.StepDetails))
+	case "turn_complete", "turn_awaiting_input":
+		p.Done()
+	}
+}
+
+// ToolsStarted shows the client-side tool calls of a paused turn running
+func (p *TurnProgress) ToolsStarted(calls []AgentToolCall) {
+	names := make([]string, 0, len(calls))
+	for _, call := range calls {
+		names = append(names, call.ToolName)
+	}
+	p.start(toolsStatus(names))
+}
+
+// ToolsFinished ends the status line of ToolsStarted with a summary of the
+// tool responses
+func (p *TurnProgress) ToolsFinished(responses []SpecToolResponse) {
+	summaries := make([]string, 0, len(responses))
+	for _, resp := range responses {
+		summaries = append(summaries, toolResultSummary(map[string]interface{}{
+			"tool_name": resp.ToolName,
+			"content":   resp.Content,
+			"metadata":  resp.Metadata,
+		}))
+	}
+	p.finish(strings.Join(summaries, ", "))
+	if p != nil {
+		p.mu.Lock()
+		p.ranTools = true
+		p.mu.Unlock()
+	}
+}
+
+// takeRanTools reports and clears ranTools
+func (p *TurnProgress) takeRanTools() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ran := p.ranTools
+	p.ranTools = false
+	return ran
+}
+
+// Done clears a status line left on screen, e.g. when a turn pauses or fails
+// mid-step
+func (p *TurnProgress) Done() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.status != "" && p.live && p.w != nil {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+	p.status = ""
+}
+
+func (p *TurnProgress) start(status string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status, p.started = status, time.Now()
+	p.draw()
+}
+
+func (p *TurnProgress) update(status string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.status == "" || p.status == status {
+		return
+	}
+	p.status = status
+	p.draw()
+}
+
+// finish replaces the running step's line with its outcome and duration
+func (p *TurnProgress) finish(outcome string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.w == nil {
+		return
+	}
+	line := "  " + outcome
+	if p.status != "" {
+		line += fmt.Sprintf(" (%s)", time.Since(p.started).Round(time.Millisecond))
+	}
+	if p.live {
+		line = "\r\033[K" + line
+	}
+	fmt.Fprintln(p.w, line)
+	p.status = ""
+}
+
+// draw shows the running step's status on a terminal
+func (p *TurnProgress) draw() {
+	if p.live && p.w != nil {
+		fmt.Fprintf(p.w, "\r\033[K  %s", p.status)
+	}
+}
+
+// stepStartStatus describes a step that just started
+func stepStartStatus(stepType string) string {
+	switch stepType {
+	case "inference":
+		return "thinking..."
+	case "tool_execution":
+		return "running tools..."
+	case "memory_retrieval":
+		return "searching documents..."
+	case "shield_call":
+		return "checking safety..."
+	}
+	return stepType + "..."
+}
+
+// stepProgressStatus describes a step_progress delta, or returns "" when
+// it does not change what the step is doing
+func stepProgressStatus(delta map[string]interface{}) string {
+	switch delta["type"] {
+	case "text":
+		return "writing answer..."
+	case "tool_call":
+		if delta["parse_status"] != "succeeded" {
+			return "choosing a tool..."
+		}
+		call, _ := delta["tool_call"].(map[string]interface{})
+		name, _ := call["tool_name"].(string)
+		if name == "" {
+			return "choosing a tool..."
+		}
+		return toolsStatus([]string{name})
+	}
+	return ""
+}
+
+// toolsStatus describes tools being called
+func toolsStatus(names []string) string {
+	if len(names) == 1 && strings.Contains(names[0], "knowledge_search") {
+		return "searching documents..."
+	}
+	return "running " + strings.Join(names, ", ") + "..."
+}
+
+// stepCompleteStatus describes the outcome of a finished step
+func stepCompleteStatus(stepType string, details map[string]interface{}) string {
+	switch stepType {
+	case "inference":
+		response, _ := details["model_response"].(map[string]interface{})
+		calls, _ := response["tool_calls"].([]interface{})
+		if len(calls) > 0 {
+			return "decided to call " + pluralize(len(calls), "tool")
+		}
+		return "answer written"
+	case "tool_execution":
+		responses, _ := details["tool_responses"].([]interface{})
+		if len(responses) == 0 {
+			return "tools ran"
+		}
+		summaries := make([]string, 0, len(responses))
+		for _, r := range responses {
+			resp, _ := r.(map[string]interface{})
+			summaries = append(summaries, toolResultSummary(resp))
+		}
+		return strings.Join(summaries, ", ")
+	case "memory_retrieval":
+		return pluralize(len(contentTexts(details["inserted_context"])), "chunk") + " retrieved"
+	case "shield_call":
+		if violation, ok := details["violation"].(map[string]interface{}); ok {
+			if message, _ := violation["user_message"].(string); message != "" {
+				return "flagged by safety check: " + message
+			}
+			return "flagged by safety check"
+		}
+		return "passed safety check"
+	}
+	return stepType + " done"
+}
+
+// toolResultSummary describes one tool response: the chunks a
+// knowledge_search retrieved, or that another tool returned or failed
+func toolResultSummary(resp map[string]interface{}) string {
+	name, _ := resp["tool_name"].(string)
+	if meta, _ := resp["metadata"].(map[string]interface{}); meta["error"] != nil {
+		return name + " failed"
+	}
+	if !strings.Contains(name, "knowledge_search") {
+		return name + " returned"
+	}
+	return pluralize(len(ParseKnowledgeSearchResponse(resp)), "chunk") + " retrieved"
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultUploadPartSize is the part size of UploadFileInParts, the most the
+// OpenAI uploads API accepts per part
+const DefaultUploadPartSize = 64 << 20
+
+// ErrUploadsUnsupported is returned by CreateUpload when the distro has no
+// OpenAI-compatible uploads endpoint; UploadFile still works there
+var ErrUploadsUnsupported = errors.New("this distro does not expose an uploads endpoint")
+
+// Upload is a file being uploaded in parts
+type Upload struct {
+	ID        string        `json:"id"`
+	Object    string        `json:"object"`
+	Bytes     int64         `json:"bytes"`
+	CreatedAt int64         `json:"created_at"`
+	ExpiresAt int64         `json:"expires_at"`
+	Filename  string        `json:"filename"`
+	Purpose   string        `json:"purpose"`
+	Status    string        `json:"status"`         // pending, completed, cancelled or expired
+	File      *FileResponse `json:"file,omitempty"` // the created file, once completed
+}
+
+// UploadPart is one uploaded part of an Upload
+type UploadPart struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	CreatedAt int64  `json:"created_at"`
+	UploadID  string `json:"upload_id"`
+}
+
+// CreateUpload starts an upload of size bytes that parts are added to
+func (c *LlamaStackClient) CreateUpload(ctx context.Context, filename string, purpose FilePurpose, size int64) (*Upload, error) {
+	if err := ValidateFileUpload(purpose, filename, size); err != nil {
+		return nil, err
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(filename))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	var upload Upload
+	err := c.postJSON(ctx, "/v1/openai/v1/uploads", map[string]interface{}{
+		"filename":  filename,
+		"purpose":   purpose,
+		"bytes":     size,
+		"mime_type": mimeType,
+	}, &upload)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed) {
+		return nil, fmt.Errorf("%w: %w", ErrUploadsUnsupported, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// AddUploadPart uploads one part of an upload
+func (c *LlamaStackClient) AddUploadPart(ctx context.Context, uploadID string, data []byte) (*UploadPart, error) {
+	size := int64(len(data))
+	body, contentType, length, err := streamingFormBody("data", "part", progressBody(ctx, "part", bytes.NewReader(data), size), size, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/v1/openai/v1/uploads/"+url.PathEscape(uploadID)+"/parts", body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = length
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, newAPIError(resp, respBody)
+	}
+	var uploaded UploadPart
+	if err := json.Unmarshal(respBody, &uploaded); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &uploaded, nil
+}
+
+// CompleteUpload joins the parts, in the given order, into a file
+func (c *LlamaStackClient) CompleteUpload(ctx context.Context, uploadID string, partIDs []string) (*Upload, error) {
+	var upload Upload
+	if err := c.postJSON(ctx, "/v1/openai/v1/uploads/"+url.PathEscape(uploadID)+"/complete", map[string]interface{}{"part_ids": partIDs}, &upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// CancelUpload cancels an upload; no parts can be added afterwards
+func (c *LlamaStackClient) CancelUpload(ctx context.Context, uploadID string) (*Upload, error) {
+	var upload Upload
+	if err := c.postJSON(ctx, "/v1/openai/v1/uploads/"+url.PathEscape(uploadID)+"/cancel", map[string]interface{}{}, &upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// PartUploadOptions configures UploadFileInParts
+type PartUploadOptions struct {
+	PartSize   int64         // bytes per part; defaults to DefaultUploadPartSize
+	MaxRetries int           // retries per part after the first attempt
+	RetryDelay time.Duration // delay before the first retry of a part, doubled for each further retry
+	// Manifest is where progress is recorded for resuming; defaults to the
+	// file's path with ".upload.json" appended
+	Manifest string
+}
+
+// uploadManifest records the parts of an upload that are done, so a crashed
+// run can resume it
+type uploadManifest struct {
+	UploadID string      `json:"upload_id"`
+	Purpose  FilePurpose `json:"purpose"`
+	Size     int64       `json:"size"`
+	ModTime  time.Time   `json:"mod_time"`
+	PartSize int64       `json:"part_size"`
+	PartIDs  []string    `json:"part_ids"` // by part index, "" until the part is uploaded
+}
+
+// UploadFileInParts uploads a large file in parts of opts.PartSize bytes.
+// Each part is retried on its own, and progress is written to a manifest
+// file after every part. When a run fails or crashes, calling it again with
+// the same file resumes the upload: parts in the manifest are not sent
+// again, unless the server no longer has the upload. When the file or the
+// part size changed, the manifest's upload is cancelled and a new one
+// started. The manifest is removed once the file is created.
+func (c *LlamaStackClient) UploadFileInParts(ctx context.Context, filePath string, purpose FilePurpose, opts PartUploadOptions) (*FileResponse, error) {
+	if opts.PartSize <= 0 {
+		opts.PartSize = DefaultUploadPartSize
+	}
+	if opts.Manifest == "" {
+		opts.Manifest = filePath + ".upload.json"
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	filename := filepath.Base(filePath)
+	if err := ValidateFileUpload(purpose, filename, info.Size()); err != nil {
+		return nil, err
+	}
+
+	manifest, err := loadUploadManifest(opts.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	resumed := manifest != nil && manifest.Purpose == purpose && manifest.Size == info.Size() &&
+		manifest.ModTime.Equal(info.ModTime()) && manifest.PartSize == opts.PartSize
+	if resumed {
+		c.logger().InfoContext(ctx, "resuming upload", "file", filePath, "upload_id", manifest.UploadID, "parts_done", countNonEmpty(manifest.PartIDs))
+	} else if manifest != nil {
+		// The file or the options changed, so the parts sent so far are of
+		// no use; cancel their upload rather than leave it on the server
+		c.logger().InfoContext(ctx, "manifest does not match the file, starting over", "file", filePath, "upload_id", manifest.UploadID)
+		if _, err := c.CancelUpload(ctx, manifest.UploadID); err != nil {
+			c.logger().WarnContext(ctx, "failed to cancel stale upload", "upload_id", manifest.UploadID, "error", err)
+		}
+		manifest = nil
+	}
+
+	for {
+		if manifest == nil {
+			upload, err := c.CreateUpload(ctx, filename, purpose, info.Size())
+			if err != nil {
+				return nil, err
+			}
+			manifest = &uploadManifest{
+				UploadID: upload.ID,
+				Purpose:  purpose,
+				Size:     info.Size(),
+				ModTime:  info.ModTime(),
+				PartSize: opts.PartSize,
+				PartIDs:  make([]string, (info.Size()+opts.PartSize-1)/opts.PartSize),
+			}
+			if err := manifest.save(opts.Manifest); err != nil {
+				return nil, err
+			}
+		}
+
+		uploaded, err := c.uploadParts(ctx, file, filename, manifest, opts)
+		var apiErr *APIError
+		if resumed && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			// The upload expired or was cancelled since the manifest was
+			// written, so start over
+			c.logger().WarnContext(ctx, "upload is gone, starting over", "file", filePath, "upload_id", manifest.UploadID)
+			manifest, resumed = nil, false
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Remove(opts.Manifest); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			c.logger().WarnContext(ctx, "failed to remove upload manifest", "path", opts.Manifest, "error", err)
+		}
+		c.trackResource(ctx, ResourceFile, uploaded.ID, "")
+		return uploaded, nil
+	}
+}
+
+// uploadParts sends the parts the manifest does not have yet and completes
+// the upload. Every part is read, so the checksum covers the whole file.
+func (c *LlamaStackClient) uploadParts(ctx context.Context, file io.ReaderAt, filename string, manifest *uploadManifest, opts PartUploadOptions) (*FileResponse, error) {
+	h := sha256.New()
+	buf := make([]byte, manifest.PartSize)
+	progress := uploadProgressFunc(ctx)
+	for i := range manifest.PartIDs {
+		offset := int64(i) * manifest.PartSize
+		n, err := file.ReadAt(buf, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read part %d: %w", i+1, err)
+		}
+		data := buf[:n]
+		h.Write(data)
+		if manifest.PartIDs[i] != "" {
+			continue
+		}
+
+		partCtx := ctx
+		if progress != nil {
+			// Report the part's share of its request body as file bytes
+			partCtx = WithUploadProgress(ctx, func(p UploadProgress) {
+				progress(UploadProgress{Filename: filename, Sent: offset + p.Sent*int64(len(data))/max(p.Total, 1), Total: manifest.Size})
+			})
+		}
+		part, err := c.addUploadPartWithRetries(partCtx, manifest.UploadID, i, data, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload part %d of %d: %w", i+1, len(manifest.PartIDs), err)
+		}
+		manifest.PartIDs[i] = part.ID
+		if err := manifest.save(opts.Manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	upload, err := c.CompleteUpload(ctx, manifest.UploadID, manifest.PartIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete upload: %w", err)
+	}
+	if upload.File == nil {
+		return nil, fmt.Errorf("upload %s completed without a file (status %s)", upload.ID, upload.Status)
+	}
+	upload.File.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return upload.File, nil
+}
+
+// addUploadPartWithRetries sends one part, retrying transient failures
+func (c *LlamaStackClient) addUploadPartWithRetries(ctx context.Context, uploadID string, index int, data []byte, opts PartUploadOptions) (*UploadPart, error) {
+	delay := opts.RetryDelay
+	for attempts := 1; ; attempts++ {
+		part, err := c.AddUploadPart(withRetries(ctx, attempts-1), uploadID, data)
+		if err == nil || attempts > opts.MaxRetries || !isRetryable(ctx, err) {
+			return part, err
+		}
+
+		c.logger().WarnContext(ctx, "retrying upload part", "upload_id", uploadID, "part", index+1, "attempt", attempts, "delay", delay, "error", err)
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			delay *= 2
+		}
+	}
+}
+
+// loadUploadManifest reads a manifest, or returns nil when there is none
+func loadUploadManifest(p string) (*uploadManifest, error) {
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload manifest: %w", err)
+	}
+	var manifest uploadManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode upload manifest %s: %w", p, err)
+	}
+	return &manifest, nil
+}
+
+// save writes the manifest through a temporary file, so a crash mid-write
+// leaves the previous one intact
+func (m *uploadManifest) save(p string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload manifest: %w", err)
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write upload manifest: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return fmt.Errorf("failed to write upload manifest: %w", err)
+	}
+	return nil
+}
+
+func countNonEmpty(values []string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// runFilesCommand handles "files upload [-purpose P] [-part-size MB] FILE..."
+func runFilesCommand(client *LlamaStackClient, args []string) error {
+	if len(args) == 0 || args[0] != "upload" {
+		return errors.New("usage: files upload ...")
+	}
+	flags := flag.NewFlagSet("files upload", flag.ContinueOnError)
+	purpose := flags.String("purpose", string(FilePurposeAssistants), "file purpose")
+	partSize := flags.Int64("part-size", DefaultUploadPartSize>>20, "upload files larger than this many MB in resumable parts of this size")
+	retries := flags.Int("retries", 3, "retries per part")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: files upload [-purpose P] [-part-size MB] [-retries N] FILE...")
+	}
+
+	bars := NewUploadProgressBars(os.Stderr, isTerminal(os.Stderr))
+	defer bars.Summary()
+	ctx := WithUploadProgress(context.Background(), bars.Update)
+	opts := PartUploadOptions{PartSize: *partSize << 20, MaxRetries: *retries, RetryDelay: time.Second}
+	for _, p := range flags.Args() {
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		var file *FileResponse
+		if info.Size() > opts.PartSize {
+			file, err = client.UploadFileInParts(ctx, p, FilePurpose(*purpose), opts)
+		} else {
+			file, err = client.UploadFile(ctx, p, FilePurpose(*purpose))
+		}
+		bars.FileDone(err)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", p, err)
+		}
+		fmt.Printf("%s\t%s\t%s\tsha256:%s\n", file.ID, p, formatBytes(info.Size()), file.SHA256)
+	}
+	return nil
+}
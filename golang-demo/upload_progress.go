@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UploadProgress is how far the upload of one file has got
+type UploadProgress struct {
+	Filename string
+	Sent     int64 // bytes sent so far
+	Total    int64 // 0 when the size is not known in advance
+}
+
+// ProgressReader reports the bytes read through it, e.g. from a request body
+// as the transport sends it
+type ProgressReader struct {
+	r      io.Reader
+	total  int64
+	read   int64
+	report func(read, total int64)
+}
+
+// NewProgressReader calls report with the running byte count and total
+// after every read from r
+func NewProgressReader(r io.Reader, total int64, report func(read, total int64)) *ProgressReader {
+	return &ProgressReader{r: r, total: total, report: report}
+}
+
+// Read reads from the wrapped reader and reports progress
+func (p *ProgressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.report(p.read, p.total)
+	}
+	return n, err
+}
+
+// uploadProgressKey holds the upload progress callback of a context
+type uploadProgressKey struct{}
+
+// WithUploadProgress returns a context whose file uploads report the bytes
+// sent to fn. UploadFileInParts reports progress across all parts.
+func WithUploadProgress(ctx context.Context, fn func(UploadProgress)) context.Context {
+	return context.WithValue(ctx, uploadProgressKey{}, fn)
+}
+
+// uploadProgressFunc returns the upload progress callback of ctx, or nil
+func uploadProgressFunc(ctx context.Context) func(UploadProgress) {
+	fn, _ := ctx.Value(uploadProgressKey{}).(func(UploadProgress))
+	return fn
+}
+
+// progressBody wraps the content of an upload of filename, size bytes or -1
+// when unknown, so sending it reports progress, when ctx asks for it
+func progressBody(ctx context.Context, filename string, content io.Reader, size int64) io.Reader {
+	fn := uploadProgressFunc(ctx)
+	if fn == nil {
+		return content
+	}
+	return NewProgressReader(content, max(size, 0), func(read, total int64) {
+		fn(UploadProgress{Filename: filename, Sent: read, Total: total})
+	})
+}
+
+// uploadProgressBarWidth is the number of cells in a progress bar
+const uploadProgressBarWidth = 24
+
+// UploadProgressBars renders upload progress for the CLI. On a terminal the
+// file being uploaded gets a bar, redrawn in place, with the aggregate
+// throughput of the run; Summary prints the totals in any case.
+type UploadProgressBars struct {
+	w    io.Writer
+	live bool
+
+	mu       sync.Mutex
+	start    time.Time
+	files    int
+	done     int64 // bytes of finished files
+	current  UploadProgress
+	lastDraw time.Time
+}
+
+// NewUploadProgressBars writes progress to w, drawing bars only when live is
+// set; pass isTerminal(os.Stderr)
+func NewUploadProgressBars(w io.Writer, live bool) *UploadProgressBars {
+	return &UploadProgressBars{w: w, live: live, start: time.Now()}
+}
+
+// Update records progress; pass it to WithUploadProgress
+func (b *UploadProgressBars) Update(p UploadProgress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current = p
+	// Redraw at most ten times a second, and always at the end of a file
+	if !b.live || ((p.Total == 0 || p.Sent < p.Total) && time.Since(b.lastDraw) < 100*time.Millisecond) {
+		return
+	}
+	b.lastDraw = time.Now()
+	fmt.Fprintf(b.w, "\r\033[K%s", b.line())
+}
+
+// line renders the bar of the current file
+func (b *UploadProgressBars) line() string {
+	p := b.current
+	if p.Total == 0 {
+		return fmt.Sprintf("  %s %s sent %s", p.Filename, formatBytes(p.Sent), b.throughput(b.done+p.Sent))
+	}
+	fraction := 1.0
+	if p.Total > 0 {
+		fraction = float64(p.Sent) / float64(p.Total)
+	}
+	filled := int(fraction * uploadProgressBarWidth)
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", uploadProgressBarWidth-filled)
+	return fmt.Sprintf("  %s [%s] %3.0f%% %s/%s %s", p.Filename, bar, fraction*100,
+		formatBytes(p.Sent), formatBytes(p.Total), b.throughput(b.done+p.Sent))
+}
+
+// throughput formats the average rate of sent bytes since the start
+func (b *UploadProgressBars) throughput(sent int64) string {
+	elapsed := time.Since(b.start).Seconds()
+	if elapsed <= 0 {
+		return ""
+	}
+	return formatBytes(int64(float64(sent)/elapsed)) + "/s"
+}
+
+// Clear removes the bar from the screen, e.g. before printing a line
+func (b *UploadProgressBars) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.live && b.current.Filename != "" {
+		fmt.Fprint(b.w, "\r\033[K")
+	}
+}
+
+// FileDone clears the bar of the file last reported by Update and, unless
+// its upload failed with err, counts it towards the totals. Without a
+// reported upload, e.g. in a dry run, nothing is counted.
+func (b *UploadProgressBars) FileDone(err error) {
+	b.Clear()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.current.Filename != "" && err == nil {
+		b.files++
+		b.done += b.current.Sent
+	}
+	b.current = UploadProgress{}
+}
+
+// Summary prints the number of files and bytes uploaded and the aggregate
+// throughput
+func (b *UploadProgressBars) Summary() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.files == 0 {
+		return
+	}
+	fmt.Fprintf(b.w, "Uploaded %s, %s in %s (%s)\n", pluralize(b.files, "file"), formatBytes(b.done),
+		time.Since(b.start).Round(time.Millisecond), b.throughput(b.done))
+}
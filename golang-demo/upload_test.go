@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// zeroReader reads zero bytes forever
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// newUploadClient starts a mock stack and returns a client on it that passes
+// every request to inspect before sending it
+func newUploadClient(t *testing.T, inspect func(*http.Request)) *LlamaStackClient {
+	t.Helper()
+	mock := NewMockStack()
+	t.Cleanup(mock.Close)
+	client := NewLlamaStackClient(mock.URL(), "test")
+	client.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		inspect(req)
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	return client
+}
+
+func TestUploadFileStreams(t *testing.T) {
+	content := strings.Repeat("Dora is a pug owned by Ana. ", 4096)
+	sum := sha256.Sum256([]byte(content))
+	name := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each case opens the content and reports how much of it is still unread
+	tests := []struct {
+		name    string
+		open    func(t *testing.T) (io.Reader, func() int64)
+		chunked bool
+	}{
+		{"file", func(t *testing.T) (io.Reader, func() int64) {
+			f, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { f.Close() })
+			return f, func() int64 {
+				offset, _ := f.Seek(0, io.SeekCurrent)
+				return int64(len(content)) - offset
+			}
+		}, false},
+		{"strings reader", func(*testing.T) (io.Reader, func() int64) {
+			r := strings.NewReader(content)
+			return r, func() int64 { return int64(r.Len()) }
+		}, false},
+		{"unknown size", func(*testing.T) (io.Reader, func() int64) {
+			r := &countingReader{r: strings.NewReader(content)}
+			return r, func() int64 { return int64(len(content)) - r.n.Load() }
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, unread := tt.open(t)
+			var unreadAtSend, length int64
+			client := newUploadClient(t, func(req *http.Request) {
+				unreadAtSend, length = unread(), req.ContentLength
+			})
+
+			file, err := client.UploadFileFromReader(context.Background(), "notes.txt", r, FilePurposeAssistants)
+			if err != nil {
+				t.Fatalf("UploadFileFromReader: %v", err)
+			}
+			if file.SHA256 != hex.EncodeToString(sum[:]) {
+				t.Fatalf("SHA256 = %s, want %x", file.SHA256, sum)
+			}
+			if file.Bytes != len(content) {
+				t.Fatalf("server received %d bytes, want %d", file.Bytes, len(content))
+			}
+			// Beyond a byte peeked to reject empty content, nothing is read
+			// before the transport sends the body
+			if unreadAtSend < int64(len(content))-1 {
+				t.Fatalf("only %d of %d bytes unread when the request was sent", unreadAtSend, len(content))
+			}
+			if tt.chunked && length != -1 {
+				t.Fatalf("ContentLength = %d for content of unknown size, want -1", length)
+			}
+			if !tt.chunked && length <= int64(len(content)) {
+				t.Fatalf("ContentLength = %d, want the content plus the form around it", length)
+			}
+		})
+	}
+}
+
+func TestUploadFileRejectsInvalidContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content io.Reader
+		purpose FilePurpose
+		want    string
+		sent    bool // whether the upload starts before the problem shows
+	}{
+		{"empty", strings.NewReader(""), FilePurposeAssistants, "is empty", false},
+		{"empty of unknown size", io.MultiReader(), FilePurposeAssistants, "is empty", false},
+		{"oversize", io.LimitReader(zeroReader{}, 21<<20), FilePurposeVision, "over the 20.0 MB limit", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			client := newUploadClient(t, func(*http.Request) { requests++ })
+			_, err := client.UploadFileFromReader(context.Background(), "image.png", tt.content, tt.purpose)
+			if !errors.Is(err, ErrInvalidUpload) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want ErrInvalidUpload mentioning %q", err, tt.want)
+			}
+			if sent := requests > 0; sent != tt.sent {
+				t.Fatalf("request sent = %v, want %v", sent, tt.sent)
+			}
+		})
+	}
+}
+
+func TestUploadFileInPartsCancelsStaleUpload(t *testing.T) {
+	mock := NewMockStack()
+	defer mock.Close()
+	client := NewLlamaStackClient(mock.URL(), "test")
+	ctx := context.Background()
+	content := strings.Repeat("Dora is a pug. ", 200)
+	name := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A run with another part size left a manifest and a pending upload
+	stale, err := client.CreateUpload(ctx, "notes.txt", FilePurposeAssistants, info.Size())
+	if err != nil {
+		t.Fatalf("CreateUpload: %v", err)
+	}
+	manifest := &uploadManifest{UploadID: stale.ID, Purpose: FilePurposeAssistants, Size: info.Size(), ModTime: info.ModTime(), PartSize: 2048, PartIDs: []string{"", ""}}
+	if err := manifest.save(name + ".upload.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := client.UploadFileInParts(ctx, name, FilePurposeAssistants, PartUploadOptions{PartSize: 1024})
+	if err != nil {
+		t.Fatalf("UploadFileInParts: %v", err)
+	}
+	if got := string(mock.fileContent[file.ID]); got != content {
+		t.Fatalf("server holds %d bytes, want %d", len(got), len(content))
+	}
+	if status := mock.uploads[stale.ID].Status; status != "cancelled" {
+		t.Fatalf("stale upload is %s, want cancelled", status)
+	}
+	if _, err := os.Stat(name + ".upload.json"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("manifest left behind: %v", err)
+	}
+}
+
+func TestUploadFileInPartsRejectsEmptyFile(t *testing.T) {
+	var requests int
+	client := newUploadClient(t, func(*http.Request) { requests++ })
+	name := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(name, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.UploadFileInParts(context.Background(), name, FilePurposeAssistants, PartUploadOptions{})
+	if !errors.Is(err, ErrInvalidUpload) || !strings.Contains(err.Error(), "is empty") {
+		t.Fatalf("err = %v, want ErrInvalidUpload for an empty file", err)
+	}
+	if requests > 0 {
+		t.Fatalf("%d requests sent for an empty file", requests)
+	}
+}
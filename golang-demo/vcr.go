@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// VCRMode selects whether a VCRTransport records or replays interactions
+type VCRMode string
+
+const (
+	VCRRecord VCRMode = "record"
+	VCRReplay VCRMode = "replay"
+)
+
+// redactedHeaders are replaced with a placeholder before fixtures are written
+var redactedHeaders = []string{
+	"Authorization",
+	"X-Llamastack-Provider-Data",
+	"X-Api-Key",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// VCRRequest represents the recorded part of a request
+type VCRRequest struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+}
+
+// VCRResponse represents the recorded part of a response, including full SSE bodies
+type VCRResponse struct {
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body"`
+}
+
+// VCRInteraction represents one recorded request/response pair
+type VCRInteraction struct {
+	Request  VCRRequest  `json:"request"`
+	Response VCRResponse `json:"response"`
+}
+
+// VCRTransport is an http.RoundTripper that records real interactions to a
+// fixture file, or replays them later without a server. Fixtures store only
+// the request path, so they replay against any base URL.
+type VCRTransport struct {
+	Mode VCRMode
+	Path string
+	Next http.RoundTripper // transport used while recording; defaults to http.DefaultTransport
+
+	mu           sync.Mutex
+	interactions []VCRInteraction
+	used         []bool
+	err          error // why the fixture could not be written, if it could not
+}
+
+// NewVCRTransport creates a VCR transport, loading the fixture file in replay mode
+func NewVCRTransport(mode VCRMode, path string) (*VCRTransport, error) {
+	t := &VCRTransport{Mode: mode, Path: path}
+	switch mode {
+	case VCRRecord:
+		return t, nil
+	case VCRReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		if err := json.Unmarshal(data, &t.interactions); err != nil {
+			return nil, fmt.Errorf("failed to decode fixture: %w", err)
+		}
+		t.used = make([]bool, len(t.interactions))
+		return t, nil
+	default:
+		return nil, fmt.Errorf("unknown VCR mode %q", mode)
+	}
+}
+
+// RoundTrip records or replays a single request
+func (t *VCRTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	recorded := VCRRequest{
+		Method:  req.Method,
+		Path:    req.URL.RequestURI(),
+		Headers: sanitizeHeaders(req.Header),
+		Body:    string(body),
+	}
+
+	if t.Mode == VCRReplay {
+		return t.replay(req, recorded)
+	}
+	return t.record(req, recorded)
+}
+
+// replay returns the first unused interaction matching method, path and body,
+// falling back to method and path alone for bodies with random parts such as
+// multipart boundaries
+func (t *VCRTransport) replay(req *http.Request, recorded VCRRequest) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	match := -1
+	for i, in := range t.interactions {
+		if t.used[i] || in.Request.Method != recorded.Method || in.Request.Path != recorded.Path {
+			continue
+		}
+		if in.Request.Body == recorded.Body {
+			match = i
+			break
+		}
+		if match == -1 {
+			match = i
+		}
+	}
+	if match == -1 {
+		return nil, fmt.Errorf("vcr: no recorded interaction for %s %s", recorded.Method, recorded.Path)
+	}
+	t.used[match] = true
+
+	in := t.interactions[match].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+		StatusCode:    in.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header(in.Headers).Clone(),
+		Body:          io.NopCloser(strings.NewReader(in.Body)),
+		ContentLength: int64(len(in.Body)),
+		Request:       req,
+	}, nil
+}
+
+// record forwards the request and captures the response body as it is read,
+// so streaming responses still reach the caller incrementally. The fixture is
+// written when the body is closed; if that fails, Close returns the error and
+// so does every later request, so a run never passes with a broken recording.
+func (t *VCRTransport) record(req *http.Request, recorded VCRRequest) (*http.Response, error) {
+	t.mu.Lock()
+	err := t.err
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = &recordingBody{
+		body: resp.Body,
+		done: func(captured []byte) error {
+			return t.append(VCRInteraction{
+				Request: recorded,
+				Response: VCRResponse{
+					StatusCode: resp.StatusCode,
+					Headers:    sanitizeHeaders(resp.Header),
+					Body:       string(captured),
+				},
+			})
+		},
+	}
+	return resp, nil
+}
+
+// append adds an interaction and rewrites the fixture file
+func (t *VCRTransport) append(in VCRInteraction) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return t.err
+	}
+	t.interactions = append(t.interactions, in)
+	t.err = t.write()
+	return t.err
+}
+
+// write saves all interactions to the fixture file
+func (t *VCRTransport) write() error {
+	data, err := json.MarshalIndent(t.interactions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("vcr: failed to encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.Path), 0o755); err != nil {
+		return fmt.Errorf("vcr: failed to write fixture: %w", err)
+	}
+	if err := os.WriteFile(t.Path, data, 0o644); err != nil {
+		return fmt.Errorf("vcr: failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+// recordingBody copies everything read from body and reports it once on
+// Close, which returns the error of done
+type recordingBody struct {
+	body io.ReadCloser
+	buf  bytes.Buffer
+	done func([]byte) error
+	once sync.Once
+}
+
+func (r *recordingBody) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.buf.Write(p[:n])
+	return n, err
+}
+
+func (r *recordingBody) Close() error {
+	err := r.body.Close()
+	r.once.Do(func() {
+		if doneErr := r.done(r.buf.Bytes()); doneErr != nil {
+			err = doneErr
+		}
+	})
+	return err
+}
+
+// sanitizeHeaders copies headers with secrets replaced by a placeholder
+func sanitizeHeaders(h http.Header) map[string][]string {
+	out := make(map[string][]string, len(h))
+	for k, v := range h {
+		out[k] = append([]string(nil), v...)
+	}
+	for _, name := range redactedHeaders {
+		key := http.CanonicalHeaderKey(name)
+		if _, ok := out[key]; ok {
+			out[key] = []string{"REDACTED"}
+		}
+	}
+	return out
+}
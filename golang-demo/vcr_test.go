@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newVCRTestServer serves a JSON model list and a streamed chat completion
+func newVCRTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data":[{"identifier":"m1","model_type":"llm","provider_id":"p"}]}`)
+	})
+	mux.HandleFunc("POST /v1/openai/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, word := range []string{"Dora", "'s owner"} {
+			fmt.Fprintf(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", word)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// vcrClient returns a client for baseURL whose calls go through a VCR
+// transport in mode
+func vcrClient(t *testing.T, baseURL string, mode VCRMode, path string) *LlamaStackClient {
+	t.Helper()
+	vcr, err := NewVCRTransport(mode, path)
+	if err != nil {
+		t.Fatalf("NewVCRTransport(%s): %v", mode, err)
+	}
+	client := NewLlamaStackClient(baseURL, "secret-key")
+	client.HTTPClient = &http.Client{Transport: vcr}
+	return client
+}
+
+// chatText streams a completion of prompt and returns its text
+func chatText(t *testing.T, client *LlamaStackClient, prompt string) string {
+	t.Helper()
+	var sb strings.Builder
+	params := ChatCompletionParams{Model: "m1", Messages: []Message{{Role: "user", Content: prompt}}}
+	for chunk, err := range client.StreamChatCompletion(context.Background(), params) {
+		if err != nil {
+			t.Fatalf("StreamChatCompletion: %v", err)
+		}
+		for _, c := range chunk.Choices {
+			sb.WriteString(c.Delta.Content)
+		}
+	}
+	return sb.String()
+}
+
+func TestVCRRecordAndReplay(t *testing.T) {
+	srv := newVCRTestServer(t)
+	cassette := filepath.Join(t.TempDir(), "fixtures", "demo.json")
+
+	recording := vcrClient(t, srv.URL, VCRRecord, cassette)
+	if _, err := recording.ListModels(context.Background()); err != nil {
+		t.Fatalf("ListModels while recording: %v", err)
+	}
+	if got := chatText(t, recording, "Who owns Dora?"); got != "Dora's owner" {
+		t.Fatalf("recorded stream = %q", got)
+	}
+
+	data, err := os.ReadFile(cassette)
+	if err != nil {
+		t.Fatalf("cassette not written: %v", err)
+	}
+	if strings.Contains(string(data), "secret-key") {
+		t.Fatal("cassette contains the API key")
+	}
+
+	// Replay against a base URL with no server behind it
+	srv.Close()
+	replaying := vcrClient(t, "http://127.0.0.1:1", VCRReplay, cassette)
+	models, err := replaying.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels while replaying: %v", err)
+	}
+	if len(models.Data) != 1 || models.Data[0].Identifier != "m1" {
+		t.Fatalf("replayed models = %+v", models)
+	}
+	if got := chatText(t, replaying, "Who owns Dora?"); got != "Dora's owner" {
+		t.Fatalf("replayed stream = %q", got)
+	}
+	if _, err := replaying.ListModels(context.Background()); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Fatalf("replaying past the cassette: err = %v", err)
+	}
+}
+
+func TestVCRRecordWriteFailure(t *testing.T) {
+	srv := newVCRTestServer(t)
+	// A fixture below a regular file can never be written
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vcr, err := NewVCRTransport(VCRRecord, filepath.Join(blocker, "demo.json"))
+	if err != nil {
+		t.Fatalf("NewVCRTransport: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+"/v1/models", nil)
+	resp, err := vcr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("first RoundTrip: %v", err)
+	}
+	if err := resp.Body.Close(); err == nil {
+		t.Fatal("Close succeeded although the fixture could not be written")
+	}
+
+	req, _ = http.NewRequest("GET", srv.URL+"/v1/models", nil)
+	if _, err := vcr.RoundTrip(req); err == nil || !errors.Is(err, vcr.err) {
+		t.Fatalf("RoundTrip after a failed write: err = %v", err)
+	}
+}
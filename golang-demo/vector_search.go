@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// VectorStoreSearchParams represents parameters for searching a vector store
+type VectorStoreSearchParams struct {
+	Query          string                `json:"query"`
+	Filters        *Filter               `json:"filters,omitempty"`
+	MaxNumResults  int                   `json:"max_num_results,omitempty"`
+	RewriteQuery   bool                  `json:"rewrite_query,omitempty"`
+	SearchMode     RAGSearchMode         `json:"search_mode,omitempty"`
+	RankingOptions *SearchRankingOptions `json:"ranking_options,omitempty"`
+}
+
+// SearchRankingOptions tunes how vector store search results are ranked
+type SearchRankingOptions struct {
+	Ranker         string  `json:"ranker,omitempty"`
+	ScoreThreshold float64 `json:"score_threshold,omitempty"` // drop results scoring below this
+}
+
+// VectorStoreSearchResult is one chunk matched by a vector store search
+type VectorStoreSearchResult struct {
+	FileID     string                 `json:"file_id"`
+	Filename   string                 `json:"filename"`
+	Score      float64                `json:"score"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Content    []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+// VectorStoreSearchResponse represents the response from searching a vector store
+type VectorStoreSearchResponse struct {
+	Object      string                    `json:"object"`
+	SearchQuery interface{}               `json:"search_query"`
+	Data        []VectorStoreSearchResult `json:"data"`
+	HasMore     bool                      `json:"has_more"`
+	NextPage    *string                   `json:"next_page,omitempty"`
+}
+
+// SearchVectorStore searches a vector store directly, without an agent. Filters
+// are evaluated by the server against the attributes given when attaching files.
+func (c *LlamaStackClient) SearchVectorStore(ctx context.Context, vectorStoreID string, params VectorStoreSearchParams) (*VectorStoreSearchResponse, error) {
+	if params.Filters != nil {
+		if err := params.Filters.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+	if err := params.SearchMode.Validate(); err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal search params: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/openai/v1/vector_stores/%s/search", c.BaseURL, vectorStoreID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, body)
+	}
+
+	var response VectorStoreSearchResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// filterQueryResult keeps the chunks of result whose metadata, including
+// document_id, matches filter
+func filterQueryResult(result *QueryResult, filter Filter) *QueryResult {
+	var kept []Citation
+	for _, c := range CitationsFromQueryResult(result) {
+		attrs := make(map[string]interface{}, len(c.Metadata)+1)
+		for k, v := range c.Metadata {
+			attrs[k] = v
+		}
+		attrs["document_id"] = c.DocumentID
+		if filter.Match(attrs) {
+			c.Index = len(kept) + 1
+			kept = append(kept, c)
+		}
+	}
+	return (&MultiQueryResult{Chunks: kept}).QueryResult()
+}
+
+// formatChunkMetadata renders chunk metadata as the Python dict repr the
+// server's chunk template uses, so parsePythonDict can read it back
+func formatChunkMetadata(c Citation) string {
+	keys := make([]string, 0, len(c.Metadata))
+	for k := range c.Metadata {
+		if k != "document_id" && k != "vector_db_id" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	entries := []string{fmt.Sprintf("'document_id': '%s'", c.DocumentID)}
+	if c.VectorDBID != "" {
+		entries = append(entries, fmt.Sprintf("'vector_db_id': '%s'", c.VectorDBID))
+	}
+	for _, k := range keys {
+		switch v := c.Metadata[k].(type) {
+		case string:
+			// Python switches to double quotes for strings holding a single quote
+			if strings.Contains(v, "'") && !strings.Contains(v, `"`) {
+				entries = append(entries, fmt.Sprintf(`'%s': "%s"`, k, v))
+			} else {
+				entries = append(entries, fmt.Sprintf("'%s': '%s'", k, v))
+			}
+		case bool:
+			entries = append(entries, fmt.Sprintf("'%s': %s", k, map[bool]string{true: "True", false: "False"}[v]))
+		case nil:
+			entries = append(entries, fmt.Sprintf("'%s': None", k))
+		default:
+			entries = append(entries, fmt.Sprintf("'%s': %v", k, v))
+		}
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
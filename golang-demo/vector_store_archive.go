@@ -0,0 +1,284 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+// ListVectorStoreFilesResponse represents one page of files attached to a vector store
+type ListVectorStoreFilesResponse = Page[VectorStoreFile]
+
+// GetVectorStore retrieves a vector store
+func (c *LlamaStackClient) GetVectorStore(ctx context.Context, vectorStoreID string) (*VectorStore, error) {
+	var store VectorStore
+	if err := c.getJSON(ctx, "/v1/openai/v1/vector_stores/"+url.PathEscape(vectorStoreID), &store); err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+// ListVectorStoreFiles lists every file attached to a vector store, following pages
+func (c *LlamaStackClient) ListVectorStoreFiles(ctx context.Context, vectorStoreID string) ([]VectorStoreFile, error) {
+	return listAfter[VectorStoreFile](ctx, c, "/v1/openai/v1/vector_stores/"+url.PathEscape(vectorStoreID)+"/files")
+}
+
+// GetVectorStoreFile retrieves one file attached to a vector store, including
+// its processing status
+func (c *LlamaStackClient) GetVectorStoreFile(ctx context.Context, vectorStoreID, fileID string) (*VectorStoreFile, error) {
+	var file VectorStoreFile
+	endpoint := fmt.Sprintf("/v1/openai/v1/vector_stores/%s/files/%s", url.PathEscape(vectorStoreID), url.PathEscape(fileID))
+	if err := c.getJSON(ctx, endpoint, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// WaitForVectorStoreFile polls an attached file until the server has finished
+// chunking and embedding it. A file that failed or was cancelled is returned
+// with an error carrying the server's last_error.
+func (c *LlamaStackClient) WaitForVectorStoreFile(ctx context.Context, vectorStoreID, fileID string, opts PollOptions[*VectorStoreFile]) (*VectorStoreFile, error) {
+	poller := Poller[*VectorStoreFile]{
+		Poll: func(ctx context.Context) (*VectorStoreFile, error) {
+			return c.GetVectorStoreFile(ctx, vectorStoreID, fileID)
+		},
+		Done: func(file *VectorStoreFile) (bool, error) {
+			switch file.Status {
+			case "completed":
+				return true, nil
+			case "failed", "cancelled":
+				if file.LastError != nil {
+					return true, fmt.Errorf("file %s %s: %s: %s", fileID, file.Status, file.LastError.Code, file.LastError.Message)
+				}
+				return true, fmt.Errorf("file %s %s", fileID, file.Status)
+			}
+			return false, nil
+		},
+		Options: opts,
+		Logger:  c.logger(),
+	}
+	return poller.Wait(ctx)
+}
+
+// GetFile retrieves the metadata of an uploaded file
+func (c *LlamaStackClient) GetFile(ctx context.Context, fileID string) (*FileResponse, error) {
+	var file FileResponse
+	if err := c.getJSON(ctx, "/v1/openai/v1/files/"+url.PathEscape(fileID), &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// DownloadFile writes the content of an uploaded file to w
+func (c *LlamaStackClient) DownloadFile(ctx context.Context, fileID string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/v1/openai/v1/files/"+url.PathEscape(fileID)+"/content", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp, body)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download file %s: %w", fileID, err)
+	}
+	return nil
+}
+
+// vectorStoreArchiveVersion is bumped when the archive layout changes
+const vectorStoreArchiveVersion = 1
+
+// vectorStoreManifest is the first entry of a vector store archive
+type vectorStoreManifest struct {
+	Version    int                      `json:"version"`
+	ExportedAt time.Time                `json:"exported_at"`
+	Store      VectorStore              `json:"vector_store"`
+	Files      []vectorStoreArchiveFile `json:"files"`
+}
+
+// vectorStoreArchiveFile describes one attached file and where its bytes are stored
+type vectorStoreArchiveFile struct {
+	FileID           string                 `json:"file_id"`
+	Filename         string                 `json:"filename"`
+	Purpose          string                 `json:"purpose"`
+	Attributes       map[string]interface{} `json:"attributes,omitempty"`
+	ChunkingStrategy interface{}            `json:"chunking_strategy,omitempty"`
+	Path             string                 `json:"path"`
+}
+
+// ExportVectorStore writes a vector store to w as a gzipped tar archive: a
+// manifest.json with the store's name, metadata and per-file attributes and
+// chunking strategy, followed by the original bytes of every attached file.
+//
+// Embeddings are not part of the OpenAI-compatible API, so ImportVectorStore
+// has the target server chunk and embed the files again. The archive still
+// carries everything needed, so the source documents themselves are not.
+func (c *LlamaStackClient) ExportVectorStore(ctx context.Context, vectorStoreID string, w io.Writer) error {
+	ctx = ensureCorrelationID(ctx)
+	store, err := c.GetVectorStore(ctx, vectorStoreID)
+	if err != nil {
+		return fmt.Errorf("failed to get vector store: %w", err)
+	}
+	attached, err := c.ListVectorStoreFiles(ctx, vectorStoreID)
+	if err != nil {
+		return fmt.Errorf("failed to list vector store files: %w", err)
+	}
+
+	manifest := vectorStoreManifest{Version: vectorStoreArchiveVersion, ExportedAt: time.Now().UTC(), Store: *store}
+	for _, f := range attached {
+		info, err := c.GetFile(ctx, f.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get file %s: %w", f.ID, err)
+		}
+		name := path.Base(info.Filename)
+		if name == "." || name == "/" {
+			name = f.ID
+		}
+		manifest.Files = append(manifest.Files, vectorStoreArchiveFile{
+			FileID:           f.ID,
+			Filename:         info.Filename,
+			Purpose:          info.Purpose,
+			Attributes:       f.Attributes,
+			ChunkingStrategy: f.ChunkingStrategy,
+			Path:             path.Join("files", f.ID, name),
+		})
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := writeTarEntry(tw, "manifest.json", data); err != nil {
+		return err
+	}
+
+	for _, f := range manifest.Files {
+		var content bytes.Buffer
+		if err := c.DownloadFileVerified(ctx, f.FileID, FileChecksum(f.Attributes), &content); err != nil {
+			return fmt.Errorf("failed to download file %s: %w", f.FileID, err)
+		}
+		if err := writeTarEntry(tw, f.Path, content.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+// ImportVectorStore recreates a vector store from an ExportVectorStore archive:
+// it creates a store with the same name and metadata, uploads every file and
+// attaches it with its original attributes and chunking strategy. On error the
+// partially imported store is returned with it so the caller can delete it.
+func (c *LlamaStackClient) ImportVectorStore(ctx context.Context, r io.Reader) (*VectorStore, error) {
+	ctx = ensureCorrelationID(ctx)
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if header.Name != "manifest.json" {
+		return nil, fmt.Errorf("archive does not start with manifest.json (found %s)", header.Name)
+	}
+	var manifest vectorStoreManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if manifest.Version != vectorStoreArchiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", manifest.Version)
+	}
+
+	byPath := make(map[string]vectorStoreArchiveFile, len(manifest.Files))
+	for _, f := range manifest.Files {
+		byPath[f.Path] = f
+	}
+
+	store, err := c.CreateVectorStoreWithParams(ctx, VectorStoreCreateParams{
+		Name:         manifest.Store.Name,
+		Metadata:     manifest.Store.Metadata,
+		ExpiresAfter: manifest.Store.ExpiresAfter,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vector store: %w", err)
+	}
+
+	imported := 0
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return store, fmt.Errorf("failed to read archive: %w", err)
+		}
+		f, ok := byPath[header.Name]
+		if !ok {
+			continue // not listed in the manifest, e.g. added by hand
+		}
+
+		purpose := FilePurpose(f.Purpose)
+		if purpose == "" {
+			purpose = FilePurposeAssistants
+		}
+		uploaded, err := c.UploadFileFromReader(ctx, path.Base(f.Filename), tr, purpose)
+		if err != nil {
+			return store, fmt.Errorf("failed to upload %s: %w", f.Filename, err)
+		}
+		if want := FileChecksum(f.Attributes); want != "" && uploaded.SHA256 != want {
+			c.DeleteFile(ctx, uploaded.ID) // best effort, the archive entry is corrupt
+			return store, fmt.Errorf("%w: %s has sha256 %s in the archive, expected %s", ErrChecksumMismatch, f.Filename, uploaded.SHA256, want)
+		}
+		if _, err := c.attachFile(ctx, store.ID, uploaded.ID, checksumAttributes(f.Attributes, uploaded), f.ChunkingStrategy); err != nil {
+			return store, fmt.Errorf("failed to attach %s: %w", f.Filename, err)
+		}
+		imported++
+	}
+	if imported != len(manifest.Files) {
+		return store, fmt.Errorf("archive is missing %d of %d files listed in its manifest", len(manifest.Files)-imported, len(manifest.Files))
+	}
+	return store, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// VectorStoreExpirationAnchor is the timestamp an expiration counts from:
+// the store's last use, the only anchor the API supports
+const VectorStoreExpirationAnchor = "last_active_at"
+
+// VectorStoreExpiration makes the server delete a vector store that has not
+// been used for Days days
+type VectorStoreExpiration struct {
+	Anchor string `json:"anchor"`
+	Days   int    `json:"days"`
+}
+
+// ExpireAfterDays returns an expiration of days after the store's last use
+func ExpireAfterDays(days int) *VectorStoreExpiration {
+	return &VectorStoreExpiration{Anchor: VectorStoreExpirationAnchor, Days: days}
+}
+
+// Validate checks the expiration against the limits the API accepts
+func (e *VectorStoreExpiration) Validate() error {
+	if e.Anchor != VectorStoreExpirationAnchor {
+		return fmt.Errorf("unsupported expiration anchor %q, expected %q", e.Anchor, VectorStoreExpirationAnchor)
+	}
+	if e.Days < 1 || e.Days > 365 {
+		return fmt.Errorf("expiration must be between 1 and 365 days, got %d", e.Days)
+	}
+	return nil
+}
+
+// vectorStoreExpiry describes when a store expires relative to now, e.g.
+// "expires in 6d", "expired" or "no expiry"
+func vectorStoreExpiry(vs VectorStore, now time.Time) string {
+	if vs.Status == "expired" {
+		return "expired"
+	}
+	if vs.ExpiresAt == nil {
+		return "no expiry"
+	}
+	left := time.Unix(*vs.ExpiresAt, 0).Sub(now).Round(time.Minute)
+	switch {
+	case left <= 0:
+		return "expired"
+	case left >= 48*time.Hour:
+		return fmt.Sprintf("expires in %dd", int(left.Hours()/24))
+	case left >= time.Hour:
+		return fmt.Sprintf("expires in %dh", int(left.Hours()))
+	}
+	return fmt.Sprintf("expires in %dm", max(int(left.Minutes()), 1))
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VectorStoreFileContent is the parsed content the server stored for one file
+type VectorStoreFileContent struct {
+	FileID     string                 `json:"file_id"`
+	Filename   string                 `json:"filename"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Chunks     []VectorStoreChunk     `json:"-"`
+}
+
+// VectorStoreChunk is one chunk of a vector store file
+type VectorStoreChunk struct {
+	Index    int
+	Text     string
+	Tokens   int                    // estimated with CountTokens
+	Metadata map[string]interface{} // chunk metadata, when the server includes it
+}
+
+// GetVectorStoreFileContent returns the chunks the server produced for a file
+// attached to a vector store, to check what retrieval can actually find
+func (c *LlamaStackClient) GetVectorStoreFileContent(ctx context.Context, vectorStoreID, fileID string) (*VectorStoreFileContent, error) {
+	// Older servers return the chunks in "content", newer ones page them in "data"
+	var raw struct {
+		VectorStoreFileContent
+		Content []contentChunk `json:"content"`
+		Data    []contentChunk `json:"data"`
+	}
+	endpoint := fmt.Sprintf("/v1/openai/v1/vector_stores/%s/files/%s/content", url.PathEscape(vectorStoreID), url.PathEscape(fileID))
+	if err := c.getJSON(ctx, endpoint, &raw); err != nil {
+		return nil, err
+	}
+
+	content := raw.VectorStoreFileContent
+	if content.FileID == "" {
+		content.FileID = fileID
+	}
+	for _, item := range append(raw.Content, raw.Data...) {
+		if item.Type != "" && item.Type != "text" {
+			continue
+		}
+		metadata := item.Metadata
+		if metadata == nil {
+			metadata = item.ChunkMetadata
+		}
+		content.Chunks = append(content.Chunks, VectorStoreChunk{
+			Index:    len(content.Chunks) + 1,
+			Text:     item.Text,
+			Tokens:   CountTokens("", item.Text),
+			Metadata: metadata,
+		})
+	}
+	return &content, nil
+}
+
+// contentChunk is one item of a file content response
+type contentChunk struct {
+	Type          string                 `json:"type"`
+	Text          string                 `json:"text"`
+	Metadata      map[string]interface{} `json:"metadata"`
+	ChunkMetadata map[string]interface{} `json:"chunk_metadata"`
+}
+
+// runVectorStoreCommand handles the "vector-store inspect", "sync" and "ingest" subcommands
+func runVectorStoreCommand(client *LlamaStackClient, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return runListStoresCommand(client, args[1:])
+		case "inspect":
+			return runInspectCommand(client, args[1:])
+		case "sync":
+			return runSyncCommand(client, args[1:])
+		case "ingest":
+			return runIngestCommand(client, args[1:])
+		}
+	}
+	return errors.New("usage: vector-store list|inspect|sync|ingest ...")
+}
+
+// runListStoresCommand handles "vector-store list", showing when each store
+// expires next to its name
+func runListStoresCommand(client *LlamaStackClient, args []string) error {
+	if len(args) > 0 {
+		return errors.New("usage: vector-store list")
+	}
+	stores, err := client.ListVectorStores(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to list vector stores: %w", err)
+	}
+	now := time.Now()
+	rows := make([][]string, 0, len(stores))
+	for _, vs := range stores {
+		expiry := vectorStoreExpiry(vs, now)
+		if vs.ExpiresAt != nil {
+			expiry += " (" + time.Unix(*vs.ExpiresAt, 0).Format("2006-01-02 15:04") + ")"
+		}
+		rows = append(rows, []string{vs.ID, vs.Name, expiry, pluralize(vs.FileCounts["total"], "file"), vs.Status})
+	}
+	for _, line := range alignColumns(rows) {
+		fmt.Println(line)
+	}
+	fmt.Printf("%s\n", pluralize(len(stores), "vector store"))
+	return nil
+}
+
+// runInspectCommand handles "vector-store inspect STORE_ID [FILE_ID]"
+func runInspectCommand(client *LlamaStackClient, args []string) error {
+	fs := flag.NewFlagSet("vector-store inspect", flag.ContinueOnError)
+	full := fs.Bool("full", false, "print whole chunks instead of the first line")
+	grep := fs.String("grep", "", "only show chunks containing this text (case-insensitive)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return errors.New("usage: vector-store inspect [-full] [-grep TEXT] STORE_ID [FILE_ID]")
+	}
+
+	ctx := context.Background()
+	storeID := fs.Arg(0)
+	store, err := client.GetVectorStore(ctx, storeID)
+	if err != nil {
+		return fmt.Errorf("failed to get vector store: %w", err)
+	}
+	files, err := client.ListVectorStoreFiles(ctx, storeID)
+	if err != nil {
+		return fmt.Errorf("failed to list vector store files: %w", err)
+	}
+	fmt.Printf("Vector store %s (%s): %d files\n", store.ID, store.Name, len(files))
+
+	if fs.NArg() > 1 {
+		files = filterFileID(files, fs.Arg(1))
+		if len(files) == 0 {
+			return fmt.Errorf("file %s is not attached to %s", fs.Arg(1), storeID)
+		}
+	}
+
+	for _, f := range files {
+		content, err := client.GetVectorStoreFileContent(ctx, storeID, f.ID)
+		if err != nil {
+			fmt.Printf("\n%s: failed to get content: %v\n", f.ID, err)
+			continue
+		}
+		tokens := 0
+		for _, chunk := range content.Chunks {
+			tokens += chunk.Tokens
+		}
+		fmt.Printf("\n%s  %s  status=%s  chunks=%d  ~tokens=%d\n", f.ID, content.Filename, f.Status, len(content.Chunks), tokens)
+		if f.LastError != nil {
+			fmt.Printf("  last error: %s: %s\n", f.LastError.Code, f.LastError.Message)
+		}
+		if len(f.Attributes) > 0 {
+			fmt.Printf("  attributes: %s\n", formatAttributes(f.Attributes))
+		}
+		if len(files) > 1 && fs.NArg() < 2 && *grep == "" {
+			continue // the per-file summary is enough when listing a whole store
+		}
+
+		for _, chunk := range content.Chunks {
+			if *grep != "" && !strings.Contains(strings.ToLower(chunk.Text), strings.ToLower(*grep)) {
+				continue
+			}
+			text := chunk.Text
+			if !*full {
+				text, _, _ = strings.Cut(strings.TrimSpace(text), "\n")
+				if r := []rune(text); len(r) > 100 {
+					text = string(r[:100]) + "..."
+				}
+			}
+			fmt.Printf("  [%d] ~%d tokens: %s\n", chunk.Index, chunk.Tokens, text)
+			if len(chunk.Metadata) > 0 {
+				fmt.Printf("      metadata: %s\n", formatAttributes(chunk.Metadata))
+			}
+		}
+	}
+	return nil
+}
+
+func filterFileID(files []VectorStoreFile, id string) []VectorStoreFile {
+	for _, f := range files {
+		if f.ID == id {
+			return []VectorStoreFile{f}
+		}
+	}
+	return nil
+}
+
+// formatAttributes renders attributes as sorted key=value pairs
+func formatAttributes(attrs map[string]interface{}) string {
+	pairs := make([]string, 0, len(attrs))
+	for k, v := range attrs {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ContentPart is one part of a chat message whose content is a list rather
+// than a string, in the OpenAI format: {"type": "text", "text": ...} or
+// {"type": "image_url", "image_url": {"url": ...}}
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL is the image of a content part: an http(s) URL the server can
+// fetch or a base64 data: URL
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"` // "low", "high" or "auto"
+}
+
+// ErrNoVisionModel is returned when no registered model can read images
+var ErrNoVisionModel = errors.New("no vision-capable model registered on this distro")
+
+// visionModelHints are parts of the IDs of model families that read images
+var visionModelHints = []string{"vision", "llava", "llama-4", "llama4", "scout", "maverick", "pixtral", "-vl", "gemma-3", "gemma3"}
+
+// MarshalJSON sends the content as a string, or as a list of parts when the
+// message has Parts: a text part with Content, if any, then Parts
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	if len(m.Parts) == 0 {
+		return json.Marshal(message(m))
+	}
+	parts := m.Parts
+	if m.Content != "" {
+		parts = append([]ContentPart{TextPart(m.Content)}, parts...)
+	}
+	return json.Marshal(struct {
+		Role    string        `json:"role"`
+		Content []ContentPart `json:"content"`
+		Name    string        `json:"name,omitempty"`
+	}{m.Role, parts, m.Name})
+}
+
+// UnmarshalJSON accepts string content and lists of parts; the text parts of
+// a list are joined into Content and the others kept in Parts
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+		Name    string          `json:"name,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = Message{Role: raw.Role, Name: raw.Name}
+	if len(raw.Content) == 0 || string(raw.Content) == "null" {
+		return nil
+	}
+	if raw.Content[0] != '[' {
+		return json.Unmarshal(raw.Content, &m.Content)
+	}
+	var parts []ContentPart
+	if err := json.Unmarshal(raw.Content, &parts); err != nil {
+		return err
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		} else {
+			m.Parts = append(m.Parts, p)
+		}
+	}
+	m.Content = strings.Join(texts, "\n")
+	return nil
+}
+
+// Images returns how many image parts the message has
+func (m Message) Images() int {
+	n := 0
+	for _, p := range m.Parts {
+		if p.Type == "image_url" {
+			n++
+		}
+	}
+	return n
+}
+
+// TextPart is a text content part
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: "text", Text: text}
+}
+
+// ImageURLPart is an image the server fetches from url
+func ImageURLPart(url string) ContentPart {
+	return ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: url}}
+}
+
+// ImageFilePart reads the image at path into a base64 data: URL part, so
+// the server needs no access to the file
+func ImageFilePart(path string) (ContentPart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ContentPart{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return ContentPart{}, fmt.Errorf("%s is not an image (%s)", path, mimeType)
+	}
+	return ImageURLPart("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// UploadedImagePart refers to an image uploaded with the Files API by its
+// content URL, so large images are not resent with every message
+func (c *LlamaStackClient) UploadedImagePart(fileID string) ContentPart {
+	return ImageURLPart(c.BaseURL + "/v1/openai/v1/files/" + fileID + "/content")
+}
+
+// ImageMessage is a user message asking text about images
+func ImageMessage(text string, images ...ContentPart) Message {
+	return Message{Role: "user", Content: text, Parts: images}
+}
+
+// VisionModel returns the first registered LLM that can read images: one
+// whose metadata says so, or whose ID names a vision model family such as
+// Llama 3.2 Vision, Llama 4 or LLaVA
+func (c *LlamaStackClient) VisionModel(ctx context.Context) (string, error) {
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list models: %w", err)
+	}
+	var llms []string
+	for _, model := range models.Data {
+		if model.ModelType != "llm" {
+			continue
+		}
+		llms = append(llms, model.Identifier)
+		if isVisionModel(model) {
+			return model.Identifier, nil
+		}
+	}
+	return "", fmt.Errorf("%w (available llms: %s); register one such as meta-llama/Llama-3.2-11B-Vision-Instruct", ErrNoVisionModel, listOrNone(llms))
+}
+
+// isVisionModel reports whether model's metadata or ID says it reads images
+func isVisionModel(model Model) bool {
+	if v, ok := model.Metadata["vision"].(bool); ok {
+		return v
+	}
+	if modalities, ok := model.Metadata["input_modalities"].([]interface{}); ok {
+		for _, m := range modalities {
+			if m == "image" {
+				return true
+			}
+		}
+	}
+	id := strings.ToLower(model.Identifier)
+	for _, hint := range visionModelHints {
+		if strings.Contains(id, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// runVisionCommand handles "vision": it sends an image with a question to a
+// vision model and streams the answer; further questions continue the same
+// conversation
+func runVisionCommand(client *LlamaStackClient, args []string, raw bool) error {
+	fs := flag.NewFlagSet("vision", flag.ContinueOnError)
+	model := fs.String("model", "", "vision model; the first registered one by default")
+	upload := fs.Bool("upload", false, "upload the image with the Files API and refer to it instead of sending it inline")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errors.New("usage: vision [-model MODEL] [-upload] IMAGE QUESTION [QUESTION]...")
+	}
+	ctx := context.Background()
+	image := fs.Arg(0)
+
+	var err error
+	if *model == "" {
+		if *model, err = client.VisionModel(ctx); err != nil {
+			return err
+		}
+	}
+	var part ContentPart
+	switch {
+	case strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://"):
+		part = ImageURLPart(image)
+	case *upload:
+		file, err := client.UploadFile(ctx, image, FilePurposeAssistants)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Uploaded %s as %s\n", filepath.Base(image), file.ID)
+		part = client.UploadedImagePart(file.ID)
+	default:
+		if part, err = ImageFilePart(image); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Asking %s about %s\n", *model, image)
+	// The image goes with the first question only; later ones refer back to it
+	messages := []Message{ImageMessage(fs.Arg(1), part)}
+	for i, question := range fs.Args()[1:] {
+		if i > 0 {
+			messages = append(messages, Message{Role: "user", Content: question})
+		}
+		fmt.Printf("\n> %s\n", question)
+		reply, err := streamReplReply(ctx, client, *model, messages, raw)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, Message{Role: "assistant", Content: reply})
+	}
+	return nil
+}
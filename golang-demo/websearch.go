@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ProviderDataHeader carries per-request provider settings, such as search
+// API keys, as a JSON object
+const ProviderDataHeader = "X-LlamaStack-Provider-Data"
+
+// WebSearchProvider names a backend of the builtin::websearch toolgroup
+type WebSearchProvider string
+
+const (
+	WebSearchBrave  WebSearchProvider = "brave"
+	WebSearchTavily WebSearchProvider = "tavily"
+	WebSearchBing   WebSearchProvider = "bing"
+)
+
+// WebSearchProviders lists the supported web search backends
+var WebSearchProviders = []WebSearchProvider{WebSearchBrave, WebSearchTavily, WebSearchBing}
+
+// APIKeyField returns the provider-data key the server reads the provider's
+// API key from, e.g. "tavily_search_api_key"
+func (p WebSearchProvider) APIKeyField() string {
+	return string(p) + "_search_api_key"
+}
+
+// WithProviderData sends key with every request in the provider-data header
+func WithProviderData(key string, value interface{}) ClientOption {
+	return func(c *LlamaStackClient) {
+		if c.ProviderData == nil {
+			c.ProviderData = make(map[string]interface{})
+		}
+		c.ProviderData[key] = value
+	}
+}
+
+// WithWebSearchAPIKey passes the API key of a web search provider, so agents
+// with the builtin::websearch toolgroup can search
+func WithWebSearchAPIKey(provider WebSearchProvider, key string) ClientOption {
+	return WithProviderData(provider.APIKeyField(), key)
+}
+
+type contextProviderDataKey struct{}
+
+// WithContextProviderData returns a context whose requests also send key in
+// the provider-data header, overriding the client's value, e.g. a tenant's
+// own search API key
+func WithContextProviderData(ctx context.Context, key string, value interface{}) context.Context {
+	data := make(map[string]interface{})
+	if parent, ok := ctx.Value(contextProviderDataKey{}).(map[string]interface{}); ok {
+		for k, v := range parent {
+			data[k] = v
+		}
+	}
+	data[key] = value
+	return context.WithValue(ctx, contextProviderDataKey{}, data)
+}
+
+// providerDataHeader returns the provider-data header for requests made with
+// ctx, or "" if there is none
+func (c *LlamaStackClient) providerDataHeader(ctx context.Context) (string, error) {
+	fromCtx, _ := ctx.Value(contextProviderDataKey{}).(map[string]interface{})
+	if len(c.ProviderData) == 0 && len(fromCtx) == 0 {
+		return "", nil
+	}
+	data := make(map[string]interface{}, len(c.ProviderData)+len(fromCtx))
+	for k, v := range c.ProviderData {
+		data[k] = v
+	}
+	for k, v := range fromCtx {
+		data[k] = v
+	}
+	header, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal provider data: %w", err)
+	}
+	return string(header), nil
+}
+
+// WebSearchResult is one page found by a web_search call
+type WebSearchResult struct {
+	Title   string
+	URL     string
+	Content string  // snippet or description of the page
+	Score   float64 // relevance, if the provider reports one
+}
+
+// WebSearchResults holds what one web_search call found
+type WebSearchResults struct {
+	CallID  string
+	Query   string
+	Results []WebSearchResult
+}
+
+// ParseWebSearchResults decodes the JSON a web_search tool returns. Brave,
+// Tavily and Bing name their fields differently; all map onto
+// WebSearchResult.
+func ParseWebSearchResults(content string) (WebSearchResults, error) {
+	var raw struct {
+		Query string                   `json:"query"`
+		TopK  []map[string]interface{} `json:"top_k"`
+	}
+	if err := json.Unmarshal([]byte(content), &raw); err != nil {
+		return WebSearchResults{}, fmt.Errorf("failed to decode web search results: %w", err)
+	}
+	results := WebSearchResults{Query: raw.Query}
+	for _, item := range raw.TopK {
+		r := WebSearchResult{
+			Title:   firstString(item, "title", "name"),
+			URL:     firstString(item, "url"),
+			Content: firstString(item, "content", "description", "snippet"),
+		}
+		r.Score, _ = item["score"].(float64)
+		if r.URL == "" && r.Title == "" && r.Content == "" {
+			continue
+		}
+		results.Results = append(results.Results, r)
+	}
+	return results, nil
+}
+
+// firstString returns the first of keys that holds a non-empty string
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := m[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// WebSearchResultsFromTurn decodes the results of the web_search calls the
+// server ran during a turn, skipping responses that are not search results,
+// such as errors
+func WebSearchResultsFromTurn(turn *Turn) []WebSearchResults {
+	if turn == nil {
+		return nil
+	}
+	var all []WebSearchResults
+	for _, raw := range turn.Steps {
+		step, ok := raw.(map[string]interface{})
+		if !ok || step["step_type"] != "tool_execution" {
+			continue
+		}
+		queries := make(map[string]string) // query argument by call ID
+		calls, _ := step["tool_calls"].([]interface{})
+		for _, c := range calls {
+			call, _ := c.(map[string]interface{})
+			args, _ := call["arguments"].(map[string]interface{})
+			if id, _ := call["call_id"].(string); id != "" && args != nil {
+				queries[id], _ = args["query"].(string)
+			}
+		}
+		responses, _ := step["tool_responses"].([]interface{})
+		for _, r := range responses {
+			resp, _ := r.(map[string]interface{})
+			if resp == nil || resp["tool_name"] != "web_search" {
+				continue
+			}
+			results, err := ParseWebSearchResults(contentText(resp["content"]))
+			if err != nil {
+				continue
+			}
+			results.CallID, _ = resp["call_id"].(string)
+			if results.Query == "" {
+				results.Query = queries[results.CallID]
+			}
+			all = append(all, results)
+		}
+	}
+	return all
+}
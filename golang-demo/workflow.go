@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// WorkflowStep is one step of a workflow. Run gets the outputs of the steps
+// named in DependsOn, by name, and returns the step's output. A Go function
+// is a step as is; ChatStep, RAGStep and AgentStep build the others.
+type WorkflowStep struct {
+	Name      string
+	DependsOn []string
+	Run       func(ctx context.Context, inputs map[string]interface{}) (interface{}, error)
+}
+
+// WorkflowOptions configures RunWorkflow
+type WorkflowOptions struct {
+	Concurrency int // steps running at once; defaults to 4
+
+	// Progress, if set, is called when a step starts, finishes, fails or is
+	// skipped. Calls are made one at a time.
+	Progress func(WorkflowEvent)
+}
+
+// WorkflowEvent reports the progress of one step
+type WorkflowEvent struct {
+	Step     string
+	Status   string // "started", "done", "failed" or "skipped"
+	Output   interface{}
+	Err      error         // why the step failed or was skipped
+	Duration time.Duration // for done and failed steps
+}
+
+// WorkflowResult holds what each step of a workflow produced
+type WorkflowResult struct {
+	Outputs map[string]interface{}
+	Errors  map[string]error // failed and skipped steps
+}
+
+// RunWorkflow runs steps as soon as the steps they depend on are done, up to
+// Concurrency at once. A failed step does not stop the steps independent of
+// it; steps depending on it are skipped. When ctx is done, steps not yet
+// started are skipped. It returns an error without running anything if the
+// steps do not form a DAG, and the step errors joined otherwise.
+func RunWorkflow(ctx context.Context, steps []WorkflowStep, opts WorkflowOptions) (*WorkflowResult, error) {
+	if err := validateWorkflow(steps); err != nil {
+		return nil, err
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	report := func(event WorkflowEvent) {
+		if opts.Progress != nil {
+			opts.Progress(event)
+		}
+	}
+
+	byName := make(map[string]*WorkflowStep, len(steps))
+	waiting := make(map[string]int, len(steps)) // unfinished dependencies of each step
+	dependents := make(map[string][]string)
+	var ready []string
+	for i := range steps {
+		s := &steps[i]
+		byName[s.Name] = s
+		waiting[s.Name] = len(s.DependsOn)
+		for _, dep := range s.DependsOn {
+			dependents[dep] = append(dependents[dep], s.Name)
+		}
+		if len(s.DependsOn) == 0 {
+			ready = append(ready, s.Name)
+		}
+	}
+
+	type stepDone struct {
+		name     string
+		output   interface{}
+		err      error
+		duration time.Duration
+	}
+	done := make(chan stepDone)
+	result := &WorkflowResult{Outputs: make(map[string]interface{}), Errors: make(map[string]error)}
+	var errs []error
+
+	// skip marks a step and everything depending on it as skipped
+	var skip func(name string, reason error)
+	skip = func(name string, reason error) {
+		if _, ok := result.Errors[name]; ok {
+			return
+		}
+		result.Errors[name] = reason
+		report(WorkflowEvent{Step: name, Status: "skipped", Err: reason})
+		for _, d := range dependents[name] {
+			skip(d, fmt.Errorf("depends on %s, which did not run", name))
+		}
+	}
+
+	running, finished := 0, 0
+	for finished < len(steps) {
+		for running < concurrency && len(ready) > 0 {
+			name := ready[0]
+			ready = ready[1:]
+			if ctx.Err() != nil {
+				skip(name, ctx.Err())
+				continue
+			}
+			s := byName[name]
+			inputs := make(map[string]interface{}, len(s.DependsOn))
+			for _, dep := range s.DependsOn {
+				inputs[dep] = result.Outputs[dep]
+			}
+			report(WorkflowEvent{Step: name, Status: "started"})
+			running++
+			go func() {
+				start := time.Now()
+				output, err := s.Run(ctx, inputs)
+				done <- stepDone{name: name, output: output, err: err, duration: time.Since(start)}
+			}()
+		}
+		// Skipped steps are finished too
+		finished = len(result.Outputs) + len(result.Errors)
+		if running == 0 {
+			if finished < len(steps) {
+				// Cannot happen for a validated DAG; better than waiting forever
+				return result, fmt.Errorf("workflow stalled with %d steps left", len(steps)-finished)
+			}
+			break
+		}
+
+		d := <-done
+		running--
+		if d.err != nil {
+			result.Errors[d.name] = d.err
+			errs = append(errs, fmt.Errorf("step %s: %w", d.name, d.err))
+			report(WorkflowEvent{Step: d.name, Status: "failed", Err: d.err, Duration: d.duration})
+			for _, dep := range dependents[d.name] {
+				skip(dep, fmt.Errorf("depends on %s, which failed", d.name))
+			}
+		} else {
+			result.Outputs[d.name] = d.output
+			report(WorkflowEvent{Step: d.name, Status: "done", Output: d.output, Duration: d.duration})
+			for _, dep := range dependents[d.name] {
+				waiting[dep]--
+				if _, skipped := result.Errors[dep]; waiting[dep] == 0 && !skipped {
+					ready = append(ready, dep)
+				}
+			}
+		}
+		finished = len(result.Outputs) + len(result.Errors)
+	}
+	if ctx.Err() != nil && len(result.Outputs) < len(steps) {
+		errs = append(errs, ctx.Err())
+	}
+	return result, errors.Join(errs...)
+}
+
+// validateWorkflow checks step names are unique, dependencies exist and
+// there are no cycles
+func validateWorkflow(steps []WorkflowStep) error {
+	names := make(map[string]bool, len(steps))
+	for _, s := range steps {
+		if s.Name == "" {
+			return errors.New("workflow step without a name")
+		}
+		if names[s.Name] {
+			return fmt.Errorf("duplicate workflow step %s", s.Name)
+		}
+		if s.Run == nil {
+			return fmt.Errorf("workflow step %s has no Run func", s.Name)
+		}
+		names[s.Name] = true
+	}
+	for _, s := range steps {
+		for _, dep := range s.DependsOn {
+			if !names[dep] {
+				return fmt.Errorf("workflow step %s depends on unknown step %s", s.Name, dep)
+			}
+		}
+	}
+
+	// Depth-first search; a step seen again while on the stack closes a cycle
+	deps := make(map[string][]string, len(steps))
+	for _, s := range steps {
+		deps[s.Name] = s.DependsOn
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(steps))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("workflow has a cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, s := range steps {
+		if err := visit(s.Name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderStepPrompt expands a text/template prompt with the outputs of a
+// step's dependencies, e.g. "Summarize: {{.research}}"; use
+// {{index . "step-name"}} for names that are not identifiers
+func renderStepPrompt(step, prompt string, inputs map[string]interface{}) (string, error) {
+	tmpl, err := template.New(step).Option("missingkey=error").Parse(prompt)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, inputs); err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+	return b.String(), nil
+}
+
+// ChatStep is a workflow step sending prompt, a template over the outputs of
+// its dependencies, as a chat completion; its output is the reply
+func ChatStep(client *LlamaStackClient, name, model, prompt string, dependsOn ...string) WorkflowStep {
+	return WorkflowStep{
+		Name:      name,
+		DependsOn: dependsOn,
+		Run: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+			content, err := renderStepPrompt(name, prompt, inputs)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := client.CreateChatCompletion(ctx, ChatCompletionParams{
+				Model:    model,
+				Messages: []Message{{Role: "user", Content: content}},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(resp.Choices) == 0 {
+				return nil, errors.New("no choices in response")
+			}
+			return resp.Choices[0].Message.Content, nil
+		},
+	}
+}
+
+// RAGStep is a workflow step querying vectorDBIDs with query, a template over
+// the outputs of its dependencies; its output is the retrieved text
+func RAGStep(client *LlamaStackClient, name string, vectorDBIDs []string, query string, dependsOn ...string) WorkflowStep {
+	return WorkflowStep{
+		Name:      name,
+		DependsOn: dependsOn,
+		Run: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+			content, err := renderStepPrompt(name, query, inputs)
+			if err != nil {
+				return nil, err
+			}
+			result, err := client.QueryRAG(ctx, RagToolQueryParams{Content: content, VectorDBIDs: vectorDBIDs})
+			if err != nil {
+				return nil, err
+			}
+			return contentText(result.Content), nil
+		},
+	}
+}
+
+// AgentStep is a workflow step running prompt, a template over the outputs
+// of its dependencies, as a turn of agentID in a new session; its output is
+// the agent's final answer
+func AgentStep(runner *AgentRunner, name, agentID, prompt string, dependsOn ...string) WorkflowStep {
+	return WorkflowStep{
+		Name:      name,
+		DependsOn: dependsOn,
+		Run: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+			content, err := renderStepPrompt(name, prompt, inputs)
+			if err != nil {
+				return nil, err
+			}
+			session, err := runner.Client.CreateSession(ctx, agentID, SessionCreateParams{SessionName: "workflow-" + name})
+			if err != nil {
+				return nil, err
+			}
+			turn, err := runner.Run(ctx, agentID, session.SessionID, []Message{{Role: "user", Content: content}})
+			if err != nil {
+				return nil, err
+			}
+			return turn.OutputMessage.Content, nil
+		},
+	}
+}
@@ -0,0 +1,706 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// This file implements the small YAML subset used for agent presets, model
+// profiles and pricing tables. Values round-trip through JSON, so any struct
+// with json tags can be stored. The parser accepts:
+//
+//   - one document, optionally opened by "---", with # comments
+//   - block mappings and sequences indented with spaces, including
+//     "- key: value" items and sequences at their parent key's indentation
+//   - plain scalars resolving to null (~, null), bools (true, false),
+//     decimal numbers or strings; yes/no, 0x10, inf and the like stay strings
+//   - single-quoted ('' escapes a quote) and double-quoted (JSON escapes)
+//     scalars, also as keys
+//   - literal block scalars with the |, |- and |+ headers
+//   - flow collections ([a, b] and {k: v}) that fit on one line
+//
+// Anchors, aliases and merge keys, tags, folded (>) block scalars, block
+// indentation indicators, complex (?) keys, directives, multi-line flow
+// collections and multi-line plain or quoted scalars are rejected with an
+// error rather than read as strings.
+
+// marshalYAML renders v, via its JSON encoding, as block-style YAML
+func marshalYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch n := node.(type) {
+	case yamlMap:
+		if len(n) == 0 {
+			buf.WriteString("{}\n")
+		}
+		writeYAMLMap(&buf, n, 0)
+	case []interface{}:
+		if len(n) == 0 {
+			buf.WriteString("[]\n")
+		}
+		writeYAMLList(&buf, n, 0)
+	default:
+		buf.WriteString(yamlScalar(n, 0) + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalYAML parses data and decodes it into v through its JSON form
+func unmarshalYAML(data []byte, v interface{}) error {
+	value, err := parseYAML(data)
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, v)
+}
+
+// yamlMap is a mapping that keeps its keys in document order
+type yamlMap []yamlPair
+
+type yamlPair struct {
+	Key   string
+	Value interface{}
+}
+
+// decodeOrdered reads one JSON value, keeping object keys in order
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			list := []interface{}{}
+			for dec.More() {
+				item, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			_, err := dec.Token()
+			return list, err
+		}
+		m := yamlMap{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yamlPair{Key: keyTok.(string), Value: value})
+		}
+		_, err := dec.Token()
+		return m, err
+	}
+	return tok, nil
+}
+
+func writeYAMLMap(buf *bytes.Buffer, m yamlMap, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, p := range m {
+		key := yamlInlineString(p.Key)
+		switch v := p.Value.(type) {
+		case yamlMap:
+			if len(v) == 0 {
+				fmt.Fprintf(buf, "%s%s: {}\n", pad, key)
+				continue
+			}
+			fmt.Fprintf(buf, "%s%s:\n", pad, key)
+			writeYAMLMap(buf, v, indent+2)
+		case []interface{}:
+			if len(v) == 0 {
+				fmt.Fprintf(buf, "%s%s: []\n", pad, key)
+				continue
+			}
+			fmt.Fprintf(buf, "%s%s:\n", pad, key)
+			writeYAMLList(buf, v, indent+2)
+		default:
+			fmt.Fprintf(buf, "%s%s: %s\n", pad, key, yamlScalar(v, indent+2))
+		}
+	}
+}
+
+func writeYAMLList(buf *bytes.Buffer, list []interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, item := range list {
+		switch v := item.(type) {
+		case yamlMap:
+			if len(v) == 0 {
+				fmt.Fprintf(buf, "%s- {}\n", pad)
+				continue
+			}
+			// The first key shares the line with the dash
+			var inner bytes.Buffer
+			writeYAMLMap(&inner, v, indent+2)
+			fmt.Fprintf(buf, "%s- %s", pad, strings.TrimPrefix(inner.String(), pad+"  "))
+		case []interface{}:
+			if len(v) == 0 {
+				fmt.Fprintf(buf, "%s- []\n", pad)
+				continue
+			}
+			fmt.Fprintf(buf, "%s-\n", pad)
+			writeYAMLList(buf, v, indent+2)
+		default:
+			fmt.Fprintf(buf, "%s- %s\n", pad, yamlScalar(v, indent+2))
+		}
+	}
+}
+
+// yamlScalar renders a scalar; indent is used for literal block lines
+func yamlScalar(v interface{}, indent int) string {
+	switch s := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(s)
+	case json.Number:
+		return s.String()
+	case string:
+		if yamlLiteralSafe(s) {
+			header := "|-"
+			if strings.HasSuffix(s, "\n") {
+				header = "|"
+			}
+			pad := strings.Repeat(" ", indent)
+			lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+			for i, line := range lines {
+				if line != "" {
+					lines[i] = pad + line
+				}
+			}
+			return header + "\n" + strings.Join(lines, "\n")
+		}
+		return yamlInlineString(s)
+	}
+	return fmt.Sprint(v)
+}
+
+// yamlLiteralSafe reports whether the multi-line string s reads back the same
+// from a | block, whose indentation comes from its first non-empty line and
+// whose blank lines lose their spaces
+func yamlLiteralSafe(s string) bool {
+	if !strings.Contains(s, "\n") || strings.Trim(s, "\n") == "" || strings.HasSuffix(s, "\n\n") || strings.ContainsAny(s, "\r\t") {
+		return false
+	}
+	if strings.HasPrefix(strings.TrimLeft(s, "\n"), " ") || strings.HasSuffix(strings.TrimRight(s, "\n"), " ") {
+		return false
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" && strings.TrimLeft(line, " ") == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlInlineString renders s on one line, quoting it unless it is plain-safe;
+// mapping keys always use this form
+func yamlInlineString(s string) string {
+	if yamlPlainSafe(s) {
+		return s
+	}
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// yamlPlainSafe reports whether s can be written unquoted and read back as the same string
+func yamlPlainSafe(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s, "\n\r\t") {
+		return false
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	_, isString := parseYAMLPlain(s).(string)
+	return isString
+}
+
+// yamlLine is one significant input line
+type yamlLine struct {
+	num    int
+	indent int
+	text   string // without indentation
+}
+
+type yamlParser struct {
+	raw   []string
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses a document into maps, slices and scalars
+func parseYAML(data []byte) (interface{}, error) {
+	src := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	p := &yamlParser{raw: strings.Split(src, "\n")}
+	for i, line := range p.raw {
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml line %d: tabs are not allowed for indentation", i+1)
+		}
+		text := stripYAMLComment(trimmed)
+		if text == "" || (text == "---" && len(p.lines) == 0) {
+			continue
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(line) - len(trimmed), text: text})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+
+	// A document may also be a single scalar or flow collection
+	first := p.lines[0]
+	if _, _, isMap := splitYAMLKey(first.text); len(p.lines) == 1 && !isMap && !isYAMLSeqItem(first.text) {
+		p.pos++
+		return p.parseValue(first.text, -1, first.num, false)
+	}
+
+	value, err := p.parseBlock(first.indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, unexpectedYAMLLine(p.lines[p.pos], "unexpected indentation")
+	}
+	return value, nil
+}
+
+// parseBlock parses the mapping or sequence starting at the current line
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if isYAMLSeqItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("yaml line %d: unexpected indentation", line.num)
+		}
+		if isYAMLSeqItem(line.text) {
+			break
+		}
+
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, unexpectedYAMLLine(line, `expected "key: value"`)
+		}
+		if !isYAMLQuoted(line.text) {
+			if err := checkYAMLPlain(key); err != nil {
+				return nil, fmt.Errorf("yaml line %d: %w", line.num, err)
+			}
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("yaml line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+
+		value, err := p.parseValue(rest, indent, line.num, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isYAMLSeqItem(line.text) {
+			if line.indent > indent {
+				return nil, fmt.Errorf("yaml line %d: unexpected indentation", line.num)
+			}
+			break
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if _, _, isMap := splitYAMLKey(rest); isMap {
+			// "- key: value" opens a mapping indented to the key's column
+			column := indent + len(line.text) - len(rest)
+			p.lines[p.pos] = yamlLine{num: line.num, indent: column, text: rest}
+			item, err := p.parseMapping(column)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			continue
+		}
+
+		p.pos++
+		item, err := p.parseValue(rest, indent, line.num, false)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// parseValue parses what follows "key:" or "-"; nested blocks must be indented
+// deeper than parent, except sequences under a mapping key
+func (p *yamlParser) parseValue(rest string, parent, num int, inMapping bool) (interface{}, error) {
+	switch {
+	case rest == "":
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > parent || (inMapping && next.indent == parent && isYAMLSeqItem(next.text)) {
+				return p.parseBlock(next.indent)
+			}
+		}
+		return nil, nil
+	case rest == "|" || rest == "|-" || rest == "|+":
+		return p.parseLiteral(rest, parent, num), nil
+	case strings.HasPrefix(rest, "[") || strings.HasPrefix(rest, "{"):
+		value, remaining, err := parseYAMLFlow(rest)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %w", num, err)
+		}
+		if strings.TrimSpace(remaining) != "" {
+			return nil, fmt.Errorf("yaml line %d: unexpected %q after flow collection", num, remaining)
+		}
+		return value, nil
+	}
+	value, err := parseYAMLScalar(rest)
+	if err != nil {
+		return nil, fmt.Errorf("yaml line %d: %w", num, err)
+	}
+	return value, nil
+}
+
+// parseLiteral reads a | block scalar from the raw lines after line num
+func (p *yamlParser) parseLiteral(header string, parent, num int) string {
+	var lines []string
+	blockIndent := -1
+	last := num // 1-based number of the last raw line consumed
+	for i := num; i < len(p.raw); i++ {
+		raw := p.raw[i]
+		trimmed := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(trimmed)
+		if trimmed == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if blockIndent < 0 {
+			if indent <= parent {
+				break
+			}
+			blockIndent = indent
+		}
+		if indent < blockIndent {
+			break
+		}
+		lines = append(lines, raw[blockIndent:])
+		last = i + 1
+	}
+	trailing := countTrailingEmpty(lines)
+	lines = lines[:len(lines)-trailing]
+
+	// Skip the significant lines that belonged to the block
+	for p.pos < len(p.lines) && p.lines[p.pos].num <= last {
+		p.pos++
+	}
+
+	text := strings.Join(lines, "\n")
+	switch header {
+	case "|":
+		if text != "" {
+			text += "\n"
+		}
+	case "|+":
+		text += "\n" + strings.Repeat("\n", trailing)
+	}
+	return text
+}
+
+// unexpectedYAMLLine returns an error for a line that does not fit where it
+// appears, naming the unsupported construct when there is one
+func unexpectedYAMLLine(line yamlLine, problem string) error {
+	switch {
+	case line.text == "---":
+		problem = "multiple documents are not supported"
+	case line.text == "?" || strings.HasPrefix(line.text, "? "):
+		problem = "complex keys (?) are not supported"
+	case strings.HasPrefix(line.text, "%"):
+		problem = "directives are not supported"
+	}
+	return fmt.Errorf("yaml line %d: %s", line.num, problem)
+}
+
+func countTrailingEmpty(lines []string) int {
+	n := 0
+	for i := len(lines) - 1; i >= 0 && lines[i] == ""; i-- {
+		n++
+	}
+	return n
+}
+
+// parseYAMLFlow parses a [..] or {..} collection and returns the unconsumed input
+func parseYAMLFlow(s string) (interface{}, string, error) {
+	s = strings.TrimLeft(s, " ")
+	if s == "" {
+		return nil, "", io.ErrUnexpectedEOF
+	}
+	open := s[0]
+	if open != '[' && open != '{' {
+		return nil, "", fmt.Errorf("expected flow collection, got %q", s)
+	}
+	closing := byte(']')
+	if open == '{' {
+		closing = '}'
+	}
+	s = strings.TrimLeft(s[1:], " ")
+
+	list := []interface{}{}
+	m := make(map[string]interface{})
+	for {
+		if s == "" {
+			return nil, "", fmt.Errorf("unterminated flow collection")
+		}
+		if s[0] == closing {
+			if open == '[' {
+				return list, s[1:], nil
+			}
+			return m, s[1:], nil
+		}
+
+		var key string
+		if open == '{' {
+			var keyValue interface{}
+			var err error
+			keyValue, s, err = parseYAMLFlowScalar(s, true)
+			if err != nil {
+				return nil, "", err
+			}
+			key = fmt.Sprint(keyValue)
+			s = strings.TrimLeft(s, " ")
+			if !strings.HasPrefix(s, ":") {
+				return nil, "", fmt.Errorf("expected ':' after flow key %q", key)
+			}
+			s = strings.TrimLeft(s[1:], " ")
+		}
+
+		var value interface{}
+		var err error
+		if s != "" && (s[0] == '[' || s[0] == '{') {
+			value, s, err = parseYAMLFlow(s)
+		} else {
+			value, s, err = parseYAMLFlowScalar(s, false)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if open == '[' {
+			list = append(list, value)
+		} else {
+			m[key] = value
+		}
+
+		s = strings.TrimLeft(s, " ")
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimLeft(s[1:], " ")
+		} else if s == "" {
+			return nil, "", fmt.Errorf("unterminated flow collection")
+		} else if s[0] != closing {
+			return nil, "", fmt.Errorf("expected ',' or %q in flow collection", closing)
+		}
+	}
+}
+
+// parseYAMLFlowScalar reads one scalar inside a flow collection
+func parseYAMLFlowScalar(s string, isKey bool) (interface{}, string, error) {
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		end := quotedEnd(s)
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated quoted string")
+		}
+		value, err := parseYAMLScalar(s[:end])
+		return value, s[end:], err
+	}
+	stop := ",]}"
+	if isKey {
+		stop += ":"
+	}
+	end := strings.IndexAny(s, stop)
+	if end < 0 {
+		end = len(s)
+	}
+	value, err := parseYAMLScalar(strings.TrimSpace(s[:end]))
+	return value, s[end:], err
+}
+
+// parseYAMLScalar parses a quoted or plain scalar
+func parseYAMLScalar(s string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		if quotedEnd(s) != len(s) {
+			return nil, fmt.Errorf("invalid double-quoted string %s", s)
+		}
+		var value string
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, fmt.Errorf("invalid double-quoted string %s: %w", s, err)
+		}
+		return value, nil
+	case strings.HasPrefix(s, "'"):
+		if quotedEnd(s) != len(s) {
+			return nil, fmt.Errorf("invalid single-quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	if err := checkYAMLPlain(s); err != nil {
+		return nil, err
+	}
+	return parseYAMLPlain(s), nil
+}
+
+// checkYAMLPlain rejects plain scalars that start a construct outside the
+// subset, which a full YAML parser would not read as a string
+func checkYAMLPlain(s string) error {
+	if s == "" {
+		return nil
+	}
+	switch s[0] {
+	case '&':
+		return errors.New("anchors are not supported")
+	case '*':
+		return errors.New("aliases are not supported")
+	case '!':
+		return errors.New("tags are not supported")
+	case '>':
+		return errors.New("folded block scalars (>) are not supported")
+	case '|':
+		return fmt.Errorf("unsupported block scalar header %q, only |, |- and |+ are supported", s)
+	}
+	return nil
+}
+
+// parseYAMLPlain resolves an unquoted scalar to null, bool, number or string
+func parseYAMLPlain(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "xXpP_") && !strings.EqualFold(strings.TrimLeft(s, "+-"), "inf") && !strings.EqualFold(s, "nan") {
+		return f
+	}
+	return s
+}
+
+// quotedEnd returns the index just past the closing quote of the string s starts with, or -1
+func quotedEnd(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// splitYAMLKey splits "key: value" or "key:" at the mapping colon; a flow
+// collection is never a mapping line
+func splitYAMLKey(text string) (string, string, bool) {
+	if text != "" && (text[0] == '[' || text[0] == '{') {
+		return "", "", false
+	}
+	if isYAMLQuoted(text) {
+		end := quotedEnd(text)
+		if end < 0 || end >= len(text) || text[end] != ':' || (end+1 < len(text) && text[end+1] != ' ') {
+			return "", "", false
+		}
+		key, err := parseYAMLScalar(text[:end])
+		if err != nil {
+			return "", "", false
+		}
+		return key.(string), strings.TrimSpace(text[end+1:]), true
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			key := strings.TrimSpace(text[:i])
+			if key == "" {
+				return "", "", false
+			}
+			return key, strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func isYAMLQuoted(text string) bool {
+	return text != "" && (text[0] == '"' || text[0] == '\'')
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// stripYAMLComment removes a trailing # comment that is outside quotes
+func stripYAMLComment(text string) string {
+	if strings.HasPrefix(text, "#") {
+		return ""
+	}
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'':
+			// Quotes only open a string at the start of a scalar
+			if i == 0 || strings.ContainsRune(" [{,:-", rune(text[i-1])) {
+				if end := quotedEnd(text[i:]); end > 0 {
+					i += end - 1
+				}
+			}
+		case '#':
+			if text[i-1] == ' ' {
+				return strings.TrimRight(text[:i], " ")
+			}
+		}
+	}
+	return strings.TrimRight(text, " ")
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// normalizeJSON decodes and re-encodes JSON so that key order and number
+// formatting do not matter when comparing
+func normalizeJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // JSON
+	}{
+		{"empty", "", `null`},
+		{"only comments", "# nothing\n\n  # here\n", `null`},
+		{"document marker", "---\na: 1\n", `{"a":1}`},
+		{"crlf", "a: 1\r\nb: two\r\n", `{"a":1,"b":"two"}`},
+
+		// Block mappings and sequences
+		{"mapping", "name: agent\nmodel: llama3.2:3b\n", `{"name":"agent","model":"llama3.2:3b"}`},
+		{"nested mapping", "a:\n  b:\n    c: 1\n  d: 2\ne: 3\n", `{"a":{"b":{"c":1},"d":2},"e":3}`},
+		{"empty value", "a:\nb: 1\n", `{"a":null,"b":1}`},
+		{"sequence", "- a\n- b\n", `["a","b"]`},
+		{"sequence under key", "tools:\n  - x\n  - y\n", `{"tools":["x","y"]}`},
+		{"sequence at key indent", "tools:\n- x\n- y\nname: n\n", `{"tools":["x","y"],"name":"n"}`},
+		{"sequence of mappings", "- name: a\n  args:\n    k: v\n- name: b\n", `[{"name":"a","args":{"k":"v"}},{"name":"b"}]`},
+		{"nested sequence", "-\n  - a\n  - b\n- c\n", `[["a","b"],"c"]`},
+		{"empty item", "- \n- a\n", `[null,"a"]`},
+
+		// Plain scalars
+		{"null forms", "a: ~\nb: null\nc: NULL\n", `{"a":null,"b":null,"c":null}`},
+		{"bools", "a: true\nb: False\nc: TRUE\n", `{"a":true,"b":false,"c":true}`},
+		{"numbers", "a: 42\nb: -7\nc: 0.5\nd: 1e3\n", `{"a":42,"b":-7,"c":0.5,"d":1000}`},
+		{"number-like strings", "a: 0x10\nb: inf\nc: NaN\nd: 1_000\ne: yes\n", `{"a":"0x10","b":"inf","c":"NaN","d":"1_000","e":"yes"}`},
+		{"colon inside plain", "url: http://localhost:8321/v1\n", `{"url":"http://localhost:8321/v1"}`},
+		{"hash inside plain", "a: issue#12\n", `{"a":"issue#12"}`},
+
+		// Quoting
+		{"double quoted", `a: "x: y # not a comment"`, `{"a":"x: y # not a comment"}`},
+		{"double quoted escapes", `a: "line\nnext \"q\" \u00e9"`, `{"a":"line\nnext \"q\" é"}`},
+		{"single quoted", `a: 'it''s # here'`, `{"a":"it's # here"}`},
+		{"single quoted backslash", `a: 'C:\path'`, `{"a":"C:\\path"}`},
+		{"quoted keeps type", "a: \"true\"\nb: '42'\nc: \"\"\n", `{"a":"true","b":"42","c":""}`},
+		{"quoted key", "\"a: b\": 1\n'c''d': 2\n", `{"a: b":1,"c'd":2}`},
+		{"quoted item", "- \"- x\"\n- '#y'\n", `["- x","#y"]`},
+
+		// Comments
+		{"trailing comment", "a: 1 # one\nb: two  # two\n", `{"a":1,"b":"two"}`},
+		{"comment between keys", "a: 1\n# b: 2\nc: 3\n", `{"a":1,"c":3}`},
+		{"comment after quote", `a: "x" # y`, `{"a":"x"}`},
+
+		// Literal blocks
+		{"literal", "a: |\n  one\n  two\nb: 1\n", `{"a":"one\ntwo\n","b":1}`},
+		{"literal strip", "a: |-\n  one\n  two\n\nb: 1\n", `{"a":"one\ntwo","b":1}`},
+		{"literal keep", "a: |+\n  one\n\n\nb: 1\n", `{"a":"one\n\n\n","b":1}`},
+		{"literal inner lines", "a: |\n  one\n\n    indented\n  # not a comment\n", `{"a":"one\n\n  indented\n# not a comment\n"}`},
+		{"literal in sequence", "- |\n  text\n- b\n", `["text\n","b"]`},
+		{"literal markers", "a: |\n  ---\n  ? x\n  %y\n", `{"a":"---\n? x\n%y\n"}`},
+		{"literal empty", "a: |\nb: 1\n", `{"a":"","b":1}`},
+
+		// Flow collections
+		{"flow sequence", "a: [x, 'y, z', 3, true, null]\n", `{"a":["x","y, z",3,true,null]}`},
+		{"flow mapping", "a: {k: v, \"q:k\": [1, 2], n: {}}\n", `{"a":{"k":"v","q:k":[1,2],"n":{}}}`},
+		{"flow empty", "a: []\nb: {}\n", `{"a":[],"b":{}}`},
+		{"flow nested", "a: [[1, 2], {b: [c]}]\n", `{"a":[[1,2],{"b":["c"]}]}`},
+		{"flow in sequence", "- [a, b]\n- {k: v}\n", `[["a","b"],{"k":"v"}]`},
+		{"flow trailing comma", "a: [x, y, ]\n", `{"a":["x","y"]}`},
+		{"flow document", "{a: 1, b: [2]}", `{"a":1,"b":[2]}`},
+
+		// The pricing and model profile examples from the README
+		{"readme pricing", "openai/gpt-4o-mini:\n  prompt_per_1k: 0.00015\n  completion_per_1k: 0.0006\nollama/*:\n  per_gpu_second: 0.0004 # dollars per GPU per second\n  gpus: 1\n  watts: 350\n",
+			`{"openai/gpt-4o-mini":{"prompt_per_1k":0.00015,"completion_per_1k":0.0006},"ollama/*":{"per_gpu_second":0.0004,"gpus":1,"watts":350}}`},
+		{"readme profiles", "ollama/llama3.2:3b:\n  temperature: 0.7\n  max_tokens: 512\nvllm/*:\n  temperature: 0.2\n  top_p: 0.9\n  stop: [\"<|eot_id|>\"]\n  tool_prompt_format: json\n",
+			`{"ollama/llama3.2:3b":{"temperature":0.7,"max_tokens":512},"vllm/*":{"temperature":0.2,"top_p":0.9,"stop":["<|eot_id|>"],"tool_prompt_format":"json"}}`},
+
+		// Single scalar documents
+		{"scalar document", "hello\n", `"hello"`},
+		{"quoted scalar document", `"a: b"`, `"a: b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("parseYAML(%q): %v", tt.in, err)
+			}
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("result is not JSON-encodable: %v", err)
+			}
+			if g, w := normalizeJSON(t, data), normalizeJSON(t, []byte(tt.want)); g != w {
+				t.Fatalf("parseYAML(%q)\n got %s\nwant %s", tt.in, g, w)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n", "line 2: tabs"},
+		{"duplicate key", "a: 1\na: 2\n", "line 2: duplicate key"},
+		{"over-indented key", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"missing colon", "a: 1\nb\n", "line 2: expected"},
+		{"unterminated flow", "a: [x, y\n", "line 1: unterminated flow"},
+		{"text after flow", "a: [x] y\n", "line 1: unexpected"},
+		{"flow key without colon", "a: {k}\n", "line 1: expected ':'"},
+		{"unterminated double quote", "a: \"x\n", "line 1: invalid double-quoted"},
+		{"unterminated single quote", "a: 'x\n", "line 1: invalid single-quoted"},
+		{"bad escape", `a: "\q"`, "line 1: invalid double-quoted"},
+		{"second document", "a: 1\n---\nb: 2\n", "line 2: multiple documents"},
+		{"second document after sequence", "- a\n---\n- b\n", "line 2: multiple documents"},
+
+		// Constructs outside the subset fail instead of being read as strings
+		{"anchor", "base: &base\n  a: 1\n", "line 1: anchors"},
+		{"alias", "a: *base\n", "line 1: aliases"},
+		{"merge key", "b:\n  <<: *base\n", "line 2: aliases"},
+		{"tag", "a: !!str 1\n", "line 1: tags"},
+		{"folded block", "a: >\n  one\n  two\n", "line 1: folded"},
+		{"literal indentation indicator", "a: |2\n  one\n", "line 1: unsupported block scalar header"},
+		{"flow alias", "a: [*x]\n", "line 1: aliases"},
+		{"complex key", "? a\n: b\n", "line 1: complex keys"},
+		{"directive", "%YAML 1.2\na: 1\n", "line 1: directives"},
+		{"multi-line flow", "a: [x,\n  y]\n", "line 1: unterminated flow"},
+		{"multi-line plain", "a: one\n  two\n", "line 2: unexpected indentation"},
+		{"multi-line quoted", "a: \"one\n  two\"\n", "line 1: invalid double-quoted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.in))
+			if err == nil {
+				t.Fatalf("parseYAML(%q) = %v, want error containing %q", tt.in, got, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("parseYAML(%q) error %q does not contain %q", tt.in, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"scalars", map[string]interface{}{"s": "text", "n": 1.5, "i": 3.0, "b": false, "z": nil}},
+		{"strings needing quotes", []interface{}{
+			"", " padded ", "true", "null", "42", "1e3", "- dash", "key: value", "a #b", "ends:",
+			"#hash", "&anchor", "*alias", "!tag", "|pipe", ">fold", "'single'", `"double"`, "%pct", "@at", "`tick",
+			"[flow", "{flow", "tab\there", "carriage\rreturn", "é ünïcode",
+		}},
+		{"multi-line strings", []interface{}{
+			"one\ntwo", "one\ntwo\n", "trailing blank\n\n", " leading space\nx", "trailing space \nx",
+			"a\n\nb\n", "\nstarts with newline", "  indented\n  lines\n",
+		}},
+		{"keys needing quotes", map[string]interface{}{"a: b": 1.0, "": 2.0, "- x": 3.0, "#k": 4.0, "true": 5.0, "multi\nline": 6.0, "*": 7.0}},
+		{"empty map", map[string]interface{}{}},
+		{"empty list", []interface{}{}},
+		{"nesting", map[string]interface{}{
+			"list":   []interface{}{map[string]interface{}{"a": 1.0, "b": []interface{}{"x"}}, []interface{}{"y", "z"}, "w"},
+			"empty":  map[string]interface{}{"list": []interface{}{}, "map": map[string]interface{}{}},
+			"nested": map[string]interface{}{"deeper": map[string]interface{}{"text": "line\nnext\n"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := marshalYAML(tt.value)
+			if err != nil {
+				t.Fatalf("marshalYAML: %v", err)
+			}
+			var got interface{}
+			if err := unmarshalYAML(data, &got); err != nil {
+				t.Fatalf("unmarshalYAML: %v\n%s", err, data)
+			}
+			if !reflect.DeepEqual(got, tt.value) {
+				t.Fatalf("round trip changed the value\n got %#v\nwant %#v\nyaml:\n%s", got, tt.value, data)
+			}
+		})
+	}
+}
+
+func TestAgentPresetRoundTrip(t *testing.T) {
+	maxTokens := 256
+	cfg := AgentConfig{
+		Model:        "llama3.2:3b",
+		Name:         "rag-agent",
+		Instructions: "Answer from the documents.\nCite the file: name and page.\n",
+		ToolChoice:   "auto",
+		Toolgroups: []interface{}{
+			"builtin::websearch",
+			map[string]interface{}{"name": "builtin::rag", "args": map[string]interface{}{"vector_db_ids": []interface{}{"vs1", "vs2"}}},
+		},
+		InputShields: []string{"llama-guard"},
+		SamplingParams: &SamplingParams{
+			Strategy:  SamplingStrategy{Type: "greedy"},
+			MaxTokens: &maxTokens,
+			Stop:      []string{"\n\n", "END"},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "presets", "rag.yaml")
+	if err := SaveAgentPreset(path, cfg); err != nil {
+		t.Fatalf("SaveAgentPreset: %v", err)
+	}
+	got, err := LoadAgentPreset(path)
+	if err != nil {
+		t.Fatalf("LoadAgentPreset: %v", err)
+	}
+	if !reflect.DeepEqual(got, cfg) {
+		t.Fatalf("preset changed on the way through YAML\n got %+v\nwant %+v", got, cfg)
+	}
+}
+
+// FuzzYAMLRoundTrip checks that any string survives marshalYAML and
+// unmarshalYAML as a key, a mapping value and a list item
+func FuzzYAMLRoundTrip(f *testing.F) {
+	for _, seed := range []string{"", "plain", "a: b", "- x", "one\ntwo\n", " lead\n", "'q'", "#c", "tab\t", "\n", "\n\n", "\n 0", "a\n  \nb", "\n?", "x\n---\n%y", "|+", "*"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			return // JSON, and so the YAML form, replaces invalid bytes
+		}
+		value := map[string]interface{}{s: map[string]interface{}{"v": s, "list": []interface{}{s, "x"}}}
+		data, err := marshalYAML(value)
+		if err != nil {
+			t.Fatalf("marshalYAML: %v", err)
+		}
+		var got interface{}
+		if err := unmarshalYAML(data, &got); err != nil {
+			t.Fatalf("unmarshalYAML: %v\n%s", err, data)
+		}
+		if !reflect.DeepEqual(got, value) {
+			t.Fatalf("round trip changed %q\nyaml:\n%s", s, data)
+		}
+	})
+}
+
+// FuzzParseYAML checks that the parser never panics and that whatever it
+// accepts reads back the same after marshalYAML
+func FuzzParseYAML(f *testing.F) {
+	for _, seed := range []string{
+		"a: 1\nb: [x, 'y']\n", "- k: v\n  l:\n  - 1\n", "a: |+\n  x\n\n", "{a: {b: \"c\"}}", "a: &x 1", "a: \"x\" # c\n",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		value, err := parseYAML([]byte(in))
+		if err != nil || !utf8.ValidString(in) {
+			return
+		}
+		data, err := marshalYAML(value)
+		if err != nil {
+			t.Fatalf("marshalYAML of a parsed value: %v", err)
+		}
+		again, err := parseYAML(data)
+		if err != nil {
+			t.Fatalf("re-parsing marshalled YAML: %v\n%s", err, data)
+		}
+		first, _ := json.Marshal(value)
+		second, _ := json.Marshal(again)
+		if !bytes.Equal(first, second) {
+			t.Fatalf("value changed on re-parse\n got %s\nwant %s\nyaml:\n%s", second, first, data)
+		}
+	})
+}